@@ -16,7 +16,9 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/fault"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
 )
 
 const defaultFlushPeriod = 1 * time.Second
@@ -29,6 +31,9 @@ type RegistryEntry struct {
 	Timestamp   string
 	Offset      int64
 	LastUpdated time.Time
+	Sequence    uint64 `json:",omitempty"`
+	// File identifies the file the offset belongs to, nil for the other sources
+	File *FileIdentity `json:",omitempty"`
 }
 
 // An Auditor handles messages successfully submitted to the intake
@@ -38,6 +43,13 @@ type Auditor struct {
 	registryMutex *sync.Mutex
 	registryPath  string
 
+	statePath    string
+	stateMutex   *sync.Mutex
+	readers      map[string]OffsetReader
+	buffers      map[string]func() BufferState
+	replayWindow *ReplayWindow
+	completions  *completions
+
 	flushTicker   *time.Ticker
 	flushPeriod   time.Duration
 	cleanupTicker *time.Ticker
@@ -45,13 +57,26 @@ type Auditor struct {
 	entryTTL      time.Duration
 }
 
-// New returns an initialized Auditor
+// New returns an initialized Auditor. When the agent is stateless,
+// the registry is only kept in memory
 func New(inputChan chan message.Message) *Auditor {
+	var registryPath, statePath string
+	if !config.IsStateless() {
+		registryPath = filepath.Join(config.LogsAgent.GetString("run_path"), "registry.json")
+		statePath = filepath.Join(config.LogsAgent.GetString("run_path"), "pipeline_state.json")
+	}
 	return &Auditor{
 		inputChan:     inputChan,
-		registryPath:  filepath.Join(config.LogsAgent.GetString("run_path"), "registry.json"),
+		registryPath:  registryPath,
 		registryMutex: &sync.Mutex{},
 
+		statePath:  statePath,
+		stateMutex: &sync.Mutex{},
+		readers:    make(map[string]OffsetReader),
+		buffers:    make(map[string]func() BufferState),
+
+		completions: newCompletions(),
+
 		flushPeriod:   defaultFlushPeriod,
 		cleanupPeriod: defaultCleanupPeriod,
 		entryTTL:      defaultTTL,
@@ -60,20 +85,42 @@ func New(inputChan chan message.Message) *Auditor {
 
 // Start starts the Auditor
 func (a *Auditor) Start() {
-	a.registry = a.recoverRegistry(a.registryPath)
+	a.recover()
+	status.Register("replay window", a.replayWindowStatus)
+	status.Register("completed streams", a.completions.status)
 	a.cleanupRegistry(a.registry)
 	go a.run()
-	go a.flushRegistryPediodically()
+	if a.registryPath != "" {
+		go a.flushRegistryPediodically()
+	}
 	go a.cleanupRegistryPeriodically()
 }
 
-// flushRegistryPediodically periodically saves the registry in its current state
+// recover rebuilds the registry and the replay window from the files of the previous run
+func (a *Auditor) recover() {
+	if a.registryPath == "" {
+		a.registry = make(map[string]*RegistryEntry)
+		return
+	}
+	state := a.recoverState(a.statePath)
+	a.registry = a.recoverRegistry(a.registryPath)
+	if len(a.registry) == 0 && state != nil {
+		// the registry was lost, fall back on the offsets of the last snapshot
+		a.registry = a.recoverRegistryFromState(state)
+	}
+	a.replayWindow = a.computeReplayWindow(state, a.registry)
+}
+
+// flushRegistryPediodically periodically saves the registry and the pipeline state
 func (a *Auditor) flushRegistryPediodically() {
 	a.flushTicker = time.NewTicker(a.flushPeriod)
 	for {
 		select {
 		case <-a.flushTicker.C:
 			err := a.flushRegistry(a.registry, a.registryPath)
+			if err == nil {
+				err = a.flushState(a.readOnlyRegistryCopy(a.registry), a.statePath)
+			}
 			if err != nil {
 				log.Println(err)
 			}
@@ -88,6 +135,7 @@ func (a *Auditor) cleanupRegistryPeriodically() {
 		select {
 		case <-a.cleanupTicker.C:
 			a.cleanupRegistry(a.registry)
+			a.completions.cleanup(time.Now().UTC().Add(-a.entryTTL))
 		}
 	}
 }
@@ -95,23 +143,31 @@ func (a *Auditor) cleanupRegistryPeriodically() {
 // run lets the auditor update the registry
 func (a *Auditor) run() {
 	for msg := range a.inputChan {
+		msg.GetOrigin().Acknowledge(true)
+		if message.IsEndOfStream(msg) {
+			a.completions.markComplete(msg)
+			continue
+		}
 		// An empty Identifier means that we don't want to track down the offset
 		// This is useful for origins that don't have offsets (networks), or when we
 		// specially want to avoid storing the offset
 		if msg.GetOrigin().Identifier != "" {
-			a.updateRegistry(msg.GetOrigin().Identifier, msg.GetOrigin().Offset, msg.GetOrigin().Timestamp)
+			a.updateRegistry(msg.GetOrigin().Identifier, msg.GetOrigin().Offset, msg.GetOrigin().Timestamp, msg.GetOrigin().SourceSequence)
 		}
 	}
 }
 
 // updateRegistry updates the offset of identifier in the auditor's registry
-func (a *Auditor) updateRegistry(identifier string, offset int64, timestamp string) {
+func (a *Auditor) updateRegistry(identifier string, offset int64, timestamp string, sequence uint64) {
+	file := a.fileIdentity(identifier)
 	a.registryMutex.Lock()
 	defer a.registryMutex.Unlock()
 	a.registry[identifier] = &RegistryEntry{
 		LastUpdated: time.Now().UTC(),
 		Offset:      offset,
 		Timestamp:   timestamp,
+		Sequence:    sequence,
+		File:        file,
 	}
 }
 
@@ -148,7 +204,7 @@ func (a *Auditor) flushRegistry(registry map[string]*RegistryEntry, path string)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(path, mr, 0644)
+	return writeFileAtomically(path, fault.CorruptRegistry(mr))
 }
 
 // GetLastCommitedOffset returns the last commited offset for a given identifier
@@ -161,6 +217,33 @@ func (a *Auditor) GetLastCommitedOffset(identifier string) (int64, int) {
 	return entry.Offset, os.SEEK_CUR
 }
 
+// MigrateIdentifier moves the registry entry of legacy to identifier,
+// unless identifier already has one
+func (a *Auditor) MigrateIdentifier(legacy, identifier string) {
+	a.registryMutex.Lock()
+	defer a.registryMutex.Unlock()
+	if _, ok := a.registry[identifier]; ok {
+		return
+	}
+	entry, ok := a.registry[legacy]
+	if !ok {
+		return
+	}
+	a.registry[identifier] = entry
+	delete(a.registry, legacy)
+}
+
+// GetLastCommitedSequence returns the sequence number of the last commited
+// message of a given identifier, 0 if it has none
+func (a *Auditor) GetLastCommitedSequence(identifier string) uint64 {
+	r := a.readOnlyRegistryCopy(a.registry)
+	entry, ok := r[identifier]
+	if !ok {
+		return 0
+	}
+	return entry.Sequence
+}
+
 // GetLastCommitedTimestamp returns the last commited offset for a given identifier
 func (a *Auditor) GetLastCommitedTimestamp(identifier string) string {
 	r := a.readOnlyRegistryCopy(a.registry)
@@ -52,14 +52,14 @@ func (suite *AuditorTestSuite) TearDownTest() {
 func (suite *AuditorTestSuite) TestAuditorUpdatesRegistry() {
 	suite.a.registry = make(map[string]*RegistryEntry)
 	suite.Equal(0, len(suite.a.registry))
-	suite.a.updateRegistry(suite.source.Path, 42, "")
+	suite.a.updateRegistry(suite.source.Path, 42, "", 0)
 	suite.Equal(1, len(suite.a.registry))
 	suite.Equal(int64(42), suite.a.registry[suite.source.Path].Offset)
 	suite.Equal("", suite.a.registry[suite.source.Path].Timestamp)
-	suite.a.updateRegistry(suite.source.Path, 43, "")
+	suite.a.updateRegistry(suite.source.Path, 43, "", 0)
 	suite.Equal(int64(43), suite.a.registry[suite.source.Path].Offset)
 	ts := time.Now().UTC().Format("2006-01-02T15:04:05.000000")
-	suite.a.updateRegistry("containerid", 0, ts)
+	suite.a.updateRegistry("containerid", 0, ts, 0)
 	suite.Equal(ts, suite.a.registry["containerid"].Timestamp)
 }
 
@@ -180,3 +180,78 @@ func (suite *AuditorTestSuite) TestAuditorUnmarshalRegistryV1() {
 func TestScannerTestSuite(t *testing.T) {
 	suite.Run(t, new(AuditorTestSuite))
 }
+
+func (suite *AuditorTestSuite) TestAuditorMigratesIdentifiers() {
+	suite.a.registry = map[string]*RegistryEntry{
+		"file:a.log": {Offset: 10},
+		"file:b.log": {Offset: 20},
+		"file:2":     {Offset: 30},
+	}
+	suite.a.MigrateIdentifier("file:a.log", "file:1")
+	suite.a.MigrateIdentifier("file:b.log", "file:2")
+	suite.a.MigrateIdentifier("file:c.log", "file:3")
+
+	offset, _ := suite.a.GetLastCommitedOffset("file:1")
+	suite.Equal(int64(10), offset)
+	suite.Nil(suite.a.registry["file:a.log"])
+	// the entry of the new identifier is the most recent
+	offset, _ = suite.a.GetLastCommitedOffset("file:2")
+	suite.Equal(int64(30), offset)
+	suite.Nil(suite.a.registry["file:3"])
+}
+
+func (suite *AuditorTestSuite) TestStatelessAuditorKeepsRegistryInMemory() {
+	config.LogsAgent.Set("log_stateless", true)
+	defer config.LogsAgent.Set("log_stateless", false)
+
+	a := New(suite.inputChan)
+	suite.Equal("", a.registryPath)
+	suite.Equal("", a.statePath)
+	a.recover()
+	a.updateRegistry(suite.source.Path, 42, "", 0)
+	offset, _ := a.GetLastCommitedOffset(suite.source.Path)
+	suite.Equal(int64(42), offset)
+}
+
+func (suite *AuditorTestSuite) TestAuditorPersistsSequences() {
+	suite.a.registry = make(map[string]*RegistryEntry)
+	suite.a.updateRegistry(suite.source.Path, 42, "", 7)
+	suite.a.updateRegistry("unnumbered", 42, "", 0)
+	suite.Nil(suite.a.flushRegistry(suite.a.registry, suite.testPath))
+
+	suite.a.registry = suite.a.recoverRegistry(suite.testPath)
+	suite.Equal(uint64(7), suite.a.GetLastCommitedSequence(suite.source.Path))
+	suite.Equal(uint64(0), suite.a.GetLastCommitedSequence("unnumbered"))
+	suite.Equal(uint64(0), suite.a.GetLastCommitedSequence("unknown"))
+}
+
+func (suite *AuditorTestSuite) TestAuditorAcknowledgesSentMessages() {
+	acked := make(chan bool, 1)
+	msg := message.NewNetworkMessage(nil)
+	msg.Origin = message.NewOrigin()
+	msg.Origin.Ack = func(sent bool) { acked <- sent }
+
+	suite.a.Start()
+	suite.inputChan <- msg
+	suite.True(<-acked)
+}
+
+func (suite *AuditorTestSuite) TestAuditorMarksStreamsComplete() {
+	origin := message.NewOrigin()
+	origin.LogSource = suite.source
+	origin.Path = testpath
+	acked := make(chan bool, 1)
+	msg := message.NewFileMessage(nil)
+	msg.Origin = message.NewOrigin()
+	msg.Origin.Ack = func(sent bool) { acked <- sent }
+
+	suite.a.Start()
+	suite.False(suite.a.IsComplete(testpath))
+	suite.inputChan <- message.NewEndOfStreamMessage(origin)
+	suite.inputChan <- msg
+	<-acked
+	suite.True(suite.a.IsComplete(testpath))
+
+	suite.a.completions.cleanup(time.Now().UTC().Add(time.Second))
+	suite.False(suite.a.IsComplete(testpath))
+}
@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package auditor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+// completedStreams counts the finite streams whose messages were all sent
+var completedStreams = metrics.NewCounter("logs_completed_streams")
+
+// completions records when the finite streams, such as rotated files, were complete
+type completions struct {
+	mutex     sync.Mutex
+	completed map[string]time.Time
+}
+
+func newCompletions() *completions {
+	return &completions{completed: make(map[string]time.Time)}
+}
+
+// streamName returns the name of the stream msg ends: the path of its file,
+// or the identifier of its source
+func streamName(msg message.Message) string {
+	origin := msg.GetOrigin()
+	if origin.Path != "" {
+		return origin.Path
+	}
+	return origin.LogSource.GetID()
+}
+
+// markComplete records that all the messages of the stream ended by msg were sent
+func (c *completions) markComplete(msg message.Message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.completed[streamName(msg)] = time.Now().UTC()
+	completedStreams.Add(1)
+}
+
+// isComplete returns true if the stream name is complete
+func (c *completions) isComplete(name string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.completed[name]
+	return ok
+}
+
+// cleanup forgets the streams completed before expireBefore
+func (c *completions) cleanup(expireBefore time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for name, completedAt := range c.completed {
+		if completedAt.Before(expireBefore) {
+			delete(c.completed, name)
+		}
+	}
+}
+
+// status lists the completed streams for the status
+func (c *completions) status() interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	streams := []string{}
+	for name, completedAt := range c.completed {
+		streams = append(streams, fmt.Sprintf("%s: completed at %s", name, completedAt.Format(time.RFC3339)))
+	}
+	sort.Strings(streams)
+	return streams
+}
+
+// IsComplete returns true if all the messages of the finite stream name,
+// such as the path of a rotated file, were sent
+func (a *Auditor) IsComplete(name string) bool {
+	return a.completions.isComplete(name)
+}
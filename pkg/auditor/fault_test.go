@@ -0,0 +1,45 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build faultinjection
+// +build faultinjection
+
+package auditor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditorRecoversFromCorruptedRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auditor")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	a := New(nil)
+	a.registryPath = filepath.Join(dir, "registry.json")
+	a.statePath = filepath.Join(dir, "pipeline_state.json")
+	a.registry = map[string]*RegistryEntry{"file:/var/log/a.log": {Offset: 42, LastUpdated: time.Now().UTC()}}
+	a.TrackReader(&mockReader{identifier: "file:/var/log/a.log", offset: 50})
+
+	fault.Set(fault.Faults{CorruptRegistry: true})
+	defer fault.Reset()
+	assert.Nil(t, a.flushRegistry(a.registry, a.registryPath))
+	assert.Nil(t, a.flushState(a.readOnlyRegistryCopy(a.registry), a.statePath))
+
+	// the agent restarts with a truncated registry
+	restarted := New(nil)
+	restarted.registryPath = a.registryPath
+	restarted.statePath = a.statePath
+	restarted.recover()
+	assert.Equal(t, int64(42), restarted.registry["file:/var/log/a.log"].Offset)
+	assert.Equal(t, int64(8), restarted.replayWindow.Sources["file:/var/log/a.log"])
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package auditor
+
+// A FileIdentity identifies the file an offset of the registry belongs to, beyond
+// its path: the device and inode tell apart the files successively created at a
+// path, and the fingerprint, a hash of the first FingerprintSize bytes of the file,
+// a file created with the inode of a deleted one, or on another file system mounted
+// at the same place
+type FileIdentity struct {
+	Device          uint64 `json:",omitempty"`
+	Inode           uint64 `json:",omitempty"`
+	Fingerprint     string `json:",omitempty"`
+	FingerprintSize int64  `json:",omitempty"`
+}
+
+// A FileReader is an OffsetReader reading a file, whose identity is
+// recorded along with its offsets
+type FileReader interface {
+	OffsetReader
+	FileIdentity() *FileIdentity
+}
+
+// fileIdentity returns the identity of the file read by the reader of identifier,
+// nil when it's not reading a file
+func (a *Auditor) fileIdentity(identifier string) *FileIdentity {
+	a.stateMutex.Lock()
+	reader, ok := a.readers[identifier].(FileReader)
+	a.stateMutex.Unlock()
+	if !ok {
+		return nil
+	}
+	return reader.FileIdentity()
+}
+
+// GetLastCommitedFile returns the identity of the file of the last commited
+// offset of a given identifier, nil if it's unknown
+func (a *Auditor) GetLastCommitedFile(identifier string) *FileIdentity {
+	r := a.readOnlyRegistryCopy(a.registry)
+	entry, ok := r[identifier]
+	if !ok {
+		return nil
+	}
+	return entry.File
+}
@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package auditor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockFileReader struct {
+	mockReader
+	identity *FileIdentity
+}
+
+func (r *mockFileReader) FileIdentity() *FileIdentity {
+	return r.identity
+}
+
+func TestAuditorRecordsFileIdentities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auditor")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.json")
+
+	a := New(nil)
+	a.registry = make(map[string]*RegistryEntry)
+	identity := &FileIdentity{Device: 2049, Inode: 1234, Fingerprint: "abcd", FingerprintSize: 1024}
+	a.TrackReader(&mockFileReader{mockReader{identifier: "file:1", offset: 42}, identity})
+	a.TrackReader(&mockReader{identifier: "tcp:1"})
+	a.updateRegistry("file:1", 42, "", 0)
+	a.updateRegistry("tcp:1", 0, "", 0)
+	assert.Nil(t, a.GetLastCommitedFile("tcp:1"))
+	assert.Nil(t, a.GetLastCommitedFile("file:2"))
+
+	assert.Nil(t, a.flushRegistry(a.registry, path))
+	restarted := New(nil)
+	restarted.registry = restarted.recoverRegistry(path)
+	assert.Equal(t, identity, restarted.GetLastCommitedFile("file:1"))
+	assert.Nil(t, restarted.GetLastCommitedFile("tcp:1"))
+}
@@ -0,0 +1,193 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package auditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An OffsetReader reports how far a source was read, whether or not
+// the messages read were acknowledged by the intake yet
+type OffsetReader interface {
+	Identifier() string
+	GetReadOffset() int64
+}
+
+// SourceState represents how far a source was read and acknowledged
+type SourceState struct {
+	Read  int64
+	Acked int64
+}
+
+// BufferState represents the indexes of a disk buffer: everything before
+// Committed was delivered, everything between Committed and Write is replayed after a restart
+type BufferState struct {
+	Committed int64
+	Read      int64
+	Write     int64
+}
+
+// PipelineState is a snapshot of the in-flight messages of the pipeline,
+// written on disk along with the registry
+type PipelineState struct {
+	Version   int
+	Timestamp time.Time
+	Sources   map[string]SourceState
+	Buffers   map[string]BufferState
+}
+
+// A ReplayWindow describes what was read but not acknowledged when the previous run stopped,
+// and will be sent again
+type ReplayWindow struct {
+	Snapshot time.Time
+	Sources  map[string]int64
+	Buffers  map[string]BufferState
+}
+
+// TrackReader adds the read offset of r to the pipeline state
+func (a *Auditor) TrackReader(r OffsetReader) {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	a.readers[r.Identifier()] = r
+}
+
+// UntrackReader removes the source identified by identifier from the pipeline state
+func (a *Auditor) UntrackReader(identifier string) {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	delete(a.readers, identifier)
+}
+
+// TrackBuffer adds the indexes of the disk buffer named name to the pipeline state
+func (a *Auditor) TrackBuffer(name string, indexes func() BufferState) {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	a.buffers[name] = indexes
+}
+
+// snapshotState returns the current state of the pipeline
+func (a *Auditor) snapshotState(registry map[string]RegistryEntry) PipelineState {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	state := PipelineState{
+		Version:   1,
+		Timestamp: time.Now().UTC(),
+		Sources:   make(map[string]SourceState),
+		Buffers:   make(map[string]BufferState),
+	}
+	for identifier, reader := range a.readers {
+		state.Sources[identifier] = SourceState{
+			Read:  reader.GetReadOffset(),
+			Acked: registry[identifier].Offset,
+		}
+	}
+	for name, indexes := range a.buffers {
+		state.Buffers[name] = indexes()
+	}
+	return state
+}
+
+// flushState writes on disk the state of the pipeline at the given path
+func (a *Auditor) flushState(registry map[string]RegistryEntry, path string) error {
+	b, err := json.Marshal(a.snapshotState(registry))
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path, b)
+}
+
+// recoverState reads the snapshot found at path, returning nil if there is none
+func (a *Auditor) recoverState(path string) *PipelineState {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return nil
+	}
+	var state PipelineState
+	err = json.Unmarshal(b, &state)
+	if err != nil {
+		log.Println("Can't read pipeline state:", err)
+		return nil
+	}
+	return &state
+}
+
+// recoverRegistryFromState rebuilds the registry from the offsets acknowledged in state,
+// when the registry itself couldn't be recovered
+func (a *Auditor) recoverRegistryFromState(state *PipelineState) map[string]*RegistryEntry {
+	registry := make(map[string]*RegistryEntry)
+	for identifier, source := range state.Sources {
+		registry[identifier] = &RegistryEntry{
+			Offset:      source.Acked,
+			LastUpdated: state.Timestamp,
+		}
+	}
+	return registry
+}
+
+// computeReplayWindow returns what will be sent again after the restart, knowing the
+// state of the pipeline when it stopped and the offsets that were acknowledged
+func (a *Auditor) computeReplayWindow(state *PipelineState, registry map[string]*RegistryEntry) *ReplayWindow {
+	if state == nil {
+		return nil
+	}
+	window := &ReplayWindow{
+		Snapshot: state.Timestamp,
+		Sources:  make(map[string]int64),
+		Buffers:  state.Buffers,
+	}
+	for identifier, source := range state.Sources {
+		acked := source.Acked
+		if entry, ok := registry[identifier]; ok && entry.Offset > acked {
+			acked = entry.Offset
+		}
+		if source.Read > acked {
+			window.Sources[identifier] = source.Read - acked
+		}
+	}
+	return window
+}
+
+// replayWindowStatus describes the replay window for the status
+func (a *Auditor) replayWindowStatus() interface{} {
+	window := a.replayWindow
+	if window == nil {
+		return "no previous pipeline state"
+	}
+	var total int64
+	sources := []string{}
+	for identifier, bytes := range window.Sources {
+		total += bytes
+		sources = append(sources, fmt.Sprintf("%s: %d bytes", identifier, bytes))
+	}
+	sort.Strings(sources)
+	for name, buffer := range window.Buffers {
+		sources = append(sources, fmt.Sprintf("%s: replaying from %d (read %d, written %d)", name, buffer.Committed, buffer.Read, buffer.Write))
+	}
+	description := fmt.Sprintf("%d bytes re-read from %d sources since %s", total, len(window.Sources), window.Snapshot.Format(time.RFC3339))
+	if len(sources) == 0 {
+		return description
+	}
+	return description + "; " + strings.Join(sources, ", ")
+}
+
+// writeFileAtomically writes b to path so that a crash never leaves a truncated file
+func writeFileAtomically(path string, b []byte) error {
+	err := ioutil.WriteFile(path+".tmp", b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package auditor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockReader struct {
+	identifier string
+	offset     int64
+}
+
+func (r *mockReader) Identifier() string {
+	return r.identifier
+}
+
+func (r *mockReader) GetReadOffset() int64 {
+	return r.offset
+}
+
+func TestAuditorFlushesAndRecoversState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auditor")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pipeline_state.json")
+
+	a := New(nil)
+	a.TrackReader(&mockReader{identifier: "file:/var/log/a.log", offset: 120})
+	a.TrackReader(&mockReader{identifier: "file:/var/log/b.log", offset: 10})
+	a.TrackBuffer("spool", func() BufferState { return BufferState{Committed: 5, Read: 8, Write: 13} })
+	registry := map[string]RegistryEntry{
+		"file:/var/log/a.log": {Offset: 100},
+		"file:/var/log/b.log": {Offset: 10},
+	}
+	assert.Nil(t, a.flushState(registry, path))
+
+	state := a.recoverState(path)
+	assert.NotNil(t, state)
+	assert.Equal(t, SourceState{Read: 120, Acked: 100}, state.Sources["file:/var/log/a.log"])
+	assert.Equal(t, SourceState{Read: 10, Acked: 10}, state.Sources["file:/var/log/b.log"])
+	assert.Equal(t, BufferState{Committed: 5, Read: 8, Write: 13}, state.Buffers["spool"])
+
+	a.UntrackReader("file:/var/log/b.log")
+	assert.Nil(t, a.flushState(registry, path))
+	assert.Equal(t, 1, len(a.recoverState(path).Sources))
+}
+
+func TestAuditorRecoversRegistryFromState(t *testing.T) {
+	a := New(nil)
+	state := &PipelineState{Sources: map[string]SourceState{"file:/var/log/a.log": {Read: 120, Acked: 100}}}
+	registry := a.recoverRegistryFromState(state)
+	assert.Equal(t, int64(100), registry["file:/var/log/a.log"].Offset)
+}
+
+func TestComputeReplayWindow(t *testing.T) {
+	a := New(nil)
+	assert.Nil(t, a.computeReplayWindow(nil, nil))
+
+	state := &PipelineState{
+		Sources: map[string]SourceState{
+			"file:/var/log/a.log": {Read: 120, Acked: 100},
+			"file:/var/log/b.log": {Read: 50, Acked: 20},
+			"file:/var/log/c.log": {Read: 10, Acked: 10},
+		},
+	}
+	// the registry may have been flushed after the snapshot
+	registry := map[string]*RegistryEntry{"file:/var/log/b.log": {Offset: 40}}
+	window := a.computeReplayWindow(state, registry)
+	assert.Equal(t, map[string]int64{"file:/var/log/a.log": 20, "file:/var/log/b.log": 10}, window.Sources)
+
+	a.replayWindow = window
+	assert.Contains(t, a.replayWindowStatus(), "30 bytes re-read from 2 sources")
+}
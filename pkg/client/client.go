@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+// Package client wraps the control API of a logs agent, served on log_control_port:
+// querying its status and sources, and registering sources at runtime with AddSource
+// and RemoveSource, which need an agent serving the runtime sources endpoints
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/control"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// A Client queries the control API of a logs agent, served on log_control_port,
+// for deployment tools and other agent components not to hand-roll the requests
+type Client struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// New returns a client of the control API served at address, such as localhost:5002
+func New(address string) *Client {
+	return &Client{
+		url:    "http://" + address,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// SetToken sets the token of the agent, written in run_path/control_token, which
+// the requests changing its state must carry
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Status returns the status of the agent, by component
+func (c *Client) Status() (map[string]interface{}, error) {
+	var s map[string]interface{}
+	err := c.get(control.StatusPath, &s)
+	return s, err
+}
+
+// Sources returns the settings of the sources collected by the agent, secrets scrubbed
+func (c *Client) Sources() ([]map[string]interface{}, error) {
+	var sources []map[string]interface{}
+	err := c.get(control.SourcesPath, &sources)
+	return sources, err
+}
+
+// AddSource adds the source described by settings, the settings of a source of
+// conf.d, to the running agent, returning its description once it's collected
+// or the error of its validation
+func (c *Client) AddSource(settings map[string]interface{}) (map[string]interface{}, error) {
+	body, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	var source map[string]interface{}
+	err = c.do("POST", control.SourcesPath, bytes.NewReader(body), http.StatusCreated, &source)
+	return source, err
+}
+
+// RemoveSource stops collecting the source with id, which must have been added with AddSource
+func (c *Client) RemoveSource(id string) error {
+	return c.do("DELETE", control.SourcesPath+"/"+url.PathEscape(id), nil, http.StatusNoContent, nil)
+}
+
+// PauseSource stops collecting the source with id until ResumeSource, its offsets
+// being kept, such as to quiet a flooding source
+func (c *Client) PauseSource(id string) error {
+	return c.do("POST", control.SourcesPath+"/"+url.PathEscape(id)+control.PausePath, nil, http.StatusNoContent, nil)
+}
+
+// ResumeSource collects the source with id paused by PauseSource again, from where it was left
+func (c *Client) ResumeSource(id string) error {
+	return c.do("POST", control.SourcesPath+"/"+url.PathEscape(id)+control.ResumePath, nil, http.StatusNoContent, nil)
+}
+
+// DisableSource stops collecting the source with id until EnableSource, across
+// restarts of the agent, its offsets being kept
+func (c *Client) DisableSource(id string) error {
+	return c.do("POST", control.SourcesPath+"/"+url.PathEscape(id)+control.DisablePath, nil, http.StatusNoContent, nil)
+}
+
+// EnableSource collects the source with id disabled by DisableSource again, from where it was left
+func (c *Client) EnableSource(id string) error {
+	return c.do("POST", control.SourcesPath+"/"+url.PathEscape(id)+control.EnablePath, nil, http.StatusNoContent, nil)
+}
+
+// get decodes the response to a GET request on path into value
+func (c *Client) get(path string, value interface{}) error {
+	return c.do("GET", path, nil, http.StatusOK, value)
+}
+
+// do sends a request on path, and decodes its response into value when not nil,
+// returning an error unless the response has the expected status code
+func (c *Client) do(method, path string, body io.Reader, expected int, value interface{}) error {
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+	if method != "GET" {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(control.TokenHeader, c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expected {
+		var apiErr control.Error
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("control API returned %s", resp.Status)
+		}
+		return fmt.Errorf("control API returned %s: %s", resp.Status, apiErr.Error)
+	}
+	if value == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
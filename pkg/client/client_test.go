@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package client
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/control"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+	"github.com/stretchr/testify/assert"
+)
+
+const testToken = "secret"
+
+func newTestClient() (*Client, *httptest.Server) {
+	control.SetToken(testToken)
+	server := httptest.NewServer(control.Handler())
+	c := New(strings.TrimPrefix(server.URL, "http://"))
+	c.SetToken(testToken)
+	return c, server
+}
+
+func TestStatus(t *testing.T) {
+	status.Set("test", "running")
+	c, server := newTestClient()
+	defer server.Close()
+
+	s, err := c.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, "running", s["test"])
+}
+
+func TestSources(t *testing.T) {
+	config.SetLogsSources([]*config.IntegrationConfigLogSource{{Type: config.TCP_TYPE, Port: 10514, ID: "tcp:10514"}})
+	defer config.SetLogsSources(nil)
+	c, server := newTestClient()
+	defer server.Close()
+
+	sources, err := c.Sources()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(sources))
+	assert.Equal(t, "tcp", sources[0]["type"])
+	assert.Equal(t, float64(10514), sources[0]["port"])
+}
+
+// fakeManager collects the sources added to it
+type fakeManager struct {
+	sources  map[string]*config.IntegrationConfigLogSource
+	paused   map[string]bool
+	disabled map[string]bool
+}
+
+func (m *fakeManager) AddSource(source *config.IntegrationConfigLogSource) error {
+	m.sources[source.ID] = source
+	return nil
+}
+
+func (m *fakeManager) RemoveSource(id string) error {
+	if _, exists := m.sources[id]; !exists {
+		return control.ErrSourceNotFound
+	}
+	delete(m.sources, id)
+	return nil
+}
+
+func (m *fakeManager) PauseSource(id string) error {
+	return m.setPaused(id, true)
+}
+
+func (m *fakeManager) ResumeSource(id string) error {
+	return m.setPaused(id, false)
+}
+
+func (m *fakeManager) DisableSource(id string) error {
+	return m.setDisabled(id, true)
+}
+
+func (m *fakeManager) EnableSource(id string) error {
+	return m.setDisabled(id, false)
+}
+
+func (m *fakeManager) setDisabled(id string, disabled bool) error {
+	if _, exists := m.sources[id]; !exists {
+		return control.ErrUnknownSource
+	}
+	m.disabled[id] = disabled
+	return nil
+}
+
+func (m *fakeManager) setPaused(id string, paused bool) error {
+	if _, exists := m.sources[id]; !exists {
+		return control.ErrUnknownSource
+	}
+	m.paused[id] = paused
+	return nil
+}
+
+func TestAddAndRemoveSource(t *testing.T) {
+	m := &fakeManager{sources: make(map[string]*config.IntegrationConfigLogSource), paused: make(map[string]bool), disabled: make(map[string]bool)}
+	control.SetSourcesManager(m)
+	defer control.SetSourcesManager(nil)
+	c, server := newTestClient()
+	defer server.Close()
+
+	source, err := c.AddSource(map[string]interface{}{"type": "tcp", "port": 10514})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(10514), source["port"])
+	id := source["id"].(string)
+	assert.NotNil(t, m.sources[id])
+
+	_, err = c.AddSource(map[string]interface{}{"type": "tcp"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "400")
+
+	c.SetToken("guess")
+	_, err = c.AddSource(map[string]interface{}{"type": "tcp", "port": 10515})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "401")
+	c.SetToken(testToken)
+
+	assert.Nil(t, c.RemoveSource(id))
+	assert.Equal(t, 0, len(m.sources))
+	assert.NotNil(t, c.RemoveSource(id))
+}
+
+func TestPauseAndResumeSource(t *testing.T) {
+	m := &fakeManager{sources: make(map[string]*config.IntegrationConfigLogSource), paused: make(map[string]bool), disabled: make(map[string]bool)}
+	m.sources["file:1"] = &config.IntegrationConfigLogSource{ID: "file:1", Type: config.FILE_TYPE, Path: "/var/log/app.log"}
+	control.SetSourcesManager(m)
+	defer control.SetSourcesManager(nil)
+	c, server := newTestClient()
+	defer server.Close()
+
+	assert.Nil(t, c.PauseSource("file:1"))
+	assert.True(t, m.paused["file:1"])
+	assert.Nil(t, c.ResumeSource("file:1"))
+	assert.False(t, m.paused["file:1"])
+	assert.Nil(t, c.DisableSource("file:1"))
+	assert.True(t, m.disabled["file:1"])
+	assert.Nil(t, c.EnableSource("file:1"))
+	assert.False(t, m.disabled["file:1"])
+
+	err := c.PauseSource("file:2")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestErrors(t *testing.T) {
+	c, server := newTestClient()
+	server.Close()
+	_, err := c.Status()
+	assert.NotNil(t, err)
+}
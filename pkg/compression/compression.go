@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The compression kinds of the payloads sent over http and stored in the spool
+const (
+	KindNone = "none"
+	KindGzip = "gzip"
+	KindZstd = "zstd"
+)
+
+// the magic numbers the compressed payloads start with
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// A Codec compresses payloads
+type Codec interface {
+	Compress(payload []byte) ([]byte, error)
+	// ContentEncoding is the value of the Content-Encoding header of the compressed payloads
+	ContentEncoding() string
+}
+
+// New returns the codec of kind compressing at level, 0 being the default level
+// of the kind, or nil for KindNone
+func New(kind string, level int) (Codec, error) {
+	if err := Validate(kind, level); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case KindGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return &gzipCodec{level: level}, nil
+	case KindZstd:
+		encoderLevel := zstd.SpeedDefault
+		if level != 0 {
+			encoderLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel))
+		if err != nil {
+			return nil, err
+		}
+		return &zstdCodec{encoder: encoder}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Validate returns an error if kind isn't a compression kind or level isn't one of its levels
+func Validate(kind string, level int) error {
+	switch kind {
+	case KindNone:
+		return nil
+	case KindGzip:
+		if level < 0 || level > gzip.BestCompression {
+			return fmt.Errorf("the gzip compression level must be between 1 and %d (got %d)", gzip.BestCompression, level)
+		}
+		return nil
+	case KindZstd:
+		if level < 0 || level > 22 {
+			return fmt.Errorf("the zstd compression level must be between 1 and 22 (got %d)", level)
+		}
+		return nil
+	default:
+		return fmt.Errorf("the compression kind must be %s, %s or %s (got %s)", KindGzip, KindZstd, KindNone, kind)
+	}
+}
+
+// Decompress returns payload decompressed according to its magic number,
+// the payloads which aren't compressed being returned as they are
+func Decompress(payload []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(payload, gzipMagic):
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	case bytes.HasPrefix(payload, zstdMagic):
+		return zstdDecoder.DecodeAll(payload, nil)
+	default:
+		return payload, nil
+	}
+}
+
+// zstdDecoder decodes the zstd payloads, concurrently
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+type gzipCodec struct {
+	level int
+}
+
+func (c *gzipCodec) Compress(payload []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&compressed, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func (c *gzipCodec) ContentEncoding() string {
+	return "gzip"
+}
+
+// zstdCodec compresses with an encoder shared by the senders, EncodeAll being
+// safe for concurrent use
+type zstdCodec struct {
+	encoder *zstd.Encoder
+}
+
+func (c *zstdCodec) Compress(payload []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(payload, nil), nil
+}
+
+func (c *zstdCodec) ContentEncoding() string {
+	return "zstd"
+}
@@ -0,0 +1,45 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package compression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressAndDecompress(t *testing.T) {
+	payload := []byte("api_key <46>0 2017-10-16T10:00:00Z host app - - - hello world\n")
+	for _, kind := range []string{KindGzip, KindZstd} {
+		codec, err := New(kind, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, kind, codec.ContentEncoding())
+		compressed, err := codec.Compress(payload)
+		assert.Nil(t, err)
+		decompressed, err := Decompress(compressed)
+		assert.Nil(t, err)
+		assert.Equal(t, payload, decompressed)
+	}
+
+	decompressed, err := Decompress(payload)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, decompressed)
+}
+
+func TestNewWithoutCompression(t *testing.T) {
+	codec, err := New(KindNone, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, codec)
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate(KindGzip, 0))
+	assert.Nil(t, Validate(KindGzip, 9))
+	assert.Nil(t, Validate(KindZstd, 19))
+	assert.NotNil(t, Validate(KindGzip, 10))
+	assert.NotNil(t, Validate(KindZstd, 23))
+	assert.NotNil(t, Validate("lz4", 0))
+}
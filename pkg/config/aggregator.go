@@ -0,0 +1,21 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+)
+
+// IsForwardingToAggregator returns true if the agent is an edge agent forwarding
+// its logs to an aggregator agent instead of the intake
+func IsForwardingToAggregator() bool {
+	return LogsAgent.GetString("log_aggregator_host") != ""
+}
+
+// AggregatorAddress returns the host:port of the aggregator agent
+func AggregatorAddress() string {
+	return fmt.Sprintf("%s:%d", LogsAgent.GetString("log_aggregator_host"), LogsAgent.GetInt("log_aggregator_port"))
+}
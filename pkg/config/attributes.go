@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxAttributeNameLen is the maximum length of a parameter name in RFC5424
+const maxAttributeNameLen = 32
+
+// reservedAttributes are the parameters of the tags payload set by the agent
+var reservedAttributes = []string{"ddsource", "ddsourcecategory", "ddtags"}
+
+// validateAttributes checks that the names of attributes can be sent as
+// parameters of the structured data of the tags payload
+func validateAttributes(attributes map[string]string) error {
+	for _, name := range sortedAttributeNames(attributes) {
+		if err := validateAttributeName(name); err != nil {
+			return newSourceError("invalid attribute `%s`: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func validateAttributeName(name string) error {
+	if name == "" {
+		return fmt.Errorf("attribute names can't be empty")
+	}
+	if len(name) > maxAttributeNameLen {
+		return fmt.Errorf("attribute names can't be longer than %d characters", maxAttributeNameLen)
+	}
+	for _, reserved := range reservedAttributes {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("%s is set by the agent", reserved)
+		}
+	}
+	for _, r := range name {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return fmt.Errorf("attribute names can only contain printable ascii characters, except '=', ']' and '\"'")
+		}
+	}
+	return nil
+}
+
+// sortedAttributeNames returns the names of attributes, sorted so that
+// payloads are stable
+func sortedAttributeNames(attributes map[string]string) []string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
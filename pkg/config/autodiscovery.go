@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+const (
+	// AutodiscoveryLogsLabel is the container label holding the log configs of a
+	// container, as a json list of sources, as the datadog agent reads it
+	AutodiscoveryLogsLabel = "com.datadoghq.ad.logs"
+
+	// autodiscoveryKey enables the autodiscovery of the log configs of containers
+	autodiscoveryKey = "log_container_autodiscovery"
+)
+
+// IsAutodiscoveryEnabled returns true if the log configs of the containers
+// are read from their labels
+func IsAutodiscoveryEnabled() bool {
+	return LogsAgent.GetBool(autodiscoveryKey)
+}
+
+// ParseAutodiscoveryLogs returns the docker source of the container running image
+// described by the value of its AutodiscoveryLogsLabel, with the global processing
+// rules, the rules with invalid patterns being skipped. Only the first config of the
+// list is used, a container having one output
+func ParseAutodiscoveryLogs(value, image string) (*IntegrationConfigLogSource, error) {
+	globalRules, err := getGlobalProcessingRules(LogsAgent)
+	if err != nil {
+		return nil, err
+	}
+	return parseAutodiscoveryLogs(value, image, globalRules)
+}
+
+func parseAutodiscoveryLogs(value, image string, globalRules []LogsProcessingRule) (*IntegrationConfigLogSource, error) {
+	var configs []map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, fmt.Errorf("invalid %s label: %v", AutodiscoveryLogsLabel, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("invalid %s label: no log config", AutodiscoveryLogsLabel)
+	}
+	settings := configs[0]
+	if sourceType, ok := settings["type"]; ok && sourceType != DOCKER_TYPE {
+		return nil, fmt.Errorf("invalid %s label: the type of the logs of a container must be %s", AutodiscoveryLogsLabel, DOCKER_TYPE)
+	}
+	settings["type"] = DOCKER_TYPE
+	settings["image"] = image
+	source, skippedRules, err := checkSource(settings, nil, nil, globalRules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s label: %v", AutodiscoveryLogsLabel, err)
+	}
+	for _, ruleErr := range skippedRules {
+		log.Printf("Skipping a rule of the %s label of %s: %v", AutodiscoveryLogsLabel, image, ruleErr)
+	}
+	return source, nil
+}
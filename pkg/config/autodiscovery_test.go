@@ -0,0 +1,31 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAutodiscoveryLogs(t *testing.T) {
+	globalRules := []LogsProcessingRule{{Name: "exclude_healthchecks", Type: EXCLUDE_AT_MATCH, Pattern: "GET /health"}}
+	source, err := parseAutodiscoveryLogs(`[{"source":"nginx","service":"web","tags":["env:prod"],"log_processing_rules":[{"type":"mask_sequences","name":"cards","pattern":"[0-9]{16}","replace_placeholder":"[card]"},{"type":"exclude_at_match","name":"broken","pattern":"("}]}]`, "nginx:latest", globalRules)
+	assert.Nil(t, err)
+	assert.Equal(t, DOCKER_TYPE, source.Type)
+	assert.Equal(t, "nginx:latest", source.Image)
+	assert.Equal(t, "web", source.Service)
+	assert.Equal(t, "env:prod", source.Tags)
+	assert.NotEmpty(t, source.ID)
+	assert.Equal(t, 2, len(source.ProcessingRules))
+	assert.Equal(t, "exclude_healthchecks", source.ProcessingRules[0].Name)
+	assert.Equal(t, "cards", source.ProcessingRules[1].Name)
+
+	for _, value := range []string{`{"source":"nginx"}`, `[]`, `[{"type":"file","path":"/var/log/app.log"}]`, `[{"priority":"urgent"}]`} {
+		_, err = parseAutodiscoveryLogs(value, "nginx:latest", nil)
+		assert.NotNil(t, err, value)
+	}
+}
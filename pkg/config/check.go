@@ -0,0 +1,193 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// A FileReport lists the misconfigurations of a config file
+type FileReport struct {
+	File     string
+	Sources  int
+	Errors   []error
+	Warnings []string
+}
+
+// CheckConfig validates datadog.yaml and every integration config file of ddconfdPath
+// as the agent would load them, without starting anything, and returns a report per
+// file. Every source of every file is checked, the invalid ones being reported
+// as errors as the agent skips them
+func CheckConfig(ddconfigPath, ddconfdPath string) []*FileReport {
+	config := viper.New()
+	config.SetConfigFile(ddconfigPath)
+	bindEnvironment(config)
+	mainReport := &FileReport{File: filepath.Base(ddconfigPath)}
+	reports := []*FileReport{mainReport}
+	if err := config.ReadInConfig(); err != nil {
+		mainReport.Errors = append(mainReport.Errors, err)
+	}
+	setDefaults(config)
+	if err := interpolateEnv(config, config); err != nil {
+		mainReport.Errors = append(mainReport.Errors, fmt.Errorf("can't interpolate environment variables: %v", err))
+	}
+	if err := resolveSecrets(config, config); err != nil {
+		mainReport.Errors = append(mainReport.Errors, fmt.Errorf("can't resolve secrets: %v", err))
+	}
+	mainReport.Warnings = append(mainReport.Warnings, applyDeprecations(config)...)
+	for _, validate := range []func(*viper.Viper) error{validateTimestampFormat, validateExcludedPaths, validateContainerRuntime, validateCompression, validateOpenFilesLimit, validateEndpoints} {
+		if err := validate(config); err != nil {
+			mainReport.Errors = append(mainReport.Errors, err)
+		}
+	}
+	globalRules, err := getGlobalProcessingRules(config)
+	if err != nil {
+		mainReport.Errors = append(mainReport.Errors, err)
+	}
+
+	namespaces, namespaceErrors := readNamespaces(config, ddconfdPath)
+	for _, name := range sortedNamespaceNames(namespaceErrors) {
+		report := &FileReport{File: filepath.Join(name, namespaceConfName), Errors: []error{namespaceErrors[name]}}
+		reports = append(reports, report)
+	}
+
+	files := availableIntegrationConfigs(ddconfdPath)
+	if os.Getenv(LogsSourcesEnv) != "" {
+		files = append(files, LogsSourcesEnv)
+	}
+	readable := []string{}
+	viperCfgs := make(map[string]*viper.Viper, len(files))
+	fileReports := make(map[string]*FileReport, len(files))
+	for _, file := range files {
+		report := &FileReport{File: file}
+		reports = append(reports, report)
+		fileReports[file] = report
+		if namespace := namespaceOf(file); namespaceErrors[namespace] != nil {
+			report.Errors = append(report.Errors, newFileError(file, fmt.Errorf("skipped as the namespace %s is invalid", namespace)))
+			continue
+		}
+		viperCfg, err := readIntegrationConfig(config, ddconfdPath, file)
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			continue
+		}
+		viperCfgs[file] = viperCfg
+		readable = append(readable, file)
+	}
+	templates, err := collectTemplates(readable, viperCfgs)
+	if err != nil {
+		if cfgErr, ok := err.(*ConfigError); ok && fileReports[cfgErr.File] != nil {
+			fileReports[cfgErr.File].Errors = append(fileReports[cfgErr.File].Errors, err)
+		} else {
+			mainReport.Errors = append(mainReport.Errors, err)
+		}
+	}
+
+	collectedBy := make(map[string]string)
+	for _, file := range readable {
+		report := fileReports[file]
+		viperCfg := viperCfgs[file]
+		content := integrationConfigContent(ddconfdPath, file)
+		if !viperCfg.IsSet("logs") {
+			if len(viperCfg.GetStringMap(templatesKey)) == 0 {
+				report.Warnings = append(report.Warnings, "no logs section, the file has no effect")
+			}
+			continue
+		}
+		sources, err := cast.ToSliceE(viperCfg.Get("logs"))
+		if err != nil {
+			report.Errors = append(report.Errors, newFileError(file, err))
+			continue
+		}
+		for i, settings := range sources {
+			settings, err := resolveIncludes(config, settings, includeDir(ddconfdPath, file))
+			if err != nil {
+				report.Errors = append(report.Errors, locateError(err, file, content, i))
+				continue
+			}
+			source, skippedRules, err := checkSource(settings, templates, namespaces[namespaceOf(file)], globalRules)
+			for _, ruleErr := range skippedRules {
+				report.Errors = append(report.Errors, locateError(ruleErr, file, content, i))
+			}
+			if err != nil {
+				report.Errors = append(report.Errors, locateError(err, file, content, i))
+				continue
+			}
+			report.Sources++
+			if isInputDisabled(config, source.Type) {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("logs[%d]: ignored, %s sources are disabled by %s", i, source.Type, inputSwitches[source.Type]))
+			}
+			location := fmt.Sprintf("%s logs[%d]", file, i)
+			if other, ok := collectedBy[source.ID]; ok && file != LogsSourcesEnv {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("logs[%d]: collects the same logs as %s", i, other))
+			}
+			collectedBy[source.ID] = location
+			if source.Type == FILE_TYPE && !source.IsPattern() && IsExcludedPath(source.Path) {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("logs[%d]: %s is excluded by %s", i, source.Path, ExcludedPathsKey))
+			} else if source.Type == FILE_TYPE && !source.IsPattern() {
+				if _, err := os.Stat(source.Path); err != nil {
+					report.Warnings = append(report.Warnings, fmt.Sprintf("logs[%d]: %s can't be read yet: %v", i, source.Path, err))
+				}
+			}
+		}
+	}
+	return reports
+}
+
+// checkSource returns the source of settings once resolved, merged over the defaults
+// of its namespace if any, validated and prepared,
+// along with the errors of the processing rules skipped as their pattern is invalid
+func checkSource(settings interface{}, templates map[string]sourceTemplate, namespace *Namespace, globalRules []LogsProcessingRule) (*IntegrationConfigLogSource, []error, error) {
+	resolved, err := resolveSource(settings, templates)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved = namespace.apply(resolved)
+	// decoded alone, as the agent decodes the logs section
+	single := viper.New()
+	single.Set("logs", []interface{}{resolved})
+	var integrationConfig IntegrationConfig
+	if err := single.Unmarshal(&integrationConfig); err != nil {
+		return nil, nil, newSourceError("%v", err)
+	}
+	source := integrationConfig.Logs[0]
+	source.Namespace, source.NamespaceMaxBytesPerSecond = "", 0
+	if namespace != nil {
+		source.Namespace, source.NamespaceMaxBytesPerSecond = namespace.Name, namespace.MaxBytesPerSecond
+	}
+	if err := validateSource(source); err != nil {
+		return nil, nil, err
+	}
+	var skippedRules []error
+	source.ProcessingRules, skippedRules = skipInvalidPatterns(source.ProcessingRules)
+	if err := prepareSource(&source, globalRules); err != nil {
+		return nil, skippedRules, err
+	}
+	return &source, skippedRules, nil
+}
+
+// skipInvalidPatterns returns rules without those whose pattern isn't a valid
+// regular expression, and their errors, so that a typo in a pattern only
+// disables its rule rather than the whole source
+func skipInvalidPatterns(rules []LogsProcessingRule) ([]LogsProcessingRule, []error) {
+	var valid []LogsProcessingRule
+	var errs []error
+	for _, rule := range rules {
+		if rule.Pattern != "" && rule.Type != MULTILINE_CONTINUATION {
+			if _, err := compileRulePattern(rule, rule.Pattern); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		valid = append(valid, rule)
+	}
+	return valid, errs
+}
@@ -0,0 +1,42 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConfig(t *testing.T) {
+	reports := CheckConfig(filepath.Join(testsPath, "check", "datadog.yaml"), filepath.Join(testsPath, "check", "conf.d"))
+	assert.Equal(t, 4, len(reports))
+
+	assert.Equal(t, "datadog.yaml", reports[0].File)
+	assert.Equal(t, 0, len(reports[0].Errors))
+
+	broken := reports[1]
+	assert.Equal(t, "broken.yaml", broken.File)
+	// the source with an invalid pattern is collected without its rule
+	assert.Equal(t, 2, broken.Sources)
+	assert.Equal(t, 2, len(broken.Errors))
+	assert.Equal(t, 0, broken.Errors[0].(*ConfigError).SourceIndex)
+	assert.Equal(t, 2, broken.Errors[1].(*ConfigError).SourceIndex)
+	assert.Equal(t, "bad_regex", broken.Errors[1].(*ConfigError).RuleName)
+	assert.Equal(t, 13, broken.Errors[1].(*ConfigError).Line)
+
+	empty := reports[2]
+	assert.Equal(t, "empty.yaml", empty.File)
+	assert.Equal(t, 0, len(empty.Errors))
+	assert.Equal(t, 1, len(empty.Warnings))
+
+	web := reports[3]
+	assert.Equal(t, "web.yaml", web.File)
+	assert.Equal(t, 2, web.Sources)
+	assert.Equal(t, 0, len(web.Errors))
+	assert.Equal(t, 1, len(web.Warnings))
+}
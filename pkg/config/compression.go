@@ -0,0 +1,33 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
+	"github.com/spf13/viper"
+)
+
+// The compression of the payloads sent over http and stored in the spool
+const (
+	CompressionKindKey  = "logs_config.compression_kind"
+	CompressionLevelKey = "logs_config.compression_level"
+)
+
+// CompressionCodec returns the codec compressing the payloads sent over http
+// and stored in the spool, nil when they aren't compressed
+func CompressionCodec() (compression.Codec, error) {
+	return compression.New(LogsAgent.GetString(CompressionKindKey), LogsAgent.GetInt(CompressionLevelKey))
+}
+
+// validateCompression checks the compression kind and level
+func validateCompression(config *viper.Viper) error {
+	if err := compression.Validate(config.GetString(CompressionKindKey), config.GetInt(CompressionLevelKey)); err != nil {
+		return fmt.Errorf("invalid %s: %v", CompressionKindKey, err)
+	}
+	return nil
+}
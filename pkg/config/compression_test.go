@@ -0,0 +1,27 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCompression(t *testing.T) {
+	config := viper.New()
+	setDefaults(config)
+	assert.Nil(t, validateCompression(config))
+	config.Set(CompressionKindKey, compression.KindZstd)
+	config.Set(CompressionLevelKey, 19)
+	assert.Nil(t, validateCompression(config))
+	config.Set(CompressionKindKey, compression.KindGzip)
+	assert.NotNil(t, validateCompression(config))
+	config.Set(CompressionKindKey, "lz4")
+	assert.NotNil(t, validateCompression(config))
+}
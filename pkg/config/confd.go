@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// confdDirs returns the directories of ddconfdPath, a list of directories
+// separated as in PATH, such as a read-only base directory baked into an image
+// followed by a writable directory overriding it
+func confdDirs(ddconfdPath string) []string {
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(ddconfdPath) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return []string{ddconfdPath}
+	}
+	return dirs
+}
+
+// confdFile returns the path of file, relative to the conf.d directories, in the
+// last directory of ddconfdPath having it, as later directories override earlier ones
+func confdFile(ddconfdPath, file string) string {
+	dirs := confdDirs(ddconfdPath)
+	for i := len(dirs) - 1; i > 0; i-- {
+		if _, err := os.Stat(filepath.Join(dirs[i], file)); err == nil {
+			return filepath.Join(dirs[i], file)
+		}
+	}
+	return filepath.Join(dirs[0], file)
+}
+
+// mergeConfdFiles returns the files listed in each conf.d directory, once, those
+// of a directory overriding the files of the same name of the previous ones
+func mergeConfdFiles(dirs []string, filesByDir [][]string) []string {
+	merged := []string{}
+	origins := make(map[string]string)
+	for i, files := range filesByDir {
+		for _, file := range files {
+			if origin, ok := origins[file]; ok {
+				log.Printf("%s of %s overrides the one of %s", file, dirs[i], origin)
+			} else {
+				merged = append(merged, file)
+			}
+			origins[file] = dirs[i]
+		}
+	}
+	return dropOverriddenDefaults(merged)
+}
+
+// dropOverriddenDefaults removes the conf.yaml.default files of the <integration>.d
+// directories having a conf.yaml in another conf.d directory
+func dropOverriddenDefaults(files []string) []string {
+	listed := make(map[string]bool, len(files))
+	for _, file := range files {
+		listed[file] = true
+	}
+	kept := []string{}
+	for _, file := range files {
+		if filepath.Base(file) == defaultConfName+defaultConfSuffix {
+			if listed[strings.TrimSuffix(file, defaultConfSuffix)] {
+				continue
+			}
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
@@ -0,0 +1,40 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfdDirs(t *testing.T) {
+	assert.Equal(t, []string{"conf.d"}, confdDirs("conf.d"))
+	assert.Equal(t, []string{""}, confdDirs(""))
+	separator := string(os.PathListSeparator)
+	assert.Equal(t, []string{"base", "override"}, confdDirs("base"+separator+separator+"override"))
+}
+
+func TestLoadLogsSourcesFromSeveralConfdDirs(t *testing.T) {
+	base := filepath.Join(testsPath, "confd_paths", "base")
+	override := filepath.Join(testsPath, "confd_paths", "override")
+	ddconfdPath := base + string(os.PathListSeparator) + override
+
+	assert.Equal(t, []string{"app.yaml", "redis.yaml", filepath.Join("nginx.d", "conf.yaml")}, availableIntegrationConfigs(ddconfdPath))
+	assert.Equal(t, filepath.Join(override, "redis.yaml"), confdFile(ddconfdPath, "redis.yaml"))
+	assert.Equal(t, filepath.Join(base, "app.yaml"), confdFile(ddconfdPath, "app.yaml"))
+
+	sources, configErrors, err := loadLogsSources(viper.New(), ddconfdPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(configErrors))
+	assert.Equal(t, 3, len(sources))
+	assert.Equal(t, "app", sources[0].Service)
+	assert.Equal(t, "/var/log/redis/redis.log", sources[1].Path)
+	assert.Equal(t, "env:prod", sources[2].Tags)
+}
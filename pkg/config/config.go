@@ -6,10 +6,11 @@
 package config
 
 import (
-	"log"
+	"fmt"
 
 	ddconfig "github.com/DataDog/datadog-agent/pkg/config"
-	"github.com/DataDog/datadog-agent/pkg/util"
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
 	"github.com/spf13/viper"
 )
 
@@ -27,22 +28,46 @@ func BuildLogsAgentConfig(ddconfigPath, ddconfdPath string) error {
 func buildMainConfig(config *viper.Viper, ddconfigPath, ddconfdPath string) error {
 
 	config.SetConfigFile(ddconfigPath)
+	bindEnvironment(config)
 
 	err := config.ReadInConfig()
 	if err != nil {
 		return err
 	}
 
-	// For hostname, use value from config if set and non empty,
-	// or fallback on agent6's logic
-	if config.GetString("hostname") == "" {
-		hostname, err := util.GetHostname()
-		if err != nil {
-			log.Println(err)
-			hostname = "unknown"
-		}
-		config.Set("hostname", hostname)
+	setDefaults(config)
+	if err := interpolateEnv(config, config); err != nil {
+		return fmt.Errorf("can't interpolate the environment variables of %s: %v", ddconfigPath, err)
 	}
+	if err := resolveSecrets(config, config); err != nil {
+		return fmt.Errorf("can't resolve the secrets of %s: %v", ddconfigPath, err)
+	}
+	reportDeprecations(applyDeprecations(config))
+	if err := validateTimestampFormat(config); err != nil {
+		return err
+	}
+	if err := validateExcludedPaths(config); err != nil {
+		return err
+	}
+	if err := validateContainerRuntime(config); err != nil {
+		return err
+	}
+	if err := validateCompression(config); err != nil {
+		return err
+	}
+	if err := validateOpenFilesLimit(config); err != nil {
+		return err
+	}
+	if err := validateEndpoints(config); err != nil {
+		return err
+	}
+	checkRunPath(config)
+	loadDisabledSources(config)
+	initRemoteConfig(config)
+
+	hostname, strategy := newHostnameResolver(config).resolve()
+	config.Set("hostname", hostname)
+	status.Set("hostname", fmt.Sprintf("%s (resolved from %s)", hostname, strategy))
 
 	err = BuildLogsAgentIntegrationsConfigs(ddconfdPath)
 	if err != nil {
@@ -50,3 +75,60 @@ func buildMainConfig(config *viper.Viper, ddconfigPath, ddconfdPath string) erro
 	}
 	return nil
 }
+
+// setDefaults sets the default values of the logs agent specific settings
+func setDefaults(config *viper.Viper) {
+	config.SetDefault("log_use_http", false)
+	config.SetDefault("log_dd_http_url", "https://http-intake.logs.datadoghq.com/v1/input")
+	config.SetDefault("log_send_max_retries", 5)
+	config.SetDefault("log_dns_refresh_interval", "5m")
+	config.SetDefault("log_latency_budget", "0s")
+	config.SetDefault("log_offline_mode", false)
+	config.SetDefault("log_spool_max_size", 1024*1024*1024)
+	config.SetDefault("log_spool_max_upload_bytes_per_second", 0)
+	config.SetDefault("max_upload_bytes_per_second", 0)
+	config.SetDefault("log_clock_skew_threshold", "1m")
+	config.SetDefault("log_hostname_use_cloud_metadata", false)
+	config.SetDefault("log_hostname_fqdn", false)
+	config.SetDefault("log_tag_cardinality", "high")
+	config.SetDefault(containerRuntimeKey, ContainerRuntimeAuto)
+	config.SetDefault(autodiscoveryKey, false)
+	config.SetDefault("log_check_for_updates", false)
+	config.SetDefault("log_backfill_max_bytes_per_second", 1024*1024)
+	config.SetDefault("log_device_max_read_bytes_per_second", 0)
+	config.SetDefault("log_aggregator_host", "")
+	config.SetDefault("log_aggregator_port", 10518)
+	config.SetDefault("log_aggregator_use_ssl", true)
+	config.SetDefault("log_pipelines", 0)
+	config.SetDefault("log_gomaxprocs", 0)
+	config.SetDefault("log_origin_attributes", false)
+	config.SetDefault("log_timestamp_format", TIMESTAMP_FORMAT_RFC3339_NANO)
+	config.SetDefault(strictInterpolationKey, false)
+	config.SetDefault("log_file", "")
+	config.SetDefault("log_spool_encryption_key_secret", "")
+	config.SetDefault(CompressionKindKey, compression.KindNone)
+	config.SetDefault(CompressionLevelKey, 0)
+	config.SetDefault("secret_backend_command", "")
+	config.SetDefault("secret_backend_arguments", []string{})
+	config.SetDefault("secret_backend_timeout", defaultSecretBackendTimeout)
+	config.SetDefault("log_reload_probation", "1m")
+	config.SetDefault("log_reload_max_failures", 100)
+	config.SetDefault("log_health_port", 0)
+	config.SetDefault("log_control_port", 0)
+	config.SetDefault("log_dead_letter_file", "")
+	config.SetDefault("log_dead_letter_max_size", 100*1024*1024)
+	config.SetDefault("log_remote_config_url", "")
+	config.SetDefault("log_remote_config_secret", "")
+	config.SetDefault("log_remote_config_interval", defaultRemoteConfigPoll)
+	config.SetDefault("log_redis_address", "")
+	config.SetDefault("log_redis_stream", "datadog-logs")
+	config.SetDefault("log_redis_stream_max_len", 100000)
+	config.SetDefault("log_nats_address", "")
+	config.SetDefault("log_nats_subject", "datadog.logs")
+	config.SetDefault(statelessKey, false)
+	config.SetDefault(DisableFileCollection, false)
+	config.SetDefault(DisableNetworkListeners, false)
+	config.SetDefault(DisableContainerCollection, false)
+	config.SetDefault(ExcludedPathsKey, []string{})
+	config.SetDefault(OpenFilesLimitKey, 0)
+}
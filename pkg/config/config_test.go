@@ -11,6 +11,7 @@ import (
 
 	ddconfig "github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/util"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -45,31 +46,17 @@ func TestDDConfigDefaultValues(t *testing.T) {
 }
 
 func TestComputeConfigWithMisconfiguredFile(t *testing.T) {
-	var testConfig = viper.New()
-	var ddconfigPath, ddconfdPath string
-	var err error
-	ddconfigPath = filepath.Join(testsPath, "misconfigured_1", "datadog.yaml")
-	ddconfdPath = filepath.Join(testsPath, "misconfigured_1")
-	err = buildMainConfig(testConfig, ddconfigPath, ddconfdPath)
-	assert.NotNil(t, err)
-
-	ddconfigPath = filepath.Join(testsPath, "misconfigured_2", "datadog.yaml")
-	ddconfdPath = filepath.Join(testsPath, "misconfigured_2", "conf.d")
-	err = buildMainConfig(testConfig, ddconfigPath, ddconfdPath)
-	assert.NotNil(t, err)
-
-	ddconfigPath = filepath.Join(testsPath, "misconfigured_3", "datadog.yaml")
-	ddconfdPath = filepath.Join(testsPath, "misconfigured_3", "conf.d")
-	err = buildMainConfig(testConfig, ddconfigPath, ddconfdPath)
-	assert.NotNil(t, err)
-
-	ddconfigPath = filepath.Join(testsPath, "misconfigured_4", "datadog.yaml")
-	ddconfdPath = filepath.Join(testsPath, "misconfigured_4", "conf.d")
-	err = buildMainConfig(testConfig, ddconfigPath, ddconfdPath)
-	assert.NotNil(t, err)
-
-	ddconfigPath = filepath.Join(testsPath, "misconfigured_5", "datadog.yaml")
-	ddconfdPath = filepath.Join(testsPath, "misconfigured_5", "conf.d")
-	err = buildMainConfig(testConfig, ddconfigPath, ddconfdPath)
-	assert.NotNil(t, err)
+	// the invalid sources are skipped and reported, the agent starting anyway
+	for _, dir := range []string{"misconfigured_1", "misconfigured_2", "misconfigured_3", "misconfigured_4", "misconfigured_5"} {
+		var testConfig = viper.New()
+		ddconfigPath := filepath.Join(testsPath, dir, "datadog.yaml")
+		ddconfdPath := filepath.Join(testsPath, dir, "conf.d")
+		if dir == "misconfigured_1" {
+			ddconfdPath = filepath.Join(testsPath, dir)
+		}
+		err := buildMainConfig(testConfig, ddconfigPath, ddconfdPath)
+		assert.Nil(t, err, dir)
+		assert.Equal(t, 0, len(GetLogsSources()), dir)
+		assert.Equal(t, 1, len(status.Get()["config errors"].([]map[string]interface{})), dir)
+	}
 }
@@ -8,7 +8,8 @@ package config
 // Technical constants
 
 const (
-	ChanSizes         = 100
+	ChanSizes = 100
+	// NumberOfPipelines is the number of pipelines when the CPUs aren't limited
 	NumberOfPipelines = int32(4)
 )
 
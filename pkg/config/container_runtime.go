@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// The container runtimes the docker sources collect the logs of
+const (
+	// ContainerRuntimeAuto picks the first runtime whose socket is found
+	ContainerRuntimeAuto       = "auto"
+	ContainerRuntimeDocker     = "docker"
+	ContainerRuntimePodman     = "podman"
+	ContainerRuntimeContainerd = "containerd"
+
+	containerRuntimeKey = "log_container_runtime"
+)
+
+const (
+	dockerSocket     = "/var/run/docker.sock"
+	podmanSocket     = "/run/podman/podman.sock"
+	containerdSocket = "/run/containerd/containerd.sock"
+
+	// CRILogsPattern matches the container log files the kubelet has the CRI
+	// runtimes write, in the <namespace>_<pod>_<uid> directory of their pod
+	CRILogsPattern = "/var/log/pods/*/*/*.log"
+	criLogsTags    = "/var/log/pods/{kube_namespace}_{pod_name}_*/{kube_container_name}/*.log"
+)
+
+// ContainerRuntime returns the configured container runtime
+func ContainerRuntime() string {
+	return LogsAgent.GetString(containerRuntimeKey)
+}
+
+// IsContainerRuntime returns true if runtime is a valid log_container_runtime
+func IsContainerRuntime(runtime string) bool {
+	switch runtime {
+	case ContainerRuntimeAuto, ContainerRuntimeDocker, ContainerRuntimePodman, ContainerRuntimeContainerd:
+		return true
+	}
+	return false
+}
+
+// validateContainerRuntime checks the value of log_container_runtime
+func validateContainerRuntime(config *viper.Viper) error {
+	runtime := config.GetString(containerRuntimeKey)
+	if !IsContainerRuntime(runtime) {
+		return fmt.Errorf("%s must be %s, %s, %s or %s (got %s)", containerRuntimeKey, ContainerRuntimeAuto, ContainerRuntimeDocker, ContainerRuntimePodman, ContainerRuntimeContainerd, runtime)
+	}
+	return nil
+}
+
+// ResolveContainerRuntime returns the container runtime the docker sources collect
+// the logs of, along with the address of its API, "" for the environment of the
+// docker client. With ContainerRuntimeAuto, DOCKER_HOST designates docker, else
+// the sockets of docker, podman and containerd are looked for in that order
+func ResolveContainerRuntime() (string, string) {
+	return resolveContainerRuntime(ContainerRuntime(), os.Getenv, socketExists)
+}
+
+func resolveContainerRuntime(runtime string, getenv func(string) string, exists func(string) bool) (string, string) {
+	switch runtime {
+	case ContainerRuntimeDocker, ContainerRuntimeContainerd:
+		return runtime, ""
+	case ContainerRuntimePodman:
+		for _, socket := range podmanSockets(getenv) {
+			if exists(socket) {
+				return runtime, "unix://" + socket
+			}
+		}
+		return runtime, "unix://" + podmanSocket
+	}
+	if getenv("DOCKER_HOST") != "" || exists(dockerSocket) {
+		return ContainerRuntimeDocker, ""
+	}
+	for _, socket := range podmanSockets(getenv) {
+		if exists(socket) {
+			return ContainerRuntimePodman, "unix://" + socket
+		}
+	}
+	if exists(containerdSocket) {
+		return ContainerRuntimeContainerd, ""
+	}
+	return ContainerRuntimeDocker, ""
+}
+
+// podmanSockets returns the paths of the sockets of the rootful then rootless podman
+func podmanSockets(getenv func(string) string) []string {
+	sockets := []string{podmanSocket}
+	if dir := getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sockets = append(sockets, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	return sockets
+}
+
+func socketExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// CRISources returns the file sources tailing the CRI log files of the containers
+// collected by the docker sources, containerd having no API to stream the logs of
+// containers. Their namespace, pod and container names are tagged from their path.
+// The sources filtering containers by image or label are skipped, as the files
+// don't tell them
+func CRISources(sources []*IntegrationConfigLogSource) []*IntegrationConfigLogSource {
+	criSources := []*IntegrationConfigLogSource{}
+	for _, source := range sources {
+		if source.Type != DOCKER_TYPE {
+			continue
+		}
+		if source.Image != "" || source.Label != "" {
+			log.Printf("Skipping source %s: image and label filters require the %s or %s runtime", source.GetID(), ContainerRuntimeDocker, ContainerRuntimePodman)
+			continue
+		}
+		criSource := *source
+		criSource.Type = FILE_TYPE
+		criSource.Path = CRILogsPattern
+		criSource.Format = FormatCRI
+		criSource.PathTags = criLogsTags
+		criSource.PathTagsReg, _ = CompilePathTags(criLogsTags)
+		criSource.ID = BuildSourceID(&criSource)
+		criSources = append(criSources, &criSource)
+	}
+	return criSources
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateContainerRuntime(t *testing.T) {
+	config := viper.New()
+	setDefaults(config)
+	assert.Nil(t, validateContainerRuntime(config))
+	config.Set(containerRuntimeKey, ContainerRuntimePodman)
+	assert.Nil(t, validateContainerRuntime(config))
+	config.Set(containerRuntimeKey, "cri-o")
+	assert.NotNil(t, validateContainerRuntime(config))
+}
+
+func TestResolveContainerRuntime(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+	sockets := map[string]bool{}
+	exists := func(path string) bool { return sockets[path] }
+
+	runtime, host := resolveContainerRuntime(ContainerRuntimeAuto, getenv, exists)
+	assert.Equal(t, ContainerRuntimeDocker, runtime)
+	assert.Equal(t, "", host)
+
+	sockets[containerdSocket] = true
+	runtime, _ = resolveContainerRuntime(ContainerRuntimeAuto, getenv, exists)
+	assert.Equal(t, ContainerRuntimeContainerd, runtime)
+
+	env["XDG_RUNTIME_DIR"] = "/run/user/1000"
+	sockets["/run/user/1000/podman/podman.sock"] = true
+	runtime, host = resolveContainerRuntime(ContainerRuntimeAuto, getenv, exists)
+	assert.Equal(t, ContainerRuntimePodman, runtime)
+	assert.Equal(t, "unix:///run/user/1000/podman/podman.sock", host)
+
+	sockets[podmanSocket] = true
+	_, host = resolveContainerRuntime(ContainerRuntimePodman, getenv, exists)
+	assert.Equal(t, "unix://"+podmanSocket, host)
+
+	env["DOCKER_HOST"] = "tcp://127.0.0.1:2375"
+	runtime, host = resolveContainerRuntime(ContainerRuntimeAuto, getenv, exists)
+	assert.Equal(t, ContainerRuntimeDocker, runtime)
+	assert.Equal(t, "", host)
+
+	runtime, _ = resolveContainerRuntime(ContainerRuntimeContainerd, getenv, exists)
+	assert.Equal(t, ContainerRuntimeContainerd, runtime)
+}
+
+func TestCRISources(t *testing.T) {
+	sources := []*IntegrationConfigLogSource{
+		{Type: DOCKER_TYPE, Service: "k8s", Tags: "env:prod"},
+		{Type: DOCKER_TYPE, Image: "nginx"},
+		{Type: FILE_TYPE, Path: "/var/log/app.log"},
+	}
+	criSources := CRISources(sources)
+	assert.Equal(t, 1, len(criSources))
+	source := criSources[0]
+	assert.Equal(t, FILE_TYPE, source.Type)
+	assert.Equal(t, FormatCRI, source.Format)
+	assert.Equal(t, "k8s", source.Service)
+	assert.True(t, source.IsPattern())
+	assert.Equal(t, DOCKER_TYPE, sources[0].Type)
+
+	path := "/var/log/pods/default_web-6d4cf56db6-x2x9z_0c3a2f16-9f4e-4b4a-8e7e-4a1d6c0b8f5e/nginx/0.log"
+	assert.True(t, MatchPathPattern(source.Path, path))
+	assert.Equal(t, "env:prod,kube_namespace:default,pod_name:web-6d4cf56db6-x2x9z,kube_container_name:nginx", PathTags(source, path))
+}
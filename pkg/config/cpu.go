@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup filesystem is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// AvailableCPUs returns the number of CPUs the agent can use, which is lower than
+// the number of CPUs of the host when running in a container with a CPU limit
+func AvailableCPUs() int {
+	return availableCPUs(cgroupRoot, runtime.NumCPU())
+}
+
+func availableCPUs(root string, numCPU int) int {
+	quota, ok := cgroupCPUQuota(root)
+	if !ok {
+		return numCPU
+	}
+	cpus := int(math.Ceil(quota))
+	if cpus < 1 {
+		return 1
+	}
+	if cpus > numCPU {
+		return numCPU
+	}
+	return cpus
+}
+
+// cgroupCPUQuota returns the number of CPUs allowed by the cgroup of the agent,
+// and false when it is not limited
+func cgroupCPUQuota(root string) (float64, bool) {
+	// cgroup v2: "<quota> <period>", the quota being "max" when unlimited
+	if content, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(content))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		return cpuQuota(fields[0], fields[1])
+	}
+	// cgroup v1: the quota is -1 when unlimited
+	for _, dir := range []string{"cpu", "cpu,cpuacct"} {
+		quota, err := ioutil.ReadFile(filepath.Join(root, dir, "cpu.cfs_quota_us"))
+		if err != nil {
+			continue
+		}
+		period, err := ioutil.ReadFile(filepath.Join(root, dir, "cpu.cfs_period_us"))
+		if err != nil {
+			continue
+		}
+		return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+	}
+	return 0, false
+}
+
+// cpuQuota returns the number of CPUs allowed by a quota over a period
+func cpuQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// PipelinesCount returns the number of pipelines to run: log_pipelines when set,
+// else one per available CPU up to NumberOfPipelines
+func PipelinesCount() int32 {
+	if n := LogsAgent.GetInt("log_pipelines"); n > 0 {
+		return int32(n)
+	}
+	cpus := int32(AvailableCPUs())
+	if cpus < NumberOfPipelines {
+		return cpus
+	}
+	return NumberOfPipelines
+}
+
+// SetupGOMAXPROCS limits the number of threads running go code to log_gomaxprocs
+// when set, else to the available CPUs, unless the GOMAXPROCS environment variable is set
+func SetupGOMAXPROCS() {
+	if os.Getenv("GOMAXPROCS") != "" {
+		return
+	}
+	n := LogsAgent.GetInt("log_gomaxprocs")
+	if n <= 0 {
+		n = AvailableCPUs()
+	}
+	if n != runtime.GOMAXPROCS(0) {
+		log.Println("Setting GOMAXPROCS to", n)
+		runtime.GOMAXPROCS(n)
+	}
+}
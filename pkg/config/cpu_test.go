@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCgroupFile(t *testing.T, root, name, content string) {
+	path := filepath.Join(root, name)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestAvailableCPUsWithCgroupV2(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	// no cgroup
+	assert.Equal(t, 8, availableCPUs(root, 8))
+
+	writeCgroupFile(t, root, "cpu.max", "max 100000\n")
+	assert.Equal(t, 8, availableCPUs(root, 8))
+
+	writeCgroupFile(t, root, "cpu.max", "150000 100000\n")
+	assert.Equal(t, 2, availableCPUs(root, 8))
+
+	writeCgroupFile(t, root, "cpu.max", "10000 100000\n")
+	assert.Equal(t, 1, availableCPUs(root, 8))
+
+	// the quota can't give more CPUs than the host has
+	writeCgroupFile(t, root, "cpu.max", "1600000 100000\n")
+	assert.Equal(t, 8, availableCPUs(root, 8))
+}
+
+func TestAvailableCPUsWithCgroupV1(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	writeCgroupFile(t, root, "cpu,cpuacct/cpu.cfs_period_us", "100000\n")
+	writeCgroupFile(t, root, "cpu,cpuacct/cpu.cfs_quota_us", "-1\n")
+	assert.Equal(t, 8, availableCPUs(root, 8))
+
+	writeCgroupFile(t, root, "cpu,cpuacct/cpu.cfs_quota_us", "300000\n")
+	assert.Equal(t, 3, availableCPUs(root, 8))
+}
+
+func TestPipelinesCount(t *testing.T) {
+	defer LogsAgent.Set("log_pipelines", 0)
+	LogsAgent.Set("log_pipelines", 6)
+	assert.Equal(t, int32(6), PipelinesCount())
+
+	LogsAgent.Set("log_pipelines", 0)
+	count := PipelinesCount()
+	assert.True(t, count >= 1 && count <= NumberOfPipelines)
+}
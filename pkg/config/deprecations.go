@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+	"github.com/spf13/viper"
+)
+
+// A deprecation is a setting of datadog.yaml replaced by another one, still
+// applied in its stead until it's removed
+type deprecation struct {
+	key         string
+	replacement string
+}
+
+// deprecations are the deprecated settings, in the order they are reported
+var deprecations = []deprecation{
+	{key: "log_dd_url", replacement: DDURLKey},
+	{key: "log_dd_port", replacement: DDPortKey},
+	{key: "skip_ssl_validation", replacement: DevModeNoSSLKey},
+}
+
+// applyDeprecations sets the replacements of the deprecated settings set in the
+// config file or the environment, unless they are set too, and returns a
+// warning for each deprecated setting found
+func applyDeprecations(config *viper.Viper) []string {
+	warnings := []string{}
+	for _, d := range deprecations {
+		if !isSetByUser(config, d.key) {
+			continue
+		}
+		if config.IsSet(d.replacement) {
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated and ignored, %s being set", d.key, d.replacement))
+			continue
+		}
+		config.Set(d.replacement, config.Get(d.key))
+		warnings = append(warnings, fmt.Sprintf("%s is deprecated, use %s instead", d.key, d.replacement))
+	}
+	return warnings
+}
+
+// isSetByUser returns true if the top level key is set in the config file or
+// the environment, the defaults of the datadog agent set for it excepted
+func isSetByUser(config *viper.Viper, key string) bool {
+	if config.InConfig(key) {
+		return true
+	}
+	_, exists := os.LookupEnv(envPrefix + "_" + strings.ToUpper(key))
+	return exists
+}
+
+// reportDeprecations logs the warnings of the deprecated settings and sets
+// them in the status, for users to migrate before the settings are removed
+func reportDeprecations(warnings []string) {
+	for _, warning := range warnings {
+		log.Println(warning)
+	}
+	status.Set("deprecations", warnings)
+}
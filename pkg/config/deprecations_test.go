@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDeprecationsTestConfig(t *testing.T, content string) *viper.Viper {
+	config := viper.New()
+	config.SetConfigType("yaml")
+	assert.Nil(t, config.ReadConfig(strings.NewReader(content)))
+	bindEnvironment(config)
+	setDefaults(config)
+	return config
+}
+
+func TestApplyDeprecations(t *testing.T) {
+	config := newDeprecationsTestConfig(t, "api_key: helloworld\n")
+	config.SetDefault("log_dd_url", "intake.logs.datadoghq.com")
+	// the defaults of the deprecated settings aren't reported
+	assert.Equal(t, 0, len(applyDeprecations(config)))
+	assert.False(t, config.IsSet(DDURLKey))
+
+	config = newDeprecationsTestConfig(t, "log_dd_url: localhost\nskip_ssl_validation: true\n")
+	warnings := applyDeprecations(config)
+	assert.Equal(t, []string{
+		"log_dd_url is deprecated, use logs_config.dd_url instead",
+		"skip_ssl_validation is deprecated, use logs_config.dev_mode_no_ssl instead",
+	}, warnings)
+	assert.Equal(t, "localhost", config.GetString(DDURLKey))
+	assert.True(t, config.GetBool(DevModeNoSSLKey))
+	assert.False(t, mainEndpoint(config).UseSSL)
+
+	// the replacements take precedence
+	config = newDeprecationsTestConfig(t, "log_dd_port: 10514\nlogs_config:\n  dd_port: 443\n")
+	warnings = applyDeprecations(config)
+	assert.Equal(t, []string{"log_dd_port is deprecated and ignored, logs_config.dd_port being set"}, warnings)
+	assert.Equal(t, 443, config.GetInt(DDPortKey))
+}
+
+func TestApplyDeprecationsFromEnvironment(t *testing.T) {
+	os.Setenv("DD_LOG_DD_PORT", "10514")
+	defer os.Unsetenv("DD_LOG_DD_PORT")
+	config := newDeprecationsTestConfig(t, "api_key: helloworld\n")
+	assert.Equal(t, []string{"log_dd_port is deprecated, use logs_config.dd_port instead"}, applyDeprecations(config))
+	assert.Equal(t, 10514, config.GetInt(DDPortKey))
+}
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import "strings"
+
+// FindSources returns the logs sources whose identifier, service or path is name
+func FindSources(name string) []*IntegrationConfigLogSource {
+	return findSources(GetLogsSources(), name)
+}
+
+func findSources(sources []*IntegrationConfigLogSource, name string) []*IntegrationConfigLogSource {
+	found := []*IntegrationConfigLogSource{}
+	for _, source := range sources {
+		if source.GetID() == name || source.Service == name || source.Path == name {
+			found = append(found, source)
+		}
+	}
+	return found
+}
+
+// DescribeSource returns the fully resolved configuration of source: its settings,
+// its effective tags, its processing rules in the order they apply with the
+// compiled patterns and where they come from, its multiline rules and its endpoint
+func DescribeSource(source *IntegrationConfigLogSource) map[string]interface{} {
+	described := describeSources([]*IntegrationConfigLogSource{source})[0]
+	delete(described, "log_processing_rules")
+
+	described["effective_tags"] = normalizeTagString(source.Tags)
+	described["tags_payload"] = string(source.TagsPayload)
+
+	rules := []map[string]interface{}{}
+	multiline := []map[string]interface{}{}
+	for _, rule := range source.ProcessingRules {
+		if rule.Type == MULTILINE || rule.Type == MULTILINE_CONTINUATION {
+			multiline = append(multiline, describeRule(rule))
+			continue
+		}
+		rules = append(rules, describeRule(rule))
+	}
+	if len(rules) > 0 {
+		described["log_processing_rules"] = rules
+	}
+	if len(multiline) > 0 {
+		described["multiline"] = multiline
+	}
+
+	described["endpoint"] = describeEndpoint(source)
+	if source.Logset == "" && source.APIKey == "" {
+		addSetting(described, "logset", LogsAgent.GetString("logset"))
+	}
+	return described
+}
+
+// describeRule returns the settings of rule, with its compiled pattern
+func describeRule(rule LogsProcessingRule) map[string]interface{} {
+	described := map[string]interface{}{"name": rule.Name, "type": rule.Type, "origin": "source"}
+	if rule.global {
+		described["origin"] = "global"
+	}
+	addSetting(described, "pattern", rule.Pattern)
+	if rule.Reg != nil {
+		described["compiled_pattern"] = rule.Reg.String()
+	}
+	addSetting(described, "field", rule.Field)
+	addSetting(described, "replace_placeholder", rule.ReplacePlaceholder)
+	addSetting(described, "severity", rule.Severity)
+	addSetting(described, "from", rule.From)
+	selectors := []string{}
+	if rule.Service != "" {
+		selectors = append(selectors, "service:"+rule.Service)
+	}
+	if rule.Tags != "" {
+		selectors = append(selectors, "tags:"+rule.Tags)
+	}
+	if len(selectors) > 0 {
+		described["selector"] = strings.Join(selectors, " ")
+	}
+	if rule.Enabled != nil && !*rule.Enabled {
+		described["enabled"] = false
+	}
+	return described
+}
+
+// describeEndpoint returns where the logs of source are sent, the endpoint of
+// their logset if it has one
+func describeEndpoint(source *IntegrationConfigLogSource) string {
+	if IsForwardingToAggregator() {
+		return "aggregator " + AggregatorAddress()
+	}
+	endpoint := MainEndpoint()
+	logset := source.Logset
+	if logset == "" && source.APIKey == "" {
+		logset = LogsAgent.GetString("logset")
+	}
+	if logsetEndpoint, ok := LogsetEndpoints()[strings.ToLower(logset)]; ok {
+		endpoint = logsetEndpoint
+	}
+	if LogsAgent.GetBool("log_use_http") {
+		return endpoint.HTTPURL
+	}
+	return endpoint.Address()
+}
@@ -0,0 +1,46 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeSource(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.Set(globalProcessingRulesKey, []map[string]interface{}{
+		{"type": EXCLUDE_AT_MATCH, "name": "exclude_healthchecks", "pattern": "(?i)GET /health"},
+	})
+	err := buildLogsAgentIntegrationsConfig(testConfig, filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+	sources := getLogsSources(testConfig)
+
+	assert.Equal(t, 1, len(findSources(sources, "nginx")))
+	assert.Equal(t, 1, len(findSources(sources, sources[1].GetID())))
+	assert.Equal(t, 0, len(findSources(sources, "unknown")))
+
+	described := DescribeSource(sources[1])
+	assert.Equal(t, "devteam", described["logset"])
+	rules := described["log_processing_rules"].([]map[string]interface{})
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, "exclude_healthchecks", rules[0]["name"])
+	assert.Equal(t, "global", rules[0]["origin"])
+	assert.Equal(t, "(?i)GET /health", rules[0]["compiled_pattern"])
+	assert.Equal(t, "mocked_mask_rule", rules[1]["name"])
+	assert.Equal(t, "source", rules[1]["origin"])
+	multiline := described["multiline"].([]map[string]interface{})
+	assert.Equal(t, 1, len(multiline))
+	assert.Equal(t, "^^[0-9]", multiline[0]["compiled_pattern"])
+	assert.NotEmpty(t, described["endpoint"])
+
+	described = DescribeSource(sources[0])
+	assert.Equal(t, "env:prod", described["effective_tags"])
+	assert.Contains(t, described["tags_payload"], "env:prod")
+}
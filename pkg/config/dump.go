@@ -0,0 +1,201 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+const scrubbedValue = "********"
+
+// secretKeyMarkers identify the settings holding secrets
+var secretKeyMarkers = []string{"api_key", "apikey", "password", "passphrase", "secret", "token"}
+
+// EffectiveConfig returns the fully resolved configuration of the logs agent:
+// defaults, files and environment, with secrets scrubbed
+func EffectiveConfig() map[string]interface{} {
+	return effectiveConfig(LogsAgent)
+}
+
+func effectiveConfig(config *viper.Viper) map[string]interface{} {
+	settings := config.AllSettings()
+	delete(settings, strings.ToLower(LOGS_RULES))
+	scrubbed := scrubSettings(settings).(map[string]interface{})
+	if config.IsSet(LOGS_RULES) {
+		scrubbed["logs"] = describeSources(getLogsSources(config))
+	}
+	return scrubbed
+}
+
+// DumpEffectiveConfig returns the effective configuration as yaml
+func DumpEffectiveConfig() string {
+	b, err := yaml.Marshal(EffectiveConfig())
+	if err != nil {
+		return fmt.Sprintf("can't dump configuration: %v", err)
+	}
+	return string(b)
+}
+
+// scrubSettings returns a copy of value where the secrets are replaced
+func scrubSettings(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		scrubbed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			scrubbed[key] = scrubSetting(key, item)
+		}
+		return scrubbed
+	case map[interface{}]interface{}:
+		scrubbed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			k := fmt.Sprintf("%v", key)
+			scrubbed[k] = scrubSetting(k, item)
+		}
+		return scrubbed
+	case []interface{}:
+		scrubbed := make([]interface{}, len(v))
+		for i, item := range v {
+			scrubbed[i] = scrubSettings(item)
+		}
+		return scrubbed
+	default:
+		return value
+	}
+}
+
+func scrubSetting(key string, value interface{}) interface{} {
+	if isSecretKey(key) && value != nil && value != "" {
+		return scrubbedValue
+	}
+	return scrubSettings(value)
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeSources returns the settings of the logs sources being collected, with secrets scrubbed,
+// the paused and disabled sources being marked as such
+func DescribeSources() []map[string]interface{} {
+	sources := GetLogsSources()
+	described := describeSources(sources)
+	for i, source := range sources {
+		if IsSourcePaused(source.GetID()) {
+			described[i]["paused"] = true
+		}
+		if IsSourceDisabled(source.GetID()) {
+			described[i]["disabled"] = true
+		}
+	}
+	return described
+}
+
+// describeSources returns the settings of the logs sources
+func describeSources(sources []*IntegrationConfigLogSource) []map[string]interface{} {
+	described := []map[string]interface{}{}
+	for _, source := range sources {
+		settings := map[string]interface{}{"type": source.Type, "id": source.GetID()}
+		addSetting(settings, "port", source.Port)
+		addSetting(settings, "port_range", source.PortRange)
+		addSetting(settings, "tls_cert", source.TLSCert)
+		addSetting(settings, "tls_key", source.TLSKey)
+		addSetting(settings, "framing", source.Framing)
+		addSetting(settings, "encoding", source.Encoding)
+		addSetting(settings, "path", source.Path)
+		addSetting(settings, "path_tags", source.PathTags)
+		addSetting(settings, "start_position", source.StartPosition)
+		addSetting(settings, "format", source.Format)
+		if len(source.ExcludePaths) > 0 {
+			settings["exclude_paths"] = source.ExcludePaths
+		}
+		addSetting(settings, "image", source.Image)
+		addSetting(settings, "label", source.Label)
+		addSetting(settings, "command", source.Command)
+		if len(source.Args) > 0 {
+			settings["args"] = source.Args
+		}
+		addSetting(settings, "broker", source.Broker)
+		if len(source.Topics) > 0 {
+			settings["topics"] = source.Topics
+		}
+		addSetting(settings, "topic_tags", source.TopicTags)
+		addSetting(settings, "client_id", source.ClientID)
+		addSetting(settings, "username", source.Username)
+		if source.UseTLS {
+			settings["use_tls"] = true
+		}
+		addSetting(settings, "tls_ca", source.TLSCA)
+		addSetting(settings, "queue", source.Queue)
+		addSetting(settings, "vhost", source.VHost)
+		addSetting(settings, "prefetch_count", source.PrefetchCount)
+		if len(source.Brokers) > 0 {
+			settings["brokers"] = source.Brokers
+		}
+		addSetting(settings, "group_id", source.GroupID)
+		addSetting(settings, "offset_reset", source.OffsetReset)
+		if source.Community != "" {
+			settings["community"] = scrubbedValue
+		}
+		addSetting(settings, "mib_file", source.MIBFile)
+		addSetting(settings, "service", source.Service)
+		addSetting(settings, "service_pattern", source.ServicePattern)
+		addSetting(settings, "service_attribute", source.ServiceAttribute)
+		addSetting(settings, "service_label", source.ServiceLabel)
+		addSetting(settings, "logset", source.Logset)
+		if source.APIKey != "" {
+			settings["api_key"] = scrubbedValue
+		}
+		addSetting(settings, "source", source.Source)
+		addSetting(settings, "sourcecategory", source.SourceCategory)
+		addSetting(settings, "tags", source.Tags)
+		if len(source.Attributes) > 0 {
+			settings["attributes"] = source.Attributes
+		}
+		addSetting(settings, "priority", source.Priority)
+		addSetting(settings, "namespace", source.Namespace)
+		addSetting(settings, "timestamp_source", source.TimestampSource)
+		if source.SequenceNumbers {
+			settings["sequence_numbers"] = true
+		}
+		if source.RawForward {
+			settings["raw_forward"] = true
+		}
+		if source.SamplingRate != nil {
+			settings["sampling_rate"] = *source.SamplingRate
+		}
+		if source.ReorderWindow > 0 {
+			settings["reorder_window"] = source.ReorderWindow.String()
+		}
+		if source.UseGlobalRules != nil {
+			settings["use_global_rules"] = source.UseGlobalRules
+		}
+		rules := []string{}
+		for _, rule := range source.ProcessingRules {
+			rules = append(rules, fmt.Sprintf("%s (%s)", rule.Name, rule.Type))
+		}
+		if len(rules) > 0 {
+			settings["log_processing_rules"] = rules
+		}
+		described = append(described, settings)
+	}
+	return described
+}
+
+func addSetting(settings map[string]interface{}, key string, value interface{}) {
+	if value != "" && value != 0 {
+		settings[key] = value
+	}
+}
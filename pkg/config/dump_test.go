@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveConfigScrubsSecrets(t *testing.T) {
+	var testConfig = viper.New()
+	testConfig.Set("api_key", "helloworld")
+	testConfig.Set("log_dd_url", "my.url")
+	testConfig.Set("proxy", map[string]interface{}{"password": "secret", "user": "me"})
+
+	settings := effectiveConfig(testConfig)
+	assert.Equal(t, scrubbedValue, settings["api_key"])
+	assert.Equal(t, "my.url", settings["log_dd_url"])
+	assert.Equal(t, map[string]interface{}{"password": scrubbedValue, "user": "me"}, settings["proxy"])
+	assert.Nil(t, settings["logs"])
+}
+
+func TestEffectiveConfigDescribesSources(t *testing.T) {
+	var testConfig = viper.New()
+	buildLogsAgentIntegrationsConfig(testConfig, filepath.Join(testsPath, "complete", "conf.d"))
+
+	settings := effectiveConfig(testConfig)
+	sources := settings["logs"].([]map[string]interface{})
+	assert.Equal(t, 3, len(sources))
+	assert.Equal(t, map[string]interface{}{
+		"type":           "file",
+		"id":             BuildSourceID(&IntegrationConfigLogSource{Type: "file", Path: "/var/log/access.log"}),
+		"path":           "/var/log/access.log",
+		"service":        "nginx",
+		"source":         "nginx",
+		"sourcecategory": "http_access",
+		"tags":           "env:prod",
+	}, sources[0])
+	assert.Equal(t, []string{"mocked_mask_rule (mask_sequences)", "numbers (multi_line)"}, sources[1]["log_processing_rules"])
+	assert.Nil(t, settings["logsrules"])
+}
+
+func TestDescribeSourcesScrubsAPIKeys(t *testing.T) {
+	sources := describeSources([]*IntegrationConfigLogSource{{Type: TCP_TYPE, Port: 10514, Logset: "team", APIKey: "teamkey"}})
+	assert.Equal(t, scrubbedValue, sources[0]["api_key"])
+	assert.Equal(t, "team", sources[0]["logset"])
+}
@@ -0,0 +1,26 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+// Character encodings of the logs of file and network sources, converted to UTF-8
+// before being processed
+const (
+	ENCODING_UTF8      = "utf-8"
+	ENCODING_UTF16_LE  = "utf-16-le"
+	ENCODING_UTF16_BE  = "utf-16-be"
+	ENCODING_LATIN1    = "latin-1"
+	ENCODING_SHIFT_JIS = "shift-jis"
+)
+
+// IsEncoding returns true if encoding is one of the supported encodings
+func IsEncoding(encoding string) bool {
+	switch encoding {
+	case ENCODING_UTF8, ENCODING_UTF16_LE, ENCODING_UTF16_BE, ENCODING_LATIN1, ENCODING_SHIFT_JIS:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// The settings of the intake, as in the config of the datadog agent, replacing
+// the deprecated log_dd_url, log_dd_port and skip_ssl_validation
+const (
+	DDURLKey           = "logs_config.dd_url"
+	DDPortKey          = "logs_config.dd_port"
+	DevModeNoSSLKey    = "logs_config.dev_mode_no_ssl"
+	LogsetEndpointsKey = "logs_config.logset_endpoints"
+)
+
+// An Endpoint is an intake the logs are sent to
+type Endpoint struct {
+	Host   string
+	Port   int
+	UseSSL bool
+	// HTTPURL is the url the logs are posted to with log_use_http
+	HTTPURL string
+}
+
+// Address returns the host:port of the endpoint
+func (e Endpoint) Address() string {
+	return fmt.Sprintf("%s:%d", e.Host, e.Port)
+}
+
+// MainEndpoint returns the intake of the logs whose logset has no endpoint of its own
+func MainEndpoint() Endpoint {
+	return mainEndpoint(LogsAgent)
+}
+
+func mainEndpoint(config *viper.Viper) Endpoint {
+	endpoint := Endpoint{
+		Host:    config.GetString("log_dd_url"),
+		Port:    config.GetInt("log_dd_port"),
+		UseSSL:  !config.GetBool("skip_ssl_validation"),
+		HTTPURL: config.GetString("log_dd_http_url"),
+	}
+	if config.IsSet(DDURLKey) {
+		endpoint.Host = config.GetString(DDURLKey)
+	}
+	if config.IsSet(DDPortKey) {
+		endpoint.Port = config.GetInt(DDPortKey)
+	}
+	if config.GetBool(DevModeNoSSLKey) {
+		endpoint.UseSSL = false
+	}
+	return endpoint
+}
+
+// LogsetEndpoints returns the intakes of the logsets shipped elsewhere than the
+// main endpoint, by lower case logset
+func LogsetEndpoints() map[string]Endpoint {
+	endpoints, _ := logsetEndpoints(LogsAgent)
+	return endpoints
+}
+
+// logsetEndpoints returns the endpoints of logs_config.logset_endpoints, a map of
+// the dd_url, dd_port, dev_mode_no_ssl and http_url of logsets, each of them
+// overriding the setting of the main endpoint
+func logsetEndpoints(config *viper.Viper) (map[string]Endpoint, error) {
+	endpoints := make(map[string]Endpoint)
+	if !config.IsSet(LogsetEndpointsKey) {
+		return endpoints, nil
+	}
+	main := mainEndpoint(config)
+	logsets, err := cast.ToStringMapE(config.Get(LogsetEndpointsKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected a map of logsets", LogsetEndpointsKey)
+	}
+	for logset, value := range logsets {
+		settings, err := cast.ToStringMapE(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s.%s: expected a map of settings", LogsetEndpointsKey, logset)
+		}
+		endpoint := main
+		for key, setting := range settings {
+			switch strings.ToLower(key) {
+			case "dd_url":
+				endpoint.Host, err = cast.ToStringE(setting)
+			case "dd_port":
+				endpoint.Port, err = cast.ToIntE(setting)
+			case "dev_mode_no_ssl":
+				var noSSL bool
+				noSSL, err = cast.ToBoolE(setting)
+				endpoint.UseSSL = !noSSL
+			case "http_url":
+				endpoint.HTTPURL, err = cast.ToStringE(setting)
+			default:
+				err = fmt.Errorf("unknown setting %s", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s.%s: %v", LogsetEndpointsKey, logset, err)
+			}
+		}
+		if err := validateEndpoint(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid %s.%s: %v", LogsetEndpointsKey, logset, err)
+		}
+		endpoints[strings.ToLower(logset)] = endpoint
+	}
+	return endpoints, nil
+}
+
+// validateEndpoints checks the port of the main endpoint and the endpoints of the logsets
+func validateEndpoints(config *viper.Viper) error {
+	if config.IsSet(DDPortKey) {
+		if port := config.GetInt(DDPortKey); port < 1 || port > 65535 {
+			return fmt.Errorf("%s must be between 1 and 65535 (got %d)", DDPortKey, port)
+		}
+	}
+	_, err := logsetEndpoints(config)
+	return err
+}
+
+// validateEndpoint checks the host and port of endpoint
+func validateEndpoint(endpoint Endpoint) error {
+	if endpoint.Host == "" {
+		return fmt.Errorf("dd_url must be set")
+	}
+	if endpoint.Port < 1 || endpoint.Port > 65535 {
+		return fmt.Errorf("dd_port must be between 1 and 65535 (got %d)", endpoint.Port)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEndpointTestConfig() *viper.Viper {
+	config := viper.New()
+	setDefaults(config)
+	config.Set("log_dd_url", "intake.logs.datadoghq.com")
+	config.Set("log_dd_port", 10516)
+	return config
+}
+
+func TestMainEndpoint(t *testing.T) {
+	config := newEndpointTestConfig()
+	endpoint := mainEndpoint(config)
+	assert.Equal(t, "intake.logs.datadoghq.com:10516", endpoint.Address())
+	assert.True(t, endpoint.UseSSL)
+
+	config.Set(DDURLKey, "localhost")
+	config.Set(DDPortKey, 10514)
+	config.Set(DevModeNoSSLKey, true)
+	endpoint = mainEndpoint(config)
+	assert.Equal(t, "localhost:10514", endpoint.Address())
+	assert.False(t, endpoint.UseSSL)
+	assert.Equal(t, config.GetString("log_dd_http_url"), endpoint.HTTPURL)
+}
+
+func TestLogsetEndpoints(t *testing.T) {
+	config := newEndpointTestConfig()
+	endpoints, err := logsetEndpoints(config)
+	assert.Nil(t, err)
+	assert.Len(t, endpoints, 0)
+
+	config.Set(LogsetEndpointsKey, map[string]interface{}{
+		"EU":  map[string]interface{}{"dd_url": "intake.logs.datadoghq.eu", "dd_port": 443},
+		"dev": map[string]interface{}{"dev_mode_no_ssl": true},
+	})
+	endpoints, err = logsetEndpoints(config)
+	assert.Nil(t, err)
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, "intake.logs.datadoghq.eu:443", endpoints["eu"].Address())
+	assert.True(t, endpoints["eu"].UseSSL)
+	// the settings not overridden are those of the main endpoint
+	assert.Equal(t, "intake.logs.datadoghq.com:10516", endpoints["dev"].Address())
+	assert.False(t, endpoints["dev"].UseSSL)
+}
+
+func TestValidateEndpoints(t *testing.T) {
+	config := newEndpointTestConfig()
+	assert.Nil(t, validateEndpoints(config))
+
+	config.Set(DDPortKey, 70000)
+	assert.NotNil(t, validateEndpoints(config))
+
+	config = newEndpointTestConfig()
+	config.Set(LogsetEndpointsKey, map[string]interface{}{"eu": map[string]interface{}{"dd_host": "intake.logs.datadoghq.eu"}})
+	assert.NotNil(t, validateEndpoints(config))
+	config.Set(LogsetEndpointsKey, map[string]interface{}{"eu": map[string]interface{}{"dd_port": "https"}})
+	assert.NotNil(t, validateEndpoints(config))
+	config.Set(LogsetEndpointsKey, map[string]interface{}{"eu": "intake.logs.datadoghq.eu"})
+	assert.NotNil(t, validateEndpoints(config))
+}
@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// envPrefix prefixes the environment variables overriding the settings
+	envPrefix = "DD"
+	// LogsSourcesEnv holds sources encoded in JSON, added to those of conf.d,
+	// such as [{"type":"file","path":"/var/log/app.log","service":"app"}]
+	LogsSourcesEnv = "DD_LOGS_SOURCES"
+	// ConfigPathEnv and ConfdPathEnv locate the config files when the flags are not set
+	ConfigPathEnv = "DD_CONFIG_PATH"
+	ConfdPathEnv  = "DD_CONFD_PATH"
+)
+
+// bindEnvironment lets DD_ prefixed environment variables override the settings
+// of the config files, such as DD_API_KEY for api_key, or
+// DD_LOGS_CONFIG_DISABLE_FILE_COLLECTION for logs_config.disable_file_collection.
+// The values set at runtime still take precedence over the environment
+func bindEnvironment(config *viper.Viper) {
+	config.SetEnvPrefix(envPrefix)
+	config.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	config.AutomaticEnv()
+}
+
+// FlagOrEnv returns the value of a command line flag, or that of the environment
+// variable env when the flag isn't set
+func FlagOrEnv(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
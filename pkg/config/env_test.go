@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironmentOverridesConfigFile(t *testing.T) {
+	os.Setenv("DD_API_KEY", "fromenv")
+	os.Setenv("DD_LOGS_CONFIG_DISABLE_FILE_COLLECTION", "true")
+	defer os.Unsetenv("DD_API_KEY")
+	defer os.Unsetenv("DD_LOGS_CONFIG_DISABLE_FILE_COLLECTION")
+
+	var testConfig = viper.New()
+	buildMainConfig(testConfig, filepath.Join(testsPath, "complete", "datadog.yaml"), filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Equal(t, "fromenv", testConfig.GetString("api_key"))
+	assert.Equal(t, "playground", testConfig.GetString("logset"))
+	assert.True(t, testConfig.GetBool(DisableFileCollection))
+	// the values set at runtime take precedence
+	testConfig.Set("api_key", "runtime")
+	assert.Equal(t, "runtime", testConfig.GetString("api_key"))
+}
+
+func TestLogsSourcesFromEnvironment(t *testing.T) {
+	os.Setenv(LogsSourcesEnv, `[{"type":"file","path":"/var/log/access.log","service":"fromenv"},{"type":"udp","port":10518}]`)
+	defer os.Unsetenv(LogsSourcesEnv)
+
+	sources, _, err := loadLogsSources(viper.New(), filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(sources))
+	assert.Equal(t, "/var/log/access.log", sources[0].Path)
+	assert.Equal(t, "fromenv", sources[0].Service)
+	assert.Equal(t, UDP_TYPE, sources[3].Type)
+	assert.Equal(t, 10518, sources[3].Port)
+
+	os.Setenv(LogsSourcesEnv, `[{"type":"tcp"}]`)
+	sources, configErrors, err := loadLogsSources(viper.New(), filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(sources))
+	assert.Equal(t, 1, len(configErrors))
+	assert.Contains(t, configErrors[0].Error(), LogsSourcesEnv+": logs[0]: a tcp source must have a port")
+}
+
+func TestFlagOrEnv(t *testing.T) {
+	os.Setenv(ConfdPathEnv, "/etc/datadog/conf.d")
+	defer os.Unsetenv(ConfdPathEnv)
+	assert.Equal(t, "/etc/datadog/conf.d", FlagOrEnv("", ConfdPathEnv))
+	assert.Equal(t, "conf.d", FlagOrEnv("conf.d", ConfdPathEnv))
+}
@@ -0,0 +1,202 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+// noSourceIndex is used when an error is not related to a specific log source
+const noSourceIndex = -1
+
+// A ConfigError describes a misconfiguration found in an integration config file.
+// It carries enough context to point users to the faulty stanza:
+// the file, the index of the log source in the `logs` section,
+// the name of the processing rule and the line in the file when known.
+type ConfigError struct {
+	File        string
+	SourceIndex int
+	RuleName    string
+	Line        int
+	Reason      string
+}
+
+// newSourceError returns a ConfigError related to a log source
+func newSourceError(reason string, args ...interface{}) *ConfigError {
+	return &ConfigError{
+		SourceIndex: noSourceIndex,
+		Reason:      fmt.Sprintf(reason, args...),
+	}
+}
+
+// newRuleError returns a ConfigError related to a log processing rule
+func newRuleError(ruleName, reason string, args ...interface{}) *ConfigError {
+	err := newSourceError(reason, args...)
+	err.RuleName = ruleName
+	return err
+}
+
+// newFileError returns a ConfigError related to a whole integration config file
+func newFileError(file string, err error) *ConfigError {
+	return &ConfigError{
+		File:        file,
+		SourceIndex: noSourceIndex,
+		Reason:      err.Error(),
+	}
+}
+
+// Error returns a human readable description of the misconfiguration,
+// for instance: LogsAgent misconfigured: nginx.yaml:12: logs[1]: rule `mask`: pattern must be set
+func (e *ConfigError) Error() string {
+	var location []string
+	if e.File != "" {
+		if e.Line > 0 {
+			location = append(location, fmt.Sprintf("%s:%d", e.File, e.Line))
+		} else {
+			location = append(location, e.File)
+		}
+	}
+	if e.SourceIndex != noSourceIndex {
+		location = append(location, fmt.Sprintf("logs[%d]", e.SourceIndex))
+	}
+	if e.RuleName != "" {
+		location = append(location, fmt.Sprintf("rule `%s`", e.RuleName))
+	}
+	location = append(location, e.Reason)
+	return "LogsAgent misconfigured: " + strings.Join(location, ": ")
+}
+
+// ReportConfigErrors logs the errors of the invalid files and sources skipped
+// while loading the sources, and reports them in the `config errors` entry of the status
+func ReportConfigErrors(errs []error) {
+	report := []map[string]interface{}{}
+	for _, err := range errs {
+		log.Println(err)
+		report = append(report, describeConfigError(err))
+	}
+	status.Set("config errors", report)
+}
+
+// describeConfigError returns the structured description of err
+func describeConfigError(err error) map[string]interface{} {
+	cfgErr, ok := err.(*ConfigError)
+	if !ok {
+		return map[string]interface{}{"reason": err.Error()}
+	}
+	described := map[string]interface{}{"reason": cfgErr.Reason}
+	if cfgErr.File != "" {
+		described["file"] = cfgErr.File
+	}
+	if cfgErr.Line > 0 {
+		described["line"] = cfgErr.Line
+	}
+	if cfgErr.SourceIndex != noSourceIndex {
+		described["source_index"] = cfgErr.SourceIndex
+	}
+	if cfgErr.RuleName != "" {
+		described["rule"] = cfgErr.RuleName
+	}
+	return described
+}
+
+// locate fills the file and line information of a ConfigError
+// related to the log source at sourceIndex in file
+func (e *ConfigError) locate(file string, content []byte, sourceIndex int) *ConfigError {
+	e.File = file
+	e.SourceIndex = sourceIndex
+	e.Line = sourceLine(content, sourceIndex)
+	if e.Line > 0 && e.RuleName != "" {
+		if line := ruleLine(content, e.Line, e.RuleName); line > 0 {
+			e.Line = line
+		}
+	}
+	return e
+}
+
+var (
+	logsSectionRe = regexp.MustCompile(`^logs\s*:`)
+	listItemRe    = regexp.MustCompile(`^(\s*)-\s`)
+	topLevelKeyRe = regexp.MustCompile(`^\S`)
+)
+
+// readLines returns the lines of content, or nil when content is empty
+func readLines(content []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// sourceLine returns the line number, starting at 1, at which the log source
+// at sourceIndex is declared in the `logs` section of a yaml file, or 0 if it can't be found.
+// This is a best effort lookup which only supports block sequences.
+func sourceLine(content []byte, sourceIndex int) int {
+	lines := readLines(content)
+	inLogsSection := false
+	itemIndent := -1
+	index := 0
+	for i, line := range lines {
+		if logsSectionRe.MatchString(line) {
+			inLogsSection = true
+			continue
+		}
+		if !inLogsSection {
+			continue
+		}
+		if topLevelKeyRe.MatchString(line) && !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "#") {
+			// end of the logs section
+			return 0
+		}
+		match := listItemRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if itemIndent == -1 {
+			itemIndent = len(match[1])
+		}
+		if len(match[1]) != itemIndent {
+			continue
+		}
+		if index == sourceIndex {
+			return i + 1
+		}
+		index++
+	}
+	return 0
+}
+
+// ruleLine returns the line number, starting at 1, at which the rule named
+// ruleName is declared after fromLine, or 0 if it can't be found
+func ruleLine(content []byte, fromLine int, ruleName string) int {
+	lines := readLines(content)
+	nameRe := regexp.MustCompile(`^\s*-?\s*name\s*:\s*["']?` + regexp.QuoteMeta(ruleName) + `["']?\s*$`)
+	for i := fromLine; i < len(lines); i++ {
+		if nameRe.MatchString(lines[i]) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// readConfigFile returns the content of an integration config file,
+// or nil if it can't be read, in which case line numbers won't be available
+func readConfigFile(path string) []byte {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return content
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+const testIntegrationConfig = `init_config:
+
+logs:
+  - type: file
+    path: /var/log/access.log
+
+  - type: tcp
+    port: 10514
+    log_processing_rules:
+      - type: mask_sequences
+        name: mocked_mask_rule
+      - type: multi_line
+        name: "numbers"
+
+instances:
+  - whatever: anything
+`
+
+func TestSourceLine(t *testing.T) {
+	content := []byte(testIntegrationConfig)
+	assert.Equal(t, 4, sourceLine(content, 0))
+	assert.Equal(t, 7, sourceLine(content, 1))
+	assert.Equal(t, 0, sourceLine(content, 2))
+	assert.Equal(t, 0, sourceLine(nil, 0))
+}
+
+func TestRuleLine(t *testing.T) {
+	content := []byte(testIntegrationConfig)
+	assert.Equal(t, 11, ruleLine(content, 7, "mocked_mask_rule"))
+	assert.Equal(t, 13, ruleLine(content, 7, "numbers"))
+	assert.Equal(t, 0, ruleLine(content, 7, "unknown"))
+}
+
+func TestConfigError(t *testing.T) {
+	err := newRuleError("numbers", "type %s is unsupported", "foo")
+	assert.Equal(t, "LogsAgent misconfigured: rule `numbers`: type foo is unsupported", err.Error())
+
+	err.locate("integration.yaml", []byte(testIntegrationConfig), 1)
+	assert.Equal(t, "integration.yaml", err.File)
+	assert.Equal(t, 1, err.SourceIndex)
+	assert.Equal(t, 13, err.Line)
+	assert.Equal(t, "LogsAgent misconfigured: integration.yaml:13: logs[1]: rule `numbers`: type foo is unsupported", err.Error())
+
+	err = newSourceError("a tcp source must have a port")
+	err.locate("integration.yaml", nil, 0)
+	assert.Equal(t, "LogsAgent misconfigured: integration.yaml: logs[0]: a tcp source must have a port", err.Error())
+}
+
+func TestBuildLogsAgentIntegrationsConfigReportsConfigErrors(t *testing.T) {
+	var testConfig = viper.New()
+
+	err := buildLogsAgentIntegrationsConfig(testConfig, filepath.Join(testsPath, "misconfigured_2", "conf.d"))
+	assert.Nil(t, err)
+	report := status.Get()["config errors"].([]map[string]interface{})
+	assert.Equal(t, []map[string]interface{}{{
+		"file":         "integration.yaml",
+		"line":         6,
+		"source_index": 0,
+		"rule":         "wrong_type",
+		"reason":       report[0]["reason"],
+	}}, report)
+
+	_, configErrors, err := loadLogsSources(testConfig, filepath.Join(testsPath, "misconfigured_4", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(configErrors))
+	assert.Equal(t, "LogsAgent misconfigured: integration.yaml:2: logs[0]: a tcp source must have a port or a port_range", configErrors[0].Error())
+}
+
+func TestLoadLogsSourcesSkipsInvalidFilesAndSources(t *testing.T) {
+	sources, configErrors, err := loadLogsSources(viper.New(), filepath.Join(testsPath, "partially_misconfigured", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sources))
+	assert.Equal(t, "/var/log/valid.log", sources[0].Path)
+	assert.Equal(t, 10514, sources[1].Port)
+	assert.Equal(t, 2, len(configErrors))
+	assert.Equal(t, "invalid.yaml", configErrors[0].(*ConfigError).File)
+	assert.Equal(t, "valid.yaml", configErrors[1].(*ConfigError).File)
+	assert.Equal(t, 1, configErrors[1].(*ConfigError).SourceIndex)
+}
@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// hostSpecificKeys are the settings resolved on each host, left out of the
+// fingerprint so that the hosts sharing a configuration share its fingerprint
+var hostSpecificKeys = []string{"hostname", statelessKey}
+
+// fingerprint is the checksum of the configuration loaded last, with its load time
+var fingerprint = struct {
+	sync.Mutex
+	checksum string
+	loaded   time.Time
+}{}
+
+func init() {
+	status.Register("config fingerprint", fingerprintStatus)
+}
+
+// ConfigFingerprint returns the checksum of the effective configuration loaded
+// last, sources included, or "" before it's loaded
+func ConfigFingerprint() string {
+	fingerprint.Lock()
+	defer fingerprint.Unlock()
+	return fingerprint.checksum
+}
+
+// updateFingerprint computes the checksum of the effective configuration of config
+func updateFingerprint(config *viper.Viper) {
+	checksum, err := computeFingerprint(config)
+	if err != nil {
+		log.Println("Can't compute the fingerprint of the configuration:", err)
+		return
+	}
+	log.Println("Configuration fingerprint:", checksum)
+	fingerprint.Lock()
+	defer fingerprint.Unlock()
+	fingerprint.checksum = checksum
+	fingerprint.loaded = time.Now()
+}
+
+// computeFingerprint returns the sha256 of the effective configuration of config
+// as yaml, whose keys are sorted. The secrets being scrubbed, rotating them
+// doesn't change the fingerprint, which can be shared freely
+func computeFingerprint(config *viper.Viper) (string, error) {
+	settings := effectiveConfig(config)
+	for _, key := range hostSpecificKeys {
+		delete(settings, key)
+	}
+	b, err := yaml.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func fingerprintStatus() interface{} {
+	fingerprint.Lock()
+	defer fingerprint.Unlock()
+	if fingerprint.checksum == "" {
+		return "not loaded"
+	}
+	return map[string]string{
+		"sha256":    fingerprint.checksum,
+		"loaded_at": fingerprint.loaded.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,46 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeFingerprint(t *testing.T) {
+	newConfig := func(hostname, apiKey string, port int) *viper.Viper {
+		config := viper.New()
+		config.Set("hostname", hostname)
+		config.Set("api_key", apiKey)
+		config.Set("log_dd_port", 10516)
+		config.Set(LOGS_RULES, []*IntegrationConfigLogSource{{Type: TCP_TYPE, Port: port}})
+		return config
+	}
+
+	reference, err := computeFingerprint(newConfig("host-1", "key-1", 10514))
+	assert.Nil(t, err)
+	assert.Equal(t, 64, len(reference))
+
+	// stable, and shared by the hosts, whatever their secrets
+	fingerprint, _ := computeFingerprint(newConfig("host-1", "key-1", 10514))
+	assert.Equal(t, reference, fingerprint)
+	fingerprint, _ = computeFingerprint(newConfig("host-2", "key-2", 10514))
+	assert.Equal(t, reference, fingerprint)
+
+	fingerprint, _ = computeFingerprint(newConfig("host-1", "key-1", 10515))
+	assert.NotEqual(t, reference, fingerprint)
+}
+
+func TestUpdateFingerprint(t *testing.T) {
+	config := viper.New()
+	config.Set(LOGS_RULES, []*IntegrationConfigLogSource{{Type: TCP_TYPE, Port: 10514}})
+	updateFingerprint(config)
+	expected, _ := computeFingerprint(config)
+	assert.Equal(t, expected, ConfigFingerprint())
+	assert.Equal(t, expected, fingerprintStatus().(map[string]string)["sha256"])
+}
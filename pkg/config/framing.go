@@ -0,0 +1,26 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+// Framings splitting the data received by tcp sources into records: lines, or
+// records preceded by their length as an unsigned big or little endian integer
+const (
+	FRAMING_NEWLINE   = "newline"
+	FRAMING_UINT16_BE = "uint16_be"
+	FRAMING_UINT16_LE = "uint16_le"
+	FRAMING_UINT32_BE = "uint32_be"
+	FRAMING_UINT32_LE = "uint32_le"
+)
+
+// IsLengthPrefixed returns true if framing is one of the length-prefixed framings
+func IsLengthPrefixed(framing string) bool {
+	switch framing {
+	case FRAMING_UINT16_BE, FRAMING_UINT16_LE, FRAMING_UINT32_BE, FRAMING_UINT32_LE:
+		return true
+	default:
+		return false
+	}
+}
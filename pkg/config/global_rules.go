@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// globalProcessingRulesKey is the setting of the main config holding the processing rules
+// applied to all the log sources matching their selector
+const globalProcessingRulesKey = "log_processing_rules"
+
+// logsConfigProcessingRulesKey holds global processing rules too, as in the config of the
+// datadog agent, such as the fleet-wide scrubbing policies. They apply before those of
+// globalProcessingRulesKey
+const logsConfigProcessingRulesKey = "logs_config.processing_rules"
+
+// getGlobalProcessingRules returns the validated global processing rules
+func getGlobalProcessingRules(config *viper.Viper) ([]LogsProcessingRule, error) {
+	rules := []LogsProcessingRule{}
+	for _, key := range []string{logsConfigProcessingRulesKey, globalProcessingRulesKey} {
+		keyRules, err := unmarshalProcessingRules(config, key)
+		if err != nil {
+			return nil, newFileError(filepath.Base(config.ConfigFileUsed()), fmt.Errorf("invalid %s: %v", key, err))
+		}
+		rules = append(rules, keyRules...)
+	}
+	rules, err := validateProcessingRules(rules)
+	if err != nil {
+		if cfgErr, ok := err.(*ConfigError); ok {
+			cfgErr.File = filepath.Base(config.ConfigFileUsed())
+		}
+		return nil, err
+	}
+	return rules, nil
+}
+
+// unmarshalProcessingRules returns the processing rules of key, which are
+// encoded in JSON when set by the environment, such as DD_LOGS_CONFIG_PROCESSING_RULES
+func unmarshalProcessingRules(config *viper.Viper, key string) ([]LogsProcessingRule, error) {
+	var rules []LogsProcessingRule
+	if !config.IsSet(key) {
+		return rules, nil
+	}
+	if value, ok := config.Get(key).(string); ok {
+		decoded := viper.New()
+		decoded.SetConfigType("json")
+		if err := decoded.ReadConfig(strings.NewReader(`{"rules":` + value + `}`)); err != nil {
+			return nil, err
+		}
+		err := decoded.UnmarshalKey("rules", &rules)
+		return rules, err
+	}
+	err := config.UnmarshalKey(key, &rules)
+	return rules, err
+}
+
+// validateRuleSelectors checks that the rules of a log source don't have any selector
+func validateRuleSelectors(rules []LogsProcessingRule) error {
+	for _, rule := range rules {
+		if rule.Service != "" || rule.Tags != "" {
+			return newRuleError(rule.Name, "selectors are only supported by the global processing rules")
+		}
+		if rule.Enabled != nil {
+			return newRuleError(rule.Name, "enabled is only supported by the global processing rules")
+		}
+	}
+	return nil
+}
+
+// validateGlobalRuleNames checks that the names of use_global_rules are those of global rules
+func validateGlobalRuleNames(names []string, rules []LogsProcessingRule) error {
+	for _, name := range names {
+		found := false
+		for _, rule := range rules {
+			if rule.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return newSourceError("use_global_rules: no global processing rule is named %s", name)
+		}
+	}
+	return nil
+}
+
+// selectProcessingRules returns the rules applying to source
+func selectProcessingRules(rules []LogsProcessingRule, source *IntegrationConfigLogSource) []LogsProcessingRule {
+	selected := []LogsProcessingRule{}
+	for _, rule := range rules {
+		if rule.selectedBy(source) {
+			rule.global = true
+			selected = append(selected, rule)
+		}
+	}
+	return selected
+}
+
+// selectedBy returns true if the global rule applies to source: the sources setting
+// use_global_rules get the rules they name, the others the enabled rules whose
+// selector they match
+func (r *LogsProcessingRule) selectedBy(source *IntegrationConfigLogSource) bool {
+	if source.UseGlobalRules != nil {
+		for _, name := range source.UseGlobalRules {
+			if name == r.Name {
+				return true
+			}
+		}
+		return false
+	}
+	return (r.Enabled == nil || *r.Enabled) && r.appliesTo(source)
+}
+
+// appliesTo returns true if source has the service and all the tags of the selector of the rule,
+// a rule without selector applying to all the sources
+func (r *LogsProcessingRule) appliesTo(source *IntegrationConfigLogSource) bool {
+	if r.Service != "" && r.Service != source.Service {
+		return false
+	}
+	sourceTags := splitTags(source.Tags)
+	for tag := range splitTags(r.Tags) {
+		if !sourceTags[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTags returns the set of the comma separated tags
+func splitTags(tags string) map[string]bool {
+	set := make(map[string]bool)
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			set[tag] = true
+		}
+	}
+	return set
+}
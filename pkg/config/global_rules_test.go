@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleAppliesTo(t *testing.T) {
+	source := &IntegrationConfigLogSource{Service: "payments", Tags: "env:prod, team:billing"}
+
+	assert.True(t, (&LogsProcessingRule{}).appliesTo(source))
+	assert.True(t, (&LogsProcessingRule{Service: "payments"}).appliesTo(source))
+	assert.True(t, (&LogsProcessingRule{Tags: "env:prod"}).appliesTo(source))
+	assert.True(t, (&LogsProcessingRule{Service: "payments", Tags: "team:billing,env:prod"}).appliesTo(source))
+	assert.False(t, (&LogsProcessingRule{Service: "checkout"}).appliesTo(source))
+	assert.False(t, (&LogsProcessingRule{Tags: "env:staging"}).appliesTo(source))
+	assert.False(t, (&LogsProcessingRule{Service: "payments", Tags: "env:prod,team:web"}).appliesTo(source))
+}
+
+func TestGlobalProcessingRulesAreSelectedBySource(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.Set(globalProcessingRulesKey, []map[string]interface{}{
+		{"type": MASK_SEQUENCES, "name": "mask_cards", "pattern": "[0-9]{16}", "replace_placeholder": "[card]", "service": "payments"},
+		{"type": EXCLUDE_AT_MATCH, "name": "exclude_healthchecks", "pattern": "GET /health", "tags": "env:prod"},
+	})
+	err := buildLogsAgentIntegrationsConfig(testConfig, filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+
+	sources := getLogsSources(testConfig)
+
+	// nginx is tagged env:prod
+	assert.Equal(t, 1, len(sources[0].ProcessingRules))
+	assert.Equal(t, "exclude_healthchecks", sources[0].ProcessingRules[0].Name)
+	assert.NotNil(t, sources[0].ProcessingRules[0].Reg)
+
+	// the rules of the source are kept after the global ones
+	assert.Equal(t, 2, len(sources[1].ProcessingRules))
+	assert.Equal(t, "mocked_mask_rule", sources[1].ProcessingRules[0].Name)
+}
+
+func TestLogsConfigProcessingRulesApplyFirst(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.Set(logsConfigProcessingRulesKey, []map[string]interface{}{
+		{"type": MASK_SEQUENCES, "name": "scrub_tokens", "pattern": "token=[a-z0-9]+", "replace_placeholder": "token=[masked]"},
+	})
+	testConfig.Set(globalProcessingRulesKey, []map[string]interface{}{
+		{"type": EXCLUDE_AT_MATCH, "name": "exclude_healthchecks", "pattern": "GET /health"},
+	})
+	rules, err := getGlobalProcessingRules(testConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, "scrub_tokens", rules[0].Name)
+	assert.Equal(t, "token=[masked]", string(rules[0].ReplacePlaceholderBytes))
+	assert.Equal(t, "exclude_healthchecks", rules[1].Name)
+}
+
+func TestLogsConfigProcessingRulesFromEnvironment(t *testing.T) {
+	os.Setenv("DD_LOGS_CONFIG_PROCESSING_RULES", `[{"type":"mask_sequences","name":"scrub_tokens","pattern":"token=[a-z0-9]+","replace_placeholder":"token=[masked]"}]`)
+	defer os.Unsetenv("DD_LOGS_CONFIG_PROCESSING_RULES")
+	testConfig := viper.New()
+	bindEnvironment(testConfig)
+	rules, err := getGlobalProcessingRules(testConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rules))
+	assert.Equal(t, "token=[masked]", rules[0].ReplacePlaceholder)
+
+	os.Setenv("DD_LOGS_CONFIG_PROCESSING_RULES", `[{"type":`)
+	_, err = getGlobalProcessingRules(testConfig)
+	assert.Contains(t, err.Error(), "invalid logs_config.processing_rules")
+}
+
+func TestGlobalProcessingRulesSkipRawForwardSources(t *testing.T) {
+	source := &IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, RawForward: true}
+	globalRules := []LogsProcessingRule{{Name: "exclude_healthchecks", Type: EXCLUDE_AT_MATCH, Pattern: "GET /health"}}
+	assert.Nil(t, prepareSource(source, globalRules))
+	assert.Equal(t, 0, len(source.ProcessingRules))
+}
+
+func TestGlobalProcessingRulesAreValidated(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.Set(globalProcessingRulesKey, []map[string]interface{}{
+		{"type": "unknown", "name": "broken"},
+	})
+	err := buildLogsAgentIntegrationsConfig(testConfig, filepath.Join(testsPath, "complete", "conf.d"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "rule `broken`: type unknown is unsupported")
+}
+
+func TestSourceProcessingRulesCantHaveSelectors(t *testing.T) {
+	err := validateRuleSelectors([]LogsProcessingRule{{Name: "scoped", Service: "payments"}})
+	assert.NotNil(t, err)
+}
+
+func TestUseGlobalRules(t *testing.T) {
+	disabled := false
+	globalRules := []LogsProcessingRule{
+		{Name: "exclude_healthchecks", Type: EXCLUDE_AT_MATCH, Pattern: "GET /health"},
+		{Name: "exclude_debug", Type: EXCLUDE_AT_MATCH, Pattern: "DEBUG", Enabled: &disabled},
+		{Name: "mask_cards", Type: MASK_SEQUENCES, Pattern: "[0-9]{16}", Service: "payments"},
+	}
+
+	// by default, the enabled rules matching the source apply
+	rules := selectProcessingRules(globalRules, &IntegrationConfigLogSource{Service: "web"})
+	assert.Equal(t, 1, len(rules))
+	assert.Equal(t, "exclude_healthchecks", rules[0].Name)
+
+	// the named rules apply, whatever their selector and enabled flag
+	source := &IntegrationConfigLogSource{Service: "web", UseGlobalRules: []string{"exclude_debug", "mask_cards"}}
+	rules = selectProcessingRules(globalRules, source)
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, "exclude_debug", rules[0].Name)
+	assert.Equal(t, "mask_cards", rules[1].Name)
+
+	// an empty list disables them all
+	rules = selectProcessingRules(globalRules, &IntegrationConfigLogSource{UseGlobalRules: []string{}})
+	assert.Equal(t, 0, len(rules))
+
+	assert.Nil(t, validateGlobalRuleNames(source.UseGlobalRules, globalRules))
+	err := validateGlobalRuleNames([]string{"exclude_typo"}, globalRules)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no global processing rule is named exclude_typo")
+}
+
+func TestSourceProcessingRulesCantBeDisabled(t *testing.T) {
+	enabled := true
+	err := validateRuleSelectors([]LogsProcessingRule{{Name: "scoped", Enabled: &enabled}})
+	assert.NotNil(t, err)
+}
+
+func TestGlobalProcessingRulesCanBeDisabled(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.Set(globalProcessingRulesKey, []map[string]interface{}{
+		{"type": EXCLUDE_AT_MATCH, "name": "exclude_debug", "pattern": "DEBUG", "enabled": false},
+	})
+	rules, err := getGlobalProcessingRules(testConfig)
+	assert.Nil(t, err)
+	assert.NotNil(t, rules[0].Enabled)
+	assert.False(t, *rules[0].Enabled)
+}
@@ -0,0 +1,183 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util"
+	"github.com/spf13/viper"
+)
+
+// Hostname resolution strategies, by priority
+const (
+	HostnameFromConfig        = "config"
+	HostnameFromCloudMetadata = "cloud_metadata"
+	HostnameFromOS            = "os"
+	HostnameFromFQDN          = "fqdn"
+)
+
+const (
+	hostnameCacheFile      = "hostname.json"
+	cloudMetadataTimeout   = 300 * time.Millisecond
+	ec2HostnameURL         = "http://169.254.169.254/latest/meta-data/instance-id"
+	gceHostnameURL         = "http://metadata.google.internal/computeMetadata/v1/instance/hostname"
+	defaultUnknownHostname = "unknown"
+)
+
+// cachedHostname is the hostname persisted between runs
+type cachedHostname struct {
+	Hostname string
+	Strategy string
+}
+
+// A hostnameResolver resolves the hostname shipped with the logs, trying in order
+// the config override, the cloud provider metadata when enabled, and the hostname
+// of agent6, resolved from the OS, optionally qualified. As the hostname drives all the downstream correlation, the result of
+// the cloud metadata is cached on disk, so that a metadata server temporarily
+// unavailable doesn't change the hostname
+type hostnameResolver struct {
+	config     *viper.Viper
+	client     *http.Client
+	ec2URL     string
+	gceURL     string
+	osHostname func() (string, error)
+}
+
+func newHostnameResolver(config *viper.Viper) *hostnameResolver {
+	return &hostnameResolver{
+		config:     config,
+		client:     &http.Client{Timeout: cloudMetadataTimeout},
+		ec2URL:     ec2HostnameURL,
+		gceURL:     gceHostnameURL,
+		osHostname: util.GetHostname,
+	}
+}
+
+// resolve returns the hostname and the strategy it was resolved with
+func (r *hostnameResolver) resolve() (string, string) {
+	if hostname := r.config.GetString("hostname"); hostname != "" {
+		return hostname, HostnameFromConfig
+	}
+
+	if r.config.GetBool("log_hostname_use_cloud_metadata") {
+		if hostname, err := r.cloudMetadataHostname(); err == nil {
+			r.writeCache(hostname, HostnameFromCloudMetadata)
+			return hostname, HostnameFromCloudMetadata
+		}
+		if cached := r.readCache(); cached.Strategy == HostnameFromCloudMetadata {
+			return cached.Hostname, HostnameFromCloudMetadata + " (cached)"
+		}
+	}
+
+	hostname, err := r.osHostname()
+	if err != nil || hostname == "" {
+		log.Println("Can't resolve hostname:", err)
+		return defaultUnknownHostname, HostnameFromOS
+	}
+	if r.config.GetBool("log_hostname_fqdn") {
+		if fqdn, err := lookupFQDN(hostname); err == nil {
+			return fqdn, HostnameFromFQDN
+		}
+	}
+	return hostname, HostnameFromOS
+}
+
+// cloudMetadataHostname queries the metadata servers of the supported cloud providers
+func (r *hostnameResolver) cloudMetadataHostname() (string, error) {
+	if hostname, err := r.getMetadata(r.gceURL, "Metadata-Flavor", "Google"); err == nil {
+		return strings.SplitN(hostname, ".", 2)[0], nil
+	}
+	return r.getMetadata(r.ec2URL, "", "")
+}
+
+// getMetadata returns the body of a metadata server response
+func (r *hostnameResolver) getMetadata(url, header, value string) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	hostname := strings.TrimSpace(string(body))
+	if hostname == "" {
+		return "", fmt.Errorf("empty hostname returned by metadata server")
+	}
+	return hostname, nil
+}
+
+func (r *hostnameResolver) cachePath() string {
+	runPath := r.config.GetString("run_path")
+	if runPath == "" {
+		return ""
+	}
+	return filepath.Join(runPath, hostnameCacheFile)
+}
+
+func (r *hostnameResolver) readCache() cachedHostname {
+	var cached cachedHostname
+	path := r.cachePath()
+	if path == "" {
+		return cached
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cached
+	}
+	json.Unmarshal(b, &cached)
+	return cached
+}
+
+func (r *hostnameResolver) writeCache(hostname, strategy string) {
+	path := r.cachePath()
+	if path == "" {
+		return
+	}
+	b, err := json.Marshal(cachedHostname{Hostname: hostname, Strategy: strategy})
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(path, b, 0644)
+	if err != nil {
+		log.Println("Can't cache hostname:", err)
+	}
+}
+
+// lookupFQDN returns the fully qualified domain name of hostname
+func lookupFQDN(hostname string) (string, error) {
+	addrs, err := net.LookupIP(hostname)
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		names, err := net.LookupAddr(addr.String())
+		if err == nil && len(names) > 0 {
+			return strings.TrimSuffix(names[0], "."), nil
+		}
+	}
+	return "", fmt.Errorf("no fqdn found for %s", hostname)
+}
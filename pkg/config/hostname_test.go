@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type HostnameTestSuite struct {
+	suite.Suite
+
+	runPath  string
+	config   *viper.Viper
+	server   *httptest.Server
+	metadata string
+	resolver *hostnameResolver
+}
+
+func (suite *HostnameTestSuite) SetupTest() {
+	suite.runPath, _ = ioutil.TempDir("", "hostname")
+	suite.config = viper.New()
+	suite.config.Set("run_path", suite.runPath)
+	suite.config.Set("log_hostname_use_cloud_metadata", true)
+	suite.metadata = ""
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if suite.metadata == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, suite.metadata)
+	}))
+	suite.resolver = newHostnameResolver(suite.config)
+	suite.resolver.ec2URL = suite.server.URL
+	suite.resolver.gceURL = suite.server.URL + "/gce"
+	suite.resolver.osHostname = func() (string, error) { return "os-host", nil }
+}
+
+func (suite *HostnameTestSuite) TearDownTest() {
+	suite.server.Close()
+	os.RemoveAll(suite.runPath)
+}
+
+func (suite *HostnameTestSuite) assertResolves(hostname, strategy string) {
+	h, s := suite.resolver.resolve()
+	suite.Equal(hostname, h)
+	suite.Equal(strategy, s)
+}
+
+func (suite *HostnameTestSuite) TestConfigOverrideWins() {
+	suite.config.Set("hostname", "my.host")
+	suite.metadata = "i-1234"
+	suite.assertResolves("my.host", HostnameFromConfig)
+}
+
+func (suite *HostnameTestSuite) TestCloudMetadataIsCached() {
+	suite.metadata = "i-1234"
+	suite.assertResolves("i-1234", HostnameFromCloudMetadata)
+
+	// the metadata server is temporarily unavailable
+	suite.metadata = ""
+	suite.assertResolves("i-1234", HostnameFromCloudMetadata+" (cached)")
+}
+
+func (suite *HostnameTestSuite) TestFallsBackOnOSHostname() {
+	suite.assertResolves("os-host", HostnameFromOS)
+	suite.config.Set("log_hostname_use_cloud_metadata", false)
+	suite.metadata = "i-1234"
+	suite.assertResolves("os-host", HostnameFromOS)
+}
+
+func (suite *HostnameTestSuite) TestUnknownHostname() {
+	suite.resolver.osHostname = func() (string, error) { return "", fmt.Errorf("no hostname") }
+	suite.assertResolves("unknown", HostnameFromOS)
+}
+
+func TestHostnameTestSuite(t *testing.T) {
+	suite.Run(t, new(HostnameTestSuite))
+}
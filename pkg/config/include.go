@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// includeKey lists the snippet files whose settings a source includes
+const includeKey = "include"
+
+// includeDir returns the directory the included paths of file are relative to,
+// the first conf.d directory for the sources of the environment and the remote config
+func includeDir(ddconfdPath, file string) string {
+	switch file {
+	case LogsSourcesEnv, RemoteConfigFile:
+		return confdDirs(ddconfdPath)[0]
+	}
+	return filepath.Dir(confdFile(ddconfdPath, file))
+}
+
+// resolveIncludes returns the settings of source merged over those of the
+// snippets it includes, in order, the relative paths of the snippets being
+// relative to dir. The sources which aren't maps are left to checkSource
+func resolveIncludes(config *viper.Viper, source interface{}, dir string) (interface{}, error) {
+	settings, err := cast.ToStringMapE(source)
+	if err != nil {
+		return source, nil
+	}
+	return includeSnippets(config, settings, dir, nil)
+}
+
+// includeSnippets merges settings over the snippets they include, chain being
+// the paths of the snippets already met, to detect cycles
+func includeSnippets(config *viper.Viper, settings map[string]interface{}, dir string, chain []string) (map[string]interface{}, error) {
+	var include interface{}
+	own := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if strings.EqualFold(key, includeKey) {
+			include = value
+		} else {
+			own[key] = value
+		}
+	}
+	if include == nil {
+		return settings, nil
+	}
+	paths, err := includedPaths(include)
+	if err != nil {
+		return nil, newSourceError("%v", err)
+	}
+	if err := normalizeTags(own); err != nil {
+		return nil, newSourceError("%v", err)
+	}
+	merged := map[string]interface{}{}
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		path = filepath.Clean(path)
+		for _, met := range chain {
+			if met == path {
+				return nil, newSourceError("snippets include each other: %s", strings.Join(append(chain, path), " -> "))
+			}
+		}
+		snippet, err := readSnippet(config, path)
+		if err != nil {
+			return nil, err
+		}
+		snippet, err = includeSnippets(config, snippet, filepath.Dir(path), append(chain, path))
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeSettings(merged, snippet)
+	}
+	merged = mergeSettings(merged, own)
+	// the template extended by the source applies below its snippets
+	for key, value := range own {
+		if strings.EqualFold(key, extendsKey) {
+			merged[extendsKey] = value
+		}
+	}
+	return merged, nil
+}
+
+// includedPaths returns the paths of an include setting, a path or a list of paths
+func includedPaths(include interface{}) ([]string, error) {
+	if path, ok := include.(string); ok {
+		return []string{path}, nil
+	}
+	paths, err := cast.ToStringSliceE(include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected a path or a list of paths", includeKey)
+	}
+	return paths, nil
+}
+
+// readSnippet returns the settings of the snippet file at path, its ${VAR}
+// references interpolated and its secrets resolved as in the integration configs
+func readSnippet(config *viper.Viper, path string) (map[string]interface{}, error) {
+	snippetCfg := viper.New()
+	snippetCfg.SetConfigFile(path)
+	err := snippetCfg.ReadInConfig()
+	if err == nil {
+		err = interpolateEnv(config, snippetCfg)
+	}
+	if err == nil {
+		err = resolveSecrets(config, snippetCfg)
+	}
+	if err != nil {
+		return nil, newSourceError("can't include %s: %v", path, err)
+	}
+	snippet := snippetCfg.AllSettings()
+	if err := normalizeTags(snippet); err != nil {
+		return nil, newSourceError("can't include %s: %v", path, err)
+	}
+	return snippet, nil
+}
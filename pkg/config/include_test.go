@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourcesIncludeSnippets(t *testing.T) {
+	sources, configErrors, err := loadLogsSources(viper.New(), filepath.Join(testsPath, "includes", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sources))
+
+	app := sources[0]
+	assert.Equal(t, "app", app.Service)
+	assert.Equal(t, "go", app.Source)
+	assert.Equal(t, "env:prod,team:checkout", app.Tags)
+	assert.Equal(t, 2, len(app.ProcessingRules))
+	assert.Equal(t, "mask_credit_cards", app.ProcessingRules[0].Name)
+	assert.Equal(t, "exclude_healthchecks", app.ProcessingRules[1].Name)
+
+	audit := sources[1]
+	assert.Equal(t, "audit", audit.Service)
+	assert.Equal(t, 1, len(audit.ProcessingRules))
+	assert.Equal(t, "mask_credit_cards", audit.ProcessingRules[0].Name)
+
+	assert.Equal(t, 2, len(configErrors))
+	assert.Contains(t, configErrors[0].Error(), "snippets include each other")
+	assert.Contains(t, configErrors[1].Error(), "can't include")
+}
+
+func TestIncludedSnippetsApplyOverTemplates(t *testing.T) {
+	templates := map[string]sourceTemplate{
+		"web": {"type": "file", "service": "web", "source": "nginx"},
+	}
+	dir := filepath.Join(testsPath, "includes", "conf.d")
+	settings, err := resolveIncludes(viper.New(), map[string]interface{}{"extends": "web", "include": "../snippets/web.yaml", "path": "/var/log/web.log"}, dir)
+	assert.Nil(t, err)
+	source, err := resolveTemplates(settings.(map[string]interface{}), templates)
+	assert.Nil(t, err)
+	assert.Equal(t, "web", source["service"])
+	assert.Equal(t, "go", source["source"])
+	assert.Equal(t, "file", source["type"])
+	assert.NotContains(t, source, "include")
+}
@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// Master switches administratively disabling whole classes of inputs,
+// whatever the log sources configured
+const (
+	DisableFileCollection      = "logs_config.disable_file_collection"
+	DisableNetworkListeners    = "logs_config.disable_network_listeners"
+	DisableContainerCollection = "logs_config.disable_container_collection"
+)
+
+// inputSwitches maps the source types to the switch disabling them
+var inputSwitches = map[string]string{
+	FILE_TYPE:   DisableFileCollection,
+	TCP_TYPE:    DisableNetworkListeners,
+	UDP_TYPE:    DisableNetworkListeners,
+	UNIX_TYPE:   DisableNetworkListeners,
+	AGENT_TYPE:  DisableNetworkListeners,
+	SNMP_TYPE:   DisableNetworkListeners,
+	FLOW_TYPE:   DisableNetworkListeners,
+	DOCKER_TYPE: DisableContainerCollection,
+}
+
+// IsInputDisabled returns true if the inputs of sourceType are disabled
+func IsInputDisabled(sourceType string) bool {
+	return isInputDisabled(LogsAgent, sourceType)
+}
+
+func isInputDisabled(config *viper.Viper, sourceType string) bool {
+	key, ok := inputSwitches[sourceType]
+	return ok && config.GetBool(key)
+}
+
+// DisabledInputs returns the switches that are turned on
+func DisabledInputs() []string {
+	disabled := []string{}
+	for _, key := range []string{DisableFileCollection, DisableNetworkListeners, DisableContainerCollection} {
+		if LogsAgent.GetBool(key) {
+			disabled = append(disabled, key)
+		}
+	}
+	return disabled
+}
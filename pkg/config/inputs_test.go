@@ -0,0 +1,35 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsInputDisabled(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.Set(DisableNetworkListeners, true)
+	assert.True(t, isInputDisabled(testConfig, TCP_TYPE))
+	assert.True(t, isInputDisabled(testConfig, UDP_TYPE))
+	assert.False(t, isInputDisabled(testConfig, FILE_TYPE))
+	assert.False(t, isInputDisabled(testConfig, DOCKER_TYPE))
+}
+
+func TestDisabledInputsAreIgnored(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.Set(DisableFileCollection, true)
+	testConfig.Set(DisableContainerCollection, true)
+	err := buildLogsAgentIntegrationsConfig(testConfig, filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+
+	sources := getLogsSources(testConfig)
+	assert.Equal(t, 1, len(sources))
+	assert.Equal(t, TCP_TYPE, sources[0].Type)
+}
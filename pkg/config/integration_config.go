@@ -6,11 +6,16 @@
 package config
 
 import (
-	"fmt"
 	"io/ioutil"
+	"log"
+	"math"
+	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
@@ -18,14 +23,56 @@ const (
 	LOGS_RULES       = "LogsRules"
 	TCP_TYPE         = "tcp"
 	UDP_TYPE         = "udp"
+	UNIX_TYPE        = "unix"
 	FILE_TYPE        = "file"
 	DOCKER_TYPE      = "docker"
+	AGENT_TYPE       = "agent"
+	MQTT_TYPE        = "mqtt"
+	AMQP_TYPE        = "amqp"
+	KAFKA_TYPE       = "kafka"
+	SNMP_TYPE        = "snmp_traps"
+	FLOW_TYPE        = "flow"
+	COMMAND_TYPE     = "command"
 	EXCLUDE_AT_MATCH = "exclude_at_match"
+	INCLUDE_AT_MATCH = "include_at_match"
 	MASK_SEQUENCES   = "mask_sequences"
 	MULTILINE        = "multi_line"
+
+	MULTILINE_CONTINUATION = "multi_line_continuation"
+
+	// the offsets a kafka source is consumed from when its group has none
+	OffsetResetEarliest = "earliest"
+	OffsetResetLatest   = "latest"
+	REMAP_SEVERITY      = "remap_severity"
+
+	// where a file source is tailed from when there is no offset for it
+	StartPositionBeginning = "beginning"
+	StartPositionEnd       = "end"
+
+	// FormatCRI is the format of the container log files written by the CRI
+	// runtimes such as containerd: <time> <stream> <P|F> <content>
+	FormatCRI = "cri"
 )
 
-const INTEGRATION_CONFIG_EXTENTION = ".yaml"
+// defaultContinuationPattern matches the lines starting with whitespace,
+// such as the frames of Python tracebacks and Java stack traces
+const defaultContinuationPattern = `^\s`
+
+// integrationConfigExtensions are the extensions of the integration config files,
+// viper picking the format from the extension
+var integrationConfigExtensions = []string{".yaml", ".yml", ".json"}
+
+const (
+	// integrationDirSuffix ends the names of the per-integration directories of
+	// the datadog agent, such as conf.d/nginx.d
+	integrationDirSuffix = ".d"
+	// defaultConfName is the config file of an integration directory, which
+	// defaults to defaultConfName+defaultConfSuffix shipped with the integration
+	defaultConfName   = "conf.yaml"
+	defaultConfSuffix = ".default"
+	// autoConfName holds the autodiscovery templates of an integration directory
+	autoConfName = "auto_conf"
+)
 
 // LogsProcessingRule defines an exclusion or a masking rule to
 // be applied on log lines
@@ -36,26 +83,139 @@ type LogsProcessingRule struct {
 	Pattern                 string
 	Reg                     *regexp.Regexp
 	ReplacePlaceholderBytes []byte
+
+	// exclude_at_match and include_at_match rules match Field of json logs
+	// instead of the whole line when set, nested attributes being separated with dots
+	Field string
+
+	// selectors of the global processing rules
+	Service string
+	Tags    string
+	// Enabled set to false makes a global processing rule apply only to the
+	// sources listing it in use_global_rules
+	Enabled *bool
+
+	// remap_severity rules set the severity of the matching messages
+	// to Severity, optionally only when From was detected
+	Severity      string
+	From          string
+	SeverityLevel int
+	FromLevel     int
+
+	// global is true for the rules selected from the global processing rules
+	global bool
 }
 
 // IntegrationConfigLogSource represents a log source config, which can be for instance
 // a file to tail or a port to listen to
 type IntegrationConfigLogSource struct {
 	Type string
-
-	Port int    // Network
-	Path string // File
+	ID   string // computed by BuildSourceID
+
+	Port          int           // Network, Snmp, Flow
+	PortRange     string        `mapstructure:"port_range"`     // Network
+	ReorderWindow time.Duration `mapstructure:"reorder_window"` // Network
+	TLSCert       string        `mapstructure:"tls_cert"`       // Tcp, Mqtt, Amqp, Kafka
+	TLSKey        string        `mapstructure:"tls_key"`        // Tcp, Mqtt, Amqp, Kafka
+	Framing       string        // Tcp
+	Encoding      string        // File, Tcp, Udp
+	Path          string        // File, Unix
+	// StartPosition is where a file is tailed from when no offset was saved for it,
+	// StartPositionEnd when empty; the saved offsets always take precedence
+	StartPosition string `mapstructure:"start_position"` // File
+	// Format is the format of the lines of the file, FormatCRI or "" for raw lines
+	Format string // File
 
 	Image string // Docker
 	Label string // Docker
 
-	Service         string
-	Logset          string
-	Source          string
-	SourceCategory  string
-	Tags            string
+	// Command is the executable the agent runs and restarts, Args its arguments,
+	// its stdout and stderr being collected as two streams
+	Command string   // Command
+	Args    []string // Command
+
+	Service          string
+	ServicePattern   string         `mapstructure:"service_pattern"`
+	ServiceReg       *regexp.Regexp // compiled ServicePattern
+	ServiceAttribute string         `mapstructure:"service_attribute"`
+	ServiceLabel     string         `mapstructure:"service_label"` // Docker
+
+	// Logset and APIKey route the logs of the source to another logset or org
+	// than those of the agent; the logset of the agent isn't used with APIKey
+	Logset         string
+	APIKey         string `mapstructure:"api_key"`
+	Source         string
+	SourceCategory string
+	Tags           string
+	// Attributes are static key/values attached to every message of the source,
+	// serialized in the tags payload along with the source and the tags
+	Attributes      map[string]string
 	TagsPayload     []byte
 	ProcessingRules []LogsProcessingRule `mapstructure:"log_processing_rules"`
+	// UseGlobalRules names the global processing rules applying to the source,
+	// whatever their selector and enabled flag, instead of the enabled rules
+	// whose selector it matches; nil when unset, an empty list disabling them all
+	UseGlobalRules []string `mapstructure:"use_global_rules"`
+
+	// Priority is high, normal or low, see PriorityClass
+	Priority string
+
+	// TimestampSource is parsed or received, see UsesReceiveTime
+	TimestampSource string `mapstructure:"timestamp_source"`
+
+	// SequenceNumbers numbers the messages of the source, persisting
+	// the numbering across restarts in the registry
+	SequenceNumbers bool `mapstructure:"sequence_numbers"` // File, Docker
+
+	// Broker is the host:port of the MQTT broker to subscribe to Topics on,
+	// or of the AMQP broker to consume Queue from, TLSCert and TLSKey
+	// being the client certificate when UseTLS is set
+	Broker   string   // Mqtt, Amqp
+	Topics   []string // Mqtt, Kafka
+	ClientID string   `mapstructure:"client_id"` // Mqtt
+	Username string   // Mqtt, Amqp
+	Password string   // Mqtt, Amqp
+	UseTLS   bool     `mapstructure:"use_tls"` // Mqtt, Amqp, Kafka
+	TLSCA    string   `mapstructure:"tls_ca"`  // Mqtt, Amqp, Kafka
+	Queue    string   // Amqp
+	VHost    string   `mapstructure:"vhost"` // Amqp
+	// PrefetchCount bounds the number of messages received but not yet acknowledged
+	PrefetchCount int `mapstructure:"prefetch_count"` // Amqp
+	// Brokers are the host:port of the Kafka brokers to bootstrap from, the Topics
+	// being consumed as a member of the consumer group GroupID, from the offsets
+	// of OffsetReset when the group has none
+	Brokers     []string // Kafka
+	GroupID     string   `mapstructure:"group_id"`     // Kafka
+	OffsetReset string   `mapstructure:"offset_reset"` // Kafka
+	// Community filters the traps received by their community when set, the OIDs
+	// being named after the MIBFile listing a name and an OID per line
+	Community string // Snmp
+	MIBFile   string `mapstructure:"mib_file"` // Snmp
+	// TopicTags extracts tags from the topics, such as devices/{device}/logs
+	TopicTags    string         `mapstructure:"topic_tags"` // Mqtt
+	TopicTagsReg *regexp.Regexp // compiled TopicTags
+
+	// PathTags extracts tags from the path of the file, such as
+	// /var/log/apps/{service}/{env}/app.log
+	PathTags    string         `mapstructure:"path_tags"` // File
+	PathTagsReg *regexp.Regexp // compiled PathTags
+	// ExcludePaths are the patterns of the files matching the path pattern not to
+	// tail, such as *.gz, matching the name of the files or their path when they
+	// contain a separator
+	ExcludePaths []string `mapstructure:"exclude_paths"` // File
+
+	// RawForward relays the logs of the source as they are, skipping their
+	// processing: only the api key and the end of line are added
+	RawForward bool `mapstructure:"raw_forward"`
+
+	// SamplingRate is the fraction of the lines of the source forwarded, between 0
+	// and 1, nil forwarding them all; the lines kept are evenly spread
+	SamplingRate *float64 `mapstructure:"sampling_rate"`
+
+	// Namespace is the directory of conf.d the source is configured in, when it
+	// has a namespace file, NamespaceMaxBytesPerSecond being its quota
+	Namespace                  string
+	NamespaceMaxBytesPerSecond int64
 }
 
 // IntegrationConfig represents a dd agent config, which includes infra and logs parts
@@ -72,74 +232,298 @@ func getLogsSources(config *viper.Viper) []*IntegrationConfigLogSource {
 	return config.Get(LOGS_RULES).([]*IntegrationConfigLogSource)
 }
 
+// SetLogsSources replaces the integration sources, when the config is reloaded
+func SetLogsSources(sources []*IntegrationConfigLogSource) {
+	LogsAgent.Set(LOGS_RULES, sources)
+	updateFingerprint(LogsAgent)
+}
+
 // BuildLogsAgentIntegrationsConfigs looks for all yml configs in the ddconfdPath directory,
 // and initializes the LogsAgent integrations configs
 func BuildLogsAgentIntegrationsConfigs(ddconfdPath string) error {
 	return buildLogsAgentIntegrationsConfig(LogsAgent, ddconfdPath)
 }
 
+// LoadLogsSources returns the sources configured in the ddconfdPath directory,
+// without replacing the current ones, and the errors of the invalid files and
+// sources skipped. An error is only returned when the global rules are invalid
+func LoadLogsSources(ddconfdPath string) ([]*IntegrationConfigLogSource, []error, error) {
+	return loadLogsSources(LogsAgent, ddconfdPath)
+}
+
 func buildLogsAgentIntegrationsConfig(config *viper.Viper, ddconfdPath string) error {
+	sources, configErrors, err := loadLogsSources(config, ddconfdPath)
+	if err != nil {
+		return err
+	}
+	ReportConfigErrors(configErrors)
+	config.Set(LOGS_RULES, sources)
+	updateFingerprint(config)
+	return nil
+}
+
+// loadLogsSources returns the valid sources of ddconfdPath, skipping the invalid
+// files and sources, whose errors are returned; only invalid global processing
+// rules, which apply to every source, prevent the sources from loading
+func loadLogsSources(config *viper.Viper, ddconfdPath string) ([]*IntegrationConfigLogSource, []error, error) {
+
+	globalRules, err := getGlobalProcessingRules(config)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	integrationConfigFiles := availableIntegrationConfigs(ddconfdPath)
 	logsSourceConfigs := []*IntegrationConfigLogSource{}
-
+	configErrors := []error{}
+
+	// all the files are read first, as sources can extend the templates of any file
+	viperCfgs := make(map[string]*viper.Viper, len(integrationConfigFiles)+1)
+	// the remote config is merged with conf.d, and the sources of the environment
+	// come last, as they override those of conf.d
+	if remoteConfigContent() != nil {
+		integrationConfigFiles = append(integrationConfigFiles, RemoteConfigFile)
+	}
+	if os.Getenv(LogsSourcesEnv) != "" {
+		integrationConfigFiles = append(integrationConfigFiles, LogsSourcesEnv)
+	}
+	namespaces, namespaceErrors := readNamespaces(config, ddconfdPath)
+	for _, name := range sortedNamespaceNames(namespaceErrors) {
+		// the sources of an invalid namespace are skipped, as they'd miss its rules
+		configErrors = append(configErrors, namespaceErrors[name])
+	}
+	readable := []string{}
 	for _, file := range integrationConfigFiles {
-		var integrationConfig IntegrationConfig
-		var viperCfg = viper.New()
-		viperCfg.SetConfigFile(filepath.Join(ddconfdPath, file))
-		err := viperCfg.ReadInConfig()
-		if err != nil {
-			return err
+		if namespaceErrors[namespaceOf(file)] != nil {
+			continue
 		}
-		err = viperCfg.Unmarshal(&integrationConfig)
+		viperCfg, err := readIntegrationConfig(config, ddconfdPath, file)
 		if err != nil {
-			return err
+			configErrors = append(configErrors, err)
+			continue
 		}
+		viperCfgs[file] = viperCfg
+		readable = append(readable, file)
+	}
+	templates, readable, templateErrors := collectValidTemplates(readable, viperCfgs)
+	configErrors = append(configErrors, templateErrors...)
 
-		for _, logSourceConfigIterator := range integrationConfig.Logs {
-			logSourceConfig := logSourceConfigIterator
-			err = validateSource(logSourceConfig)
+	for _, file := range readable {
+		viperCfg := viperCfgs[file]
+		if !viperCfg.IsSet("logs") {
+			continue
+		}
+		content := integrationConfigContent(ddconfdPath, file)
+		sources, err := cast.ToSliceE(viperCfg.Get("logs"))
+		if err != nil {
+			configErrors = append(configErrors, newFileError(file, err))
+			continue
+		}
+		for i, settings := range sources {
+			if file == RemoteConfigFile {
+				err = checkRemoteIncludes(settings)
+			} else {
+				settings, err = resolveIncludes(config, settings, includeDir(ddconfdPath, file))
+			}
 			if err != nil {
-				return err
+				configErrors = append(configErrors, locateError(err, file, content, i))
+				continue
+			}
+			logSourceConfig, skippedRules, err := checkSource(settings, templates, namespaces[namespaceOf(file)], globalRules)
+			for _, ruleErr := range skippedRules {
+				configErrors = append(configErrors, locateError(ruleErr, file, content, i))
+			}
+			if err == nil && file == RemoteConfigFile {
+				err = checkRemoteSourceType(logSourceConfig)
 			}
-
-			rules, err := validateProcessingRules(logSourceConfig.ProcessingRules)
 			if err != nil {
-				return err
+				configErrors = append(configErrors, locateError(err, file, content, i))
+				continue
+			}
+			if isInputDisabled(config, logSourceConfig.Type) {
+				log.Printf("Ignoring %s source %d of %s: disabled by %s", logSourceConfig.Type, i, file, inputSwitches[logSourceConfig.Type])
+				continue
+			}
+			if file == LogsSourcesEnv {
+				logsSourceConfigs = overrideSource(logsSourceConfigs, logSourceConfig)
+			} else {
+				logsSourceConfigs = append(logsSourceConfigs, logSourceConfig)
 			}
-			logSourceConfig.ProcessingRules = rules
+		}
+	}
+	return logsSourceConfigs, configErrors, nil
+}
 
-			logSourceConfig.TagsPayload = BuildTagsPayload(logSourceConfig.Tags, logSourceConfig.Source, logSourceConfig.SourceCategory)
+// overrideSource returns sources with source replacing the one collecting
+// the same logs, or appended if there is none
+func overrideSource(sources []*IntegrationConfigLogSource, source *IntegrationConfigLogSource) []*IntegrationConfigLogSource {
+	for i, s := range sources {
+		if s.ID == source.ID {
+			log.Printf("Source %s of %s overrides the one of conf.d", source.ID, LogsSourcesEnv)
+			sources[i] = source
+			return sources
+		}
+	}
+	return append(sources, source)
+}
 
-			logsSourceConfigs = append(logsSourceConfigs, &logSourceConfig)
+// readIntegrationConfig reads an integration config file of ddconfdPath, the
+// sources of the environment when file is LogsSourcesEnv or the remote config
+// when file is RemoteConfigFile. Except for the remote config, its ${VAR} references
+// to environment variables are interpolated and its secrets resolved with the
+// secret backend of config
+func readIntegrationConfig(config *viper.Viper, ddconfdPath, file string) (*viper.Viper, error) {
+	var viperCfg = viper.New()
+	var err error
+	switch file {
+	case LogsSourcesEnv:
+		viperCfg.SetConfigType("json")
+		err = viperCfg.ReadConfig(strings.NewReader(`{"logs":` + os.Getenv(LogsSourcesEnv) + `}`))
+	case RemoteConfigFile:
+		viperCfg, err = parseRemoteConfig(remoteConfigContent())
+	default:
+		viperCfg.SetConfigFile(confdFile(ddconfdPath, file))
+		if strings.HasSuffix(file, defaultConfSuffix) {
+			viperCfg.SetConfigType("yaml")
 		}
+		err = viperCfg.ReadInConfig()
+	}
+	// the remote config is data of the endpoint: it can't read the environment
+	// nor the secrets of the host
+	if err == nil && file != RemoteConfigFile {
+		err = interpolateEnv(config, viperCfg)
+	}
+	if err == nil && file != RemoteConfigFile {
+		err = resolveSecrets(config, viperCfg)
 	}
-	config.Set(LOGS_RULES, logsSourceConfigs)
+	if err != nil {
+		return nil, newFileError(file, err)
+	}
+	return viperCfg, nil
+}
+
+// integrationConfigContent returns the content of an integration config file
+// to locate errors, nil for the sources of the environment
+func integrationConfigContent(ddconfdPath, file string) []byte {
+	switch file {
+	case LogsSourcesEnv:
+		return nil
+	case RemoteConfigFile:
+		return remoteConfigContent()
+	}
+	return readConfigFile(confdFile(ddconfdPath, file))
+}
+
+// resolveSource returns the settings of a source of the logs section,
+// its tags normalized and merged over the template it extends
+func resolveSource(source interface{}, templates map[string]sourceTemplate) (map[string]interface{}, error) {
+	settings, err := cast.ToStringMapE(source)
+	if err != nil {
+		return nil, newSourceError("invalid source: %v", err)
+	}
+	if err := normalizeTags(settings); err != nil {
+		return nil, newSourceError("%v", err)
+	}
+	return resolveTemplates(settings, templates)
+}
+
+// prepareSource validates the processing rules of a validated source, compiles its
+// patterns and computes its tags payload and ID, globalRules applying before its rules
+func prepareSource(source *IntegrationConfigLogSource, globalRules []LogsProcessingRule) error {
+	rules, err := validateProcessingRules(source.ProcessingRules)
+	if err == nil {
+		err = validateRuleSelectors(rules)
+	}
+	if err == nil {
+		err = validateGlobalRuleNames(source.UseGlobalRules, globalRules)
+	}
+	if err != nil {
+		return err
+	}
+	// the rules of the source come last so that they take precedence,
+	// the logs forwarded raw not being processed
+	if !source.RawForward {
+		source.ProcessingRules = append(selectProcessingRules(globalRules, source), rules...)
+	}
+
+	if source.ServicePattern != "" {
+		source.ServiceReg = regexp.MustCompile(source.ServicePattern)
+	}
+	if source.PathTags != "" {
+		source.PathTagsReg, _ = CompilePathTags(source.PathTags)
+	}
+	if source.TopicTags != "" {
+		source.TopicTagsReg, _ = CompilePathTags(source.TopicTags)
+	}
+
+	source.TagsPayload = BuildTagsPayload(source, source.Tags)
+	source.ID = BuildSourceID(source)
 	return nil
 }
 
-// availableIntegrationConfigs lists yaml files in ddconfdPath
+// availableIntegrationConfigs lists the yaml and json files in the conf.d directories
+// of ddconfdPath, relative to them, the files of a directory overriding those of the
+// same name in the previous ones
 func availableIntegrationConfigs(ddconfdPath string) []string {
-	integrationConfigFiles := integrationConfigsFromDirectory(ddconfdPath, ".")
-	dirs, _ := ioutil.ReadDir(ddconfdPath)
+	dirs := confdDirs(ddconfdPath)
+	filesByDir := make([][]string, len(dirs))
+	for i, dir := range dirs {
+		filesByDir[i] = confdDirConfigs(dir)
+	}
+	return mergeConfdFiles(dirs, filesByDir)
+}
+
+// confdDirConfigs lists the yaml and json files in ddconfdDir and its directories,
+// those of the <integration>.d directories of the datadog agent being listed as
+// the datadog agent does
+func confdDirConfigs(ddconfdDir string) []string {
+	integrationConfigFiles := integrationConfigsFromDirectory(ddconfdDir, ".")
+	dirs, _ := ioutil.ReadDir(ddconfdDir)
 	for _, d := range dirs {
-		if d.IsDir() {
-			integrationConfigFiles = append(
-				integrationConfigFiles,
-				integrationConfigsFromDirectory(filepath.Join(ddconfdPath, d.Name()), d.Name())...,
-			)
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(ddconfdDir, d.Name())
+		if strings.HasSuffix(d.Name(), integrationDirSuffix) {
+			integrationConfigFiles = append(integrationConfigFiles, integrationDirConfigs(dir, d.Name())...)
+		} else {
+			integrationConfigFiles = append(integrationConfigFiles, integrationConfigsFromDirectory(dir, d.Name())...)
+		}
+	}
+	return integrationConfigFiles
+}
+
+// integrationDirConfigs returns the integration config files of an <integration>.d
+// directory of the datadog agent: conf.yaml.default is used when there's no
+// conf.yaml, and the autodiscovery templates of auto_conf.yaml are left to the
+// datadog agent, which resolves them
+func integrationDirConfigs(dir string, prefix string) []string {
+	var integrationConfigFiles []string
+	hasConf := false
+	for _, file := range integrationConfigsFromDirectory(dir, prefix) {
+		name := filepath.Base(file)
+		if strings.TrimSuffix(name, filepath.Ext(name)) == autoConfName {
+			continue
+		}
+		if name == defaultConfName {
+			hasConf = true
+		}
+		integrationConfigFiles = append(integrationConfigFiles, file)
+	}
+	if !hasConf {
+		if _, err := os.Stat(filepath.Join(dir, defaultConfName+defaultConfSuffix)); err == nil {
+			integrationConfigFiles = append(integrationConfigFiles, filepath.Join(prefix, defaultConfName+defaultConfSuffix))
 		}
 	}
 	return integrationConfigFiles
 }
 
-// integrationConfigsFromDirectory returns a list of yaml files in a directory
+// integrationConfigsFromDirectory returns a list of yaml and json files in a directory
 func integrationConfigsFromDirectory(dir string, prefix string) []string {
 	var integrationConfigFiles []string
 	files, _ := ioutil.ReadDir(dir)
 	for _, f := range files {
 		if !f.IsDir() {
-			if filepath.Ext(f.Name()) == INTEGRATION_CONFIG_EXTENTION {
+			if isIntegrationConfig(f.Name()) {
 				integrationConfigFiles = append(integrationConfigFiles, filepath.Join(prefix, f.Name()))
 			}
 		}
@@ -153,77 +537,337 @@ func validateSource(config IntegrationConfigLogSource) error {
 	case FILE_TYPE,
 		DOCKER_TYPE,
 		TCP_TYPE,
-		UDP_TYPE:
+		UDP_TYPE,
+		UNIX_TYPE,
+		AGENT_TYPE,
+		MQTT_TYPE,
+		AMQP_TYPE,
+		KAFKA_TYPE,
+		SNMP_TYPE,
+		FLOW_TYPE,
+		COMMAND_TYPE:
 	default:
-		return fmt.Errorf("A source must have a valid type (got %s)", config.Type)
+		return newSourceError("a source must have a valid type (got %s)", config.Type)
 	}
 
 	if config.Type == FILE_TYPE && config.Path == "" {
-		return fmt.Errorf("A file source must have a path")
+		return newSourceError("a file source must have a path")
+	}
+
+	if config.Type == UNIX_TYPE && config.Path == "" {
+		return newSourceError("a unix source must have the path of its socket")
+	}
+
+	if config.Type == COMMAND_TYPE && config.Command == "" {
+		return newSourceError("a command source must have a command")
+	}
+
+	if (config.Command != "" || len(config.Args) > 0) && config.Type != COMMAND_TYPE {
+		return newSourceError("command and args are only supported by command sources")
+	}
+
+	if config.Type == FILE_TYPE && IsPathPattern(config.Path) {
+		if err := validatePathPattern(config.Path); err != nil {
+			return newSourceError("invalid path pattern %s: %v", config.Path, err)
+		}
+	}
+
+	if config.Type == TCP_TYPE && config.Port == 0 && config.PortRange == "" {
+		return newSourceError("a tcp source must have a port or a port_range")
+	}
+
+	if config.Type == UDP_TYPE && config.Port == 0 && config.PortRange == "" {
+		return newSourceError("a udp source must have a port or a port_range")
+	}
+
+	if config.Type == AGENT_TYPE && config.Port == 0 {
+		return newSourceError("an agent source must have a port")
+	}
+
+	if config.Type == FLOW_TYPE && config.Port == 0 {
+		return newSourceError("a flow source must have a port")
+	}
+
+	if config.PortRange != "" {
+		if config.Type != TCP_TYPE && config.Type != UDP_TYPE {
+			return newSourceError("port_range is only supported by network sources")
+		}
+		if config.Port != 0 {
+			return newSourceError("port and port_range can't be both set")
+		}
+		if _, _, err := ParsePortRange(config.PortRange); err != nil {
+			return newSourceError("%v", err)
+		}
+	}
+
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return newSourceError("tls_cert and tls_key must be both set")
+	}
+
+	if config.TLSCert != "" && config.Type != TCP_TYPE && config.Type != AGENT_TYPE && !isBrokerType(config.Type) {
+		return newSourceError("tls_cert and tls_key are only supported by tcp, agent, mqtt, amqp and kafka sources")
+	}
+
+	if config.Type == MQTT_TYPE && (config.Broker == "" || len(config.Topics) == 0) {
+		return newSourceError("an mqtt source must have a broker and topics")
+	}
+
+	if config.Type == AMQP_TYPE && (config.Broker == "" || config.Queue == "") {
+		return newSourceError("an amqp source must have a broker and a queue")
+	}
+
+	if config.Type == KAFKA_TYPE && (len(config.Brokers) == 0 || len(config.Topics) == 0 || config.GroupID == "") {
+		return newSourceError("a kafka source must have brokers, topics and a group_id")
+	}
+
+	switch config.StartPosition {
+	case "", StartPositionBeginning, StartPositionEnd:
+	default:
+		return newSourceError("start_position must be %s or %s (got %s)", StartPositionBeginning, StartPositionEnd, config.StartPosition)
+	}
+	if config.StartPosition != "" && config.Type != FILE_TYPE {
+		return newSourceError("start_position is only supported by file sources")
+	}
+
+	if config.Format != "" && config.Format != FormatCRI {
+		return newSourceError("format must be %s (got %s)", FormatCRI, config.Format)
+	}
+	if config.Format != "" && config.Type != FILE_TYPE {
+		return newSourceError("format is only supported by file sources")
+	}
+
+	switch config.OffsetReset {
+	case "", OffsetResetEarliest, OffsetResetLatest:
+	default:
+		return newSourceError("offset_reset must be %s or %s (got %s)", OffsetResetEarliest, OffsetResetLatest, config.OffsetReset)
+	}
+
+	if isBrokerType(config.Type) && config.TLSCert != "" && !config.UseTLS {
+		return newSourceError("tls_cert and tls_key require use_tls")
 	}
 
-	if config.Type == TCP_TYPE && config.Port == 0 {
-		return fmt.Errorf("A tcp source must have a port")
+	if (config.TLSCA != "" || config.UseTLS) && !isBrokerType(config.Type) {
+		return newSourceError("tls_ca and use_tls are only supported by mqtt, amqp and kafka sources")
 	}
 
-	if config.Type == UDP_TYPE && config.Port == 0 {
-		return fmt.Errorf("A udp source must have a port")
+	if (config.Community != "" || config.MIBFile != "") && config.Type != SNMP_TYPE {
+		return newSourceError("community and mib_file are only supported by snmp_traps sources")
+	}
+
+	if config.TopicTags != "" && config.Type != MQTT_TYPE {
+		return newSourceError("topic_tags is only supported by mqtt sources")
+	}
+
+	if config.PrefetchCount < 0 || config.PrefetchCount > math.MaxUint16 {
+		return newSourceError("prefetch_count must be between 0 and %d", math.MaxUint16)
+	}
+
+	if config.TopicTags != "" {
+		if _, err := CompilePathTags(config.TopicTags); err != nil {
+			return newSourceError("invalid topic_tags: %v", err)
+		}
+	}
+
+	if config.ServiceLabel != "" && config.Type != DOCKER_TYPE {
+		return newSourceError("service_label is only supported by docker sources")
+	}
+
+	if config.ServicePattern != "" {
+		re, err := regexp.Compile(config.ServicePattern)
+		if err != nil {
+			return newSourceError("invalid service_pattern: %v", err)
+		}
+		if re.NumSubexp() == 0 {
+			return newSourceError("service_pattern must have a capture group")
+		}
+	}
+
+	if config.PathTags != "" {
+		if config.Type != FILE_TYPE {
+			return newSourceError("path_tags is only supported by file sources")
+		}
+		if _, err := CompilePathTags(config.PathTags); err != nil {
+			return newSourceError("invalid path_tags: %v", err)
+		}
+	}
+
+	if len(config.ExcludePaths) > 0 {
+		if config.Type != FILE_TYPE || !IsPathPattern(config.Path) {
+			return newSourceError("exclude_paths is only supported by file sources with a path pattern")
+		}
+		for _, pattern := range config.ExcludePaths {
+			if err := validatePathPattern(pattern); err != nil {
+				return newSourceError("invalid exclude_paths pattern %s: %v", pattern, err)
+			}
+		}
+	}
+
+	if config.Framing != "" && config.Framing != FRAMING_NEWLINE {
+		if !IsLengthPrefixed(config.Framing) {
+			return newSourceError("framing must be %s, %s, %s, %s or %s (got %s)", FRAMING_NEWLINE, FRAMING_UINT16_BE, FRAMING_UINT16_LE, FRAMING_UINT32_BE, FRAMING_UINT32_LE, config.Framing)
+		}
+		if config.Type != TCP_TYPE {
+			return newSourceError("length-prefixed framings are only supported by tcp sources")
+		}
+	}
+
+	if config.Encoding != "" {
+		if !IsEncoding(config.Encoding) {
+			return newSourceError("encoding must be %s, %s, %s, %s or %s (got %s)", ENCODING_UTF8, ENCODING_UTF16_LE, ENCODING_UTF16_BE, ENCODING_LATIN1, ENCODING_SHIFT_JIS, config.Encoding)
+		}
+		if config.Type != FILE_TYPE && config.Type != TCP_TYPE && config.Type != UDP_TYPE {
+			return newSourceError("encoding is only supported by file, tcp and udp sources")
+		}
+	}
+
+	if config.RawForward {
+		if len(config.ProcessingRules) > 0 {
+			return newSourceError("log_processing_rules can't be set with raw_forward")
+		}
+		if config.Encoding != "" && config.Encoding != ENCODING_UTF8 {
+			return newSourceError("encoding can't be set with raw_forward")
+		}
+		if config.ServicePattern != "" || config.ServiceAttribute != "" {
+			return newSourceError("service_pattern and service_attribute can't be set with raw_forward")
+		}
+		if len(config.Attributes) > 0 {
+			return newSourceError("attributes can't be set with raw_forward")
+		}
+	}
+
+	if config.SamplingRate != nil && (*config.SamplingRate < 0 || *config.SamplingRate > 1) {
+		return newSourceError("sampling_rate must be between 0 and 1 (got %v)", *config.SamplingRate)
+	}
+
+	if err := validateAttributes(config.Attributes); err != nil {
+		return err
+	}
+
+	if _, ok := ParsePriority(config.Priority); !ok {
+		return newSourceError("priority must be %s, %s or %s (got %s)", PRIORITY_HIGH, PRIORITY_NORMAL, PRIORITY_LOW, config.Priority)
+	}
+
+	if config.TimestampSource != "" && config.TimestampSource != TIMESTAMP_PARSED && config.TimestampSource != TIMESTAMP_RECEIVED {
+		return newSourceError("timestamp_source must be %s or %s (got %s)", TIMESTAMP_PARSED, TIMESTAMP_RECEIVED, config.TimestampSource)
+	}
+
+	if config.SequenceNumbers && config.Type != FILE_TYPE && config.Type != DOCKER_TYPE {
+		return newSourceError("sequence_numbers is only supported by file and docker sources")
+	}
+
+	if config.ReorderWindow < 0 {
+		return newSourceError("reorder_window can't be negative")
+	}
+
+	if config.ReorderWindow > 0 && config.Type != TCP_TYPE && config.Type != UDP_TYPE {
+		return newSourceError("reorder_window is only supported by network sources")
 	}
 
 	return nil
 }
 
+// compileRulePattern returns the regular expression expr built from the pattern
+// of rule, or an error naming the rule and its pattern
+func compileRulePattern(rule LogsProcessingRule, expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, newRuleError(rule.Name, "invalid pattern `%s`: %v", rule.Pattern, err)
+	}
+	return re, nil
+}
+
 // validateProcessingRules checks the rules and raises errors if one is misconfigured
 func validateProcessingRules(rules []LogsProcessingRule) ([]LogsProcessingRule, error) {
 	for i, rule := range rules {
 		if rule.Name == "" {
-			return nil, fmt.Errorf("LogsAgent misconfigured: all log processing rules need a name")
+			return nil, newSourceError("all log processing rules need a name")
 		}
+		if rule.Field != "" && rule.Type != EXCLUDE_AT_MATCH && rule.Type != INCLUDE_AT_MATCH {
+			return nil, newRuleError(rule.Name, "field is only supported by %s and %s rules", EXCLUDE_AT_MATCH, INCLUDE_AT_MATCH)
+		}
+		var err error
 		switch rule.Type {
-		case EXCLUDE_AT_MATCH:
-			rules[i].Reg = regexp.MustCompile(rule.Pattern)
+		case EXCLUDE_AT_MATCH, INCLUDE_AT_MATCH:
+			rules[i].Reg, err = compileRulePattern(rule, rule.Pattern)
 		case MASK_SEQUENCES:
-			rules[i].Reg = regexp.MustCompile(rule.Pattern)
+			rules[i].Reg, err = compileRulePattern(rule, rule.Pattern)
 			rules[i].ReplacePlaceholderBytes = []byte(rule.ReplacePlaceholder)
 		case MULTILINE:
-			rules[i].Reg = regexp.MustCompile("^" + rule.Pattern)
+			rules[i].Reg, err = compileRulePattern(rule, "^"+rule.Pattern)
+		case REMAP_SEVERITY:
+			if rule.Pattern == "" && rule.From == "" {
+				return nil, newRuleError(rule.Name, "pattern or from must be set")
+			}
+			level, ok := ParseSeverity(rule.Severity)
+			if !ok {
+				return nil, newRuleError(rule.Name, "invalid severity %s", rule.Severity)
+			}
+			rules[i].SeverityLevel = level
+			rules[i].FromLevel = NoSeverity
+			if rule.From != "" {
+				from, ok := ParseSeverity(rule.From)
+				if !ok {
+					return nil, newRuleError(rule.Name, "invalid from severity %s", rule.From)
+				}
+				rules[i].FromLevel = from
+			}
+			if rule.Pattern != "" {
+				rules[i].Reg, err = compileRulePattern(rule, rule.Pattern)
+			}
+		case MULTILINE_CONTINUATION:
+			// the pattern is a literal continuation prefix
+			if rule.Pattern == "" {
+				rules[i].Reg = regexp.MustCompile(defaultContinuationPattern)
+			} else {
+				rules[i].Reg = regexp.MustCompile("^" + regexp.QuoteMeta(rule.Pattern))
+			}
 		default:
 			if rule.Type == "" {
-				return nil, fmt.Errorf("LogsAgent misconfigured: type must be set for log processing rule `%s`", rule.Name)
+				return nil, newRuleError(rule.Name, "type must be set")
 			} else {
-				return nil, fmt.Errorf("LogsAgent misconfigured: type %s is unsupported for log processing rule `%s`", rule.Type, rule.Name)
+				return nil, newRuleError(rule.Name, "type %s is unsupported", rule.Type)
 			}
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
 	return rules, nil
 }
 
-// Given a list of tags, BuildTagsPayload generates the bytes array that will be inserted
-// into messages
-func BuildTagsPayload(configTags, source, sourceCategory string) []byte {
-
-	tagsPayload := []byte{}
-	if source != "" {
-		tagsPayload = append(tagsPayload, []byte("[dd ddsource=\"")...)
-		tagsPayload = append(tagsPayload, []byte(source)...)
-		tagsPayload = append(tagsPayload, []byte("\"]")...)
+// locateError adds the file and line context to a validation error
+// of the log source at sourceIndex
+func locateError(err error, file string, content []byte, sourceIndex int) error {
+	if cfgErr, ok := err.(*ConfigError); ok {
+		return cfgErr.locate(file, content, sourceIndex)
 	}
+	return err
+}
 
-	if sourceCategory != "" {
-		tagsPayload = append(tagsPayload, []byte("[dd ddsourcecategory=\"")...)
-		tagsPayload = append(tagsPayload, []byte(sourceCategory)...)
-		tagsPayload = append(tagsPayload, []byte("\"]")...)
-	}
+// BuildTagsPayload generates the bytes array that will be inserted into the messages
+// of source, in the format supported by the intake, from tags, which are normalized,
+// and from the source, source category and attributes of source
+func BuildTagsPayload(source *IntegrationConfigLogSource, tags string) []byte {
+	formatter := GetTagsPayloadFormatter()
+	payload := formatter.Format(normalizeTagString(tags), source.Source, source.SourceCategory)
+	return formatter.AppendAttributes(payload, source.Attributes)
+}
 
-	if configTags != "" {
-		tagsPayload = append(tagsPayload, []byte("[dd ddtags=\"")...)
-		tagsPayload = append(tagsPayload, []byte(configTags)...)
-		tagsPayload = append(tagsPayload, []byte("\"]")...)
-	}
+// isBrokerType returns true for the sources consuming the messages of a broker
+func isBrokerType(sourceType string) bool {
+	return sourceType == MQTT_TYPE || sourceType == AMQP_TYPE || sourceType == KAFKA_TYPE
+}
 
-	if len(tagsPayload) == 0 {
-		return []byte{'-'}
+// isIntegrationConfig returns true when name has the extension of an integration config file
+func isIntegrationConfig(name string) bool {
+	if name == namespaceConfName {
+		return false
 	}
-
-	return tagsPayload
+	ext := filepath.Ext(name)
+	for _, integrationConfigExtension := range integrationConfigExtensions {
+		if ext == integrationConfigExtension {
+			return true
+		}
+	}
+	return false
 }
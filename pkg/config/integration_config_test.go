@@ -8,16 +8,73 @@ package config
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestLoadLogsSourcesKeepsCurrentSources(t *testing.T) {
+	current := []*IntegrationConfigLogSource{{Type: FILE_TYPE, Path: "/var/log/current.log"}}
+	SetLogsSources(current)
+	defer SetLogsSources(nil)
+
+	sources, _, err := LoadLogsSources(filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(sources))
+	assert.Equal(t, current, GetLogsSources())
+
+	sources, configErrors, err := LoadLogsSources(filepath.Join(testsPath, "misconfigured_2", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(sources))
+	assert.Equal(t, 1, len(configErrors))
+}
+
 func TestAvailableIntegrationConfigs(t *testing.T) {
 	ddconfdPath := filepath.Join(testsPath, "complete", "conf.d")
 	assert.Equal(t, []string{"integration.yaml", "integration2.yaml", "integration.d/integration3.yaml"}, availableIntegrationConfigs(ddconfdPath))
 }
 
+func TestAvailableIntegrationConfigsOfIntegrationDirectories(t *testing.T) {
+	ddconfdPath := filepath.Join(testsPath, "ddagent", "conf.d")
+	assert.Equal(t, []string{"apache.d/conf.yaml", "nginx.d/conf.yaml", "redis.d/conf.yaml.default"}, availableIntegrationConfigs(ddconfdPath))
+
+	sources, _, err := loadLogsSources(viper.New(), ddconfdPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(sources))
+	assert.Equal(t, "/var/log/apache2/access.log", sources[0].Path)
+	assert.Equal(t, "/var/log/nginx/access.log", sources[1].Path)
+	assert.Equal(t, "/var/log/redis/redis-server.log", sources[2].Path)
+}
+
+func TestIsIntegrationConfig(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"nginx.yaml":         true,
+		"nginx.yml":          true,
+		"nginx.json":         true,
+		"nginx.yaml.example": false,
+		"nginx.yaml.bak":     false,
+		"nginx.toml":         false,
+		"nginx":              false,
+	} {
+		assert.Equal(t, expected, isIntegrationConfig(name), name)
+	}
+}
+
+func TestLoadLogsSourcesOfEachFormat(t *testing.T) {
+	sources, _, err := loadLogsSources(viper.New(), filepath.Join(testsPath, "formats", "conf.d"))
+	assert.Nil(t, err)
+	services := make(map[string]string)
+	for _, source := range sources {
+		services[source.Service] = source.Path
+	}
+	assert.Equal(t, map[string]string{
+		"nginx":    "/var/log/nginx/access.log",
+		"postgres": "/var/log/postgresql/postgresql.log",
+		"redis":    "/var/log/redis/redis.log",
+	}, services)
+}
+
 func TestBuildLogsAgentIntegrationsConfigs(t *testing.T) {
 	ddconfdPath := filepath.Join(testsPath, "complete", "conf.d")
 	var testConfig = viper.New()
@@ -40,6 +97,7 @@ func TestBuildLogsAgentIntegrationsConfigs(t *testing.T) {
 	assert.Equal(t, "", rules[1].Service)
 	assert.Equal(t, "", rules[1].Source)
 	assert.Equal(t, 0, len(rules[1].Tags))
+	assert.Equal(t, 2*time.Second, rules[1].ReorderWindow)
 
 	assert.Equal(t, "docker", rules[2].Type)
 	assert.Equal(t, "test", rules[2].Image)
@@ -63,8 +121,236 @@ func TestBuildLogsAgentIntegrationsConfigs(t *testing.T) {
 	assert.False(t, re.MatchString("a123"))
 }
 
+func TestValidateContinuationRules(t *testing.T) {
+	rules, err := validateProcessingRules([]LogsProcessingRule{
+		{Type: MULTILINE_CONTINUATION, Name: "indented"},
+		{Type: MULTILINE_CONTINUATION, Name: "prefixed", Pattern: "... "},
+	})
+	assert.Nil(t, err)
+
+	indented := rules[0].Reg
+	assert.True(t, indented.MatchString("  File \"main.py\", line 1"))
+	assert.True(t, indented.MatchString("\tat com.example.Main.main(Main.java:5)"))
+	assert.False(t, indented.MatchString("Traceback (most recent call last):"))
+
+	prefixed := rules[1].Reg
+	assert.True(t, prefixed.MatchString("... 3 more"))
+	assert.False(t, prefixed.MatchString("...3 more"))
+	assert.False(t, prefixed.MatchString("foo... "))
+}
+
+func TestValidateFieldRules(t *testing.T) {
+	rules, err := validateProcessingRules([]LogsProcessingRule{
+		{Type: EXCLUDE_AT_MATCH, Name: "success", Field: "http.status_code", Pattern: "^2..$"},
+		{Type: INCLUDE_AT_MATCH, Name: "errors", Field: "level", Pattern: "error"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, rules[0].Reg.MatchString("200"))
+	assert.True(t, rules[1].Reg.MatchString("error"))
+
+	_, err = validateProcessingRules([]LogsProcessingRule{{Type: MASK_SEQUENCES, Name: "mask", Field: "user", Pattern: "a"}})
+	assert.NotNil(t, err)
+}
+
+func TestValidateCommandSource(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: COMMAND_TYPE, Command: "/usr/local/bin/exporter", Args: []string{"--verbose"}}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: COMMAND_TYPE}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", Command: "/usr/local/bin/exporter"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", Args: []string{"--verbose"}}))
+}
+
+func TestValidateDynamicService(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", ServicePattern: `^\[(\w+)\]`}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", ServicePattern: `^\[\w+\]`}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", ServicePattern: `^\[(\w+`}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: DOCKER_TYPE, ServiceLabel: "com.example.service"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", ServiceLabel: "com.example.service"}))
+}
+
+func TestValidatePriority(t *testing.T) {
+	for _, priority := range []string{"", "high", "normal", "low"} {
+		assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", Priority: priority}))
+	}
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", Priority: "urgent"}))
+
+	assert.Equal(t, PriorityNormal, (&IntegrationConfigLogSource{}).PriorityClass())
+	assert.Equal(t, PriorityHigh, (&IntegrationConfigLogSource{Priority: "high"}).PriorityClass())
+	assert.Equal(t, PriorityLow, (&IntegrationConfigLogSource{Priority: "low"}).PriorityClass())
+}
+
+func TestValidateFraming(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, Framing: FRAMING_UINT16_BE}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, Framing: FRAMING_NEWLINE}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: UDP_TYPE, Port: 10514, Framing: FRAMING_NEWLINE}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, Framing: "uint8"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: UDP_TYPE, Port: 10514, Framing: FRAMING_UINT32_LE}))
+}
+
+func TestValidateSequenceNumbers(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", SequenceNumbers: true}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: DOCKER_TYPE, SequenceNumbers: true}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, SequenceNumbers: true}))
+}
+
+func TestValidateMQTT(t *testing.T) {
+	source := IntegrationConfigLogSource{Type: MQTT_TYPE, Broker: "localhost:8883", Topics: []string{"devices/+/logs"}, TopicTags: "devices/{device}/logs"}
+	assert.Nil(t, validateSource(source))
+	source.TLSCert, source.TLSKey = "cert.pem", "key.pem"
+	assert.NotNil(t, validateSource(source))
+	source.UseTLS = true
+	assert.Nil(t, validateSource(source))
+
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: MQTT_TYPE, Broker: "localhost:1883"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, UseTLS: true}))
+}
+
+func TestValidateKafka(t *testing.T) {
+	source := IntegrationConfigLogSource{Type: KAFKA_TYPE, Brokers: []string{"localhost:9092"}, Topics: []string{"logs"}, GroupID: "agents"}
+	assert.Nil(t, validateSource(source))
+	source.OffsetReset = OffsetResetEarliest
+	assert.Nil(t, validateSource(source))
+	source.OffsetReset = "beginning"
+	assert.NotNil(t, validateSource(source))
+
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: KAFKA_TYPE, Brokers: []string{"localhost:9092"}, Topics: []string{"logs"}}))
+}
+
+func TestValidateStartPosition(t *testing.T) {
+	source := IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/app.log", StartPosition: StartPositionBeginning}
+	assert.Nil(t, validateSource(source))
+	source.StartPosition = "earliest"
+	assert.NotNil(t, validateSource(source))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, StartPosition: StartPositionEnd}))
+}
+
+func TestValidateSNMP(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: SNMP_TYPE}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: SNMP_TYPE, Port: 1162, Community: "public", MIBFile: "mibs.txt"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: UDP_TYPE, Port: 1162, Community: "public"}))
+}
+
+func TestValidateFlow(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FLOW_TYPE, Port: 2055}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FLOW_TYPE}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FLOW_TYPE, PortRange: "2055-2056"}))
+}
+
+func TestValidateEncoding(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/iis.log", Encoding: ENCODING_UTF16_LE}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: UDP_TYPE, Port: 10514, Encoding: ENCODING_SHIFT_JIS}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/iis.log", Encoding: "utf-32"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: DOCKER_TYPE, Encoding: ENCODING_LATIN1}))
+}
+
+func TestValidateRawForward(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, RawForward: true}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/app.log", RawForward: true, Encoding: ENCODING_UTF8}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, RawForward: true, ProcessingRules: []LogsProcessingRule{{Name: "exclude", Type: EXCLUDE_AT_MATCH, Pattern: "debug"}}}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, RawForward: true, Encoding: ENCODING_LATIN1}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, RawForward: true, ServiceAttribute: "app"}))
+}
+
+func TestValidateProcessingRulesWithInvalidPattern(t *testing.T) {
+	_, err := validateProcessingRules([]LogsProcessingRule{{Name: "bad_regex", Type: MASK_SEQUENCES, Pattern: "(unclosed"}})
+	cfgErr, ok := err.(*ConfigError)
+	assert.True(t, ok)
+	assert.Equal(t, "bad_regex", cfgErr.RuleName)
+	assert.Contains(t, cfgErr.Reason, "invalid pattern `(unclosed`")
+
+	rules, errs := skipInvalidPatterns([]LogsProcessingRule{
+		{Name: "bad_regex", Type: EXCLUDE_AT_MATCH, Pattern: "(unclosed"},
+		{Name: "continuation", Type: MULTILINE_CONTINUATION, Pattern: "(literal"},
+		{Name: "mask", Type: MASK_SEQUENCES, Pattern: "[0-9]+"},
+	})
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, "continuation", rules[0].Name)
+	assert.Equal(t, "mask", rules[1].Name)
+}
+
+func TestValidateAttributes(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, Attributes: map[string]string{"owner": "web-team", "cost.center": "42"}}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, Attributes: map[string]string{"ddtags": "env:prod"}}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, Attributes: map[string]string{"cost center": "42"}}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, Attributes: map[string]string{"owner": "web-team"}, RawForward: true}))
+}
+
+func TestValidateSamplingRate(t *testing.T) {
+	rate := 0.1
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, SamplingRate: &rate}))
+	rate = 0
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, SamplingRate: &rate}))
+	rate = 1.5
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, SamplingRate: &rate}))
+}
+
+func TestValidateTimestampSource(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, TimestampSource: TIMESTAMP_PARSED}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, TimestampSource: TIMESTAMP_RECEIVED}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, TimestampSource: "now"}))
+}
+
+func TestExcludedPaths(t *testing.T) {
+	defer LogsAgent.Set(ExcludedPathsKey, []string{})
+	LogsAgent.Set(ExcludedPathsKey, []string{"/var/log/secure", "*.key"})
+	assert.True(t, IsExcludedPath("/var/log/secure"))
+	assert.True(t, IsExcludedPath("/etc/ssl/server.key"))
+	assert.False(t, IsExcludedPath("/var/log/messages"))
+
+	source := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/*", ExcludePaths: []string{"*.gz"}}
+	assert.True(t, source.ExcludesPath("/var/log/secure"))
+	assert.True(t, source.ExcludesPath("/var/log/messages.gz"))
+	assert.False(t, source.ExcludesPath("/var/log/messages"))
+
+	config := viper.New()
+	config.Set(ExcludedPathsKey, []string{"/var/log/[a-"})
+	assert.NotNil(t, validateExcludedPaths(config))
+}
+
+func TestValidatePathPattern(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/myapp/*.log"}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/**/app-[0-9].log"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/app**/*.log"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/[a-.log"}))
+}
+
+func TestValidateExcludePaths(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/myapp/*", ExcludePaths: []string{"*.gz", "/var/log/myapp/debug-*.log"}}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/myapp/app.log", ExcludePaths: []string{"*.gz"}}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/myapp/*", ExcludePaths: []string{"[a-.gz"}}))
+}
+
+func TestSourceExcludesPath(t *testing.T) {
+	source := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/**/*", ExcludePaths: []string{"*.gz", "debug-*.log", "/var/log/**/archive/*"}}
+	assert.True(t, source.ExcludesPath("/var/log/app/app.log.1.gz"))
+	assert.True(t, source.ExcludesPath("/var/log/app/debug-1.log"))
+	assert.True(t, source.ExcludesPath("/var/log/app/archive/app.log"))
+	assert.False(t, source.ExcludesPath("/var/log/app/app.log"))
+	assert.False(t, source.ExcludesPath("/var/log/archive.log"))
+}
+
+func TestMatchPathPattern(t *testing.T) {
+	assert.True(t, MatchPathPattern("/var/**", "/var/log/app.log"))
+	assert.True(t, MatchPathPattern("/var/log/**/app.log", "/var/log/app.log"))
+	assert.True(t, MatchPathPattern("/var/log/**/*.log", "/var/log/a/b/c.log"))
+	assert.False(t, MatchPathPattern("/var/log/**/*.log", "/var/lib/c.log"))
+	assert.False(t, MatchPathPattern("/var/log/*.log", "/var/log/a/c.log"))
+}
+
+func TestSourceForPath(t *testing.T) {
+	source := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/*.log", Service: "app"}
+	source.ID = BuildSourceID(source)
+	assert.True(t, source.IsPattern())
+	matched := source.ForPath("/var/log/a.log")
+	assert.False(t, matched.IsPattern())
+	assert.Equal(t, "app", matched.Service)
+	assert.Equal(t, BuildSourceID(&IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log"}), matched.ID)
+	assert.Equal(t, "/var/log/*.log", source.Path)
+}
+
 func TestBuildTagsPayload(t *testing.T) {
-	assert.Equal(t, "-", string(BuildTagsPayload("", "", "")))
-	assert.Equal(t, "[dd ddtags=\"hello:world\"]", string(BuildTagsPayload("hello:world", "", "")))
-	assert.Equal(t, "[dd ddsource=\"nginx\"][dd ddsourcecategory=\"http_access\"][dd ddtags=\"hello:world, hi\"]", string(BuildTagsPayload("hello:world, hi", "nginx", "http_access")))
+	assert.Equal(t, "-", string(BuildTagsPayload(&IntegrationConfigLogSource{}, "")))
+	assert.Equal(t, "[dd ddtags=\"hello:world\"]", string(BuildTagsPayload(&IntegrationConfigLogSource{}, "hello:world")))
+	source := &IntegrationConfigLogSource{Source: "nginx", SourceCategory: "http_access", Attributes: map[string]string{"owner": "web"}}
+	assert.Equal(t, "[dd ddsource=\"nginx\"][dd ddsourcecategory=\"http_access\"][dd ddtags=\"hello:world,hi\"][dd owner=\"web\"]", string(BuildTagsPayload(source, "hello:world, hi")))
 }
@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// strictInterpolationKey makes the variables referenced by the config and not set
+// in the environment errors, instead of being replaced by empty strings
+const strictInterpolationKey = "log_strict_env_interpolation"
+
+// envReference matches the ${VAR} references to environment variables,
+// $${VAR} being the escaped form of the literal ${VAR}
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolateEnv replaces the ${VAR} references of the string settings of target
+// by the values of the environment variables, the unset variables being errors
+// when log_strict_env_interpolation is set in config
+func interpolateEnv(config, target *viper.Viper) error {
+	strict := config.GetBool(strictInterpolationKey)
+	for _, key := range target.AllKeys() {
+		value := target.Get(key)
+		unset := map[string]bool{}
+		interpolated, changed := interpolateValue(value, unset)
+		if strict && len(unset) > 0 {
+			return fmt.Errorf("%s references unset environment variables: %s", key, joinNames(unset))
+		}
+		if changed {
+			target.Set(key, interpolated)
+		}
+	}
+	return nil
+}
+
+// interpolateValue returns a copy of value whose references are interpolated, and
+// true when it has some, adding the variables not set to unset
+func interpolateValue(value interface{}, unset map[string]bool) (interface{}, bool) {
+	changed := false
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "${") {
+			return v, false
+		}
+		return envReference.ReplaceAllStringFunc(v, func(reference string) string {
+			if strings.HasPrefix(reference, "$$") {
+				return reference[1:]
+			}
+			name := envReference.FindStringSubmatch(reference)[1]
+			variable, ok := os.LookupEnv(name)
+			if !ok {
+				unset[name] = true
+			}
+			return variable
+		}), true
+	case []interface{}:
+		interpolated := make([]interface{}, len(v))
+		for i, item := range v {
+			var itemChanged bool
+			interpolated[i], itemChanged = interpolateValue(item, unset)
+			changed = changed || itemChanged
+		}
+		return interpolated, changed
+	case []string:
+		interpolated := make([]string, len(v))
+		for i, item := range v {
+			s, itemChanged := interpolateValue(item, unset)
+			interpolated[i] = s.(string)
+			changed = changed || itemChanged
+		}
+		return interpolated, changed
+	case map[string]interface{}:
+		interpolated := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var itemChanged bool
+			interpolated[key], itemChanged = interpolateValue(item, unset)
+			changed = changed || itemChanged
+		}
+		return interpolated, changed
+	case map[interface{}]interface{}:
+		interpolated := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			var itemChanged bool
+			interpolated[key], itemChanged = interpolateValue(item, unset)
+			changed = changed || itemChanged
+		}
+		return interpolated, changed
+	default:
+		return value, false
+	}
+}
+
+// joinNames returns the sorted names of set
+func joinNames(set map[string]bool) string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,62 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolateValue(t *testing.T) {
+	os.Setenv("DD_TEST_ENV", "prod")
+	defer os.Unsetenv("DD_TEST_ENV")
+
+	unset := map[string]bool{}
+	value, changed := interpolateValue("env:${DD_TEST_ENV}", unset)
+	assert.True(t, changed)
+	assert.Equal(t, "env:prod", value)
+
+	value, changed = interpolateValue([]interface{}{"${DD_TEST_ENV}", 42, map[string]interface{}{"path": "/${DD_TEST_UNSET}/app.log"}}, unset)
+	assert.True(t, changed)
+	assert.Equal(t, []interface{}{"prod", 42, map[string]interface{}{"path": "//app.log"}}, value)
+	assert.Equal(t, map[string]bool{"DD_TEST_UNSET": true}, unset)
+
+	value, changed = interpolateValue("$${DD_TEST_ENV} and $HOME", unset)
+	assert.True(t, changed)
+	assert.Equal(t, "${DD_TEST_ENV} and $HOME", value)
+
+	_, changed = interpolateValue("/var/log/app.log", unset)
+	assert.False(t, changed)
+}
+
+func TestIntegrationConfigIsInterpolated(t *testing.T) {
+	os.Setenv("APP_LOG_DIR", "/var/log/app")
+	os.Setenv("APP_ENV", "staging")
+	defer os.Unsetenv("APP_LOG_DIR")
+	defer os.Unsetenv("APP_ENV")
+
+	config := viper.New()
+	sources, configErrors, err := loadLogsSources(config, filepath.Join(testsPath, "interpolation", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(configErrors))
+	assert.Equal(t, 1, len(sources))
+	assert.Equal(t, "/var/log/app/app.log", sources[0].Path)
+	assert.Equal(t, "app-staging", sources[0].Service)
+	assert.Equal(t, "env:staging,literal:${APP_ENV}", sources[0].Tags)
+
+	// unset variables are errors in strict mode, the file being skipped
+	os.Unsetenv("APP_ENV")
+	config.Set(strictInterpolationKey, true)
+	sources, configErrors, err = loadLogsSources(config, filepath.Join(testsPath, "interpolation", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(sources))
+	assert.Equal(t, 1, len(configErrors))
+	assert.Contains(t, configErrors[0].Error(), "unset environment variables: APP_ENV")
+}
@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// namespaceConfName holds the settings of a namespace, a directory of conf.d
+// owned by a team, such as conf.d/payments/_namespace.yaml
+const namespaceConfName = "_namespace.yaml"
+
+// maxBytesPerSecondKey caps the throughput of the sources of a namespace
+const maxBytesPerSecondKey = "max_bytes_per_second"
+
+// A Namespace is a directory of conf.d whose settings apply to all the sources
+// of its files, so that teams can own their directory on shared hosts
+type Namespace struct {
+	Name string
+	// MaxBytesPerSecond is the quota shared by the sources of the namespace,
+	// the messages over it being dropped, 0 for no quota
+	MaxBytesPerSecond int64
+	// defaults are the tags and the processing rules presets of the sources,
+	// merged as those of a template
+	defaults sourceTemplate
+}
+
+// namespaceOf returns the name of the namespace of an integration config file,
+// the directory of conf.d it's in, or an empty string for the files out of namespaces
+func namespaceOf(file string) string {
+	if file == LogsSourcesEnv || file == RemoteConfigFile {
+		return ""
+	}
+	dir := strings.Split(filepath.ToSlash(file), "/")[0]
+	if dir == file || strings.HasSuffix(dir, integrationDirSuffix) {
+		return ""
+	}
+	return dir
+}
+
+// readNamespaces returns the namespaces of the directories of the conf.d directories
+// of ddconfdPath having a namespace file, by name, and the errors of the invalid ones
+func readNamespaces(config *viper.Viper, ddconfdPath string) (map[string]*Namespace, map[string]error) {
+	namespaces := make(map[string]*Namespace)
+	errs := make(map[string]error)
+	for _, d := range namespaceDirs(ddconfdPath) {
+		file := filepath.Join(d.Name(), namespaceConfName)
+		if _, err := os.Stat(confdFile(ddconfdPath, file)); err != nil {
+			continue
+		}
+		namespace, err := readNamespace(config, ddconfdPath, file)
+		if err != nil {
+			errs[d.Name()] = err
+			continue
+		}
+		namespaces[d.Name()] = namespace
+	}
+	return namespaces, errs
+}
+
+// namespaceDirs returns the directories of the conf.d directories of ddconfdPath
+// that may be namespaces, once per name
+func namespaceDirs(ddconfdPath string) []os.FileInfo {
+	seen := make(map[string]bool)
+	namespaceDirs := []os.FileInfo{}
+	for _, confd := range confdDirs(ddconfdPath) {
+		dirs, _ := ioutil.ReadDir(confd)
+		for _, d := range dirs {
+			if !d.IsDir() || strings.HasSuffix(d.Name(), integrationDirSuffix) || seen[d.Name()] {
+				continue
+			}
+			seen[d.Name()] = true
+			namespaceDirs = append(namespaceDirs, d)
+		}
+	}
+	return namespaceDirs
+}
+
+// sortedNamespaceNames returns the names of the namespaces of errs, sorted
+func sortedNamespaceNames(errs map[string]error) []string {
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readNamespace reads and validates the namespace file of ddconfdPath
+func readNamespace(config *viper.Viper, ddconfdPath, file string) (*Namespace, error) {
+	viperCfg, err := readIntegrationConfig(config, ddconfdPath, file)
+	if err != nil {
+		return nil, err
+	}
+	namespace := &Namespace{Name: namespaceOf(file), defaults: sourceTemplate{}}
+	for key, value := range viperCfg.AllSettings() {
+		switch key {
+		case tagsKey:
+			namespace.defaults[key] = value
+		case rulesKey:
+			var rules []LogsProcessingRule
+			if err := viperCfg.UnmarshalKey(rulesKey, &rules); err != nil {
+				return nil, newFileError(file, err)
+			}
+			rules, err = validateProcessingRules(rules)
+			if err == nil {
+				err = validateRuleSelectors(rules)
+			}
+			if err != nil {
+				if cfgErr, ok := err.(*ConfigError); ok {
+					cfgErr.File = file
+				}
+				return nil, err
+			}
+			namespace.defaults[key] = value
+		case maxBytesPerSecondKey:
+			namespace.MaxBytesPerSecond, err = cast.ToInt64E(value)
+			if err != nil || namespace.MaxBytesPerSecond < 0 {
+				return nil, newFileError(file, fmt.Errorf("%s must be a positive number of bytes (got %v)", key, value))
+			}
+		default:
+			return nil, newFileError(file, fmt.Errorf("unknown namespace setting %s, the settings are %s, %s and %s", key, tagsKey, rulesKey, maxBytesPerSecondKey))
+		}
+	}
+	if err := normalizeTags(namespace.defaults); err != nil {
+		return nil, newFileError(file, err)
+	}
+	return namespace, nil
+}
+
+// apply returns the settings of a source of the namespace merged over its defaults
+func (n *Namespace) apply(settings map[string]interface{}) map[string]interface{} {
+	if n == nil {
+		return settings
+	}
+	return mergeSettings(n.defaults, settings)
+}
@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceOf(t *testing.T) {
+	assert.Equal(t, "", namespaceOf("shared.yaml"))
+	assert.Equal(t, "payments", namespaceOf(filepath.Join("payments", "api.yaml")))
+	assert.Equal(t, "", namespaceOf(filepath.Join("nginx.d", "conf.yaml")))
+	assert.Equal(t, "", namespaceOf(LogsSourcesEnv))
+}
+
+func TestSourcesInheritTheirNamespace(t *testing.T) {
+	ddconfdPath := filepath.Join(testsPath, "namespaces", "conf.d")
+	assert.Equal(t, []string{"shared.yaml", filepath.Join("broken", "app.yaml"), filepath.Join("payments", "api.yaml")}, availableIntegrationConfigs(ddconfdPath))
+
+	sources, configErrors, err := loadLogsSources(viper.New(), ddconfdPath)
+	assert.Nil(t, err)
+
+	// the sources of the invalid namespace are skipped
+	assert.Equal(t, 1, len(configErrors))
+	assert.Contains(t, configErrors[0].Error(), filepath.Join("broken", namespaceConfName)+": unknown namespace setting quota")
+	assert.Equal(t, 2, len(sources))
+
+	shared := sources[0]
+	assert.Equal(t, "", shared.Namespace)
+	assert.Equal(t, int64(0), shared.NamespaceMaxBytesPerSecond)
+
+	api := sources[1]
+	assert.Equal(t, "payments", api.Namespace)
+	assert.Equal(t, int64(1048576), api.NamespaceMaxBytesPerSecond)
+	assert.Equal(t, "team:payments,env:prod", api.Tags)
+	// the presets of the namespace apply before the rules of the source
+	assert.Equal(t, 2, len(api.ProcessingRules))
+	assert.Equal(t, "mask_credit_cards", api.ProcessingRules[0].Name)
+	assert.Equal(t, "exclude_debug", api.ProcessingRules[1].Name)
+}
@@ -0,0 +1,28 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// OpenFilesLimitKey caps the number of files tailed at once, 0 meaning no limit
+const OpenFilesLimitKey = "logs_config.open_files_limit"
+
+// OpenFilesLimit returns the maximum number of files tailed at once, 0 for no limit
+func OpenFilesLimit() int {
+	return LogsAgent.GetInt(OpenFilesLimitKey)
+}
+
+// validateOpenFilesLimit checks that the open files limit isn't negative
+func validateOpenFilesLimit(config *viper.Viper) error {
+	if limit := config.GetInt(OpenFilesLimitKey); limit < 0 {
+		return fmt.Errorf("%s must be positive, or 0 for no limit (got %d)", OpenFilesLimitKey, limit)
+	}
+	return nil
+}
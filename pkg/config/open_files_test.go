@@ -0,0 +1,23 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOpenFilesLimit(t *testing.T) {
+	config := viper.New()
+	setDefaults(config)
+	assert.Nil(t, validateOpenFilesLimit(config))
+	config.Set(OpenFilesLimitKey, 500)
+	assert.Nil(t, validateOpenFilesLimit(config))
+	config.Set(OpenFilesLimitKey, -1)
+	assert.NotNil(t, validateOpenFilesLimit(config))
+}
@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// IsPathPattern returns true when path is a glob pattern, such as /var/log/myapp/*.log,
+// ** matching any number of directories as in /var/log/**/app.log
+func IsPathPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// IsPattern returns true when the path of the source is a pattern matching several files
+func (s *IntegrationConfigLogSource) IsPattern() bool {
+	return s.Type == FILE_TYPE && IsPathPattern(s.Path)
+}
+
+// ForPath returns the source tailing path, one of the files matching the pattern of s,
+// which has its own identifier so that the offset of each file is tracked
+func (s *IntegrationConfigLogSource) ForPath(path string) *IntegrationConfigLogSource {
+	source := *s
+	source.Path = path
+	source.ID = BuildSourceID(&source)
+	return &source
+}
+
+// MatchPathPattern returns true when path matches pattern, ** matching any number of directories
+func MatchPathPattern(pattern, path string) bool {
+	separator := string(filepath.Separator)
+	return matchComponents(strings.Split(filepath.Clean(pattern), separator), strings.Split(filepath.Clean(path), separator))
+}
+
+// ExcludedPathsKey holds the patterns of the files no source tails on the host, such
+// as sensitive files, whatever the sources configured in conf.d
+const ExcludedPathsKey = "logs_config.excluded_paths"
+
+// ExcludesPath returns true when path is excluded by the exclude_paths of the source,
+// the patterns without separator matching the name of the file, or by logs_config.excluded_paths
+func (s *IntegrationConfigLogSource) ExcludesPath(path string) bool {
+	return matchesExcludePatterns(s.ExcludePaths, path) || IsExcludedPath(path)
+}
+
+// IsExcludedPath returns true when path is excluded by logs_config.excluded_paths
+func IsExcludedPath(path string) bool {
+	return matchesExcludePatterns(LogsAgent.GetStringSlice(ExcludedPathsKey), path)
+}
+
+// matchesExcludePatterns returns true when path matches one of the patterns,
+// the patterns without separator matching the name of the file
+func matchesExcludePatterns(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, `/\`) {
+			if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+				return true
+			}
+		} else if MatchPathPattern(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExcludedPaths checks the patterns of logs_config.excluded_paths
+func validateExcludedPaths(config *viper.Viper) error {
+	for _, pattern := range config.GetStringSlice(ExcludedPathsKey) {
+		if err := validatePathPattern(pattern); err != nil {
+			return fmt.Errorf("invalid %s pattern %s: %v", ExcludedPathsKey, pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchComponents returns true when the components of a path match those of a pattern
+func matchComponents(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchComponents(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// validatePathPattern checks that each component of pattern is a valid glob,
+// ** being only allowed as a whole component
+func validatePathPattern(pattern string) error {
+	for _, component := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if component == "**" {
+			continue
+		}
+		if strings.Contains(component, "**") {
+			return fmt.Errorf("** must be a whole component of the path")
+		}
+		if _, err := filepath.Match(component, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// pathTagName matches the placeholders of path_tags, such as {service}
+var pathTagName = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_.\-]*)\}`)
+
+// CompilePathTags returns the regexp matching the paths described by template,
+// each {name} placeholder capturing one component of the path, and *
+// matching any part of a component as in glob patterns
+func CompilePathTags(template string) (*regexp.Regexp, error) {
+	var pattern bytes.Buffer
+	pattern.WriteString("^")
+	names := make(map[string]bool)
+	last := 0
+	for _, loc := range pathTagName.FindAllStringSubmatchIndex(template, -1) {
+		writeLiteral(&pattern, template[last:loc[0]])
+		name := template[loc[2]:loc[3]]
+		if names[name] {
+			return nil, fmt.Errorf("{%s} is used twice", name)
+		}
+		names[name] = true
+		pattern.WriteString(fmt.Sprintf("(?P<%s>[^/]+)", strings.Replace(name, ".", "_", -1)))
+		last = loc[1]
+	}
+	writeLiteral(&pattern, template[last:])
+	pattern.WriteString("$")
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s has no {tag} placeholder", template)
+	}
+	return regexp.Compile(pattern.String())
+}
+
+// PathTags returns the tags extracted from path by the path_tags of source,
+// along with the tags of source, or the tags of source when path doesn't match
+func PathTags(source *IntegrationConfigLogSource, path string) string {
+	return templateTags(source.Tags, source.PathTags, source.PathTagsReg, path)
+}
+
+// TopicTags returns the tags extracted from the mqtt topic by the topic_tags of
+// source, along with the tags of source, or the tags of source when topic doesn't match
+func TopicTags(source *IntegrationConfigLogSource, topic string) string {
+	return templateTags(source.Tags, source.TopicTags, source.TopicTagsReg, topic)
+}
+
+// templateTags returns tags along with those extracted from value by template,
+// reg being the compiled template
+func templateTags(tags, template string, reg *regexp.Regexp, value string) string {
+	if reg == nil {
+		return tags
+	}
+	values := reg.FindStringSubmatch(value)
+	if values == nil {
+		return tags
+	}
+	all := []string{}
+	if tags != "" {
+		all = append(all, tags)
+	}
+	for i, name := range pathTagName.FindAllStringSubmatch(template, -1) {
+		all = append(all, name[1]+":"+values[i+1])
+	}
+	return strings.Join(all, ",")
+}
+
+// writeLiteral writes the pattern matching the literal part of a template, * being a wildcard
+func writeLiteral(pattern *bytes.Buffer, literal string) {
+	for i, part := range strings.Split(literal, "*") {
+		if i > 0 {
+			pattern.WriteString("[^/]*")
+		}
+		pattern.WriteString(regexp.QuoteMeta(part))
+	}
+}
@@ -0,0 +1,36 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathTags(t *testing.T) {
+	source := &IntegrationConfigLogSource{Tags: "team:web", PathTags: "/var/log/apps/{service}/{env}/*.log"}
+	var err error
+	source.PathTagsReg, err = CompilePathTags(source.PathTags)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "team:web,service:billing,env:prod", PathTags(source, "/var/log/apps/billing/prod/app.log"))
+	assert.Equal(t, "team:web", PathTags(source, "/var/log/apps/billing/prod/current/app.log"))
+	assert.Equal(t, "team:web", PathTags(source, "/var/log/apps/billing/prod/app.txt"))
+
+	source.Tags = ""
+	assert.Equal(t, "service:billing,env:staging", PathTags(source, "/var/log/apps/billing/staging/a.b.log"))
+}
+
+func TestCompilePathTags(t *testing.T) {
+	_, err := CompilePathTags("/var/log/app.log")
+	assert.NotNil(t, err)
+	_, err = CompilePathTags("/var/log/{env}/{env}.log")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/prod.log", PathTags: "/var/log/{env}.log"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514, PathTags: "/var/log/{env}.log"}))
+}
@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// disabledSourcesFile is where the identifiers of the disabled sources are
+// kept in run_path, next to the registry
+const disabledSourcesFile = "disabled_sources.json"
+
+// pausedSources are the identifiers of the sources paused through the control
+// API, kept across reloads but not across restarts, and disabledSources those
+// of the sources disabled, kept across restarts until enabled again
+var (
+	pausedSources   = make(map[string]bool)
+	disabledSources = make(map[string]bool)
+	pausedMutex     sync.RWMutex
+)
+
+// SetSourcePaused pauses or resumes the collection of the source with id
+func SetSourcePaused(id string, paused bool) {
+	pausedMutex.Lock()
+	defer pausedMutex.Unlock()
+	if paused {
+		pausedSources[id] = true
+	} else {
+		delete(pausedSources, id)
+	}
+}
+
+// IsSourcePaused returns true if the source with id is paused
+func IsSourcePaused(id string) bool {
+	pausedMutex.RLock()
+	defer pausedMutex.RUnlock()
+	return pausedSources[id]
+}
+
+// SetSourceDisabled disables or enables the collection of the source with id,
+// persisting it in run_path. Nothing changes when it can't be persisted
+func SetSourceDisabled(id string, disabled bool) error {
+	return setSourceDisabled(LogsAgent, id, disabled)
+}
+
+func setSourceDisabled(config *viper.Viper, id string, disabled bool) error {
+	path := disabledSourcesPath(config)
+	if path == "" {
+		return fmt.Errorf("can't persist the disabled sources without writable run_path")
+	}
+	pausedMutex.Lock()
+	defer pausedMutex.Unlock()
+	ids := []string{}
+	for disabledID := range disabledSources {
+		if disabledID != id {
+			ids = append(ids, disabledID)
+		}
+	}
+	if disabled {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	b, err := json.Marshal(ids)
+	if err == nil {
+		err = ioutil.WriteFile(path+".tmp", b, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		return fmt.Errorf("can't persist the disabled sources: %v", err)
+	}
+	if disabled {
+		disabledSources[id] = true
+	} else {
+		delete(disabledSources, id)
+	}
+	return nil
+}
+
+// IsSourceDisabled returns true if the source with id is disabled
+func IsSourceDisabled(id string) bool {
+	pausedMutex.RLock()
+	defer pausedMutex.RUnlock()
+	return disabledSources[id]
+}
+
+// loadDisabledSources reads the sources disabled by the previous runs of the agent
+func loadDisabledSources(config *viper.Viper) {
+	path := disabledSourcesPath(config)
+	if path == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	var ids []string
+	if err == nil {
+		err = json.Unmarshal(b, &ids)
+	}
+	if err != nil {
+		log.Println("Can't read the disabled sources, collecting them all:", err)
+		return
+	}
+	pausedMutex.Lock()
+	defer pausedMutex.Unlock()
+	disabledSources = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		disabledSources[id] = true
+	}
+}
+
+// disabledSourcesPath returns where the disabled sources are kept, empty when stateless
+func disabledSourcesPath(config *viper.Viper) string {
+	runPath := config.GetString("run_path")
+	if runPath == "" {
+		return ""
+	}
+	return filepath.Join(runPath, disabledSourcesFile)
+}
+
+// ActiveSources returns the sources which are neither paused nor disabled
+func ActiveSources(sources []*IntegrationConfigLogSource) []*IntegrationConfigLogSource {
+	active := []*IntegrationConfigLogSource{}
+	for _, source := range sources {
+		if IsSourceActive(source.GetID()) {
+			active = append(active, source)
+		}
+	}
+	return active
+}
+
+// IsSourceActive returns true if the source with id is neither paused nor disabled
+func IsSourceActive(id string) bool {
+	return !IsSourcePaused(id) && !IsSourceDisabled(id)
+}
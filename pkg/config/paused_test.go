@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveSources(t *testing.T) {
+	tcp := &IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514}
+	file := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/app.log"}
+	sources := []*IntegrationConfigLogSource{tcp, file}
+	assert.Equal(t, sources, ActiveSources(sources))
+
+	SetSourcePaused(file.GetID(), true)
+	defer SetSourcePaused(file.GetID(), false)
+	assert.True(t, IsSourcePaused(file.GetID()))
+	assert.Equal(t, []*IntegrationConfigLogSource{tcp}, ActiveSources(sources))
+
+	SetSourcePaused(file.GetID(), false)
+	assert.False(t, IsSourcePaused(file.GetID()))
+	assert.Equal(t, sources, ActiveSources(sources))
+}
+
+func TestDisabledSourcesSurviveRestarts(t *testing.T) {
+	runPath, err := ioutil.TempDir("", "disabled")
+	assert.Nil(t, err)
+	defer os.RemoveAll(runPath)
+	config := viper.New()
+	config.Set("run_path", runPath)
+	file := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/app.log"}
+	defer func() { disabledSources = make(map[string]bool) }()
+
+	assert.Nil(t, setSourceDisabled(config, file.GetID(), true))
+	assert.True(t, IsSourceDisabled(file.GetID()))
+	assert.Equal(t, 0, len(ActiveSources([]*IntegrationConfigLogSource{file})))
+
+	// a restart reads the sources disabled by the previous run
+	disabledSources = make(map[string]bool)
+	loadDisabledSources(config)
+	assert.True(t, IsSourceDisabled(file.GetID()))
+
+	assert.Nil(t, setSourceDisabled(config, file.GetID(), false))
+	disabledSources = make(map[string]bool)
+	loadDisabledSources(config)
+	assert.False(t, IsSourceDisabled(file.GetID()))
+
+	// nothing is disabled when it can't be persisted
+	config.Set("run_path", "")
+	assert.NotNil(t, setSourceDisabled(config, file.GetID(), true))
+	assert.False(t, IsSourceDisabled(file.GetID()))
+}
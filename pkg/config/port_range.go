@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPortRangeSize is the maximum number of ports a network source can listen to
+const maxPortRangeSize = 1024
+
+// ParsePortRange returns the first and last ports of a range such as 10500-10520
+func ParsePortRange(portRange string) (int, int, error) {
+	bounds := strings.SplitN(portRange, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid port_range %s, expected first-last", portRange)
+	}
+	first, err := parsePort(bounds[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port_range %s: %v", portRange, err)
+	}
+	last, err := parsePort(bounds[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port_range %s: %v", portRange, err)
+	}
+	if first > last {
+		return 0, 0, fmt.Errorf("invalid port_range %s: first port is greater than last port", portRange)
+	}
+	if last-first+1 > maxPortRangeSize {
+		return 0, 0, fmt.Errorf("invalid port_range %s: can't listen to more than %d ports", portRange, maxPortRangeSize)
+	}
+	return first, last, nil
+}
+
+// parsePort returns the port number represented by s
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("%s is not a valid port", s)
+	}
+	return port, nil
+}
@@ -0,0 +1,37 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortRange(t *testing.T) {
+	first, last, err := ParsePortRange("10500-10520")
+	assert.Nil(t, err)
+	assert.Equal(t, 10500, first)
+	assert.Equal(t, 10520, last)
+
+	first, last, err = ParsePortRange("10500 - 10500")
+	assert.Nil(t, err)
+	assert.Equal(t, 10500, first)
+	assert.Equal(t, 10500, last)
+
+	for _, invalid := range []string{"10500", "10520-10500", "a-10500", "0-10", "10-70000", "1-2000"} {
+		_, _, err = ParsePortRange(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestValidatePortRange(t *testing.T) {
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, PortRange: "10500-10520"}))
+	assert.Nil(t, validateSource(IntegrationConfigLogSource{Type: UDP_TYPE, PortRange: "10500-10520"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10500, PortRange: "10500-10520"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", PortRange: "10500-10520"}))
+	assert.NotNil(t, validateSource(IntegrationConfigLogSource{Type: TCP_TYPE, PortRange: "10520-10500"}))
+}
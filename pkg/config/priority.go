@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+// Priority is the class of a source, which decides in which order
+// its logs are processed, and dropped under backpressure
+type Priority int
+
+// Priority classes, normal being the default
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// Names of the priority classes
+const (
+	PRIORITY_LOW    = "low"
+	PRIORITY_NORMAL = "normal"
+	PRIORITY_HIGH   = "high"
+)
+
+// ParsePriority returns the class named s, an empty name being normal
+func ParsePriority(s string) (Priority, bool) {
+	switch s {
+	case PRIORITY_HIGH:
+		return PriorityHigh, true
+	case PRIORITY_NORMAL, "":
+		return PriorityNormal, true
+	case PRIORITY_LOW:
+		return PriorityLow, true
+	default:
+		return PriorityNormal, false
+	}
+}
+
+// PriorityClass returns the priority class of the source
+func (s *IntegrationConfigLogSource) PriorityClass() Priority {
+	priority, _ := ParsePriority(s.Priority)
+	return priority
+}
@@ -0,0 +1,234 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	// RemoteConfigFile names the integration config fetched from log_remote_config_url,
+	// merged with the files of conf.d
+	RemoteConfigFile = "remote config"
+	// remoteConfigSignatureHeader holds the hex encoded HMAC-SHA256 of the remote
+	// config, keyed by log_remote_config_secret
+	remoteConfigSignatureHeader = "X-Signature"
+	// remoteConfigCacheFile is where the remote config fetched last is kept in
+	// run_path, to be used on start when the endpoint can't be reached
+	remoteConfigCacheFile   = "remote_config.yaml"
+	remoteConfigTimeout     = 30 * time.Second
+	maxRemoteConfigSize     = 10 * 1024 * 1024
+	defaultRemoteConfigPoll = 5 * time.Minute
+)
+
+// remoteConfig is the integration config fetched last, with the outcome of the last fetch
+var remoteConfig = struct {
+	sync.Mutex
+	content []byte
+	fetched time.Time
+	err     error
+}{}
+
+func init() {
+	status.Register("remote config", remoteConfigStatus)
+}
+
+// IsRemoteConfigEnabled returns true when integration configs are pulled from log_remote_config_url
+func IsRemoteConfigEnabled() bool {
+	return LogsAgent.GetString("log_remote_config_url") != ""
+}
+
+// RemoteConfigPollInterval returns how often the remote config is fetched
+func RemoteConfigPollInterval() time.Duration {
+	interval := LogsAgent.GetDuration("log_remote_config_interval")
+	if interval <= 0 {
+		return defaultRemoteConfigPoll
+	}
+	return interval
+}
+
+// FetchRemoteConfig pulls the integration config of log_remote_config_url, and
+// returns true when it changed, its sources having to be reloaded
+func FetchRemoteConfig() (bool, error) {
+	return fetchRemoteConfig(LogsAgent)
+}
+
+// initRemoteConfig fetches the remote config on start, falling back to the one
+// fetched last by a previous run when the endpoint can't be reached
+func initRemoteConfig(config *viper.Viper) {
+	if config.GetString("log_remote_config_url") == "" {
+		return
+	}
+	_, err := fetchRemoteConfig(config)
+	if err == nil {
+		return
+	}
+	log.Println("Can't fetch the remote config:", err)
+	path := remoteConfigCachePath(config)
+	if path == "" {
+		return
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	log.Println("Using the remote config fetched last")
+	remoteConfig.Lock()
+	remoteConfig.content = content
+	remoteConfig.Unlock()
+}
+
+// fetchRemoteConfig pulls the remote config with the settings of config
+func fetchRemoteConfig(config *viper.Viper) (bool, error) {
+	content, err := downloadRemoteConfig(config.GetString("log_remote_config_url"), config.GetString("log_remote_config_secret"))
+	remoteConfig.Lock()
+	defer remoteConfig.Unlock()
+	remoteConfig.err = err
+	if err != nil {
+		return false, err
+	}
+	remoteConfig.fetched = time.Now()
+	if bytes.Equal(content, remoteConfig.content) {
+		return false, nil
+	}
+	remoteConfig.content = content
+	if path := remoteConfigCachePath(config); path != "" {
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			log.Println("Can't cache the remote config:", err)
+		}
+	}
+	return true, nil
+}
+
+// downloadRemoteConfig returns the integration config served at rawurl, which must be
+// signed with secret. The signature is required even over https, as the endpoint,
+// such as a bucket, isn't trusted as much as the host running the agent
+func downloadRemoteConfig(rawurl, secret string) ([]byte, error) {
+	if secret == "" {
+		return nil, errors.New("log_remote_config_secret must be set to verify the remote config")
+	}
+	if _, err := url.Parse(rawurl); err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: remoteConfigTimeout}
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote config endpoint returned %s", resp.Status)
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxRemoteConfigSize {
+		return nil, fmt.Errorf("remote config is larger than %d bytes", maxRemoteConfigSize)
+	}
+	if !validSignature(content, secret, resp.Header.Get(remoteConfigSignatureHeader)) {
+		return nil, errors.New("invalid remote config signature")
+	}
+	if _, err := parseRemoteConfig(content); err != nil {
+		return nil, fmt.Errorf("invalid remote config: %v", err)
+	}
+	return content, nil
+}
+
+// validSignature returns true when signature is the hex encoded HMAC-SHA256 of content keyed by secret
+func validSignature(content []byte, secret, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(content)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// parseRemoteConfig returns the integration config of content
+func parseRemoteConfig(content []byte) (*viper.Viper, error) {
+	viperCfg := viper.New()
+	viperCfg.SetConfigType("yaml")
+	if err := viperCfg.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return viperCfg, nil
+}
+
+// checkRemoteIncludes returns an error when a source of the remote config
+// includes snippets, which would read the files of the host
+func checkRemoteIncludes(source interface{}) error {
+	settings, err := cast.ToStringMapE(source)
+	if err != nil {
+		return nil
+	}
+	for key := range settings {
+		if strings.EqualFold(key, includeKey) {
+			return newSourceError("the sources of the remote config can't include snippets")
+		}
+	}
+	return nil
+}
+
+// checkRemoteSourceType returns an error when source isn't one of runtimeSourceTypes,
+// the remote config being as trusted as the sources added at runtime
+func checkRemoteSourceType(source *IntegrationConfigLogSource) error {
+	if !runtimeSourceTypes[source.Type] {
+		return newSourceError("%s sources can't be configured remotely, only %s", source.Type, strings.Join(runtimeSourceTypeNames(), ", "))
+	}
+	return nil
+}
+
+// remoteConfigContent returns the remote config fetched last, nil if none
+func remoteConfigContent() []byte {
+	remoteConfig.Lock()
+	defer remoteConfig.Unlock()
+	return remoteConfig.content
+}
+
+// remoteConfigCachePath returns where the remote config is cached, empty when stateless
+func remoteConfigCachePath(config *viper.Viper) string {
+	runPath := config.GetString("run_path")
+	if runPath == "" {
+		return ""
+	}
+	return filepath.Join(runPath, remoteConfigCacheFile)
+}
+
+// remoteConfigStatus returns the outcome of the last fetch of the remote config
+func remoteConfigStatus() interface{} {
+	remoteConfig.Lock()
+	defer remoteConfig.Unlock()
+	switch {
+	case remoteConfig.err != nil:
+		return fmt.Sprintf("can't fetch: %v", remoteConfig.err)
+	case !remoteConfig.fetched.IsZero():
+		return "fetched at " + remoteConfig.fetched.Format(time.RFC3339)
+	case remoteConfig.content != nil:
+		return "using the config fetched by a previous run"
+	default:
+		return "disabled"
+	}
+}
@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+const remoteConfigYAML = `logs:
+  - type: tcp
+    port: 10600
+    service: remote
+`
+
+// serveRemoteConfig serves content signed with secret
+func serveRemoteConfig(content *string, secret string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(*content))
+		w.Header().Set(remoteConfigSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+		w.Write([]byte(*content))
+	}))
+}
+
+// resetRemoteConfig forgets the remote config fetched by a test
+func resetRemoteConfig() {
+	remoteConfig.Lock()
+	defer remoteConfig.Unlock()
+	remoteConfig.content = nil
+	remoteConfig.fetched = time.Time{}
+	remoteConfig.err = nil
+}
+
+func TestFetchRemoteConfig(t *testing.T) {
+	defer resetRemoteConfig()
+	runPath, err := ioutil.TempDir("", "remote")
+	assert.Nil(t, err)
+	defer os.RemoveAll(runPath)
+	content := remoteConfigYAML
+	server := serveRemoteConfig(&content, "s3cr3t")
+	defer server.Close()
+
+	config := viper.New()
+	config.Set("run_path", runPath)
+	config.Set("log_remote_config_url", server.URL)
+	config.Set("log_remote_config_secret", "s3cr3t")
+
+	changed, err := fetchRemoteConfig(config)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	cached, err := ioutil.ReadFile(filepath.Join(runPath, remoteConfigCacheFile))
+	assert.Nil(t, err)
+	assert.Equal(t, remoteConfigYAML, string(cached))
+
+	changed, err = fetchRemoteConfig(config)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	content = "logs: [\n"
+	_, err = fetchRemoteConfig(config)
+	assert.NotNil(t, err)
+	assert.Equal(t, remoteConfigYAML, string(remoteConfigContent()))
+
+	config.Set("log_remote_config_secret", "wrong")
+	content = remoteConfigYAML
+	_, err = fetchRemoteConfig(config)
+	assert.EqualError(t, err, "invalid remote config signature")
+
+	// the remote config must be signed
+	config.Set("log_remote_config_secret", "")
+	_, err = fetchRemoteConfig(config)
+	assert.EqualError(t, err, "log_remote_config_secret must be set to verify the remote config")
+}
+
+func TestRemoteConfigIsMergedWithConfd(t *testing.T) {
+	defer resetRemoteConfig()
+	content := remoteConfigYAML
+	server := serveRemoteConfig(&content, "s3cr3t")
+	defer server.Close()
+
+	config := viper.New()
+	config.Set("log_remote_config_url", server.URL)
+	config.Set("log_remote_config_secret", "s3cr3t")
+	initRemoteConfig(config)
+
+	sources, _, err := loadLogsSources(config, filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(sources))
+	assert.Equal(t, "remote", sources[3].Service)
+	assert.Equal(t, 10600, sources[3].Port)
+}
+
+func TestRemoteConfigIsRestricted(t *testing.T) {
+	defer resetRemoteConfig()
+	os.Setenv("REMOTE_SERVICE", "interpolated")
+	defer os.Unsetenv("REMOTE_SERVICE")
+	content := `logs:
+  - type: tcp
+    port: 10600
+    service: ${REMOTE_SERVICE}
+  - type: command
+    command: /bin/sh
+    service: remote
+  - type: udp
+    port: 10601
+    include: integration.yaml
+`
+	server := serveRemoteConfig(&content, "s3cr3t")
+	defer server.Close()
+
+	config := viper.New()
+	config.Set("log_remote_config_url", server.URL)
+	config.Set("log_remote_config_secret", "s3cr3t")
+	initRemoteConfig(config)
+
+	sources, errs, err := loadLogsSources(config, filepath.Join(testsPath, "complete", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(sources))
+	assert.Equal(t, "${REMOTE_SERVICE}", sources[3].Service)
+	assert.Equal(t, 2, len(errs))
+	assert.Contains(t, errs[0].Error(), "command sources can't be configured remotely, only docker, file, tcp, udp")
+	assert.Contains(t, errs[1].Error(), "the sources of the remote config can't include snippets")
+}
+
+func TestInitRemoteConfigFallsBackToCache(t *testing.T) {
+	defer resetRemoteConfig()
+	runPath, err := ioutil.TempDir("", "remote")
+	assert.Nil(t, err)
+	defer os.RemoveAll(runPath)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(runPath, remoteConfigCacheFile), []byte(remoteConfigYAML), 0600))
+
+	config := viper.New()
+	config.Set("run_path", runPath)
+	config.Set("log_remote_config_url", "https://127.0.0.1:1/logs.yaml")
+	initRemoteConfig(config)
+	assert.Equal(t, remoteConfigYAML, string(remoteConfigContent()))
+	assert.Contains(t, remoteConfigStatus(), "can't fetch")
+}
@@ -0,0 +1,59 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// runtimeSourceTypes are the types of the sources which can be added at runtime.
+// The command sources, running a program, and the sources connecting to remote
+// brokers are only configured in conf.d
+var runtimeSourceTypes = map[string]bool{
+	FILE_TYPE:   true,
+	TCP_TYPE:    true,
+	UDP_TYPE:    true,
+	DOCKER_TYPE: true,
+}
+
+// ParseSource returns the source described by settings, as a source of conf.d
+// decoded from json, with the global processing rules. Unlike conf.d, a rule
+// with an invalid pattern makes the source invalid, the errors being returned
+// to the caller registering the source rather than logged. Only the types of
+// runtimeSourceTypes are accepted
+func ParseSource(settings map[string]interface{}) (*IntegrationConfigLogSource, error) {
+	globalRules, err := getGlobalProcessingRules(LogsAgent)
+	if err != nil {
+		return nil, err
+	}
+	return parseSource(settings, globalRules)
+}
+
+func parseSource(settings map[string]interface{}, globalRules []LogsProcessingRule) (*IntegrationConfigLogSource, error) {
+	source, skippedRules, err := checkSource(settings, nil, nil, globalRules)
+	if err != nil {
+		return nil, err
+	}
+	if !runtimeSourceTypes[source.Type] {
+		return nil, fmt.Errorf("%s sources can't be added at runtime, only %s", source.Type, strings.Join(runtimeSourceTypeNames(), ", "))
+	}
+	if len(skippedRules) > 0 {
+		return nil, skippedRules[0]
+	}
+	return source, nil
+}
+
+// runtimeSourceTypeNames returns the sorted types of runtimeSourceTypes
+func runtimeSourceTypeNames() []string {
+	names := make([]string, 0, len(runtimeSourceTypes))
+	for name := range runtimeSourceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,34 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSource(t *testing.T) {
+	source, err := parseSource(map[string]interface{}{"type": "tcp", "port": 10514, "service": "app"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, TCP_TYPE, source.Type)
+	assert.Equal(t, 10514, source.Port)
+	assert.Equal(t, "app", source.Service)
+	assert.NotEmpty(t, source.ID)
+	assert.NotNil(t, source.TagsPayload)
+
+	_, err = parseSource(map[string]interface{}{"type": "tcp"}, nil)
+	assert.NotNil(t, err)
+	_, err = parseSource(map[string]interface{}{"type": "ftp", "port": 21}, nil)
+	assert.NotNil(t, err)
+	// the programs run by the command sources must be configured in conf.d
+	_, err = parseSource(map[string]interface{}{"type": "command", "command": "/bin/sh", "args": []interface{}{"-c", "id"}}, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "can't be added at runtime")
+	rules := []interface{}{map[string]interface{}{"type": "exclude_at_match", "name": "broken", "pattern": "("}}
+	_, err = parseSource(map[string]interface{}{"type": "tcp", "port": 10514, "log_processing_rules": rules}, nil)
+	assert.NotNil(t, err)
+}
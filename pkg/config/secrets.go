@@ -0,0 +1,278 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultSecretBackendTimeout bounds the run time of the secret backend command
+const defaultSecretBackendTimeout = 5 * time.Second
+
+// secretsPayload is what the secret backend command reads on its standard input
+type secretsPayload struct {
+	Version string   `json:"version"`
+	Secrets []string `json:"secrets"`
+}
+
+// secret is the value of a secret returned by the secret backend command, or why it failed
+type secret struct {
+	Value    string  `json:"value"`
+	ErrorMsg *string `json:"error"`
+}
+
+// A SecretResolver fetches the values of secret handles
+type SecretResolver interface {
+	Resolve(handles []string) (map[string]string, error)
+}
+
+// secretHandle matches the values of the settings naming a secret, such as ENC[api_key]
+var secretHandle = regexp.MustCompile(`^ENC\[(.+)\]$`)
+
+// secretResolvers are the resolvers of the secret backend commands, by command line,
+// unless a custom resolver replaces them
+var secretResolvers = struct {
+	sync.Mutex
+	custom   *cachedResolver
+	commands map[string]*cachedResolver
+}{commands: make(map[string]*cachedResolver)}
+
+// SetSecretResolver makes resolver fetch the secrets instead of secret_backend_command,
+// nil restoring the command
+func SetSecretResolver(resolver SecretResolver) {
+	secretResolvers.Lock()
+	defer secretResolvers.Unlock()
+	if resolver == nil {
+		secretResolvers.custom = nil
+		return
+	}
+	secretResolvers.custom = newCachedResolver(resolver)
+}
+
+// secretResolverOf returns the resolver of the secret backend command of config
+func secretResolverOf(config *viper.Viper) SecretResolver {
+	secretResolvers.Lock()
+	defer secretResolvers.Unlock()
+	if secretResolvers.custom != nil {
+		return secretResolvers.custom
+	}
+	command := &commandResolver{
+		command:   config.GetString("secret_backend_command"),
+		arguments: config.GetStringSlice("secret_backend_arguments"),
+		timeout:   config.GetDuration("secret_backend_timeout"),
+	}
+	key := strings.Join(append([]string{command.command}, command.arguments...), "\x00")
+	resolver, ok := secretResolvers.commands[key]
+	if !ok {
+		resolver = newCachedResolver(command)
+		secretResolvers.commands[key] = resolver
+	}
+	return resolver
+}
+
+// ResolveSecret returns the value of the secret handle, fetched with secret_backend_command
+func ResolveSecret(handle string) (string, error) {
+	values, err := secretResolverOf(LogsAgent).Resolve([]string{handle})
+	if err != nil {
+		return "", err
+	}
+	return values[handle], nil
+}
+
+// resolveSecrets replaces the settings of target naming a secret, ENC[handle], by the
+// value of the secret, fetched with the secret backend of config
+func resolveSecrets(config, target *viper.Viper) error {
+	handles := []string{}
+	for _, key := range target.AllKeys() {
+		handles = collectSecretHandles(target.Get(key), handles)
+	}
+	if len(handles) == 0 {
+		return nil
+	}
+	values, err := secretResolverOf(config).Resolve(handles)
+	if err != nil {
+		return err
+	}
+	for _, key := range target.AllKeys() {
+		value := target.Get(key)
+		if len(collectSecretHandles(value, nil)) > 0 {
+			target.Set(key, decryptValue(value, values))
+		}
+	}
+	return nil
+}
+
+// collectSecretHandles appends the secret handles named in value to handles
+func collectSecretHandles(value interface{}, handles []string) []string {
+	switch v := value.(type) {
+	case string:
+		if match := secretHandle.FindStringSubmatch(v); match != nil {
+			handles = append(handles, match[1])
+		}
+	case []interface{}:
+		for _, item := range v {
+			handles = collectSecretHandles(item, handles)
+		}
+	case []string:
+		for _, item := range v {
+			handles = collectSecretHandles(item, handles)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			handles = collectSecretHandles(item, handles)
+		}
+	case map[interface{}]interface{}:
+		for _, item := range v {
+			handles = collectSecretHandles(item, handles)
+		}
+	}
+	return handles
+}
+
+// decryptValue returns a copy of value whose secret handles are replaced by their values
+func decryptValue(value interface{}, values map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if match := secretHandle.FindStringSubmatch(v); match != nil {
+			return values[match[1]]
+		}
+		return v
+	case []interface{}:
+		decrypted := make([]interface{}, len(v))
+		for i, item := range v {
+			decrypted[i] = decryptValue(item, values)
+		}
+		return decrypted
+	case []string:
+		decrypted := make([]string, len(v))
+		for i, item := range v {
+			decrypted[i] = decryptValue(item, values).(string)
+		}
+		return decrypted
+	case map[string]interface{}:
+		decrypted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			decrypted[key] = decryptValue(item, values)
+		}
+		return decrypted
+	case map[interface{}]interface{}:
+		decrypted := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			decrypted[key] = decryptValue(item, values)
+		}
+		return decrypted
+	}
+	return value
+}
+
+// cachedResolver caches the secrets fetched by a resolver, so that they're
+// fetched once for the lifetime of the agent, errors being retried
+type cachedResolver struct {
+	resolver SecretResolver
+	mutex    sync.Mutex
+	values   map[string]string
+}
+
+// newCachedResolver returns a resolver caching the secrets fetched by resolver
+func newCachedResolver(resolver SecretResolver) *cachedResolver {
+	return &cachedResolver{
+		resolver: resolver,
+		values:   make(map[string]string),
+	}
+}
+
+// Resolve returns the values of handles, fetching at once those not cached yet
+func (c *cachedResolver) Resolve(handles []string) (map[string]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	missing := []string{}
+	for _, handle := range handles {
+		if _, ok := c.values[handle]; !ok {
+			missing = append(missing, handle)
+		}
+	}
+	if len(missing) > 0 {
+		fetched, err := c.resolver.Resolve(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, handle := range missing {
+			value, ok := fetched[handle]
+			if !ok {
+				return nil, fmt.Errorf("secret %s wasn't resolved", handle)
+			}
+			c.values[handle] = value
+		}
+	}
+	values := make(map[string]string, len(handles))
+	for _, handle := range handles {
+		values[handle] = c.values[handle]
+	}
+	return values, nil
+}
+
+// commandResolver fetches secrets with a secret backend command, which reads the
+// requested handles as JSON on its standard input and writes their values as JSON
+// on its standard output, like for the datadog agent
+type commandResolver struct {
+	command   string
+	arguments []string
+	timeout   time.Duration
+}
+
+// Resolve runs the command to fetch the values of handles
+func (r *commandResolver) Resolve(handles []string) (map[string]string, error) {
+	if r.command == "" {
+		return nil, errors.New("secret_backend_command is not set")
+	}
+	input, err := json.Marshal(secretsPayload{Version: "1.0", Secrets: handles})
+	if err != nil {
+		return nil, err
+	}
+	timeout := r.timeout
+	if timeout <= 0 {
+		timeout = defaultSecretBackendTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, r.command, r.arguments...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("secret backend command failed: %v %s", err, stderr.String())
+	}
+	secrets := map[string]secret{}
+	err = json.Unmarshal(stdout.Bytes(), &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output of the secret backend command: %v", err)
+	}
+	values := make(map[string]string, len(handles))
+	for _, handle := range handles {
+		s, ok := secrets[handle]
+		if !ok {
+			return nil, fmt.Errorf("secret %s is missing from the output of the secret backend command", handle)
+		}
+		if s.ErrorMsg != nil {
+			return nil, fmt.Errorf("can't fetch secret %s: %s", handle, *s.ErrorMsg)
+		}
+		values[handle] = s.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// secretBackend writes a secret backend command printing output, each command
+// having its own path as the secrets are cached by command
+func secretBackend(t *testing.T, dir, output string) string {
+	f, err := ioutil.TempFile(dir, "backend")
+	assert.Nil(t, err)
+	f.Close()
+	script := "#!/bin/sh\ncat > /dev/null\necho '" + output + "'\n"
+	assert.Nil(t, ioutil.WriteFile(f.Name(), []byte(script), 0700))
+	assert.Nil(t, os.Chmod(f.Name(), 0700))
+	return f.Name()
+}
+
+func TestSpoolEncryptionKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer LogsAgent.Set("secret_backend_command", "")
+	defer LogsAgent.Set("log_spool_encryption_key_secret", "")
+
+	key, err := SpoolEncryptionKey()
+	assert.Nil(t, err)
+	assert.Nil(t, key)
+
+	LogsAgent.Set("log_spool_encryption_key_secret", "spool_key")
+	_, err = SpoolEncryptionKey()
+	assert.NotNil(t, err)
+
+	LogsAgent.Set("secret_backend_command", secretBackend(t, dir, `{"spool_key": {"value": "MDEyMzQ1Njc4OWFiY2RlZg==", "error": null}}`))
+	key, err = SpoolEncryptionKey()
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789abcdef", string(key))
+
+	LogsAgent.Set("secret_backend_command", secretBackend(t, dir, `{"spool_key": {"value": "c2hvcnQ=", "error": null}}`))
+	_, err = SpoolEncryptionKey()
+	assert.NotNil(t, err)
+
+	LogsAgent.Set("secret_backend_command", secretBackend(t, dir, `{"spool_key": {"value": "", "error": "access denied"}}`))
+	_, err = SpoolEncryptionKey()
+	assert.EqualError(t, err, "can't fetch secret spool_key: access denied")
+}
+
+// countingResolver resolves handles to their name in upper case, counting its calls
+type countingResolver struct {
+	calls int
+}
+
+func (r *countingResolver) Resolve(handles []string) (map[string]string, error) {
+	r.calls++
+	values := make(map[string]string, len(handles))
+	for _, handle := range handles {
+		values[handle] = strings.ToUpper(handle)
+	}
+	return values, nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	resolver := &countingResolver{}
+	SetSecretResolver(resolver)
+	defer SetSecretResolver(nil)
+
+	target := viper.New()
+	target.Set("api_key", "ENC[api_key]")
+	target.Set("hostname", "ENC[not a secret")
+	target.Set("logs", []interface{}{
+		map[interface{}]interface{}{"type": "mqtt", "password": "ENC[mqtt_password]", "topics": []interface{}{"logs"}},
+	})
+	assert.Nil(t, resolveSecrets(viper.New(), target))
+	assert.Equal(t, 1, resolver.calls)
+	assert.Equal(t, "API_KEY", target.GetString("api_key"))
+	assert.Equal(t, "ENC[not a secret", target.GetString("hostname"))
+	source := target.Get("logs").([]interface{})[0].(map[interface{}]interface{})
+	assert.Equal(t, "MQTT_PASSWORD", source["password"])
+	assert.Equal(t, []interface{}{"logs"}, source["topics"])
+
+	// cached
+	value, err := ResolveSecret("api_key")
+	assert.Nil(t, err)
+	assert.Equal(t, "API_KEY", value)
+	assert.Equal(t, 1, resolver.calls)
+}
+
+func TestResolveSecretsWithCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config := viper.New()
+	config.Set("secret_backend_command", secretBackend(t, dir, `{"api_key": {"value": "0123", "error": null}, "password": {"value": "s3cr3t", "error": null}}`))
+	target := viper.New()
+	target.Set("api_key", "ENC[api_key]")
+	target.Set("broker", map[string]interface{}{"password": "ENC[password]"})
+	assert.Nil(t, resolveSecrets(config, target))
+	assert.Equal(t, "0123", target.GetString("api_key"))
+	assert.Equal(t, "s3cr3t", target.GetString("broker.password"))
+
+	target.Set("api_key", "ENC[unknown]")
+	assert.EqualError(t, resolveSecrets(config, target), "secret unknown is missing from the output of the secret backend command")
+}
+
+func TestIntegrationConfigSecretsAreResolved(t *testing.T) {
+	SetSecretResolver(&countingResolver{})
+	defer SetSecretResolver(nil)
+
+	viperCfg, err := readIntegrationConfig(viper.New(), filepath.Join(testsPath, "secrets"), "mqtt.yaml")
+	assert.Nil(t, err)
+	sources := viperCfg.Get("logs").([]interface{})
+	assert.Equal(t, "BROKER_PASSWORD", cast.ToStringMap(sources[0])["password"])
+}
@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NoSeverity means that a remap_severity rule applies whatever the detected severity
+const NoSeverity = -1
+
+// severityLevels maps the names of the severities to their syslog level
+var severityLevels = map[string]int{
+	"emerg":     0,
+	"emergency": 0,
+	"alert":     1,
+	"crit":      2,
+	"critical":  2,
+	"err":       3,
+	"error":     3,
+	"warn":      4,
+	"warning":   4,
+	"notice":    5,
+	"info":      6,
+	"debug":     7,
+}
+
+// ParseSeverity returns the syslog level of a severity given by name or by level
+func ParseSeverity(severity string) (int, bool) {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	if level, ok := severityLevels[severity]; ok {
+		return level, true
+	}
+	level, err := strconv.Atoi(severity)
+	if err != nil || level < 0 || level > 7 {
+		return 0, false
+	}
+	return level, true
+}
@@ -0,0 +1,45 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeverity(t *testing.T) {
+	for severity, expected := range map[string]int{"WARNING": 4, "warn": 4, "4": 4, "error": 3, "Info": 6, "0": 0} {
+		level, ok := ParseSeverity(severity)
+		assert.True(t, ok, severity)
+		assert.Equal(t, expected, level, severity)
+	}
+	for _, invalid := range []string{"", "verbose", "8", "-1"} {
+		_, ok := ParseSeverity(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestValidateRemapSeverityRules(t *testing.T) {
+	rules, err := validateProcessingRules([]LogsProcessingRule{
+		{Type: REMAP_SEVERITY, Name: "warnings", Pattern: "WARN", Severity: "warning"},
+		{Type: REMAP_SEVERITY, Name: "noisy", From: "error", Severity: "info"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, rules[0].SeverityLevel)
+	assert.Equal(t, NoSeverity, rules[0].FromLevel)
+	assert.NotNil(t, rules[0].Reg)
+	assert.Equal(t, 6, rules[1].SeverityLevel)
+	assert.Equal(t, 3, rules[1].FromLevel)
+	assert.Nil(t, rules[1].Reg)
+
+	_, err = validateProcessingRules([]LogsProcessingRule{{Type: REMAP_SEVERITY, Name: "nothing", Severity: "info"}})
+	assert.NotNil(t, err)
+	_, err = validateProcessingRules([]LogsProcessingRule{{Type: REMAP_SEVERITY, Name: "invalid", Pattern: "a", Severity: "verbose"}})
+	assert.NotNil(t, err)
+	_, err = validateProcessingRules([]LogsProcessingRule{{Type: REMAP_SEVERITY, Name: "invalid", From: "verbose", Severity: "info"}})
+	assert.NotNil(t, err)
+}
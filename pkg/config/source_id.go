@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BuildSourceID returns a stable identifier of source, such as "file:3b5d5c3712955042",
+// hashing the type and the settings identifying what it collects, sorted by name.
+// It doesn't depend on the file the source is configured in nor on the settings
+// changing how logs are processed, so that the offsets of the registry and the
+// metrics of the source survive config moves and edits
+func BuildSourceID(source *IntegrationConfigLogSource) string {
+	settings := map[string]string{"type": source.Type}
+	if source.Path != "" {
+		path, err := filepath.Abs(source.Path)
+		if err != nil {
+			path = filepath.Clean(source.Path)
+		}
+		settings["path"] = path
+	}
+	if source.Port != 0 {
+		settings["port"] = strconv.Itoa(source.Port)
+	}
+	if source.PortRange != "" {
+		settings["port_range"] = source.PortRange
+	}
+	if source.Image != "" {
+		settings["image"] = source.Image
+	}
+	if source.Label != "" {
+		settings["label"] = source.Label
+	}
+	if source.Command != "" {
+		settings["command"] = strings.Join(append([]string{source.Command}, source.Args...), " ")
+	}
+	if source.Broker != "" {
+		settings["broker"] = source.Broker
+	}
+	if len(source.Topics) > 0 {
+		settings["topics"] = strings.Join(source.Topics, ",")
+	}
+	if len(source.Brokers) > 0 {
+		settings["brokers"] = strings.Join(source.Brokers, ",")
+		settings["group_id"] = source.GroupID
+	}
+	if source.Queue != "" {
+		settings["queue"] = source.Queue
+		settings["vhost"] = source.VHost
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\n", name, settings[name])
+	}
+	return fmt.Sprintf("%s:%x", source.Type, h.Sum(nil)[:8])
+}
+
+// GetID returns the stable identifier of the source
+func (s *IntegrationConfigLogSource) GetID() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	return BuildSourceID(s)
+}
@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSourceID(t *testing.T) {
+	source := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", Service: "app"}
+	id := BuildSourceID(source)
+	assert.Regexp(t, regexp.MustCompile("^file:[0-9a-f]{16}$"), id)
+
+	// processing settings don't change the identifier
+	assert.Equal(t, id, BuildSourceID(&IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log", Service: "other", Tags: "env:prod"}))
+	assert.Equal(t, id, BuildSourceID(&IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/../log/a.log"}))
+
+	assert.NotEqual(t, id, BuildSourceID(&IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/b.log"}))
+	assert.NotEqual(t, BuildSourceID(&IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514}), BuildSourceID(&IntegrationConfigLogSource{Type: UDP_TYPE, Port: 10514}))
+	assert.NotEqual(t, BuildSourceID(&IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10514}), BuildSourceID(&IntegrationConfigLogSource{Type: TCP_TYPE, Port: 10515}))
+}
+
+func TestBuildSourceIDResolvesRelativePaths(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	relative := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "logs/a.log"}
+	absolute := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: filepath.Join(wd, "logs", "a.log")}
+	assert.Equal(t, BuildSourceID(absolute), BuildSourceID(relative))
+}
+
+func TestGetIDUsesComputedID(t *testing.T) {
+	source := &IntegrationConfigLogSource{Type: FILE_TYPE, Path: "/var/log/a.log"}
+	assert.Equal(t, BuildSourceID(source), source.GetID())
+	source.ID = "file:0000000000000000"
+	assert.Equal(t, "file:0000000000000000", source.GetID())
+}
@@ -0,0 +1,35 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// SpoolEncryptionKey returns the AES key the spooled payloads are encrypted
+// with, or nil when they aren't. The key is the base64 encoded secret named by
+// log_spool_encryption_key_secret, of 16, 24 or 32 bytes
+func SpoolEncryptionKey() ([]byte, error) {
+	handle := LogsAgent.GetString("log_spool_encryption_key_secret")
+	if handle == "" {
+		return nil, nil
+	}
+	value, err := ResolveSecret(handle)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("the spool encryption key must be base64 encoded: %v", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("the spool encryption key must be 16, 24 or 32 bytes long (got %d)", len(key))
+	}
+}
@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cast"
+)
+
+// normalizeTags replaces the list of tags of source settings, such as
+// [env:prod, team:web], with the comma separated string of the legacy form,
+// validating each tag. The tags of the legacy form are left as is
+func normalizeTags(settings map[string]interface{}) error {
+	for key, value := range settings {
+		if !strings.EqualFold(key, tagsKey) {
+			continue
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		tags := make([]string, 0, len(list))
+		for _, item := range list {
+			tag, err := cast.ToStringE(item)
+			if err != nil {
+				return fmt.Errorf("invalid tag %v: tags must be strings", item)
+			}
+			if err := validateTag(tag); err != nil {
+				return fmt.Errorf("invalid tag `%s`: %v", tag, err)
+			}
+			tags = append(tags, tag)
+		}
+		settings[key] = strings.Join(tags, ",")
+	}
+	return nil
+}
+
+// validateTag checks that tag is a key:value or a bare value that can be
+// sent as is in the ddtags of the tags payload
+func validateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("tags can't be empty")
+	}
+	if strings.HasPrefix(tag, ":") {
+		return fmt.Errorf("the key of a tag can't be empty")
+	}
+	for _, r := range tag {
+		switch {
+		case r == ',':
+			return fmt.Errorf("tags of a list can't contain commas")
+		case r == '"' || r == ']' || r == '\\':
+			return fmt.Errorf("tags can't contain %q", r)
+		case unicode.IsSpace(r) || !unicode.IsPrint(r):
+			return fmt.Errorf("tags can't contain whitespace or control characters")
+		}
+	}
+	return nil
+}
+
+// normalizeTagString returns the comma separated tags once normalized, before
+// they're sent: whitespace is trimmed and replaced by underscores within tags,
+// keys are lowercased, malformed tags are dropped and duplicates are removed,
+// the first occurrence being kept
+func normalizeTagString(tags string) string {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(tags, ",") {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return strings.Join(normalized, ",")
+}
+
+// normalizeTag returns tag as a key:value or a bare key, or an empty string
+// when it's malformed. The characters the tags payload escapes are kept
+func normalizeTag(tag string) string {
+	tag = strings.Join(strings.Fields(tag), "_")
+	key, value := tag, ""
+	if i := strings.Index(tag, ":"); i >= 0 {
+		key, value = tag[:i], tag[i+1:]
+	}
+	if key == "" {
+		return ""
+	}
+	tag = strings.ToLower(key)
+	if value != "" {
+		tag += ":" + value
+	}
+	for _, r := range tag {
+		if !unicode.IsPrint(r) {
+			return ""
+		}
+	}
+	return tag
+}
@@ -0,0 +1,141 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"bytes"
+	"strings"
+)
+
+// emptyTagsPayload is the nil value of structured data in RFC5424
+var emptyTagsPayload = []byte{'-'}
+
+// A TagsPayloadFormatter builds the bytes array holding the source,
+// source category and tags inserted into messages
+type TagsPayloadFormatter interface {
+	// Format returns the tags payload of a source
+	Format(tags, source, sourceCategory string) []byte
+	// AppendTags returns a copy of a tags payload with additional tags
+	AppendTags(payload []byte, tags string) []byte
+	// AppendAttributes returns a copy of a tags payload with additional attributes
+	AppendAttributes(payload []byte, attributes map[string]string) []byte
+}
+
+// TagsPayloadV1 formats every attribute in its own structured data element:
+// [dd ddsource="nginx"][dd ddtags="env:prod"]
+type TagsPayloadV1 struct{}
+
+// Format implements TagsPayloadFormatter
+func (TagsPayloadV1) Format(tags, source, sourceCategory string) []byte {
+	payload := []byte{}
+	payload = appendV1Element(payload, "ddsource", source)
+	payload = appendV1Element(payload, "ddsourcecategory", sourceCategory)
+	payload = appendV1Element(payload, "ddtags", tags)
+	if len(payload) == 0 {
+		return []byte{'-'}
+	}
+	return payload
+}
+
+// AppendTags implements TagsPayloadFormatter
+func (TagsPayloadV1) AppendTags(payload []byte, tags string) []byte {
+	if isEmptyTagsPayload(payload) {
+		payload = nil
+	}
+	return appendV1Element(append([]byte{}, payload...), "ddtags", tags)
+}
+
+// AppendAttributes implements TagsPayloadFormatter, escaping the values
+// as the attributes are free form
+func (TagsPayloadV1) AppendAttributes(payload []byte, attributes map[string]string) []byte {
+	if len(attributes) == 0 {
+		return payload
+	}
+	if isEmptyTagsPayload(payload) {
+		payload = nil
+	}
+	payload = append([]byte{}, payload...)
+	for _, name := range sortedAttributeNames(attributes) {
+		payload = appendV1Element(payload, name, v2Escaper.Replace(attributes[name]))
+	}
+	return payload
+}
+
+func appendV1Element(payload []byte, name, value string) []byte {
+	if value == "" {
+		return payload
+	}
+	payload = append(payload, []byte("[dd "+name+"=\"")...)
+	payload = append(payload, []byte(value)...)
+	return append(payload, []byte("\"]")...)
+}
+
+// TagsPayloadV2 formats all the attributes in a single structured data element,
+// with values escaped as defined in RFC5424:
+// [dd ddsource="nginx" ddtags="env:prod"]
+type TagsPayloadV2 struct{}
+
+// Format implements TagsPayloadFormatter
+func (TagsPayloadV2) Format(tags, source, sourceCategory string) []byte {
+	params := []byte{}
+	params = appendV2Param(params, "ddsource", source)
+	params = appendV2Param(params, "ddsourcecategory", sourceCategory)
+	params = appendV2Param(params, "ddtags", tags)
+	if len(params) == 0 {
+		return []byte{'-'}
+	}
+	return append(append([]byte("[dd"), params...), ']')
+}
+
+// AppendTags implements TagsPayloadFormatter
+func (f TagsPayloadV2) AppendTags(payload []byte, tags string) []byte {
+	if isEmptyTagsPayload(payload) || !bytes.HasSuffix(payload, []byte{']'}) {
+		return f.Format(tags, "", "")
+	}
+	params := appendV2Param(nil, "ddtags", tags)
+	updated := append([]byte{}, payload[:len(payload)-1]...)
+	return append(append(updated, params...), ']')
+}
+
+// AppendAttributes implements TagsPayloadFormatter
+func (f TagsPayloadV2) AppendAttributes(payload []byte, attributes map[string]string) []byte {
+	if len(attributes) == 0 {
+		return payload
+	}
+	params := []byte{}
+	for _, name := range sortedAttributeNames(attributes) {
+		params = appendV2Param(params, name, attributes[name])
+	}
+	if isEmptyTagsPayload(payload) || !bytes.HasSuffix(payload, []byte{']'}) {
+		return append(append([]byte("[dd"), params...), ']')
+	}
+	updated := append([]byte{}, payload[:len(payload)-1]...)
+	return append(append(updated, params...), ']')
+}
+
+var v2Escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func appendV2Param(params []byte, name, value string) []byte {
+	if value == "" {
+		return params
+	}
+	params = append(params, []byte(" "+name+"=\"")...)
+	params = append(params, []byte(v2Escaper.Replace(value))...)
+	return append(params, '"')
+}
+
+func isEmptyTagsPayload(payload []byte) bool {
+	return len(payload) == 0 || bytes.Equal(payload, emptyTagsPayload)
+}
+
+// GetTagsPayloadFormatter returns the formatter matching the protocol of the intake:
+// the http intake supports the structured format, the tcp intake the bracketed one
+func GetTagsPayloadFormatter() TagsPayloadFormatter {
+	if LogsAgent.GetBool("log_use_http") {
+		return TagsPayloadV2{}
+	}
+	return TagsPayloadV1{}
+}
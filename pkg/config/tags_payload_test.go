@@ -0,0 +1,40 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagsPayloadV1(t *testing.T) {
+	f := TagsPayloadV1{}
+	assert.Equal(t, "-", string(f.Format("", "", "")))
+	assert.Equal(t, `[dd ddsource="nginx"][dd ddtags="env:prod"]`, string(f.Format("env:prod", "nginx", "")))
+	assert.Equal(t, `[dd ddtags="a:b"]`, string(f.AppendTags([]byte("-"), "a:b")))
+	assert.Equal(t, `[dd ddsource="x"][dd ddtags="a:b"]`, string(f.AppendTags([]byte(`[dd ddsource="x"]`), "a:b")))
+	assert.Equal(t, `-`, string(f.AppendAttributes([]byte("-"), nil)))
+	assert.Equal(t, `[dd ddsource="x"][dd owner="web-team"][dd region="\"eu\""]`, string(f.AppendAttributes([]byte(`[dd ddsource="x"]`), map[string]string{"region": `"eu"`, "owner": "web-team"})))
+}
+
+func TestTagsPayloadV2(t *testing.T) {
+	f := TagsPayloadV2{}
+	assert.Equal(t, "-", string(f.Format("", "", "")))
+	assert.Equal(t, `[dd ddsource="nginx" ddsourcecategory="http_access" ddtags="env:prod"]`, string(f.Format("env:prod", "nginx", "http_access")))
+	assert.Equal(t, `[dd ddtags="quote:\"a\\b\]"]`, string(f.Format(`quote:"a\b]`, "", "")))
+	assert.Equal(t, `[dd ddtags="a:b"]`, string(f.AppendTags([]byte("-"), "a:b")))
+	assert.Equal(t, `[dd ddsource="x" ddtags="a:b"]`, string(f.AppendTags([]byte(`[dd ddsource="x"]`), "a:b")))
+	assert.Equal(t, `[dd owner="web-team"]`, string(f.AppendAttributes([]byte("-"), map[string]string{"owner": "web-team"})))
+	assert.Equal(t, `[dd ddsource="x" owner="web-team" region="eu"]`, string(f.AppendAttributes([]byte(`[dd ddsource="x"]`), map[string]string{"region": "eu", "owner": "web-team"})))
+}
+
+func TestGetTagsPayloadFormatter(t *testing.T) {
+	defer LogsAgent.Set("log_use_http", false)
+	assert.Equal(t, TagsPayloadV1{}, GetTagsPayloadFormatter())
+	LogsAgent.Set("log_use_http", true)
+	assert.Equal(t, TagsPayloadV2{}, GetTagsPayloadFormatter())
+}
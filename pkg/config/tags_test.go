@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLogsSourcesWithTagsLists(t *testing.T) {
+	sources, _, err := loadLogsSources(viper.New(), filepath.Join(testsPath, "tags", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sources))
+	assert.Equal(t, "team:web,env:prod,role:frontend", sources[0].Tags)
+	assert.Equal(t, "env:prod,legacy", sources[1].Tags)
+}
+
+func TestNormalizeTags(t *testing.T) {
+	settings := map[string]interface{}{"Tags": []interface{}{"env:prod", "canary", 42}}
+	assert.Nil(t, normalizeTags(settings))
+	assert.Equal(t, "env:prod,canary,42", settings["Tags"])
+
+	settings = map[string]interface{}{"tags": "env:prod, team:web"}
+	assert.Nil(t, normalizeTags(settings))
+	assert.Equal(t, "env:prod, team:web", settings["tags"])
+
+	for _, tag := range []interface{}{"", ":prod", "env:prod,team:web", "env:my prod", `env:"prod"`, "env:prod]", map[string]interface{}{"env": "prod"}} {
+		assert.NotNil(t, normalizeTags(map[string]interface{}{"tags": []interface{}{tag}}), "%v", tag)
+	}
+}
+
+func TestNormalizeTagString(t *testing.T) {
+	assert.Equal(t, "", normalizeTagString(""))
+	assert.Equal(t, "env:prod,team:web", normalizeTagString(" env:prod , team:web,"))
+	assert.Equal(t, "env:Prod,canary", normalizeTagString("ENV:Prod,Canary,env:Prod,canary"))
+	assert.Equal(t, "env:my_prod,service_name:api", normalizeTagString("env:my  prod,service name:api"))
+	assert.Equal(t, "env,quote:\"a\"", normalizeTagString("env:,:prod,quote:\"a\",bell:\a"))
+}
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	templatesKey  = "templates"
+	extendsKey    = "extends"
+	tagsKey       = "tags"
+	rulesKey      = "log_processing_rules"
+	attributesKey = "attributes"
+)
+
+// A sourceTemplate holds the settings shared by the sources extending it
+type sourceTemplate map[string]interface{}
+
+// collectTemplates returns the templates defined in the templates section of
+// the integration configs of files, by name. Template names are case insensitive
+func collectTemplates(files []string, configs map[string]*viper.Viper) (map[string]sourceTemplate, error) {
+	templates := make(map[string]sourceTemplate)
+	definedIn := make(map[string]string)
+	for _, file := range files {
+		for name, settings := range configs[file].GetStringMap(templatesKey) {
+			name = strings.ToLower(name)
+			if other, ok := definedIn[name]; ok {
+				return nil, newFileError(file, fmt.Errorf("template %s is already defined in %s", name, other))
+			}
+			template, err := cast.ToStringMapE(settings)
+			if err != nil {
+				return nil, newFileError(file, fmt.Errorf("invalid template %s: %v", name, err))
+			}
+			if err := normalizeTags(template); err != nil {
+				return nil, newFileError(file, fmt.Errorf("invalid template %s: %v", name, err))
+			}
+			templates[name] = template
+			definedIn[name] = file
+		}
+	}
+	return templates, nil
+}
+
+// collectValidTemplates returns the templates of the files which define valid
+// ones, along with those files and the errors of the others
+func collectValidTemplates(files []string, configs map[string]*viper.Viper) (map[string]sourceTemplate, []string, []error) {
+	errs := []error{}
+	for {
+		templates, err := collectTemplates(files, configs)
+		if err == nil {
+			return templates, files, errs
+		}
+		errs = append(errs, err)
+		cfgErr, ok := err.(*ConfigError)
+		if !ok {
+			return map[string]sourceTemplate{}, files, errs
+		}
+		valid := []string{}
+		for _, file := range files {
+			if file != cfgErr.File {
+				valid = append(valid, file)
+			}
+		}
+		files = valid
+	}
+}
+
+// resolveTemplates returns the settings of source merged over those
+// of the template it extends, if any
+func resolveTemplates(source map[string]interface{}, templates map[string]sourceTemplate) (map[string]interface{}, error) {
+	return resolveExtends(source, templates, nil)
+}
+
+// resolveExtends merges settings over the templates they extend, chain
+// being the names of the templates already met, to detect cycles
+func resolveExtends(settings map[string]interface{}, templates map[string]sourceTemplate, chain []string) (map[string]interface{}, error) {
+	extends, ok := settings[extendsKey]
+	if !ok {
+		return settings, nil
+	}
+	name := strings.ToLower(cast.ToString(extends))
+	for _, met := range chain {
+		if met == name {
+			return nil, newSourceError("templates extend each other: %s", strings.Join(append(chain, name), " -> "))
+		}
+	}
+	template, ok := templates[name]
+	if !ok {
+		return nil, newSourceError("unknown template %s", name)
+	}
+	parent, err := resolveExtends(template, templates, append(chain, name))
+	if err != nil {
+		return nil, err
+	}
+	return mergeSettings(parent, settings), nil
+}
+
+// mergeSettings returns the settings of child overriding those of parent,
+// except for the tags and the attributes, which are combined, and the processing
+// rules, those of child being applied after those of parent
+func mergeSettings(parent, child map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(parent)+len(child))
+	for key, value := range parent {
+		merged[strings.ToLower(key)] = value
+	}
+	for key, value := range child {
+		key = strings.ToLower(key)
+		switch key {
+		case extendsKey:
+			continue
+		case tagsKey:
+			if parentTags := cast.ToString(merged[key]); parentTags != "" {
+				value = parentTags + "," + cast.ToString(value)
+			}
+		case attributesKey:
+			if parentAttributes, ok := merged[key]; ok {
+				attributes := make(map[string]interface{})
+				for name, attribute := range cast.ToStringMap(parentAttributes) {
+					attributes[name] = attribute
+				}
+				for name, attribute := range cast.ToStringMap(value) {
+					attributes[name] = attribute
+				}
+				value = attributes
+			}
+		case rulesKey:
+			if parentRules, ok := merged[key].([]interface{}); ok {
+				value = append(append([]interface{}{}, parentRules...), cast.ToSlice(value)...)
+			}
+		}
+		merged[key] = value
+	}
+	delete(merged, extendsKey)
+	return merged
+}
@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourcesInheritTemplates(t *testing.T) {
+	sources, _, err := loadLogsSources(viper.New(), filepath.Join(testsPath, "templates", "conf.d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sources))
+
+	access := sources[0]
+	assert.Equal(t, FILE_TYPE, access.Type)
+	assert.Equal(t, "/var/log/nginx/access.log", access.Path)
+	assert.Equal(t, "web", access.Service)
+	assert.Equal(t, "nginx", access.Source)
+	assert.Equal(t, "http_access", access.SourceCategory)
+	assert.Equal(t, "env:prod,team:edge", access.Tags)
+	assert.Equal(t, map[string]string{"owner": "web-team", "region": "eu"}, access.Attributes)
+	assert.Equal(t, 1, len(access.ProcessingRules))
+	assert.Equal(t, MULTILINE, access.ProcessingRules[0].Type)
+
+	errors := sources[1]
+	assert.Equal(t, "nginx-errors", errors.Service)
+	assert.Equal(t, map[string]string{"owner": "web-team", "region": "us"}, errors.Attributes)
+	assert.Equal(t, 2, len(errors.ProcessingRules))
+	assert.Equal(t, MULTILINE, errors.ProcessingRules[0].Type)
+	assert.Equal(t, "exclude_debug", errors.ProcessingRules[1].Name)
+}
+
+func TestResolveTemplates(t *testing.T) {
+	templates := map[string]sourceTemplate{
+		"a": {"extends": "b", "service": "a"},
+		"b": {"extends": "a"},
+	}
+	_, err := resolveTemplates(map[string]interface{}{"extends": "c"}, templates)
+	assert.Contains(t, err.Error(), "unknown template c")
+
+	_, err = resolveTemplates(map[string]interface{}{"extends": "A"}, templates)
+	assert.Contains(t, err.Error(), "templates extend each other: a -> b -> a")
+
+	source, err := resolveTemplates(map[string]interface{}{"type": "tcp"}, templates)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"type": "tcp"}, source)
+}
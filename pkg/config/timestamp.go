@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Representations of the timestamps sent to the intake, set by log_timestamp_format
+const (
+	TIMESTAMP_FORMAT_RFC3339_NANO = "rfc3339_nano"
+	TIMESTAMP_FORMAT_EPOCH_MILLIS = "epoch_millis"
+)
+
+// Timestamps authoritative for the messages of a source, set by timestamp_source:
+// the one parsed from the message when it has one, or the time it was received at
+const (
+	TIMESTAMP_PARSED   = "parsed"
+	TIMESTAMP_RECEIVED = "received"
+)
+
+// validateTimestampFormat returns an error when log_timestamp_format is unknown
+func validateTimestampFormat(config *viper.Viper) error {
+	switch format := config.GetString("log_timestamp_format"); format {
+	case TIMESTAMP_FORMAT_RFC3339_NANO, TIMESTAMP_FORMAT_EPOCH_MILLIS:
+		return nil
+	default:
+		return fmt.Errorf("log_timestamp_format must be %s or %s (got %s)", TIMESTAMP_FORMAT_RFC3339_NANO, TIMESTAMP_FORMAT_EPOCH_MILLIS, format)
+	}
+}
+
+// FormatTimestamp returns the representation of timestamp sent to the intake,
+// timestamp being formatted as DateFormat. Timestamps which can't be parsed are
+// sent as they are
+func FormatTimestamp(timestamp string) string {
+	if LogsAgent.GetString("log_timestamp_format") != TIMESTAMP_FORMAT_EPOCH_MILLIS {
+		return timestamp
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// UsesReceiveTime returns true when the messages of the source are stamped
+// with the time they were received at, even when they have their own timestamp,
+// such as sources backfilling logs whose timestamps can't be trusted
+func (s *IntegrationConfigLogSource) UsesReceiveTime() bool {
+	return s.TimestampSource == TIMESTAMP_RECEIVED
+}
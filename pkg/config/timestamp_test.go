@@ -0,0 +1,34 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTimestampFormat(t *testing.T) {
+	config := viper.New()
+	setDefaults(config)
+	assert.Nil(t, validateTimestampFormat(config))
+	config.Set("log_timestamp_format", TIMESTAMP_FORMAT_EPOCH_MILLIS)
+	assert.Nil(t, validateTimestampFormat(config))
+	config.Set("log_timestamp_format", "unix")
+	assert.NotNil(t, validateTimestampFormat(config))
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	defer LogsAgent.Set("log_timestamp_format", TIMESTAMP_FORMAT_RFC3339_NANO)
+	LogsAgent.Set("log_timestamp_format", TIMESTAMP_FORMAT_RFC3339_NANO)
+	assert.Equal(t, "2017-10-16T10:00:00.123456789Z", FormatTimestamp("2017-10-16T10:00:00.123456789Z"))
+
+	LogsAgent.Set("log_timestamp_format", TIMESTAMP_FORMAT_EPOCH_MILLIS)
+	assert.Equal(t, "1508148000123", FormatTimestamp("2017-10-16T10:00:00.123456789Z"))
+	assert.Equal(t, "1508148000000", FormatTimestamp("2017-10-16T10:00:00Z"))
+	assert.Equal(t, "ts", FormatTimestamp("ts"))
+}
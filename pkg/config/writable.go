@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+	"github.com/spf13/viper"
+)
+
+// statelessKey is set when the agent runs without writable run_path
+const statelessKey = "log_stateless"
+
+// IsStateless returns true if the agent can't write its state, in which case
+// it runs without registry, pipeline state snapshot nor spool
+func IsStateless() bool {
+	return LogsAgent.GetBool(statelessKey)
+}
+
+// checkRunPath makes the agent run stateless when run_path, where all its
+// state is written, is not writable, such as in read-only-rootfs containers
+// without volume
+func checkRunPath(config *viper.Viper) {
+	runPath := config.GetString("run_path")
+	err := checkWritable(runPath)
+	if err == nil {
+		return
+	}
+	log.Println("WARNING: run_path is not writable, running stateless: offsets won't survive restarts and logs won't be spooled:", err)
+	config.Set(statelessKey, true)
+	// the hostname is not cached either
+	config.Set("run_path", "")
+	status.Set("stateless", err.Error())
+}
+
+// checkWritable returns an error if files can't be written in dir, creating it if needed
+func checkWritable(dir string) error {
+	if dir == "" {
+		return os.ErrNotExist
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".writable")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
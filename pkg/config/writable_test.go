@@ -0,0 +1,37 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRunPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "run")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config := viper.New()
+	config.Set("run_path", filepath.Join(dir, "run"))
+	checkRunPath(config)
+	assert.False(t, config.GetBool(statelessKey))
+	assert.Equal(t, filepath.Join(dir, "run"), config.GetString("run_path"))
+
+	// a file can't contain the run directory
+	file := filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+	config = viper.New()
+	config.Set("run_path", filepath.Join(file, "run"))
+	checkRunPath(config)
+	assert.True(t, config.GetBool(statelessKey))
+	assert.Equal(t, "", config.GetString("run_path"))
+}
@@ -0,0 +1,207 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+// Paths of the endpoints of the control API
+const (
+	StatusPath  = "/status"
+	SourcesPath = "/sources"
+	// POST on SourcesPath/<id>PausePath pauses a source, and ResumePath resumes it;
+	// DisablePath disables it across restarts, and EnablePath enables it again
+	PausePath   = "/pause"
+	ResumePath  = "/resume"
+	DisablePath = "/disable"
+	EnablePath  = "/enable"
+)
+
+// An Error is the body of the responses of the control API to failed requests
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Errors of a SourcesManager, answered with 409 and 404
+var (
+	ErrSourceExists   = errors.New("a source with the same id is already collected")
+	ErrSourceNotFound = errors.New("no source with this id was added through the control API")
+	ErrUnknownSource  = errors.New("no source with this id is configured")
+)
+
+// A SourcesManager adds sources to the running agent and removes them, returning
+// once they are collected, or an error if they couldn't be started. It pauses and
+// resumes the collection of the sources, their offsets being kept meanwhile, and
+// disables and enables it, the disabled sources staying so across restarts
+type SourcesManager interface {
+	AddSource(source *config.IntegrationConfigLogSource) error
+	RemoveSource(id string) error
+	PauseSource(id string) error
+	ResumeSource(id string) error
+	DisableSource(id string) error
+	EnableSource(id string) error
+}
+
+var (
+	manager      SourcesManager
+	managerMutex sync.Mutex
+)
+
+// SetSourcesManager lets the control API add and remove sources with m, the
+// requests being answered with 503 until the agent sets it
+func SetSourcesManager(m SourcesManager) {
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+	manager = m
+}
+
+func getSourcesManager() SourcesManager {
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+	return manager
+}
+
+// Handler returns the handler of the control API, which serves the status of the
+// agent and the sources it collects as json: POST on SourcesPath adds a source,
+// DELETE on SourcesPath/<id> removes it, and POST on SourcesPath/<id>/pause,
+// /resume, /disable and /enable pauses, resumes, disables and enables it. The
+// requests changing the state of the agent must carry its token, see SetToken
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(StatusPath, get(func() interface{} { return status.Get() }))
+	mux.HandleFunc(SourcesPath, sources)
+	mux.HandleFunc(SourcesPath+"/", source)
+	return mux
+}
+
+// Serve serves the control API on address, returning once it's bound
+func Serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	go http.Serve(listener, Handler())
+	return nil
+}
+
+// get returns a handler answering GET requests with the value of provider
+func get(provider func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			notAllowed(w, r)
+			return
+		}
+		reply(w, http.StatusOK, provider())
+	}
+}
+
+// sources lists the sources on GET and adds one on POST
+func sources(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		reply(w, http.StatusOK, config.DescribeSources())
+	case "POST":
+		addSource(w, r)
+	default:
+		notAllowed(w, r)
+	}
+}
+
+// addSource adds the source described by the json body of r, answering with its
+// description once it's collected, or with the error of its validation
+func addSource(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+	m := getSourcesManager()
+	if m == nil {
+		reply(w, http.StatusServiceUnavailable, Error{Error: "the agent isn't started"})
+		return
+	}
+	var settings map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		reply(w, http.StatusBadRequest, Error{Error: "invalid json: " + err.Error()})
+		return
+	}
+	source, err := config.ParseSource(settings)
+	if err != nil {
+		reply(w, http.StatusBadRequest, Error{Error: err.Error()})
+		return
+	}
+	switch err := m.AddSource(source); err {
+	case nil:
+		reply(w, http.StatusCreated, config.DescribeSource(source))
+	case ErrSourceExists:
+		reply(w, http.StatusConflict, Error{Error: err.Error()})
+	default:
+		reply(w, http.StatusInternalServerError, Error{Error: err.Error()})
+	}
+}
+
+// sourceActions are the actions run on POST on the paths of a source
+var sourceActions = map[string]func(m SourcesManager, id string) error{
+	PausePath:   SourcesManager.PauseSource,
+	ResumePath:  SourcesManager.ResumeSource,
+	DisablePath: SourcesManager.DisableSource,
+	EnablePath:  SourcesManager.EnableSource,
+}
+
+// source removes the source whose id ends the path of r on DELETE, and runs
+// the action of its path on POST
+func source(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, SourcesPath+"/")
+	var action func(m SourcesManager, id string) error
+	switch r.Method {
+	case "POST":
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			action = sourceActions[path[i:]]
+			path = path[:i]
+		}
+	case "DELETE":
+		action = SourcesManager.RemoveSource
+	}
+	if action == nil {
+		notAllowed(w, r)
+		return
+	}
+	if !authorize(w, r) {
+		return
+	}
+	m := getSourcesManager()
+	if m == nil {
+		reply(w, http.StatusServiceUnavailable, Error{Error: "the agent isn't started"})
+		return
+	}
+	switch err := action(m, path); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case ErrSourceNotFound, ErrUnknownSource:
+		reply(w, http.StatusNotFound, Error{Error: err.Error()})
+	default:
+		reply(w, http.StatusInternalServerError, Error{Error: err.Error()})
+	}
+}
+
+// notAllowed answers requests with a method the endpoint doesn't support
+func notAllowed(w http.ResponseWriter, r *http.Request) {
+	reply(w, http.StatusMethodNotAllowed, Error{Error: r.Method + " is not allowed on " + r.URL.Path})
+}
+
+// reply writes value as the json body of a response with code
+func reply(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,226 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package control
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeManager collects the sources added to it
+type fakeManager struct {
+	sources  map[string]*config.IntegrationConfigLogSource
+	paused   map[string]bool
+	disabled map[string]bool
+}
+
+func (m *fakeManager) AddSource(source *config.IntegrationConfigLogSource) error {
+	if _, exists := m.sources[source.ID]; exists {
+		return ErrSourceExists
+	}
+	m.sources[source.ID] = source
+	return nil
+}
+
+func (m *fakeManager) RemoveSource(id string) error {
+	if _, exists := m.sources[id]; !exists {
+		return ErrSourceNotFound
+	}
+	delete(m.sources, id)
+	return nil
+}
+
+func (m *fakeManager) PauseSource(id string) error {
+	return m.setPaused(id, true)
+}
+
+func (m *fakeManager) ResumeSource(id string) error {
+	return m.setPaused(id, false)
+}
+
+func (m *fakeManager) DisableSource(id string) error {
+	return m.setDisabled(id, true)
+}
+
+func (m *fakeManager) EnableSource(id string) error {
+	return m.setDisabled(id, false)
+}
+
+func (m *fakeManager) setDisabled(id string, disabled bool) error {
+	if _, exists := m.sources[id]; !exists {
+		return ErrUnknownSource
+	}
+	m.disabled[id] = disabled
+	return nil
+}
+
+func (m *fakeManager) setPaused(id string, paused bool) error {
+	if _, exists := m.sources[id]; !exists {
+		return ErrUnknownSource
+	}
+	m.paused[id] = paused
+	return nil
+}
+
+const testToken = "secret"
+
+// newRequest returns a request carrying the token and a json content type
+func newRequest(method, path string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, path, body)
+	r.Header.Set(TokenHeader, testToken)
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestHandlerOnlyAnswersGet(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", StatusPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	w = httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("DELETE", StatusPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "{\"error\":\"DELETE is not allowed on /status\"}\n", w.Body.String())
+}
+
+func TestHandlerAddsAndRemovesSources(t *testing.T) {
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, newRequest("POST", SourcesPath, strings.NewReader(body)))
+		return w
+	}
+	remove := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, newRequest("DELETE", SourcesPath+"/"+id, nil))
+		return w
+	}
+
+	SetToken(testToken)
+	defer SetToken("")
+	assert.Equal(t, http.StatusServiceUnavailable, post(`{"type":"tcp","port":10514}`).Code)
+
+	m := &fakeManager{sources: make(map[string]*config.IntegrationConfigLogSource), paused: make(map[string]bool), disabled: make(map[string]bool)}
+	SetSourcesManager(m)
+	defer SetSourcesManager(nil)
+
+	w := post(`{"type":"tcp","port":10514,"service":"app"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"service":"app"`)
+	assert.Equal(t, 1, len(m.sources))
+	assert.Equal(t, http.StatusConflict, post(`{"type":"tcp","port":10514}`).Code)
+
+	w = post(`{"type":"tcp"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "port")
+	assert.Equal(t, http.StatusBadRequest, post(`[`).Code)
+	w = post(`{"type":"command","command":"/bin/sh"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "can't be added at runtime")
+
+	var id string
+	for id = range m.sources {
+	}
+	assert.Equal(t, http.StatusNoContent, remove(id).Code)
+	assert.Equal(t, 0, len(m.sources))
+	assert.Equal(t, http.StatusNotFound, remove(id).Code)
+}
+
+func TestHandlerPausesAndResumesSources(t *testing.T) {
+	post := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, newRequest("POST", path, nil))
+		return w
+	}
+
+	SetToken(testToken)
+	defer SetToken("")
+	assert.Equal(t, http.StatusServiceUnavailable, post(SourcesPath+"/tcp:1"+PausePath).Code)
+
+	m := &fakeManager{sources: make(map[string]*config.IntegrationConfigLogSource), paused: make(map[string]bool), disabled: make(map[string]bool)}
+	m.sources["tcp:1"] = &config.IntegrationConfigLogSource{ID: "tcp:1", Type: config.TCP_TYPE, Port: 10514}
+	SetSourcesManager(m)
+	defer SetSourcesManager(nil)
+
+	assert.Equal(t, http.StatusNoContent, post(SourcesPath+"/tcp:1"+PausePath).Code)
+	assert.True(t, m.paused["tcp:1"])
+	assert.Equal(t, http.StatusNoContent, post(SourcesPath+"/tcp:1"+ResumePath).Code)
+	assert.False(t, m.paused["tcp:1"])
+	assert.Equal(t, http.StatusNoContent, post(SourcesPath+"/tcp:1"+DisablePath).Code)
+	assert.True(t, m.disabled["tcp:1"])
+	assert.Equal(t, http.StatusNoContent, post(SourcesPath+"/tcp:1"+EnablePath).Code)
+	assert.False(t, m.disabled["tcp:1"])
+
+	w := post(SourcesPath + "/tcp:2" + PausePath)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrUnknownSource.Error())
+	assert.Equal(t, http.StatusMethodNotAllowed, post(SourcesPath+"/tcp:1").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, post(SourcesPath+"/tcp:1/stop").Code)
+}
+
+func TestHandlerRequiresTokenToChangeState(t *testing.T) {
+	m := &fakeManager{sources: make(map[string]*config.IntegrationConfigLogSource), paused: make(map[string]bool), disabled: make(map[string]bool)}
+	SetSourcesManager(m)
+	defer SetSourcesManager(nil)
+	body := `{"type":"tcp","port":10514}`
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// without token, the state of the agent can't be changed
+	assert.Equal(t, http.StatusForbidden, serve(newRequest("POST", SourcesPath, strings.NewReader(body))))
+
+	SetToken(testToken)
+	defer SetToken("")
+	r := newRequest("POST", SourcesPath, strings.NewReader(body))
+	r.Header.Set(TokenHeader, "guess")
+	assert.Equal(t, http.StatusUnauthorized, serve(r))
+	r = newRequest("POST", SourcesPath, strings.NewReader(body))
+	r.Header.Del(TokenHeader)
+	assert.Equal(t, http.StatusUnauthorized, serve(r))
+	// the content type of the cross-site forms is refused
+	r = newRequest("POST", SourcesPath, strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/plain")
+	assert.Equal(t, http.StatusUnsupportedMediaType, serve(r))
+	assert.Equal(t, 0, len(m.sources))
+
+	assert.Equal(t, http.StatusCreated, serve(newRequest("POST", SourcesPath, strings.NewReader(body))))
+	// reading the state doesn't require the token
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest("GET", StatusPath, nil)))
+}
+
+func TestWriteAndReadToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, TokenFile)
+
+	token, err := WriteToken(path)
+	assert.Nil(t, err)
+	assert.Len(t, token, 64)
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	read, err := ReadToken(path)
+	assert.Nil(t, err)
+	assert.Equal(t, token, read)
+
+	// each run of the agent has its own token
+	other, err := WriteToken(path)
+	assert.Nil(t, err)
+	assert.NotEqual(t, token, other)
+}
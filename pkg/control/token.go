@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package control
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// TokenHeader carries the token of the requests changing the state of the agent
+	TokenHeader = "X-Logs-Agent-Token"
+	// TokenFile is where the agent writes the token in run_path, readable by its user only
+	TokenFile = "control_token"
+)
+
+var token string
+
+// SetToken sets the token the requests changing the state of the agent must
+// carry in TokenHeader, these requests being refused while it's empty
+func SetToken(t string) {
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+	token = t
+}
+
+func getToken() string {
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+	return token
+}
+
+// WriteToken writes a new random token at path, readable by the user of the
+// agent only, and returns it
+func WriteToken(path string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	t := hex.EncodeToString(b)
+	// the token of a previous run may have been written with other permissions
+	os.Remove(path)
+	if err := ioutil.WriteFile(path, []byte(t), 0600); err != nil {
+		return "", err
+	}
+	return t, nil
+}
+
+// ReadToken returns the token written at path by the agent
+func ReadToken(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("can't read the token of the control API: %v", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// authorize answers the requests changing the state of the agent unless they
+// carry the token and a json content type, which browsers can't send cross-site
+// without the agent allowing it, returning true when r can be served
+func authorize(w http.ResponseWriter, r *http.Request) bool {
+	expected := getToken()
+	if expected == "" {
+		reply(w, http.StatusForbidden, Error{Error: "the control API is read only, the agent has no writable run_path to write its token to"})
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenHeader)), []byte(expected)) != 1 {
+		reply(w, http.StatusUnauthorized, Error{Error: "missing or invalid " + TokenHeader})
+		return false
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		reply(w, http.StatusUnsupportedMediaType, Error{Error: "the content type must be application/json"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package deadletter
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+// Reasons for which a message is written to the dead letter file, besides
+// those of the senders
+const (
+	ReasonInvalidEnvelope = "invalid_envelope"
+	ReasonEncodeError     = "encode_error"
+	ReasonSpoolError      = "spool_error"
+)
+
+// deadLetters counts the messages written to the dead letter file, by reason
+var deadLetters = metrics.NewLabeledCounter("logs_dead_letter_messages")
+
+// A record is a line of the dead letter file, a message with why it was dropped
+// and where it was collected
+type record struct {
+	Time       string `json:"time"`
+	Reason     string `json:"reason"`
+	Source     string `json:"source,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Offset     int64  `json:"offset,omitempty"`
+	Connection string `json:"connection,omitempty"`
+	Message    string `json:"message"`
+}
+
+// A file holds the messages dropped for other reasons than the processing rules,
+// one json record per line, so that they can be replayed after an incident. Once
+// it reaches its maximum size, it's rotated to path.1, replacing the previous one
+type file struct {
+	path    string
+	maxSize int64
+
+	mutex sync.Mutex
+	f     *os.File
+	size  int64
+}
+
+var current struct {
+	sync.Mutex
+	file *file
+}
+
+// Open writes the dropped messages to the dead letter file at path, rotated at maxSize bytes
+func Open(path string, maxSize int64) error {
+	f, err := openFile(path, maxSize)
+	if err != nil {
+		return err
+	}
+	current.Lock()
+	defer current.Unlock()
+	if current.file != nil {
+		current.file.close()
+	}
+	current.file = f
+	return nil
+}
+
+// Close stops writing the dropped messages
+func Close() {
+	current.Lock()
+	defer current.Unlock()
+	if current.file != nil {
+		current.file.close()
+		current.file = nil
+	}
+}
+
+// Write writes msg, dropped for reason, to the dead letter file if any, content
+// being what was dropped of it
+func Write(reason string, msg message.Message, content []byte) {
+	current.Lock()
+	f := current.file
+	current.Unlock()
+	if f == nil {
+		return
+	}
+	r := &record{
+		Time:    time.Now().UTC().Format(time.RFC3339Nano),
+		Reason:  reason,
+		Message: string(content),
+	}
+	if origin := msg.GetOrigin(); origin != nil {
+		if origin.LogSource != nil {
+			r.Source = origin.LogSource.GetID()
+		}
+		r.Path = origin.Path
+		r.Offset = origin.StartOffset
+		r.Connection = origin.ConnectionID
+	}
+	if err := f.write(r); err != nil {
+		log.Println("Can't write to the dead letter file:", err)
+		return
+	}
+	deadLetters.Add(reason, 1)
+}
+
+// openFile opens the dead letter file at path, appending to it
+func openFile(path string, maxSize int64) (*file, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &file{path: path, maxSize: maxSize, f: f, size: info.Size()}, nil
+}
+
+// write appends r to the file, rotating it first when r doesn't fit
+func (f *file) write(r *record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.f == nil {
+		return os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.f.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+// rotate moves the file to path.1 and starts a new one
+func (f *file) rotate() error {
+	f.f.Close()
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		log.Println("Can't rotate the dead letter file:", err)
+	}
+	next, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		f.f = nil
+		return err
+	}
+	f.f = next
+	f.size = 0
+	return nil
+}
+
+// close closes the file
+func (f *file) close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.f != nil {
+		f.f.Close()
+		f.f = nil
+	}
+}
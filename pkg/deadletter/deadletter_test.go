@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package deadletter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFileMessage(content string) message.Message {
+	msg := message.NewFileMessage([]byte(content))
+	origin := message.NewOrigin()
+	origin.LogSource = &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: "/var/log/app.log", ID: "file:/var/log/app.log"}
+	origin.Path = "/var/log/app.log"
+	origin.StartOffset = 42
+	msg.SetOrigin(origin)
+	return msg
+}
+
+func readRecords(t *testing.T, path string) []record {
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	records := []record{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var r record
+		assert.Nil(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletter")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dead_letters.json")
+
+	// disabled
+	Write("oversize", newFileMessage("lost"), []byte("lost"))
+
+	assert.Nil(t, Open(path, 0))
+	defer Close()
+	Write("oversize", newFileMessage("hello"), []byte("hello"))
+	Write(ReasonEncodeError, newFileMessage("world"), []byte("world"))
+
+	records := readRecords(t, path)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "oversize", records[0].Reason)
+	assert.Equal(t, "hello", records[0].Message)
+	assert.Equal(t, "file:/var/log/app.log", records[0].Source)
+	assert.Equal(t, "/var/log/app.log", records[0].Path)
+	assert.Equal(t, int64(42), records[0].Offset)
+	assert.Equal(t, ReasonEncodeError, records[1].Reason)
+}
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletter")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dead_letters.json")
+
+	assert.Nil(t, Open(path, 200))
+	defer Close()
+	for _, content := range []string{"first", "second", "third"} {
+		Write("rejected", newFileMessage(content), []byte(content))
+	}
+
+	rotated := readRecords(t, path+".1")
+	assert.Equal(t, "second", rotated[len(rotated)-1].Message)
+	records := readRecords(t, path)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "third", records[0].Message)
+}
@@ -7,12 +7,18 @@ package decoder
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
 )
 
-// contentLenLimit represents the length limit above which we want to truncate the output content
-var contentLenLimit = 256 * 1000
+// contentLenLimit represents the length limit above which we want to truncate the output content,
+// the default limit of the decoders
+const contentLenLimit = 256 * 1000
+
+// decodeLatency measures the time spent splitting each chunk of raw data into lines
+var decodeLatency = metrics.NewHistogram("logs_decode_latency_seconds", metrics.LatencyBuckets)
 
 // Input represents a list of bytes consumed by the Decoder
 type Input struct {
@@ -51,6 +57,15 @@ type Decoder struct {
 
 	lineBuffer  *bytes.Buffer
 	lineHandler LineHandler
+
+	// lengthPrefix is set when the data is made of length-prefixed records instead of lines
+	lengthPrefix *lengthPrefix
+	// charset is set when the data isn't encoded in UTF-8
+	charset *charset
+	// discard is the number of bytes left to discard of a truncated record
+	discard uint64
+	// lenLimit is the length above which the lines and records are truncated
+	lenLimit int
 }
 
 // InitializeDecoder returns a properly initialized Decoder
@@ -63,13 +78,18 @@ func InitializeDecoder(source *config.IntegrationConfigLogSource) *Decoder {
 		switch rule.Type {
 		case config.MULTILINE:
 			lineHandler = NewMultiLineLineHandler(outputChan, rule.Reg)
+		case config.MULTILINE_CONTINUATION:
+			lineHandler = NewContinuationLineHandler(outputChan, rule.Reg)
 		}
 	}
 	if lineHandler == nil {
 		lineHandler = NewSingleLineHandler(outputChan)
 	}
 
-	return New(inputChan, outputChan, lineHandler)
+	d := New(inputChan, outputChan, lineHandler)
+	d.lengthPrefix = lengthPrefixes[source.Framing]
+	d.charset = charsets[source.Encoding]
+	return d
 }
 
 // New returns an initialized Decoder
@@ -80,6 +100,7 @@ func New(InputChan chan *Input, OutputChan chan *Output, lineHandler LineHandler
 		OutputChan:  OutputChan,
 		lineBuffer:  &lineBuffer,
 		lineHandler: lineHandler,
+		lenLimit:    contentLenLimit,
 	}
 }
 
@@ -96,7 +117,15 @@ func (d *Decoder) Stop() {
 // run lets the Decoder handle data coming from InputChan
 func (d *Decoder) run() {
 	for data := range d.InputChan {
-		d.decodeIncomingData(data.content)
+		start := time.Now()
+		if d.lengthPrefix != nil {
+			d.decodeLengthPrefixedData(data.content)
+		} else if d.charset != nil {
+			d.decodeEncodedData(data.content)
+		} else {
+			d.decodeIncomingData(data.content)
+		}
+		decodeLatency.ObserveSince(start)
 	}
 	// finish to stop decoder
 	d.lineHandler.Stop()
@@ -106,7 +135,7 @@ func (d *Decoder) run() {
 func (d *Decoder) decodeIncomingData(inBuf []byte) {
 	i, j := 0, 0
 	n := len(inBuf)
-	maxj := contentLenLimit - d.lineBuffer.Len()
+	maxj := d.lenLimit - d.lineBuffer.Len()
 
 	for ; j < n; j++ {
 		if j == maxj {
@@ -114,12 +143,12 @@ func (d *Decoder) decodeIncomingData(inBuf []byte) {
 			d.lineBuffer.Write(inBuf[i:j])
 			d.sendLine()
 			i = j
-			maxj = i + contentLenLimit
+			maxj = i + d.lenLimit
 		} else if inBuf[j] == '\n' {
 			d.lineBuffer.Write(inBuf[i:j])
 			d.sendLine()
 			i = j + 1 // +1 as we skip the `\n`
-			maxj = i + contentLenLimit
+			maxj = i + d.lenLimit
 		}
 	}
 	d.lineBuffer.Write(inBuf[i:j])
@@ -130,6 +159,6 @@ func (d *Decoder) sendLine() {
 	content := make([]byte, d.lineBuffer.Len())
 	copy(content, d.lineBuffer.Bytes())
 	d.lineBuffer.Reset()
-	newLine := NewLine(content)
+	newLine := newLine(content, d.lenLimit)
 	d.lineHandler.Handle(newLine)
 }
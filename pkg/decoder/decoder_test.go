@@ -11,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/DataDog/datadog-log-agent/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -199,6 +200,30 @@ func TestDecodeIncomingDataForMultiLineLogs(t *testing.T) {
 	assert.Equal(t, string(TRUNCATED)+strings.Repeat("a", 10), string(out.Content))
 }
 
+func TestDecodeIncomingDataForContinuedLogs(t *testing.T) {
+	inChan := make(chan *Input, 10)
+	outChan := make(chan *Output, 10)
+	re := regexp.MustCompile("^\\s")
+	d := New(inChan, outChan, NewContinuationLineHandler(outChan, re))
+	d.Start()
+
+	var out *Output
+
+	// indented lines are joined to the previous message
+	inChan <- NewInput([]byte("Traceback (most recent call last):\n  File \"main.py\", line 1\n\traise ValueError\nValueError\n"))
+	out = <-outChan
+	assert.Equal(t, "Traceback (most recent call last):\\n  File \"main.py\", line 1\\n\traise ValueError", string(out.Content))
+
+	// the last message is flushed once no more lines are received
+	out = <-outChan
+	assert.Equal(t, "ValueError", string(out.Content))
+
+	// single-line messages are sent one by one
+	inChan <- NewInput([]byte("Hello\nworld!\n"))
+	out = <-outChan
+	assert.Equal(t, "Hello", string(out.Content))
+}
+
 func TestSingleLineDecoderLifecycle(t *testing.T) {
 	inChan := make(chan *Input, 10)
 	outChan := make(chan *Output, 10)
@@ -220,3 +245,47 @@ func TestMultiLineDecoderLifecycle(t *testing.T) {
 	out := <-outChan
 	assert.Equal(t, reflect.TypeOf(out), reflect.TypeOf(newStopOutput()))
 }
+
+func TestDecodeLengthPrefixedData(t *testing.T) {
+	outChan := make(chan *Output, 10)
+	d := New(nil, outChan, NewSingleLineHandler(outChan))
+	d.lengthPrefix = lengthPrefixes["uint16_be"]
+
+	// multiple records in one buffer, records may contain new lines
+	d.decodeLengthPrefixedData([]byte("\x00\x05hello\x00\x0bworld\nagain"))
+	assert.Equal(t, "hello", string((<-outChan).Content))
+	assert.Equal(t, "world\nagain", string((<-outChan).Content))
+	assert.Equal(t, 0, d.lineBuffer.Len())
+
+	// records and headers overflow in the next buffers
+	d.decodeLengthPrefixedData([]byte("\x00"))
+	d.decodeLengthPrefixedData([]byte("\x0ahello"))
+	d.decodeLengthPrefixedData([]byte("world\x00\x02"))
+	assert.Equal(t, "helloworld", string((<-outChan).Content))
+	d.decodeLengthPrefixedData([]byte("ok"))
+	assert.Equal(t, "ok", string((<-outChan).Content))
+
+	d.lengthPrefix = lengthPrefixes["uint32_le"]
+	d.decodeLengthPrefixedData([]byte("\x03\x00\x00\x00abc"))
+	assert.Equal(t, "abc", string((<-outChan).Content))
+}
+
+func TestDecodeLengthPrefixedDataTruncatesLongRecords(t *testing.T) {
+	outChan := make(chan *Output, 10)
+	d := New(nil, outChan, NewSingleLineHandler(outChan))
+	d.lengthPrefix = lengthPrefixes["uint16_le"]
+	d.lenLimit = len(TRUNCATED) + 6
+
+	d.decodeLengthPrefixedData([]byte("\x1e\x00abcde"))
+	assert.Equal(t, "abcde"+string(TRUNCATED), string((<-outChan).Content))
+	// the rest of the record is discarded, even when split over buffers
+	d.decodeLengthPrefixedData([]byte("fghijklmnopqrst"))
+	d.decodeLengthPrefixedData([]byte("uvwxyz0123\x02\x00ok"))
+	assert.Equal(t, "ok", string((<-outChan).Content))
+}
+
+func TestInitializeDecoderWithFraming(t *testing.T) {
+	assert.Nil(t, InitializeDecoder(&config.IntegrationConfigLogSource{}).lengthPrefix)
+	assert.Nil(t, InitializeDecoder(&config.IntegrationConfigLogSource{Framing: config.FRAMING_NEWLINE}).lengthPrefix)
+	assert.Equal(t, 4, InitializeDecoder(&config.IntegrationConfigLogSource{Framing: config.FRAMING_UINT32_BE}).lengthPrefix.size)
+}
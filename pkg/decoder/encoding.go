@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package decoder
+
+import (
+	"bytes"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// A charset is the character encoding of raw data converted to UTF-8
+type charset struct {
+	// unit is the size of the code units, the lines ending with a newline unit
+	unit     int
+	newline  []byte
+	encoding encoding.Encoding
+}
+
+// charsets maps the encodings to their charset, UTF-8 data being used as is.
+// The byte order mark beginning UTF-16 files is stripped
+var charsets = map[string]*charset{
+	config.ENCODING_UTF16_LE:  {2, []byte{'\n', 0}, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)},
+	config.ENCODING_UTF16_BE:  {2, []byte{0, '\n'}, unicode.UTF16(unicode.BigEndian, unicode.UseBOM)},
+	config.ENCODING_LATIN1:    {1, []byte{'\n'}, charmap.ISO8859_1},
+	config.ENCODING_SHIFT_JIS: {1, []byte{'\n'}, japanese.ShiftJIS},
+}
+
+// indexNewline returns the index of the first newline unit of b, -1 if there is none
+func (c *charset) indexNewline(b []byte) int {
+	if c.unit == 1 {
+		// the bytes of the multibyte characters of shift-jis are never '\n'
+		return bytes.IndexByte(b, '\n')
+	}
+	for i := 0; i+c.unit <= len(b); i += c.unit {
+		if bytes.Equal(b[i:i+c.unit], c.newline) {
+			return i
+		}
+	}
+	return -1
+}
+
+// toUTF8 returns raw converted to UTF-8, the invalid sequences being replaced
+func (c *charset) toUTF8(raw []byte) []byte {
+	content, err := c.encoding.NewDecoder().Bytes(raw)
+	if err != nil {
+		// sent as is rather than lost
+		return append([]byte(nil), raw...)
+	}
+	return content
+}
+
+// decodeEncodedData splits raw data encoded in the charset of the decoder into
+// lines, split in their encoding so that the raw data offsets stay exact, and
+// converts them to UTF-8
+func (d *Decoder) decodeEncodedData(inBuf []byte) {
+	d.lineBuffer.Write(inBuf)
+	// the raw lines are limited so that once converted they fit in the length limit
+	maxLen := d.lenLimit / 2
+	maxLen -= maxLen % d.charset.unit
+	for {
+		buf := d.lineBuffer.Bytes()
+		i := d.charset.indexNewline(buf)
+		switch {
+		case i >= 0 && i <= maxLen:
+			d.lineHandler.Handle(&Line{
+				content:    d.charset.toUTF8(buf[:i]),
+				rawDataLen: i + d.charset.unit,
+			})
+			d.lineBuffer.Next(i + d.charset.unit)
+		case len(buf) >= maxLen:
+			// send line because it is too long
+			d.lineHandler.Handle(&Line{
+				content:    d.charset.toUTF8(buf[:maxLen]),
+				rawDataLen: maxLen,
+				truncated:  true,
+			})
+			d.lineBuffer.Next(maxLen)
+		default:
+			return
+		}
+	}
+}
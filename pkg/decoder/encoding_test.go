@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package decoder
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeUTF16Data(t *testing.T) {
+	outChan := make(chan *Output, 10)
+	d := New(nil, outChan, NewSingleLineHandler(outChan))
+	d.charset = charsets[config.ENCODING_UTF16_LE]
+
+	// BOM, "hé\n", then U+010A whose low byte is '\n', split over buffers
+	d.decodeEncodedData([]byte("\xff\xfeh\x00\xe9\x00\n\x00\n\x01"))
+	out := <-outChan
+	assert.Equal(t, "hé", string(out.Content))
+	assert.Equal(t, 8, out.RawDataLen)
+	d.decodeEncodedData([]byte("a"))
+	d.decodeEncodedData([]byte("\x00\n\x00"))
+	out = <-outChan
+	assert.Equal(t, "Ċa", string(out.Content))
+	assert.Equal(t, 6, out.RawDataLen)
+	assert.Equal(t, 0, d.lineBuffer.Len())
+
+	d.charset = charsets[config.ENCODING_UTF16_BE]
+	d.decodeEncodedData([]byte("\x00o\x00k\x00\n"))
+	assert.Equal(t, "ok", string((<-outChan).Content))
+}
+
+func TestDecodeSingleByteAndShiftJISData(t *testing.T) {
+	outChan := make(chan *Output, 10)
+	d := New(nil, outChan, NewSingleLineHandler(outChan))
+	d.charset = charsets[config.ENCODING_LATIN1]
+	d.decodeEncodedData([]byte("caf\xe9\n"))
+	out := <-outChan
+	assert.Equal(t, "café", string(out.Content))
+	assert.Equal(t, 5, out.RawDataLen)
+
+	d.charset = charsets[config.ENCODING_SHIFT_JIS]
+	d.decodeEncodedData([]byte("\x83\x8d\x83\x4f\n"))
+	out = <-outChan
+	assert.Equal(t, "ログ", string(out.Content))
+	assert.Equal(t, 5, out.RawDataLen)
+}
+
+func TestDecodeEncodedDataTruncatesLongLines(t *testing.T) {
+	outChan := make(chan *Output, 10)
+	d := New(nil, outChan, NewSingleLineHandler(outChan))
+	d.charset = charsets[config.ENCODING_UTF16_LE]
+	d.lenLimit = 12
+	d.decodeEncodedData([]byte("a\x00b\x00c\x00d\x00e\x00f\x00g\x00\n\x00"))
+	out := <-outChan
+	assert.Equal(t, "abc"+string(TRUNCATED), string(out.Content))
+	assert.Equal(t, 6, out.RawDataLen)
+	out = <-outChan
+	assert.Equal(t, string(TRUNCATED)+"def"+string(TRUNCATED), string(out.Content))
+	assert.Equal(t, 6, out.RawDataLen)
+	out = <-outChan
+	assert.Equal(t, string(TRUNCATED)+"g", string(out.Content))
+	assert.Equal(t, 4, out.RawDataLen)
+}
+
+func TestInitializeDecoderWithEncoding(t *testing.T) {
+	assert.Nil(t, InitializeDecoder(&config.IntegrationConfigLogSource{}).charset)
+	assert.Nil(t, InitializeDecoder(&config.IntegrationConfigLogSource{Encoding: config.ENCODING_UTF8}).charset)
+	assert.Equal(t, 2, InitializeDecoder(&config.IntegrationConfigLogSource{Encoding: config.ENCODING_UTF16_BE}).charset.unit)
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package decoder
+
+import (
+	"encoding/binary"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+)
+
+// A lengthPrefix is the header of a length-prefixed record
+type lengthPrefix struct {
+	size  int
+	order binary.ByteOrder
+}
+
+// lengthPrefixes maps the length-prefixed framings to their header
+var lengthPrefixes = map[string]*lengthPrefix{
+	config.FRAMING_UINT16_BE: {2, binary.BigEndian},
+	config.FRAMING_UINT16_LE: {2, binary.LittleEndian},
+	config.FRAMING_UINT32_BE: {4, binary.BigEndian},
+	config.FRAMING_UINT32_LE: {4, binary.LittleEndian},
+}
+
+// length returns the length of the record whose header starts b
+func (p *lengthPrefix) length(b []byte) uint64 {
+	if p.size == 2 {
+		return uint64(p.order.Uint16(b))
+	}
+	return uint64(p.order.Uint32(b))
+}
+
+// decodeLengthPrefixedData splits raw data into records preceded by their length.
+// Records too long are truncated, the rest of their content being discarded
+func (d *Decoder) decodeLengthPrefixedData(inBuf []byte) {
+	d.lineBuffer.Write(inBuf)
+	for {
+		if d.discard > 0 {
+			n := d.discard
+			if n > uint64(d.lineBuffer.Len()) {
+				n = uint64(d.lineBuffer.Len())
+			}
+			d.lineBuffer.Next(int(n))
+			d.discard -= n
+			if d.discard > 0 {
+				return
+			}
+		}
+		if d.lineBuffer.Len() < d.lengthPrefix.size {
+			return
+		}
+		length := d.lengthPrefix.length(d.lineBuffer.Bytes())
+		recordLen := length
+		truncated := length >= uint64(d.lenLimit)
+		if truncated {
+			recordLen = uint64(d.lenLimit - len(TRUNCATED) - 1)
+		}
+		if uint64(d.lineBuffer.Len()) < uint64(d.lengthPrefix.size)+recordLen {
+			return
+		}
+		d.lineBuffer.Next(d.lengthPrefix.size)
+		content := make([]byte, recordLen, recordLen+uint64(len(TRUNCATED)))
+		copy(content, d.lineBuffer.Next(int(recordLen)))
+		if truncated {
+			content = append(content, TRUNCATED...)
+		}
+		d.discard = length - recordLen
+		if d.charset != nil {
+			content = d.charset.toUTF8(content)
+		}
+		d.lineHandler.Handle(newLine(content, d.lenLimit))
+	}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // LineBuffer accumulates lines in buffer escaping all '\n'
-// and accumulates the total number of bytes of all lines in line representation (line + '\n') in contentLen
+// and accumulates the total number of bytes of raw data of all lines (line + '\n') in contentLen
 // to form and forward outputs to outputChan
 type LineBuffer struct {
 	outputChan chan *Output
@@ -40,7 +40,7 @@ func (l *LineBuffer) Length() int {
 // Add stores line in buffer
 func (l *LineBuffer) Add(line *Line) {
 	l.buffer.Write(line.content)
-	l.contentLen += len(line.content) + 1 // add 1 for '\n'
+	l.contentLen += line.rawDataLen
 }
 
 // AddEndOfLine stores an escaped '\n' in buffer
@@ -51,7 +51,7 @@ func (l *LineBuffer) AddEndOfLine() {
 // AddIncompleteLine stores a chunck of line in buff
 func (l *LineBuffer) AddIncompleteLine(line *Line) {
 	l.buffer.Write(line.content)
-	l.contentLen += len(line.content)
+	l.contentLen += line.rawDataLen
 }
 
 // AddTruncate stores TRUNCATED in buffer
@@ -17,12 +17,29 @@ var TRUNCATED = []byte("...TRUNCATED...")
 // Line represents content separated by two '\n'
 type Line struct {
 	content []byte
+	// rawDataLen is the number of bytes of raw data the line was decoded from,
+	// its end of line included
+	rawDataLen int
+	// truncated is true when the line was split because it was too long
+	truncated bool
 }
 
-// NewLine returns a new Line
+// NewLine returns a new Line, split at its '\n' or because it was too long
 func NewLine(content []byte) *Line {
+	return newLine(content, contentLenLimit)
+}
+
+// newLine returns a new Line, split at its '\n' or because it reached lenLimit
+func newLine(content []byte, lenLimit int) *Line {
+	truncated := len(content) >= lenLimit
+	rawDataLen := len(content)
+	if !truncated {
+		rawDataLen++ // '\n'
+	}
 	return &Line{
-		content: content,
+		content:    content,
+		rawDataLen: rawDataLen,
+		truncated:  truncated,
 	}
 }
 
@@ -86,14 +103,14 @@ func (lh *SingleLineHandler) process(line *Line) {
 		content = line.content
 	}
 
-	if lineLen < contentLenLimit {
+	if !line.truncated {
 		// send content
-		output := NewOutput(content, lineLen+1) // add 1 to take into account '\n'
+		output := NewOutput(content, line.rawDataLen)
 		lh.outputChan <- output
 	} else {
 		// add TRUNCATED at the end of content and send it
 		content := append(content, TRUNCATED...)
-		output := NewOutput(content, lineLen)
+		output := NewOutput(content, line.rawDataLen)
 		lh.outputChan <- output
 		lh.shouldTruncate = true
 	}
@@ -104,25 +121,41 @@ func (lh *SingleLineHandler) process(line *Line) {
 const flushTimeout = 1 * time.Second
 
 // MultiLineLineHandler reads lines from lineChan and uses lineBuffer to send them
-// when a new line matches with re or flushTimer is fired
+// when a new line starts a new content or flushTimer is fired
 type MultiLineLineHandler struct {
 	lineChan     chan *Line
 	lineBuffer   *LineBuffer
-	newContentRe *regexp.Regexp
+	isNewContent func(content []byte) bool
 	flushTimer   *time.Timer
 	mu           sync.Mutex
 	shouldStop   bool
 }
 
 // NewMultiLineLineHandler returns a new MultiLineLineHandler
+// starting a new content for each line matching newContentRe
 func NewMultiLineLineHandler(outputChan chan *Output, newContentRe *regexp.Regexp) *MultiLineLineHandler {
+	return newMultiLineLineHandler(outputChan, func(content []byte) bool {
+		return newContentRe.Match(content)
+	})
+}
+
+// NewContinuationLineHandler returns a new MultiLineLineHandler
+// joining each line matching continuationRe to the previous content,
+// to handle stack traces whose lines are indented or prefixed
+func NewContinuationLineHandler(outputChan chan *Output, continuationRe *regexp.Regexp) *MultiLineLineHandler {
+	return newMultiLineLineHandler(outputChan, func(content []byte) bool {
+		return !continuationRe.Match(content)
+	})
+}
+
+func newMultiLineLineHandler(outputChan chan *Output, isNewContent func(content []byte) bool) *MultiLineLineHandler {
 	lineChan := make(chan *Line)
 	lineBuffer := NewLineBuffer(outputChan)
 	flushTimer := time.NewTimer(flushTimeout)
 	lineHandler := MultiLineLineHandler{
 		lineChan:     lineChan,
 		lineBuffer:   lineBuffer,
-		newContentRe: newContentRe,
+		isNewContent: isNewContent,
 		flushTimer:   flushTimer,
 	}
 	go lineHandler.start()
@@ -177,10 +210,10 @@ func (lh *MultiLineLineHandler) handleExpiration() {
 	lh.lineBuffer.Stop()
 }
 
-// process accumulates lines in lineBuffer and flushes lineBuffer when a new line matches with newContentRe
+// process accumulates lines in lineBuffer and flushes lineBuffer when a new line starts a new content
 // When lines are too long, they are truncated
 func (lh *MultiLineLineHandler) process(line *Line) {
-	if lh.newContentRe.Match(line.content) {
+	if lh.isNewContent(line.content) {
 		// send content in lineBuffer
 		lh.lineBuffer.Flush()
 	}
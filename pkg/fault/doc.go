@@ -0,0 +1,9 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+// Package fault provides fault injection points to verify the resilience of the agent:
+// dropped intake connections, delayed acknowledgements and corrupted registry.
+// They do nothing unless the agent is built with the faultinjection tag
+package fault
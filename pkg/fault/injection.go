@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build faultinjection
+// +build faultinjection
+
+package fault
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Enabled is true when the fault injection points are compiled in
+const Enabled = true
+
+// Faults are the faults injected in the agent, the zero value injecting none
+type Faults struct {
+	// DropConnectionAfterBytes closes the connections to the intake once they carried that many bytes
+	DropConnectionAfterBytes int64
+	// AckDelay delays the acknowledgement of the messages sent to the auditor
+	AckDelay time.Duration
+	// CorruptRegistry truncates the registry when it is written on disk
+	CorruptRegistry bool
+}
+
+var (
+	mutex  sync.RWMutex
+	faults Faults
+)
+
+// errDroppedConnection is returned by the writes on a dropped connection
+var errDroppedConnection = errors.New("connection dropped by fault injection")
+
+func init() {
+	// faults can be injected in a running agent from its environment
+	var f Faults
+	if v, err := strconv.ParseInt(os.Getenv("DD_LOGS_FAULT_DROP_CONNECTION_AFTER_BYTES"), 10, 64); err == nil {
+		f.DropConnectionAfterBytes = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("DD_LOGS_FAULT_ACK_DELAY")); err == nil {
+		f.AckDelay = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("DD_LOGS_FAULT_CORRUPT_REGISTRY")); err == nil {
+		f.CorruptRegistry = v
+	}
+	if f != (Faults{}) {
+		log.Printf("Injecting faults: %+v", f)
+	}
+	Set(f)
+}
+
+// Set replaces the injected faults
+func Set(f Faults) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	faults = f
+}
+
+// Reset stops injecting faults
+func Reset() {
+	Set(Faults{})
+}
+
+func get() Faults {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return faults
+}
+
+// WrapConn returns conn, dropped once it carried the configured number of bytes
+func WrapConn(conn net.Conn) net.Conn {
+	limit := get().DropConnectionAfterBytes
+	if limit <= 0 {
+		return conn
+	}
+	return &droppingConn{Conn: conn, remaining: limit}
+}
+
+// A droppingConn is closed after a number of bytes were written to it,
+// the last write being partial
+type droppingConn struct {
+	net.Conn
+	remaining int64
+}
+
+// Write writes b until the connection is dropped
+func (c *droppingConn) Write(b []byte) (int, error) {
+	remaining := atomic.LoadInt64(&c.remaining)
+	if remaining <= 0 {
+		return 0, errDroppedConnection
+	}
+	if int64(len(b)) <= remaining {
+		atomic.AddInt64(&c.remaining, -int64(len(b)))
+		return c.Conn.Write(b)
+	}
+	n, _ := c.Conn.Write(b[:remaining])
+	atomic.StoreInt64(&c.remaining, 0)
+	c.Conn.Close()
+	return n, errDroppedConnection
+}
+
+// DelayAck sleeps for the configured delay before a message is acknowledged
+func DelayAck() {
+	if delay := get().AckDelay; delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// CorruptRegistry returns the registry to write on disk, truncated when configured
+func CorruptRegistry(b []byte) []byte {
+	if !get().CorruptRegistry {
+		return b
+	}
+	return b[:len(b)/2]
+}
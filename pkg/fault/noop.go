@@ -0,0 +1,29 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build !faultinjection
+// +build !faultinjection
+
+package fault
+
+import (
+	"net"
+)
+
+// Enabled is true when the fault injection points are compiled in
+const Enabled = false
+
+// WrapConn returns conn
+func WrapConn(conn net.Conn) net.Conn {
+	return conn
+}
+
+// DelayAck returns immediately
+func DelayAck() {}
+
+// CorruptRegistry returns b
+func CorruptRegistry(b []byte) []byte {
+	return b
+}
@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package health
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+// A Phase is a step of the lifecycle of the agent
+type Phase int32
+
+// The phases of the agent, in order
+const (
+	// Initializing while the components are created
+	Initializing Phase = iota
+	// Starting while the components start, the listeners being bound
+	Starting
+	// Ready once all the components started, the listeners accepting connections
+	Ready
+	// Stopping once the agent stops collecting logs
+	Stopping
+)
+
+var phaseNames = map[Phase]string{
+	Initializing: "initializing",
+	Starting:     "starting",
+	Ready:        "ready",
+	Stopping:     "stopping",
+}
+
+// String returns the name of the phase
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+var current int32
+
+func init() {
+	status.Register("lifecycle", func() interface{} { return CurrentPhase().String() })
+}
+
+// SetPhase moves the agent to phase
+func SetPhase(phase Phase) {
+	atomic.StoreInt32(&current, int32(phase))
+}
+
+// CurrentPhase returns the phase of the agent
+func CurrentPhase() Phase {
+	return Phase(atomic.LoadInt32(&current))
+}
+
+// Handler answers 200 once the agent is ready, so that orchestrators only route
+// traffic to its listeners once they're bound, and 503 before and after
+func Handler(w http.ResponseWriter, r *http.Request) {
+	phase := CurrentPhase()
+	w.Header().Set("Content-Type", "application/json")
+	if phase != Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": phase.String()})
+}
+
+// Serve serves the health endpoint at /health on address, returning once it's bound
+func Serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", Handler)
+	go http.Serve(listener, mux)
+	return nil
+}
@@ -0,0 +1,30 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerIsGatedByReadiness(t *testing.T) {
+	defer SetPhase(Initializing)
+	for phase, code := range map[Phase]int{
+		Initializing: http.StatusServiceUnavailable,
+		Starting:     http.StatusServiceUnavailable,
+		Ready:        http.StatusOK,
+		Stopping:     http.StatusServiceUnavailable,
+	} {
+		SetPhase(phase)
+		w := httptest.NewRecorder()
+		Handler(w, httptest.NewRequest("GET", "/health", nil))
+		assert.Equal(t, code, w.Code, phase.String())
+		assert.Equal(t, `{"status":"`+phase.String()+`"}`+"\n", w.Body.String())
+	}
+}
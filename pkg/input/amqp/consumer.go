@@ -0,0 +1,423 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
+)
+
+const (
+	// ioTimeout bounds the time spent connecting and opening the channel
+	ioTimeout = 10 * time.Second
+	// maxHeartbeat is the longest interval in seconds between heartbeats we accept
+	maxHeartbeat = 60
+	// maxFrameSize is the largest frame we accept when the broker sets no limit
+	maxFrameSize = 131072
+	// defaultPrefetchCount is the number of messages the broker sends ahead of the acknowledgements
+	defaultPrefetchCount = 100
+	// defaultRetryPeriod is the time waited before reconnecting to the broker
+	defaultRetryPeriod = 5 * time.Second
+	// consumerChannel is the channel the consumer opens on the connection
+	consumerChannel = 1
+)
+
+// A Consumer receives the messages of the queue of an amqp source, and sends them
+// to its pipeline as logs. Each message is acknowledged once sent to the intake,
+// so that the broker delivers again those lost if the agent stops. The messages
+// the agent gives up on are rejected, to be dead-lettered if the queue is set up for it
+type Consumer struct {
+	source     *config.IntegrationConfigLogSource
+	outputChan chan message.Message
+	tlsConfig  *tls.Config
+
+	retryPeriod time.Duration
+	lastError   string
+
+	// mutex guards conn, written to by the consumer and the acknowledgements
+	mutex   sync.Mutex
+	conn    net.Conn
+	stopped bool
+	stop    chan struct{}
+}
+
+// NewConsumer returns a Consumer of the queue of source, or an error if its TLS files can't be loaded
+func NewConsumer(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (*Consumer, error) {
+	var tlsConfig *tls.Config
+	if source.UseTLS {
+		var err error
+		tlsConfig, err = utils.NewClientTLSConfig(source.Broker, source.TLSCA, source.TLSCert, source.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Consumer{
+		source:      source,
+		outputChan:  outputChan,
+		tlsConfig:   tlsConfig,
+		retryPeriod: defaultRetryPeriod,
+		stop:        make(chan struct{}),
+	}, nil
+}
+
+// Start starts consuming messages
+func (c *Consumer) Start() {
+	go c.run()
+}
+
+// Stop closes the connection to the broker, the messages not yet
+// acknowledged being delivered again on the next connection
+func (c *Consumer) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	close(c.stop)
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// run consumes messages until stopped, reconnecting when the connection is lost
+func (c *Consumer) run() {
+	for {
+		session, err := c.connect()
+		if err == nil {
+			c.setError(nil)
+			err = session.consume()
+		}
+		if c.isStopped() {
+			return
+		}
+		c.setError(err)
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(c.retryPeriod):
+		}
+	}
+}
+
+// A session is a connection to the broker, with its negotiated settings
+type session struct {
+	*Consumer
+	conn      net.Conn
+	reader    *bufio.Reader
+	frameMax  uint32
+	heartbeat uint16
+}
+
+// connect opens a connection, and a channel consuming the queue
+func (c *Consumer) connect() (*session, error) {
+	dialer := &net.Dialer{Timeout: ioTimeout}
+	var conn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.source.Broker, c.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", c.source.Broker)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &session{Consumer: c, conn: conn, reader: bufio.NewReader(conn), frameMax: maxFrameSize}
+	conn.SetDeadline(time.Now().Add(ioTimeout))
+	err = s.open()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stopped {
+		conn.Close()
+		return nil, errors.New("stopped")
+	}
+	c.conn = conn
+	return s, nil
+}
+
+// open negotiates the connection, opens the channel and starts consuming
+func (s *session) open() error {
+	if _, err := s.conn.Write([]byte(protocolHeader)); err != nil {
+		return err
+	}
+
+	d, err := s.expect(0, connectionStart)
+	if err != nil {
+		return err
+	}
+	d.uint8()
+	d.uint8()
+	d.skipTable()
+	if mechanisms := d.longString(); !strings.Contains(mechanisms, "PLAIN") {
+		return fmt.Errorf("PLAIN authentication not supported by the broker (%s)", mechanisms)
+	}
+	response := "\x00" + s.source.Username + "\x00" + s.source.Password
+	args := (&encoder{}).table(map[string]string{"product": "datadog-log-agent"}).shortString("PLAIN").longString(response).shortString("en_US")
+	if err := s.send(newMethodFrame(0, connectionStartOk, args)); err != nil {
+		return err
+	}
+
+	d, err = s.expect(0, connectionTune)
+	if err != nil {
+		return err
+	}
+	channelMax, frameMax, heartbeat := d.uint16(), d.uint32(), d.uint16()
+	if frameMax > 0 && frameMax < s.frameMax {
+		s.frameMax = frameMax
+	}
+	s.heartbeat = heartbeat
+	if s.heartbeat == 0 || s.heartbeat > maxHeartbeat {
+		s.heartbeat = maxHeartbeat
+	}
+	args = (&encoder{}).uint16(channelMax).uint32(s.frameMax).uint16(s.heartbeat)
+	if err := s.send(newMethodFrame(0, connectionTuneOk, args)); err != nil {
+		return err
+	}
+
+	vhost := s.source.VHost
+	if vhost == "" {
+		vhost = "/"
+	}
+	args = (&encoder{}).shortString(vhost).shortString("").uint8(0)
+	if err := s.call(0, connectionOpen, args, connectionOpenOk); err != nil {
+		return err
+	}
+	if err := s.call(consumerChannel, channelOpen, (&encoder{}).shortString(""), channelOpenOk); err != nil {
+		return err
+	}
+
+	prefetchCount := s.source.PrefetchCount
+	if prefetchCount == 0 {
+		prefetchCount = defaultPrefetchCount
+	}
+	args = (&encoder{}).uint32(0).uint16(uint16(prefetchCount)).uint8(0)
+	if err := s.call(consumerChannel, basicQos, args, basicQosOk); err != nil {
+		return err
+	}
+
+	// acknowledgements required, no arguments
+	args = (&encoder{}).uint16(0).shortString(s.source.Queue).shortString("").uint8(0).uint32(0)
+	return s.call(consumerChannel, basicConsume, args, basicConsumeOk)
+}
+
+// call sends the method m on channel and waits for its reply
+func (s *session) call(channel uint16, m method, args *encoder, reply method) error {
+	if err := s.send(newMethodFrame(channel, m, args)); err != nil {
+		return err
+	}
+	_, err := s.expect(channel, reply)
+	return err
+}
+
+// expect reads the next method frame, and returns the decoder of its arguments if it's m
+func (s *session) expect(channel uint16, m method) (*decoder, error) {
+	for {
+		f, err := readFrame(s.reader, s.frameMax)
+		if err != nil {
+			return nil, err
+		}
+		if f.kind == heartbeatFrame {
+			continue
+		}
+		if f.kind != methodFrame || f.channel != channel {
+			return nil, fmt.Errorf("unexpected frame %d on channel %d while waiting for %s", f.kind, f.channel, m)
+		}
+		got, d := methodOf(f)
+		if err := s.checkClose(got, d); err != nil {
+			return nil, err
+		}
+		if got != m {
+			return nil, fmt.Errorf("unexpected method %s while waiting for %s", got, m)
+		}
+		return d, nil
+	}
+}
+
+// checkClose returns the reason given by the broker if m closes the connection or the channel
+func (s *session) checkClose(m method, d *decoder) error {
+	var reply method
+	switch m {
+	case connectionClose:
+		reply = connectionCloseOk
+	case channelClose:
+		reply = channelCloseOk
+	default:
+		return nil
+	}
+	code, text := d.uint16(), d.shortString()
+	channel := uint16(0)
+	if m == channelClose {
+		channel = consumerChannel
+	}
+	s.send(newMethodFrame(channel, reply, nil))
+	return fmt.Errorf("closed by the broker: %d %s", code, text)
+}
+
+// consume receives the messages of the queue until the connection is lost,
+// sending heartbeats so that the broker keeps the connection alive
+func (s *session) consume() error {
+	done := make(chan struct{})
+	defer close(done)
+	go s.beat(done)
+	for {
+		// the broker sends heartbeats too, or closes the connection
+		s.conn.SetReadDeadline(time.Now().Add(2 * time.Duration(s.heartbeat) * time.Second))
+		f, err := readFrame(s.reader, s.frameMax)
+		if err != nil {
+			return err
+		}
+		if f.kind != methodFrame {
+			continue
+		}
+		m, d := methodOf(f)
+		if err := s.checkClose(m, d); err != nil {
+			return err
+		}
+		if m != basicDeliver {
+			continue
+		}
+		d.shortString()
+		tag := d.uint64()
+		if d.err != nil {
+			return d.err
+		}
+		body, err := s.readContent()
+		if err != nil {
+			return err
+		}
+		s.forward(body, tag)
+	}
+}
+
+// readContent reads the header and body frames following a delivery
+func (s *session) readContent() ([]byte, error) {
+	f, err := readFrame(s.reader, s.frameMax)
+	if err != nil {
+		return nil, err
+	}
+	if f.kind != headerFrame {
+		return nil, fmt.Errorf("unexpected frame %d instead of content header", f.kind)
+	}
+	d := &decoder{buf: f.payload}
+	d.uint16()
+	d.uint16()
+	size := d.uint64()
+	if d.err != nil {
+		return nil, d.err
+	}
+	body := make([]byte, 0, size)
+	for uint64(len(body)) < size {
+		f, err := readFrame(s.reader, s.frameMax)
+		if err != nil {
+			return nil, err
+		}
+		if f.kind != bodyFrame {
+			return nil, fmt.Errorf("unexpected frame %d instead of content body", f.kind)
+		}
+		body = append(body, f.payload...)
+	}
+	return body, nil
+}
+
+// forward sends body to the pipeline, to be acknowledged with tag once sent
+func (s *session) forward(body []byte, tag uint64) {
+	content := bytes.TrimRight(body, "\r\n")
+	if len(content) == 0 {
+		s.acknowledge(tag, true)
+		return
+	}
+	msg := message.NewNetworkMessage(content)
+	origin := message.NewOrigin()
+	origin.LogSource = s.source
+	origin.Ack = func(sent bool) {
+		s.acknowledge(tag, sent)
+	}
+	msg.SetOrigin(origin)
+	s.outputChan <- msg
+}
+
+// acknowledge acks the delivery tag when the message was sent, and
+// rejects it without requeuing otherwise. Tags are bound to the connection,
+// so nothing is done if it was lost: the broker delivers the message again
+func (s *session) acknowledge(tag uint64, sent bool) {
+	var f frame
+	if sent {
+		f = newMethodFrame(consumerChannel, basicAck, (&encoder{}).uint64(tag).uint8(0))
+	} else {
+		f = newMethodFrame(consumerChannel, basicReject, (&encoder{}).uint64(tag).uint8(0))
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Consumer.conn != s.conn {
+		return
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+	s.conn.Write(f.encode())
+}
+
+// beat sends a heartbeat every half heartbeat interval, until done is closed
+func (s *session) beat(done chan struct{}) {
+	ticker := time.NewTicker(time.Duration(s.heartbeat) * time.Second / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.send(frame{kind: heartbeatFrame})
+		}
+	}
+}
+
+// send writes f on the connection, shared with the acknowledgements
+func (s *session) send(f frame) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+	_, err := s.conn.Write(f.encode())
+	return err
+}
+
+func (c *Consumer) isStopped() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stopped
+}
+
+// setError logs the connection errors when they change
+func (c *Consumer) setError(err error) {
+	description := ""
+	if err != nil {
+		description = err.Error()
+	}
+	if description == c.lastError {
+		return
+	}
+	c.lastError = description
+	if err != nil {
+		log.Printf("Can't consume logs from amqp queue %s on %s: %v", c.source.Queue, c.source.Broker, err)
+	} else {
+		log.Println("Consuming amqp queue", c.source.Queue, "on", c.source.Broker)
+	}
+}
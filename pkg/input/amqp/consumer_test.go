@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package amqp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBroker accepts one consumer, delivers it two messages and reports their acknowledgements
+func fakeBroker(t *testing.T, listener net.Listener, acks chan method) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	header := make([]byte, len(protocolHeader))
+	io.ReadFull(reader, header)
+	assert.Equal(t, protocolHeader, string(header))
+
+	expect := func(m method) {
+		f, err := readFrame(reader, 0)
+		assert.Nil(t, err)
+		got, _ := methodOf(f)
+		assert.Equal(t, m, got)
+	}
+	reply := func(channel uint16, m method, args *encoder) {
+		conn.Write(newMethodFrame(channel, m, args).encode())
+	}
+
+	reply(0, connectionStart, (&encoder{}).uint8(0).uint8(9).table(nil).longString("PLAIN AMQPLAIN").longString("en_US"))
+	expect(connectionStartOk)
+	reply(0, connectionTune, (&encoder{}).uint16(2047).uint32(131072).uint16(60))
+	expect(connectionTuneOk)
+	expect(connectionOpen)
+	reply(0, connectionOpenOk, (&encoder{}).shortString(""))
+	expect(channelOpen)
+	reply(consumerChannel, channelOpenOk, (&encoder{}).longString(""))
+	expect(basicQos)
+	reply(consumerChannel, basicQosOk, nil)
+	expect(basicConsume)
+	reply(consumerChannel, basicConsumeOk, (&encoder{}).shortString("ctag"))
+
+	for tag, body := range []string{"disk full\n", "disk still full"} {
+		reply(consumerChannel, basicDeliver, (&encoder{}).shortString("ctag").uint64(uint64(tag+1)).uint8(0).shortString("").shortString("logs"))
+		header := (&encoder{}).uint16(60).uint16(0).uint64(uint64(len(body))).uint16(0)
+		conn.Write(frame{kind: headerFrame, channel: consumerChannel, payload: header.buf}.encode())
+		// the body is split in two frames
+		conn.Write(frame{kind: bodyFrame, channel: consumerChannel, payload: []byte(body[:4])}.encode())
+		conn.Write(frame{kind: bodyFrame, channel: consumerChannel, payload: []byte(body[4:])}.encode())
+	}
+
+	for {
+		f, err := readFrame(reader, 0)
+		if err != nil {
+			return
+		}
+		if f.kind == methodFrame {
+			m, _ := methodOf(f)
+			acks <- m
+		}
+	}
+}
+
+func TestConsumerAcknowledgesSentMessages(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	acks := make(chan method, 2)
+	go fakeBroker(t, listener, acks)
+
+	source := &config.IntegrationConfigLogSource{Type: config.AMQP_TYPE, Broker: listener.Addr().String(), Queue: "logs"}
+	outputChan := make(chan message.Message, 2)
+	c, err := NewConsumer(source, outputChan)
+	assert.Nil(t, err)
+	c.Start()
+	defer c.Stop()
+
+	msg := <-outputChan
+	assert.Equal(t, "disk full", string(msg.Content()))
+	msg.GetOrigin().Acknowledge(true)
+	assert.Equal(t, basicAck, <-acks)
+
+	msg = <-outputChan
+	assert.Equal(t, "disk still full", string(msg.Content()))
+	msg.GetOrigin().Acknowledge(false)
+	msg.GetOrigin().Acknowledge(true)
+	assert.Equal(t, basicReject, <-acks)
+	assert.Equal(t, 0, len(acks))
+}
@@ -0,0 +1,208 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package amqp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// protocolHeader opens an AMQP 0-9-1 connection
+const protocolHeader = "AMQP\x00\x00\x09\x01"
+
+// the frame types of AMQP 0-9-1
+const (
+	methodFrame    = 1
+	headerFrame    = 2
+	bodyFrame      = 3
+	heartbeatFrame = 8
+	frameEnd       = 0xce
+)
+
+// a method is identified by its class and its id in the class
+type method struct {
+	class, id uint16
+}
+
+// the methods used by the consumer
+var (
+	connectionStart   = method{10, 10}
+	connectionStartOk = method{10, 11}
+	connectionTune    = method{10, 30}
+	connectionTuneOk  = method{10, 31}
+	connectionOpen    = method{10, 40}
+	connectionOpenOk  = method{10, 41}
+	connectionClose   = method{10, 50}
+	connectionCloseOk = method{10, 51}
+	channelOpen       = method{20, 10}
+	channelOpenOk     = method{20, 11}
+	channelClose      = method{20, 40}
+	channelCloseOk    = method{20, 41}
+	basicQos          = method{60, 10}
+	basicQosOk        = method{60, 11}
+	basicConsume      = method{60, 20}
+	basicConsumeOk    = method{60, 21}
+	basicDeliver      = method{60, 60}
+	basicAck          = method{60, 80}
+	basicReject       = method{60, 90}
+)
+
+func (m method) String() string {
+	return fmt.Sprintf("%d.%d", m.class, m.id)
+}
+
+// A frame is the unit of the AMQP protocol
+type frame struct {
+	kind    byte
+	channel uint16
+	payload []byte
+}
+
+// encode returns the bytes of f on the wire
+func (f frame) encode() []byte {
+	buf := make([]byte, 7, 8+len(f.payload))
+	buf[0] = f.kind
+	binary.BigEndian.PutUint16(buf[1:], f.channel)
+	binary.BigEndian.PutUint32(buf[3:], uint32(len(f.payload)))
+	buf = append(buf, f.payload...)
+	return append(buf, frameEnd)
+}
+
+// readFrame reads the next frame, up to maxSize bytes of payload
+func readFrame(reader *bufio.Reader, maxSize uint32) (frame, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return frame{}, err
+	}
+	size := binary.BigEndian.Uint32(header[3:])
+	if maxSize > 0 && size > maxSize {
+		return frame{}, fmt.Errorf("frame of %d bytes over the negotiated %d", size, maxSize)
+	}
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return frame{}, err
+	}
+	if payload[size] != frameEnd {
+		return frame{}, errors.New("malformed frame")
+	}
+	return frame{kind: header[0], channel: binary.BigEndian.Uint16(header[1:]), payload: payload[:size]}, nil
+}
+
+// newMethodFrame returns the frame calling m with the arguments of args
+func newMethodFrame(channel uint16, m method, args *encoder) frame {
+	payload := (&encoder{}).uint16(m.class).uint16(m.id).buf
+	if args != nil {
+		payload = append(payload, args.buf...)
+	}
+	return frame{kind: methodFrame, channel: channel, payload: payload}
+}
+
+// methodOf returns the method called by f and the decoder of its arguments
+func methodOf(f frame) (method, *decoder) {
+	d := &decoder{buf: f.payload}
+	m := method{d.uint16(), d.uint16()}
+	return m, d
+}
+
+// An encoder builds the arguments of a method
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint8(n uint8) *encoder {
+	e.buf = append(e.buf, n)
+	return e
+}
+
+func (e *encoder) uint16(n uint16) *encoder {
+	e.buf = append(e.buf, byte(n>>8), byte(n))
+	return e
+}
+
+func (e *encoder) uint32(n uint32) *encoder {
+	e.buf = append(e.buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return e
+}
+
+func (e *encoder) uint64(n uint64) *encoder {
+	return e.uint32(uint32(n >> 32)).uint32(uint32(n))
+}
+
+func (e *encoder) shortString(s string) *encoder {
+	e.buf = append(e.buf, byte(len(s)))
+	e.buf = append(e.buf, s...)
+	return e
+}
+
+func (e *encoder) longString(s string) *encoder {
+	e.uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	return e
+}
+
+// table encodes a field table of string values
+func (e *encoder) table(fields map[string]string) *encoder {
+	t := &encoder{}
+	for name, value := range fields {
+		t.shortString(name).uint8('S').longString(value)
+	}
+	e.uint32(uint32(len(t.buf)))
+	e.buf = append(e.buf, t.buf...)
+	return e
+}
+
+// A decoder reads the arguments of a method, err being set when they're truncated
+type decoder struct {
+	buf []byte
+	err error
+}
+
+// next returns the next n bytes
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.buf) < n {
+		d.err = errors.New("truncated frame")
+		// zeros for the numbers, the strings being left empty
+		if n > 8 {
+			return nil
+		}
+		return make([]byte, n)
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	return binary.BigEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.BigEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) uint64() uint64 {
+	return binary.BigEndian.Uint64(d.next(8))
+}
+
+func (d *decoder) shortString() string {
+	return string(d.next(int(d.uint8())))
+}
+
+func (d *decoder) longString() string {
+	return string(d.next(int(d.uint32())))
+}
+
+// skipTable skips a field table
+func (d *decoder) skipTable() {
+	d.next(int(d.uint32()))
+}
@@ -0,0 +1,197 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/decoder"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+)
+
+const (
+	// defaultMinBackoff is the time waited before restarting a command that exited,
+	// doubled on each restart up to defaultMaxBackoff
+	defaultMinBackoff = time.Second
+	// defaultMaxBackoff bounds the time waited before restarting a command, the
+	// commands running longer than it being restarted after defaultMinBackoff again
+	defaultMaxBackoff = time.Minute
+)
+
+// A Supervisor runs the command of a source, restarting it when it exits,
+// and sends the lines of its stdout and stderr to its pipeline
+type Supervisor struct {
+	source     *config.IntegrationConfigLogSource
+	outputChan chan message.Message
+
+	// the messages of stdout and stderr are tagged with their stream
+	stdoutTagsPayload []byte
+	stderrTagsPayload []byte
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mutex   sync.Mutex
+	process *os.Process
+	stopped bool
+	stop    chan struct{}
+}
+
+// NewSupervisor returns a Supervisor of the command of source
+func NewSupervisor(source *config.IntegrationConfigLogSource, outputChan chan message.Message) *Supervisor {
+	return &Supervisor{
+		source:            source,
+		outputChan:        outputChan,
+		stdoutTagsPayload: streamTagsPayload(source, "stdout"),
+		stderrTagsPayload: streamTagsPayload(source, "stderr"),
+		minBackoff:        defaultMinBackoff,
+		maxBackoff:        defaultMaxBackoff,
+		stop:              make(chan struct{}),
+	}
+}
+
+// streamTagsPayload returns the tags payload of the messages of stream
+func streamTagsPayload(source *config.IntegrationConfigLogSource, stream string) []byte {
+	return config.BuildTagsPayload(source, fmt.Sprintf("%s,stream:%s", source.Tags, stream))
+}
+
+// Start starts the command
+func (s *Supervisor) Start() {
+	go s.run()
+}
+
+// Stop kills the command and stops restarting it
+func (s *Supervisor) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	close(s.stop)
+	if s.process != nil {
+		s.process.Kill()
+	}
+}
+
+// run runs the command until stopped, restarting it with an exponential backoff
+func (s *Supervisor) run() {
+	backoff := s.minBackoff
+	for {
+		started := time.Now()
+		err := s.runCommand()
+		if s.isStopped() {
+			return
+		}
+		if time.Since(started) >= s.maxBackoff {
+			// the command was healthy for a while, restart it promptly
+			backoff = s.minBackoff
+		}
+		if err != nil {
+			log.Printf("Command %s failed: %v, restarting in %s", s.source.Command, err, backoff)
+		} else {
+			log.Printf("Command %s exited, restarting in %s", s.source.Command, backoff)
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// runCommand runs the command once, collecting its output until it exits
+func (s *Supervisor) runCommand() error {
+	cmd := exec.Command(s.source.Command, s.source.Args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	if s.stopped {
+		cmd.Process.Kill()
+	}
+	s.process = cmd.Process
+	s.mutex.Unlock()
+
+	// the pipes must be read to their end before waiting for the command
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go s.collect(stdout, config.SEV_INFO, s.stdoutTagsPayload, &wg)
+	go s.collect(stderr, config.SEV_ERROR, s.stderrTagsPayload, &wg)
+	wg.Wait()
+	err = cmd.Wait()
+
+	s.mutex.Lock()
+	s.process = nil
+	s.mutex.Unlock()
+	return err
+}
+
+// collect decodes the lines of stream until it's closed, and forwards them
+func (s *Supervisor) collect(stream io.Reader, severity []byte, tagsPayload []byte, wg *sync.WaitGroup) {
+	defer wg.Done()
+	d := decoder.InitializeDecoder(s.source)
+	d.Start()
+	forwarded := make(chan struct{})
+	go func() {
+		s.forwardMessages(d, severity, tagsPayload)
+		close(forwarded)
+	}()
+	for {
+		inBuf := make([]byte, 4096)
+		n, err := stream.Read(inBuf)
+		if n > 0 {
+			d.InputChan <- decoder.NewInput(inBuf[:n])
+		}
+		if err != nil {
+			d.Stop()
+			<-forwarded
+			return
+		}
+	}
+}
+
+// forwardMessages sends the lines decoded by d to the pipeline
+func (s *Supervisor) forwardMessages(d *decoder.Decoder, severity []byte, tagsPayload []byte) {
+	for output := range d.OutputChan {
+		if output.ShouldStop {
+			return
+		}
+		msg := message.NewCommandMessage(output.Content)
+		origin := message.NewOrigin()
+		origin.LogSource = s.source
+		msg.SetOrigin(origin)
+		msg.SetSeverity(severity)
+		msg.SetTagsPayload(tagsPayload)
+		s.outputChan <- msg
+	}
+}
+
+func (s *Supervisor) isStopped() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stopped
+}
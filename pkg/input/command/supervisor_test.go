@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package command
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervisorCollectsAndRestartsCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	source := &config.IntegrationConfigLogSource{Type: config.COMMAND_TYPE, Command: "sh", Args: []string{"-c", "echo out; echo err >&2"}, Tags: "env:test"}
+	outputChan := make(chan message.Message, 10)
+	s := NewSupervisor(source, outputChan)
+	s.minBackoff = 10 * time.Millisecond
+	s.Start()
+	defer s.Stop()
+
+	// the command exits right away, so it's restarted
+	stdout, stderr := 0, 0
+	for stdout < 2 || stderr < 2 {
+		select {
+		case msg := <-outputChan:
+			switch string(msg.Content()) {
+			case "out":
+				stdout++
+				assert.Equal(t, config.SEV_INFO, msg.GetSeverity())
+				assert.Equal(t, s.stdoutTagsPayload, msg.GetTagsPayload())
+			case "err":
+				stderr++
+				assert.Equal(t, config.SEV_ERROR, msg.GetSeverity())
+				assert.Equal(t, s.stderrTagsPayload, msg.GetTagsPayload())
+			default:
+				assert.Fail(t, "unexpected message", string(msg.Content()))
+			}
+			assert.Equal(t, source, msg.GetOrigin().LogSource)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "the command wasn't restarted")
+			return
+		}
+	}
+	assert.Contains(t, string(s.stdoutTagsPayload), "stream:stdout")
+	assert.Contains(t, string(s.stderrTagsPayload), "stream:stderr")
+}
+
+func TestSupervisorStopKillsCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	source := &config.IntegrationConfigLogSource{Type: config.COMMAND_TYPE, Command: "sh", Args: []string{"-c", "echo started; exec sleep 60"}}
+	outputChan := make(chan message.Message, 10)
+	s := NewSupervisor(source, outputChan)
+	s.Start()
+	select {
+	case msg := <-outputChan:
+		assert.Equal(t, "started", string(msg.Content()))
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the command wasn't started")
+	}
+
+	s.Stop()
+	for i := 0; i < 100 && s.runningProcess() != nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Nil(t, s.runningProcess())
+}
+
+// runningProcess returns the process of the command while it runs
+func (s *Supervisor) runningProcess() *os.Process {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.process
+}
@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package container
+
+import (
+	"log"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/docker/docker/api/types"
+)
+
+// A discoveredSource is the source read from the labels of a container,
+// nil when they are invalid, parsed once for the lifetime of the container
+type discoveredSource struct {
+	source *config.IntegrationConfigLogSource
+}
+
+// discoveredSourceOf returns the source described by the labels of container,
+// nil when autodiscovery is disabled or the container has no valid log config
+func (c *ContainerInput) discoveredSourceOf(container types.Container) *config.IntegrationConfigLogSource {
+	if !config.IsAutodiscoveryEnabled() {
+		return nil
+	}
+	value, ok := container.Labels[config.AutodiscoveryLogsLabel]
+	if !ok {
+		return nil
+	}
+	if discovered, ok := c.discovered[container.ID]; ok {
+		return discovered.source
+	}
+	source, err := config.ParseAutodiscoveryLogs(value, container.Image)
+	if err != nil {
+		log.Println("Ignoring the log config of container", c.HumanReadableContainerId(container.ID), ":", err)
+	} else {
+		log.Println("Discovered the log config of container", c.HumanReadableContainerId(container.ID))
+	}
+	c.discovered[container.ID] = &discoveredSource{source: source}
+	return source
+}
+
+// forgetDiscoveredSources drops the sources of the containers no longer running
+func (c *ContainerInput) forgetDiscoveredSources(runningContainers []types.Container) {
+	running := make(map[string]bool, len(runningContainers))
+	for _, container := range runningContainers {
+		running[container.ID] = true
+	}
+	for id := range c.discovered {
+		if !running[id] {
+			delete(c.discovered, id)
+		}
+	}
+}
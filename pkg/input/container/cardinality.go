@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package container
+
+import (
+	"strings"
+)
+
+// Tag cardinalities, controlling which container metadata become tags
+const (
+	LowCardinality          = "low"
+	OrchestratorCardinality = "orchestrator"
+	HighCardinality         = "high"
+)
+
+// orchestratorTagNames are the tags identifying an orchestrator unit of work,
+// one value per pod or task, kept from the orchestrator cardinality
+var orchestratorTagNames = map[string]bool{
+	"pod_name":           true,
+	"pod_uid":            true,
+	"kube_pod_uid":       true,
+	"kube_ownerref_name": true,
+	"oshift_deployment":  true,
+	"task_arn":           true,
+}
+
+// highCardinalityTagNames are the tags identifying a single container,
+// kept only at high cardinality
+var highCardinalityTagNames = map[string]bool{
+	"container_id":           true,
+	"container_name":         true,
+	"display_container_name": true,
+}
+
+// isValidCardinality returns true if cardinality is supported
+func isValidCardinality(cardinality string) bool {
+	switch cardinality {
+	case LowCardinality, OrchestratorCardinality, HighCardinality:
+		return true
+	default:
+		return false
+	}
+}
+
+// filterTags returns the tags allowed at cardinality
+func filterTags(tags []string, cardinality string) []string {
+	if cardinality == HighCardinality {
+		return tags
+	}
+	filtered := []string{}
+	for _, tag := range tags {
+		name := strings.SplitN(tag, ":", 2)[0]
+		if highCardinalityTagNames[name] {
+			continue
+		}
+		if cardinality == LowCardinality && orchestratorTagNames[name] {
+			continue
+		}
+		filtered = append(filtered, tag)
+	}
+	return filtered
+}
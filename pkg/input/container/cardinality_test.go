@@ -0,0 +1,26 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterTags(t *testing.T) {
+	tags := []string{"image_name:redis", "pod_name:redis-1", "kube_pod_uid:1234", "container_id:abcd"}
+	assert.Equal(t, tags, filterTags(tags, HighCardinality))
+	assert.Equal(t, []string{"image_name:redis", "pod_name:redis-1", "kube_pod_uid:1234"}, filterTags(tags, OrchestratorCardinality))
+	assert.Equal(t, []string{"image_name:redis"}, filterTags(tags, LowCardinality))
+}
+
+func TestIsValidCardinality(t *testing.T) {
+	assert.True(t, isValidCardinality(LowCardinality))
+	assert.True(t, isValidCardinality(OrchestratorCardinality))
+	assert.True(t, isValidCardinality(HighCardinality))
+	assert.False(t, isValidCardinality("medium"))
+}
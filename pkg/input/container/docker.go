@@ -14,6 +14,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/tagger"
@@ -39,14 +40,22 @@ const messageHeaderLength = 8
 // With docker api, there is no way to know if a log comes from strout or stderr
 // so if we want to capture the severity, we need to tail both in two goroutines
 type DockerTailer struct {
-	containerId   string
-	outputChan    chan message.Message
-	d             *decoder.Decoder
-	reader        io.ReadCloser
-	cli           *client.Client
-	source        *config.IntegrationConfigLogSource
-	containerTags []string
-	tagsPayload   []byte
+	containerId    string
+	outputChan     chan message.Message
+	d              *decoder.Decoder
+	reader         io.ReadCloser
+	cli            *client.Client
+	source         *config.IntegrationConfigLogSource
+	containerTags  []string
+	tagsPayload    []byte
+	tagCardinality string
+	service        string
+	// sequence is the number of the last message when the source is numbered
+	sequence uint64
+	// lastTimestamp is the docker timestamp of the last message forwarded,
+	// the cursor the tailing resumes from when the tailer is restarted
+	lastTimestamp string
+	cursorMutex   sync.Mutex
 
 	sleepDuration time.Duration
 	shouldStop    bool
@@ -61,10 +70,22 @@ func NewDockerTailer(cli *client.Client, container types.Container, source *conf
 		source:      source,
 		cli:         cli,
 
-		sleepDuration: defaultSleepDuration,
+		tagCardinality: tagCardinality(),
+		service:        container.Labels[source.ServiceLabel],
+		sleepDuration:  defaultSleepDuration,
 	}
 }
 
+// tagCardinality returns the configured cardinality of the container tags
+func tagCardinality() string {
+	cardinality := config.LogsAgent.GetString("log_tag_cardinality")
+	if !isValidCardinality(cardinality) {
+		log.Println("Invalid tag cardinality", cardinality, "- using", HighCardinality)
+		return HighCardinality
+	}
+	return cardinality
+}
+
 // Identifier returns a string that uniquely identifies a source
 func (dt *DockerTailer) Identifier() string {
 	return fmt.Sprintf("docker:%s", dt.containerId)
@@ -91,9 +112,21 @@ func (dt *DockerTailer) tailFromEnd() error {
 // recoverTailing starts the tailing from the last log line processed, or now
 // if we see this container for the first time
 func (dt *DockerTailer) recoverTailing(a *auditor.Auditor) error {
+	dt.sequence = a.GetLastCommitedSequence(dt.Identifier())
 	return dt.tailFrom(dt.nextLogSinceDate(a.GetLastCommitedTimestamp(dt.Identifier())))
 }
 
+// cursor returns the `since` date resuming the tailing after the last message
+// forwarded, or "" if no message was forwarded
+func (dt *DockerTailer) cursor() string {
+	dt.cursorMutex.Lock()
+	defer dt.cursorMutex.Unlock()
+	if dt.lastTimestamp == "" {
+		return ""
+	}
+	return dt.nextLogSinceDate(dt.lastTimestamp)
+}
+
 // nextLogSinceDate returns the `from` value of the next log line
 // for a container.
 // In the auditor, we store the date of the last log line processed.
@@ -158,8 +191,10 @@ func (dt *DockerTailer) readForever() {
 			continue
 		}
 		if err != nil {
-			log.Println("Err:", err)
-			return
+			// let the scanner restart the tailer from its cursor
+			log.Println("Can't read the logs of container", dt.containerId[:12], "-", err)
+			dt.shouldStop = true
+			continue
 		}
 		if n == 0 {
 			dt.wait()
@@ -187,13 +222,22 @@ func (dt *DockerTailer) forwardMessages() {
 			continue
 		}
 
+		dt.cursorMutex.Lock()
+		dt.lastTimestamp = ts
+		dt.cursorMutex.Unlock()
+
 		containerMsg := message.NewContainerMessage(updatedMsg)
 		msgOrigin := message.NewOrigin()
 		msgOrigin.LogSource = dt.source
 		msgOrigin.Timestamp = ts
 		msgOrigin.Identifier = dt.Identifier()
+		if dt.source.SequenceNumbers {
+			dt.sequence++
+			msgOrigin.SourceSequence = dt.sequence
+		}
 		containerMsg.SetSeverity(sev)
 		containerMsg.SetTagsPayload(dt.tagsPayload)
+		containerMsg.SetService(dt.service)
 		containerMsg.SetOrigin(msgOrigin)
 		dt.outputChan <- containerMsg
 	}
@@ -211,10 +255,12 @@ func (dt *DockerTailer) keepDockerTagsUpdated() {
 }
 
 func (dt *DockerTailer) checkForNewDockerTags() {
-	tags, err := tagger.Tag(dockerutil.ContainerIDToEntityName(dt.containerId), true)
+	highCardinality := dt.tagCardinality != LowCardinality
+	tags, err := tagger.Tag(dockerutil.ContainerIDToEntityName(dt.containerId), highCardinality)
 	if err != nil {
 		log.Println(err)
 	} else {
+		tags = filterTags(tags, dt.tagCardinality)
 		if !reflect.DeepEqual(tags, dt.containerTags) {
 			dt.containerTags = tags
 			dt.tagsPayload = dt.buildTagsPayload()
@@ -224,7 +270,7 @@ func (dt *DockerTailer) checkForNewDockerTags() {
 
 func (dt *DockerTailer) buildTagsPayload() []byte {
 	tagsString := fmt.Sprintf("%s,%s", strings.Join(dt.containerTags, ","), dt.source.Tags)
-	return config.BuildTagsPayload(tagsString, dt.source.Source, dt.source.SourceCategory)
+	return config.BuildTagsPayload(dt.source, tagsString)
 }
 
 // parseMessage extracts the date and the severity from the raw docker message
@@ -55,6 +55,12 @@ func (suite *DockerTailerTestSuite) TestDockerTailerRemovesDate() {
 	suite.Equal("2008-01-12T01:01:01.000000000Z", ts)
 }
 
+func (suite *DockerTailerTestSuite) TestDockerTailerCursor() {
+	suite.Equal("", suite.tailer.cursor())
+	suite.tailer.lastTimestamp = "2008-01-12T01:01:01.000000000Z"
+	suite.Equal("2008-01-12T01:01:01.000000001Z", suite.tailer.cursor())
+}
+
 func (suite *DockerTailerTestSuite) TestDockerTailerNextLogSinceDate() {
 	suite.Equal("2008-01-12T01:01:01.000000001Z", suite.tailer.nextLogSinceDate("2008-01-12T01:01:01.000000000Z"))
 	suite.Equal("2008-01-12T01:01:01.anything", suite.tailer.nextLogSinceDate("2008-01-12T01:01:01.anything"))
@@ -69,10 +75,14 @@ func (suite *DockerTailerTestSuite) TestDockerTailerIdentifier() {
 func (suite *DockerTailerTestSuite) TestBuildTagsPayload() {
 	suite.tailer.containerTags = []string{"test", "hello:world"}
 	suite.tailer.source = &config.IntegrationConfigLogSource{Source: "mysource", Tags: "sourceTags"}
-	suite.Equal("[dd ddsource=\"mysource\"][dd ddtags=\"test,hello:world,sourceTags\"]", string(suite.tailer.buildTagsPayload()))
+	suite.Equal("[dd ddsource=\"mysource\"][dd ddtags=\"test,hello:world,sourcetags\"]", string(suite.tailer.buildTagsPayload()))
 
 	suite.tailer.source = &config.IntegrationConfigLogSource{}
-	suite.Equal("[dd ddtags=\"test,hello:world,\"]", string(suite.tailer.buildTagsPayload()))
+	suite.Equal("[dd ddtags=\"test,hello:world\"]", string(suite.tailer.buildTagsPayload()))
+
+	// the tags of the container and of the source are deduplicated
+	suite.tailer.source = &config.IntegrationConfigLogSource{Tags: "Hello:world"}
+	suite.Equal("[dd ddtags=\"test,hello:world\"]", string(suite.tailer.buildTagsPayload()))
 }
 
 func (suite *DockerTailerTestSuite) TestParseMessage() {
@@ -9,6 +9,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/tagger"
@@ -31,6 +33,18 @@ type ContainerInput struct {
 	tailers map[string]*DockerTailer
 	cli     *client.Client
 	auditor *auditor.Auditor
+	// discovered holds the sources read from the labels of the containers, by container id
+	discovered map[string]*discoveredSource
+	// unreadable holds the ids of the containers whose logging driver doesn't
+	// support reading their logs, not to retry them on every scan
+	unreadable map[string]bool
+	// mutex guards the tailers between the scans and the sources stopped or
+	// started while the input runs, running once the containers are listed
+	mutex   sync.Mutex
+	running bool
+
+	startupErrors int
+	stop          chan struct{}
 }
 
 // New returns an initialized ContainerInput
@@ -50,47 +64,110 @@ func New(sources []*config.IntegrationConfigLogSource, pp *pipeline.PipelineProv
 		sources: containerSources,
 		tailers: make(map[string]*DockerTailer),
 		auditor: a,
+		stop:    make(chan struct{}),
+
+		discovered: make(map[string]*discoveredSource),
+		unreadable: make(map[string]bool),
 	}
 }
 
 // Start starts the ContainerInput
 func (c *ContainerInput) Start() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	err := c.setup()
 	if err == nil {
+		c.running = true
 		go c.run()
+	} else if len(c.sources) > 0 {
+		c.startupErrors++
+	}
+}
+
+// StartSource starts tailing the containers of source, unless it's of another
+// type or already tailed. The containers are listed again at once
+func (c *ContainerInput) StartSource(source *config.IntegrationConfigLogSource) {
+	if source.Type != config.DOCKER_TYPE {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, s := range c.sources {
+		if s.GetID() == source.GetID() {
+			return
+		}
+	}
+	c.sources = append(c.sources, source)
+	if c.running {
+		c.scan()
+	} else if err := c.setup(); err == nil {
+		// the input had no source to start with
+		c.running = true
+		go c.run()
+	}
+}
+
+// StopSource stops tailing the containers of the source with id, those
+// matching another source being tailed by it from the next scan
+func (c *ContainerInput) StopSource(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	sources := c.sources[:0]
+	for _, source := range c.sources {
+		if source.GetID() != id {
+			sources = append(sources, source)
+		}
+	}
+	c.sources = sources
+	for _, tailer := range c.tailers {
+		if tailer.source.GetID() == id {
+			c.stopTailer(tailer)
+		}
 	}
 }
 
 // run lets the ContainerInput tail docker stdouts
 func (c *ContainerInput) run() {
 	ticker := time.NewTicker(scanPeriod)
-	for _ = range ticker.C {
-		c.scan(true)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.mutex.Lock()
+			c.scan()
+			c.mutex.Unlock()
+		}
 	}
 }
 
 // scan checks for new containers we're expected to
 // tail, as well as stopped containers or containers that
 // restarted
-func (c *ContainerInput) scan(tailFromBegining bool) {
+func (c *ContainerInput) scan() {
 	runningContainers := c.listContainers()
 	containersToMonitor := make(map[string]bool)
 
 	// monitor new containers, and restart tailers if needed
 	for _, container := range runningContainers {
-		for _, source := range c.sources {
-			if c.sourceShouldMonitorContainer(source, container) {
-				containersToMonitor[container.ID] = true
-
-				tailer, isTailed := c.tailers[container.ID]
-				if isTailed && tailer.shouldStop {
-					c.stopTailer(tailer)
-					isTailed = false
-				}
-				if !isTailed {
-					c.setupTailer(c.cli, container, source, tailFromBegining, c.pp.NextPipelineChan())
-				}
-			}
+		source := c.sourceOf(container)
+		if source == nil || c.unreadable[container.ID] {
+			continue
+		}
+		containersToMonitor[container.ID] = true
+
+		tailer, isTailed := c.tailers[container.ID]
+		cursor := ""
+		if isTailed && tailer.shouldStop {
+			// resume after the last message forwarded rather than the last one
+			// committed, the messages in flight being sent anyway
+			cursor = tailer.cursor()
+			c.stopTailer(tailer)
+			isTailed = false
+		}
+		if !isTailed {
+			c.setupTailer(c.cli, container, source, cursor, c.pp.NextPipelineChan())
 		}
 	}
 
@@ -101,6 +178,22 @@ func (c *ContainerInput) scan(tailFromBegining bool) {
 			c.stopTailer(tailer)
 		}
 	}
+	c.forgetDiscoveredSources(runningContainers)
+	c.forgetUnreadableContainers(runningContainers)
+}
+
+// sourceOf returns the source of container: the one of its labels when
+// autodiscovery is enabled, or the first source matching it, nil if none
+func (c *ContainerInput) sourceOf(container types.Container) *config.IntegrationConfigLogSource {
+	if source := c.discoveredSourceOf(container); source != nil {
+		return source
+	}
+	for _, source := range c.sources {
+		if c.sourceShouldMonitorContainer(source, container) {
+			return source
+		}
+	}
+	return nil
 }
 
 func (c *ContainerInput) stopTailer(tailer *DockerTailer) {
@@ -132,20 +225,20 @@ func (c *ContainerInput) sourceShouldMonitorContainer(source *config.Integration
 
 // Start starts the ContainerInput
 func (c *ContainerInput) setup() error {
-	if len(c.sources) == 0 {
+	if len(c.sources) == 0 && !config.IsAutodiscoveryEnabled() {
 		return fmt.Errorf("No container source defined")
 	}
 
 	// List available containers
 
-	cli, err := client.NewEnvClient()
-	// Docker's api updates quickly and is pretty unstable, best pinpoint it
-	cli.UpdateClientVersion(DOCKER_API_VERSION)
+	runtime, host := config.ResolveContainerRuntime()
+	cli, err := newClient(host)
 	c.cli = cli
 	if err != nil {
 		log.Println("Can't tail containers,", err)
 		return fmt.Errorf("Can't initialize client")
 	}
+	log.Println("Tailing the containers of", runtime)
 
 	// Initialize docker utils
 	err = tagger.Init()
@@ -154,28 +247,79 @@ func (c *ContainerInput) setup() error {
 	}
 
 	// Start tailing monitored containers
-	c.scan(false)
+	c.scan()
 	return nil
 }
 
-// setupTailer sets one tailer, making it tail from the begining or the end
-func (c *ContainerInput) setupTailer(cli *client.Client, container types.Container, source *config.IntegrationConfigLogSource, tailFromBegining bool, outputChan chan message.Message) {
+// newClient returns the client of the docker API served at host, podman serving
+// a compatible one, or of the docker daemon of the environment when host is empty
+func newClient(host string) (*client.Client, error) {
+	if host != "" {
+		return client.NewClient(host, DOCKER_API_VERSION, nil, nil)
+	}
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, err
+	}
+	// Docker's api updates quickly and is pretty unstable, best pinpoint it
+	cli.UpdateClientVersion(DOCKER_API_VERSION)
+	return cli, nil
+}
+
+// setupTailer sets one tailer, making it tail from cursor when set, else from
+// the cursor of the registry, or from the beginning for the new containers
+func (c *ContainerInput) setupTailer(cli *client.Client, container types.Container, source *config.IntegrationConfigLogSource, cursor string, outputChan chan message.Message) {
 	log.Println("Detected container", container.Image, "-", c.HumanReadableContainerId(container.ID))
 	t := NewDockerTailer(cli, container, source, outputChan)
 	var err error
-	if tailFromBegining {
-		err = t.tailFromBegining()
+	if cursor != "" {
+		err = t.tailFrom(cursor)
 	} else {
 		err = t.recoverTailing(c.auditor)
 	}
+	if isUnreadableLogsError(err) {
+		log.Println("Can't tail container", c.HumanReadableContainerId(container.ID), "- its logging driver doesn't support reading logs, use json-file, local or journald")
+		t.Stop()
+		c.unreadable[container.ID] = true
+		return
+	}
 	if err != nil {
 		log.Println(err)
+		// retried on next scan
+		t.shouldStop = true
 	}
 	c.tailers[container.ID] = t
 }
 
+// isUnreadableLogsError returns true if err is the error of the docker daemon
+// for the logging drivers not supporting reading, such as syslog or fluentd
+func isUnreadableLogsError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "does not support reading")
+}
+
+// forgetUnreadableContainers forgets the unreadable containers that are no longer running
+func (c *ContainerInput) forgetUnreadableContainers(running []types.Container) {
+	isRunning := make(map[string]bool)
+	for _, container := range running {
+		isRunning[container.ID] = true
+	}
+	for id := range c.unreadable {
+		if !isRunning[id] {
+			delete(c.unreadable, id)
+		}
+	}
+}
+
+// StartupErrors returns 1 if the containers couldn't be listed on start
+func (c *ContainerInput) StartupErrors() int {
+	return c.startupErrors
+}
+
 // Stop stops the ContainerInput and its tailers
 func (c *ContainerInput) Stop() {
+	close(c.stop)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	for _, t := range c.tailers {
 		t.Stop()
 	}
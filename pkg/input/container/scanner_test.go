@@ -6,9 +6,11 @@
 package container
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
 
 	"github.com/docker/docker/api/types"
 	"github.com/stretchr/testify/suite"
@@ -20,7 +22,7 @@ type ContainerScannerTestSuite struct {
 }
 
 func (suite *ContainerScannerTestSuite) SetupTest() {
-	suite.c = &ContainerInput{}
+	suite.c = &ContainerInput{discovered: make(map[string]*discoveredSource), unreadable: make(map[string]bool)}
 }
 
 func (suite *ContainerScannerTestSuite) TestContainerScannerFilter() {
@@ -43,6 +45,52 @@ func (suite *ContainerScannerTestSuite) TestContainerScannerFilter() {
 	suite.True(suite.c.sourceShouldMonitorContainer(cfg, container))
 }
 
+func (suite *ContainerScannerTestSuite) TestContainerScannerDiscoversSourcesFromLabels() {
+	defer config.LogsAgent.Set("log_container_autodiscovery", false)
+	static := &config.IntegrationConfigLogSource{Type: config.DOCKER_TYPE, Service: "static"}
+	suite.c.sources = []*config.IntegrationConfigLogSource{static}
+	labels := map[string]string{config.AutodiscoveryLogsLabel: `[{"source":"nginx","service":"web"}]`}
+	container := types.Container{ID: "0123456789abcdef", Image: "nginx", Labels: labels}
+	invalid := types.Container{ID: "fedcba9876543210", Image: "nginx", Labels: map[string]string{config.AutodiscoveryLogsLabel: "{"}}
+
+	suite.Equal(static, suite.c.sourceOf(container))
+
+	config.LogsAgent.Set("log_container_autodiscovery", true)
+	source := suite.c.sourceOf(container)
+	suite.Equal("web", source.Service)
+	suite.Equal(source, suite.c.sourceOf(container))
+	suite.Equal(static, suite.c.sourceOf(invalid))
+
+	suite.c.forgetDiscoveredSources([]types.Container{invalid})
+	suite.Equal(1, len(suite.c.discovered))
+}
+
+func (suite *ContainerScannerTestSuite) TestContainerScannerSkipsUnreadableContainers() {
+	suite.True(isUnreadableLogsError(errors.New(`Error response from daemon: configured logging driver does not support reading`)))
+	suite.False(isUnreadableLogsError(errors.New("Cannot connect to the Docker daemon")))
+	suite.False(isUnreadableLogsError(nil))
+
+	suite.c.unreadable["0123456789abcdef"] = true
+	suite.c.unreadable["fedcba9876543210"] = true
+	suite.c.forgetUnreadableContainers([]types.Container{{ID: "0123456789abcdef"}})
+	suite.Equal(map[string]bool{"0123456789abcdef": true}, suite.c.unreadable)
+}
+
+func (suite *ContainerScannerTestSuite) TestContainerScannerStopsSource() {
+	a := &config.IntegrationConfigLogSource{Type: config.DOCKER_TYPE, Image: "a"}
+	b := &config.IntegrationConfigLogSource{Type: config.DOCKER_TYPE, Image: "b"}
+	suite.c.sources = []*config.IntegrationConfigLogSource{a, b}
+	suite.c.tailers = make(map[string]*DockerTailer)
+	for id, source := range map[string]*config.IntegrationConfigLogSource{"0123456789abcdef": a, "fedcba9876543210": b} {
+		suite.c.tailers[id] = NewDockerTailer(nil, types.Container{ID: id}, source, make(chan message.Message))
+	}
+
+	suite.c.StopSource(a.GetID())
+	suite.Equal([]*config.IntegrationConfigLogSource{b}, suite.c.sources)
+	suite.Equal(1, len(suite.c.tailers))
+	suite.Equal(b, suite.c.tailers["fedcba9876543210"].source)
+}
+
 func TestContainerScannerTestSuite(t *testing.T) {
 	suite.Run(t, new(ContainerScannerTestSuite))
 }
@@ -0,0 +1,152 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package flow
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// the kinds of flows, after the protocol they were exported with
+const (
+	kindNetFlow5 = "netflow5"
+	kindNetFlow9 = "netflow9"
+	kindIPFIX    = "ipfix"
+	kindSFlow5   = "sflow5"
+)
+
+var errTruncated = errors.New("truncated datagram")
+
+// protocolNames are the names of the most common IP protocols
+var protocolNames = map[uint8]string{1: "icmp", 6: "tcp", 17: "udp", 47: "gre", 50: "esp", 58: "icmpv6", 132: "sctp"}
+
+// A flow is a sequence of packets between two endpoints, as exported by a router
+type flow struct {
+	kind     string
+	srcIP    net.IP
+	dstIP    net.IP
+	srcPort  uint16
+	dstPort  uint16
+	protocol uint8
+	tcpFlags uint8
+	tos      uint8
+	bytes    uint64
+	packets  uint64
+	nextHop  net.IP
+	inputIf  uint32
+	outputIf uint32
+	srcAS    uint32
+	dstAS    uint32
+	// start and end are zero when not exported
+	start time.Time
+	end   time.Time
+	// samplingRate is the number of packets a sampled one stands for, 0 when unknown
+	samplingRate uint32
+}
+
+// decodeDatagram returns the flows of a NetFlow v5, v9, IPFIX or sFlow v5 datagram,
+// telling them apart by their version, templates being those exported by exporter
+func decodeDatagram(datagram []byte, exporter string, templates *templateCache) ([]flow, error) {
+	if len(datagram) < 4 {
+		return nil, errTruncated
+	}
+	switch version := binary.BigEndian.Uint16(datagram); version {
+	case 5:
+		return decodeNetFlow5(datagram)
+	case 9:
+		return decodeNetFlow9(datagram, exporter, templates)
+	case 10:
+		return decodeIPFIX(datagram, exporter, templates)
+	case 0:
+		// the version of sFlow is encoded on 4 bytes
+		if binary.BigEndian.Uint32(datagram) == 5 {
+			return decodeSFlow5(datagram)
+		}
+	}
+	return nil, fmt.Errorf("unsupported datagram version %d", binary.BigEndian.Uint16(datagram))
+}
+
+// content returns the structured log of the flow exported by exporter,
+// the endpoints being named after the network standard attributes
+func (f *flow) content(exporter string) ([]byte, error) {
+	protocol, ok := protocolNames[f.protocol]
+	if !ok {
+		protocol = strconv.Itoa(int(f.protocol))
+	}
+	client := map[string]interface{}{"ip": ipString(f.srcIP)}
+	destination := map[string]interface{}{"ip": ipString(f.dstIP)}
+	if f.protocol == 6 || f.protocol == 17 || f.protocol == 132 {
+		client["port"] = f.srcPort
+		destination["port"] = f.dstPort
+	}
+	attributes := map[string]interface{}{
+		"type":        f.kind,
+		"exporter":    exporter,
+		"ip_protocol": protocol,
+		"bytes":       f.bytes,
+		"packets":     f.packets,
+		"tos":         f.tos,
+	}
+	if f.protocol == 6 {
+		attributes["tcp_flags"] = f.tcpFlags
+	}
+	if f.nextHop != nil && !f.nextHop.IsUnspecified() {
+		attributes["next_hop"] = f.nextHop.String()
+	}
+	if f.inputIf != 0 {
+		attributes["input_interface"] = f.inputIf
+	}
+	if f.outputIf != 0 {
+		attributes["output_interface"] = f.outputIf
+	}
+	if f.srcAS != 0 || f.dstAS != 0 {
+		attributes["source_as"] = f.srcAS
+		attributes["destination_as"] = f.dstAS
+	}
+	if !f.start.IsZero() {
+		attributes["start"] = f.start.UTC().Format(time.RFC3339Nano)
+	}
+	if !f.end.IsZero() {
+		attributes["end"] = f.end.UTC().Format(time.RFC3339Nano)
+		if !f.start.IsZero() {
+			attributes["duration"] = f.end.Sub(f.start).Seconds()
+		}
+	}
+	if f.samplingRate > 0 {
+		attributes["sampling_rate"] = f.samplingRate
+	}
+	return json.Marshal(map[string]interface{}{
+		"message": fmt.Sprintf("%s %s -> %s", protocol, endpoint(client), endpoint(destination)),
+		"flow":    attributes,
+		"network": map[string]interface{}{
+			"client":      client,
+			"destination": destination,
+		},
+	})
+}
+
+// ipString returns the textual form of ip, or an empty string when it wasn't exported
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// endpoint formats the ip and the port of an endpoint
+func endpoint(attributes map[string]interface{}) string {
+	ip := attributes["ip"].(string)
+	port, ok := attributes["port"]
+	if !ok {
+		return ip
+	}
+	return net.JoinHostPort(ip, fmt.Sprintf("%d", port))
+}
@@ -0,0 +1,382 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package flow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	netFlow5HeaderSize = 24
+	netFlow5RecordSize = 48
+	netFlow9HeaderSize = 20
+	ipfixHeaderSize    = 16
+
+	// the ids of the template sets, the ids of the data sets being those of their template
+	netFlow9TemplateSet = 0
+	ipfixTemplateSet    = 2
+	minDataSet          = 256
+
+	// variableLength is the length of the IPFIX fields whose length is encoded in the record
+	variableLength = 0xffff
+	// enterpriseBit flags the IPFIX fields followed by their enterprise number
+	enterpriseBit = 0x8000
+
+	// maxTemplates bounds the number of templates cached
+	maxTemplates = 10000
+)
+
+// the information elements of the templates decoded into flows, the
+// same in NetFlow v9 and IPFIX
+const (
+	fieldBytes         = 1
+	fieldPackets       = 2
+	fieldProtocol      = 4
+	fieldTOS           = 5
+	fieldTCPFlags      = 6
+	fieldSrcPort       = 7
+	fieldSrcIPv4       = 8
+	fieldInputIf       = 10
+	fieldDstPort       = 11
+	fieldDstIPv4       = 12
+	fieldOutputIf      = 14
+	fieldNextHopIPv4   = 15
+	fieldSrcAS         = 16
+	fieldDstAS         = 17
+	fieldLastSwitched  = 21
+	fieldFirstSwitched = 22
+	fieldSrcIPv6       = 27
+	fieldDstIPv6       = 28
+	fieldNextHopIPv6   = 62
+	fieldStartSeconds  = 150
+	fieldEndSeconds    = 151
+	fieldStartMillis   = 152
+	fieldEndMillis     = 153
+)
+
+// decodeNetFlow5 returns the flows of a NetFlow v5 datagram
+func decodeNetFlow5(datagram []byte) ([]flow, error) {
+	if len(datagram) < netFlow5HeaderSize {
+		return nil, errTruncated
+	}
+	count := int(binary.BigEndian.Uint16(datagram[2:]))
+	if len(datagram) < netFlow5HeaderSize+count*netFlow5RecordSize {
+		return nil, errTruncated
+	}
+	uptime := binary.BigEndian.Uint32(datagram[4:])
+	exportTime := time.Unix(int64(binary.BigEndian.Uint32(datagram[8:])), int64(binary.BigEndian.Uint32(datagram[12:])))
+	samplingRate := uint32(binary.BigEndian.Uint16(datagram[22:]) & 0x3fff)
+
+	flows := make([]flow, 0, count)
+	for i := 0; i < count; i++ {
+		r := datagram[netFlow5HeaderSize+i*netFlow5RecordSize:]
+		flows = append(flows, flow{
+			kind:         kindNetFlow5,
+			srcIP:        ip(r[0:4]),
+			dstIP:        ip(r[4:8]),
+			nextHop:      ip(r[8:12]),
+			inputIf:      uint32(binary.BigEndian.Uint16(r[12:])),
+			outputIf:     uint32(binary.BigEndian.Uint16(r[14:])),
+			packets:      uint64(binary.BigEndian.Uint32(r[16:])),
+			bytes:        uint64(binary.BigEndian.Uint32(r[20:])),
+			start:        uptimeTime(exportTime, uptime, binary.BigEndian.Uint32(r[24:])),
+			end:          uptimeTime(exportTime, uptime, binary.BigEndian.Uint32(r[28:])),
+			srcPort:      binary.BigEndian.Uint16(r[32:]),
+			dstPort:      binary.BigEndian.Uint16(r[34:]),
+			tcpFlags:     r[37],
+			protocol:     r[38],
+			tos:          r[39],
+			srcAS:        uint32(binary.BigEndian.Uint16(r[40:])),
+			dstAS:        uint32(binary.BigEndian.Uint16(r[42:])),
+			samplingRate: samplingRate,
+		})
+	}
+	return flows, nil
+}
+
+// uptimeTime returns the time at which the exporter was up for switched milliseconds,
+// given it was up for uptime milliseconds at exportTime
+func uptimeTime(exportTime time.Time, uptime, switched uint32) time.Time {
+	// the difference wraps around along with the uptimes
+	return exportTime.Add(-time.Duration(int32(uptime-switched)) * time.Millisecond)
+}
+
+// A templateField is a field of the records of a template
+type templateField struct {
+	id     uint16
+	length uint16
+	// enterprise fields are skipped
+	enterprise bool
+}
+
+// A templateCache holds the templates of the exporters, NetFlow v9 and IPFIX
+// records being decoded with the template previously exported for their set
+type templateCache struct {
+	mutex     sync.Mutex
+	templates map[templateKey][]templateField
+}
+
+// A templateKey identifies a template, the ids being scoped to the
+// observation domain of an exporter
+type templateKey struct {
+	exporter string
+	domain   uint32
+	id       uint16
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{templates: make(map[templateKey][]templateField)}
+}
+
+func (c *templateCache) get(key templateKey) []templateField {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.templates[key]
+}
+
+func (c *templateCache) set(key templateKey, fields []templateField) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.templates[key]; !ok && len(c.templates) >= maxTemplates {
+		// exporters export their templates periodically
+		c.templates = make(map[templateKey][]templateField)
+	}
+	c.templates[key] = fields
+}
+
+// A setDecoder decodes the sets of a NetFlow v9 or IPFIX datagram
+type setDecoder struct {
+	kind        string
+	templateSet uint16
+	key         templateKey
+	templates   *templateCache
+	// uptime and exportTime date the NetFlow v9 switched times
+	uptime     uint32
+	exportTime time.Time
+}
+
+// decodeNetFlow9 returns the flows of a NetFlow v9 datagram
+func decodeNetFlow9(datagram []byte, exporter string, templates *templateCache) ([]flow, error) {
+	if len(datagram) < netFlow9HeaderSize {
+		return nil, errTruncated
+	}
+	d := &setDecoder{
+		kind:        kindNetFlow9,
+		templateSet: netFlow9TemplateSet,
+		key:         templateKey{exporter: exporter, domain: binary.BigEndian.Uint32(datagram[16:])},
+		templates:   templates,
+		uptime:      binary.BigEndian.Uint32(datagram[4:]),
+		exportTime:  time.Unix(int64(binary.BigEndian.Uint32(datagram[8:])), 0),
+	}
+	return d.decodeSets(datagram[netFlow9HeaderSize:])
+}
+
+// decodeIPFIX returns the flows of an IPFIX message
+func decodeIPFIX(datagram []byte, exporter string, templates *templateCache) ([]flow, error) {
+	if len(datagram) < ipfixHeaderSize {
+		return nil, errTruncated
+	}
+	length := int(binary.BigEndian.Uint16(datagram[2:]))
+	if length < ipfixHeaderSize || length > len(datagram) {
+		return nil, errTruncated
+	}
+	d := &setDecoder{
+		kind:        kindIPFIX,
+		templateSet: ipfixTemplateSet,
+		key:         templateKey{exporter: exporter, domain: binary.BigEndian.Uint32(datagram[12:])},
+		templates:   templates,
+		exportTime:  time.Unix(int64(binary.BigEndian.Uint32(datagram[4:])), 0),
+	}
+	return d.decodeSets(datagram[ipfixHeaderSize:length])
+}
+
+// decodeSets returns the flows of the data sets of sets, caching the templates
+// of its template sets. The options templates and their data are skipped
+func (d *setDecoder) decodeSets(sets []byte) ([]flow, error) {
+	var flows []flow
+	for len(sets) >= 4 {
+		id := binary.BigEndian.Uint16(sets)
+		length := int(binary.BigEndian.Uint16(sets[2:]))
+		if length < 4 || length > len(sets) {
+			return flows, errTruncated
+		}
+		body := sets[4:length]
+		sets = sets[length:]
+		switch {
+		case id == d.templateSet:
+			if err := d.decodeTemplates(body); err != nil {
+				return flows, err
+			}
+		case id >= minDataSet:
+			key := d.key
+			key.id = id
+			fields := d.templates.get(key)
+			if fields == nil {
+				// the template will come with a later datagram
+				continue
+			}
+			decoded, err := d.decodeRecords(body, fields)
+			flows = append(flows, decoded...)
+			if err != nil {
+				return flows, err
+			}
+		}
+	}
+	return flows, nil
+}
+
+// decodeTemplates caches the templates of a template set
+func (d *setDecoder) decodeTemplates(body []byte) error {
+	for len(body) >= 4 {
+		key := d.key
+		key.id = binary.BigEndian.Uint16(body)
+		count := int(binary.BigEndian.Uint16(body[2:]))
+		body = body[4:]
+		if key.id < minDataSet {
+			// padding
+			return nil
+		}
+		fields := make([]templateField, 0, count)
+		for i := 0; i < count; i++ {
+			if len(body) < 4 {
+				return errTruncated
+			}
+			field := templateField{id: binary.BigEndian.Uint16(body), length: binary.BigEndian.Uint16(body[2:])}
+			body = body[4:]
+			if d.kind == kindIPFIX && field.id&enterpriseBit != 0 {
+				if len(body) < 4 {
+					return errTruncated
+				}
+				field.enterprise = true
+				body = body[4:]
+			}
+			fields = append(fields, field)
+		}
+		d.templates.set(key, fields)
+	}
+	return nil
+}
+
+// decodeRecords returns the flows of the records of a data set
+func (d *setDecoder) decodeRecords(body []byte, fields []templateField) ([]flow, error) {
+	minLength := 0
+	for _, field := range fields {
+		if field.length != variableLength {
+			minLength += int(field.length)
+		} else {
+			minLength++
+		}
+	}
+	if minLength == 0 {
+		return nil, fmt.Errorf("empty template")
+	}
+	var flows []flow
+	// the set may be padded with less bytes than a record
+	for len(body) >= minLength {
+		f := flow{kind: d.kind}
+		for _, field := range fields {
+			length := int(field.length)
+			if field.length == variableLength {
+				if len(body) < 1 {
+					return flows, errTruncated
+				}
+				length, body = int(body[0]), body[1:]
+				if length == 0xff {
+					if len(body) < 2 {
+						return flows, errTruncated
+					}
+					length, body = int(binary.BigEndian.Uint16(body)), body[2:]
+				}
+			}
+			if len(body) < length {
+				return flows, errTruncated
+			}
+			if !field.enterprise {
+				d.setField(&f, field.id, body[:length])
+			}
+			body = body[length:]
+		}
+		flows = append(flows, f)
+	}
+	return flows, nil
+}
+
+// setField sets the field id of f to value
+func (d *setDecoder) setField(f *flow, id uint16, value []byte) {
+	switch id {
+	case fieldBytes:
+		f.bytes = unsigned(value)
+	case fieldPackets:
+		f.packets = unsigned(value)
+	case fieldProtocol:
+		f.protocol = uint8(unsigned(value))
+	case fieldTOS:
+		f.tos = uint8(unsigned(value))
+	case fieldTCPFlags:
+		f.tcpFlags = uint8(unsigned(value))
+	case fieldSrcPort:
+		f.srcPort = uint16(unsigned(value))
+	case fieldDstPort:
+		f.dstPort = uint16(unsigned(value))
+	case fieldSrcIPv4, fieldSrcIPv6:
+		f.srcIP = ip(value)
+	case fieldDstIPv4, fieldDstIPv6:
+		f.dstIP = ip(value)
+	case fieldNextHopIPv4, fieldNextHopIPv6:
+		f.nextHop = ip(value)
+	case fieldInputIf:
+		f.inputIf = uint32(unsigned(value))
+	case fieldOutputIf:
+		f.outputIf = uint32(unsigned(value))
+	case fieldSrcAS:
+		f.srcAS = uint32(unsigned(value))
+	case fieldDstAS:
+		f.dstAS = uint32(unsigned(value))
+	case fieldFirstSwitched:
+		if d.kind == kindNetFlow9 {
+			f.start = uptimeTime(d.exportTime, d.uptime, uint32(unsigned(value)))
+		}
+	case fieldLastSwitched:
+		if d.kind == kindNetFlow9 {
+			f.end = uptimeTime(d.exportTime, d.uptime, uint32(unsigned(value)))
+		}
+	case fieldStartSeconds:
+		f.start = time.Unix(int64(unsigned(value)), 0)
+	case fieldEndSeconds:
+		f.end = time.Unix(int64(unsigned(value)), 0)
+	case fieldStartMillis:
+		f.start = millisTime(unsigned(value))
+	case fieldEndMillis:
+		f.end = millisTime(unsigned(value))
+	}
+}
+
+// unsigned decodes an unsigned integer of up to 8 bytes, as exporters may
+// encode the counters on less bytes than their information element
+func unsigned(value []byte) uint64 {
+	var n uint64
+	for _, b := range value {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
+
+// ip decodes an IPv4 or IPv6 address, or returns nil
+func ip(value []byte) net.IP {
+	if len(value) != net.IPv4len && len(value) != net.IPv6len {
+		return nil
+	}
+	return net.IP(append([]byte{}, value...))
+}
+
+// millisTime returns the time of milliseconds since the epoch
+func millisTime(millis uint64) time.Time {
+	return time.Unix(int64(millis/1000), int64(millis%1000)*int64(time.Millisecond))
+}
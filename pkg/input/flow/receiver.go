@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package flow
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+// maxDatagramSize is the size of the largest UDP datagram
+const maxDatagramSize = 65535
+
+// invalidDatagrams counts the datagrams that couldn't be decoded, even partially
+var invalidDatagrams = metrics.NewCounter("logs_flow_invalid_datagrams")
+
+// A Receiver listens for the NetFlow, IPFIX and sFlow datagrams of a flow
+// source, and sends each of their flows to its pipeline as a structured log
+type Receiver struct {
+	source     *config.IntegrationConfigLogSource
+	outputChan chan message.Message
+	templates  *templateCache
+	conn       *net.UDPConn
+	done       chan struct{}
+}
+
+// NewReceiver returns a Receiver listening on the port of source,
+// or an error if the port can't be listened on
+func NewReceiver(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (*Receiver, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", source.Port))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Receiving flows on port", source.Port)
+	return &Receiver{
+		source:     source,
+		outputChan: outputChan,
+		templates:  newTemplateCache(),
+		conn:       conn,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start starts receiving flows
+func (r *Receiver) Start() {
+	go r.run()
+}
+
+// Stop closes the socket and waits for the flows being received
+func (r *Receiver) Stop() {
+	r.conn.Close()
+	<-r.done
+}
+
+// run receives datagrams until the socket is closed
+func (r *Receiver) run() {
+	defer close(r.done)
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			if opErr, ok := err.(*net.OpError); ok && !opErr.Temporary() {
+				return
+			}
+			log.Println("Can't receive flows:", err)
+			continue
+		}
+		r.handle(buf[:n], addr.IP.String())
+	}
+}
+
+// handle forwards the flows of datagram, sent by exporter
+func (r *Receiver) handle(datagram []byte, exporter string) {
+	flows, err := decodeDatagram(datagram, exporter, r.templates)
+	if err != nil {
+		// the flows decoded before the error are forwarded nonetheless
+		invalidDatagrams.Add(1)
+		log.Printf("Invalid flow datagram from %s: %v", exporter, err)
+	}
+	for _, f := range flows {
+		content, err := f.content(exporter)
+		if err != nil {
+			log.Printf("Can't format flow from %s: %v", exporter, err)
+			continue
+		}
+		msg := message.NewNetworkMessage(content)
+		origin := message.NewOrigin()
+		origin.LogSource = r.source
+		msg.SetOrigin(origin)
+		r.outputChan <- msg
+	}
+}
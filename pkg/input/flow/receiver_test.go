@@ -0,0 +1,181 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package flow
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// be encodes values in big endian, each on the size of its type
+func be(values ...interface{}) []byte {
+	var buf []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case uint8:
+			buf = append(buf, v)
+		case uint16:
+			buf = append(buf, byte(v>>8), byte(v))
+		case uint32:
+			b := make([]byte, 4)
+			binary.BigEndian.PutUint32(b, v)
+			buf = append(buf, b...)
+		case []byte:
+			buf = append(buf, v...)
+		}
+	}
+	return buf
+}
+
+func netFlow5Datagram() []byte {
+	header := be(uint16(5), uint16(1), uint32(10000), uint32(1500000000), uint32(0), uint32(1), uint8(0), uint8(0), uint16(0x4000|100))
+	record := be(
+		[]byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}, []byte{0, 0, 0, 0},
+		uint16(1), uint16(2), uint32(3), uint32(1500), uint32(8000), uint32(9000),
+		uint16(51000), uint16(443), uint8(0), uint8(0x12), uint8(6), uint8(0),
+		uint16(64512), uint16(64513), uint8(24), uint8(24), uint16(0),
+	)
+	return append(header, record...)
+}
+
+func TestDecodeNetFlow5(t *testing.T) {
+	flows, err := decodeDatagram(netFlow5Datagram(), "192.168.0.1", newTemplateCache())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(flows))
+	f := flows[0]
+	assert.Equal(t, kindNetFlow5, f.kind)
+	assert.Equal(t, "10.0.0.1", f.srcIP.String())
+	assert.Equal(t, "10.0.0.2", f.dstIP.String())
+	assert.Equal(t, uint16(51000), f.srcPort)
+	assert.Equal(t, uint16(443), f.dstPort)
+	assert.Equal(t, uint8(6), f.protocol)
+	assert.Equal(t, uint64(1500), f.bytes)
+	assert.Equal(t, uint64(3), f.packets)
+	assert.Equal(t, uint32(100), f.samplingRate)
+	assert.Equal(t, time.Unix(1500000000-2, 0), f.start)
+	assert.Equal(t, time.Unix(1500000000-1, 0), f.end)
+
+	_, err = decodeDatagram(netFlow5Datagram()[:60], "192.168.0.1", newTemplateCache())
+	assert.NotNil(t, err)
+}
+
+func TestFlowContent(t *testing.T) {
+	flows, _ := decodeDatagram(netFlow5Datagram(), "192.168.0.1", newTemplateCache())
+	content, err := flows[0].content("192.168.0.1")
+	assert.Nil(t, err)
+	var log map[string]interface{}
+	assert.Nil(t, json.Unmarshal(content, &log))
+	assert.Equal(t, "tcp 10.0.0.1:51000 -> 10.0.0.2:443", log["message"])
+	assert.Equal(t, map[string]interface{}{
+		"client":      map[string]interface{}{"ip": "10.0.0.1", "port": float64(51000)},
+		"destination": map[string]interface{}{"ip": "10.0.0.2", "port": float64(443)},
+	}, log["network"])
+	attributes := log["flow"].(map[string]interface{})
+	assert.Equal(t, "netflow5", attributes["type"])
+	assert.Equal(t, "192.168.0.1", attributes["exporter"])
+	assert.Equal(t, float64(1500), attributes["bytes"])
+	assert.Equal(t, float64(1), attributes["duration"])
+	assert.Equal(t, float64(64512), attributes["source_as"])
+	assert.NotContains(t, attributes, "next_hop")
+}
+
+func TestDecodeNetFlow9WithTemplates(t *testing.T) {
+	templates := newTemplateCache()
+	header := be(uint16(9), uint16(2), uint32(10000), uint32(1500000000), uint32(1), uint32(7))
+	template := be(uint16(0), uint16(24), uint16(256), uint16(4),
+		uint16(fieldSrcIPv4), uint16(4), uint16(fieldDstIPv4), uint16(4), uint16(fieldProtocol), uint16(1), uint16(fieldBytes), uint16(8))
+	data := be(uint16(256), uint16(24), []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}, uint8(17), uint32(0), uint32(4096), []byte{0, 0, 0})
+
+	// the data before its template is skipped
+	flows, err := decodeDatagram(append(header, data...), "192.168.0.1", templates)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(flows))
+
+	flows, err = decodeDatagram(append(append(header, template...), data...), "192.168.0.1", templates)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(flows))
+	assert.Equal(t, "10.0.0.2", flows[0].dstIP.String())
+	assert.Equal(t, uint8(17), flows[0].protocol)
+	assert.Equal(t, uint64(4096), flows[0].bytes)
+
+	// the templates are scoped to their exporter
+	flows, _ = decodeDatagram(append(header, data...), "192.168.0.1", templates)
+	assert.Equal(t, 1, len(flows))
+	flows, _ = decodeDatagram(append(header, data...), "192.168.0.2", templates)
+	assert.Equal(t, 0, len(flows))
+}
+
+func TestDecodeIPFIXWithVariableAndEnterpriseFields(t *testing.T) {
+	template := be(uint16(2), uint16(28), uint16(300), uint16(4),
+		uint16(fieldSrcIPv6), uint16(16), uint16(0x8000|1), uint16(2), uint32(9), uint16(82), uint16(variableLength), uint16(fieldStartMillis), uint16(8))
+	src := net.ParseIP("2001:db8::1")
+	data := be(uint16(300), uint16(4+16+2+1+4+8), []byte(src), uint16(0xffff), uint8(4), []byte("eth0"), uint32(0), uint32(1500000000))
+	body := append(template, data...)
+	header := be(uint16(10), uint16(16+len(body)), uint32(1500000000), uint32(1), uint32(3))
+
+	flows, err := decodeDatagram(append(header, body...), "192.168.0.1", newTemplateCache())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(flows))
+	assert.Equal(t, kindIPFIX, flows[0].kind)
+	assert.Equal(t, "2001:db8::1", flows[0].srcIP.String())
+	assert.Equal(t, time.Unix(1500000, 0), flows[0].start)
+}
+
+func TestDecodeSFlow5(t *testing.T) {
+	frame := be(
+		[]byte{0, 1, 2, 3, 4, 5}, []byte{6, 7, 8, 9, 10, 11}, uint16(etherTypeIPv4),
+		uint8(0x45), uint8(0), uint16(40), uint32(0), uint8(64), uint8(6), uint16(0), []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2},
+		uint16(51000), uint16(443), uint32(0), uint32(0), uint8(0x50), uint8(0x02),
+	)
+	record := be(uint32(sFlowRawHeader), uint32(16+len(frame)), uint32(headerEthernet), uint32(1514), uint32(4), uint32(len(frame)), frame)
+	sample := be(uint32(1), uint32(3), uint32(512), uint32(0), uint32(0), uint32(5), uint32(6), uint32(1))
+	sample = append(sample, record...)
+	counters := be(uint32(2), uint32(4), uint32(0))
+	datagram := be(uint32(5), uint32(1), []byte{192, 168, 0, 1}, uint32(0), uint32(1), uint32(1000), uint32(2),
+		counters, uint32(sFlowSample), uint32(len(sample)), sample)
+
+	flows, err := decodeDatagram(datagram, "192.168.0.1", newTemplateCache())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(flows))
+	f := flows[0]
+	assert.Equal(t, kindSFlow5, f.kind)
+	assert.Equal(t, "10.0.0.1", f.srcIP.String())
+	assert.Equal(t, uint16(443), f.dstPort)
+	assert.Equal(t, uint8(0x02), f.tcpFlags)
+	assert.Equal(t, uint64(1514), f.bytes)
+	assert.Equal(t, uint32(512), f.samplingRate)
+	assert.Equal(t, uint32(5), f.inputIf)
+}
+
+func TestReceiverForwardsFlows(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	outputChan := make(chan message.Message, 10)
+	source := &config.IntegrationConfigLogSource{Type: config.FLOW_TYPE, Port: port}
+	r, err := NewReceiver(source, outputChan)
+	assert.Nil(t, err)
+	r.Start()
+	defer r.Stop()
+
+	client, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	assert.Nil(t, err)
+	defer client.Close()
+	client.Write(netFlow5Datagram())
+
+	msg := <-outputChan
+	assert.Equal(t, source, msg.GetOrigin().LogSource)
+	assert.Contains(t, string(msg.Content()), `"exporter":"127.0.0.1"`)
+}
@@ -0,0 +1,204 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package flow
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// the formats of the sFlow samples and records decoded, those of other
+// enterprises than sFlow.org being skipped along with the counter samples
+const (
+	sFlowSample         = 1
+	sFlowExpandedSample = 3
+	sFlowRawHeader      = 1
+)
+
+// the protocols of the sampled headers
+const (
+	headerEthernet = 1
+	headerIPv4     = 11
+	headerIPv6     = 12
+)
+
+// the types of the ethernet frames
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+)
+
+// An xdrReader reads the 4 bytes aligned values of an sFlow datagram
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+func (r *xdrReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// opaque returns the next n bytes, skipping their padding
+func (r *xdrReader) opaque(n int) []byte {
+	b := r.next(n)
+	r.next((4 - n%4) % 4)
+	return b
+}
+
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil || n < 0 || len(r.buf) < n {
+		r.err = errTruncated
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// decodeSFlow5 returns the flows of the packets sampled in an sFlow v5 datagram
+func decodeSFlow5(datagram []byte) ([]flow, error) {
+	r := &xdrReader{buf: datagram}
+	r.uint32() // version
+	switch addressType := r.uint32(); addressType {
+	case 1:
+		r.next(net.IPv4len)
+	case 2:
+		r.next(net.IPv6len)
+	default:
+		return nil, errTruncated
+	}
+	r.uint32() // sub agent id
+	r.uint32() // sequence number
+	r.uint32() // uptime
+	count := int(r.uint32())
+	var flows []flow
+	for i := 0; i < count && r.err == nil; i++ {
+		format := r.uint32()
+		sample := &xdrReader{buf: r.opaque(int(r.uint32()))}
+		if r.err != nil {
+			break
+		}
+		switch format {
+		case sFlowSample, sFlowExpandedSample:
+			flows = append(flows, decodeFlowSample(sample, format == sFlowExpandedSample)...)
+		}
+	}
+	return flows, r.err
+}
+
+// decodeFlowSample returns the flows of the packets sampled in a flow sample
+func decodeFlowSample(r *xdrReader, expanded bool) []flow {
+	r.uint32() // sequence number
+	if expanded {
+		r.uint32() // source id type
+	}
+	r.uint32() // source id
+	samplingRate := r.uint32()
+	r.uint32() // sample pool
+	r.uint32() // drops
+	var inputIf, outputIf uint32
+	if expanded {
+		r.uint32() // input format
+		inputIf = r.uint32()
+		r.uint32() // output format
+		outputIf = r.uint32()
+	} else {
+		// the format is encoded in the 2 most significant bits
+		inputIf = r.uint32() & 0x3fffffff
+		outputIf = r.uint32() & 0x3fffffff
+	}
+	count := int(r.uint32())
+	var flows []flow
+	for i := 0; i < count && r.err == nil; i++ {
+		format := r.uint32()
+		record := &xdrReader{buf: r.opaque(int(r.uint32()))}
+		if r.err != nil || format != sFlowRawHeader {
+			continue
+		}
+		protocol := record.uint32()
+		frameLength := record.uint32()
+		record.uint32() // stripped
+		header := record.opaque(int(record.uint32()))
+		if record.err != nil {
+			continue
+		}
+		f := flow{
+			kind:         kindSFlow5,
+			bytes:        uint64(frameLength),
+			packets:      1,
+			inputIf:      inputIf,
+			outputIf:     outputIf,
+			samplingRate: samplingRate,
+		}
+		if decodeHeader(&f, protocol, header) {
+			flows = append(flows, f)
+		}
+	}
+	return flows
+}
+
+// decodeHeader sets the endpoints of f from the sampled header of a packet,
+// returning false when it's not an IP packet
+func decodeHeader(f *flow, protocol uint32, header []byte) bool {
+	if protocol == headerEthernet {
+		if len(header) < 14 {
+			return false
+		}
+		etherType := binary.BigEndian.Uint16(header[12:])
+		header = header[14:]
+		if etherType == etherTypeVLAN && len(header) >= 4 {
+			etherType = binary.BigEndian.Uint16(header[2:])
+			header = header[4:]
+		}
+		switch etherType {
+		case etherTypeIPv4:
+			protocol = headerIPv4
+		case etherTypeIPv6:
+			protocol = headerIPv6
+		default:
+			return false
+		}
+	}
+	var transport []byte
+	switch protocol {
+	case headerIPv4:
+		if len(header) < 20 {
+			return false
+		}
+		ihl := int(header[0]&0x0f) * 4
+		f.tos = header[1]
+		f.protocol = header[9]
+		f.srcIP = ip(header[12:16])
+		f.dstIP = ip(header[16:20])
+		if ihl >= 20 && len(header) >= ihl {
+			transport = header[ihl:]
+		}
+	case headerIPv6:
+		if len(header) < 40 {
+			return false
+		}
+		f.tos = uint8(binary.BigEndian.Uint16(header) >> 4)
+		f.protocol = header[6]
+		f.srcIP = ip(header[8:24])
+		f.dstIP = ip(header[24:40])
+		transport = header[40:]
+	default:
+		return false
+	}
+	if (f.protocol == 6 || f.protocol == 17 || f.protocol == 132) && len(transport) >= 4 {
+		f.srcPort = binary.BigEndian.Uint16(transport)
+		f.dstPort = binary.BigEndian.Uint16(transport[2:])
+		if f.protocol == 6 && len(transport) >= 14 {
+			f.tcpFlags = transport[13]
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// clientID identifies the agent in the logs of the brokers
+	clientID = "datadog-log-agent"
+	// maxResponseSize bounds the size of the responses we accept
+	maxResponseSize = 64 * 1024 * 1024
+)
+
+// A broker is a connection to a Kafka broker, sending one request at a time
+type broker struct {
+	address string
+	timeout time.Duration
+
+	mutex       sync.Mutex
+	conn        net.Conn
+	reader      *bufio.Reader
+	correlation int32
+}
+
+// dialBroker connects to the broker at address
+func dialBroker(address string, tlsConfig *tls.Config, timeout time.Duration) (*broker, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, config)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &broker{
+		address: address,
+		timeout: timeout,
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+	}, nil
+}
+
+// request sends a request and returns the decoder of its response,
+// waiting for it up to the timeout of the broker plus wait
+func (b *broker) request(key, version int16, body *encoder, wait time.Duration) (*decoder, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.correlation++
+	header := (&encoder{}).int32(0).int16(key).int16(version).int32(b.correlation).string(clientID)
+	buf := append(header.buf, body.buf...)
+	binary.BigEndian.PutUint32(buf, uint32(len(buf)-4))
+
+	b.conn.SetDeadline(time.Now().Add(b.timeout + wait))
+	if _, err := b.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	sizeBuf := make([]byte, 4)
+	if _, err := io.ReadFull(b.reader, sizeBuf); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(sizeBuf)
+	if size < 4 || size > maxResponseSize {
+		return nil, fmt.Errorf("invalid response size %d from %s", size, b.address)
+	}
+	response := make([]byte, size)
+	if _, err := io.ReadFull(b.reader, response); err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: response}
+	if correlation := d.int32(); correlation != b.correlation {
+		return nil, fmt.Errorf("unexpected response %d from %s instead of %d", correlation, b.address, b.correlation)
+	}
+	return d, nil
+}
+
+// close closes the connection
+func (b *broker) close() {
+	b.conn.Close()
+}
@@ -0,0 +1,600 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
+)
+
+const (
+	// ioTimeout bounds the time waited for the brokers
+	ioTimeout = 10 * time.Second
+	// heartbeatPeriod is the period at which the consumer tells the coordinator it's alive
+	heartbeatPeriod = 3 * time.Second
+	// commitPeriod is the period at which the acknowledged offsets are committed
+	commitPeriod = 5 * time.Second
+	// fetchWait is the time a broker waits for messages before answering a fetch
+	fetchWait = 500 * time.Millisecond
+	// fetchMaxBytes bounds the size of the records fetched at once from a broker
+	fetchMaxBytes = 8 * 1024 * 1024
+	// partitionMaxBytes bounds the size of the records fetched at once from a partition
+	partitionMaxBytes = 1024 * 1024
+	// defaultRetryPeriod is the time waited before reconnecting to the brokers
+	defaultRetryPeriod = 5 * time.Second
+
+	// the timestamps requesting the first and the next offsets of a partition
+	earliestOffset = -2
+	latestOffset   = -1
+)
+
+var errStopped = errors.New("stopped")
+
+// A Consumer drains the topics of a kafka source as a member of its consumer group,
+// and sends their messages to its pipeline as logs. The offsets are committed once
+// the messages are sent to the intake, so that those lost if the agent stops are
+// consumed again by the group
+type Consumer struct {
+	source     *config.IntegrationConfigLogSource
+	outputChan chan message.Message
+	tlsConfig  *tls.Config
+
+	retryPeriod time.Duration
+	lastError   string
+	// memberID is kept across the generations of the group to rejoin faster
+	memberID string
+
+	mutex   sync.Mutex
+	stopped bool
+	stop    chan struct{}
+}
+
+// NewConsumer returns a Consumer of the topics of source, or an error if its TLS files can't be loaded
+func NewConsumer(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (*Consumer, error) {
+	var tlsConfig *tls.Config
+	if source.UseTLS {
+		var err error
+		tlsConfig, err = utils.NewClientTLSConfig(source.Brokers[0], source.TLSCA, source.TLSCert, source.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		// set by broker
+		tlsConfig.ServerName = ""
+	}
+	return &Consumer{
+		source:      source,
+		outputChan:  outputChan,
+		tlsConfig:   tlsConfig,
+		retryPeriod: defaultRetryPeriod,
+		stop:        make(chan struct{}),
+	}, nil
+}
+
+// Start starts consuming messages
+func (c *Consumer) Start() {
+	go c.run()
+}
+
+// Stop leaves the group, committing the offsets of the messages already sent
+func (c *Consumer) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	close(c.stop)
+}
+
+// run consumes messages until stopped, joining the group again when it's rebalanced
+func (c *Consumer) run() {
+	for {
+		err := c.consume()
+		if err == errStopped || c.isStopped() {
+			return
+		}
+		if needsRejoin(err) {
+			if err == kafkaError(errUnknownMemberID) {
+				c.memberID = ""
+			}
+			continue
+		}
+		c.setError(err)
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(c.retryPeriod):
+		}
+	}
+}
+
+// A session is the consumption of the partitions assigned to the consumer by a generation of its group
+type session struct {
+	*Consumer
+	bootstrap   *broker
+	coordinator *broker
+	// mutex guards brokers, the connections to the leaders, and err
+	mutex      sync.Mutex
+	brokers    map[int32]*broker
+	addresses  map[int32]string
+	leaders    map[topicPartition]int32
+	membership *membership
+	tracker    *offsetTracker
+	// offsets are the offsets to fetch next
+	offsets map[topicPartition]int64
+	// done is closed when the membership is lost
+	done chan struct{}
+	err  error
+}
+
+// consume joins the group and consumes the assigned partitions until the group rebalances
+func (c *Consumer) consume() error {
+	s := &session{
+		Consumer:  c,
+		brokers:   make(map[int32]*broker),
+		addresses: make(map[int32]string),
+		leaders:   make(map[topicPartition]int32),
+		tracker:   newOffsetTracker(),
+		offsets:   make(map[topicPartition]int64),
+		done:      make(chan struct{}),
+	}
+	defer s.close()
+	if err := s.join(); err != nil {
+		return err
+	}
+	c.setError(nil)
+	if err := s.startPartitions(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.keepAlive()
+	}()
+	err := s.fetchForever()
+	close(s.done)
+	wg.Wait()
+	if s.err != nil {
+		err = s.err
+	}
+	// the coordinator accepts the commits of the previous generation while the group
+	// rebalances, the messages not acknowledged yet being consumed again
+	s.commit()
+	if err == errStopped {
+		s.membership.leave()
+	}
+	return err
+}
+
+// join connects to the cluster and joins the group
+func (s *session) join() error {
+	var err error
+	for _, address := range s.source.Brokers {
+		s.bootstrap, err = dialBroker(address, s.tlsConfig, ioTimeout)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	d, err := s.bootstrap.request(findCoordinatorKey, 0, (&encoder{}).string(s.source.GroupID), 0)
+	if err != nil {
+		return err
+	}
+	errorCode := d.int16()
+	d.int32() // node
+	host, port := d.string(), d.int32()
+	if d.err != nil {
+		return d.err
+	}
+	if err := checkError(errorCode); err != nil {
+		return fmt.Errorf("can't find the coordinator of group %s: %v", s.source.GroupID, err)
+	}
+	// the coordinator has its own connection, not to wait for the fetches
+	s.coordinator, err = dialBroker(net.JoinHostPort(host, strconv.Itoa(int(port))), s.tlsConfig, ioTimeout)
+	if err != nil {
+		return err
+	}
+
+	s.membership, err = joinGroup(s.coordinator, s.source.GroupID, s.memberID, s.source.Topics, s.partitions)
+	if err != nil {
+		return err
+	}
+	s.memberID = s.membership.memberID
+	return nil
+}
+
+// partitions returns the partitions of topics, recording their leaders
+func (s *session) partitions(topics []string) (map[string][]int32, error) {
+	body := (&encoder{}).array(len(topics))
+	for _, topic := range topics {
+		body.string(topic)
+	}
+	d, err := s.bootstrap.request(metadataKey, metadataVersion, body, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, n := 0, d.array(); i < n; i++ {
+		node, host, port := d.int32(), d.string(), d.int32()
+		d.string() // rack
+		s.addresses[node] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller
+	partitions := make(map[string][]int32)
+	for i, n := 0, d.array(); i < n; i++ {
+		errorCode, topic := d.int16(), d.string()
+		d.int8() // internal
+		if err := checkError(errorCode); err != nil && d.err == nil {
+			return nil, fmt.Errorf("can't get the partitions of %s: %v", topic, err)
+		}
+		for j, m := 0, d.array(); j < m; j++ {
+			d.int16() // error of the partition, such as its replicas being offline
+			partition, leader := d.int32(), d.int32()
+			for k, r := 0, d.array(); k < r; k++ {
+				d.int32()
+			}
+			for k, r := 0, d.array(); k < r; k++ {
+				d.int32()
+			}
+			partitions[topic] = append(partitions[topic], partition)
+			s.leaders[topicPartition{topic, partition}] = leader
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return partitions, nil
+}
+
+// startPartitions sets the offsets the assigned partitions are consumed from:
+// the committed ones, or those of the offset reset policy
+func (s *session) startPartitions() error {
+	topics := []string{}
+	body := (&encoder{}).string(s.source.GroupID).array(len(s.membership.assignment))
+	for topic, partitions := range s.membership.assignment {
+		topics = append(topics, topic)
+		body.string(topic).array(len(partitions))
+		for _, partition := range partitions {
+			body.int32(partition)
+		}
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+	if _, err := s.partitions(topics); err != nil {
+		return err
+	}
+	d, err := s.membership.coordinator.request(offsetFetchKey, offsetFetchVersion, body, 0)
+	if err != nil {
+		return err
+	}
+	for i, n := 0, d.array(); i < n; i++ {
+		topic := d.string()
+		for j, m := 0, d.array(); j < m; j++ {
+			partition, offset := d.int32(), d.int64()
+			d.string() // metadata
+			if err := checkError(d.int16()); err != nil && d.err == nil {
+				return err
+			}
+			tp := topicPartition{topic, partition}
+			if offset < 0 {
+				if offset, err = s.resetOffset(tp); err != nil {
+					return err
+				}
+			}
+			s.offsets[tp] = offset
+			s.tracker.start(tp, offset)
+		}
+	}
+	return d.err
+}
+
+// resetOffset returns the offset tp is consumed from when it has no valid committed offset
+func (s *session) resetOffset(tp topicPartition) (int64, error) {
+	timestamp := int64(latestOffset)
+	if s.source.OffsetReset == config.OffsetResetEarliest {
+		timestamp = earliestOffset
+	}
+	b, err := s.leader(tp)
+	if err != nil {
+		return 0, err
+	}
+	body := (&encoder{}).int32(-1).array(1).string(tp.topic).array(1).int32(tp.partition).int64(timestamp)
+	d, err := b.request(listOffsetsKey, listOffsetsVersion, body, 0)
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	for i, n := 0, d.array(); i < n; i++ {
+		d.string()
+		for j, m := 0, d.array(); j < m; j++ {
+			d.int32()
+			if err := checkError(d.int16()); err != nil && d.err == nil {
+				return 0, err
+			}
+			d.int64() // timestamp
+			offset = d.int64()
+		}
+	}
+	return offset, d.err
+}
+
+// leader returns the connection to the leader of tp
+func (s *session) leader(tp topicPartition) (*broker, error) {
+	node, ok := s.leaders[tp]
+	if !ok {
+		return nil, fmt.Errorf("no leader for partition %d of %s", tp.partition, tp.topic)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if b, ok := s.brokers[node]; ok {
+		return b, nil
+	}
+	b, err := dialBroker(s.addresses[node], s.tlsConfig, ioTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s.brokers[node] = b
+	return b, nil
+}
+
+// keepAlive sends heartbeats and commits the offsets periodically, until the session is done
+func (s *session) keepAlive() {
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+	commit := time.NewTicker(commitPeriod)
+	defer commit.Stop()
+	for {
+		var err error
+		select {
+		case <-s.done:
+			return
+		case <-heartbeat.C:
+			err = s.membership.heartbeat()
+		case <-commit.C:
+			err = s.commit()
+		}
+		if err != nil {
+			s.mutex.Lock()
+			s.err = err
+			// unblocks the fetches
+			s.closeBrokers()
+			s.mutex.Unlock()
+			return
+		}
+	}
+}
+
+// commit commits the offsets of the messages acknowledged since the last commit
+func (s *session) commit() error {
+	offsets := s.tracker.toCommit()
+	if len(offsets) == 0 {
+		return nil
+	}
+	byTopic := make(map[string][]topicPartition)
+	for tp := range offsets {
+		byTopic[tp.topic] = append(byTopic[tp.topic], tp)
+	}
+	body := (&encoder{}).string(s.source.GroupID).int32(s.membership.generation).string(s.membership.memberID).int64(-1).array(len(byTopic))
+	for topic, tps := range byTopic {
+		body.string(topic).array(len(tps))
+		for _, tp := range tps {
+			body.int32(tp.partition).int64(offsets[tp]).nullString()
+		}
+	}
+	d, err := s.membership.coordinator.request(offsetCommitKey, offsetCommitVer, body, 0)
+	if err != nil {
+		return err
+	}
+	for i, n := 0, d.array(); i < n; i++ {
+		d.string()
+		for j, m := 0, d.array(); j < m; j++ {
+			d.int32()
+			if err := checkError(d.int16()); err != nil && d.err == nil {
+				return err
+			}
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	s.tracker.setCommitted(offsets)
+	return nil
+}
+
+// fetchForever fetches the assigned partitions from their leaders until the session is done
+func (s *session) fetchForever() error {
+	byLeader := make(map[int32][]topicPartition)
+	for tp := range s.offsets {
+		byLeader[s.leaders[tp]] = append(byLeader[s.leaders[tp]], tp)
+	}
+	for {
+		if len(byLeader) == 0 {
+			// no partition assigned, such as when the group has more members than partitions
+			select {
+			case <-s.stop:
+				return errStopped
+			case <-time.After(heartbeatPeriod):
+				if err := s.lostMembership(); err != nil {
+					return err
+				}
+			}
+		}
+		for _, tps := range byLeader {
+			select {
+			case <-s.stop:
+				return errStopped
+			default:
+			}
+			if err := s.lostMembership(); err != nil {
+				return err
+			}
+			if err := s.fetch(tps); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// lostMembership returns the error that ended the membership, if any
+func (s *session) lostMembership() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// fetch fetches tps from their leader and forwards their messages
+func (s *session) fetch(tps []topicPartition) error {
+	b, err := s.leader(tps[0])
+	if err != nil {
+		return err
+	}
+	byTopic := make(map[string][]topicPartition)
+	for _, tp := range tps {
+		byTopic[tp.topic] = append(byTopic[tp.topic], tp)
+	}
+	body := (&encoder{}).int32(-1).int32(int32(fetchWait / time.Millisecond)).int32(1).int32(fetchMaxBytes).int8(0).array(len(byTopic))
+	for topic, partitions := range byTopic {
+		body.string(topic).array(len(partitions))
+		for _, tp := range partitions {
+			body.int32(tp.partition).int64(s.offsets[tp]).int32(partitionMaxBytes)
+		}
+	}
+	d, err := b.request(fetchKey, fetchVersion, body, fetchWait)
+	if err != nil {
+		return err
+	}
+	d.int32() // throttle time
+	for i, n := 0, d.array(); i < n; i++ {
+		topic := d.string()
+		for j, m := 0, d.array(); j < m; j++ {
+			partition, errorCode := d.int32(), d.int16()
+			d.int64() // high watermark
+			d.int64() // last stable offset
+			for k, a := 0, d.array(); k < a; k++ {
+				d.int64() // aborted transactions
+				d.int64()
+			}
+			set := d.bytes()
+			if d.err != nil {
+				return d.err
+			}
+			tp := topicPartition{topic, partition}
+			if errorCode == errOffsetOutOfRange {
+				// the messages were deleted by the retention
+				log.Printf("Offset %d of partition %d of %s out of range, resetting it", s.offsets[tp], partition, topic)
+				offset, err := s.resetOffset(tp)
+				if err != nil {
+					return err
+				}
+				s.offsets[tp] = offset
+				s.tracker.skip(tp, offset)
+				continue
+			}
+			if err := checkError(errorCode); err != nil {
+				return fmt.Errorf("can't fetch partition %d of %s: %v", partition, topic, err)
+			}
+			if err := s.forward(tp, set); err != nil {
+				return err
+			}
+		}
+	}
+	return d.err
+}
+
+// forward sends the messages of the record set of tp to the pipeline
+func (s *session) forward(tp topicPartition, set []byte) error {
+	records, next, err := parseRecords(set, s.offsets[tp])
+	if err != nil {
+		return fmt.Errorf("can't read partition %d of %s: %v", tp.partition, tp.topic, err)
+	}
+	for _, r := range records {
+		s.tracker.add(tp, r.offset)
+		content := bytes.TrimRight(r.value, "\r\n")
+		if len(content) == 0 {
+			s.tracker.ack(tp, r.offset)
+			continue
+		}
+		msg := message.NewNetworkMessage(content)
+		origin := message.NewOrigin()
+		origin.LogSource = s.source
+		tracker, offset := s.tracker, r.offset
+		origin.Ack = func(bool) {
+			// the messages given up on are not consumed again
+			tracker.ack(tp, offset)
+		}
+		msg.SetOrigin(origin)
+		select {
+		case s.outputChan <- msg:
+		case <-s.stop:
+			return errStopped
+		}
+	}
+	s.offsets[tp] = next
+	s.tracker.skip(tp, next)
+	return nil
+}
+
+// close closes the connections of the session
+func (s *session) close() {
+	s.mutex.Lock()
+	s.closeBrokers()
+	s.mutex.Unlock()
+	if s.coordinator != nil {
+		s.coordinator.close()
+	}
+	if s.bootstrap != nil {
+		s.bootstrap.close()
+	}
+}
+
+// closeBrokers closes the connections to the leaders, s.mutex being held
+func (s *session) closeBrokers() {
+	for _, b := range s.brokers {
+		b.close()
+	}
+}
+
+func (c *Consumer) isStopped() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stopped
+}
+
+// setError logs the consumption errors when they change
+func (c *Consumer) setError(err error) {
+	description := ""
+	if err != nil {
+		description = err.Error()
+	}
+	if description == c.lastError {
+		return
+	}
+	c.lastError = description
+	if err != nil {
+		log.Printf("Can't consume logs from kafka group %s: %v", c.source.GroupID, err)
+	} else {
+		log.Println("Consuming kafka topics", c.source.Topics, "as a member of group", c.source.GroupID)
+	}
+}
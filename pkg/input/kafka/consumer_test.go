@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordBatch returns a record batch of values from baseOffset
+func recordBatch(baseOffset int64, attributes int16, values ...string) []byte {
+	var records []byte
+	for i, value := range values {
+		var r []byte
+		r = append(r, 0) // attributes
+		r = appendVarint(r, 0)
+		r = appendVarint(r, int64(i))
+		r = appendVarint(r, -1) // null key
+		r = appendVarint(r, int64(len(value)))
+		r = append(r, value...)
+		r = appendVarint(r, 0) // headers
+		records = append(appendVarint(records, int64(len(r))), r...)
+	}
+	if attributes&compressionMask == compressionGzip {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		writer.Write(records)
+		writer.Close()
+		records = buf.Bytes()
+	}
+	batch := (&encoder{}).int32(0).int8(recordBatchMagic).int32(0).int16(attributes).int32(int32(len(values) - 1))
+	batch.int64(0).int64(0).int64(-1).int16(-1).int32(-1).array(len(values))
+	batch.buf = append(batch.buf, records...)
+	return (&encoder{}).int64(baseOffset).bytes(batch.buf).buf
+}
+
+func appendVarint(buf []byte, n int64) []byte {
+	varint := make([]byte, binary.MaxVarintLen64)
+	return append(buf, varint[:binary.PutVarint(varint, n)]...)
+}
+
+func TestParseRecords(t *testing.T) {
+	set := append(recordBatch(10, compressionNone, "a", "b"), recordBatch(12, compressionGzip, "c")...)
+	records, next, err := parseRecords(set, 11)
+	assert.Nil(t, err)
+	assert.Equal(t, []record{{offset: 11, value: []byte("b")}, {offset: 12, value: []byte("c")}}, records)
+	assert.Equal(t, int64(13), next)
+}
+
+func TestParseRecordsSkipsControlBatchesAndTruncatedBatches(t *testing.T) {
+	set := append(recordBatch(10, controlBatch, "commit"), recordBatch(11, compressionNone, "a")...)
+	records, next, err := parseRecords(set[:len(set)-1], 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(records))
+	assert.Equal(t, int64(11), next)
+}
+
+func TestOffsetTrackerCommitsUpToFirstPendingMessage(t *testing.T) {
+	tp := topicPartition{topic: "logs", partition: 0}
+	tracker := newOffsetTracker()
+	tracker.start(tp, 5)
+	tracker.add(tp, 5)
+	tracker.add(tp, 6)
+	tracker.add(tp, 7)
+	assert.Equal(t, 0, len(tracker.toCommit()))
+
+	tracker.ack(tp, 6)
+	assert.Equal(t, 0, len(tracker.toCommit()))
+	tracker.ack(tp, 5)
+	assert.Equal(t, map[topicPartition]int64{tp: 7}, tracker.toCommit())
+
+	tracker.setCommitted(tracker.toCommit())
+	assert.Equal(t, 0, len(tracker.toCommit()))
+	tracker.ack(tp, 7)
+	tracker.skip(tp, 10)
+	assert.Equal(t, map[topicPartition]int64{tp: 10}, tracker.toCommit())
+}
+
+func TestAssignRanges(t *testing.T) {
+	members := map[string][]string{"b": {"logs"}, "a": {"logs", "audit"}}
+	partitions := map[string][]int32{"logs": {0, 1, 2}, "audit": {0}}
+	assigned := assignRanges(members, partitions)
+	assert.Equal(t, map[string][]int32{"logs": {0, 1}, "audit": {0}}, assigned["a"])
+	assert.Equal(t, map[string][]int32{"logs": {2}}, assigned["b"])
+	assert.Equal(t, assigned["a"], decodeAssignment(encodeAssignment(assigned["a"])))
+	assert.Equal(t, []string{"logs", "audit"}, decodeSubscription(encodeSubscription([]string{"logs", "audit"})))
+}
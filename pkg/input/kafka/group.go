@@ -0,0 +1,197 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// sessionTimeout is the time after which a silent member is excluded from the group
+	sessionTimeout = 30 * time.Second
+	// rebalanceTimeout is the time the members are given to rejoin the group
+	rebalanceTimeout = 60 * time.Second
+	// the protocol of the consumer groups, with the range assignment strategy
+	consumerProtocol = "consumer"
+	rangeAssignor    = "range"
+)
+
+// A topicPartition identifies a partition
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// A membership is the participation of the consumer to a generation of its group
+type membership struct {
+	groupID     string
+	memberID    string
+	generation  int32
+	coordinator *broker
+	assignment  map[string][]int32
+}
+
+// joinGroup joins groupID through coordinator, subscribing to topics, and returns
+// the partitions assigned to the consumer. The leader of the group assigns the
+// partitions of the topics of the members, listed by metadata
+func joinGroup(coordinator *broker, groupID, memberID string, topics []string, metadata func([]string) (map[string][]int32, error)) (*membership, error) {
+	subscription := encodeSubscription(topics)
+	body := (&encoder{}).string(groupID).int32(int32(sessionTimeout / time.Millisecond)).int32(int32(rebalanceTimeout / time.Millisecond)).
+		string(memberID).string(consumerProtocol).array(1).string(rangeAssignor).bytes(subscription)
+	d, err := coordinator.request(joinGroupKey, joinGroupVersion, body, rebalanceTimeout)
+	if err != nil {
+		return nil, err
+	}
+	errorCode := d.int16()
+	generation := d.int32()
+	d.string() // protocol
+	leaderID := d.string()
+	memberID = d.string()
+	members := make(map[string][]string)
+	for i, n := 0, d.array(); i < n; i++ {
+		id := d.string()
+		members[id] = decodeSubscription(d.bytes())
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := checkError(errorCode); err != nil {
+		return nil, err
+	}
+
+	m := &membership{groupID: groupID, memberID: memberID, generation: generation, coordinator: coordinator}
+	assignments := (&encoder{}).array(0)
+	if leaderID == memberID {
+		all := []string{}
+		for _, subscribed := range members {
+			all = append(all, subscribed...)
+		}
+		partitions, err := metadata(all)
+		if err != nil {
+			return nil, err
+		}
+		assigned := assignRanges(members, partitions)
+		assignments = (&encoder{}).array(len(assigned))
+		for id, assignment := range assigned {
+			assignments.string(id).bytes(encodeAssignment(assignment))
+		}
+	}
+
+	body = (&encoder{}).string(groupID).int32(generation).string(memberID)
+	body.buf = append(body.buf, assignments.buf...)
+	d, err = coordinator.request(syncGroupKey, 0, body, rebalanceTimeout)
+	if err != nil {
+		return nil, err
+	}
+	errorCode = d.int16()
+	assignment := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := checkError(errorCode); err != nil {
+		return nil, err
+	}
+	m.assignment = decodeAssignment(assignment)
+	return m, nil
+}
+
+// heartbeat tells the coordinator the consumer is alive, and returns
+// an error when the group rebalances
+func (m *membership) heartbeat() error {
+	body := (&encoder{}).string(m.groupID).int32(m.generation).string(m.memberID)
+	d, err := m.coordinator.request(heartbeatKey, 0, body, 0)
+	if err != nil {
+		return err
+	}
+	errorCode := d.int16()
+	if d.err != nil {
+		return d.err
+	}
+	return checkError(errorCode)
+}
+
+// leave leaves the group, so that its partitions are assigned right away to the other members
+func (m *membership) leave() {
+	body := (&encoder{}).string(m.groupID).string(m.memberID)
+	m.coordinator.request(leaveGroupKey, 0, body, 0)
+}
+
+// assignRanges assigns to each member a range of the partitions of each
+// topic it subscribes to, as the range strategy of the Kafka clients
+func assignRanges(members map[string][]string, partitions map[string][]int32) map[string]map[string][]int32 {
+	subscribers := make(map[string][]string)
+	assigned := make(map[string]map[string][]int32)
+	for id, topics := range members {
+		assigned[id] = make(map[string][]int32)
+		for _, topic := range topics {
+			subscribers[topic] = append(subscribers[topic], id)
+		}
+	}
+	for topic, ids := range subscribers {
+		sort.Strings(ids)
+		topicPartitions := partitions[topic]
+		per, extra := len(topicPartitions)/len(ids), len(topicPartitions)%len(ids)
+		start := 0
+		for i, id := range ids {
+			n := per
+			if i < extra {
+				n++
+			}
+			if n > 0 {
+				assigned[id][topic] = topicPartitions[start : start+n]
+			}
+			start += n
+		}
+	}
+	return assigned
+}
+
+// encodeSubscription returns the metadata of a member subscribing to topics
+func encodeSubscription(topics []string) []byte {
+	e := (&encoder{}).int16(0).array(len(topics))
+	for _, topic := range topics {
+		e.string(topic)
+	}
+	return e.bytes(nil).buf
+}
+
+// decodeSubscription returns the topics subscribed to by a member
+func decodeSubscription(metadata []byte) []string {
+	d := &decoder{buf: metadata}
+	d.int16()
+	topics := []string{}
+	for i, n := 0, d.array(); i < n; i++ {
+		topics = append(topics, d.string())
+	}
+	return topics
+}
+
+// encodeAssignment returns the assignment of the partitions of a member
+func encodeAssignment(assignment map[string][]int32) []byte {
+	e := (&encoder{}).int16(0).array(len(assignment))
+	for topic, partitions := range assignment {
+		e.string(topic).array(len(partitions))
+		for _, partition := range partitions {
+			e.int32(partition)
+		}
+	}
+	return e.bytes(nil).buf
+}
+
+// decodeAssignment returns the partitions assigned to the consumer, by topic
+func decodeAssignment(assignment []byte) map[string][]int32 {
+	d := &decoder{buf: assignment}
+	d.int16()
+	partitions := make(map[string][]int32)
+	for i, n := 0, d.array(); i < n; i++ {
+		topic := d.string()
+		for j, m := 0, d.array(); j < m; j++ {
+			partitions[topic] = append(partitions[topic], d.int32())
+		}
+	}
+	return partitions
+}
@@ -0,0 +1,22 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/input/runner"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+)
+
+// New returns an input which consumes the topics of the kafka sources
+func New(sources []*config.IntegrationConfigLogSource, pp *pipeline.PipelineProvider) *runner.Runner {
+	return runner.New(config.KAFKA_TYPE, newWorker, sources, pp)
+}
+
+func newWorker(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (runner.Worker, error) {
+	return NewConsumer(source, outputChan)
+}
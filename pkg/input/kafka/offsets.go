@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"sync"
+)
+
+// An offsetTracker computes the offsets to commit for the partitions of a membership:
+// the acknowledgements may come out of order, such as when filtered messages are
+// acknowledged before previous ones are sent, so a partition is committed up to
+// its first message not acknowledged yet
+type offsetTracker struct {
+	mutex      sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+// partitionOffsets are the offsets of the messages of a partition sent to the pipeline
+type partitionOffsets struct {
+	// pending are the offsets not acknowledged yet or following such an offset, in order
+	pending []int64
+	acked   map[int64]bool
+	// committable is the offset to commit, committed the last committed one
+	committable int64
+	committed   int64
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[topicPartition]*partitionOffsets)}
+}
+
+// start sets the offset the consumption of tp starts from
+func (t *offsetTracker) start(tp topicPartition, offset int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.partitions[tp] = &partitionOffsets{acked: make(map[int64]bool), committable: offset, committed: offset}
+}
+
+// add tracks the message at offset of tp, sent to the pipeline
+func (t *offsetTracker) add(tp topicPartition, offset int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	p := t.partitions[tp]
+	p.pending = append(p.pending, offset)
+}
+
+// skip moves the committable offset of tp to offset when no message is pending,
+// such as when the end of a fetch was made of control records only
+func (t *offsetTracker) skip(tp topicPartition, offset int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if p := t.partitions[tp]; len(p.pending) == 0 && offset > p.committable {
+		p.committable = offset
+	}
+}
+
+// ack records that the message at offset of tp was sent, or given up on
+func (t *offsetTracker) ack(tp topicPartition, offset int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	p := t.partitions[tp]
+	p.acked[offset] = true
+	for len(p.pending) > 0 && p.acked[p.pending[0]] {
+		delete(p.acked, p.pending[0])
+		p.committable = p.pending[0] + 1
+		p.pending = p.pending[1:]
+	}
+}
+
+// toCommit returns the offsets of the partitions that progressed since the last commit
+func (t *offsetTracker) toCommit() map[topicPartition]int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	offsets := make(map[topicPartition]int64)
+	for tp, p := range t.partitions {
+		if p.committable > p.committed {
+			offsets[tp] = p.committable
+		}
+	}
+	return offsets
+}
+
+// setCommitted records that offsets were committed
+func (t *offsetTracker) setCommitted(offsets map[topicPartition]int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for tp, offset := range offsets {
+		t.partitions[tp].committed = offset
+	}
+}
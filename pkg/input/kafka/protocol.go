@@ -0,0 +1,205 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// the keys and versions of the requests sent by the consumer, supported since Kafka 0.11
+const (
+	fetchKey           = 1
+	fetchVersion       = 4
+	listOffsetsKey     = 2
+	listOffsetsVersion = 1
+	metadataKey        = 3
+	metadataVersion    = 1
+	offsetCommitKey    = 8
+	offsetCommitVer    = 2
+	offsetFetchKey     = 9
+	offsetFetchVersion = 1
+	findCoordinatorKey = 10
+	joinGroupKey       = 11
+	joinGroupVersion   = 1
+	heartbeatKey       = 12
+	leaveGroupKey      = 13
+	syncGroupKey       = 14
+)
+
+// the error codes handled by the consumer
+const (
+	errNone                = 0
+	errOffsetOutOfRange    = 1
+	errIllegalGeneration   = 22
+	errUnknownMemberID     = 25
+	errRebalanceInProgress = 27
+)
+
+// A kafkaError is an error code returned by a broker
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	return fmt.Sprintf("kafka error %d", int16(e))
+}
+
+// checkError returns the error matching code, nil for errNone
+func checkError(code int16) error {
+	if code == errNone {
+		return nil
+	}
+	return kafkaError(code)
+}
+
+// needsRejoin returns true if err means the group was rebalanced without the consumer
+func needsRejoin(err error) bool {
+	switch err {
+	case kafkaError(errIllegalGeneration), kafkaError(errUnknownMemberID), kafkaError(errRebalanceInProgress):
+		return true
+	}
+	return false
+}
+
+// An encoder builds the body of a request
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(n int8) *encoder {
+	e.buf = append(e.buf, byte(n))
+	return e
+}
+
+func (e *encoder) int16(n int16) *encoder {
+	e.buf = append(e.buf, byte(n>>8), byte(n))
+	return e
+}
+
+func (e *encoder) int32(n int32) *encoder {
+	e.buf = append(e.buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return e
+}
+
+func (e *encoder) int64(n int64) *encoder {
+	return e.int32(int32(n >> 32)).int32(int32(n))
+}
+
+func (e *encoder) string(s string) *encoder {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+	return e
+}
+
+// nullString encodes a null string
+func (e *encoder) nullString() *encoder {
+	return e.int16(-1)
+}
+
+func (e *encoder) bytes(b []byte) *encoder {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+	return e
+}
+
+// array encodes the length of an array, its items being encoded next
+func (e *encoder) array(n int) *encoder {
+	return e.int32(int32(n))
+}
+
+// A decoder reads a response, err being set when it's truncated
+type decoder struct {
+	buf []byte
+	err error
+}
+
+var errTruncated = errors.New("truncated response")
+
+// next returns the next n bytes
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = errTruncated
+		// zeros for the numbers, the strings being left empty
+		if n > 8 || n < 0 {
+			return nil
+		}
+		return make([]byte, n)
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	return int8(d.next(1)[0])
+}
+
+func (d *decoder) int16() int16 {
+	return int16(binary.BigEndian.Uint16(d.next(2)))
+}
+
+func (d *decoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.next(4)))
+}
+
+func (d *decoder) int64() int64 {
+	return int64(binary.BigEndian.Uint64(d.next(8)))
+}
+
+// string reads a string, empty when null
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// bytes reads bytes, nil when null
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// array returns the number of items of an array, 0 when null
+func (d *decoder) array() int {
+	n := int(d.int32())
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	// each item takes at least a byte
+	if n > len(d.buf) {
+		d.err = errTruncated
+		return 0
+	}
+	return n
+}
+
+// varint reads a zigzag encoded variable length integer
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Varint(d.buf)
+	if size <= 0 {
+		d.err = errTruncated
+		return 0
+	}
+	d.buf = d.buf[size:]
+	return n
+}
+
+// varbytes reads bytes prefixed with their varint length, nil when null
+func (d *decoder) varbytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	// recordBatchMagic is the version of the record batches introduced by Kafka 0.11
+	recordBatchMagic = 2
+	// recordBatchHeaderSize is the size of the header of a record batch, up to the count of records
+	recordBatchHeaderSize = 61
+	// the attributes of a record batch
+	compressionMask = 0x07
+	controlBatch    = 0x20
+	// the compression codecs of the record batches
+	compressionNone = 0
+	compressionGzip = 1
+)
+
+// A record is a message of a partition
+type record struct {
+	offset int64
+	value  []byte
+}
+
+// parseRecords returns the records of a record set from offset, and the offset
+// to fetch next. The last batch may be truncated by the size limit of the fetch,
+// its records being fetched again next time
+func parseRecords(set []byte, offset int64) ([]record, int64, error) {
+	records := []record{}
+	next := offset
+	for len(set) >= recordBatchHeaderSize {
+		d := &decoder{buf: set}
+		baseOffset := d.int64()
+		length := int(d.int32())
+		if len(set) < 12+length {
+			break
+		}
+		batch := &decoder{buf: set[12 : 12+length]}
+		set = set[12+length:]
+
+		batch.int32() // partition leader epoch
+		if magic := batch.int8(); magic != recordBatchMagic {
+			return nil, next, fmt.Errorf("unsupported message format v%d, the topic must use the format of Kafka 0.11 or later", magic)
+		}
+		batch.int32() // crc
+		attributes := batch.int16()
+		lastOffsetDelta := batch.int32()
+		batch.next(8 + 8 + 8 + 2 + 4) // timestamps, producer id and epoch, base sequence
+		count := int(batch.int32())
+		if batch.err != nil {
+			return nil, next, batch.err
+		}
+		if end := baseOffset + int64(lastOffsetDelta) + 1; end > next {
+			// even when all its records are skipped, such as those of control batches
+			next = end
+		}
+		if attributes&controlBatch != 0 {
+			continue
+		}
+		body, err := decompress(attributes&compressionMask, batch.buf)
+		if err != nil {
+			return nil, next, err
+		}
+		r := &decoder{buf: body}
+		for i := 0; i < count; i++ {
+			r.varint() // length
+			r.int8()   // attributes
+			r.varint() // timestamp delta
+			recordOffset := baseOffset + r.varint()
+			r.varbytes() // key
+			value := r.varbytes()
+			for headers := r.varint(); headers > 0 && r.err == nil; headers-- {
+				r.varbytes()
+				r.varbytes()
+			}
+			if r.err != nil {
+				return nil, next, fmt.Errorf("malformed record batch at offset %d", baseOffset)
+			}
+			if recordOffset >= offset {
+				records = append(records, record{offset: recordOffset, value: value})
+			}
+		}
+	}
+	return records, next, nil
+}
+
+// decompress returns the records of a batch compressed with codec
+func decompress(codec int16, records []byte) ([]byte, error) {
+	switch codec {
+	case compressionNone:
+		return records, nil
+	case compressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(records))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %d, the producers must use gzip or no compression", codec)
+	}
+}
@@ -6,11 +6,14 @@
 package listener
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"sync/atomic"
 
 	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/deadletter"
 	"github.com/DataDog/datadog-log-agent/pkg/decoder"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
 	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
@@ -20,6 +23,7 @@ import (
 // required by the AbstractNetworkListener to run properly
 type NetworkListener interface {
 	run()
+	stop()
 	readMessage(net.Conn, []byte) (int, error)
 }
 
@@ -29,24 +33,85 @@ type AbstractNetworkListener struct {
 	listener NetworkListener
 	pp       *pipeline.PipelineProvider
 	source   *config.IntegrationConfigLogSource
+	reorder  *reorderBuffer
+
+	// tagsPayload overrides the tags payload of the source when not nil
+	tagsPayload []byte
+
+	stopped int32
 }
 
 // Start starts the AbstractNetworkListener
 func (anl *AbstractNetworkListener) Start() {
+	if anl.source.ReorderWindow > 0 {
+		// messages from all the connections of the source are sorted together
+		anl.reorder = newReorderBuffer(anl.source.ReorderWindow, anl.pp.NextPipelineChan())
+		anl.reorder.Start()
+	}
 	go anl.listener.run()
 }
 
-// forwardMessages lets the AbstractNetworkListener forward log messages to the output channel
-func (anl *AbstractNetworkListener) forwardMessages(d *decoder.Decoder, outputChan chan message.Message) {
+// Stop closes the listening socket and the connections of the AbstractNetworkListener
+func (anl *AbstractNetworkListener) Stop() {
+	atomic.StoreInt32(&anl.stopped, 1)
+	anl.listener.stop()
+}
+
+// isStopped returns true once the AbstractNetworkListener was stopped
+func (anl *AbstractNetworkListener) isStopped() bool {
+	return atomic.LoadInt32(&anl.stopped) == 1
+}
+
+// outputChan returns the channel a new connection should forward its messages to
+func (anl *AbstractNetworkListener) outputChan() chan message.Message {
+	if anl.reorder != nil {
+		return anl.reorder.inputChan
+	}
+	return anl.pp.NextPipelineChan()
+}
+
+// connectionsCount numbers the connections of all the listeners
+var connectionsCount uint64
+
+// connectionID returns an identifier of conn unique for the lifetime of the agent
+func connectionID(conn net.Conn) string {
+	n := atomic.AddUint64(&connectionsCount, 1)
+	addr := conn.RemoteAddr()
+	if addr == nil {
+		// listening udp sockets receive from any peer
+		addr = conn.LocalAddr()
+	}
+	return fmt.Sprintf("%s-%d", addr, n)
+}
+
+// forwardMessages lets the AbstractNetworkListener forward log messages to the output channel,
+// tagged with tagsPayload when not nil
+func (anl *AbstractNetworkListener) forwardMessages(d *decoder.Decoder, outputChan chan message.Message, connID string, tagsPayload []byte) {
+	var sequence uint64
 	for output := range d.OutputChan {
 		if output.ShouldStop {
 			return
 		}
 
+		sequence++
 		netMsg := message.NewNetworkMessage(output.Content)
 		o := message.NewOrigin()
 		o.LogSource = anl.source
+		o.ConnectionID = connID
+		o.Sequence = sequence
 		netMsg.SetOrigin(o)
+		if tagsPayload != nil {
+			netMsg.SetTagsPayload(tagsPayload)
+		}
+		if anl.source.Type == config.AGENT_TYPE {
+			envelope, err := message.DecodeEnvelope(output.Content)
+			if err != nil {
+				log.Println("Dropping invalid message from edge agent:", err)
+				deadletter.Write(deadletter.ReasonInvalidEnvelope, netMsg, output.Content)
+				continue
+			}
+			envelope.Apply(netMsg)
+		}
 		outputChan <- netMsg
 	}
 }
@@ -54,9 +119,15 @@ func (anl *AbstractNetworkListener) forwardMessages(d *decoder.Decoder, outputCh
 // handleConnection listens to messages sent on a given connection
 // and forwards them to an outputChan
 func (anl *AbstractNetworkListener) handleConnection(conn net.Conn) {
+	anl.handleTaggedConnection(conn, anl.tagsPayload)
+}
+
+// handleTaggedConnection listens to messages sent on a given connection and
+// forwards them to an outputChan, tagged with tagsPayload when not nil
+func (anl *AbstractNetworkListener) handleTaggedConnection(conn net.Conn, tagsPayload []byte) {
 	d := decoder.InitializeDecoder(anl.source)
 	d.Start()
-	go anl.forwardMessages(d, anl.pp.NextPipelineChan())
+	go anl.forwardMessages(d, anl.outputChan(), connectionID(conn), tagsPayload)
 	for {
 		inBuf := make([]byte, 4096)
 		n, err := anl.listener.readMessage(conn, inBuf)
@@ -65,7 +136,9 @@ func (anl *AbstractNetworkListener) handleConnection(conn net.Conn) {
 			return
 		}
 		if err != nil {
-			log.Println("Couldn't read message from connection:", err)
+			if !anl.isStopped() {
+				log.Println("Couldn't read message from connection:", err)
+			}
 			d.Stop()
 			return
 		}
@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package listener
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// certificateCheckPeriod is the period at which the certificate files are checked for changes
+const certificateCheckPeriod = 10 * time.Second
+
+// A certificateReloader provides the certificate of a TLS listener, and reloads it
+// when its files change or on SIGHUP. New connections use the new certificate while
+// the existing ones are kept untouched
+type certificateReloader struct {
+	certPath string
+	keyPath  string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	modTime     time.Time
+}
+
+// newCertificateReloader returns a certificateReloader serving the key pair found at certPath and keyPath
+func newCertificateReloader(certPath, keyPath string) (*certificateReloader, error) {
+	r := &certificateReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+	err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// getCertificate returns the current certificate, to be used as tls.Config.GetCertificate
+func (r *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.certificate, nil
+}
+
+// start reloads the certificate when its files change or on SIGHUP
+func (r *certificateReloader) start() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	ticker := time.NewTicker(certificateCheckPeriod)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				r.reloadIfChanged()
+			case <-sighup:
+				r.reloadAndLog()
+			}
+		}
+	}()
+}
+
+// reloadIfChanged reloads the certificate if one of its files was modified since the last load
+func (r *certificateReloader) reloadIfChanged() {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		log.Println("Can't check TLS certificate:", err)
+		return
+	}
+	r.mutex.RLock()
+	changed := modTime.After(r.modTime)
+	r.mutex.RUnlock()
+	if changed {
+		r.reloadAndLog()
+	}
+}
+
+// reloadAndLog reloads the certificate, keeping the previous one on failure
+func (r *certificateReloader) reloadAndLog() {
+	err := r.reload()
+	if err != nil {
+		log.Println("Can't reload TLS certificate, keeping the previous one:", err)
+		return
+	}
+	log.Println("Reloaded TLS certificate", r.certPath)
+}
+
+// reload loads the key pair
+func (r *certificateReloader) reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.certificate = &certificate
+	r.modTime = modTime
+	return nil
+}
+
+// filesModTime returns the last modification time of the certificate and key files
+func (r *certificateReloader) filesModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, path := range []string{r.certPath, r.keyPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTime, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package listener
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+const TLS_TEST_PORT = 10513
+
+// writeCertificate writes a self-signed certificate for commonName
+func writeCertificate(t *testing.T, certPath, keyPath, commonName string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	assert.Nil(t, os.Chtimes(certPath, modTime, modTime))
+	assert.Nil(t, os.Chtimes(keyPath, modTime, modTime))
+}
+
+func commonName(t *testing.T, certificate *tls.Certificate) string {
+	cert, err := x509.ParseCertificate(certificate.Certificate[0])
+	assert.Nil(t, err)
+	return cert.Subject.CommonName
+}
+
+func TestCertificateReloaderReloadsChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certificate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now()
+
+	writeCertificate(t, certPath, keyPath, "first", now.Add(-time.Minute))
+	r, err := newCertificateReloader(certPath, keyPath)
+	assert.Nil(t, err)
+	certificate, _ := r.getCertificate(nil)
+	assert.Equal(t, "first", commonName(t, certificate))
+
+	// unchanged files are not reloaded
+	r.reloadIfChanged()
+	assert.Equal(t, certificate, r.certificate)
+
+	writeCertificate(t, certPath, keyPath, "second", now)
+	r.reloadIfChanged()
+	certificate, _ = r.getCertificate(nil)
+	assert.Equal(t, "second", commonName(t, certificate))
+
+	// a broken key pair doesn't replace the current certificate
+	assert.Nil(t, ioutil.WriteFile(keyPath, []byte("broken"), 0600))
+	assert.Nil(t, os.Chtimes(keyPath, now.Add(time.Minute), now.Add(time.Minute)))
+	r.reloadIfChanged()
+	certificate, _ = r.getCertificate(nil)
+	assert.Equal(t, "second", commonName(t, certificate))
+}
+
+func TestTCPListenerServesTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certificate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, certPath, keyPath, "logs", time.Now())
+
+	pp := pipeline.NewPipelineProvider()
+	pp.MockPipelineChans()
+	outputChan := pp.NextPipelineChan()
+	source := &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, Port: TLS_TEST_PORT, TLSCert: certPath, TLSKey: keyPath}
+	tcpl, err := NewTcpListener(pp, source, TLS_TEST_PORT)
+	assert.Nil(t, err)
+	tcpl.Start()
+
+	conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", TLS_TEST_PORT), &tls.Config{InsecureSkipVerify: true})
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Equal(t, "logs", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	fmt.Fprintf(conn, "hello world\n")
+	msg := <-outputChan
+	assert.Equal(t, "hello world", string(msg.Content()))
+}
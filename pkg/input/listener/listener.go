@@ -6,6 +6,7 @@
 package listener
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/DataDog/datadog-log-agent/pkg/config"
@@ -16,6 +17,9 @@ import (
 type Listener struct {
 	pp      *pipeline.PipelineProvider
 	sources []*config.IntegrationConfigLogSource
+
+	listeners     []*AbstractNetworkListener
+	startupErrors int
 }
 
 // New returns an initialized Listener
@@ -29,22 +33,109 @@ func New(sources []*config.IntegrationConfigLogSource, pp *pipeline.PipelineProv
 // Start starts the Listener
 func (l *Listener) Start() {
 	for _, source := range l.sources {
-		switch source.Type {
-		case config.TCP_TYPE:
-			tcpl, err := NewTcpListener(l.pp, source)
-			if err != nil {
-				log.Println("Can't start tcp source:", err)
-			} else {
-				tcpl.Start()
-			}
-		case config.UDP_TYPE:
-			udpl, err := NewUdpListener(l.pp, source)
-			if err != nil {
-				log.Println("Can't start udp source:", err)
-			} else {
-				udpl.Start()
-			}
-		default:
+		l.StartSource(source)
+	}
+}
+
+// StartSource starts listening for source, unless it's of another type or already listened for
+func (l *Listener) StartSource(source *config.IntegrationConfigLogSource) {
+	for _, anl := range l.listeners {
+		if anl.source.GetID() == source.GetID() {
+			return
 		}
 	}
+	switch source.Type {
+	case config.TCP_TYPE, config.UDP_TYPE, config.AGENT_TYPE:
+		l.startSource(source)
+	case config.UNIX_TYPE:
+		l.startUnixSource(source)
+	default:
+	}
+}
+
+// StopSource stops listening on the ports of the source with id, the
+// listeners of the other sources keeping their connections
+func (l *Listener) StopSource(id string) {
+	listeners := l.listeners[:0]
+	for _, anl := range l.listeners {
+		if anl.source.GetID() == id {
+			anl.Stop()
+		} else {
+			listeners = append(listeners, anl)
+		}
+	}
+	l.listeners = listeners
+}
+
+// startSource starts a listener on each port of source. When source listens to
+// a port range, the messages are tagged with the port they were received on
+func (l *Listener) startSource(source *config.IntegrationConfigLogSource) {
+	ports := []int{source.Port}
+	isRange := source.PortRange != ""
+	if isRange {
+		first, last, err := config.ParsePortRange(source.PortRange)
+		if err != nil {
+			log.Println("Can't start", source.Type, "source:", err)
+			l.startupErrors++
+			return
+		}
+		ports = ports[:0]
+		for port := first; port <= last; port++ {
+			ports = append(ports, port)
+		}
+	}
+	for _, port := range ports {
+		var anl *AbstractNetworkListener
+		var err error
+		if source.Type == config.TCP_TYPE || source.Type == config.AGENT_TYPE {
+			// edge agents forward their logs to aggregator agents over tcp
+			anl, err = NewTcpListener(l.pp, source, port)
+		} else {
+			anl, err = NewUdpListener(l.pp, source, port)
+		}
+		if err != nil {
+			log.Println("Can't start", source.Type, "source:", err)
+			l.startupErrors++
+			continue
+		}
+		if isRange {
+			anl.tagsPayload = portTagsPayload(source, port)
+		}
+		anl.Start()
+		l.listeners = append(l.listeners, anl)
+	}
+}
+
+// startUnixSource starts a listener on the socket of source
+func (l *Listener) startUnixSource(source *config.IntegrationConfigLogSource) {
+	anl, err := NewUnixListener(l.pp, source, source.Path)
+	if err != nil {
+		log.Println("Can't start", source.Type, "source:", err)
+		l.startupErrors++
+		return
+	}
+	anl.Start()
+	l.listeners = append(l.listeners, anl)
+}
+
+// Stop stops listening on all the ports
+func (l *Listener) Stop() {
+	for _, anl := range l.listeners {
+		anl.Stop()
+	}
+	l.listeners = nil
+}
+
+// StartupErrors returns the number of ports that couldn't be listened to
+func (l *Listener) StartupErrors() int {
+	return l.startupErrors
+}
+
+// portTagsPayload returns the tags payload of the messages of source received on port
+func portTagsPayload(source *config.IntegrationConfigLogSource, port int) []byte {
+	tags := fmt.Sprintf("port:%d", port)
+	if source.Tags != "" {
+		tags = source.Tags + "," + tags
+	}
+	return config.BuildTagsPayload(source, tags)
 }
@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerStartsOneListenerPerPortOfRange(t *testing.T) {
+	pp := pipeline.NewPipelineProvider()
+	pp.MockPipelineChans()
+	outputChan := pp.NextPipelineChan()
+	source := &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, PortRange: "10530-10531", Tags: "env:test"}
+	New([]*config.IntegrationConfigLogSource{source}, pp).Start()
+
+	for _, port := range []int{10530, 10531} {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		assert.Nil(t, err)
+		fmt.Fprintf(conn, "hello world\n")
+		msg := <-outputChan
+		assert.Equal(t, "hello world", string(msg.Content()))
+		assert.Equal(t, source, msg.GetOrigin().LogSource)
+		assert.Equal(t, fmt.Sprintf("[dd ddtags=\"env:test,port:%d\"]", port), string(msg.GetTagsPayload()))
+		conn.Close()
+	}
+}
+
+func TestListenerStopsAndStartsOneSource(t *testing.T) {
+	pp := pipeline.NewPipelineProvider()
+	pp.MockPipelineChans()
+	a := &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, Port: 10533}
+	b := &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, Port: 10534}
+	l := New([]*config.IntegrationConfigLogSource{a, b}, pp)
+	l.Start()
+	defer l.Stop()
+
+	l.StopSource(a.GetID())
+	assert.Equal(t, 1, len(l.listeners))
+	assert.Equal(t, b, l.listeners[0].source)
+
+	l.StartSource(a)
+	l.StartSource(b)
+	assert.Equal(t, 2, len(l.listeners))
+	assert.Equal(t, 0, l.StartupErrors())
+}
+
+func TestPortTagsPayload(t *testing.T) {
+	source := &config.IntegrationConfigLogSource{Source: "syslog"}
+	assert.Equal(t, "[dd ddsource=\"syslog\"][dd ddtags=\"port:10500\"]", string(portTagsPayload(source, 10500)))
+}
+
+func TestListenerDecodesEnvelopesOfEdgeAgents(t *testing.T) {
+	pp := pipeline.NewPipelineProvider()
+	pp.MockPipelineChans()
+	outputChan := pp.NextPipelineChan()
+	source := &config.IntegrationConfigLogSource{Type: config.AGENT_TYPE, Port: 10532}
+	New([]*config.IntegrationConfigLogSource{source}, pp).Start()
+
+	conn, err := net.Dial("tcp", "localhost:10532")
+	assert.Nil(t, err)
+	defer conn.Close()
+	fmt.Fprintf(conn, "not an envelope\n")
+	fmt.Fprintf(conn, "{\"message\":\"hello world\",\"hostname\":\"edge-host\",\"service\":\"app\"}\n")
+	msg := <-outputChan
+	assert.Equal(t, "hello world", string(msg.Content()))
+	assert.Equal(t, "edge-host", msg.GetHostname())
+	assert.Equal(t, "app", msg.GetService())
+	assert.Equal(t, source, msg.GetOrigin().LogSource)
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// peerTags returns the process_name and user tags of the process at the other
+// end of conn, read with SO_PEERCRED, nil when it's not a unix socket
+func peerTags(conn net.Conn) []string {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		log.Println("Can't read the credentials of the peer of", conn.LocalAddr(), ":", err)
+		return nil
+	}
+	tags := []string{}
+	if name := processName(cred.Pid); name != "" {
+		tags = append(tags, "process_name:"+name)
+	}
+	return append(tags, "user:"+userName(cred.Uid))
+}
+
+// processName returns the name of the process pid, "" when it already exited
+func processName(pid int32) string {
+	comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// userName returns the name of the user uid, or uid when it has no name
+func userName(uid uint32) string {
+	id := strconv.FormatUint(uint64(uid), 10)
+	u, err := user.LookupId(id)
+	if err != nil {
+		return id
+	}
+	return u.Username
+}
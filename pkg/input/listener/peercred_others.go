@@ -0,0 +1,16 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build !linux
+// +build !linux
+
+package listener
+
+import "net"
+
+// peerTags returns nil, SO_PEERCRED being specific to linux
+func peerTags(conn net.Conn) []string {
+	return nil
+}
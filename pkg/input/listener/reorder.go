@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package listener
+
+import (
+	"sort"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+)
+
+const (
+	reorderFlushPeriod   = 100 * time.Millisecond
+	reorderMaxBufferSize = 10000
+)
+
+// bufferedMessage is a message waiting in a reorderBuffer
+type bufferedMessage struct {
+	msg       message.Message
+	timestamp time.Time
+	received  time.Time
+}
+
+// A reorderBuffer holds the messages of a network source during a window,
+// and releases them sorted by timestamp, for devices sending logs out of order.
+// Messages without a timestamp are considered stamped on reception
+type reorderBuffer struct {
+	window     time.Duration
+	inputChan  chan message.Message
+	outputChan chan message.Message
+	buffer     []*bufferedMessage
+	now        func() time.Time
+}
+
+// newReorderBuffer returns an initialized reorderBuffer
+func newReorderBuffer(window time.Duration, outputChan chan message.Message) *reorderBuffer {
+	return &reorderBuffer{
+		window:     window,
+		inputChan:  make(chan message.Message),
+		outputChan: outputChan,
+		now:        time.Now,
+	}
+}
+
+// Start starts the reorderBuffer
+func (r *reorderBuffer) Start() {
+	go r.run()
+}
+
+// run buffers the messages and periodically releases the ones out of the window
+func (r *reorderBuffer) run() {
+	ticker := time.NewTicker(reorderFlushPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-r.inputChan:
+			r.add(msg)
+			if len(r.buffer) > reorderMaxBufferSize {
+				r.release(r.buffer[0])
+				r.buffer = r.buffer[1:]
+			}
+		case <-ticker.C:
+			r.flush()
+		}
+	}
+}
+
+// add inserts msg in the buffer, after the messages with the same timestamp
+func (r *reorderBuffer) add(msg message.Message) {
+	received := r.now()
+	timestamp, ok := message.ParseTimestamp(msg)
+	if !ok {
+		timestamp = received
+	}
+	i := sort.Search(len(r.buffer), func(i int) bool {
+		return r.buffer[i].timestamp.After(timestamp)
+	})
+	r.buffer = append(r.buffer, nil)
+	copy(r.buffer[i+1:], r.buffer[i:])
+	r.buffer[i] = &bufferedMessage{msg: msg, timestamp: timestamp, received: received}
+}
+
+// flush releases the messages older than the window, or buffered for longer than the window
+func (r *reorderBuffer) flush() {
+	limit := r.now().Add(-r.window)
+	released := 0
+	for _, buffered := range r.buffer {
+		if buffered.timestamp.After(limit) && buffered.received.After(limit) {
+			break
+		}
+		r.release(buffered)
+		released++
+	}
+	r.buffer = r.buffer[released:]
+}
+
+func (r *reorderBuffer) release(buffered *bufferedMessage) {
+	r.outputChan <- buffered.msg
+}
@@ -0,0 +1,39 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package listener
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorderBufferSortsMessagesByTimestamp(t *testing.T) {
+	outputChan := make(chan message.Message, 10)
+	r := newReorderBuffer(time.Minute, outputChan)
+	now := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	r.add(message.NewNetworkMessage([]byte("<46>1 2017-10-01T11:58:30Z host app - - - second")))
+	r.add(message.NewNetworkMessage([]byte("<46>1 2017-10-01T11:58:00Z host app - - - first")))
+	r.add(message.NewNetworkMessage([]byte("<46>1 2017-10-01T11:59:30Z host app - - - third")))
+	r.add(message.NewNetworkMessage([]byte("no timestamp")))
+
+	// only the messages out of the window are released
+	r.flush()
+	assert.Equal(t, 2, len(outputChan))
+	assert.Contains(t, string((<-outputChan).Content()), "first")
+	assert.Contains(t, string((<-outputChan).Content()), "second")
+
+	now = now.Add(time.Minute)
+	r.flush()
+	assert.Equal(t, 2, len(outputChan))
+	assert.Contains(t, string((<-outputChan).Content()), "third")
+	assert.Equal(t, "no timestamp", string((<-outputChan).Content()))
+	assert.Equal(t, 0, len(r.buffer))
+}
@@ -6,9 +6,11 @@
 package listener
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"sync"
 
 	"github.com/DataDog/datadog-log-agent/pkg/config"
 	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
@@ -19,18 +21,31 @@ import (
 type TcpListener struct {
 	listener net.Listener
 	anl      *AbstractNetworkListener
+
+	mutex sync.Mutex
+	conns map[net.Conn]struct{}
 }
 
-// NewTcpListener returns an initialized NewTcpListener
-func NewTcpListener(pp *pipeline.PipelineProvider, source *config.IntegrationConfigLogSource) (*AbstractNetworkListener, error) {
-	log.Println("Starting TCP forwarder on port", source.Port)
+// NewTcpListener returns an initialized NewTcpListener listening to port
+func NewTcpListener(pp *pipeline.PipelineProvider, source *config.IntegrationConfigLogSource, port int) (*AbstractNetworkListener, error) {
+	log.Println("Starting TCP forwarder on port", port)
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", source.Port))
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return nil, err
 	}
+	if source.TLSCert != "" {
+		reloader, err := newCertificateReloader(source.TLSCert, source.TLSKey)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		reloader.start()
+		listener = tls.NewListener(listener, &tls.Config{GetCertificate: reloader.getCertificate})
+	}
 	tcpListener := &TcpListener{
 		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
 	}
 	anl := &AbstractNetworkListener{
 		listener: tcpListener,
@@ -46,10 +61,34 @@ func (tcpListener *TcpListener) run() {
 	for {
 		conn, err := tcpListener.listener.Accept()
 		if err != nil {
-			log.Println("Can't listen:", err)
+			if !tcpListener.anl.isStopped() {
+				log.Println("Can't listen:", err)
+			}
 			return
 		}
-		go tcpListener.anl.handleConnection(conn)
+		go tcpListener.handleConnection(conn)
+	}
+}
+
+// handleConnection reads the messages of conn, tracking it until it's closed
+func (tcpListener *TcpListener) handleConnection(conn net.Conn) {
+	tcpListener.mutex.Lock()
+	tcpListener.conns[conn] = struct{}{}
+	tcpListener.mutex.Unlock()
+	tcpListener.anl.handleConnection(conn)
+	tcpListener.mutex.Lock()
+	delete(tcpListener.conns, conn)
+	tcpListener.mutex.Unlock()
+	conn.Close()
+}
+
+// stop stops accepting connections and closes the open ones
+func (tcpListener *TcpListener) stop() {
+	tcpListener.listener.Close()
+	tcpListener.mutex.Lock()
+	defer tcpListener.mutex.Unlock()
+	for conn := range tcpListener.conns {
+		conn.Close()
 	}
 }
 
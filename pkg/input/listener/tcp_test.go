@@ -7,6 +7,7 @@ package listener
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"testing"
 
@@ -32,18 +33,43 @@ func (suite *TCPTestSuite) SetupTest() {
 	suite.pp.MockPipelineChans()
 	suite.outputChan = suite.pp.NextPipelineChan()
 	suite.source = &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, Port: TCP_TEST_PORT}
-	tcpl, err := NewTcpListener(suite.pp, suite.source)
+	tcpl, err := NewTcpListener(suite.pp, suite.source, TCP_TEST_PORT)
 	suite.Nil(err)
 	suite.tcpl = tcpl
 	suite.tcpl.Start()
 }
 
+func (suite *TCPTestSuite) TearDownTest() {
+	suite.tcpl.Stop()
+}
+
 func (suite *TCPTestSuite) TestTCPReceivesMessages() {
 	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", TCP_TEST_PORT))
 	suite.Nil(err)
 	fmt.Fprintf(conn, "hello world\n")
+	fmt.Fprintf(conn, "hello again\n")
 	msg := <-suite.outputChan
 	suite.Equal("hello world", string(msg.Content()))
+	suite.Equal(uint64(1), msg.GetOrigin().Sequence)
+	connID := msg.GetOrigin().ConnectionID
+	suite.Contains(connID, "127.0.0.1:")
+	msg = <-suite.outputChan
+	suite.Equal(uint64(2), msg.GetOrigin().Sequence)
+	suite.Equal(connID, msg.GetOrigin().ConnectionID)
+}
+
+func (suite *TCPTestSuite) TestTCPStops() {
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", TCP_TEST_PORT))
+	suite.Nil(err)
+	defer conn.Close()
+	fmt.Fprintf(conn, "hello world\n")
+	<-suite.outputChan
+
+	suite.tcpl.Stop()
+	_, err = conn.Read(make([]byte, 1))
+	suite.Equal(io.EOF, err)
+	_, err = net.Dial("tcp", fmt.Sprintf("localhost:%d", TCP_TEST_PORT))
+	suite.NotNil(err)
 }
 
 func TestTCPTestSuite(t *testing.T) {
@@ -21,11 +21,11 @@ type UdpListener struct {
 	anl  *AbstractNetworkListener
 }
 
-// NewUdpListener returns an initialized NewUdpListener
-func NewUdpListener(pp *pipeline.PipelineProvider, source *config.IntegrationConfigLogSource) (*AbstractNetworkListener, error) {
-	log.Println("Starting UDP forwarder on port", source.Port)
+// NewUdpListener returns an initialized NewUdpListener listening to port
+func NewUdpListener(pp *pipeline.PipelineProvider, source *config.IntegrationConfigLogSource, port int) (*AbstractNetworkListener, error) {
+	log.Println("Starting UDP forwarder on port", port)
 
-	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", source.Port))
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return nil, err
 	}
@@ -52,6 +52,11 @@ func (udpListener *UdpListener) run() {
 	go udpListener.anl.handleConnection(udpListener.conn)
 }
 
+// stop closes the udp socket
+func (udpListener *UdpListener) stop() {
+	udpListener.conn.Close()
+}
+
 func (udpListener *UdpListener) readMessage(conn net.Conn, inBuf []byte) (int, error) {
 	n, _, err := udpListener.conn.ReadFromUDP(inBuf)
 	return n, err
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package listener
+
+import (
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+)
+
+// A UnixListener listens to bytes on the connections of a unix stream socket
+// and sends log lines to an output channel, tagged with the process and the
+// user of the peer of each connection when they can be known
+type UnixListener struct {
+	listener net.Listener
+	anl      *AbstractNetworkListener
+
+	mutex sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// NewUnixListener returns an initialized UnixListener listening to the socket at path
+func NewUnixListener(pp *pipeline.PipelineProvider, source *config.IntegrationConfigLogSource, path string) (*AbstractNetworkListener, error) {
+	log.Println("Starting unix socket forwarder on", path)
+
+	// the socket of a previous run is left behind when the agent was killed
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	unixListener := &UnixListener{
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	anl := &AbstractNetworkListener{
+		listener: unixListener,
+		pp:       pp,
+		source:   source,
+	}
+	unixListener.anl = anl
+	return anl, nil
+}
+
+// run lets the listener handle incoming connections
+func (unixListener *UnixListener) run() {
+	for {
+		conn, err := unixListener.listener.Accept()
+		if err != nil {
+			if !unixListener.anl.isStopped() {
+				log.Println("Can't listen:", err)
+			}
+			return
+		}
+		go unixListener.handleConnection(conn)
+	}
+}
+
+// handleConnection reads the messages of conn, tagged with its peer, tracking it until it's closed
+func (unixListener *UnixListener) handleConnection(conn net.Conn) {
+	unixListener.mutex.Lock()
+	unixListener.conns[conn] = struct{}{}
+	unixListener.mutex.Unlock()
+	unixListener.anl.handleTaggedConnection(conn, peerTagsPayload(unixListener.anl.source, conn))
+	unixListener.mutex.Lock()
+	delete(unixListener.conns, conn)
+	unixListener.mutex.Unlock()
+	conn.Close()
+}
+
+// stop stops accepting connections, removing the socket, and closes the open ones
+func (unixListener *UnixListener) stop() {
+	unixListener.listener.Close()
+	unixListener.mutex.Lock()
+	defer unixListener.mutex.Unlock()
+	for conn := range unixListener.conns {
+		conn.Close()
+	}
+}
+
+func (unixListener *UnixListener) readMessage(conn net.Conn, inBuf []byte) (int, error) {
+	return conn.Read(inBuf)
+}
+
+// peerTagsPayload returns the tags payload of the messages of source received on
+// conn, with the name of the process and the user of the peer, or nil when the
+// peer can't be known
+func peerTagsPayload(source *config.IntegrationConfigLogSource, conn net.Conn) []byte {
+	tags := peerTags(conn)
+	if len(tags) == 0 {
+		return nil
+	}
+	if source.Tags != "" {
+		tags = append([]string{source.Tags}, tags...)
+	}
+	return config.BuildTagsPayload(source, strings.Join(tags, ","))
+}
@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnixListenerTagsMessagesWithPeer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unix-listener")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs.sock")
+
+	pp := pipeline.NewPipelineProvider()
+	pp.MockPipelineChans()
+	outputChan := pp.NextPipelineChan()
+	source := &config.IntegrationConfigLogSource{Type: config.UNIX_TYPE, Path: path, Tags: "env:prod"}
+	source.TagsPayload = config.BuildTagsPayload(source, source.Tags)
+	anl, err := NewUnixListener(pp, source, path)
+	assert.Nil(t, err)
+	anl.Start()
+	defer anl.Stop()
+
+	conn, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+	fmt.Fprintf(conn, "hello world\n")
+	msg := <-outputChan
+	assert.Equal(t, "hello world", string(msg.Content()))
+	if runtime.GOOS == "linux" {
+		tagsPayload := string(msg.GetTagsPayload())
+		assert.Contains(t, tagsPayload, "env:prod")
+		assert.Contains(t, tagsPayload, "process_name:")
+		assert.Contains(t, tagsPayload, "user:")
+	}
+}
@@ -0,0 +1,196 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// the types of the MQTT 3.1.1 control packets used by the subscriber
+const (
+	connectPacket     = 1
+	connackPacket     = 2
+	publishPacket     = 3
+	pubackPacket      = 4
+	subscribePacket   = 8
+	subackPacket      = 9
+	pingreqPacket     = 12
+	pingrespPacket    = 13
+	disconnectPacket  = 14
+	maxRemainingBytes = 268435455
+)
+
+// connackReasons describes the return codes of a refused connection
+var connackReasons = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// A packet is an MQTT control packet
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// encode returns the bytes of p on the wire
+func (p packet) encode() []byte {
+	buf := []byte{p.kind<<4 | p.flags}
+	length := len(p.body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		buf = append(buf, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(buf, p.body...)
+}
+
+// readPacket reads the next control packet sent by the broker
+func readPacket(reader *bufio.Reader) (packet, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, multiplier := 0, 1
+	for {
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if length > maxRemainingBytes {
+			return packet{}, errors.New("malformed packet length")
+		}
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// appendString appends s prefixed with its length
+func appendString(buf []byte, s string) []byte {
+	buf = appendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func appendUint16(buf []byte, n uint16) []byte {
+	return append(buf, byte(n>>8), byte(n))
+}
+
+// newConnect returns the CONNECT packet of a clean session, the broker
+// dropping the client when it sends nothing during keepAlive seconds
+func newConnect(clientID, username, password string, keepAlive uint16) packet {
+	var flags byte = 0x02 // clean session
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags) // protocol level 3.1.1
+	body = appendUint16(body, keepAlive)
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	return packet{kind: connectPacket, body: body}
+}
+
+// checkConnack returns an error if the broker refused the connection
+func checkConnack(p packet) error {
+	if p.kind != connackPacket || len(p.body) != 2 {
+		return fmt.Errorf("unexpected packet %d instead of CONNACK", p.kind)
+	}
+	if code := p.body[1]; code != 0 {
+		if reason, ok := connackReasons[code]; ok {
+			return fmt.Errorf("connection refused: %s", reason)
+		}
+		return fmt.Errorf("connection refused: code %d", code)
+	}
+	return nil
+}
+
+// newSubscribe returns the SUBSCRIBE packet to topics, with quality of service 1
+func newSubscribe(id uint16, topics []string) packet {
+	body := appendUint16(nil, id)
+	for _, topic := range topics {
+		body = appendString(body, topic)
+		body = append(body, 1)
+	}
+	return packet{kind: subscribePacket, flags: 0x02, body: body}
+}
+
+// checkSuback returns an error if the broker refused a subscription
+func checkSuback(p packet, topics []string) error {
+	if len(p.body) != 2+len(topics) {
+		return errors.New("malformed SUBACK")
+	}
+	for i, code := range p.body[2:] {
+		if code == 0x80 {
+			return fmt.Errorf("subscription to %s refused", topics[i])
+		}
+	}
+	return nil
+}
+
+// A publication is a message published on a topic
+type publication struct {
+	topic   string
+	payload []byte
+	// id is the packet identifier to acknowledge, 0 for quality of service 0
+	id uint16
+}
+
+// parsePublish returns the publication carried by a PUBLISH packet
+func parsePublish(p packet) (publication, error) {
+	if len(p.body) < 2 {
+		return publication{}, errors.New("malformed PUBLISH")
+	}
+	length := int(binary.BigEndian.Uint16(p.body))
+	rest := p.body[2:]
+	if len(rest) < length {
+		return publication{}, errors.New("malformed PUBLISH")
+	}
+	pub := publication{topic: string(rest[:length])}
+	rest = rest[length:]
+	if qos := (p.flags >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return publication{}, errors.New("malformed PUBLISH")
+		}
+		pub.id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	pub.payload = rest
+	return pub, nil
+}
+
+// newPuback returns the acknowledgement of the publication id
+func newPuback(id uint16) packet {
+	return packet{kind: pubackPacket, body: appendUint16(nil, id)}
+}
@@ -0,0 +1,294 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
+)
+
+const (
+	// ioTimeout bounds the time spent connecting and subscribing
+	ioTimeout = 10 * time.Second
+	// keepAlive is the number of seconds after which the broker drops a silent client
+	keepAlive = 60
+	// defaultRetryPeriod is the time waited before reconnecting to the broker
+	defaultRetryPeriod = 5 * time.Second
+	// maxCachedTopics bounds the number of topics whose tags payload is kept
+	maxCachedTopics = 1000
+)
+
+// A Subscriber receives the messages published on the topics of
+// an mqtt source, and sends them to its pipeline as logs
+type Subscriber struct {
+	source     *config.IntegrationConfigLogSource
+	outputChan chan message.Message
+	tlsConfig  *tls.Config
+
+	retryPeriod time.Duration
+	// topicTags caches the tags payloads extracted from the topics
+	topicTags map[string][]byte
+	lastError string
+
+	mutex   sync.Mutex
+	conn    net.Conn
+	stopped bool
+	stop    chan struct{}
+}
+
+// NewSubscriber returns a Subscriber to the topics of source, or an error if its TLS files can't be loaded
+func NewSubscriber(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (*Subscriber, error) {
+	var tlsConfig *tls.Config
+	if source.UseTLS {
+		var err error
+		tlsConfig, err = utils.NewClientTLSConfig(source.Broker, source.TLSCA, source.TLSCert, source.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Subscriber{
+		source:      source,
+		outputChan:  outputChan,
+		tlsConfig:   tlsConfig,
+		retryPeriod: defaultRetryPeriod,
+		topicTags:   make(map[string][]byte),
+		stop:        make(chan struct{}),
+	}, nil
+}
+
+// Start starts receiving messages
+func (s *Subscriber) Start() {
+	go s.run()
+}
+
+// Stop disconnects from the broker
+func (s *Subscriber) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	close(s.stop)
+	if s.conn != nil {
+		s.conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+		s.conn.Write(packet{kind: disconnectPacket}.encode())
+		s.conn.Close()
+	}
+}
+
+// run receives messages until stopped, reconnecting when the connection is lost
+func (s *Subscriber) run() {
+	for {
+		conn, reader, err := s.connect()
+		if err == nil {
+			s.setError(nil)
+			err = s.receive(conn, reader)
+		}
+		if s.isStopped() {
+			return
+		}
+		s.setError(err)
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.retryPeriod):
+		}
+	}
+}
+
+// connect opens a session with the broker and subscribes to the topics
+func (s *Subscriber) connect() (net.Conn, *bufio.Reader, error) {
+	dialer := &net.Dialer{Timeout: ioTimeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.source.Broker, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.source.Broker)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(ioTimeout))
+	reader := bufio.NewReader(conn)
+	err = s.handshake(conn, reader)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopped {
+		conn.Close()
+		return nil, nil, fmt.Errorf("stopped")
+	}
+	s.conn = conn
+	return conn, reader, nil
+}
+
+// handshake sends the CONNECT and SUBSCRIBE packets and checks their acknowledgements
+func (s *Subscriber) handshake(conn net.Conn, reader *bufio.Reader) error {
+	connect := newConnect(s.clientID(), s.source.Username, s.source.Password, keepAlive)
+	if _, err := conn.Write(connect.encode()); err != nil {
+		return err
+	}
+	p, err := readPacket(reader)
+	if err == nil {
+		err = checkConnack(p)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(newSubscribe(1, s.source.Topics).encode()); err != nil {
+		return err
+	}
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return err
+		}
+		// retained messages may be published before the acknowledgement
+		if p.kind == subackPacket {
+			return checkSuback(p, s.source.Topics)
+		}
+		if err := s.handle(conn, p); err != nil {
+			return err
+		}
+	}
+}
+
+// clientID returns the identifier of the client, unique by source unless configured
+func (s *Subscriber) clientID() string {
+	if s.source.ClientID != "" {
+		return s.source.ClientID
+	}
+	// the hash of the source ID, brokers may reject identifiers longer than 23 characters
+	id := s.source.GetID()
+	return "dd" + id[strings.LastIndex(id, ":")+1:]
+}
+
+// receive handles the packets of the broker until the connection is lost,
+// pinging it so that it keeps the session alive
+func (s *Subscriber) receive(conn net.Conn, reader *bufio.Reader) error {
+	done := make(chan struct{})
+	defer close(done)
+	go s.ping(conn, done)
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return err
+		}
+		if err := s.handle(conn, p); err != nil {
+			return err
+		}
+	}
+}
+
+// ping sends a PINGREQ every half keepAlive, until done is closed
+func (s *Subscriber) ping(conn net.Conn, done chan struct{}) {
+	ticker := time.NewTicker(keepAlive * time.Second / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.write(conn, packet{kind: pingreqPacket})
+		}
+	}
+}
+
+// handle forwards the publications, acknowledging them once in the pipeline
+func (s *Subscriber) handle(conn net.Conn, p packet) error {
+	if p.kind != publishPacket {
+		// PINGRESP, or acknowledgements of the packets we don't send
+		return nil
+	}
+	pub, err := parsePublish(p)
+	if err != nil {
+		return err
+	}
+	s.forward(pub)
+	if pub.id != 0 {
+		return s.write(conn, newPuback(pub.id))
+	}
+	return nil
+}
+
+// forward sends the payload of pub to the pipeline
+func (s *Subscriber) forward(pub publication) {
+	content := bytes.TrimRight(pub.payload, "\r\n")
+	if len(content) == 0 {
+		return
+	}
+	msg := message.NewNetworkMessage(content)
+	origin := message.NewOrigin()
+	origin.LogSource = s.source
+	msg.SetOrigin(origin)
+	if s.source.TopicTagsReg != nil {
+		msg.SetTagsPayload(s.tagsPayload(pub.topic))
+	}
+	s.outputChan <- msg
+}
+
+// tagsPayload returns the tags payload of the messages published on topic
+func (s *Subscriber) tagsPayload(topic string) []byte {
+	payload, ok := s.topicTags[topic]
+	if !ok {
+		if len(s.topicTags) >= maxCachedTopics {
+			s.topicTags = make(map[string][]byte)
+		}
+		payload = config.BuildTagsPayload(s.source, config.TopicTags(s.source, topic))
+		s.topicTags[topic] = payload
+	}
+	return payload
+}
+
+// write sends p to the broker, the reader and the pinger sharing the connection
+func (s *Subscriber) write(conn net.Conn, p packet) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+	_, err := conn.Write(p.encode())
+	return err
+}
+
+func (s *Subscriber) isStopped() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stopped
+}
+
+// setError logs the connection errors when they change
+func (s *Subscriber) setError(err error) {
+	description := ""
+	if err != nil {
+		description = err.Error()
+	}
+	if description == s.lastError {
+		return
+	}
+	s.lastError = description
+	if err != nil {
+		log.Printf("Can't receive logs from mqtt broker %s: %v", s.source.Broker, err)
+	} else {
+		log.Println("Subscribed to mqtt broker", s.source.Broker)
+	}
+}
@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBroker accepts one subscriber and publishes a message to it
+func fakeBroker(t *testing.T, listener net.Listener, acks chan uint16) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	p, err := readPacket(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(connectPacket), p.kind)
+	conn.Write(packet{kind: connackPacket, body: []byte{0, 0}}.encode())
+
+	p, err = readPacket(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(subscribePacket), p.kind)
+	conn.Write(packet{kind: subackPacket, body: []byte{0, 1, 1}}.encode())
+
+	body := appendString(nil, "devices/sensor-12/logs")
+	body = appendUint16(body, 7)
+	body = append(body, "temperature too high\n"...)
+	conn.Write(packet{kind: publishPacket, flags: 0x02, body: body}.encode())
+
+	for {
+		p, err = readPacket(reader)
+		if err != nil {
+			return
+		}
+		if p.kind == pubackPacket {
+			acks <- uint16(p.body[0])<<8 | uint16(p.body[1])
+		}
+	}
+}
+
+func TestSubscriberForwardsPublications(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	acks := make(chan uint16, 1)
+	go fakeBroker(t, listener, acks)
+
+	source := &config.IntegrationConfigLogSource{
+		Type:      config.MQTT_TYPE,
+		Broker:    listener.Addr().String(),
+		Topics:    []string{"devices/+/logs"},
+		TopicTags: "devices/{device}/logs",
+	}
+	source.TopicTagsReg, _ = config.CompilePathTags(source.TopicTags)
+	outputChan := make(chan message.Message, 1)
+	s, err := NewSubscriber(source, outputChan)
+	assert.Nil(t, err)
+	s.Start()
+	defer s.Stop()
+
+	msg := <-outputChan
+	assert.Equal(t, "temperature too high", string(msg.Content()))
+	assert.Equal(t, `[dd ddtags="device:sensor-12"]`, string(msg.GetTagsPayload()))
+	assert.Equal(t, uint16(7), <-acks)
+}
+
+func TestCheckConnack(t *testing.T) {
+	assert.Nil(t, checkConnack(packet{kind: connackPacket, body: []byte{0, 0}}))
+	assert.Equal(t, "connection refused: bad user name or password", checkConnack(packet{kind: connackPacket, body: []byte{0, 4}}).Error())
+	assert.NotNil(t, checkConnack(packet{kind: pingrespPacket}))
+}
+
+func TestPacketLength(t *testing.T) {
+	p := packet{kind: publishPacket, body: make([]byte, 321)}
+	encoded := p.encode()
+	assert.Equal(t, []byte{0x30, 0xc1, 0x02}, encoded[:3])
+
+	decoded, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	assert.Nil(t, err)
+	assert.Equal(t, p, decoded)
+}
@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package runner
+
+import (
+	"log"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+)
+
+// A Worker collects the logs of a source, such as the subscriber of an mqtt source
+type Worker interface {
+	Start()
+	Stop()
+}
+
+// A WorkerFactory returns the worker collecting the logs of source to outputChan,
+// or an error if it can't be started
+type WorkerFactory func(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (Worker, error)
+
+// A Runner runs a worker for each source of a type
+type Runner struct {
+	sourceType string
+	newWorker  WorkerFactory
+	sources    []*config.IntegrationConfigLogSource
+	pp         *pipeline.PipelineProvider
+	// workers are the workers running, by source id
+	workers map[string]Worker
+
+	startupErrors int
+}
+
+// New returns a Runner of the workers returned by newWorker for the sources of sourceType
+func New(sourceType string, newWorker WorkerFactory, sources []*config.IntegrationConfigLogSource, pp *pipeline.PipelineProvider) *Runner {
+	typedSources := []*config.IntegrationConfigLogSource{}
+	for _, source := range sources {
+		if source.Type == sourceType {
+			typedSources = append(typedSources, source)
+		}
+	}
+	return &Runner{
+		sourceType: sourceType,
+		newWorker:  newWorker,
+		sources:    typedSources,
+		pp:         pp,
+		workers:    make(map[string]Worker),
+	}
+}
+
+// Start starts the workers of all the sources
+func (r *Runner) Start() {
+	for _, source := range r.sources {
+		r.StartSource(source)
+	}
+}
+
+// StartSource starts the worker of source, unless it's of another type or already running
+func (r *Runner) StartSource(source *config.IntegrationConfigLogSource) {
+	if source.Type != r.sourceType {
+		return
+	}
+	if _, running := r.workers[source.GetID()]; running {
+		return
+	}
+	w, err := r.newWorker(source, r.pp.NextPipelineChan())
+	if err != nil {
+		log.Println("Can't start", r.sourceType, "source:", err)
+		r.startupErrors++
+		return
+	}
+	w.Start()
+	r.workers[source.GetID()] = w
+}
+
+// StopSource stops the worker of the source with id, if running
+func (r *Runner) StopSource(id string) {
+	if w, running := r.workers[id]; running {
+		w.Stop()
+		delete(r.workers, id)
+	}
+}
+
+// Stop stops all the workers
+func (r *Runner) Stop() {
+	for id := range r.workers {
+		r.StopSource(id)
+	}
+}
+
+// StartupErrors returns the number of sources that couldn't be started
+func (r *Runner) StartupErrors() int {
+	return r.startupErrors
+}
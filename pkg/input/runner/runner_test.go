@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package runner
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeWorker struct {
+	running bool
+}
+
+func (w *fakeWorker) Start() { w.running = true }
+func (w *fakeWorker) Stop()  { w.running = false }
+
+func TestRunner(t *testing.T) {
+	pp := pipeline.NewPipelineProvider()
+	pp.MockPipelineChans()
+	workers := []*fakeWorker{}
+	newWorker := func(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (Worker, error) {
+		if source.Broker == "" {
+			return nil, errors.New("no broker")
+		}
+		w := &fakeWorker{}
+		workers = append(workers, w)
+		return w, nil
+	}
+	sources := []*config.IntegrationConfigLogSource{
+		{Type: config.MQTT_TYPE, Broker: "localhost:1883"},
+		{Type: config.MQTT_TYPE},
+		{Type: config.AMQP_TYPE, Broker: "localhost:5672"},
+	}
+
+	r := New(config.MQTT_TYPE, newWorker, sources, pp)
+	r.Start()
+	// only the sources of the type of the runner are started
+	assert.Equal(t, 1, len(workers))
+	assert.True(t, workers[0].running)
+	assert.Equal(t, 1, r.StartupErrors())
+
+	r.Stop()
+	assert.False(t, workers[0].running)
+}
+
+func TestRunnerStopsAndStartsOneSource(t *testing.T) {
+	pp := pipeline.NewPipelineProvider()
+	pp.MockPipelineChans()
+	workers := make(map[string]*fakeWorker)
+	newWorker := func(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (Worker, error) {
+		w := &fakeWorker{}
+		workers[source.Broker] = w
+		return w, nil
+	}
+	a := &config.IntegrationConfigLogSource{Type: config.MQTT_TYPE, Broker: "a:1883"}
+	b := &config.IntegrationConfigLogSource{Type: config.MQTT_TYPE, Broker: "b:1883"}
+
+	r := New(config.MQTT_TYPE, newWorker, []*config.IntegrationConfigLogSource{a, b}, pp)
+	r.Start()
+	first := workers["b:1883"]
+	r.StopSource(a.GetID())
+	assert.False(t, workers["a:1883"].running)
+	assert.True(t, workers["b:1883"].running)
+
+	r.StartSource(a)
+	assert.True(t, workers["a:1883"].running)
+	// the source still running isn't started twice
+	r.StartSource(b)
+	assert.Equal(t, first, workers["b:1883"])
+	// nor the sources of other types
+	r.StartSource(&config.IntegrationConfigLogSource{Type: config.AMQP_TYPE, Broker: "c:5672"})
+	assert.Equal(t, 2, len(workers))
+}
@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the BER tags of the SNMP types
+const (
+	tagInteger        = 0x02
+	tagOctetString    = 0x04
+	tagNull           = 0x05
+	tagOID            = 0x06
+	tagSequence       = 0x30
+	tagIPAddress      = 0x40
+	tagCounter32      = 0x41
+	tagGauge32        = 0x42
+	tagTimeTicks      = 0x43
+	tagOpaque         = 0x44
+	tagCounter64      = 0x46
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+)
+
+var errMalformed = errors.New("malformed packet")
+
+// A berReader reads the BER encoded values of a packet
+type berReader struct {
+	buf []byte
+	// offset is the position of buf in the packet
+	offset int
+}
+
+// next returns the tag, the content and the offset of the next value
+func (r *berReader) next() (byte, []byte, int, error) {
+	if len(r.buf) < 2 {
+		return 0, nil, 0, errMalformed
+	}
+	tag, offset := r.buf[0], r.offset
+	length, size := int(r.buf[1]), 2
+	if length&0x80 != 0 {
+		// long form, the length being encoded on the following bytes
+		n := length & 0x7f
+		if n == 0 || n > 4 || len(r.buf) < 2+n {
+			return 0, nil, 0, errMalformed
+		}
+		length = 0
+		for _, b := range r.buf[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		size += n
+	}
+	if length < 0 || len(r.buf) < size+length {
+		return 0, nil, 0, errMalformed
+	}
+	content := r.buf[size : size+length]
+	r.buf = r.buf[size+length:]
+	r.offset += size + length
+	return tag, content, offset, nil
+}
+
+// expect returns the content of the next value, which must have tag
+func (r *berReader) expect(tag byte) ([]byte, error) {
+	actual, content, _, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if actual != tag {
+		return nil, fmt.Errorf("unexpected tag 0x%02x instead of 0x%02x", actual, tag)
+	}
+	return content, nil
+}
+
+// sequence returns a reader of the content of the next sequence
+func (r *berReader) sequence() (*berReader, error) {
+	content, err := r.expect(tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	// the content ends where the reader now starts
+	return &berReader{buf: content, offset: r.offset - len(content)}, nil
+}
+
+// integer reads the next integer
+func (r *berReader) integer() (int64, error) {
+	content, err := r.expect(tagInteger)
+	if err != nil {
+		return 0, err
+	}
+	return parseInteger(content)
+}
+
+// parseInteger decodes a two's complement integer
+func parseInteger(content []byte) (int64, error) {
+	if len(content) == 0 || len(content) > 8 {
+		return 0, errMalformed
+	}
+	n := int64(int8(content[0]))
+	for _, b := range content[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// parseUnsigned decodes an unsigned integer, such as a counter
+func parseUnsigned(content []byte) (uint64, error) {
+	if len(content) == 0 || len(content) > 9 || (len(content) == 9 && content[0] != 0) {
+		return 0, errMalformed
+	}
+	var n uint64
+	for _, b := range content {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+// parseOID decodes an object identifier into its dotted notation
+func parseOID(content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", errMalformed
+	}
+	var components []string
+	var n uint64
+	for i, b := range content {
+		n = n<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			if i == len(content)-1 || n > 1<<56 {
+				return "", errMalformed
+			}
+			continue
+		}
+		if components == nil {
+			// the first two components are encoded together
+			first := n / 40
+			if first > 2 {
+				first = 2
+			}
+			components = append(components, strconv.FormatUint(first, 10), strconv.FormatUint(n-40*first, 10))
+		} else {
+			components = append(components, strconv.FormatUint(n, 10))
+		}
+		n = 0
+	}
+	return strings.Join(components, "."), nil
+}
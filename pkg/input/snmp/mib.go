@@ -0,0 +1,112 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package snmp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// standardNames are the names of the OIDs of SNMPv2-MIB and IF-MIB most traps refer to
+var standardNames = map[string]string{
+	"1.3.6.1.2.1.1.1":        "sysDescr",
+	"1.3.6.1.2.1.1.3":        "sysUpTime",
+	"1.3.6.1.2.1.1.5":        "sysName",
+	"1.3.6.1.2.1.2.2.1.1":    "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":    "ifDescr",
+	"1.3.6.1.2.1.2.2.1.7":    "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":    "ifOperStatus",
+	"1.3.6.1.6.3.1.1.4.1":    "snmpTrapOID",
+	"1.3.6.1.6.3.1.1.4.3":    "snmpTrapEnterprise",
+	"1.3.6.1.6.3.1.1.5.1":    "coldStart",
+	"1.3.6.1.6.3.1.1.5.2":    "warmStart",
+	"1.3.6.1.6.3.1.1.5.3":    "linkDown",
+	"1.3.6.1.6.3.1.1.5.4":    "linkUp",
+	"1.3.6.1.6.3.1.1.5.5":    "authenticationFailure",
+	"1.3.6.1.6.3.1.1.5.6":    "egpNeighborLoss",
+	"1.3.6.1.2.1.31.1.1.1.1": "ifName",
+}
+
+// A mib names the OIDs of the traps and of their variables
+type mib struct {
+	names map[string]string
+}
+
+// newMIB returns a mib of the standard names, and of those of path when set
+func newMIB(path string) (*mib, error) {
+	m := &mib{names: make(map[string]string, len(standardNames))}
+	for oid, name := range standardNames {
+		m.names[oid] = name
+	}
+	if path == "" {
+		return m, nil
+	}
+	if err := m.load(path); err != nil {
+		return nil, fmt.Errorf("can't load %s: %v", path, err)
+	}
+	return m, nil
+}
+
+// load adds the names of a file listing a name and an OID per line, such as
+// the output of `snmptranslate -Tz -m ALL`, lines starting with # being comments
+func (m *mib) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected a name and an OID", line)
+		}
+		name, oid := strings.Trim(fields[0], `"`), strings.Trim(fields[1], `"`)
+		if isOID(name) {
+			name, oid = oid, name
+		}
+		if !isOID(oid) {
+			return fmt.Errorf("line %d: invalid OID %s", line, oid)
+		}
+		m.names[strings.TrimPrefix(oid, ".")] = name
+	}
+	return scanner.Err()
+}
+
+// resolve returns the name of oid, the components of oid following the longest
+// named prefix being appended, such as ifIndex.2, or oid when no prefix is named
+func (m *mib) resolve(oid string) string {
+	for prefix := oid; prefix != ""; {
+		if name, ok := m.names[prefix]; ok {
+			return name + oid[len(prefix):]
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return oid
+}
+
+// isOID returns true when s is an OID in dotted notation
+func isOID(s string) bool {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return false
+	}
+	for _, component := range strings.Split(s, ".") {
+		if component == "" || strings.Trim(component, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package snmp
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+// defaultPort is the port traps are sent to
+const defaultPort = 162
+
+// maxPacketSize is the size of the largest UDP datagram
+const maxPacketSize = 65535
+
+var (
+	// invalidTraps counts the packets that couldn't be decoded
+	invalidTraps = metrics.NewCounter("logs_snmp_invalid_traps")
+	// rejectedTraps counts the traps of another community than the one of their source
+	rejectedTraps = metrics.NewCounter("logs_snmp_rejected_traps")
+)
+
+// A Receiver listens for the SNMP traps of an snmp_traps source,
+// and sends them to its pipeline as structured logs
+type Receiver struct {
+	source     *config.IntegrationConfigLogSource
+	outputChan chan message.Message
+	mib        *mib
+	conn       *net.UDPConn
+	done       chan struct{}
+}
+
+// NewReceiver returns a Receiver listening on the port of source, or an error
+// if the port can't be listened on or its MIB file can't be loaded
+func NewReceiver(source *config.IntegrationConfigLogSource, outputChan chan message.Message) (*Receiver, error) {
+	mib, err := newMIB(source.MIBFile)
+	if err != nil {
+		return nil, err
+	}
+	port := source.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Receiving SNMP traps on port", port)
+	return &Receiver{
+		source:     source,
+		outputChan: outputChan,
+		mib:        mib,
+		conn:       conn,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start starts receiving traps
+func (r *Receiver) Start() {
+	go r.run()
+}
+
+// Stop closes the socket and waits for the traps being received
+func (r *Receiver) Stop() {
+	r.conn.Close()
+	<-r.done
+}
+
+// run receives traps until the socket is closed
+func (r *Receiver) run() {
+	defer close(r.done)
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			if opErr, ok := err.(*net.OpError); ok && !opErr.Temporary() {
+				return
+			}
+			log.Println("Can't receive SNMP trap:", err)
+			continue
+		}
+		r.handle(buf[:n], addr)
+	}
+}
+
+// handle forwards the trap of packet sent by addr, acknowledging informs
+func (r *Receiver) handle(packet []byte, addr *net.UDPAddr) {
+	t, err := parseTrap(packet)
+	if err != nil {
+		invalidTraps.Add(1)
+		log.Printf("Invalid SNMP trap from %s: %v", addr.IP, err)
+		return
+	}
+	if r.source.Community != "" && subtle.ConstantTimeCompare([]byte(t.community), []byte(r.source.Community)) != 1 {
+		rejectedTraps.Add(1)
+		return
+	}
+	content, err := t.content(r.mib, addr.IP.String())
+	if err != nil {
+		invalidTraps.Add(1)
+		log.Printf("Can't format SNMP trap from %s: %v", addr.IP, err)
+		return
+	}
+	msg := message.NewNetworkMessage(content)
+	origin := message.NewOrigin()
+	origin.LogSource = r.source
+	msg.SetOrigin(origin)
+	r.outputChan <- msg
+	if t.pduType == pduInform {
+		r.conn.WriteToUDP(t.response(packet), addr)
+	}
+}
@@ -0,0 +1,189 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package snmp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// tlv encodes a BER value
+func tlv(tag byte, content ...[]byte) []byte {
+	var body []byte
+	for _, c := range content {
+		body = append(body, c...)
+	}
+	if len(body) < 0x80 {
+		return append([]byte{tag, byte(len(body))}, body...)
+	}
+	return append([]byte{tag, 0x82, byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+func integer(n int) []byte {
+	return tlv(tagInteger, []byte{byte(n)})
+}
+
+func oid(dotted string) []byte {
+	components := strings.Split(dotted, ".")
+	first, _ := strconv.Atoi(components[0])
+	second, _ := strconv.Atoi(components[1])
+	content := []byte{byte(40*first + second)}
+	for _, component := range components[2:] {
+		n, _ := strconv.Atoi(component)
+		var encoded []byte
+		for encoded = []byte{byte(n & 0x7f)}; n > 0x7f; {
+			n >>= 7
+			encoded = append([]byte{byte(n&0x7f) | 0x80}, encoded...)
+		}
+		content = append(content, encoded...)
+	}
+	return tlv(tagOID, content)
+}
+
+func binding(name string, value []byte) []byte {
+	return tlv(tagSequence, oid(name), value)
+}
+
+// trapV2 encodes an SNMPv2c notification of pduType
+func trapV2(community string, pduType byte, bindings ...[]byte) []byte {
+	bindings = append([][]byte{
+		binding(sysUpTimeOID, tlv(tagTimeTicks, []byte{0x01, 0x00})),
+		binding(snmpTrapOIDOID, oid("1.3.6.1.6.3.1.1.5.3")),
+	}, bindings...)
+	pdu := tlv(pduType, integer(42), integer(0), integer(0), tlv(tagSequence, bindings...))
+	return tlv(tagSequence, integer(1), tlv(tagOctetString, []byte(community)), pdu)
+}
+
+func TestParseTrapV2c(t *testing.T) {
+	packet := trapV2("public", pduTrapV2,
+		binding("1.3.6.1.2.1.2.2.1.1.2", integer(2)),
+		binding("1.3.6.1.2.1.2.2.1.2.2", tlv(tagOctetString, []byte("eth0"))),
+		binding("1.3.6.1.4.1.9999.1", tlv(tagOctetString, []byte{0x00, 0x1b, 0x21, 0xff})),
+		binding("1.3.6.1.4.1.9999.2", tlv(tagCounter32, []byte{0x00, 0xff, 0xff, 0xff, 0xff})),
+	)
+	trap, err := parseTrap(packet)
+	assert.Nil(t, err)
+	assert.Equal(t, "2c", trap.version)
+	assert.Equal(t, "public", trap.community)
+	assert.Equal(t, "1.3.6.1.6.3.1.1.5.3", trap.trapOID)
+	assert.Equal(t, uint64(256), trap.uptime)
+
+	mib, _ := newMIB("")
+	content, err := trap.content(mib, "10.0.0.1")
+	assert.Nil(t, err)
+	var log map[string]interface{}
+	assert.Nil(t, json.Unmarshal(content, &log))
+	assert.Equal(t, "linkDown", log["message"])
+	assert.Equal(t, map[string]interface{}{"client": map[string]interface{}{"ip": "10.0.0.1"}}, log["network"])
+	snmp := log["snmp"].(map[string]interface{})
+	assert.Equal(t, "1.3.6.1.6.3.1.1.5.3", snmp["trap_oid"])
+	assert.Equal(t, map[string]interface{}{
+		"ifIndex.2":          float64(2),
+		"ifDescr.2":          "eth0",
+		"1.3.6.1.4.1.9999.1": "001b21ff",
+		"1.3.6.1.4.1.9999.2": float64(4294967295),
+	}, snmp["variables"])
+}
+
+func TestParseTrapV1(t *testing.T) {
+	pdu := func(generic, specific int) []byte {
+		return tlv(pduTrapV1,
+			oid("1.3.6.1.4.1.9999"),
+			tlv(tagIPAddress, []byte{192, 168, 0, 1}),
+			integer(generic),
+			integer(specific),
+			tlv(tagTimeTicks, []byte{0x10}),
+			tlv(tagSequence, binding("1.3.6.1.2.1.2.2.1.1.3", integer(3))),
+		)
+	}
+
+	trap, err := parseTrap(tlv(tagSequence, integer(0), tlv(tagOctetString, []byte("public")), pdu(2, 0)))
+	assert.Nil(t, err)
+	assert.Equal(t, "1", trap.version)
+	assert.Equal(t, "1.3.6.1.6.3.1.1.5.3", trap.trapOID)
+	assert.Equal(t, "192.168.0.1", trap.agentAddress)
+	assert.Equal(t, uint64(16), trap.uptime)
+	assert.Equal(t, []variable{{oid: "1.3.6.1.2.1.2.2.1.1.3", value: int64(3)}}, trap.variables)
+
+	trap, err = parseTrap(tlv(tagSequence, integer(0), tlv(tagOctetString, []byte("public")), pdu(6, 17)))
+	assert.Nil(t, err)
+	assert.Equal(t, "1.3.6.1.4.1.9999.0.17", trap.trapOID)
+}
+
+func TestParseTrapRejectsInvalidPackets(t *testing.T) {
+	packet := trapV2("public", pduTrapV2)
+	_, err := parseTrap(packet[:len(packet)-1])
+	assert.NotNil(t, err)
+	_, err = parseTrap(tlv(tagSequence, integer(3), tlv(tagOctetString, nil)))
+	assert.NotNil(t, err)
+	_, err = parseTrap(tlv(tagSequence, integer(1), tlv(tagOctetString, nil), tlv(0xa0, integer(1), integer(0), integer(0), tlv(tagSequence))))
+	assert.NotNil(t, err)
+}
+
+func TestMIBResolvesLongestPrefix(t *testing.T) {
+	file, err := ioutil.TempFile("", "mib")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("# snmptranslate -Tz\n\"acme\"\t\t\"1.3.6.1.4.1.9999\"\n\"acmeFanFailure\"\t\t\"1.3.6.1.4.1.9999.0.17\"\n.1.3.6.1.4.1.9999.1 acmeFanSpeed\n")
+	file.Close()
+
+	mib, err := newMIB(file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "acmeFanFailure", mib.resolve("1.3.6.1.4.1.9999.0.17"))
+	assert.Equal(t, "acmeFanSpeed.4", mib.resolve("1.3.6.1.4.1.9999.1.4"))
+	assert.Equal(t, "acme.2", mib.resolve("1.3.6.1.4.1.9999.2"))
+	assert.Equal(t, "linkUp", mib.resolve("1.3.6.1.6.3.1.1.5.4"))
+	assert.Equal(t, "1.3.6.1.4.1.8888", mib.resolve("1.3.6.1.4.1.8888"))
+
+	_, err = newMIB("/does/not/exist")
+	assert.NotNil(t, err)
+}
+
+func TestReceiverForwardsTrapsAndAcknowledgesInforms(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	outputChan := make(chan message.Message, 10)
+	source := &config.IntegrationConfigLogSource{Type: config.SNMP_TYPE, Port: port, Community: "secret"}
+	r, err := NewReceiver(source, outputChan)
+	assert.Nil(t, err)
+	r.Start()
+	defer r.Stop()
+
+	client, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	client.Write(trapV2("public", pduTrapV2))
+	inform := trapV2("secret", pduInform)
+	client.Write(inform)
+
+	msg := <-outputChan
+	assert.Equal(t, source, msg.GetOrigin().LogSource)
+	assert.Contains(t, string(msg.Content()), `"message":"linkDown"`)
+	assert.Equal(t, 0, len(outputChan))
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	response := make([]byte, len(inform)+1)
+	n, err := client.Read(response)
+	assert.Nil(t, err)
+	trap, _ := parseTrap(inform)
+	expected := append([]byte{}, inform...)
+	expected[trap.pduOffset] = pduResponse
+	assert.Equal(t, expected, response[:n])
+}
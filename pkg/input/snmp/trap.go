@@ -0,0 +1,281 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package snmp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// the PDU types of the notifications
+const (
+	pduResponse = 0xa2
+	pduTrapV1   = 0xa4
+	pduInform   = 0xa6
+	pduTrapV2   = 0xa7
+)
+
+// the OIDs of the variables carrying the uptime and the OID of SNMPv2 traps
+const (
+	sysUpTimeOID   = "1.3.6.1.2.1.1.3.0"
+	snmpTrapOIDOID = "1.3.6.1.6.3.1.1.4.1.0"
+	// snmpTrapsOID prefixes the OIDs of the generic traps
+	snmpTrapsOID = "1.3.6.1.6.3.1.1.5"
+)
+
+// the versions of the protocol, as encoded in the packets
+var versions = map[int64]string{0: "1", 1: "2c"}
+
+// A trap is a notification received from an agent
+type trap struct {
+	version   string
+	community string
+	pduType   byte
+	// pduOffset is the position of the PDU in the packet, to answer informs
+	pduOffset int
+
+	trapOID   string
+	uptime    uint64
+	variables []variable
+
+	// SNMPv1 only
+	enterprise   string
+	agentAddress string
+}
+
+// A variable is a variable binding of a trap
+type variable struct {
+	oid   string
+	value interface{}
+}
+
+// parseTrap decodes an SNMPv1 or SNMPv2c notification
+func parseTrap(packet []byte) (*trap, error) {
+	msg, err := (&berReader{buf: packet}).sequence()
+	if err != nil {
+		return nil, err
+	}
+	number, err := msg.integer()
+	if err != nil {
+		return nil, err
+	}
+	version, ok := versions[number]
+	if !ok {
+		return nil, fmt.Errorf("unsupported version %d, only SNMPv1 and SNMPv2c are supported", number+1)
+	}
+	community, err := msg.expect(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	pduType, content, pduOffset, err := msg.next()
+	if err != nil {
+		return nil, err
+	}
+	t := &trap{
+		version:   version,
+		community: string(community),
+		pduType:   pduType,
+		pduOffset: pduOffset,
+	}
+	pdu := &berReader{buf: content}
+	switch pduType {
+	case pduTrapV1:
+		err = t.parseV1(pdu)
+	case pduTrapV2, pduInform:
+		err = t.parseV2(pdu)
+	default:
+		err = fmt.Errorf("unexpected PDU type 0x%02x", pduType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseV1 decodes the PDU of an SNMPv1 trap, mapping its OID as RFC 3584
+func (t *trap) parseV1(pdu *berReader) error {
+	content, err := pdu.expect(tagOID)
+	if err != nil {
+		return err
+	}
+	if t.enterprise, err = parseOID(content); err != nil {
+		return err
+	}
+	if content, err = pdu.expect(tagIPAddress); err != nil {
+		return err
+	}
+	if len(content) == net.IPv4len {
+		t.agentAddress = net.IP(content).String()
+	}
+	generic, err := pdu.integer()
+	if err != nil {
+		return err
+	}
+	specific, err := pdu.integer()
+	if err != nil {
+		return err
+	}
+	if content, err = pdu.expect(tagTimeTicks); err != nil {
+		return err
+	}
+	if t.uptime, err = parseUnsigned(content); err != nil {
+		return err
+	}
+	if generic >= 0 && generic < 6 {
+		t.trapOID = snmpTrapsOID + "." + strconv.FormatInt(generic+1, 10)
+	} else {
+		t.trapOID = t.enterprise + ".0." + strconv.FormatInt(specific, 10)
+	}
+	t.variables, err = parseVariables(pdu)
+	return err
+}
+
+// parseV2 decodes the PDU of an SNMPv2c trap or inform, the uptime and the OID
+// of the trap being its first variables
+func (t *trap) parseV2(pdu *berReader) error {
+	for i := 0; i < 3; i++ {
+		// request id, error status and error index
+		if _, err := pdu.integer(); err != nil {
+			return err
+		}
+	}
+	variables, err := parseVariables(pdu)
+	if err != nil {
+		return err
+	}
+	for _, v := range variables {
+		switch v.oid {
+		case sysUpTimeOID:
+			t.uptime, _ = v.value.(uint64)
+		case snmpTrapOIDOID:
+			t.trapOID, _ = v.value.(string)
+		default:
+			t.variables = append(t.variables, v)
+		}
+	}
+	if t.trapOID == "" {
+		return fmt.Errorf("missing %s variable", snmpTrapOIDOID)
+	}
+	return nil
+}
+
+// parseVariables decodes the variable bindings ending a PDU
+func parseVariables(pdu *berReader) ([]variable, error) {
+	bindings, err := pdu.sequence()
+	if err != nil {
+		return nil, err
+	}
+	var variables []variable
+	for len(bindings.buf) > 0 {
+		binding, err := bindings.sequence()
+		if err != nil {
+			return nil, err
+		}
+		content, err := binding.expect(tagOID)
+		if err != nil {
+			return nil, err
+		}
+		oid, err := parseOID(content)
+		if err != nil {
+			return nil, err
+		}
+		tag, content, _, err := binding.next()
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseValue(tag, content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %v", oid, err)
+		}
+		variables = append(variables, variable{oid: oid, value: value})
+	}
+	return variables, nil
+}
+
+// parseValue decodes the value of a variable
+func parseValue(tag byte, content []byte) (interface{}, error) {
+	switch tag {
+	case tagInteger:
+		return parseInteger(content)
+	case tagOctetString:
+		return formatOctets(content), nil
+	case tagNull:
+		return nil, nil
+	case tagOID:
+		return parseOID(content)
+	case tagIPAddress:
+		if len(content) != net.IPv4len {
+			return nil, errMalformed
+		}
+		return net.IP(content).String(), nil
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return parseUnsigned(content)
+	case tagOpaque:
+		return hex.EncodeToString(content), nil
+	case tagNoSuchObject:
+		return "noSuchObject", nil
+	case tagNoSuchInstance:
+		return "noSuchInstance", nil
+	case tagEndOfMibView:
+		return "endOfMibView", nil
+	default:
+		return nil, fmt.Errorf("unsupported type 0x%02x", tag)
+	}
+}
+
+// formatOctets returns an octet string as text when it's printable,
+// and in hexadecimal otherwise, such as for MAC addresses
+func formatOctets(content []byte) string {
+	if !utf8.Valid(content) {
+		return hex.EncodeToString(content)
+	}
+	for _, r := range string(content) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return hex.EncodeToString(content)
+		}
+	}
+	return string(content)
+}
+
+// content returns the structured log of the trap received from sender,
+// the OIDs being named after mib
+func (t *trap) content(mib *mib, sender string) ([]byte, error) {
+	variables := make(map[string]interface{}, len(t.variables))
+	for _, v := range t.variables {
+		variables[mib.resolve(v.oid)] = v.value
+	}
+	trap := map[string]interface{}{
+		"version":   t.version,
+		"trap_oid":  t.trapOID,
+		"trap":      mib.resolve(t.trapOID),
+		"uptime":    t.uptime,
+		"variables": variables,
+	}
+	if t.enterprise != "" {
+		trap["enterprise"] = mib.resolve(t.enterprise)
+	}
+	if t.agentAddress != "" {
+		trap["agent_address"] = t.agentAddress
+	}
+	return json.Marshal(map[string]interface{}{
+		"message": trap["trap"],
+		"snmp":    trap,
+		"network": map[string]interface{}{"client": map[string]interface{}{"ip": sender}},
+	})
+}
+
+// response returns the response acknowledging an inform
+func (t *trap) response(packet []byte) []byte {
+	response := make([]byte, len(packet))
+	copy(response, packet)
+	response[t.pduOffset] = pduResponse
+	return response
+}
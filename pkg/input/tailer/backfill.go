@@ -0,0 +1,71 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
+)
+
+// backfillPollPeriod is the period at which a backfilling tailer checks
+// whether it can read more of its backlog
+const backfillPollPeriod = 100 * time.Millisecond
+
+// A backfill bounds the rate at which the tailers read the backlog their files
+// accumulated while the agent was down. Live data is read without limit but
+// consumes the same budget, so the backlog is only read with the bandwidth left
+// and fresh logs aren't delayed behind hours of historical replay
+type backfill struct {
+	bucket *utils.TokenBucket
+
+	mutex     sync.Mutex
+	remaining map[string]int64
+}
+
+// newBackfill returns a backfill reading backlogs at maxBytesPerSecond,
+// or nil when the backfill is not limited
+func newBackfill(maxBytesPerSecond int64) *backfill {
+	if maxBytesPerSecond <= 0 {
+		return nil
+	}
+	return &backfill{
+		bucket:    utils.NewTokenBucket(maxBytesPerSecond),
+		remaining: make(map[string]int64),
+	}
+}
+
+// waitBacklog blocks until n bytes of the backlog of path can be read,
+// remaining being what's left of the backlog once they are
+func (b *backfill) waitBacklog(path string, n int, remaining int64) {
+	for !b.bucket.TryTake(n) {
+		time.Sleep(backfillPollPeriod)
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if remaining > 0 {
+		b.remaining[path] = remaining
+	} else {
+		delete(b.remaining, path)
+	}
+}
+
+// readLive records that n bytes of live data were read
+func (b *backfill) readLive(n int) {
+	b.bucket.Take(n)
+}
+
+// status returns the size of the backlog left to read, by file
+func (b *backfill) status() interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	status := make(map[string]int64, len(b.remaining))
+	for path, remaining := range b.remaining {
+		status[path] = remaining
+	}
+	return status
+}
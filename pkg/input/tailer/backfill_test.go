@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/auditor"
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfillIsDisabledWithoutLimit(t *testing.T) {
+	assert.Nil(t, newBackfill(0))
+	assert.NotNil(t, newBackfill(1024))
+}
+
+func TestBackfillLimitsBacklogRate(t *testing.T) {
+	tl := NewTailer(nil, &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: "backlog.log"})
+	tl.backfill = newBackfill(1000)
+	tl.backlogEnd = 1500
+
+	start := time.Now()
+	tl.throttle(500)
+	tl.incrementReadOffset(500)
+	tl.throttle(500)
+	tl.incrementReadOffset(500)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+	assert.Equal(t, map[string]int64{"backlog.log": 500}, tl.backfill.status())
+
+	// the burst was consumed
+	tl.throttle(500)
+	tl.incrementReadOffset(500)
+	assert.True(t, time.Since(start) >= 400*time.Millisecond)
+	assert.Equal(t, map[string]int64{}, tl.backfill.status())
+
+	// the backlog is over
+	start = time.Now()
+	tl.throttle(1000)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+	assert.Equal(t, map[string]int64{}, tl.backfill.status())
+}
+
+func TestBackfillYieldsToLiveData(t *testing.T) {
+	b := newBackfill(1000)
+	b.readLive(1500)
+
+	start := time.Now()
+	b.waitBacklog("backlog.log", 100, 0)
+	// the backlog waits for the debt of live data to be paid back
+	assert.True(t, time.Since(start) >= 500*time.Millisecond)
+}
+
+func TestRecoverTailingDetectsBacklog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backfill")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "backlog.log")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("first\nsecond\n"), 0644))
+
+	tl := NewTailer(make(chan message.Message, 2), &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: path})
+	tl.backfill = newBackfill(1000)
+	assert.Nil(t, tl.recoverTailing(auditor.New(nil)))
+	defer tl.Stop(false)
+	// the file is new to the registry, so it is tailed from the end without backlog
+	assert.Equal(t, int64(13), tl.backlogEnd)
+	assert.Equal(t, int64(13), tl.GetReadOffset())
+}
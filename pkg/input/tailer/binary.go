@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"bytes"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+const (
+	// binaryNULDensity is the share of NUL bytes above which the data read is deemed binary
+	binaryNULDensity = 0.1
+	// binaryRecheckPeriod is how long the data of a binary file is skipped before
+	// checking whether it turned back to text
+	binaryRecheckPeriod = time.Minute
+)
+
+// binaryBytes counts the bytes of binary data skipped instead of shipped
+var binaryBytes = metrics.NewCounter("logs_tailer_binary_bytes")
+
+// binaryFiles are the tailed files deemed binary, by path, with when they were
+var binaryFiles = struct {
+	sync.Mutex
+	since map[string]time.Time
+}{since: make(map[string]time.Time)}
+
+func init() {
+	status.Register("binary files", binaryFilesStatus)
+}
+
+// binaryFilesStatus returns since when the binary files are skipped
+func binaryFilesStatus() interface{} {
+	binaryFiles.Lock()
+	defer binaryFiles.Unlock()
+	files := make(map[string]string, len(binaryFiles.since))
+	for path, since := range binaryFiles.since {
+		files[path] = "binary, skipped since " + since.Format(time.RFC3339)
+	}
+	return files
+}
+
+// isBinary returns true when data is made of too many NUL bytes to be text
+func isBinary(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	return float64(bytes.Count(data, []byte{0}))/float64(len(data)) > binaryNULDensity
+}
+
+// detectsBinary returns true when the NUL bytes of the files of source aren't expected
+func detectsBinary(source *config.IntegrationConfigLogSource) bool {
+	return source.Encoding != config.ENCODING_UTF16_LE && source.Encoding != config.ENCODING_UTF16_BE
+}
+
+// skipBinary returns true when read must not be shipped, the file being binary.
+// A binary file is skipped until it's checked again binaryRecheckPeriod later,
+// shipping its data again if it turned back to text
+func (t *Tailer) skipBinary(read []byte) bool {
+	if !t.detectBinary {
+		return false
+	}
+	now := time.Now()
+	binary := !t.binarySince.IsZero()
+	if binary && now.Before(t.binaryCheck) || isBinary(read) {
+		if !binary {
+			log.Println("Skipping", t.path, "which turned out to be binary")
+			t.binarySince = now
+			binaryFiles.Lock()
+			binaryFiles.since[t.path] = now
+			binaryFiles.Unlock()
+		}
+		if !now.Before(t.binaryCheck) {
+			t.binaryCheck = now.Add(binaryRecheckPeriod)
+		}
+		binaryBytes.Add(int64(len(read)))
+		atomic.AddInt64(&t.skippedBytes, int64(len(read)))
+		return true
+	}
+	if binary {
+		log.Println("Shipping", t.path, "again, it's no longer binary")
+		t.clearBinary()
+	}
+	return false
+}
+
+// clearBinary removes the file from the binary files
+func (t *Tailer) clearBinary() {
+	if t.binarySince.IsZero() {
+		return
+	}
+	t.binarySince = time.Time{}
+	binaryFiles.Lock()
+	delete(binaryFiles.since, t.path)
+	binaryFiles.Unlock()
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBinary(t *testing.T) {
+	assert.False(t, isBinary(nil))
+	assert.False(t, isBinary([]byte("hello world\n")))
+	assert.False(t, isBinary(append(bytes.Repeat([]byte("a"), 99), 0)))
+	assert.True(t, isBinary([]byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00")))
+}
+
+func TestSkipBinary(t *testing.T) {
+	source := &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: "/var/log/app.db"}
+	tailer := NewTailer(make(chan message.Message), source)
+	text := []byte("hello world\n")
+	binary := make([]byte, 64)
+
+	assert.False(t, tailer.skipBinary(text))
+	assert.True(t, tailer.skipBinary(binary))
+	assert.Contains(t, binaryFilesStatus(), "/var/log/app.db")
+
+	// text is skipped until the file is checked again
+	assert.True(t, tailer.skipBinary(text))
+	assert.Equal(t, int64(len(binary)+len(text)), tailer.skippedBytes)
+
+	tailer.binaryCheck = time.Now().Add(-time.Second)
+	assert.True(t, tailer.skipBinary(binary))
+	assert.True(t, tailer.binaryCheck.After(time.Now()))
+
+	tailer.binaryCheck = time.Now().Add(-time.Second)
+	assert.False(t, tailer.skipBinary(text))
+	assert.NotContains(t, binaryFilesStatus(), "/var/log/app.db")
+}
+
+func TestSkipBinaryIgnoresUTF16(t *testing.T) {
+	source := &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: "/var/log/iis.log", Encoding: config.ENCODING_UTF16_LE}
+	tailer := NewTailer(make(chan message.Message), source)
+	assert.False(t, tailer.skipBinary([]byte("h\x00e\x00l\x00l\x00o\x00\n\x00")))
+}
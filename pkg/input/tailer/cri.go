@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+)
+
+// criMaxContentLen bounds the content of a line joined from partial lines
+const criMaxContentLen = 256 * 1000
+
+// A criLine is a complete line of a container log file of the CRI format
+type criLine struct {
+	content   []byte
+	timestamp string
+	severity  []byte
+	// startOffset is the offset of its first partial line
+	startOffset int64
+}
+
+// criParser parses the lines of the container log files written by the CRI runtimes,
+// <time> <stream> <P|F> <content>, joining the partial lines split by the runtime
+type criParser struct {
+	partial      []byte
+	partialStart int64
+	hasPartial   bool
+}
+
+// parse returns the line completed by raw, which starts at offset, and true,
+// or false when raw is a partial line waiting for the rest of its content.
+// Lines that can't be parsed are returned as they are
+func (p *criParser) parse(raw []byte, offset int64) (criLine, bool) {
+	timestamp, severity, partial, content, err := parseCRILine(raw)
+	if err != nil {
+		return criLine{content: raw, startOffset: offset}, true
+	}
+	if !p.hasPartial {
+		p.hasPartial = true
+		p.partialStart = offset
+		p.partial = p.partial[:0]
+	}
+	if len(p.partial)+len(content) > criMaxContentLen {
+		content = content[:criMaxContentLen-len(p.partial)]
+	}
+	p.partial = append(p.partial, content...)
+	if partial && len(p.partial) < criMaxContentLen {
+		return criLine{}, false
+	}
+	line := criLine{
+		content:     append([]byte(nil), p.partial...),
+		timestamp:   timestamp,
+		severity:    severity,
+		startOffset: p.partialStart,
+	}
+	// a line reaching the limit is truncated, the partial lines following it making another line
+	p.hasPartial = false
+	return line, true
+}
+
+// parseCRILine splits a line of the CRI format into its timestamp, formatted as
+// config.DateFormat, the severity of its stream, whether it is partial and its content
+func parseCRILine(line []byte) (string, []byte, bool, []byte, error) {
+	fields := bytes.SplitN(line, []byte{' '}, 4)
+	if len(fields) < 3 {
+		return "", nil, false, nil, errors.New("Can't parse CRI line: expected <time> <stream> <P|F> <content>")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, string(fields[0]))
+	if err != nil {
+		return "", nil, false, nil, errors.New("Can't parse CRI line: invalid timestamp")
+	}
+	var severity []byte
+	switch string(fields[1]) {
+	case "stdout":
+		severity = config.SEV_INFO
+	case "stderr":
+		severity = config.SEV_ERROR
+	default:
+		return "", nil, false, nil, errors.New("Can't parse CRI line: invalid stream")
+	}
+	var partial bool
+	switch string(fields[2]) {
+	case "P":
+		partial = true
+	case "F":
+	default:
+		return "", nil, false, nil, errors.New("Can't parse CRI line: invalid tag")
+	}
+	var content []byte
+	if len(fields) == 4 {
+		content = fields[3]
+	}
+	return ts.UTC().Format(config.DateFormat), severity, partial, content, nil
+}
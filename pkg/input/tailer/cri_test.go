@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCRILine(t *testing.T) {
+	ts, sev, partial, content, err := parseCRILine([]byte("2017-10-06T00:17:09.669794202Z stderr F connection refused"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-10-06T00:17:09.669794202Z", ts)
+	assert.Equal(t, config.SEV_ERROR, sev)
+	assert.False(t, partial)
+	assert.Equal(t, "connection refused", string(content))
+
+	ts, sev, partial, content, err = parseCRILine([]byte("2017-10-06T02:17:09.5+02:00 stdout P "))
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-10-06T00:17:09.500000000Z", ts)
+	assert.Equal(t, config.SEV_INFO, sev)
+	assert.True(t, partial)
+	assert.Equal(t, "", string(content))
+
+	_, _, _, _, err = parseCRILine([]byte("hello world"))
+	assert.NotNil(t, err)
+	_, _, _, _, err = parseCRILine([]byte("2017-10-06T00:17:09Z stdin F hello"))
+	assert.NotNil(t, err)
+	_, _, _, _, err = parseCRILine([]byte("2017-10-06T00:17:09Z stdout X hello"))
+	assert.NotNil(t, err)
+}
+
+func TestCRIParserJoinsPartialLines(t *testing.T) {
+	p := &criParser{}
+
+	_, complete := p.parse([]byte("2017-10-06T00:17:09Z stdout P hello "), 10)
+	assert.False(t, complete)
+	line, complete := p.parse([]byte("2017-10-06T00:17:10Z stdout F world"), 46)
+	assert.True(t, complete)
+	assert.Equal(t, "hello world", string(line.content))
+	assert.Equal(t, "2017-10-06T00:17:10.000000000Z", line.timestamp)
+	assert.Equal(t, int64(10), line.startOffset)
+
+	line, complete = p.parse([]byte("2017-10-06T00:17:11Z stdout F again"), 82)
+	assert.True(t, complete)
+	assert.Equal(t, "again", string(line.content))
+	assert.Equal(t, int64(82), line.startOffset)
+
+	line, complete = p.parse([]byte("not a cri line"), 118)
+	assert.True(t, complete)
+	assert.Equal(t, "not a cri line", string(line.content))
+	assert.Equal(t, "", line.timestamp)
+}
@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"os"
+	"sync"
+
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
+)
+
+// deviceBudgets cap the aggregate read throughput of the tailers by disk
+// device, so that many files on the same disk can't saturate its I/O
+type deviceBudgets struct {
+	maxBytesPerSecond int64
+
+	mutex   sync.Mutex
+	buckets map[uint64]*utils.TokenBucket
+}
+
+// newDeviceBudgets returns device budgets of maxBytesPerSecond each,
+// or nil when the reads are not limited
+func newDeviceBudgets(maxBytesPerSecond int64) *deviceBudgets {
+	if maxBytesPerSecond <= 0 {
+		return nil
+	}
+	return &deviceBudgets{
+		maxBytesPerSecond: maxBytesPerSecond,
+		buckets:           make(map[uint64]*utils.TokenBucket),
+	}
+}
+
+// bucket returns the token bucket shared by the files on the device of path,
+// or nil if the device can't be determined
+func (d *deviceBudgets) bucket(path string) *utils.TokenBucket {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	dev, ok := device(info)
+	if !ok {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	b, exists := d.buckets[dev]
+	if !exists {
+		b = utils.NewTokenBucket(d.maxBytesPerSecond)
+		d.buckets[dev] = b
+	}
+	return b
+}
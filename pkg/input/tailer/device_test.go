@@ -0,0 +1,33 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceBudgetsAreSharedByDevice(t *testing.T) {
+	assert.Nil(t, newDeviceBudgets(0))
+
+	dir, err := ioutil.TempDir("", "device")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+	assert.Nil(t, ioutil.WriteFile(first, nil, 0644))
+	assert.Nil(t, ioutil.WriteFile(second, nil, 0644))
+
+	devices := newDeviceBudgets(1024)
+	bucket := devices.bucket(first)
+	assert.NotNil(t, bucket)
+	assert.True(t, bucket == devices.bucket(second))
+	assert.Nil(t, devices.bucket(filepath.Join(dir, "missing.log")))
+}
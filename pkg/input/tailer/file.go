@@ -0,0 +1,46 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build !windows
+// +build !windows
+
+package tailer
+
+import (
+	"os"
+	"syscall"
+)
+
+// readBufferSize is the size of the reads
+const readBufferSize = 4096
+
+// openFile opens path for reading
+func openFile(path string) (logFile, error) {
+	return os.Open(path)
+}
+
+// device returns the identifier of the device containing a file
+func device(f os.FileInfo) (uint64, bool) {
+	switch s := f.Sys().(type) {
+	case *syscall.Stat_t:
+		return uint64(s.Dev), true
+	default:
+		return 0, false
+	}
+}
+
+// fileID returns the device and inode of f
+func fileID(f logFile) (uint64, uint64, bool) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, false
+	}
+	switch s := info.Sys().(type) {
+	case *syscall.Stat_t:
+		return uint64(s.Dev), uint64(s.Ino), true
+	default:
+		return 0, 0, false
+	}
+}
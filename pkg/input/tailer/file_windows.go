@@ -0,0 +1,209 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build windows
+// +build windows
+
+package tailer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// readBufferSize is the size of the reads, large enough for busy IIS logs
+// to be read in few overlapped operations
+const readBufferSize = 64 * 1024
+
+var procGetOverlappedResult = syscall.NewLazyDLL("kernel32.dll").NewProc("GetOverlappedResult")
+
+// openFile opens path for overlapped reads, sharing it with the writers,
+// and with the log rotation which renames or deletes it while it's tailed
+func openFile(path string) (logFile, error) {
+	name, err := syscall.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(
+		name,
+		syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_ATTRIBUTE_NORMAL|syscall.FILE_FLAG_OVERLAPPED,
+		0,
+	)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return &overlappedFile{File: os.NewFile(uintptr(handle), path), handle: handle}, nil
+}
+
+// longPath returns path with the \\?\ prefix lifting the MAX_PATH limit of
+// 260 characters, for the logs of deeply nested application directories.
+// The prefix disables the normalization of the path, so it must be absolute and clean
+func longPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || !filepath.IsAbs(path) {
+		return path
+	}
+	path = filepath.Clean(path)
+	if strings.HasPrefix(path, `\\`) {
+		// \\server\share\... becomes \\?\UNC\server\share\...
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
+
+// An overlappedFile reads a file with overlapped I/O: the next chunk of the file
+// is read ahead by the system while the previous one is being decoded. The file
+// is closed and stat'ed through its os.File, its reads bypassing the file pointer
+type overlappedFile struct {
+	*os.File
+	handle syscall.Handle
+
+	// offset is the position of the next byte returned by Read
+	offset int64
+	// pending is the read ahead at offset, nil when none is in flight
+	pending *overlappedRead
+}
+
+// An overlappedRead is a read in flight, its buffer and overlapped structure
+// being owned by the system until it completes
+type overlappedRead struct {
+	overlapped syscall.Overlapped
+	buf        []byte
+	// err is the error of a read that failed to start
+	err error
+}
+
+// Read returns the chunk read ahead at the offset, or reads it if none is
+// in flight, and starts reading the following chunk
+func (f *overlappedFile) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	read := f.pending
+	f.pending = nil
+	if read == nil || len(read.buf) != len(p) {
+		f.cancel(read)
+		read = f.start(len(p))
+	}
+	n, err := f.complete(read)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		// the file will be read again from the same offset when it grows
+		return 0, io.EOF
+	}
+	copy(p, read.buf[:n])
+	f.offset += int64(n)
+	// a full chunk suggests more data follows
+	if n == len(read.buf) {
+		f.pending = f.start(len(read.buf))
+	}
+	return n, nil
+}
+
+// Seek cancels the read ahead and moves the offset of the next read
+func (f *overlappedFile) Seek(offset int64, whence int) (int64, error) {
+	f.cancel(f.pending)
+	f.pending = nil
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		info, err := f.File.Stat()
+		if err != nil {
+			return f.offset, err
+		}
+		offset += info.Size()
+	default:
+		return f.offset, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return f.offset, fmt.Errorf("negative offset %d", offset)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Close cancels the read ahead and closes the file
+func (f *overlappedFile) Close() error {
+	f.cancel(f.pending)
+	f.pending = nil
+	return f.File.Close()
+}
+
+// start starts reading size bytes at the offset
+func (f *overlappedFile) start(size int) *overlappedRead {
+	read := &overlappedRead{buf: make([]byte, size)}
+	read.overlapped.Offset = uint32(f.offset)
+	read.overlapped.OffsetHigh = uint32(f.offset >> 32)
+	var done uint32
+	err := syscall.ReadFile(f.handle, read.buf, &done, &read.overlapped)
+	if err != nil && err != syscall.ERROR_IO_PENDING {
+		// reported by complete
+		read.err = err
+	}
+	return read
+}
+
+// complete waits for read to complete and returns the number of bytes read,
+// 0 at the end of the file
+func (f *overlappedFile) complete(read *overlappedRead) (int, error) {
+	if read.err == syscall.ERROR_HANDLE_EOF {
+		return 0, nil
+	}
+	if read.err != nil {
+		return 0, read.err
+	}
+	var done uint32
+	ok, _, err := procGetOverlappedResult.Call(uintptr(f.handle), uintptr(unsafe.Pointer(&read.overlapped)), uintptr(unsafe.Pointer(&done)), 1)
+	if ok == 0 {
+		if err == syscall.ERROR_HANDLE_EOF {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return int(done), nil
+}
+
+// cancel cancels read, if any, and waits for the system to release it
+func (f *overlappedFile) cancel(read *overlappedRead) {
+	if read == nil {
+		return
+	}
+	if read.err == nil {
+		syscall.CancelIoEx(f.handle, &read.overlapped)
+		f.complete(read)
+	}
+}
+
+// device returns the identifier of the device containing a file, which os.FileInfo
+// doesn't carry on Windows: the reads aren't budgeted by device
+func device(f os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// fileID returns the volume serial number and file index of f, the
+// device and inode of Windows
+func fileID(f logFile) (uint64, uint64, bool) {
+	o, ok := f.(*overlappedFile)
+	if !ok {
+		return 0, 0, false
+	}
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(o.handle, &info); err != nil {
+		return 0, 0, false
+	}
+	return uint64(info.VolumeSerialNumber), uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow), true
+}
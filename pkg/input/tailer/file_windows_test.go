@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build windows
+// +build windows
+
+package tailer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongPath(t *testing.T) {
+	assert.Equal(t, `\\?\C:\logs\app.log`, longPath(`C:\logs\..\logs\app.log`))
+	assert.Equal(t, `\\?\C:\logs\app.log`, longPath(`C:/logs/app.log`))
+	assert.Equal(t, `\\?\UNC\server\share\app.log`, longPath(`\\server\share\app.log`))
+	assert.Equal(t, `\\?\C:\logs\app.log`, longPath(`\\?\C:\logs\app.log`))
+	assert.Equal(t, `logs\app.log`, longPath(`logs\app.log`))
+}
+
+func TestOverlappedFileReadsAheadAndFollowsGrowth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailer")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	// deeper than MAX_PATH
+	nested := filepath.Join(dir, strings.Repeat("nested-directory\\", 16))
+	assert.Nil(t, os.MkdirAll(nested, 0755))
+	path := filepath.Join(nested, "app.log")
+	writer, err := os.Create(path)
+	assert.Nil(t, err)
+	defer writer.Close()
+	writer.WriteString(strings.Repeat("a", 10) + "\n")
+
+	f, err := openFile(path)
+	assert.Nil(t, err)
+	buf := make([]byte, 8)
+	n, err := f.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "aaaaaaaa", string(buf[:n]))
+	n, err = f.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "aa\n", string(buf[:n]))
+	_, err = f.Read(buf)
+	assert.Equal(t, io.EOF, err)
+
+	writer.WriteString("b\n")
+	n, err = f.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "b\n", string(buf[:n]))
+
+	offset, err := f.Seek(-2, io.SeekEnd)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), offset)
+
+	// the file can be rotated while it's tailed
+	writer.Close()
+	assert.Nil(t, os.Rename(path, path+".1"))
+	assert.Nil(t, f.Close())
+}
@@ -0,0 +1,62 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+)
+
+// expandPattern returns the sorted paths of the regular files matching pattern,
+// ** matching any number of directories
+func expandPattern(pattern string) []string {
+	pattern = filepath.Clean(pattern)
+	if !strings.Contains(pattern, "**") {
+		paths, _ := filepath.Glob(pattern)
+		return regularFiles(paths)
+	}
+	components := strings.Split(pattern, string(filepath.Separator))
+	// the directories above the first wildcard are walked
+	base := 0
+	for base < len(components) && !strings.ContainsAny(components[base], "*?[") {
+		base++
+	}
+	root := strings.Join(components[:base], string(filepath.Separator))
+	switch {
+	case base == 0:
+		root = "."
+	case root == "":
+		root = string(filepath.Separator)
+	}
+	paths := []string{}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			// unreadable directories are skipped
+			return nil
+		}
+		if config.MatchPathPattern(pattern, path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths
+}
+
+// regularFiles returns the paths of regular files among paths
+func regularFiles(paths []string) []string {
+	files := []string{}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
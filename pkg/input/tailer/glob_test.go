@@ -0,0 +1,40 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandPattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glob")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"a.log", "b.txt", "app/c.log", "app/v1/d.log", "app/v1/e.txt"} {
+		path = filepath.Join(dir, path)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+	}
+	// directories aren't matched
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "dir.log"), 0755))
+
+	join := func(paths ...string) []string {
+		for i, path := range paths {
+			paths[i] = filepath.Join(dir, path)
+		}
+		return paths
+	}
+	assert.Equal(t, join("a.log"), expandPattern(filepath.Join(dir, "*.log")))
+	assert.Equal(t, join("a.log", "app/c.log", "app/v1/d.log"), expandPattern(filepath.Join(dir, "**", "*.log")))
+	assert.Equal(t, join("app/c.log", "app/v1/d.log"), expandPattern(filepath.Join(dir, "app", "**", "*.log")))
+	assert.Equal(t, join("app/v1/d.log", "app/v1/e.txt"), expandPattern(filepath.Join(dir, "*", "v?", "*")))
+	assert.Equal(t, []string{}, expandPattern(filepath.Join(dir, "missing", "**", "*.log")))
+}
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+const (
+	// fingerprintSize is the number of bytes read last kept by a tailer to find
+	// them again in its file once a range before them was removed
+	fingerprintSize = 64
+	// collapseBlockSize is the block size of the file systems supporting the removal
+	// of ranges, which are multiples of it
+	collapseBlockSize = 4096
+	// maxCollapseCandidates bounds the offsets where the bytes read last are looked for
+	maxCollapseCandidates = 4096
+)
+
+var (
+	// skippedHoles counts the holes punched in the files skipped instead of read as zeros
+	skippedHoles = metrics.NewCounter("logs_tailer_skipped_holes")
+	// collapsedRanges counts the ranges removed from the tailed files
+	collapsedRanges = metrics.NewCounter("logs_tailer_collapsed_ranges")
+)
+
+// skipHole returns the offset of the first data at or after offset in f, skipping
+// the hole punched with fallocate(FALLOC_FL_PUNCH_HOLE) by the applications
+// deallocating the logs they no longer need, which would otherwise be read as zeros
+func skipHole(f logFile, offset int64) int64 {
+	data, err := nextData(f, offset)
+	if err != nil || data <= offset {
+		// not sparse, or unknown
+		f.Seek(offset, io.SeekStart)
+		return offset
+	}
+	skippedHoles.Add(1)
+	return data
+}
+
+// recordRead keeps the last bytes of read in the fingerprint and moves the read offset past them
+func (t *Tailer) recordRead(read []byte) {
+	t.fingerprintMutex.Lock()
+	defer t.fingerprintMutex.Unlock()
+	if len(read) >= fingerprintSize {
+		t.fingerprint = append(t.fingerprint[:0], read[len(read)-fingerprintSize:]...)
+	} else {
+		t.fingerprint = append(t.fingerprint, read...)
+		if len(t.fingerprint) > fingerprintSize {
+			t.fingerprint = t.fingerprint[len(t.fingerprint)-fingerprintSize:]
+		}
+	}
+	t.incrementReadOffset(len(read))
+}
+
+// collapsedOffset returns the offset where the tailer should resume reading its file,
+// now of size bytes, when a range before the read offset was removed with
+// fallocate(FALLOC_FL_COLLAPSE_RANGE) rather than the file being truncated. The removed
+// range being a multiple of the block size, the bytes read last are found again at
+// a lower offset aligned with the read one
+func (t *Tailer) collapsedOffset(size int64) (int64, bool) {
+	t.fingerprintMutex.Lock()
+	offset := t.GetReadOffset()
+	fingerprint := append([]byte(nil), t.fingerprint...)
+	t.fingerprintMutex.Unlock()
+	if len(fingerprint) < fingerprintSize || offset <= size {
+		return 0, false
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	// the highest candidate is the one removing the least data
+	candidate := offset - collapseBlockSize*((offset-size+collapseBlockSize-1)/collapseBlockSize)
+	buf := make([]byte, fingerprintSize)
+	for i := 0; i < maxCollapseCandidates && candidate >= fingerprintSize; i++ {
+		if _, err := f.ReadAt(buf, candidate-fingerprintSize); err == nil && bytes.Equal(buf, fingerprint) {
+			return candidate, true
+		}
+		candidate -= collapseBlockSize
+	}
+	return 0, false
+}
@@ -0,0 +1,17 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package tailer
+
+// seekData is the whence of lseek(2) moving to the next data, SEEK_DATA
+const seekData = 3
+
+// nextData returns the offset of the first data at or after offset in f
+func nextData(f logFile, offset int64) (int64, error) {
+	return f.Seek(offset, seekData)
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// numberedLines returns n bytes of distinct lines
+func numberedLines(n int) []byte {
+	content := []byte{}
+	for i := 0; len(content) < n; i++ {
+		content = append(content, fmt.Sprintf("line %07d\n", i)...)
+	}
+	return content[:n]
+}
+
+func TestCollapsedOffset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hole")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db.log")
+	content := numberedLines(3 * collapseBlockSize)
+	assert.Nil(t, ioutil.WriteFile(path, content, 0644))
+
+	tailer := NewTailer(make(chan message.Message), &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: path})
+	tailer.recordRead(content[:collapseBlockSize+100])
+	tailer.recordRead(content[collapseBlockSize+100:])
+	assert.Equal(t, int64(len(content)), tailer.GetReadOffset())
+
+	// the first two blocks are removed and a line is written
+	collapsed := append(append([]byte{}, content[2*collapseBlockSize:]...), "new line\n"...)
+	assert.Nil(t, ioutil.WriteFile(path, collapsed, 0644))
+	offset, ok := tailer.collapsedOffset(int64(len(collapsed)))
+	assert.True(t, ok)
+	assert.Equal(t, int64(collapseBlockSize), offset)
+
+	// the file is truncated
+	assert.Nil(t, ioutil.WriteFile(path, numberedLines(collapseBlockSize + 100)[100:], 0644))
+	_, ok = tailer.collapsedOffset(collapseBlockSize)
+	assert.False(t, ok)
+}
+
+func TestSkipHole(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hole")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db.log")
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	// the first MB is a hole
+	_, err = f.WriteAt([]byte("hello world\n"), 1<<20)
+	assert.Nil(t, err)
+
+	offset := skipHole(f, 0)
+	position, err := f.Seek(0, io.SeekCurrent)
+	assert.Nil(t, err)
+	assert.Equal(t, offset, position)
+	// some systems don't report the holes
+	assert.True(t, offset == 0 || offset == 1<<20, "%d", offset)
+
+	assert.Equal(t, int64(1<<20+5), skipHole(f, 1<<20+5))
+}
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/DataDog/datadog-log-agent/pkg/auditor"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+// fingerprintHeadSize is the number of bytes at the begining of a file hashed by its fingerprint
+const fingerprintHeadSize = 1024
+
+// replacedFiles counts the commited offsets discarded because another file
+// replaced the one they belong to
+var replacedFiles = metrics.NewCounter("logs_tailer_replaced_files")
+
+// FileIdentity returns the identity of the tailed file, its fingerprint
+// covering the begining of the file as it grows
+func (t *Tailer) FileIdentity() *auditor.FileIdentity {
+	t.identityMutex.Lock()
+	defer t.identityMutex.Unlock()
+	if t.identity == nil {
+		device, inode, _ := fileID(t.file)
+		t.identity = &auditor.FileIdentity{Device: device, Inode: inode}
+	}
+	if t.identity.FingerprintSize < fingerprintHeadSize && t.GetReadOffset() > t.identity.FingerprintSize {
+		// the identities are shared with the registry, never modified
+		fingerprint, size, err := fingerprintFile(t.path, t.file, fingerprintHeadSize)
+		if err == nil && size > t.identity.FingerprintSize {
+			identity := *t.identity
+			identity.Fingerprint = fingerprint
+			identity.FingerprintSize = size
+			t.identity = &identity
+		}
+	}
+	return t.identity
+}
+
+// isCommittedFile returns true when f, opened at path, is the file committed belongs to
+func isCommittedFile(path string, f logFile, committed *auditor.FileIdentity) bool {
+	device, inode, ok := fileID(f)
+	if ok && (committed.Device != 0 || committed.Inode != 0) && (device != committed.Device || inode != committed.Inode) {
+		return false
+	}
+	if committed.FingerprintSize == 0 {
+		return true
+	}
+	if data, err := nextData(f, 0); err == nil && data > 0 {
+		// the begining of the file was deallocated, the device and inode identify it
+		return true
+	}
+	fingerprint, size, err := fingerprintFile(path, f, committed.FingerprintSize)
+	return err == nil && size == committed.FingerprintSize && fingerprint == committed.Fingerprint
+}
+
+// fingerprintFile returns the hash of up to size bytes at the begining of f, opened
+// at path, and their number. The file is read through another descriptor to leave
+// the offset of f as is
+func fingerprintFile(path string, f logFile, size int64) (string, int64, error) {
+	head, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer head.Close()
+	headInfo, err := head.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	if !os.SameFile(headInfo, info) {
+		return "", 0, os.ErrNotExist
+	}
+	buf := make([]byte, size)
+	n, err := io.ReadFull(head, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", 0, err
+	}
+	h := sha256.Sum256(buf[:n])
+	return hex.EncodeToString(h[:16]), int64(n), nil
+}
@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package tailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/auditor"
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailerFileIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("hello world\n"), 0644))
+
+	tailer := NewTailer(make(chan message.Message, 10), &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: path})
+	tailer.file, err = openFile(path)
+	assert.Nil(t, err)
+	defer tailer.file.Close()
+
+	// nothing was read yet
+	identity := tailer.FileIdentity()
+	assert.Equal(t, int64(0), identity.FingerprintSize)
+
+	tailer.recordRead([]byte("hello world\n"))
+	identity = tailer.FileIdentity()
+	assert.Equal(t, int64(12), identity.FingerprintSize)
+	assert.NotEqual(t, "", identity.Fingerprint)
+
+	// the fingerprint grows with the file up to its head
+	ioutil.WriteFile(path, []byte(strings.Repeat("a", 2*fingerprintHeadSize)), 0644)
+	tailer.recordRead([]byte(strings.Repeat("a", 2*fingerprintHeadSize-12)))
+	grown := tailer.FileIdentity()
+	assert.Equal(t, int64(fingerprintHeadSize), grown.FingerprintSize)
+	assert.Equal(t, int64(12), identity.FingerprintSize)
+	assert.True(t, grown == tailer.FileIdentity())
+}
+
+func TestIsCommittedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("first file\n"), 0644))
+
+	f, err := openFile(path)
+	assert.Nil(t, err)
+	fingerprint, size, err := fingerprintFile(path, f, fingerprintHeadSize)
+	assert.Nil(t, err)
+	device, inode, _ := fileID(f)
+	committed := &auditor.FileIdentity{Device: device, Inode: inode, Fingerprint: fingerprint, FingerprintSize: size}
+	assert.True(t, isCommittedFile(path, f, committed))
+	assert.True(t, isCommittedFile(path, f, &auditor.FileIdentity{Device: device, Inode: inode}))
+	f.Close()
+
+	// the file is replaced by one which may reuse its inode
+	assert.Nil(t, os.Remove(path))
+	assert.Nil(t, ioutil.WriteFile(path, []byte("second file\n"), 0644))
+	f, err = openFile(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.False(t, isCommittedFile(path, f, committed))
+	device, inode, _ = fileID(f)
+	assert.False(t, isCommittedFile(path, f, &auditor.FileIdentity{Device: device, Inode: inode, Fingerprint: fingerprint, FingerprintSize: size}))
+	assert.False(t, isCommittedFile(path, f, &auditor.FileIdentity{Device: device + 1, Inode: inode}))
+}
@@ -8,56 +8,354 @@ package tailer
 import (
 	"log"
 	"os"
-	"syscall"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-log-agent/pkg/auditor"
 	"github.com/DataDog/datadog-log-agent/pkg/config"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
 	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
 )
 
 const scanPeriod = 10 * time.Second
 
+var (
+	// openFiles is the number of files tailed, and waitingFiles the number of
+	// files matching the patterns waiting for a slot under the open files limit
+	openFiles    = metrics.NewGauge("logs_tailer_open_files")
+	waitingFiles = metrics.NewGauge("logs_tailer_waiting_files")
+	// evictedFiles counts the files no longer tailed to make room for files
+	// modified more recently, under the open files limit
+	evictedFiles = metrics.NewCounter("logs_tailer_evicted_files")
+)
+
 type Scanner struct {
 	sources []*config.IntegrationConfigLogSource
+	// patterns are the sources whose path matches several files
+	patterns []*config.IntegrationConfigLogSource
+	// matches are the sources of the files matching the patterns, by path,
+	// tailed or waiting for a slot under the open files limit
+	matches map[string]*config.IntegrationConfigLogSource
 	pp      *pipeline.PipelineProvider
 	tailers map[string]*Tailer
 	auditor *auditor.Auditor
+	// backfill is nil when reading backlogs is not limited
+	backfill *backfill
+	// devices is nil when the reads by device are not limited
+	devices *deviceBudgets
+	// recreations receives the tailers whose file was deleted and recreated
+	recreations chan *Tailer
+	// sequences are the message counters of the numbered sources,
+	// shared by the successive tailers of a source across rotations
+	sequences map[string]*uint64
+	// openFilesLimit caps the number of files tailed at once, 0 meaning no limit.
+	// deferred are the files matching the patterns which waited for a slot, to
+	// be resumed from their commited offset
+	openFilesLimit int
+	deferred       map[string]bool
+	// mutex guards the tailers between the scans and the sources stopped or
+	// started while the scanner runs
+	mutex sync.Mutex
+
+	startupErrors int
+	stop          chan struct{}
 }
 
 // New returns an initialized Scanner
 func New(sources []*config.IntegrationConfigLogSource, pp *pipeline.PipelineProvider, auditor *auditor.Auditor) *Scanner {
 	tailSources := []*config.IntegrationConfigLogSource{}
+	patterns := []*config.IntegrationConfigLogSource{}
 	for _, source := range sources {
-		switch source.Type {
-		case config.FILE_TYPE:
+		switch {
+		case source.IsPattern():
+			patterns = append(patterns, source)
+		case source.Type == config.FILE_TYPE:
 			tailSources = append(tailSources, source)
 		default:
 		}
 	}
+	backfill := newBackfill(config.LogsAgent.GetInt64("log_backfill_max_bytes_per_second"))
+	if backfill != nil {
+		status.Register("backfill", backfill.status)
+	}
 	return &Scanner{
-		sources: tailSources,
-		pp:      pp,
-		tailers: make(map[string]*Tailer),
-		auditor: auditor,
+		sources:  tailSources,
+		patterns: patterns,
+		matches:  make(map[string]*config.IntegrationConfigLogSource),
+		pp:       pp,
+
+		openFilesLimit: config.OpenFilesLimit(),
+		deferred:       make(map[string]bool),
+
+		tailers:  make(map[string]*Tailer),
+		auditor:  auditor,
+		backfill: backfill,
+		devices:  newDeviceBudgets(config.LogsAgent.GetInt64("log_device_max_read_bytes_per_second")),
+
+		recreations: make(chan *Tailer, len(tailSources)),
+		sequences:   make(map[string]*uint64),
+		stop:        make(chan struct{}),
 	}
 }
 
-// setup sets all tailers
+// setup sets all tailers, those of the sources of a file before those of
+// the files matching a pattern under the open files limit
 func (s *Scanner) setup() {
 	for _, source := range s.sources {
-		if _, ok := s.tailers[source.Path]; ok {
-			log.Println("Can't tail file twice:", source.Path)
+		s.setupSource(source)
+	}
+	s.expandPatterns(false)
+	s.reportOpenFiles()
+}
+
+// setupSource sets the tailer of the source of a file, resuming from its commited offset
+func (s *Scanner) setupSource(source *config.IntegrationConfigLogSource) {
+	if config.IsExcludedPath(source.Path) {
+		log.Println("Not tailing", source.Path, "excluded by", config.ExcludedPathsKey)
+	} else if _, ok := s.tailers[source.Path]; ok {
+		log.Println("Can't tail file twice:", source.Path)
+	} else if s.openFilesLimit > 0 && len(s.tailers) >= s.openFilesLimit {
+		log.Println("Not tailing", source.Path, "over", config.OpenFilesLimitKey)
+	} else if err := s.setupTailer(source, false, s.pp.NextPipelineChan()); err != nil {
+		s.startupErrors++
+	}
+}
+
+// StartSource starts tailing the files of source from their commited offsets,
+// unless it's of another type or already tailed
+func (s *Scanner) StartSource(source *config.IntegrationConfigLogSource) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if hasSource(s.sources, source) || hasSource(s.patterns, source) {
+		return
+	}
+	switch {
+	case source.IsPattern():
+		s.patterns = append(s.patterns, source)
+		s.expandPatterns(false)
+	case source.Type == config.FILE_TYPE:
+		s.sources = append(s.sources, source)
+		s.setupSource(source)
+	default:
+		return
+	}
+	s.reportOpenFiles()
+}
+
+// hasSource returns true if sources has a source with the id of source
+func hasSource(sources []*config.IntegrationConfigLogSource, source *config.IntegrationConfigLogSource) bool {
+	for _, s := range sources {
+		if s.GetID() == source.GetID() {
+			return true
+		}
+	}
+	return false
+}
+
+// StopSource stops tailing the files of the source with id, keeping their
+// offsets, the files matching the patterns of other sources excepted
+func (s *Scanner) StopSource(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sources := s.sources[:0]
+	for _, source := range s.sources {
+		if source.GetID() == id {
+			s.pauseTailer(source.Path)
+		} else {
+			sources = append(sources, source)
+		}
+	}
+	s.sources = sources
+	patterns := s.patterns[:0]
+	stopped := false
+	for _, pattern := range s.patterns {
+		if pattern.GetID() == id {
+			stopped = true
 		} else {
-			s.setupTailer(source, false, s.pp.NextPipelineChan())
+			patterns = append(patterns, pattern)
+		}
+	}
+	s.patterns = patterns
+	if stopped {
+		matched := make(map[string]bool)
+		for _, pattern := range s.patterns {
+			for _, path := range expandPattern(pattern.Path) {
+				if !pattern.ExcludesPath(path) {
+					matched[path] = true
+				}
+			}
+		}
+		for path := range s.matches {
+			if !matched[path] {
+				s.pauseTailer(path)
+				delete(s.matches, path)
+				delete(s.deferred, path)
+			}
+		}
+	}
+	s.reportOpenFiles()
+}
+
+// expandPatterns tails the new files matching the patterns, from their begining
+// unless the agent is starting, and stops tailing the files that disappeared
+func (s *Scanner) expandPatterns(tailFromBegining bool) {
+	if len(s.patterns) == 0 {
+		return
+	}
+	matched := make(map[string]bool)
+	for _, pattern := range s.patterns {
+		paths := expandPattern(pattern.Path)
+		if len(paths) == 0 && !tailFromBegining {
+			log.Println("No file matches", pattern.Path, "yet")
+		}
+		for _, path := range paths {
+			if pattern.ExcludesPath(path) {
+				continue
+			}
+			if matched[path] {
+				// matched by several patterns, tailed once
+				continue
+			}
+			matched[path] = true
+			if _, ok := s.tailers[path]; ok {
+				continue
+			}
+			if _, ok := s.matches[path]; ok {
+				// waiting for a slot
+				continue
+			}
+			source := pattern.ForPath(path)
+			s.matches[path] = source
+			if s.openFilesLimit > 0 {
+				continue
+			}
+			if err := s.setupTailer(source, tailFromBegining, s.pp.NextPipelineChan()); err != nil {
+				s.stopTailer(path)
+			}
+		}
+	}
+	for path := range s.matches {
+		if !matched[path] {
+			log.Println("File no longer matches:", path)
+			s.stopTailer(path)
+		}
+	}
+	if s.openFilesLimit > 0 {
+		s.scheduleMatches(tailFromBegining)
+	}
+}
+
+// scheduleMatches tails the files matching the patterns which were modified last,
+// in the slots left by the sources of a file under the open files limit. The
+// files modified before are evicted, read to their end first, and resumed
+// from their commited offset once modified again
+func (s *Scanner) scheduleMatches(tailFromBegining bool) {
+	slots := s.openFilesLimit
+	for path := range s.tailers {
+		if _, ok := s.matches[path]; !ok {
+			slots--
+		}
+	}
+	if slots < 0 {
+		slots = 0
+	}
+	paths := make([]string, 0, len(s.matches))
+	modTimes := make(map[string]time.Time, len(s.matches))
+	for path := range s.matches {
+		paths = append(paths, path)
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if !modTimes[paths[i]].Equal(modTimes[paths[j]]) {
+			return modTimes[paths[i]].After(modTimes[paths[j]])
+		}
+		return paths[i] < paths[j]
+	})
+	if slots > len(paths) {
+		slots = len(paths)
+	}
+	// room is made before opening files
+	for _, path := range paths[slots:] {
+		if _, ok := s.tailers[path]; ok {
+			s.evictTailer(path)
+		}
+	}
+	for _, path := range paths[:slots] {
+		if _, ok := s.tailers[path]; !ok {
+			s.startMatch(path, tailFromBegining)
+		}
+	}
+	for _, path := range paths {
+		if _, ok := s.tailers[path]; !ok {
+			s.deferred[path] = true
 		}
 	}
 }
 
+// reportOpenFiles updates the gauges of the files tailed and waiting for a slot
+func (s *Scanner) reportOpenFiles() {
+	openFiles.Set(int64(len(s.tailers)))
+	waitingFiles.Set(int64(len(s.deferred)))
+}
+
+// startMatch tails the file matching a pattern at path, the files which waited
+// for a slot being resumed from their commited offset, or from their begining
+// when none of their messages was sent
+func (s *Scanner) startMatch(path string, tailFromBegining bool) {
+	source := s.matches[path]
+	if s.deferred[path] {
+		source.StartPosition = config.StartPositionBeginning
+		tailFromBegining = false
+		delete(s.deferred, path)
+	}
+	if err := s.setupTailer(source, tailFromBegining, s.pp.NextPipelineChan()); err != nil {
+		s.stopTailer(path)
+	}
+}
+
+// evictTailer stops tailing the file matching a pattern at path, keeping its
+// offset, to make room for a file modified more recently
+func (s *Scanner) evictTailer(path string) {
+	log.Println("Not tailing", path, "anymore, files modified more recently are tailed under", config.OpenFilesLimitKey)
+	evictedFiles.Add(1)
+	tailer := s.tailers[path]
+	shouldTrackOffset := true
+	tailer.Stop(shouldTrackOffset)
+	s.auditor.UntrackReader(tailer.Identifier())
+	delete(s.tailers, path)
+}
+
+// pauseTailer stops tailing the file at path, keeping its offset to resume from
+func (s *Scanner) pauseTailer(path string) {
+	if tailer, ok := s.tailers[path]; ok {
+		log.Println("Not tailing", path, "anymore, its source is stopped")
+		shouldTrackOffset := true
+		tailer.Stop(shouldTrackOffset)
+		s.auditor.UntrackReader(tailer.Identifier())
+		delete(s.tailers, path)
+	}
+}
+
+// stopTailer stops tailing the file matching a pattern at path
+func (s *Scanner) stopTailer(path string) {
+	if tailer, ok := s.tailers[path]; ok {
+		shouldTrackOffset := false
+		tailer.Stop(shouldTrackOffset)
+		s.auditor.UntrackReader(tailer.Identifier())
+		delete(s.tailers, path)
+	}
+	delete(s.matches, path)
+	delete(s.deferred, path)
+}
+
 // setupTailer sets one tailer, making it tail from the begining or the end
-func (s *Scanner) setupTailer(source *config.IntegrationConfigLogSource, tailFromBegining bool, outputChan chan message.Message) {
-	t := NewTailer(outputChan, source)
+func (s *Scanner) setupTailer(source *config.IntegrationConfigLogSource, tailFromBegining bool, outputChan chan message.Message) error {
+	t := s.newTailer(source, outputChan)
 	var err error
 	if tailFromBegining {
 		err = t.tailFromBegining()
@@ -65,10 +363,47 @@ func (s *Scanner) setupTailer(source *config.IntegrationConfigLogSource, tailFro
 		// resume tailing from last commited offset
 		err = t.recoverTailing(s.auditor)
 	}
+	return s.trackTailer(t, err)
+}
+
+// setupTailerAt sets one tailer, making it tail from offset
+func (s *Scanner) setupTailerAt(source *config.IntegrationConfigLogSource, offset int64, outputChan chan message.Message) error {
+	t := s.newTailer(source, outputChan)
+	return s.trackTailer(t, t.tailFrom(offset, os.SEEK_SET))
+}
+
+// newTailer returns a tailer of source sharing the budgets of the scanner
+func (s *Scanner) newTailer(source *config.IntegrationConfigLogSource, outputChan chan message.Message) *Tailer {
+	t := NewTailer(outputChan, source)
+	t.backfill = s.backfill
+	t.recreations = s.recreations
+	if source.SequenceNumbers {
+		t.sequence = s.sequenceOf(source)
+	}
+	if s.devices != nil {
+		t.deviceBucket = s.devices.bucket(source.Path)
+	}
+	return t
+}
+
+// trackTailer registers t, which failed to start when err isn't nil
+func (s *Scanner) trackTailer(t *Tailer, err error) error {
 	if err != nil {
 		log.Println(err)
 	}
-	s.tailers[source.Path] = t
+	s.tailers[t.path] = t
+	s.auditor.TrackReader(t)
+	return err
+}
+
+// sequenceOf returns the message counter of source
+func (s *Scanner) sequenceOf(source *config.IntegrationConfigLogSource) *uint64 {
+	sequence, ok := s.sequences[source.GetID()]
+	if !ok {
+		sequence = new(uint64)
+		s.sequences[source.GetID()] = sequence
+	}
+	return sequence
 }
 
 // Start starts the Scanner
@@ -80,11 +415,34 @@ func (s *Scanner) Start() {
 // run lets the Scanner tail its file
 func (s *Scanner) run() {
 	ticker := time.NewTicker(scanPeriod)
-	for _ = range ticker.C {
-		s.scan()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.scan()
+			s.mutex.Unlock()
+		case tailer := <-s.recreations:
+			s.mutex.Lock()
+			s.onFileRecreation(tailer)
+			s.mutex.Unlock()
+		}
 	}
 }
 
+// onFileRecreation starts tailing from its begining the file recreated at the
+// path of tailer, which has finished reading the deleted one
+func (s *Scanner) onFileRecreation(tailer *Tailer) {
+	if s.tailers[tailer.path] != tailer {
+		// the scan already handled it
+		return
+	}
+	log.Println("File recreated:", tailer.path)
+	s.onFileRotation(tailer, tailer.source)
+}
+
 // scan checks all the files we're expected to tail,
 // compares them to the currently tailed files,
 // and triggeres the required updates.
@@ -93,13 +451,15 @@ func (s *Scanner) run() {
 // The Scanner needs to stop that previous tailer,
 // and start a new one for the new file.
 func (s *Scanner) scan() {
-	for _, source := range s.sources {
-		tailer := s.tailers[source.Path]
-		f, err := os.Open(source.Path)
-		if err != nil {
+	s.expandPatterns(true)
+	for _, source := range s.allSources() {
+		tailer, ok := s.tailers[source.Path]
+		if !ok {
+			// over the open files limit
 			continue
 		}
-		stat1, err := f.Stat()
+		// stat'ed by path, without holding the file open
+		stat1, err := os.Stat(source.Path)
 		if err != nil {
 			continue
 		}
@@ -108,41 +468,63 @@ func (s *Scanner) scan() {
 			s.onFileRotation(tailer, source)
 			continue
 		}
-		if inode(stat1) != inode(stat2) {
+		if !os.SameFile(stat1, stat2) {
 			s.onFileRotation(tailer, source)
 			continue
 		}
 
 		if stat1.Size() < tailer.GetReadOffset() {
-			s.onFileRotation(tailer, source)
+			if offset, ok := tailer.collapsedOffset(stat1.Size()); ok {
+				s.onRangeCollapse(tailer, source, offset)
+			} else {
+				s.onFileRotation(tailer, source)
+			}
 		}
 	}
+	s.reportOpenFiles()
+}
+
+// allSources returns the sources of all the tailed files
+func (s *Scanner) allSources() []*config.IntegrationConfigLogSource {
+	if len(s.matches) == 0 {
+		return s.sources
+	}
+	sources := make([]*config.IntegrationConfigLogSource, 0, len(s.sources)+len(s.matches))
+	sources = append(sources, s.sources...)
+	for _, source := range s.matches {
+		sources = append(sources, source)
+	}
+	return sources
 }
 
 func (s *Scanner) onFileRotation(tailer *Tailer, source *config.IntegrationConfigLogSource) {
+	// the rotated file is finished
+	tailer.StopAtEnd()
+	s.setupTailer(source, true, tailer.outputChan)
+}
+
+// onRangeCollapse resumes tailing at offset the file of tailer, a range
+// before its read offset having been removed
+func (s *Scanner) onRangeCollapse(tailer *Tailer, source *config.IntegrationConfigLogSource, offset int64) {
+	log.Printf("%d bytes removed before the read offset of %s", tailer.GetReadOffset()-offset, source.Path)
+	collapsedRanges.Add(1)
 	shouldTrackOffset := false
 	tailer.Stop(shouldTrackOffset)
-	s.setupTailer(source, true, tailer.outputChan)
+	s.setupTailerAt(source, offset, tailer.outputChan)
+}
+
+// StartupErrors returns the number of files that couldn't be tailed on start
+func (s *Scanner) StartupErrors() int {
+	return s.startupErrors
 }
 
 // Stop stops the Scanner and its tailers
 func (s *Scanner) Stop() {
+	close(s.stop)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	shouldTrackOffset := true
 	for _, t := range s.tailers {
 		t.Stop(shouldTrackOffset)
 	}
 }
-
-// inode uniquely identifies a file on a filesystem
-func inode(f os.FileInfo) uint64 {
-	s := f.Sys()
-	if s == nil {
-		return 0
-	}
-	switch s := s.(type) {
-	case *syscall.Stat_t:
-		return uint64(s.Ino)
-	default:
-		return 0
-	}
-}
@@ -163,6 +163,185 @@ func (suite *ScannerTestSuite) TestScannerScanWithLogRotationCopyTruncate() {
 	suite.Equal(int64(6), newTailer.GetReadOffset())
 }
 
+func (suite *ScannerTestSuite) TestScannerStopsAndStartsSource() {
+	s := suite.s
+	source := suite.sources[0]
+
+	s.StopSource(source.GetID())
+	suite.Equal(0, len(s.tailers))
+	suite.Equal(0, len(s.sources))
+
+	s.StartSource(source)
+	s.StartSource(source)
+	suite.Equal(1, len(s.sources))
+	tailer := s.tailers[source.Path]
+	suite.NotNil(tailer)
+	tailer.sleepMutex.Lock()
+	tailer.sleepDuration = 100 * time.Millisecond
+	tailer.sleepMutex.Unlock()
+
+	_, err := suite.testFile.WriteString("hello again\n")
+	suite.Nil(err)
+	msg := <-suite.outputChan
+	suite.Equal("hello again", string(msg.Content()))
+}
+
 func TestScannerTestSuite(t *testing.T) {
 	suite.Run(t, new(ScannerTestSuite))
 }
+
+func (suite *ScannerTestSuite) TestScannerRestartsRecreatedFile() {
+	s := suite.s
+	sources := suite.sources
+
+	_, err := suite.testFile.WriteString("hello world\n")
+	suite.Nil(err)
+	msg := <-suite.outputChan
+	suite.Equal("hello world", string(msg.Content()))
+
+	tailer := s.tailers[sources[0].Path]
+	suite.Nil(os.Remove(suite.testPath))
+	f, err := os.Create(suite.testPath)
+	suite.Nil(err)
+	defer f.Close()
+
+	// the tailer notices the new file without waiting for a scan
+	suite.True(tailer == <-s.recreations)
+	s.onFileRecreation(tailer)
+	newTailer := s.tailers[sources[0].Path]
+	suite.True(tailer != newTailer)
+
+	_, err = f.WriteString("hello again\n")
+	suite.Nil(err)
+	msg = <-suite.outputChan
+	suite.Equal("hello again", string(msg.Content()))
+	suite.Equal(int64(12), newTailer.GetReadOffset())
+
+	// a late notification is ignored
+	s.onFileRecreation(tailer)
+	suite.True(newTailer == s.tailers[sources[0].Path])
+}
+
+func (suite *ScannerTestSuite) TestScannerCountsStartupErrors() {
+	suite.Equal(0, suite.s.StartupErrors())
+
+	sources := []*config.IntegrationConfigLogSource{{Type: config.FILE_TYPE, Path: suite.testDir + "/missing.log"}}
+	s := New(sources, suite.pp, auditor.New(nil))
+	s.setup()
+	defer s.Stop()
+	suite.Equal(1, s.StartupErrors())
+}
+
+func (suite *ScannerTestSuite) TestScannerFollowsPathPattern() {
+	dir := suite.testDir + "/pattern"
+	suite.Nil(os.MkdirAll(dir, 0755))
+	defer os.RemoveAll(dir)
+
+	sources := []*config.IntegrationConfigLogSource{{Type: config.FILE_TYPE, Path: dir + "/*.log"}}
+	s := New(sources, suite.pp, auditor.New(nil))
+	s.setup()
+	defer s.Stop()
+	suite.Equal(0, len(s.tailers))
+	suite.Equal(0, s.StartupErrors())
+
+	// a new file is tailed from its begining
+	f, err := os.Create(dir + "/a.log")
+	suite.Nil(err)
+	defer f.Close()
+	_, err = f.WriteString("hello world\n")
+	suite.Nil(err)
+	s.scan()
+	suite.Equal(1, len(s.tailers))
+	tailer := s.tailers[dir+"/a.log"]
+	suite.NotNil(tailer)
+	msg := <-suite.outputChan
+	suite.Equal("hello world", string(msg.Content()))
+	suite.NotEqual(sources[0].GetID(), tailer.Identifier())
+
+	// a file which disappears is no longer tailed
+	suite.Nil(os.Remove(dir + "/a.log"))
+	s.scan()
+	suite.Equal(0, len(s.tailers))
+	suite.Equal(0, len(s.matches))
+}
+
+func (suite *ScannerTestSuite) TestScannerEnforcesOpenFilesLimit() {
+	dir := suite.testDir + "/limit"
+	suite.Nil(os.MkdirAll(dir, 0755))
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	for i, name := range []string{"a.log", "b.log"} {
+		f, err := os.Create(dir + "/" + name)
+		suite.Nil(err)
+		f.Close()
+		modTime := now.Add(-time.Duration(i+1) * time.Minute)
+		suite.Nil(os.Chtimes(dir+"/"+name, modTime, modTime))
+	}
+
+	sources := []*config.IntegrationConfigLogSource{{Type: config.FILE_TYPE, Path: dir + "/*.log"}}
+	s := New(sources, suite.pp, auditor.New(nil))
+	s.openFilesLimit = 1
+	s.setup()
+	defer s.Stop()
+	// the file modified last is tailed
+	suite.Equal(1, len(s.tailers))
+	suite.NotNil(s.tailers[dir+"/a.log"])
+	suite.True(s.deferred[dir+"/b.log"])
+	suite.Equal(int64(1), waitingFiles.Value())
+
+	// the waiting file is tailed once modified, from its begining, in place of the other
+	evicted := evictedFiles.Value()
+	f, err := os.OpenFile(dir+"/b.log", os.O_WRONLY|os.O_APPEND, 0644)
+	suite.Nil(err)
+	defer f.Close()
+	_, err = f.WriteString("hello world\n")
+	suite.Nil(err)
+	s.scan()
+	suite.Equal(1, len(s.tailers))
+	suite.NotNil(s.tailers[dir+"/b.log"])
+	suite.True(s.deferred[dir+"/a.log"])
+	suite.Equal(int64(1), evictedFiles.Value()-evicted)
+	suite.Equal("hello world", string((<-suite.outputChan).Content()))
+}
+
+func (suite *ScannerTestSuite) TestScannerSkipsExcludedPaths() {
+	dir := suite.testDir + "/excluded"
+	suite.Nil(os.MkdirAll(dir, 0755))
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"app.log", "app.log.1.gz", "debug-1.log"} {
+		f, err := os.Create(dir + "/" + name)
+		suite.Nil(err)
+		f.Close()
+	}
+
+	sources := []*config.IntegrationConfigLogSource{{Type: config.FILE_TYPE, Path: dir + "/*", ExcludePaths: []string{"*.gz", "debug-*.log"}}}
+	s := New(sources, suite.pp, auditor.New(nil))
+	s.setup()
+	defer s.Stop()
+	suite.Equal(1, len(s.tailers))
+	suite.NotNil(s.tailers[dir+"/app.log"])
+}
+
+func (suite *ScannerTestSuite) TestScannerSkipsGloballyExcludedPaths() {
+	dir := suite.testDir + "/globally_excluded"
+	suite.Nil(os.MkdirAll(dir, 0755))
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"app.log", "secure"} {
+		f, err := os.Create(dir + "/" + name)
+		suite.Nil(err)
+		f.Close()
+	}
+	defer config.LogsAgent.Set(config.ExcludedPathsKey, []string{})
+	config.LogsAgent.Set(config.ExcludedPathsKey, []string{dir + "/secure"})
+
+	sources := []*config.IntegrationConfigLogSource{
+		{Type: config.FILE_TYPE, Path: dir + "/*"},
+		{Type: config.FILE_TYPE, Path: dir + "/secure"},
+	}
+	s := New(sources, suite.pp, auditor.New(nil))
+	s.setup()
+	defer s.Stop()
+	suite.Equal(1, len(s.tailers))
+	suite.NotNil(s.tailers[dir+"/app.log"])
+	suite.Equal(0, s.StartupErrors())
+}
@@ -19,41 +19,108 @@ import (
 	"github.com/DataDog/datadog-log-agent/pkg/config"
 	"github.com/DataDog/datadog-log-agent/pkg/decoder"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
 )
 
 const defaultSleepDuration = 1 * time.Second
+
+// maxIdleSleepFactor caps the polling interval of the idle files, as a multiple of sleepDuration
+const maxIdleSleepFactor = 8
 const defaultCloseTimeout = 60 * time.Second
 
+// A logFile is a file being tailed, opened by openFile
+type logFile interface {
+	io.ReadSeeker
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
 // Tailer tails one file and sends messages to an output channel
 type Tailer struct {
 	path string
-	file *os.File
+	file logFile
 
-	readOffset        int64
-	decodedOffset     int64
+	readOffset    int64
+	decodedOffset int64
+	// skippedBytes are the bytes read but skipped since the last message decoded
+	skippedBytes      int64
 	shouldTrackOffset bool
 
+	// fingerprint holds the bytes read last, up to the read offset
+	fingerprint      []byte
+	fingerprintMutex sync.Mutex
+
+	// identity identifies the tailed file in the registry, computed on demand
+	identity      *auditor.FileIdentity
+	identityMutex sync.Mutex
+	// committed identifies the file of the commited offset the tailer resumes from
+	committed *auditor.FileIdentity
+
 	outputChan chan message.Message
 	d          *decoder.Decoder
 	source     *config.IntegrationConfigLogSource
+	// tagsPayload holds the tags extracted from the path, nil without path_tags
+	tagsPayload []byte
+	// cri parses the lines of the files of the cri format, nil for raw lines
+	cri *criParser
+
+	// the file is read at a bounded rate up to backlogEnd when recovering
+	backfill   *backfill
+	backlogEnd int64
+	// deviceBucket is shared by the tailers of the files on the same device
+	deviceBucket *utils.TokenBucket
+
+	// recreations is notified once when the file is deleted and recreated
+	recreations chan *Tailer
+	recreated   bool
+
+	// detectBinary is false when the file is expected to have NUL bytes,
+	// binarySince being set while the file is deemed binary, until binaryCheck
+	detectBinary bool
+	binarySince  time.Time
+	binaryCheck  time.Time
 
-	sleepDuration time.Duration
-	sleepMutex    sync.Mutex
+	// sequence is the number of the last message, nil when the source isn't numbered
+	sequence *uint64
+
+	// sleepDuration is how long the tailer waits for the file to grow, doubling
+	// in idleSleepDuration while the file stays idle, up to maxSleepDuration,
+	// or maxIdleSleepFactor times sleepDuration when 0
+	sleepDuration     time.Duration
+	idleSleepDuration time.Duration
+	maxSleepDuration  time.Duration
+	sleepMutex        sync.Mutex
 
 	closeTimeout time.Duration
 	shouldStop   bool
 	stopTimer    *time.Timer
 	stopMutex    sync.Mutex
+	// endOfStream is true when the file won't be written anymore, and
+	// readToEnd once it was read to its end before stopping
+	endOfStream bool
+	readToEnd   int32
 }
 
 // NewTailer returns an initialized Tailer
 func NewTailer(outputChan chan message.Message, source *config.IntegrationConfigLogSource) *Tailer {
+	var tagsPayload []byte
+	if source.PathTagsReg != nil {
+		tagsPayload = config.BuildTagsPayload(source, config.PathTags(source, source.Path))
+	}
+	var cri *criParser
+	if source.Format == config.FormatCRI {
+		cri = &criParser{}
+	}
 	return &Tailer{
 		path:       source.Path,
 		outputChan: outputChan,
 		d:          decoder.InitializeDecoder(source),
 		source:     source,
 
+		tagsPayload:  tagsPayload,
+		cri:          cri,
+		detectBinary: detectsBinary(source),
+
 		readOffset:        0,
 		shouldTrackOffset: true,
 
@@ -67,13 +134,36 @@ func NewTailer(outputChan chan message.Message, source *config.IntegrationConfig
 
 // Identifier returns a string that uniquely identifies a source
 func (t *Tailer) Identifier() string {
+	return t.source.GetID()
+}
+
+// legacyIdentifier returns the identifier of the source in the registries
+// written before the sources had stable identifiers
+func (t *Tailer) legacyIdentifier() string {
 	return fmt.Sprintf("file:%s", t.source.Path)
 }
 
-// recoverTailing starts the tailing from the last log line processed, or now
-// if we tail this file for the first time
+// recoverTailing starts the tailing from the last log line processed, or from
+// the start position of the source if we tail this file for the first time
 func (t *Tailer) recoverTailing(a *auditor.Auditor) error {
-	return t.tailFrom(a.GetLastCommitedOffset(t.Identifier()))
+	a.MigrateIdentifier(t.legacyIdentifier(), t.Identifier())
+	offset, whence := a.GetLastCommitedOffset(t.Identifier())
+	if whence == os.SEEK_END && t.source.StartPosition == config.StartPositionBeginning {
+		// no offset was saved, the file is backfilled
+		offset, whence = 0, os.SEEK_SET
+	}
+	t.committed = a.GetLastCommitedFile(t.Identifier())
+	if t.sequence != nil {
+		// messages replayed from the commited offset keep their numbers
+		atomic.StoreUint64(t.sequence, a.GetLastCommitedSequence(t.Identifier()))
+	}
+	if t.backfill != nil {
+		// what was written while the agent was down is a backlog
+		if info, err := os.Stat(t.path); err == nil {
+			t.backlogEnd = info.Size()
+		}
+	}
+	return t.tailFrom(offset, whence)
 }
 
 // Stop lets  the tailer stop
@@ -85,12 +175,23 @@ func (t *Tailer) Stop(shouldTrackOffset bool) {
 	t.stopMutex.Unlock()
 }
 
+// StopAtEnd lets the tailer stop once its file, which won't be written anymore,
+// is read to its end, its last message being followed by an end of stream message
+func (t *Tailer) StopAtEnd() {
+	t.stopMutex.Lock()
+	t.endOfStream = true
+	t.stopMutex.Unlock()
+	shouldTrackOffset := false
+	t.Stop(shouldTrackOffset)
+}
+
 // onStop handles the housekeeping when we stop the tailer
 func (t *Tailer) onStop() {
 	t.stopMutex.Lock()
 	t.d.Stop()
 	log.Println("Closing", t.path)
 	t.file.Close()
+	t.clearBinary()
 	t.stopTimer.Stop()
 	t.stopMutex.Unlock()
 }
@@ -111,11 +212,19 @@ func (t *Tailer) startReading(offset int64, whence int) error {
 		return err
 	}
 	log.Println("Opening", t.path)
-	f, err := os.Open(fullpath)
+	f, err := openFile(fullpath)
 	if err != nil {
 		return err
 	}
+	if t.committed != nil && !isCommittedFile(fullpath, f, t.committed) {
+		// the commited offset belongs to a file deleted while the agent was down
+		log.Println(t.path, "was replaced, tailing it from its begining")
+		replacedFiles.Add(1)
+		offset, whence = 0, os.SEEK_SET
+	}
+	t.committed = nil
 	ret, _ := f.Seek(offset, whence)
+	ret = skipHole(f, ret)
 	t.file = f
 	t.readOffset = ret
 	t.decodedOffset = ret
@@ -140,26 +249,74 @@ func (t *Tailer) tailFromEnd() error {
 func (t *Tailer) forwardMessages() {
 	for output := range t.d.OutputChan {
 		if output.ShouldStop {
+			t.forwardEndOfStream()
 			return
 		}
 
-		fileMsg := message.NewFileMessage(output.Content)
+		// the binary data skipped before the message isn't decoded
+		t.decodedOffset += atomic.SwapInt64(&t.skippedBytes, 0)
+		startOffset := t.decodedOffset
 		msgOffset := t.decodedOffset + int64(output.RawDataLen)
+		t.decodedOffset = msgOffset
+		content := output.Content
+		var timestamp string
+		var severity []byte
+		if t.cri != nil {
+			line, complete := t.cri.parse(output.Content, startOffset)
+			if !complete {
+				// committed along with the line completing it
+				continue
+			}
+			content, timestamp, severity, startOffset = line.content, line.timestamp, line.severity, line.startOffset
+		}
+
+		fileMsg := message.NewFileMessage(content)
+		if severity != nil {
+			fileMsg.SetSeverity(severity)
+		}
 		identifier := t.Identifier()
-		if !t.shouldTrackOffset {
+		// the tailer can be stopped while its last messages are forwarded
+		t.stopMutex.Lock()
+		shouldTrackOffset := t.shouldTrackOffset
+		t.stopMutex.Unlock()
+		if !shouldTrackOffset {
 			msgOffset = 0
 			identifier = ""
 		}
-		t.decodedOffset = msgOffset
 		msgOrigin := message.NewOrigin()
 		msgOrigin.LogSource = t.source
 		msgOrigin.Identifier = identifier
 		msgOrigin.Offset = msgOffset
+		msgOrigin.Path = t.path
+		msgOrigin.StartOffset = startOffset
+		msgOrigin.Timestamp = timestamp
+		if t.sequence != nil {
+			msgOrigin.SourceSequence = atomic.AddUint64(t.sequence, 1)
+		}
 		fileMsg.SetOrigin(msgOrigin)
+		if t.tagsPayload != nil {
+			fileMsg.SetTagsPayload(t.tagsPayload)
+		}
 		t.outputChan <- fileMsg
 	}
 }
 
+// forwardEndOfStream sends the end of stream message of the file after its
+// last message, when it was stopped at its end
+func (t *Tailer) forwardEndOfStream() {
+	t.stopMutex.Lock()
+	endOfStream := t.endOfStream
+	t.stopMutex.Unlock()
+	if !endOfStream || atomic.LoadInt32(&t.readToEnd) == 0 {
+		return
+	}
+	origin := message.NewOrigin()
+	origin.LogSource = t.source
+	origin.Path = t.path
+	origin.Offset = t.decodedOffset
+	t.outputChan <- message.NewEndOfStreamMessage(origin)
+}
+
 // readForever lets the tailer tail the content of a file
 // until it is closed.
 func (t *Tailer) readForever() {
@@ -169,13 +326,15 @@ func (t *Tailer) readForever() {
 			return
 		}
 
-		inBuf := make([]byte, 4096)
+		inBuf := make([]byte, readBufferSize)
 		n, err := t.file.Read(inBuf)
 		if err == io.EOF {
 			if t.shouldSoftStop() {
+				atomic.StoreInt32(&t.readToEnd, 1)
 				t.onStop()
 				return
 			}
+			t.checkRecreation()
 			t.wait()
 			continue
 		}
@@ -187,8 +346,33 @@ func (t *Tailer) readForever() {
 			t.wait()
 			continue
 		}
-		t.d.InputChan <- decoder.NewInput(inBuf[:n])
-		t.incrementReadOffset(n)
+		t.resetWait()
+		if t.deviceBucket != nil {
+			t.deviceBucket.Wait(n)
+		}
+		t.throttle(n)
+		if !t.skipBinary(inBuf[:n]) {
+			t.d.InputChan <- decoder.NewInput(inBuf[:n])
+		}
+		t.recordRead(inBuf[:n])
+	}
+}
+
+// throttle blocks until n bytes of the backlog can be forwarded,
+// live data being forwarded right away
+func (t *Tailer) throttle(n int) {
+	if t.backfill == nil {
+		return
+	}
+	offset := t.GetReadOffset()
+	if offset >= t.backlogEnd {
+		t.backfill.readLive(n)
+		return
+	}
+	remaining := t.backlogEnd - offset - int64(n)
+	t.backfill.waitBacklog(t.path, n, remaining)
+	if remaining <= 0 {
+		log.Println("Caught up with the backlog of", t.path)
 	}
 }
 
@@ -219,9 +403,50 @@ func (t *Tailer) GetReadOffset() int64 {
 	return atomic.LoadInt64(&t.readOffset)
 }
 
-// wait lets the tailer sleep for a bit
+// checkRecreation notifies the scanner without waiting for its next scan when,
+// the tailed file being read to its end, another file was created at its path
+func (t *Tailer) checkRecreation() {
+	if t.recreations == nil || t.recreated {
+		return
+	}
+	pathInfo, err := os.Stat(t.path)
+	if err != nil {
+		// deleted but not recreated yet
+		return
+	}
+	fileInfo, err := t.file.Stat()
+	if err != nil || os.SameFile(pathInfo, fileInfo) {
+		return
+	}
+	select {
+	case t.recreations <- t:
+		t.recreated = true
+	default:
+	}
+}
+
+// wait lets the tailer sleep for a bit, longer and longer while the
+// file stays idle, so that the quiet files are polled less often
 func (t *Tailer) wait() {
 	t.sleepMutex.Lock()
 	defer t.sleepMutex.Unlock()
-	time.Sleep(t.sleepDuration)
+	if t.idleSleepDuration < t.sleepDuration {
+		t.idleSleepDuration = t.sleepDuration
+	}
+	time.Sleep(t.idleSleepDuration)
+	t.idleSleepDuration *= 2
+	maxSleep := t.maxSleepDuration
+	if maxSleep == 0 {
+		maxSleep = maxIdleSleepFactor * t.sleepDuration
+	}
+	if t.idleSleepDuration > maxSleep {
+		t.idleSleepDuration = maxSleep
+	}
+}
+
+// resetWait polls the file at the base interval again once it's active
+func (t *Tailer) resetWait() {
+	t.sleepMutex.Lock()
+	defer t.sleepMutex.Unlock()
+	t.idleSleepDuration = 0
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DataDog/datadog-log-agent/pkg/auditor"
 	"github.com/DataDog/datadog-log-agent/pkg/config"
 	"github.com/DataDog/datadog-log-agent/pkg/decoder"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
@@ -67,11 +68,54 @@ func (suite *TailerTestSuite) TestTailerTails() {
 	msg = <-suite.outputChan
 	suite.Equal("hello again", string(msg.Content()))
 
-	suite.Equal("file:tests/tailer/tailer.log", suite.tl.Identifier())
+	suite.Equal(suite.source.GetID(), msg.GetOrigin().Identifier)
+	suite.Equal(suite.testPath, msg.GetOrigin().Path)
+	suite.Equal(int64(12), msg.GetOrigin().StartOffset)
+	suite.Equal(int64(24), msg.GetOrigin().Offset)
+}
+
+func (suite *TailerTestSuite) TestTailerTagsMessagesWithPathComponents() {
+	suite.source.PathTags = "tests/{service}/*.log"
+	suite.source.PathTagsReg, _ = config.CompilePathTags(suite.source.PathTags)
+	tl := NewTailer(suite.outputChan, suite.source)
+	tl.sleepDuration = 10 * time.Millisecond
+	defer tl.Stop(false)
+	tl.tailFromEnd()
+
+	_, err := suite.testFile.WriteString("hello world\n")
+	suite.Nil(err)
+	msg := <-suite.outputChan
+	suite.Equal(`[dd ddtags="service:tailer"]`, string(msg.GetTagsPayload()))
+}
+
+func (suite *TailerTestSuite) TestTailerNumbersMessages() {
+	sequence := uint64(41)
+	suite.tl.sequence = &sequence
+	suite.tl.tailFromEnd()
+
+	_, err := suite.testFile.WriteString("hello world\nhello again\n")
+	suite.Nil(err)
+	msg := <-suite.outputChan
+	suite.Equal(uint64(42), msg.GetOrigin().SourceSequence)
+	msg = <-suite.outputChan
+	suite.Equal(uint64(43), msg.GetOrigin().SourceSequence)
 }
 
 func (suite *TailerTestSuite) TestTailerIdentifier() {
-	suite.Equal("file:tests/tailer/tailer.log", suite.tl.Identifier())
+	suite.Equal(suite.source.GetID(), suite.tl.Identifier())
+	suite.Equal("file:tests/tailer/tailer.log", suite.tl.legacyIdentifier())
+}
+
+func (suite *TailerTestSuite) TestTailerBacksOffWhenIdle() {
+	tl := NewTailer(suite.outputChan, suite.source)
+	tl.sleepDuration = time.Millisecond
+	for i := 0; i < 5; i++ {
+		tl.wait()
+	}
+	suite.Equal(maxIdleSleepFactor*time.Millisecond, tl.idleSleepDuration)
+	tl.resetWait()
+	tl.wait()
+	suite.Equal(2*time.Millisecond, tl.idleSleepDuration)
 }
 
 func (suite *TailerTestSuite) TestTailerLifecycle() {
@@ -81,6 +125,35 @@ func (suite *TailerTestSuite) TestTailerLifecycle() {
 	// this will be fixed when we implement stop pills
 }
 
+func (suite *TailerTestSuite) TestTailerStoppedAtEndSendsEndOfStream() {
+	_, err := suite.testFile.WriteString("hello world\nhello again\n")
+	suite.Nil(err)
+	suite.tl.tailFromBegining()
+	suite.tl.StopAtEnd()
+
+	suite.Equal("hello world", string((<-suite.outputChan).Content()))
+	suite.Equal("hello again", string((<-suite.outputChan).Content()))
+	msg := <-suite.outputChan
+	suite.True(message.IsEndOfStream(msg))
+	suite.Equal(suite.testPath, msg.GetOrigin().Path)
+	suite.Equal(int64(24), msg.GetOrigin().Offset)
+}
+
+func (suite *TailerTestSuite) TestTailerStartPositionWithoutSavedOffset() {
+	_, err := suite.testFile.WriteString("historical\n")
+	suite.Nil(err)
+	suite.source.StartPosition = config.StartPositionBeginning
+	suite.Nil(suite.tl.recoverTailing(auditor.New(nil)))
+	suite.Equal("historical", string((<-suite.outputChan).Content()))
+
+	source := &config.IntegrationConfigLogSource{Type: config.FILE_TYPE, Path: suite.testPath}
+	tl := NewTailer(suite.outputChan, source)
+	tl.sleepDuration = 10 * time.Millisecond
+	suite.Nil(tl.recoverTailing(auditor.New(nil)))
+	defer tl.Stop(false)
+	suite.Equal(int64(11), tl.GetReadOffset())
+}
+
 func writeMessage(file *os.File) {
 	time.Sleep(time.Millisecond)
 	file.WriteString("hello world\n")
@@ -102,6 +175,7 @@ func tick() {
 // until EOF
 func (suite *TailerTestSuite) TestTailerIsSlowAndCatchesUp() {
 	suite.tl.sleepDuration = time.Millisecond
+	suite.tl.maxSleepDuration = time.Millisecond
 
 	// mock tailer output channel
 	suite.tl.d.InputChan = make(chan *decoder.Input, 2)
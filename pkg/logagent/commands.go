@@ -0,0 +1,219 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/client"
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/control"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+	"github.com/DataDog/datadog-log-agent/pkg/version"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	testLogSource  = "datadog-log-agent"
+	testLogTimeout = 30 * time.Second
+)
+
+// commands are the actions the logs agent can run instead of starting,
+// returning the exit code of the process
+var commands = map[string]func() int{
+	"check-config":    checkConfig,
+	"describe-source": describeSource,
+	"disable-source":  disableSource,
+	"enable-source":   enableSource,
+	"pause-source":    pauseSource,
+	"resume-source":   resumeSource,
+	"send-test-log":   sendTestLog,
+	"version":         printVersion,
+}
+
+// printVersion prints the build information of the agent
+func printVersion() int {
+	fmt.Println(version.String())
+	return 0
+}
+
+// checkConfig validates the configuration without starting the agent, and
+// prints the errors and warnings of each config file
+func checkConfig() int {
+	exitCode := 0
+	for _, report := range config.CheckConfig(*ddconfigPath, *ddconfdPath) {
+		if len(report.Errors) > 0 {
+			exitCode = 1
+			fmt.Printf("[ERROR] %s\n", report.File)
+		} else if report.Sources > 0 {
+			fmt.Printf("[OK] %s: %d sources\n", report.File, report.Sources)
+		} else {
+			fmt.Printf("[OK] %s\n", report.File)
+		}
+		for _, err := range report.Errors {
+			fmt.Printf("  error: %v\n", err)
+		}
+		for _, warning := range report.Warnings {
+			fmt.Printf("  warning: %s\n", warning)
+		}
+	}
+	return exitCode
+}
+
+// describeSource prints the fully resolved configuration of the sources
+// whose identifier, service or path is the argument of the command
+func describeSource() int {
+	err := config.BuildLogsAgentConfig(*ddconfigPath, *ddconfdPath)
+	if err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		return 1
+	}
+	name := flag.Arg(1)
+	sources := config.FindSources(name)
+	if name == "" || len(sources) == 0 {
+		if name == "" {
+			fmt.Println("Usage: describe-source <id, service or path>")
+		} else {
+			fmt.Printf("Unknown source %s\n", name)
+		}
+		fmt.Println("Available sources:")
+		for _, source := range config.GetLogsSources() {
+			fmt.Printf("  %s\n", source.GetID())
+		}
+		return 1
+	}
+	for _, source := range sources {
+		b, err := yaml.Marshal(config.DescribeSource(source))
+		if err != nil {
+			fmt.Printf("[ERROR] can't describe %s: %v\n", source.GetID(), err)
+			return 1
+		}
+		fmt.Printf("---\n%s", b)
+	}
+	return 0
+}
+
+// pauseSource pauses the collection of the source whose identifier is the
+// argument of the command, through the control API of the running agent
+func pauseSource() int {
+	return controlSource("pause-source", (*client.Client).PauseSource, "Paused")
+}
+
+// resumeSource resumes the collection of the source whose identifier is the
+// argument of the command, through the control API of the running agent
+func resumeSource() int {
+	return controlSource("resume-source", (*client.Client).ResumeSource, "Resumed")
+}
+
+// disableSource disables the collection of the source whose identifier is the
+// argument of the command, across restarts, through the control API of the running agent
+func disableSource() int {
+	return controlSource("disable-source", (*client.Client).DisableSource, "Disabled")
+}
+
+// enableSource enables the collection of the source whose identifier is the
+// argument of the command, through the control API of the running agent
+func enableSource() int {
+	return controlSource("enable-source", (*client.Client).EnableSource, "Enabled")
+}
+
+// controlSource runs action on the source whose identifier is the argument of
+// the command, reporting it as done on success
+func controlSource(command string, action func(c *client.Client, id string) error, done string) int {
+	id := flag.Arg(1)
+	if id == "" {
+		fmt.Printf("Usage: %s <id>\n", command)
+		return 1
+	}
+	err := config.BuildLogsAgentConfig(*ddconfigPath, *ddconfdPath)
+	if err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		return 1
+	}
+	port := config.LogsAgent.GetInt("log_control_port")
+	if port == 0 {
+		fmt.Println("[ERROR] the control API is disabled, set log_control_port")
+		return 1
+	}
+	token, err := control.ReadToken(filepath.Join(config.LogsAgent.GetString("run_path"), control.TokenFile))
+	if err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		return 1
+	}
+	c := client.New(fmt.Sprintf("localhost:%d", port))
+	c.SetToken(token)
+	if err := action(c, id); err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s source %s\n", done, id)
+	return 0
+}
+
+// sendTestLog sends a uniquely identified message through the pipeline
+// to the configured intake, and reports the outcome of each stage
+func sendTestLog() int {
+	err := config.BuildLogsAgentConfig(*ddconfigPath, *ddconfdPath)
+	if err != nil {
+		printStage("configuration", "", err)
+		return 1
+	}
+	printStage("configuration", *ddconfigPath, nil)
+
+	id, err := newTestLogID()
+	if err != nil {
+		printStage("message", "", err)
+		return 1
+	}
+	source := &config.IntegrationConfigLogSource{
+		Service: testLogSource,
+		Source:  testLogSource,
+		Tags:    "test_log_id:" + id,
+	}
+	source.TagsPayload = config.BuildTagsPayload(source, source.Tags)
+	msg := message.NewMessage([]byte(fmt.Sprintf("datadog-log-agent test log %s", id)))
+	origin := message.NewOrigin()
+	origin.LogSource = source
+	msg.SetOrigin(origin)
+
+	exitCode := 0
+	pp := pipeline.NewPipelineProvider()
+	for _, result := range pp.SelfTest(newConnectionManager(), msg, testLogTimeout) {
+		printStage(result.Stage, result.Detail, result.Err)
+		if result.Err != nil {
+			exitCode = 1
+		}
+	}
+	if exitCode == 0 {
+		fmt.Printf("Test log sent, search for test_log_id:%s in your logs\n", id)
+	}
+	return exitCode
+}
+
+// printStage prints the outcome of a stage of a command
+func printStage(stage, detail string, err error) {
+	if err != nil {
+		fmt.Printf("[FAILED] %s: %v\n", stage, err)
+		return
+	}
+	fmt.Printf("[OK] %s: %s\n", stage, detail)
+}
+
+// newTestLogID returns a random identifier
+func newTestLogID() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
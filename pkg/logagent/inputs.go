@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package main
+
+import (
+	"github.com/DataDog/datadog-log-agent/pkg/auditor"
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/input/amqp"
+	"github.com/DataDog/datadog-log-agent/pkg/input/command"
+	"github.com/DataDog/datadog-log-agent/pkg/input/container"
+	"github.com/DataDog/datadog-log-agent/pkg/input/flow"
+	"github.com/DataDog/datadog-log-agent/pkg/input/kafka"
+	"github.com/DataDog/datadog-log-agent/pkg/input/listener"
+	"github.com/DataDog/datadog-log-agent/pkg/input/mqtt"
+	"github.com/DataDog/datadog-log-agent/pkg/input/runner"
+	"github.com/DataDog/datadog-log-agent/pkg/input/snmp"
+	"github.com/DataDog/datadog-log-agent/pkg/input/tailer"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+)
+
+// An input collects the logs of the sources of its types
+type input interface {
+	Start()
+	Stop()
+	// StartupErrors returns the number of sources, or parts of sources, that failed to start
+	StartupErrors() int
+	// StartSource starts collecting source, unless it's of other types or already collected
+	StartSource(source *config.IntegrationConfigLogSource)
+	// StopSource stops collecting the source with id, the other sources being left running
+	StopSource(id string)
+}
+
+// runnerInputs are the inputs running a worker per source, by source type
+var runnerInputs = []struct {
+	sourceType string
+	new        func([]*config.IntegrationConfigLogSource, *pipeline.PipelineProvider) *runner.Runner
+}{
+	{config.MQTT_TYPE, mqtt.New},
+	{config.AMQP_TYPE, amqp.New},
+	{config.KAFKA_TYPE, kafka.New},
+	{config.SNMP_TYPE, snmp.New},
+	{config.FLOW_TYPE, flow.New},
+}
+
+// inputs collect the logs of a set of sources, those of the disabled input classes excepted
+type inputs struct {
+	inputs []input
+	// cri is true when the containers are collected by tailing their log files
+	cri bool
+}
+
+// startInputs starts collecting the logs of sources
+func startInputs(sources []*config.IntegrationConfigLogSource, pp *pipeline.PipelineProvider, a *auditor.Auditor) *inputs {
+	i := &inputs{}
+	if !config.IsInputDisabled(config.TCP_TYPE) {
+		i.inputs = append(i.inputs, listener.New(sources, pp))
+	}
+
+	runtime, _ := config.ResolveContainerRuntime()
+	fileSources := sources
+	if runtime == config.ContainerRuntimeContainerd && !config.IsInputDisabled(config.DOCKER_TYPE) {
+		// containerd has no logs API, the log files of its containers are tailed
+		i.cri = true
+		fileSources = append(append([]*config.IntegrationConfigLogSource{}, sources...), config.CRISources(sources)...)
+	}
+
+	if !config.IsInputDisabled(config.FILE_TYPE) {
+		i.inputs = append(i.inputs, tailer.New(fileSources, pp, a))
+	}
+	if !config.IsInputDisabled(config.DOCKER_TYPE) && runtime != config.ContainerRuntimeContainerd {
+		i.inputs = append(i.inputs, container.New(sources, pp, a))
+	}
+	for _, r := range runnerInputs {
+		if !config.IsInputDisabled(r.sourceType) {
+			i.inputs = append(i.inputs, r.new(sources, pp))
+		}
+	}
+	i.inputs = append(i.inputs, command.New(sources, pp))
+
+	for _, in := range i.inputs {
+		in.Start()
+	}
+	return i
+}
+
+// startupErrors returns the number of sources, or parts of sources, that failed to start
+func (i *inputs) startupErrors() int {
+	errors := 0
+	for _, in := range i.inputs {
+		errors += in.StartupErrors()
+	}
+	return errors
+}
+
+// startSource starts collecting source, paused or disabled until now
+func (i *inputs) startSource(source *config.IntegrationConfigLogSource) {
+	for _, s := range i.sourcesOf(source) {
+		for _, in := range i.inputs {
+			in.StartSource(s)
+		}
+	}
+}
+
+// stopSource stops collecting source only, its offsets being committed
+func (i *inputs) stopSource(source *config.IntegrationConfigLogSource) {
+	for _, s := range i.sourcesOf(source) {
+		for _, in := range i.inputs {
+			in.StopSource(s.GetID())
+		}
+	}
+}
+
+// sourcesOf returns source along with the sources of the log files of its containers
+func (i *inputs) sourcesOf(source *config.IntegrationConfigLogSource) []*config.IntegrationConfigLogSource {
+	sources := []*config.IntegrationConfigLogSource{source}
+	if i.cri {
+		sources = append(sources, config.CRISources(sources)...)
+	}
+	return sources
+}
+
+// stop stops collecting logs, the offsets of the files being committed
+func (i *inputs) stop() {
+	for _, in := range i.inputs {
+		in.Stop()
+	}
+}
@@ -6,38 +6,117 @@
 package main
 
 import (
+	"log"
+	"strings"
+
 	"github.com/DataDog/datadog-log-agent/pkg/auditor"
 	"github.com/DataDog/datadog-log-agent/pkg/config"
-	"github.com/DataDog/datadog-log-agent/pkg/input/container"
-	"github.com/DataDog/datadog-log-agent/pkg/input/listener"
-	"github.com/DataDog/datadog-log-agent/pkg/input/tailer"
+	"github.com/DataDog/datadog-log-agent/pkg/control"
+	"github.com/DataDog/datadog-log-agent/pkg/deadletter"
+	"github.com/DataDog/datadog-log-agent/pkg/health"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
 	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
 	"github.com/DataDog/datadog-log-agent/pkg/sender"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
 )
 
-// Start starts the forwarder
-func Start() {
+// Start starts the forwarder, reloading the sources of ddconfdPath on SIGHUP
+func Start(ddconfdPath string) {
+	agent := newLogsAgent(ddconfdPath)
+	agent.init()
+	agent.start()
+	agent.ready()
+}
+
+// logsAgent coordinates the lifecycle of the components of the agent: they're
+// all created, then started, the agent being ready once its listeners are bound
+type logsAgent struct {
+	ddconfdPath string
 
-	cm := sender.NewConnectionManager(
-		config.LogsAgent.GetString("log_dd_url"),
-		config.LogsAgent.GetInt("log_dd_port"),
-		config.LogsAgent.GetBool("skip_ssl_validation"),
-	)
+	cm          *sender.ConnectionManager
+	auditorChan chan message.Message
+	auditor     *auditor.Auditor
+	pp          *pipeline.PipelineProvider
+	inputs      *inputs
+}
+
+// newLogsAgent returns an agent collecting the sources of ddconfdPath
+func newLogsAgent(ddconfdPath string) *logsAgent {
+	return &logsAgent{ddconfdPath: ddconfdPath}
+}
 
-	auditorChan := make(chan message.Message, config.ChanSizes)
-	a := auditor.New(auditorChan)
-	a.Start()
+// init creates the components of the agent
+func (la *logsAgent) init() {
+	health.SetPhase(health.Initializing)
+	if path := config.LogsAgent.GetString("log_dead_letter_file"); path != "" {
+		if err := deadletter.Open(path, config.LogsAgent.GetInt64("log_dead_letter_max_size")); err != nil {
+			log.Println("Can't open the dead letter file, dropped messages are lost:", err)
+		}
+	}
+	la.cm = newConnectionManager()
+	la.auditorChan = make(chan message.Message, config.ChanSizes)
+	la.auditor = auditor.New(la.auditorChan)
+	la.pp = pipeline.NewPipelineProvider()
+}
+
+// start starts the components of the agent, from the auditor to the inputs,
+// which returns once the listeners are bound
+func (la *logsAgent) start() {
+	health.SetPhase(health.Starting)
+	la.auditor.Start()
+	la.pp.Start(la.cm, la.auditorChan)
+	la.pp.TrackBuffers(la.auditor)
 
-	pp := pipeline.NewPipelineProvider()
-	pp.Start(cm, auditorChan)
+	disabled := config.DisabledInputs()
+	if len(disabled) > 0 {
+		log.Println("Inputs disabled by", strings.Join(disabled, ", "))
+		status.Set("disabled inputs", disabled)
+	}
+
+	la.inputs = startInputs(config.ActiveSources(config.GetLogsSources()), la.pp, la.auditor)
+	reloader := newReloader(la.ddconfdPath, la.pp, la.auditor, la.inputs)
+	reloader.start()
+	control.SetSourcesManager(reloader)
+	if config.IsRemoteConfigEnabled() {
+		reloader.pollRemoteConfig(config.RemoteConfigPollInterval())
+	}
+}
 
-	l := listener.New(config.GetLogsSources(), pp)
-	l.Start()
+// ready reports the agent is ready to receive logs
+func (la *logsAgent) ready() {
+	if errors := la.inputs.startupErrors(); errors > 0 {
+		log.Println(errors, "sources failed to start")
+	}
+	health.SetPhase(health.Ready)
+	log.Println("logs-agent ready")
+}
+
+// newConnectionManager returns a ConnectionManager to the configured intake,
+// or to the aggregator agent edge agents forward their logs to
+func newConnectionManager() *sender.ConnectionManager {
+	dialer, err := sender.NewDialer(
+		config.LogsAgent.GetString("log_source_ip"),
+		config.LogsAgent.GetString("log_source_interface"),
+		config.LogsAgent.GetString("log_dns_strategy"),
+		config.LogsAgent.GetString("log_dns_resolver"),
+		config.LogsAgent.GetDuration("log_dns_refresh_interval"),
+	)
+	if err != nil {
+		log.Println("Invalid outgoing connection settings, using defaults:", err)
+		dialer, _ = sender.NewDialer("", "", "", "", config.LogsAgent.GetDuration("log_dns_refresh_interval"))
+	}
 
-	s := tailer.New(config.GetLogsSources(), pp, a)
-	s.Start()
+	if config.IsForwardingToAggregator() {
+		log.Println("Forwarding logs to the aggregator agent", config.AggregatorAddress())
+		status.Set("aggregator", config.AggregatorAddress())
+		return sender.NewConnectionManager(
+			config.LogsAgent.GetString("log_aggregator_host"),
+			config.LogsAgent.GetInt("log_aggregator_port"),
+			!config.LogsAgent.GetBool("log_aggregator_use_ssl"),
+			dialer,
+		)
+	}
 
-	c := container.New(config.GetLogsSources(), pp, a)
-	c.Start()
+	endpoint := config.MainEndpoint()
+	return sender.NewConnectionManager(endpoint.Host, endpoint.Port, !endpoint.UseSSL, dialer)
 }
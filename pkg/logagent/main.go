@@ -6,33 +6,75 @@
 package main
 
 import (
+	"expvar"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
+	"runtime"
 
 	"github.com/DataDog/datadog-agent/pkg/pidfile"
 	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/control"
+	"github.com/DataDog/datadog-log-agent/pkg/health"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
 	"github.com/DataDog/datadog-log-agent/pkg/utils"
+	"github.com/DataDog/datadog-log-agent/pkg/version"
 )
 
 var ddconfigPath = flag.String("ddconfig", "", "Path to the datadog.yaml configuration file")
-var ddconfdPath = flag.String("ddconfd", "", "Path to the conf.d directory that contains all integration config files")
+var ddconfdPath = flag.String("ddconfd", "", "Path to the conf.d directory that contains all integration config files, or a list of them separated by colons, later directories overriding earlier ones")
 var pidfilePath = flag.String("pid", "", "Path to set pidfile for process")
 
+func init() {
+	status.Set("version", version.Info())
+	// the effective configuration is served along with the other debug endpoints
+	expvar.Publish("logs_agent_config", expvar.Func(func() interface{} { return config.EffectiveConfig() }))
+	http.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/yaml")
+		fmt.Fprint(w, config.DumpEffectiveConfig())
+	})
+}
+
 // main starts the logs agent
 func main() {
 	flag.Parse()
+	// the flags take precedence over the environment
+	*ddconfigPath = config.FlagOrEnv(*ddconfigPath, config.ConfigPathEnv)
+	*ddconfdPath = config.FlagOrEnv(*ddconfdPath, config.ConfdPathEnv)
 
 	utils.SetupLogger()
 
+	if flag.NArg() > 0 {
+		command, ok := commands[flag.Arg(0)]
+		if !ok {
+			log.Println("Unknown command", flag.Arg(0))
+			os.Exit(2)
+		}
+		os.Exit(command())
+	}
+
 	err := config.BuildLogsAgentConfig(*ddconfigPath, *ddconfdPath)
+	if path := config.LogsAgent.GetString("log_file"); err == nil && path != "" {
+		if err := utils.SetupLogFile(path); err != nil {
+			log.Println("Can't write logs to a file, logging to stdout only:", err)
+		}
+	}
 	if err != nil {
 		log.Println(err)
 		log.Println("Not starting logs-agent")
 	} else if config.LogsAgent.GetBool("log_enabled") {
-		log.Println("Starting logs-agent")
+		log.Println("Starting logs-agent", version.Version)
+		config.SetupGOMAXPROCS()
+		status.Set("cpus", map[string]int{
+			"available":  config.AvailableCPUs(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+			"pipelines":  int(config.PipelinesCount()),
+		})
+		log.Printf("Effective configuration:\n%s", config.DumpEffectiveConfig())
 		if *pidfilePath != "" {
 			err := pidfile.WritePID(*pidfilePath)
 			if err != nil {
@@ -46,7 +88,31 @@ func main() {
 				os.Remove(*pidfilePath)
 			}()
 		}
-		Start()
+		if port := config.LogsAgent.GetInt("log_health_port"); port != 0 {
+			// served before starting, answering 503 until the listeners are bound
+			if err := health.Serve(fmt.Sprintf(":%d", port)); err != nil {
+				log.Println("Can't serve the health endpoint:", err)
+			}
+		}
+		if port := config.LogsAgent.GetInt("log_control_port"); port != 0 {
+			// served on the loopback interface only, the requests changing the
+			// state of the agent carrying the token readable by its user only
+			if runPath := config.LogsAgent.GetString("run_path"); runPath == "" {
+				log.Println("The control API is read only without writable run_path")
+			} else if token, err := control.WriteToken(filepath.Join(runPath, control.TokenFile)); err != nil {
+				log.Println("The control API is read only, can't write its token:", err)
+			} else {
+				control.SetToken(token)
+			}
+			if err := control.Serve(fmt.Sprintf("localhost:%d", port)); err != nil {
+				log.Println("Can't serve the control API:", err)
+			}
+		}
+		Start(*ddconfdPath)
+
+		if config.LogsAgent.GetBool("log_check_for_updates") {
+			version.NewUpdateChecker(version.ReleasesURL, version.Version).Start()
+		}
 
 		if config.LogsAgent.GetBool("log_profiling_enabled") {
 			log.Println("starting logs-agent profiling")
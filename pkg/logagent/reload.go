@@ -0,0 +1,331 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/auditor"
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/control"
+	"github.com/DataDog/datadog-log-agent/pkg/health"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+	"github.com/DataDog/datadog-log-agent/pkg/pipeline"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+// failureCounters are the metrics counting dropped messages and errors,
+// watched while reloaded sources are on probation
+var failureCounters = []string{
+	"logs_sender_dropped_messages",
+	"logs_pipeline_dropped_low_priority_messages",
+	"logs_publisher_dropped_messages",
+	"logs_spool_dropped_messages",
+}
+
+// A reloader applies the sources of conf.d again on SIGHUP. The new sources
+// are on probation: when some of them fail to start, or when drops and errors
+// surge within the probation window, the previous sources are restored
+type reloader struct {
+	ddconfdPath string
+	pp          *pipeline.PipelineProvider
+	auditor     *auditor.Auditor
+	probation   time.Duration
+	maxFailures int64
+
+	mutex  sync.Mutex
+	inputs *inputs
+	// generation counts the changes of the sources, a probation ending
+	// without effect when the sources changed since it started
+	generation uint64
+	// added are the sources added through the control API, kept across reloads
+	added []*config.IntegrationConfigLogSource
+	// the failures since start, to estimate their usual rate
+	started         time.Time
+	initialFailures int64
+}
+
+// newReloader returns a reloader of the sources collected by running inputs
+func newReloader(ddconfdPath string, pp *pipeline.PipelineProvider, a *auditor.Auditor, running *inputs) *reloader {
+	return &reloader{
+		ddconfdPath:     ddconfdPath,
+		pp:              pp,
+		auditor:         a,
+		probation:       config.LogsAgent.GetDuration("log_reload_probation"),
+		maxFailures:     config.LogsAgent.GetInt64("log_reload_max_failures"),
+		inputs:          running,
+		started:         time.Now(),
+		initialFailures: countFailures(),
+	}
+}
+
+// start reloads the sources on SIGHUP
+func (r *reloader) start() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			r.reload()
+		}
+	}()
+}
+
+// pollRemoteConfig fetches the remote config every interval, reloading the sources when it changed
+func (r *reloader) pollRemoteConfig(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			changed, err := config.FetchRemoteConfig()
+			if err != nil {
+				log.Println("Can't fetch the remote config:", err)
+				continue
+			}
+			if changed {
+				log.Println("Remote config changed")
+				r.reload()
+			}
+		}
+	}()
+}
+
+// reload replaces the sources by those of conf.d, rolling back on failure
+func (r *reloader) reload() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	sources, configErrors, err := config.LoadLogsSources(r.ddconfdPath)
+	if err != nil {
+		r.report("rejected: %v", err)
+		return
+	}
+	// the invalid files and sources are skipped, the valid ones being reloaded
+	config.ReportConfigErrors(configErrors)
+	sources = append(sources, r.added...)
+	log.Println("Reloading", len(sources), "sources")
+	previous := config.GetLogsSources()
+	previousErrors := r.inputs.startupErrors()
+	r.apply(sources)
+	// the sources failing to start before, such as the files not created
+	// yet, don't prevent the reload
+	if errors := r.inputs.startupErrors() - previousErrors; errors > 0 {
+		r.rollback(previous, fmt.Sprintf("%d more startup errors", errors))
+		return
+	}
+
+	r.report("on probation until %s", time.Now().Add(r.probation).Format(time.RFC3339))
+	// the sources can be changed through the control API during probation
+	go r.endProbation(r.generation, previous, len(sources), r.usualFailures(r.probation), countFailures())
+}
+
+// endProbation restores previous when the drops and errors counted since before
+// exceed those expected by the end of the probation of the sources applied at
+// generation, unless the sources changed meanwhile
+func (r *reloader) endProbation(generation uint64, previous []*config.IntegrationConfigLogSource, count int, expected, before int64) {
+	time.Sleep(r.probation)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.generation != generation {
+		return
+	}
+	if failures := countFailures() - before; failures > expected+r.maxFailures {
+		r.rollback(previous, fmt.Sprintf("%d drops and errors during probation", failures))
+		return
+	}
+	r.report("applied %d sources at %s", count, time.Now().Format(time.RFC3339))
+}
+
+// AddSource starts collecting source along with the current sources, the
+// previous sources being restored when it fails to start
+func (r *reloader) AddSource(source *config.IntegrationConfigLogSource) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	previous := config.GetLogsSources()
+	for _, s := range previous {
+		if s.GetID() == source.GetID() {
+			return control.ErrSourceExists
+		}
+	}
+	sources := append(append([]*config.IntegrationConfigLogSource{}, previous...), source)
+	previousErrors := r.inputs.startupErrors()
+	r.apply(sources)
+	// the other sources failing to start, such as the files not created yet,
+	// don't prevent the addition
+	if r.inputs.startupErrors() > previousErrors {
+		r.apply(previous)
+		return fmt.Errorf("the source failed to start, see the logs of the agent")
+	}
+	r.added = append(r.added, source)
+	log.Println("Added source", source.GetID())
+	return nil
+}
+
+// RemoveSource stops collecting the source with id added by AddSource
+func (r *reloader) RemoveSource(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var removed *config.IntegrationConfigLogSource
+	added := []*config.IntegrationConfigLogSource{}
+	for _, s := range r.added {
+		if s.GetID() == id {
+			removed = s
+		} else {
+			added = append(added, s)
+		}
+	}
+	if removed == nil {
+		return control.ErrSourceNotFound
+	}
+	sources := []*config.IntegrationConfigLogSource{}
+	for _, s := range config.GetLogsSources() {
+		if s != removed {
+			sources = append(sources, s)
+		}
+	}
+	config.SetSourcePaused(id, false)
+	r.apply(sources)
+	r.added = added
+	log.Println("Removed source", id)
+	return nil
+}
+
+// PauseSource stops collecting the source with id, until ResumeSource. Its
+// offsets are committed, and it stays paused across reloads
+func (r *reloader) PauseSource(id string) error {
+	return r.setPaused(id, true)
+}
+
+// ResumeSource collects the source with id paused by PauseSource again, its
+// files being tailed from their committed offsets
+func (r *reloader) ResumeSource(id string) error {
+	return r.setPaused(id, false)
+}
+
+// DisableSource stops collecting the source with id, until EnableSource. It
+// stays disabled across reloads and restarts, its offsets being committed
+func (r *reloader) DisableSource(id string) error {
+	return r.setDisabled(id, true)
+}
+
+// EnableSource collects the source with id disabled by DisableSource again,
+// its files being tailed from their committed offsets. The sources disabled
+// before being removed from the configuration can be enabled too
+func (r *reloader) EnableSource(id string) error {
+	return r.setDisabled(id, false)
+}
+
+// setPaused pauses or resumes the source with id, stopping or starting its inputs only
+func (r *reloader) setPaused(id string, paused bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	source := sourceWithID(config.GetLogsSources(), id)
+	if source == nil {
+		return control.ErrUnknownSource
+	}
+	if config.IsSourcePaused(id) == paused {
+		return nil
+	}
+	wasActive := config.IsSourceActive(id)
+	config.SetSourcePaused(id, paused)
+	r.toggle(source, wasActive)
+	if paused {
+		log.Println("Paused source", id)
+	} else {
+		log.Println("Resumed source", id)
+	}
+	return nil
+}
+
+// setDisabled disables or enables the source with id, stopping or starting its inputs only
+func (r *reloader) setDisabled(id string, disabled bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	source := sourceWithID(config.GetLogsSources(), id)
+	if source == nil && (disabled || !config.IsSourceDisabled(id)) {
+		return control.ErrUnknownSource
+	}
+	if config.IsSourceDisabled(id) == disabled {
+		return nil
+	}
+	wasActive := config.IsSourceActive(id)
+	if err := config.SetSourceDisabled(id, disabled); err != nil {
+		return err
+	}
+	if source != nil {
+		r.toggle(source, wasActive)
+	}
+	if disabled {
+		log.Println("Disabled source", id)
+	} else {
+		log.Println("Enabled source", id)
+	}
+	return nil
+}
+
+// toggle stops or starts collecting source when it was paused or disabled,
+// or resumed and enabled, the inputs of the other sources being left running
+func (r *reloader) toggle(source *config.IntegrationConfigLogSource, wasActive bool) {
+	switch isActive := config.IsSourceActive(source.GetID()); {
+	case wasActive && !isActive:
+		r.inputs.stopSource(source)
+	case !wasActive && isActive:
+		r.inputs.startSource(source)
+	}
+}
+
+// sourceWithID returns the source of sources with id, nil if none
+func sourceWithID(sources []*config.IntegrationConfigLogSource, id string) *config.IntegrationConfigLogSource {
+	for _, s := range sources {
+		if s.GetID() == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// apply replaces the running inputs by inputs collecting sources, the paused and disabled ones excepted
+func (r *reloader) apply(sources []*config.IntegrationConfigLogSource) {
+	health.SetPhase(health.Starting)
+	r.inputs.stop()
+	config.SetLogsSources(sources)
+	r.inputs = startInputs(config.ActiveSources(sources), r.pp, r.auditor)
+	r.generation++
+	health.SetPhase(health.Ready)
+}
+
+// rollback restores the previous sources
+func (r *reloader) rollback(previous []*config.IntegrationConfigLogSource, reason string) {
+	r.apply(previous)
+	r.report("rolled back at %s: %s", time.Now().Format(time.RFC3339), reason)
+}
+
+// report logs the outcome of a reload and sets it in the status
+func (r *reloader) report(format string, args ...interface{}) {
+	outcome := fmt.Sprintf(format, args...)
+	log.Println("Config reload", outcome)
+	status.Set("config reload", outcome)
+}
+
+// usualFailures returns the number of failures expected in duration at the rate observed so far
+func (r *reloader) usualFailures(duration time.Duration) int64 {
+	elapsed := time.Since(r.started)
+	if elapsed <= 0 {
+		return 0
+	}
+	return (countFailures() - r.initialFailures) * int64(duration) / int64(elapsed)
+}
+
+// countFailures returns the sum of the failure counters
+func countFailures() int64 {
+	var total int64
+	for _, name := range failureCounters {
+		total += metrics.Total(name)
+	}
+	return total
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package message
+
+import (
+	"encoding/json"
+)
+
+// An Envelope is a processed message forwarded by an edge agent to an aggregator
+// agent, with the metadata the aggregator needs to ship it to the intake.
+// Envelopes are sent as json, one per line
+type Envelope struct {
+	Message     string `json:"message"`
+	Hostname    string `json:"hostname"`
+	Service     string `json:"service,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	Timestamp   string `json:"timestamp,omitempty"`
+	TagsPayload string `json:"tags_payload,omitempty"`
+	// Raw is true when the message must be relayed as is, without processing
+	Raw bool `json:"raw,omitempty"`
+	// Logset and APIKey are those of the source of the message, when it
+	// overrides those of the agents
+	Logset string `json:"logset,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// Encode returns the envelope as a line
+func (e *Envelope) Encode() ([]byte, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// DecodeEnvelope returns the envelope encoded in line
+func DecodeEnvelope(line []byte) (*Envelope, error) {
+	e := &Envelope{}
+	err := json.Unmarshal(line, e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Apply sets the content and metadata of the envelope on msg, whose origin must be set
+func (e *Envelope) Apply(msg Message) {
+	msg.SetContent([]byte(e.Message))
+	msg.SetHostname(e.Hostname)
+	if e.Service != "" {
+		msg.SetService(e.Service)
+	}
+	if e.Severity != "" {
+		msg.SetSeverity([]byte(e.Severity))
+	}
+	if e.TagsPayload != "" {
+		msg.SetTagsPayload([]byte(e.TagsPayload))
+	}
+	msg.GetOrigin().Timestamp = e.Timestamp
+	msg.GetOrigin().Raw = e.Raw
+	msg.GetOrigin().Logset = e.Logset
+	msg.GetOrigin().APIKey = e.APIKey
+}
@@ -6,6 +6,8 @@
 package message
 
 import (
+	"time"
+
 	"github.com/DataDog/datadog-log-agent/pkg/config"
 )
 
@@ -20,6 +22,10 @@ type Message interface {
 	SetSeverity([]byte)
 	GetTagsPayload() []byte
 	SetTagsPayload([]byte)
+	GetService() string
+	SetService(string)
+	GetHostname() string
+	SetHostname(string)
 }
 
 // MessageOrigin represents the Origin of a message
@@ -28,6 +34,44 @@ type MessageOrigin struct {
 	LogSource  *config.IntegrationConfigLogSource
 	Offset     int64
 	Timestamp  string
+
+	// position of the message, to trace it back to where it was collected:
+	// the file and the offset of its first byte, or the network connection
+	// and the rank of the message on it, starting at 1
+	Path         string
+	StartOffset  int64
+	ConnectionID string
+	Sequence     uint64
+
+	// SourceSequence is the rank of the message in its source, starting at 1
+	// and continued across restarts, or 0 when the source isn't numbered
+	SourceSequence uint64
+
+	// ReceivedAt is when the message entered the pipeline, or the zero time
+	// when unknown. It bounds how long the senders hold the message
+	ReceivedAt time.Time
+
+	// Raw is true when the message is relayed as is, the edge agent that
+	// collected it having skipped its processing
+	Raw bool
+
+	// Logset and APIKey override those of the log source when set, for the
+	// messages relayed by an aggregator agent for the sources of edge agents
+	Logset string
+	APIKey string
+
+	// Ack is called once the message is sent, or given up on, for the
+	// inputs acknowledging messages to their source; nil for the others
+	Ack func(sent bool)
+}
+
+// Acknowledge notifies the input of the message that it was sent,
+// or given up on when sent is false; only the first call counts
+func (o *MessageOrigin) Acknowledge(sent bool) {
+	if o != nil && o.Ack != nil {
+		o.Ack(sent)
+		o.Ack = nil
+	}
 }
 
 type message struct {
@@ -35,6 +79,8 @@ type message struct {
 	Origin      *MessageOrigin
 	severity    []byte
 	tagsPayload []byte
+	service     string
+	hostname    string
 }
 
 // Content returns the content the message, the actual log line
@@ -93,6 +139,35 @@ func (m *message) SetTagsPayload(tagsPayload []byte) {
 	m.tagsPayload = tagsPayload
 }
 
+// GetService returns the service of the message
+// It will default on the LogSource service, but can
+// be overriden in the message itself with service
+func (m *message) GetService() string {
+	if m.service != "" {
+		return m.service
+	}
+	if m.Origin != nil && m.Origin.LogSource != nil {
+		return m.Origin.LogSource.Service
+	}
+	return ""
+}
+
+// SetService sets the service of the message
+func (m *message) SetService(service string) {
+	m.service = service
+}
+
+// GetHostname returns the host the message was collected on,
+// or "" if it was collected by this agent
+func (m *message) GetHostname() string {
+	return m.hostname
+}
+
+// SetHostname sets the host the message was collected on
+func (m *message) SetHostname(hostname string) {
+	m.hostname = hostname
+}
+
 // NewMessage returns a new message
 func NewMessage(content []byte) *message {
 	return &message{
@@ -102,7 +177,7 @@ func NewMessage(content []byte) *message {
 
 // NewFileOrigin returns a new MessageOrigin
 func NewOrigin() *MessageOrigin {
-	return &MessageOrigin{}
+	return &MessageOrigin{ReceivedAt: time.Now()}
 }
 
 // StopMessage is used to let a component stop gracefully
@@ -116,6 +191,27 @@ func NewStopMessage() *StopMessage {
 	}
 }
 
+// EndOfStreamMessage follows the last message of a finite source, such as a rotated
+// file read to its end, so that the outputs flush and the auditor marks it complete
+type EndOfStreamMessage struct {
+	*message
+}
+
+// NewEndOfStreamMessage returns the end of stream message of the source of origin
+func NewEndOfStreamMessage(origin *MessageOrigin) *EndOfStreamMessage {
+	msg := &EndOfStreamMessage{
+		message: NewMessage(nil),
+	}
+	msg.SetOrigin(origin)
+	return msg
+}
+
+// IsEndOfStream returns true if msg marks the end of its source
+func IsEndOfStream(msg Message) bool {
+	_, ok := msg.(*EndOfStreamMessage)
+	return ok
+}
+
 // FileMessage is a message coming from a File
 type FileMessage struct {
 	*message
@@ -138,6 +234,17 @@ func NewNetworkMessage(content []byte) *NetworkMessage {
 	}
 }
 
+// CommandMessage is a message written by a process supervised by the agent
+type CommandMessage struct {
+	*message
+}
+
+func NewCommandMessage(content []byte) *CommandMessage {
+	return &CommandMessage{
+		message: NewMessage(content),
+	}
+}
+
 // ContainerMessage is a message coming from a container Source
 type ContainerMessage struct {
 	*message
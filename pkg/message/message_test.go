@@ -32,4 +32,44 @@ func TestMessage(t *testing.T) {
 	message.SetTagsPayload([]byte("messageTags"))
 	assert.Equal(t, "messageTags", string(message.GetTagsPayload()))
 
+	o.LogSource.Service = "sourceService"
+	assert.Equal(t, "sourceService", message.GetService())
+
+	message.SetService("messageService")
+	assert.Equal(t, "messageService", message.GetService())
+}
+
+func TestEnvelope(t *testing.T) {
+	envelope := &Envelope{
+		Message:     `hello "world"`,
+		Hostname:    "edge-host",
+		Service:     "app",
+		Severity:    "<43>",
+		Timestamp:   "2017-10-16T10:00:00Z",
+		TagsPayload: "[dd ddtags=\"env:prod\"]",
+		Logset:      "team",
+	}
+	line, err := envelope.Encode()
+	assert.Nil(t, err)
+	assert.Equal(t, byte('\n'), line[len(line)-1])
+
+	decoded, err := DecodeEnvelope(line[:len(line)-1])
+	assert.Nil(t, err)
+	assert.Equal(t, envelope, decoded)
+
+	msg := NewNetworkMessage([]byte(line))
+	origin := NewOrigin()
+	origin.LogSource = &config.IntegrationConfigLogSource{Service: "aggregator", TagsPayload: []byte("-")}
+	msg.SetOrigin(origin)
+	decoded.Apply(msg)
+	assert.Equal(t, `hello "world"`, string(msg.Content()))
+	assert.Equal(t, "edge-host", msg.GetHostname())
+	assert.Equal(t, "team", msg.GetOrigin().Logset)
+	assert.Equal(t, "app", msg.GetService())
+	assert.Equal(t, "<43>", string(msg.GetSeverity()))
+	assert.Equal(t, "2017-10-16T10:00:00Z", msg.GetTimestamp())
+	assert.Equal(t, "[dd ddtags=\"env:prod\"]", string(msg.GetTagsPayload()))
+
+	_, err = DecodeEnvelope([]byte("not an envelope"))
+	assert.NotNil(t, err)
 }
@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package message
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+)
+
+// ParseTimestamp returns the timestamp of a raw message: the one provided
+// by the input, or the one of a syslog formatted log (RFC5424 or RFC3164)
+func ParseTimestamp(msg Message) (time.Time, bool) {
+	if msg.GetTimestamp() != "" {
+		ts, err := time.Parse(config.DateFormat, msg.GetTimestamp())
+		return ts, err == nil
+	}
+	content := msg.Content()
+	if len(content) == 0 || content[0] != '<' {
+		return time.Time{}, false
+	}
+	end := bytes.IndexByte(content, '>')
+	if end < 0 {
+		return time.Time{}, false
+	}
+	header := content[end+1:]
+
+	// RFC5424: <%pri%>%protocol-version% %timestamp% ...
+	fields := bytes.SplitN(header, []byte{' '}, 3)
+	if len(fields) == 3 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(fields[1])); err == nil {
+			return ts, true
+		}
+	}
+
+	// RFC3164: <%pri%>%Mmm dd hh:mm:ss% ..., without year nor timezone
+	if len(header) >= len(time.Stamp) {
+		ts, err := time.ParseInLocation(time.Stamp, string(header[:len(time.Stamp)]), time.Local)
+		if err == nil {
+			now := time.Now()
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.AddDate(0, 1, 0)) {
+				// logs from december received in january
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
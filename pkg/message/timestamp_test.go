@@ -0,0 +1,38 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package message
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	var ts time.Time
+	var ok bool
+
+	msg := NewContainerMessage([]byte("hello"))
+	origin := NewOrigin()
+	origin.Timestamp = "2017-10-01T12:00:00.000000000Z"
+	msg.SetOrigin(origin)
+	ts, ok = ParseTimestamp(msg)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC), ts)
+
+	ts, ok = ParseTimestamp(NewNetworkMessage([]byte("<46>1 2017-10-01T12:00:00.5Z host app - - - hello")))
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2017, 10, 1, 12, 0, 0, 500000000, time.UTC), ts)
+
+	ts, ok = ParseTimestamp(NewNetworkMessage([]byte("<34>Oct  1 12:00:00 host app: hello")))
+	assert.True(t, ok)
+	assert.Equal(t, time.October, ts.Month())
+	assert.Equal(t, 12, ts.Hour())
+
+	_, ok = ParseTimestamp(NewNetworkMessage([]byte("hello world")))
+	assert.False(t, ok)
+}
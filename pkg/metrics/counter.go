@@ -0,0 +1,134 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package metrics
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// A Counter is a monotonic count, such as the number of messages dropped.
+// It is published on the debug endpoint as a number
+type Counter struct {
+	value int64
+}
+
+// NewCounter returns a Counter registered under name
+func NewCounter(name string) *Counter {
+	c := &Counter{}
+	register(name, c)
+	return c
+}
+
+// Add increments the counter by delta
+func (c *Counter) Add(delta int64) {
+	atomic.AddInt64(&c.value, delta)
+}
+
+// Value returns the current count
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Snapshot returns the current count
+func (c *Counter) Snapshot() interface{} {
+	return c.Value()
+}
+
+// String returns the count, to implement expvar.Var
+func (c *Counter) String() string {
+	return strconv.FormatInt(c.Value(), 10)
+}
+
+// A LabeledCounter counts by label, such as the identifier of a source or the
+// reason a message was dropped. It is published on the debug endpoint as
+// {"<label>": 3}
+type LabeledCounter struct {
+	mutex    sync.RWMutex
+	counters map[string]*Counter
+}
+
+// NewLabeledCounter returns a LabeledCounter registered under name
+func NewLabeledCounter(name string) *LabeledCounter {
+	c := newLabeledCounter()
+	register(name, c)
+	return c
+}
+
+func newLabeledCounter() *LabeledCounter {
+	return &LabeledCounter{
+		counters: make(map[string]*Counter),
+	}
+}
+
+// Add increments the count of label by delta
+func (c *LabeledCounter) Add(label string, delta int64) {
+	c.counter(label).Add(delta)
+}
+
+// counter returns the counter of label, created on its first use
+func (c *LabeledCounter) counter(label string) *Counter {
+	c.mutex.RLock()
+	counter, exists := c.counters[label]
+	c.mutex.RUnlock()
+	if exists {
+		return counter
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if counter, exists = c.counters[label]; !exists {
+		counter = &Counter{}
+		c.counters[label] = counter
+	}
+	return counter
+}
+
+// Value returns the count of label
+func (c *LabeledCounter) Value(label string) int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if counter, exists := c.counters[label]; exists {
+		return counter.Value()
+	}
+	return 0
+}
+
+// Total returns the sum of the counts of all the labels
+func (c *LabeledCounter) Total() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var total int64
+	for _, counter := range c.counters {
+		total += counter.Value()
+	}
+	return total
+}
+
+// Snapshot returns the counts by label
+func (c *LabeledCounter) Snapshot() interface{} {
+	return c.values()
+}
+
+func (c *LabeledCounter) values() map[string]int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	values := make(map[string]int64, len(c.counters))
+	for label, counter := range c.counters {
+		values[label] = counter.Value()
+	}
+	return values
+}
+
+// String returns the counts by label as json, to implement expvar.Var
+func (c *LabeledCounter) String() string {
+	b, err := json.Marshal(c.values())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
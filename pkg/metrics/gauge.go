@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package metrics
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// A Gauge is a value going up and down, such as the number of files tailed.
+// It is published on the debug endpoint as a number
+type Gauge struct {
+	value int64
+}
+
+// NewGauge returns a Gauge registered under name
+func NewGauge(name string) *Gauge {
+	g := &Gauge{}
+	register(name, g)
+	return g
+}
+
+// Set sets the value of the gauge
+func (g *Gauge) Set(value int64) {
+	atomic.StoreInt64(&g.value, value)
+}
+
+// Add adds delta, which can be negative, to the value of the gauge
+func (g *Gauge) Add(delta int64) {
+	atomic.AddInt64(&g.value, delta)
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// Snapshot returns the current value of the gauge
+func (g *Gauge) Snapshot() interface{} {
+	return g.Value()
+}
+
+// String returns the value of the gauge, to implement expvar.Var
+func (g *Gauge) String() string {
+	return strconv.FormatInt(g.Value(), 10)
+}
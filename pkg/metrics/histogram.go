@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package metrics
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Bounds of the histograms of message sizes, in bytes, and of latencies, in seconds
+var (
+	SizeBuckets    = []float64{64, 256, 1024, 4096, 16384, 65536, 262144}
+	LatencyBuckets = []float64{0.00001, 0.0001, 0.001, 0.01, 0.1, 1, 10}
+)
+
+// A Histogram counts observations in buckets of fixed upper bounds.
+// It is published on the debug endpoint as
+// {"count": 3, "sum": 12, "buckets": {"64": 2, "256": 1, "+Inf": 0}}
+// where each bucket counts the observations lower than or equal to its bound,
+// and greater than the bound of the previous bucket
+type Histogram struct {
+	mutex   sync.Mutex
+	bounds  []float64
+	buckets []int64
+	count   int64
+	sum     float64
+}
+
+// NewHistogram returns a Histogram of increasing bounds registered under name
+func NewHistogram(name string, bounds []float64) *Histogram {
+	h := newHistogram(bounds)
+	register(name, h)
+	return h
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		bounds:  bounds,
+		buckets: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe records value
+func (h *Histogram) Observe(value float64) {
+	i := 0
+	for i < len(h.bounds) && value > h.bounds[i] {
+		i++
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.buckets[i]++
+	h.count++
+	h.sum += value
+}
+
+// ObserveSince records the time elapsed since start, in seconds
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// HistogramSnapshot is the state of a Histogram
+type HistogramSnapshot struct {
+	Count   int64            `json:"count"`
+	Sum     float64          `json:"sum"`
+	Buckets map[string]int64 `json:"buckets"`
+}
+
+// Snapshot returns the current state of h, a HistogramSnapshot
+func (h *Histogram) Snapshot() interface{} {
+	return h.snapshot()
+}
+
+func (h *Histogram) snapshot() HistogramSnapshot {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	buckets := make(map[string]int64, len(h.buckets))
+	for i, bound := range h.bounds {
+		buckets[strconv.FormatFloat(bound, 'g', -1, 64)] = h.buckets[i]
+	}
+	buckets["+Inf"] = h.buckets[len(h.bounds)]
+	return HistogramSnapshot{Count: h.count, Sum: h.sum, Buckets: buckets}
+}
+
+// String returns h as json, to implement expvar.Var
+func (h *Histogram) String() string {
+	b, err := json.Marshal(h.snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
@@ -0,0 +1,35 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{10, 100})
+	for _, value := range []float64{1, 10, 11, 100, 1000} {
+		h.Observe(value)
+	}
+	snapshot := h.snapshot()
+	assert.Equal(t, int64(5), snapshot.Count)
+	assert.Equal(t, float64(1122), snapshot.Sum)
+	assert.Equal(t, map[string]int64{"10": 2, "100": 2, "+Inf": 1}, snapshot.Buckets)
+
+	var decoded HistogramSnapshot
+	assert.Nil(t, json.Unmarshal([]byte(h.String()), &decoded))
+	assert.Equal(t, snapshot, decoded)
+}
+
+func TestHistogramBucketNames(t *testing.T) {
+	snapshot := newHistogram(LatencyBuckets).snapshot()
+	assert.Contains(t, snapshot.Buckets, "1e-05")
+	assert.Contains(t, snapshot.Buckets, "0.001")
+	assert.Contains(t, snapshot.Buckets, "10")
+}
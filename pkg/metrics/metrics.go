@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+// A Metric is the state of a component of the agent, such as a counter of
+// dropped messages, safe for concurrent use
+type Metric interface {
+	expvar.Var
+	// Snapshot returns the current value of the metric
+	Snapshot() interface{}
+}
+
+// A Registry holds metrics by name
+type Registry struct {
+	mutex   sync.Mutex
+	metrics map[string]Metric
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]Metric),
+	}
+}
+
+// Register adds metric to the registry under name, which must be unique
+func (r *Registry) Register(name string, metric Metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, exists := r.metrics[name]; exists {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.metrics[name] = metric
+}
+
+// Get returns the metric registered under name, nil if none
+func (r *Registry) Get(name string) Metric {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.metrics[name]
+}
+
+// Snapshot returns the current value of all the metrics, by name
+func (r *Registry) Snapshot() map[string]interface{} {
+	r.mutex.Lock()
+	m := make(map[string]Metric, len(r.metrics))
+	for name, metric := range r.metrics {
+		m[name] = metric
+	}
+	r.mutex.Unlock()
+
+	s := make(map[string]interface{}, len(m))
+	for name, metric := range m {
+		s[name] = metric.Snapshot()
+	}
+	return s
+}
+
+// registry holds the metrics of the agent, published on the debug endpoint
+// and in the "metrics" entry of the status
+var registry = NewRegistry()
+
+func init() {
+	status.Register("metrics", func() interface{} { return registry.Snapshot() })
+}
+
+// register adds metric to the metrics of the agent and publishes it on the debug endpoint
+func register(name string, metric Metric) {
+	registry.Register(name, metric)
+	expvar.Publish(name, metric)
+}
+
+// Get returns the metric of the agent registered under name, nil if none
+func Get(name string) Metric {
+	return registry.Get(name)
+}
+
+// Total returns the value of the counter registered under name, summed over
+// its labels for a labeled counter, 0 for the other metrics
+func Total(name string) int64 {
+	switch m := Get(name).(type) {
+	case *Counter:
+		return m.Value()
+	case *LabeledCounter:
+		return m.Total()
+	}
+	return 0
+}
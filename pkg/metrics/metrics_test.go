@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package metrics
+
+import (
+	"expvar"
+	"sync"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabeledCounter(t *testing.T) {
+	c := newLabeledCounter()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add("file:/var/log/app.log", 1)
+				c.Add("tcp:10514", 2)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1000), c.Value("file:/var/log/app.log"))
+	assert.Equal(t, int64(2000), c.Value("tcp:10514"))
+	assert.Equal(t, int64(0), c.Value("udp:10514"))
+	assert.Equal(t, int64(3000), c.Total())
+	assert.Equal(t, `{"file:/var/log/app.log":1000,"tcp:10514":2000}`, c.String())
+}
+
+func TestGauge(t *testing.T) {
+	g := &Gauge{}
+	g.Set(5)
+	g.Add(-2)
+	assert.Equal(t, int64(3), g.Value())
+	assert.Equal(t, "3", g.String())
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := &Counter{}
+	r.Register("dropped", c)
+	c.Add(2)
+	assert.Equal(t, c, r.Get("dropped"))
+	assert.Nil(t, r.Get("unknown"))
+	assert.Equal(t, map[string]interface{}{"dropped": int64(2)}, r.Snapshot())
+	assert.Panics(t, func() { r.Register("dropped", &Counter{}) })
+}
+
+func TestMetricsArePublished(t *testing.T) {
+	c := NewLabeledCounter("logs_test_messages")
+	c.Add("tcp:10514", 3)
+	NewCounter("logs_test_errors").Add(1)
+
+	assert.Equal(t, `{"tcp:10514":3}`, expvar.Get("logs_test_messages").String())
+	assert.Equal(t, int64(3), Total("logs_test_messages"))
+	assert.Equal(t, int64(1), Total("logs_test_errors"))
+	assert.Equal(t, int64(0), Total("logs_test_unknown"))
+
+	snapshot := status.Get()["metrics"].(map[string]interface{})
+	assert.Equal(t, map[string]int64{"tcp:10514": 3}, snapshot["logs_test_messages"])
+}
@@ -0,0 +1,115 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package pipeline
+
+import (
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+// droppedLowPriorityMessages counts the messages of low priority sources dropped under backpressure
+var droppedLowPriorityMessages = metrics.NewCounter("logs_pipeline_dropped_low_priority_messages")
+
+// A prioritizer buffers the messages of a pipeline before they are processed,
+// forwarding the messages of high priority sources first and those of low priority
+// sources last. When the buffer is full, the oldest messages of low priority sources
+// are dropped to make room, the other messages being held back
+type prioritizer struct {
+	inputChan  chan message.Message
+	outputChan chan message.Message
+	capacity   int
+
+	queues map[config.Priority][]message.Message
+	size   int
+}
+
+// newPrioritizer returns a prioritizer buffering up to capacity messages
+func newPrioritizer(inputChan, outputChan chan message.Message, capacity int) *prioritizer {
+	return &prioritizer{
+		inputChan:  inputChan,
+		outputChan: outputChan,
+		capacity:   capacity,
+		queues:     make(map[config.Priority][]message.Message),
+	}
+}
+
+// Start starts the prioritizer
+func (p *prioritizer) Start() {
+	go p.run()
+}
+
+// run forwards the messages of inputChan by priority
+func (p *prioritizer) run() {
+	for {
+		inputChan := p.inputChan
+		if p.size >= p.capacity && len(p.queues[config.PriorityLow]) == 0 {
+			// nothing can be dropped, hold back the inputs
+			inputChan = nil
+		}
+		var outputChan chan message.Message
+		var next message.Message
+		if p.size > 0 {
+			outputChan = p.outputChan
+			next = p.peek()
+		}
+		select {
+		case msg := <-inputChan:
+			p.push(msg)
+		case outputChan <- next:
+			p.pop()
+		}
+	}
+}
+
+// push buffers msg, dropping the oldest low priority message when full
+func (p *prioritizer) push(msg message.Message) {
+	if p.size >= p.capacity {
+		p.queues[config.PriorityLow][0].GetOrigin().Acknowledge(false)
+		p.dequeue(config.PriorityLow)
+		droppedLowPriorityMessages.Add(1)
+	}
+	priority := priorityOf(msg)
+	p.queues[priority] = append(p.queues[priority], msg)
+	p.size++
+}
+
+// peek returns the next message to forward
+func (p *prioritizer) peek() message.Message {
+	for _, priority := range []config.Priority{config.PriorityHigh, config.PriorityNormal, config.PriorityLow} {
+		if queue := p.queues[priority]; len(queue) > 0 {
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// pop removes the next message to forward
+func (p *prioritizer) pop() {
+	for _, priority := range []config.Priority{config.PriorityHigh, config.PriorityNormal, config.PriorityLow} {
+		if len(p.queues[priority]) > 0 {
+			p.dequeue(priority)
+			return
+		}
+	}
+}
+
+// dequeue removes the oldest message of priority
+func (p *prioritizer) dequeue(priority config.Priority) {
+	queue := p.queues[priority]
+	queue[0] = nil
+	p.queues[priority] = queue[1:]
+	p.size--
+}
+
+// priorityOf returns the priority of the source of msg
+func priorityOf(msg message.Message) config.Priority {
+	origin := msg.GetOrigin()
+	if origin == nil || origin.LogSource == nil {
+		return config.PriorityNormal
+	}
+	return origin.LogSource.PriorityClass()
+}
@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPriorityMessage(content, priority string) message.Message {
+	msg := message.NewFileMessage([]byte(content))
+	origin := message.NewOrigin()
+	origin.LogSource = &config.IntegrationConfigLogSource{Priority: priority}
+	msg.SetOrigin(origin)
+	return msg
+}
+
+// drain returns the content of the buffered messages, in the order they are forwarded
+func drain(p *prioritizer) []string {
+	contents := []string{}
+	for p.size > 0 {
+		contents = append(contents, string(p.peek().Content()))
+		p.pop()
+	}
+	return contents
+}
+
+func TestPrioritizerForwardsHighPriorityFirst(t *testing.T) {
+	p := newPrioritizer(nil, nil, 10)
+	p.push(newPriorityMessage("debug", "low"))
+	p.push(newPriorityMessage("app", ""))
+	p.push(newPriorityMessage("audit", "high"))
+	p.push(newPriorityMessage("app2", "normal"))
+	assert.Equal(t, []string{"audit", "app", "app2", "debug"}, drain(p))
+}
+
+func TestPrioritizerDropsLowPriorityFirst(t *testing.T) {
+	dropped := droppedLowPriorityMessages.Value()
+	p := newPrioritizer(nil, nil, 3)
+	p.push(newPriorityMessage("debug1", "low"))
+	p.push(newPriorityMessage("debug2", "low"))
+	p.push(newPriorityMessage("app", ""))
+	p.push(newPriorityMessage("audit", "high"))
+	p.push(newPriorityMessage("debug3", "low"))
+	assert.Equal(t, dropped+2, droppedLowPriorityMessages.Value())
+	assert.Equal(t, []string{"audit", "app", "debug3"}, drain(p))
+}
+
+func TestPrioritizerHoldsBackInputsWhenNothingCanBeDropped(t *testing.T) {
+	inputChan := make(chan message.Message)
+	outputChan := make(chan message.Message)
+	newPrioritizer(inputChan, outputChan, 1).Start()
+
+	inputChan <- newPriorityMessage("app", "")
+	select {
+	case inputChan <- newPriorityMessage("audit", "high"):
+		assert.Fail(t, "the prioritizer should be full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, "app", string((<-outputChan).Content()))
+}
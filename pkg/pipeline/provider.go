@@ -6,18 +6,32 @@
 package pipeline
 
 import (
+	"log"
+	"net"
+	"net/url"
+	"path/filepath"
 	"sync/atomic"
 
+	"github.com/DataDog/datadog-log-agent/pkg/auditor"
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
 	"github.com/DataDog/datadog-log-agent/pkg/config"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
 	"github.com/DataDog/datadog-log-agent/pkg/processor"
+	"github.com/DataDog/datadog-log-agent/pkg/publisher"
 	"github.com/DataDog/datadog-log-agent/pkg/sender"
+	"github.com/DataDog/datadog-log-agent/pkg/spool"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
 )
 
 type PipelineProvider struct {
 	numberOfPipelines int32
 	chanSizes         int
 	pipelinesChans    [](chan message.Message)
+	spool             *spool.Spool
+	// codec compresses the payloads sent over http and spooled, nil when they aren't
+	codec compression.Codec
+	// endpoints are the intakes of the logsets not sent to the main endpoint
+	endpoints map[string]config.Endpoint
 
 	currentChanIdx int32
 }
@@ -25,7 +39,7 @@ type PipelineProvider struct {
 // NewPipelineProvider returns a new PipelineProvider
 func NewPipelineProvider() *PipelineProvider {
 	return &PipelineProvider{
-		numberOfPipelines: config.NumberOfPipelines,
+		numberOfPipelines: config.PipelinesCount(),
 		chanSizes:         config.ChanSizes,
 		pipelinesChans:    [](chan message.Message){},
 		currentChanIdx:    0,
@@ -35,11 +49,30 @@ func NewPipelineProvider() *PipelineProvider {
 // Start initializes the pipelines
 func (pp *PipelineProvider) Start(cm *sender.ConnectionManager, auditorChan chan message.Message) {
 
+	codec, err := config.CompressionCodec()
+	if err != nil {
+		log.Println("Sending logs uncompressed:", err)
+	}
+	pp.codec = codec
+	pp.endpoints = config.LogsetEndpoints()
+
+	var spoolChan chan message.Message
+	offline := config.LogsAgent.GetBool("log_offline_mode")
+	if offline || config.LogsAgent.GetInt64("max_upload_bytes_per_second") > 0 {
+		// when the upload bandwidth is capped, the spool buffers the excess of logs on disk
+		spoolChan = pp.startSpool(cm, auditorChan, offline)
+	}
+
+	clockSkew := newClockSkewDetector()
+	publishers := newPublishers()
+
 	for i := int32(0); i < pp.numberOfPipelines; i++ {
 
-		senderChan := make(chan message.Message, pp.chanSizes)
-		f := sender.New(senderChan, auditorChan, cm)
-		f.Start()
+		senderChan := spoolChan
+		if senderChan == nil {
+			senderChan = make(chan message.Message, pp.chanSizes)
+			pp.startSender(senderChan, auditorChan, cm)
+		}
 
 		processorChan := make(chan message.Message, pp.chanSizes)
 		p := processor.New(
@@ -47,11 +80,187 @@ func (pp *PipelineProvider) Start(cm *sender.ConnectionManager, auditorChan chan
 			senderChan,
 			config.LogsAgent.GetString("api_key"),
 			config.LogsAgent.GetString("logset"),
+			clockSkew,
 		)
+		p.PublishTo(publishers)
 		p.Start()
 
-		pp.pipelinesChans = append(pp.pipelinesChans, processorChan)
+		// the inputs write to the prioritizer, which feeds the processor
+		inputChan := make(chan message.Message, pp.chanSizes)
+		newPrioritizer(inputChan, processorChan, pp.chanSizes).Start()
+
+		pp.pipelinesChans = append(pp.pipelinesChans, inputChan)
+	}
+}
+
+// TrackBuffers adds the indexes of the disk buffers of the pipelines to the state snapshotted by a
+func (pp *PipelineProvider) TrackBuffers(a *auditor.Auditor) {
+	if pp.spool == nil {
+		return
+	}
+	s := pp.spool
+	a.TrackBuffer("spool", func() auditor.BufferState {
+		committed, read, write := s.Indexes()
+		return auditor.BufferState{Committed: committed.Encode(), Read: read.Encode(), Write: write.Encode()}
+	})
+}
+
+// newClockSkewDetector returns the clock skew detector shared by all the processors,
+// or nil if clock skew detection is disabled
+func newClockSkewDetector() *processor.ClockSkewDetector {
+	action := config.LogsAgent.GetString("log_clock_skew_action")
+	if action == "" {
+		return nil
+	}
+	d, err := processor.NewClockSkewDetector(config.LogsAgent.GetDuration("log_clock_skew_threshold"), action)
+	if err != nil {
+		log.Println("Clock skew detection disabled:", err)
+		return nil
+	}
+	status.Register("clock skew", d.Status)
+	return d
+}
+
+// newPublishers returns the started publishers shared by all the processors
+func newPublishers() []*publisher.Publisher {
+	publishers := []*publisher.Publisher{}
+	if address := config.LogsAgent.GetString("log_redis_address"); address != "" {
+		client := publisher.NewRedisStream(address, config.LogsAgent.GetString("log_redis_stream"), config.LogsAgent.GetInt64("log_redis_stream_max_len"))
+		publishers = append(publishers, publisher.New("redis "+address, client))
+	}
+	if address := config.LogsAgent.GetString("log_nats_address"); address != "" {
+		client := publisher.NewNATSSubject(address, config.LogsAgent.GetString("log_nats_subject"))
+		publishers = append(publishers, publisher.New("nats "+address, client))
+	}
+	for _, p := range publishers {
+		p.Start()
+	}
+	return publishers
+}
+
+// startSender starts the senders forwarding the messages of inputChan to the
+// intakes, those of the logsets with an endpoint of their own being routed to it
+func (pp *PipelineProvider) startSender(inputChan, outputChan chan message.Message, cm *sender.ConnectionManager) {
+	// the aggregator agents route the logs themselves
+	if len(pp.endpoints) == 0 || config.IsForwardingToAggregator() {
+		pp.startEndpointSender(inputChan, outputChan, cm, config.MainEndpoint().HTTPURL)
+		return
+	}
+	defaultChan := make(chan message.Message, pp.chanSizes)
+	pp.startEndpointSender(defaultChan, outputChan, cm, config.MainEndpoint().HTTPURL)
+	routes := make(map[string]chan message.Message, len(pp.endpoints))
+	for logset, e := range pp.endpoints {
+		routeChan := make(chan message.Message, pp.chanSizes)
+		routeCm := sender.NewConnectionManager(e.Host, e.Port, !e.UseSSL, cm.Dialer())
+		pp.startEndpointSender(routeChan, outputChan, routeCm, e.HTTPURL)
+		routes[logset] = routeChan
+	}
+	sender.NewRouter(inputChan, defaultChan, routes, config.LogsAgent.GetString("logset")).Start()
+}
+
+// startEndpointSender starts a sender forwarding the messages of inputChan to
+// the intake of cm, or posting them to httpURL when using http
+func (pp *PipelineProvider) startEndpointSender(inputChan, outputChan chan message.Message, cm *sender.ConnectionManager, httpURL string) {
+	// aggregator agents are reached over tcp
+	if config.LogsAgent.GetBool("log_use_http") && !config.IsForwardingToAggregator() {
+		f := sender.NewHTTPSender(
+			inputChan,
+			outputChan,
+			httpURL,
+			config.LogsAgent.GetInt("log_send_max_retries"),
+			cm.Dialer(),
+		)
+		f.Compress(pp.codec)
+		f.SetLatencyBudget(config.LogsAgent.GetDuration("log_latency_budget"))
+		f.Start()
+	} else {
+		f := sender.New(inputChan, outputChan, cm)
+		f.Start()
+	}
+}
+
+// startSpool starts spooling all the processed messages to disk, and uploading
+// them within the bandwidth cap. In offline mode, logs are uploaded only when
+// the intake is reachable. It returns the channel the processors
+// should write to, or nil if the spool can't be opened
+func (pp *PipelineProvider) startSpool(cm *sender.ConnectionManager, auditorChan chan message.Message, offline bool) chan message.Message {
+	path := config.LogsAgent.GetString("log_spool_path")
+	if path == "" {
+		if config.IsStateless() {
+			log.Println("Can't spool without writable run_path, sending logs directly without bandwidth cap")
+			return nil
+		}
+		path = filepath.Join(config.LogsAgent.GetString("run_path"), "spool")
+	}
+	s, err := spool.New(path, config.LogsAgent.GetInt64("log_spool_max_size"))
+	if err != nil {
+		log.Println("Can't open spool, sending logs directly without bandwidth cap:", err)
+		return nil
+	}
+	key, err := config.SpoolEncryptionKey()
+	if err == nil && key != nil {
+		err = s.Encrypt(key)
+	}
+	if err != nil {
+		// logs must not be written on disk in clear text
+		s.Close()
+		log.Println("Can't encrypt spool, sending logs directly without bandwidth cap:", err)
+		return nil
+	}
+	s.Compress(pp.codec)
+	status.Register("spool size", func() interface{} { return s.Size() })
+	pp.spool = s
+
+	spoolChan := make(chan message.Message, pp.chanSizes)
+	spool.NewWriter(s, spoolChan, auditorChan).Start()
+
+	uploadChan := make(chan message.Message, pp.chanSizes)
+	committedChan := make(chan message.Message, pp.chanSizes)
+	pp.startSender(uploadChan, committedChan, cm)
+
+	var isReachable func() bool
+	if offline {
+		address := intakeAddress()
+		isReachable = func() bool { return cm.Dialer().IsReachable(address) }
+	}
+	spool.NewUploader(s, uploadChan, committedChan, isReachable, uploadBandwidth(offline)).Start()
+
+	return spoolChan
+}
+
+// uploadBandwidth returns the maximum number of bytes per second to upload, 0 meaning no cap.
+// In offline mode, the spool specific cap applies on top of the global one
+func uploadBandwidth(offline bool) int64 {
+	bandwidth := config.LogsAgent.GetInt64("max_upload_bytes_per_second")
+	if !offline {
+		return bandwidth
+	}
+	spoolBandwidth := config.LogsAgent.GetInt64("log_spool_max_upload_bytes_per_second")
+	if spoolBandwidth > 0 && (bandwidth <= 0 || spoolBandwidth < bandwidth) {
+		return spoolBandwidth
+	}
+	return bandwidth
+}
+
+// intakeAddress returns the host:port of the intake logs are sent to
+func intakeAddress() string {
+	if config.IsForwardingToAggregator() {
+		return config.AggregatorAddress()
+	}
+	if config.LogsAgent.GetBool("log_use_http") {
+		u, err := url.Parse(config.MainEndpoint().HTTPURL)
+		if err == nil {
+			port := u.Port()
+			if port == "" {
+				port = "443"
+				if u.Scheme == "http" {
+					port = "80"
+				}
+			}
+			return net.JoinHostPort(u.Hostname(), port)
+		}
 	}
+	return config.MainEndpoint().Address()
 }
 
 func (pp *PipelineProvider) MockPipelineChans() {
@@ -8,6 +8,7 @@ package pipeline
 import (
 	"testing"
 
+	"github.com/DataDog/datadog-log-agent/pkg/config"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -40,6 +41,20 @@ func (suite *PipelineProviderTestSuite) TestPipelineProviderMock() {
 	suite.Equal(suite.pp.NextPipelineChan(), suite.pp.NextPipelineChan())
 }
 
+func (suite *PipelineProviderTestSuite) TestUploadBandwidth() {
+	defer config.LogsAgent.Set("max_upload_bytes_per_second", 0)
+	defer config.LogsAgent.Set("log_spool_max_upload_bytes_per_second", 0)
+
+	suite.Equal(int64(0), uploadBandwidth(false))
+	config.LogsAgent.Set("max_upload_bytes_per_second", 1000)
+	suite.Equal(int64(1000), uploadBandwidth(false))
+	config.LogsAgent.Set("log_spool_max_upload_bytes_per_second", 500)
+	suite.Equal(int64(1000), uploadBandwidth(false))
+	suite.Equal(int64(500), uploadBandwidth(true))
+	config.LogsAgent.Set("max_upload_bytes_per_second", 0)
+	suite.Equal(int64(500), uploadBandwidth(true))
+}
+
 func TestPipelineProviderTestSuite(t *testing.T) {
 	suite.Run(t, new(PipelineProviderTestSuite))
 }
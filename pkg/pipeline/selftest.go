@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/processor"
+	"github.com/DataDog/datadog-log-agent/pkg/sender"
+)
+
+// Stages of the self test
+const (
+	StageConnectivity = "connectivity"
+	StageProcessing   = "processing"
+	StageSending      = "sending"
+)
+
+// A StageResult is the outcome of a stage of the self test
+type StageResult struct {
+	Stage  string
+	Detail string
+	Err    error
+}
+
+// SelfTest sends msg through a processor and a sender to the configured intake,
+// and reports the outcome of each stage. A stage is only run when the previous
+// one succeeded, each of them having timeout to complete. Over tcp, the sending
+// stage only checks the message was delivered, the intake not acknowledging it
+func (pp *PipelineProvider) SelfTest(cm *sender.ConnectionManager, msg message.Message, timeout time.Duration) []StageResult {
+	results := []StageResult{}
+
+	address := intakeAddress()
+	result := StageResult{Stage: StageConnectivity, Detail: address}
+	if !cm.Dialer().IsReachable(address) {
+		result.Err = fmt.Errorf("can't connect to %s, check your network and firewall rules", address)
+	}
+	results = append(results, result)
+	if result.Err != nil {
+		return results
+	}
+
+	processorChan := make(chan message.Message, 1)
+	senderChan := make(chan message.Message, 1)
+	outputChan := make(chan message.Message, 1)
+	processor.New(
+		processorChan,
+		senderChan,
+		config.LogsAgent.GetString("api_key"),
+		config.LogsAgent.GetString("logset"),
+		nil,
+	).Start()
+
+	processorChan <- msg
+	result = StageResult{Stage: StageProcessing}
+	select {
+	case processed := <-senderChan:
+		// the payload starts with the api key, which mustn't be printed
+		result.Detail = string(sender.WithoutAPIKey(processed.Content()))
+		msg = processed
+	case <-time.After(timeout):
+		result.Err = errors.New("the message was not processed in time")
+	}
+	results = append(results, result)
+	if result.Err != nil {
+		return results
+	}
+
+	droppedBefore := sender.DroppedMessages()
+	pp.startSender(senderChan, outputChan, cm)
+	senderChan <- msg
+	result = StageResult{Stage: StageSending, Detail: intakeAddress()}
+	if !config.LogsAgent.GetBool("log_use_http") || config.IsForwardingToAggregator() {
+		// over tcp, the intake doesn't answer the messages it rejects
+		result.Detail += " (delivered only, the api key isn't checked over tcp)"
+	}
+	select {
+	case <-outputChan:
+		if sender.DroppedMessages() > droppedBefore {
+			result.Err = errors.New("the intake rejected the message, check your api key")
+		}
+	case <-time.After(timeout):
+		result.Err = errors.New("the message was not sent in time")
+	}
+	return append(results, result)
+}
@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package pipeline
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSelfTestMessage() message.Message {
+	msg := message.NewMessage([]byte("test log"))
+	origin := message.NewOrigin()
+	origin.LogSource = &config.IntegrationConfigLogSource{TagsPayload: []byte{'-'}}
+	msg.SetOrigin(origin)
+	return msg
+}
+
+func newSelfTestConnectionManager(t *testing.T, port int) *sender.ConnectionManager {
+	config.LogsAgent.Set("log_dd_url", "127.0.0.1")
+	config.LogsAgent.Set("log_dd_port", port)
+	dialer, err := sender.NewDialer("", "", "", "", 0)
+	assert.Nil(t, err)
+	return sender.NewConnectionManager("127.0.0.1", port, true, dialer)
+}
+
+func TestSelfTestSendsMessage(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		for {
+			// the first connection is the connectivity check
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			if line != "" {
+				received <- line
+			}
+		}
+	}()
+
+	cm := newSelfTestConnectionManager(t, listener.Addr().(*net.TCPAddr).Port)
+	results := NewPipelineProvider().SelfTest(cm, newSelfTestMessage(), time.Second)
+	assert.Equal(t, 3, len(results))
+	for _, result := range results {
+		assert.Nil(t, result.Err, result.Stage)
+	}
+	// the api key isn't reported
+	config.LogsAgent.Set("api_key", "helloworld")
+	defer config.LogsAgent.Set("api_key", "")
+	results = NewPipelineProvider().SelfTest(cm, newSelfTestMessage(), time.Second)
+	assert.Equal(t, StageProcessing, results[1].Stage)
+	assert.NotContains(t, results[1].Detail, "helloworld")
+	assert.Contains(t, results[1].Detail, "test log")
+	assert.Contains(t, results[2].Detail, "delivered only")
+	assert.True(t, strings.HasSuffix(<-received, "test log\n"))
+}
+
+func TestSelfTestReportsUnreachableIntake(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cm := newSelfTestConnectionManager(t, port)
+	results := NewPipelineProvider().SelfTest(cm, newSelfTestMessage(), time.Second)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, StageConnectivity, results[0].Stage)
+	assert.NotNil(t, results[0].Err)
+}
@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// attributes holds the attributes of a json log, parsed on first use
+type attributes struct {
+	content []byte
+	parsed  bool
+	root    interface{}
+}
+
+// newAttributes returns the attributes of content
+func newAttributes(content []byte) *attributes {
+	return &attributes{content: content}
+}
+
+// get returns the value of attribute formatted as in the log, and false
+// when the log is not json or doesn't have the attribute
+func (a *attributes) get(attribute string) ([]byte, bool) {
+	value, ok := a.lookup(attribute)
+	if !ok {
+		return nil, false
+	}
+	return formatAttribute(value)
+}
+
+// lookup returns the value of attribute, nested attributes being separated with dots
+func (a *attributes) lookup(attribute string) (interface{}, bool) {
+	if !a.parsed {
+		a.root = parseAttributes(a.content)
+		a.parsed = true
+	}
+	if a.root == nil {
+		return nil, false
+	}
+	value := a.root
+	for _, key := range strings.Split(attribute, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// parseAttributes returns the json object of content, or nil
+func parseAttributes(content []byte) interface{} {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 || content[0] != '{' {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	// keeps numbers as written, so that a status code 200 is matched as 200
+	decoder.UseNumber()
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil
+	}
+	return root
+}
+
+// formatAttribute returns the bytes a rule pattern is matched against for value
+func formatAttribute(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return []byte(v), true
+	case json.Number:
+		return []byte(v.String()), true
+	case bool:
+		return []byte(strconv.FormatBool(v)), true
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
+		}
+		return b, true
+	}
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// What to do when the clock skew between log timestamps and the system time is too large
+const (
+	ClockSkewActionTag     = "tag"
+	ClockSkewActionCorrect = "correct"
+)
+
+// clockSkewSmoothing is the weight of a new observation in the skew estimate
+const clockSkewSmoothing = 0.1
+
+// A ClockSkewDetector compares the timestamps parsed from logs with the system time.
+// With the tag action, messages skewed beyond the threshold are tagged with their skew;
+// with the correct action, messages stamped on reception are shifted by the estimated skew
+type ClockSkewDetector struct {
+	mutex     sync.Mutex
+	threshold time.Duration
+	action    string
+	estimate  time.Duration
+	observed  bool
+	now       func() time.Time
+}
+
+// NewClockSkewDetector returns an initialized ClockSkewDetector
+func NewClockSkewDetector(threshold time.Duration, action string) (*ClockSkewDetector, error) {
+	switch action {
+	case ClockSkewActionTag, ClockSkewActionCorrect:
+	default:
+		return nil, fmt.Errorf("unknown clock skew action %s", action)
+	}
+	return &ClockSkewDetector{
+		threshold: threshold,
+		action:    action,
+		now:       time.Now,
+	}, nil
+}
+
+// Observe updates the skew estimate with the timestamp of a log
+// and returns the skew of this log
+func (d *ClockSkewDetector) Observe(timestamp time.Time) time.Duration {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	skew := timestamp.Sub(d.now())
+	if !d.observed {
+		d.estimate = skew
+		d.observed = true
+	} else {
+		d.estimate += time.Duration(float64(skew-d.estimate) * clockSkewSmoothing)
+	}
+	return skew
+}
+
+// Now returns the time to stamp messages with on reception,
+// corrected by the estimated skew when relevant
+func (d *ClockSkewDetector) Now() time.Time {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.action == ClockSkewActionCorrect && d.isSkewed(d.estimate) {
+		return d.now().Add(d.estimate)
+	}
+	return d.now()
+}
+
+// Tag returns the tag to add to a log with the given skew, or "" if it's within the threshold
+func (d *ClockSkewDetector) Tag(skew time.Duration) string {
+	if d.action != ClockSkewActionTag || !d.isSkewed(skew) {
+		return ""
+	}
+	return fmt.Sprintf("clock_skew:%s", skew.Round(time.Second))
+}
+
+// Status returns the skew estimate when it exceeds the threshold
+func (d *ClockSkewDetector) Status() interface{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !d.isSkewed(d.estimate) {
+		return "no clock skew detected"
+	}
+	return fmt.Sprintf("clock skew of %s detected between logs and system time", d.estimate.Round(time.Second))
+}
+
+func (d *ClockSkewDetector) isSkewed(skew time.Duration) bool {
+	return skew > d.threshold || skew < -d.threshold
+}
@@ -0,0 +1,42 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClockSkewDetector(action string, now time.Time) *ClockSkewDetector {
+	d, _ := NewClockSkewDetector(time.Minute, action)
+	d.now = func() time.Time { return now }
+	return d
+}
+
+func TestNewClockSkewDetectorRejectsUnknownAction(t *testing.T) {
+	_, err := NewClockSkewDetector(time.Minute, "ignore")
+	assert.NotNil(t, err)
+}
+
+func TestClockSkewTag(t *testing.T) {
+	now := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	d := newTestClockSkewDetector(ClockSkewActionTag, now)
+	assert.Equal(t, "", d.Tag(d.Observe(now.Add(30*time.Second))))
+	assert.Equal(t, "clock_skew:-10m0s", d.Tag(d.Observe(now.Add(-10*time.Minute))))
+	assert.Equal(t, now, d.Now())
+}
+
+func TestClockSkewCorrect(t *testing.T) {
+	now := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	d := newTestClockSkewDetector(ClockSkewActionCorrect, now)
+	assert.Equal(t, "no clock skew detected", d.Status())
+	d.Observe(now.Add(time.Hour))
+	assert.Equal(t, now.Add(time.Hour), d.Now())
+	assert.Equal(t, "", d.Tag(time.Hour))
+	assert.Equal(t, "clock skew of 1h0m0s detected between logs and system time", d.Status())
+}
@@ -7,10 +7,24 @@ package processor
 
 import (
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/deadletter"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+	"github.com/DataDog/datadog-log-agent/pkg/publisher"
+)
+
+var (
+	// messageSize measures the size of the messages before processing
+	messageSize = metrics.NewHistogram("logs_message_size_bytes", metrics.SizeBuckets)
+	// processLatency measures the time spent processing each message
+	processLatency = metrics.NewHistogram("logs_process_latency_seconds", metrics.LatencyBuckets)
+	// sourceMessages counts the messages processed by source identifier
+	sourceMessages = metrics.NewLabeledCounter("logs_source_messages")
 )
 
 // A Processor updates messages from an inputChan and pushes
@@ -21,10 +35,15 @@ type Processor struct {
 	apikey       string
 	logset       string
 	apikeyString []byte
+	clockSkew    *ClockSkewDetector
+	// forward is true when messages are forwarded to an aggregator agent as envelopes
+	forward bool
+	// publishers receive the processed messages for local consumers
+	publishers []*publisher.Publisher
 }
 
-// New returns an initialized Processor
-func New(inputChan, outputChan chan message.Message, apikey, logset string) *Processor {
+// New returns an initialized Processor, clockSkew being optional
+func New(inputChan, outputChan chan message.Message, apikey, logset string, clockSkew *ClockSkewDetector) *Processor {
 	var apikeyString string
 	if logset != "" {
 		apikeyString = fmt.Sprintf("%s/%s", apikey, logset)
@@ -37,9 +56,16 @@ func New(inputChan, outputChan chan message.Message, apikey, logset string) *Pro
 		apikey:       apikey,
 		logset:       logset,
 		apikeyString: []byte(apikeyString),
+		clockSkew:    clockSkew,
+		forward:      config.IsForwardingToAggregator(),
 	}
 }
 
+// PublishTo makes the processor publish the processed messages to publishers too
+func (p *Processor) PublishTo(publishers []*publisher.Publisher) {
+	p.publishers = publishers
+}
+
 // Start starts the Processor
 func (p *Processor) Start() {
 	go p.run()
@@ -48,24 +74,61 @@ func (p *Processor) Start() {
 // run starts the processing of the inputChan
 func (p *Processor) run() {
 	for msg := range p.inputChan {
+		if message.IsEndOfStream(msg) {
+			// nothing to process, the outputs flush on it
+			p.outputChan <- msg
+			continue
+		}
+		start := time.Now()
+		messageSize.Observe(float64(len(msg.Content())))
+		sourceMessages.Add(msg.GetOrigin().LogSource.GetID(), 1)
+		if !isSampled(msg) || !withinQuota(msg) {
+			// dropped on purpose, nothing to send
+			msg.GetOrigin().Acknowledge(true)
+			processLatency.ObserveSince(start)
+			continue
+		}
+		if isRaw(msg) {
+			p.forwardRaw(msg)
+			processLatency.ObserveSince(start)
+			continue
+		}
+		remapSeverity(msg)
 		shouldProcess, redactedMessage := p.applyRedactingRules(msg)
 		if shouldProcess {
-			extraContent := p.computeExtraContent(msg)
+			assignService(msg)
+			skewTag := p.checkClockSkew(msg)
+			if p.forward || len(p.publishers) > 0 {
+				envelope := p.buildEnvelope(msg, redactedMessage, skewTag)
+				for _, publisher := range p.publishers {
+					publisher.Publish(envelope)
+				}
+				if p.forward {
+					p.forwardEnvelope(msg, envelope)
+					processLatency.ObserveSince(start)
+					continue
+				}
+			}
+			extraContent := p.computeExtraContent(msg, skewTag)
 			apikeyString := p.computeApiKeyString(msg)
 			payload := p.buildPayload(apikeyString, redactedMessage, extraContent)
 			msg.SetContent(payload)
+			processLatency.ObserveSince(start)
 			p.outputChan <- msg
+		} else {
+			// filtered out on purpose, nothing to send
+			msg.GetOrigin().Acknowledge(true)
 		}
 	}
 }
 
 // computeExtraContent returns additional content to add to a log line.
 // For instance, we want to add the timestamp, hostname and a log level
-// to messages coming from a file
-func (p *Processor) computeExtraContent(msg message.Message) []byte {
+// to messages coming from a file, along with skewTag if any
+func (p *Processor) computeExtraContent(msg message.Message, skewTag string) []byte {
 	// if the first char is '<', we can assume it's already formatted as RFC5424, thus skip this step
 	// (for instance, using tcp forwarding. We don't want to override the hostname & co)
-	if len(msg.Content()) > 0 && msg.Content()[0] != '<' {
+	if len(msg.Content()) > 0 && !isFormatted(msg) {
 		// fit RFC5424
 		// <%pri%>%protocol-version% %timestamp:::date-rfc3339% %HOSTNAME% %$!new-appname% - - - %msg%\n
 		extraContent := []byte("")
@@ -82,20 +145,15 @@ func (p *Processor) computeExtraContent(msg message.Message) []byte {
 		extraContent = append(extraContent, ' ')
 
 		// Timestamp
-		if msg.GetTimestamp() != "" {
-			extraContent = append(extraContent, []byte(msg.GetTimestamp())...)
-		} else {
-			timestamp := time.Now().UTC().Format(config.DateFormat)
-			extraContent = append(extraContent, []byte(timestamp)...)
-		}
+		extraContent = append(extraContent, []byte(config.FormatTimestamp(p.timestamp(msg)))...)
 		extraContent = append(extraContent, ' ')
 
 		// Hostname
-		extraContent = append(extraContent, []byte(config.LogsAgent.GetString("hostname"))...)
+		extraContent = append(extraContent, []byte(p.hostname(msg))...)
 		extraContent = append(extraContent, ' ')
 
 		// Service
-		service := msg.GetOrigin().LogSource.Service
+		service := msg.GetService()
 		if service != "" {
 			extraContent = append(extraContent, []byte(service)...)
 		} else {
@@ -106,7 +164,7 @@ func (p *Processor) computeExtraContent(msg message.Message) []byte {
 		extraContent = append(extraContent, []byte(" - - ")...)
 
 		// Tags
-		extraContent = append(extraContent, msg.GetTagsPayload()...)
+		extraContent = append(extraContent, p.tagsPayload(msg, skewTag)...)
 		extraContent = append(extraContent, ' ')
 
 		return extraContent
@@ -114,14 +172,158 @@ func (p *Processor) computeExtraContent(msg message.Message) []byte {
 	return nil
 }
 
+// hostname returns the host msg was collected on
+func (p *Processor) hostname(msg message.Message) string {
+	if hostname := msg.GetHostname(); hostname != "" {
+		return hostname
+	}
+	return config.LogsAgent.GetString("hostname")
+}
+
+// tagsPayload returns the tags payload of msg, with skewTag if any
+// and the tags locating its origin when enabled
+func (p *Processor) tagsPayload(msg message.Message, skewTag string) []byte {
+	tags := []string{}
+	if skewTag != "" {
+		tags = append(tags, skewTag)
+	}
+	if config.LogsAgent.GetBool("log_origin_attributes") {
+		tags = append(tags, originTags(msg.GetOrigin())...)
+	}
+	if sequence := msg.GetOrigin().SourceSequence; sequence > 0 {
+		tags = append(tags, fmt.Sprintf("source_sequence:%d", sequence))
+	}
+	if len(tags) == 0 {
+		return msg.GetTagsPayload()
+	}
+	return config.GetTagsPayloadFormatter().AppendTags(msg.GetTagsPayload(), strings.Join(tags, ","))
+}
+
+// originTags returns the tags locating where a message was collected
+func originTags(origin *message.MessageOrigin) []string {
+	if origin.Path != "" {
+		return []string{"origin_path:" + origin.Path, fmt.Sprintf("origin_offset:%d", origin.StartOffset)}
+	}
+	if origin.ConnectionID != "" {
+		return []string{"origin_connection:" + origin.ConnectionID, fmt.Sprintf("origin_sequence:%d", origin.Sequence)}
+	}
+	return nil
+}
+
+// isRaw returns true when msg must be relayed as is
+func isRaw(msg message.Message) bool {
+	origin := msg.GetOrigin()
+	return origin.Raw || origin.LogSource.RawForward
+}
+
+// forwardRaw sends the content of msg prefixed by the api key only, or wrapped
+// in an envelope telling the aggregator agent to relay it as is too
+func (p *Processor) forwardRaw(msg message.Message) {
+	if p.forward {
+		envelope := &message.Envelope{Message: string(msg.Content()), Raw: true}
+		envelope.APIKey, envelope.Logset = credentials(msg.GetOrigin())
+		p.forwardEnvelope(msg, envelope)
+		return
+	}
+	msg.SetContent(p.buildPayload(p.computeApiKeyString(msg), msg.Content(), nil))
+	p.outputChan <- msg
+}
+
+// forwardEnvelope sends msg to the aggregator agent, along with the metadata
+// it needs to ship it to the intake
+func (p *Processor) forwardEnvelope(msg message.Message, envelope *message.Envelope) {
+	payload, err := envelope.Encode()
+	if err != nil {
+		log.Println("Can't forward message to the aggregator:", err)
+		deadletter.Write(deadletter.ReasonEncodeError, msg, msg.Content())
+		msg.GetOrigin().Acknowledge(false)
+		return
+	}
+	msg.SetContent(payload)
+	p.outputChan <- msg
+}
+
+// buildEnvelope returns the envelope of a processed message, tagged with skewTag if any
+func (p *Processor) buildEnvelope(msg message.Message, redactedMessage []byte, skewTag string) *message.Envelope {
+	envelope := &message.Envelope{
+		Message:     string(redactedMessage),
+		Hostname:    p.hostname(msg),
+		Service:     msg.GetService(),
+		Severity:    string(config.SEV_INFO),
+		Timestamp:   p.timestamp(msg),
+		TagsPayload: string(p.tagsPayload(msg, skewTag)),
+	}
+	envelope.APIKey, envelope.Logset = credentials(msg.GetOrigin())
+	if msg.GetSeverity() != nil {
+		envelope.Severity = string(msg.GetSeverity())
+	}
+	return envelope
+}
+
+// timestamp returns the timestamp of msg: the one it was parsed with, unless
+// its source makes the receive time authoritative, or the current time
+func (p *Processor) timestamp(msg message.Message) string {
+	if msg.GetTimestamp() != "" && !msg.GetOrigin().LogSource.UsesReceiveTime() {
+		return msg.GetTimestamp()
+	}
+	return p.now().UTC().Format(config.DateFormat)
+}
+
+// checkClockSkew compares the timestamp of the message, if any, with the system time
+// and returns the tag to add to the message when it's skewed. The messages sent as
+// they were formatted have no room for the tag, and aren't observed to tag them
+func (p *Processor) checkClockSkew(msg message.Message) string {
+	if p.clockSkew == nil {
+		return ""
+	}
+	if p.clockSkew.action == ClockSkewActionTag && !p.forward && len(p.publishers) == 0 && isFormatted(msg) {
+		return ""
+	}
+	timestamp, ok := message.ParseTimestamp(msg)
+	if !ok {
+		return ""
+	}
+	return p.clockSkew.Tag(p.clockSkew.Observe(timestamp))
+}
+
+// isFormatted returns true when the content of msg is already formatted as RFC5424
+func isFormatted(msg message.Message) bool {
+	content := msg.Content()
+	return len(content) > 0 && content[0] == '<'
+}
+
+// now returns the time messages without timestamp are stamped with
+func (p *Processor) now() time.Time {
+	if p.clockSkew != nil {
+		return p.clockSkew.Now()
+	}
+	return time.Now()
+}
+
+// computeApiKeyString returns the credentials msg is sent with: the api key and
+// logset of its source when they override those of the agent
 func (p *Processor) computeApiKeyString(msg message.Message) []byte {
-	sourceLogset := msg.GetOrigin().LogSource.Logset
-	if sourceLogset != "" {
-		return []byte(fmt.Sprintf("%s/%s", p.apikey, sourceLogset))
+	apikey, logset := credentials(msg.GetOrigin())
+	switch {
+	case apikey != "" && logset != "":
+		return []byte(fmt.Sprintf("%s/%s", apikey, logset))
+	case apikey != "":
+		return []byte(apikey)
+	case logset != "":
+		return []byte(fmt.Sprintf("%s/%s", p.apikey, logset))
 	}
 	return p.apikeyString
 }
 
+// credentials returns the api key and logset overriding those of the agent for
+// the messages of origin, those relayed for edge agents taking precedence
+func credentials(origin *message.MessageOrigin) (string, string) {
+	if origin.APIKey != "" || origin.Logset != "" {
+		return origin.APIKey, origin.Logset
+	}
+	return origin.LogSource.APIKey, origin.LogSource.Logset
+}
+
 // buildPayload returns a processed payload from a raw message
 func (p *Processor) buildPayload(apikeyString, redactedMessage, extraContent []byte) []byte {
 	payload := append(apikeyString, ' ')
@@ -137,10 +339,15 @@ func (p *Processor) buildPayload(apikeyString, redactedMessage, extraContent []b
 // and a copy of the message with some fields redacted, depending on config
 func (p *Processor) applyRedactingRules(msg message.Message) (bool, []byte) {
 	content := msg.Content()
+	attributes := newAttributes(content)
 	for _, rule := range msg.GetOrigin().LogSource.ProcessingRules {
 		switch rule.Type {
 		case config.EXCLUDE_AT_MATCH:
-			if rule.Reg.Match(content) {
+			if matchRule(rule, content, attributes) {
+				return false, nil
+			}
+		case config.INCLUDE_AT_MATCH:
+			if !matchRule(rule, content, attributes) {
 				return false, nil
 			}
 		case config.MASK_SEQUENCES:
@@ -149,3 +356,13 @@ func (p *Processor) applyRedactingRules(msg message.Message) (bool, []byte) {
 	}
 	return true, content
 }
+
+// matchRule returns true if the pattern of rule matches the content of the message,
+// or the value of its field when the rule targets an attribute of json logs
+func matchRule(rule config.LogsProcessingRule, content []byte, attributes *attributes) bool {
+	if rule.Field == "" {
+		return rule.Reg.Match(content)
+	}
+	value, ok := attributes.get(rule.Field)
+	return ok && rule.Reg.Match(value)
+}
@@ -18,7 +18,7 @@ import (
 )
 
 func NewTestProcessor() Processor {
-	return Processor{nil, nil, "", "", nil}
+	return Processor{nil, nil, "", "", nil, nil, false, nil}
 }
 
 func buildTestProcessingRule(ruleType, replacePlaceholder, pattern string, p *Processor) config.IntegrationConfigLogSource {
@@ -43,9 +43,9 @@ func newNetworkMessage(content []byte, source *config.IntegrationConfigLogSource
 
 func TestProcessor(t *testing.T) {
 	var p *Processor
-	p = New(nil, nil, "hello", "world")
+	p = New(nil, nil, "hello", "world", nil)
 	assert.Equal(t, "hello/world", string(p.apikeyString))
-	p = New(nil, nil, "helloworld", "")
+	p = New(nil, nil, "helloworld", "", nil)
 	assert.Equal(t, "helloworld", string(p.apikeyString))
 }
 
@@ -70,6 +70,46 @@ func TestExclusion(t *testing.T) {
 	assert.Equal(t, true, shouldProcess)
 }
 
+func TestExclusionOnField(t *testing.T) {
+	p := NewTestProcessor()
+	var shouldProcess bool
+
+	source := buildTestProcessingRule("exclude_at_match", "", "^2..$", &p)
+	source.ProcessingRules[0].Field = "http.status_code"
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte(`{"http":{"status_code":200},"message":"GET / 500ms"}`), &source))
+	assert.Equal(t, false, shouldProcess)
+
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte(`{"http":{"status_code":"503"},"message":"GET / 200ms"}`), &source))
+	assert.Equal(t, true, shouldProcess)
+
+	// logs without the field are never excluded
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte(`{"message":"200"}`), &source))
+	assert.Equal(t, true, shouldProcess)
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte("200"), &source))
+	assert.Equal(t, true, shouldProcess)
+}
+
+func TestInclusion(t *testing.T) {
+	p := NewTestProcessor()
+	var shouldProcess bool
+
+	source := buildTestProcessingRule("include_at_match", "", "world", &p)
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte("hello world"), &source))
+	assert.Equal(t, true, shouldProcess)
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte("hello"), &source))
+	assert.Equal(t, false, shouldProcess)
+
+	// logs without the field are never included
+	source = buildTestProcessingRule("include_at_match", "", "^(error|warn)$", &p)
+	source.ProcessingRules[0].Field = "level"
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte(`{"level":"error","message":"info"}`), &source))
+	assert.Equal(t, true, shouldProcess)
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte(`{"level":"info","message":"error"}`), &source))
+	assert.Equal(t, false, shouldProcess)
+	shouldProcess, _ = p.applyRedactingRules(newNetworkMessage([]byte("error"), &source))
+	assert.Equal(t, false, shouldProcess)
+}
+
 func TestMask(t *testing.T) {
 	p := NewTestProcessor()
 	var shouldProcess bool
@@ -112,7 +152,7 @@ func TestComputeExtraContent(t *testing.T) {
 
 	// message with Content only, check default values
 
-	extraContent = p.computeExtraContent(newNetworkMessage([]byte("message"), source))
+	extraContent = p.computeExtraContent(newNetworkMessage([]byte("message"), source), "")
 	extraContentParts = strings.Split(string(extraContent), " ")
 	assert.Equal(t, 8, len(extraContentParts))
 
@@ -122,7 +162,7 @@ func TestComputeExtraContent(t *testing.T) {
 	assert.Nil(t, err)
 	assert.True(t, math.Abs(time.Now().UTC().Sub(timestamp).Minutes()) < 1)
 
-	extraContent = p.computeExtraContent(newNetworkMessage([]byte("<message"), source))
+	extraContent = p.computeExtraContent(newNetworkMessage([]byte("<message"), source), "")
 	assert.Nil(t, extraContent)
 
 	// message with additional information
@@ -131,7 +171,7 @@ func TestComputeExtraContent(t *testing.T) {
 	msg.SetSeverity([]byte("sev"))
 	msg.SetTagsPayload([]byte("tags"))
 
-	extraContent = p.computeExtraContent(msg)
+	extraContent = p.computeExtraContent(msg, "")
 	extraContentParts = strings.Split(string(extraContent), " ")
 	assert.Equal(t, "sev0", extraContentParts[0])
 	assert.Equal(t, "ts", extraContentParts[1])
@@ -139,7 +179,7 @@ func TestComputeExtraContent(t *testing.T) {
 }
 
 func TestComputeApiKeyString(t *testing.T) {
-	p := New(nil, nil, "hello", "world")
+	p := New(nil, nil, "hello", "world", nil)
 
 	source := &config.IntegrationConfigLogSource{}
 	extraContent := p.computeApiKeyString(newNetworkMessage(nil, source))
@@ -148,4 +188,166 @@ func TestComputeApiKeyString(t *testing.T) {
 	source = &config.IntegrationConfigLogSource{Logset: "hi"}
 	extraContent = p.computeApiKeyString(newNetworkMessage(nil, source))
 	assert.Equal(t, "hello/hi", string(extraContent))
+
+	source = &config.IntegrationConfigLogSource{APIKey: "teamkey"}
+	extraContent = p.computeApiKeyString(newNetworkMessage(nil, source))
+	assert.Equal(t, "teamkey", string(extraContent))
+
+	source = &config.IntegrationConfigLogSource{APIKey: "teamkey", Logset: "team"}
+	extraContent = p.computeApiKeyString(newNetworkMessage(nil, source))
+	assert.Equal(t, "teamkey/team", string(extraContent))
+
+	// the credentials of the sources of edge agents take precedence
+	msg := newNetworkMessage(nil, source)
+	(&message.Envelope{APIKey: "edgekey"}).Apply(msg)
+	assert.Equal(t, "edgekey", string(p.computeApiKeyString(msg)))
+}
+
+func TestBuildEnvelopeWithCredentials(t *testing.T) {
+	p := New(nil, nil, "apikey", "", nil)
+	source := &config.IntegrationConfigLogSource{APIKey: "teamkey", Logset: "team"}
+	envelope := p.buildEnvelope(newNetworkMessage([]byte("hello"), source), []byte("hello"), "")
+	assert.Equal(t, "teamkey", envelope.APIKey)
+	assert.Equal(t, "team", envelope.Logset)
+}
+
+func TestBuildEnvelope(t *testing.T) {
+	p := New(nil, nil, "apikey", "", nil)
+	source := &config.IntegrationConfigLogSource{Service: "app", TagsPayload: []byte("[dd ddtags=\"env:prod\"]")}
+	msg := newNetworkMessage([]byte("hello world"), source)
+	msg.GetOrigin().Timestamp = "2017-10-16T10:00:00Z"
+
+	envelope := p.buildEnvelope(msg, []byte("hello [masked]"), "")
+	assert.Equal(t, "hello [masked]", envelope.Message)
+	assert.Equal(t, config.LogsAgent.GetString("hostname"), envelope.Hostname)
+	assert.Equal(t, "app", envelope.Service)
+	assert.Equal(t, "<46>", envelope.Severity)
+	assert.Equal(t, "2017-10-16T10:00:00Z", envelope.Timestamp)
+	assert.Equal(t, "[dd ddtags=\"env:prod\"]", envelope.TagsPayload)
+}
+
+func TestComputeExtraContentTimestamp(t *testing.T) {
+	p := NewTestProcessor()
+	source := &config.IntegrationConfigLogSource{TagsPayload: []byte{'-'}}
+	msg := newNetworkMessage([]byte("message"), source)
+	msg.GetOrigin().Timestamp = "2017-10-16T10:00:00.123456789Z"
+
+	defer config.LogsAgent.Set("log_timestamp_format", config.TIMESTAMP_FORMAT_RFC3339_NANO)
+	config.LogsAgent.Set("log_timestamp_format", config.TIMESTAMP_FORMAT_EPOCH_MILLIS)
+	extraContentParts := strings.Split(string(p.computeExtraContent(msg, "")), " ")
+	assert.Equal(t, "1508148000123", extraContentParts[1])
+
+	// the receive time is authoritative, the message being backfilled
+	source.TimestampSource = config.TIMESTAMP_RECEIVED
+	config.LogsAgent.Set("log_timestamp_format", config.TIMESTAMP_FORMAT_RFC3339_NANO)
+	extraContentParts = strings.Split(string(p.computeExtraContent(msg, "")), " ")
+	timestamp, err := time.Parse(config.DateFormat, extraContentParts[1])
+	assert.Nil(t, err)
+	assert.True(t, math.Abs(time.Now().UTC().Sub(timestamp).Minutes()) < 1)
+}
+
+func TestComputeExtraContentOfForwardedMessage(t *testing.T) {
+	p := NewTestProcessor()
+	msg := newNetworkMessage([]byte("message"), &config.IntegrationConfigLogSource{TagsPayload: []byte{'-'}})
+	msg.SetHostname("edge-host")
+	extraContentParts := strings.Split(string(p.computeExtraContent(msg, "")), " ")
+	assert.Equal(t, "edge-host", extraContentParts[2])
+}
+
+func TestOriginTags(t *testing.T) {
+	defer config.LogsAgent.Set("log_origin_attributes", false)
+	p := NewTestProcessor()
+	msg := newNetworkMessage([]byte("message"), &config.IntegrationConfigLogSource{TagsPayload: []byte{'-'}})
+	msg.GetOrigin().Path = "/var/log/a.log"
+	msg.GetOrigin().StartOffset = 42
+	assert.Equal(t, "-", string(p.tagsPayload(msg, "")))
+
+	config.LogsAgent.Set("log_origin_attributes", true)
+	assert.Equal(t, `[dd ddtags="origin_path:/var/log/a.log,origin_offset:42"]`, string(p.tagsPayload(msg, "")))
+
+	msg = newNetworkMessage([]byte("message"), &config.IntegrationConfigLogSource{TagsPayload: []byte{'-'}})
+	msg.GetOrigin().ConnectionID = "10.0.0.1:5000-3"
+	msg.GetOrigin().Sequence = 7
+	assert.Equal(t, `[dd ddtags="clock_skew:true,origin_connection:10.0.0.1:5000-3,origin_sequence:7"]`, string(p.tagsPayload(msg, "clock_skew:true")))
+}
+
+func TestSourceSequenceTag(t *testing.T) {
+	p := NewTestProcessor()
+	msg := newNetworkMessage([]byte("message"), &config.IntegrationConfigLogSource{TagsPayload: []byte{'-'}})
+	msg.GetOrigin().SourceSequence = 12
+	assert.Equal(t, `[dd ddtags="source_sequence:12"]`, string(p.tagsPayload(msg, "")))
+}
+
+func TestClockSkewOfFormattedMessages(t *testing.T) {
+	now := time.Date(2017, 10, 16, 12, 0, 0, 0, time.UTC)
+	p := New(nil, nil, "apikey", "", newTestClockSkewDetector(ClockSkewActionTag, now))
+	source := &config.IntegrationConfigLogSource{TagsPayload: []byte{'-'}}
+	formatted := newNetworkMessage([]byte("<13>1 2017-10-16T10:00:00Z host app - - - hello"), source)
+
+	// sent as is, the message has no room for the tag
+	assert.Equal(t, "", p.checkClockSkew(formatted))
+	assert.False(t, p.clockSkew.observed)
+
+	p.forward = true
+	assert.Equal(t, "clock_skew:-2h0m0s", p.checkClockSkew(formatted))
+
+	// the skew of all the messages is estimated to correct it
+	p = New(nil, nil, "apikey", "", newTestClockSkewDetector(ClockSkewActionCorrect, now))
+	p.checkClockSkew(formatted)
+	assert.Equal(t, now.Add(-2*time.Hour), p.now())
+}
+
+func TestRawForward(t *testing.T) {
+	outputChan := make(chan message.Message, 1)
+	p := New(nil, outputChan, "apikey", "", nil)
+	source := &config.IntegrationConfigLogSource{Service: "app", RawForward: true, TagsPayload: []byte("[dd ddtags=\"env:prod\"]")}
+
+	p.forwardRaw(newNetworkMessage([]byte("<13>1 2017-10-16T10:00:00Z host app - - - hello"), source))
+	assert.Equal(t, "apikey <13>1 2017-10-16T10:00:00Z host app - - - hello\n", string((<-outputChan).Content()))
+
+	p.forward = true
+	p.forwardRaw(newNetworkMessage([]byte("hello"), source))
+	envelope, err := message.DecodeEnvelope((<-outputChan).Content())
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", envelope.Message)
+	assert.True(t, envelope.Raw)
+}
+
+func TestIsRaw(t *testing.T) {
+	assert.False(t, isRaw(newNetworkMessage(nil, &config.IntegrationConfigLogSource{})))
+	assert.True(t, isRaw(newNetworkMessage(nil, &config.IntegrationConfigLogSource{RawForward: true})))
+
+	// relayed by an aggregator agent on behalf of the edge agent
+	msg := newNetworkMessage(nil, &config.IntegrationConfigLogSource{Type: config.AGENT_TYPE})
+	(&message.Envelope{Message: "hello", Raw: true}).Apply(msg)
+	assert.True(t, isRaw(msg))
+}
+
+func TestIsSampled(t *testing.T) {
+	rate := 0.25
+	source := &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, Port: 10514, SamplingRate: &rate}
+	kept := []int{}
+	for i := 1; i <= 12; i++ {
+		if isSampled(newNetworkMessage([]byte("debug"), source)) {
+			kept = append(kept, i)
+		}
+	}
+	assert.Equal(t, []int{4, 8, 12}, kept)
+
+	none := 0.0
+	assert.False(t, isSampled(newNetworkMessage([]byte("debug"), &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, Port: 10515, SamplingRate: &none})))
+	assert.True(t, isSampled(newNetworkMessage([]byte("debug"), &config.IntegrationConfigLogSource{Type: config.TCP_TYPE, Port: 10516})))
+}
+
+func TestWithinQuota(t *testing.T) {
+	source := &config.IntegrationConfigLogSource{Namespace: "payments", NamespaceMaxBytesPerSecond: 10}
+	assert.True(t, withinQuota(newNetworkMessage([]byte("0123456789"), source)))
+	assert.False(t, withinQuota(newNetworkMessage([]byte("0123456789"), source)))
+
+	// the quota is renewed when it changes
+	source = &config.IntegrationConfigLogSource{Namespace: "payments", NamespaceMaxBytesPerSecond: 20}
+	assert.True(t, withinQuota(newNetworkMessage([]byte("0123456789"), source)))
+
+	// the sources out of namespaces have no quota
+	assert.True(t, withinQuota(newNetworkMessage([]byte("0123456789"), &config.IntegrationConfigLogSource{})))
 }
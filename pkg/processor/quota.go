@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"sync"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
+)
+
+// namespaceDrops counts the messages dropped as over the quota of their namespace
+var namespaceDrops = metrics.NewLabeledCounter("logs_namespace_dropped_messages")
+
+// namespaceQuota is a token bucket of the bytes a namespace can send per second
+type namespaceQuota struct {
+	maxBytesPerSecond int64
+	bucket            *utils.TokenBucket
+}
+
+// quotas are shared by the processors of all the pipelines, by namespace
+var quotas = struct {
+	sync.Mutex
+	byNamespace map[string]*namespaceQuota
+}{byNamespace: make(map[string]*namespaceQuota)}
+
+// withinQuota returns true when the namespace of the source of msg, if any, has the
+// quota to send it, consuming it. The messages over the quota are counted
+func withinQuota(msg message.Message) bool {
+	source := msg.GetOrigin().LogSource
+	if source.Namespace == "" || source.NamespaceMaxBytesPerSecond <= 0 {
+		return true
+	}
+	if quotaOf(source).bucket.TryTake(len(msg.Content())) {
+		return true
+	}
+	namespaceDrops.Add(source.Namespace, 1)
+	return false
+}
+
+// quotaOf returns the quota of the namespace of source, replacing it
+// when the quota changed as the sources were reloaded
+func quotaOf(source *config.IntegrationConfigLogSource) *namespaceQuota {
+	quotas.Lock()
+	defer quotas.Unlock()
+	quota, ok := quotas.byNamespace[source.Namespace]
+	if !ok || quota.maxBytesPerSecond != source.NamespaceMaxBytesPerSecond {
+		quota = &namespaceQuota{
+			maxBytesPerSecond: source.NamespaceMaxBytesPerSecond,
+			bucket:            utils.NewTokenBucket(source.NamespaceMaxBytesPerSecond),
+		}
+		quotas.byNamespace[source.Namespace] = quota
+	}
+	return quota
+}
@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"math"
+	"sync"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+// sampledOut counts the messages left out by the sampling of their source, by source
+var sampledOut = metrics.NewLabeledCounter("logs_sampled_out_messages")
+
+// sourceSampler counts the messages of a source to keep a fraction of them
+type sourceSampler struct {
+	rate  float64
+	count uint64
+}
+
+// samplers are shared by the processors of all the pipelines, by source
+var samplers = struct {
+	sync.Mutex
+	bySource map[string]*sourceSampler
+}{bySource: make(map[string]*sourceSampler)}
+
+// isSampled returns true when msg is among the fraction of the messages of its
+// source kept by its sampling_rate, every message being kept without one. The
+// messages kept are evenly spread: with a rate of 0.25, every fourth one is kept
+func isSampled(msg message.Message) bool {
+	source := msg.GetOrigin().LogSource
+	if source.SamplingRate == nil || *source.SamplingRate >= 1 {
+		return true
+	}
+	id := source.GetID()
+	samplers.Lock()
+	sampler, ok := samplers.bySource[id]
+	if !ok || sampler.rate != *source.SamplingRate {
+		// the counting restarts when the rate changed as the sources were reloaded
+		sampler = &sourceSampler{rate: *source.SamplingRate}
+		samplers.bySource[id] = sampler
+	}
+	sampler.count++
+	kept := math.Floor(float64(sampler.count)*sampler.rate) > math.Floor(float64(sampler.count-1)*sampler.rate)
+	samplers.Unlock()
+	if !kept {
+		sampledOut.Add(id, 1)
+	}
+	return kept
+}
@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"regexp"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+)
+
+// serviceGroupName is the name of the capture group of service_pattern holding the service,
+// the first group being used when there is none
+const serviceGroupName = "service"
+
+// assignService sets the service of msg from its content, when its source derives
+// the service from a regex capture or from an attribute of json logs.
+// The service of the source is kept when nothing is extracted
+func assignService(msg message.Message) {
+	source := msg.GetOrigin().LogSource
+	if source.ServiceReg != nil {
+		if service := serviceFromPattern(source.ServiceReg, msg.Content()); service != "" {
+			msg.SetService(service)
+			return
+		}
+	}
+	if source.ServiceAttribute != "" {
+		if service := serviceFromAttribute(source.ServiceAttribute, msg.Content()); service != "" {
+			msg.SetService(service)
+		}
+	}
+}
+
+// serviceFromPattern returns the service captured by re in content
+func serviceFromPattern(re *regexp.Regexp, content []byte) string {
+	match := re.FindSubmatch(content)
+	if match == nil {
+		return ""
+	}
+	group := 1
+	for i, name := range re.SubexpNames() {
+		if name == serviceGroupName {
+			group = i
+		}
+	}
+	return string(match[group])
+}
+
+// serviceFromAttribute returns the value of the attribute of a json log,
+// nested attributes being separated with dots
+func serviceFromAttribute(attribute string, content []byte) string {
+	value, _ := newAttributes(content).lookup(attribute)
+	service, _ := value.(string)
+	return service
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceFromPattern(t *testing.T) {
+	re := regexp.MustCompile(`^\[(\w+)\]`)
+	assert.Equal(t, "payments", serviceFromPattern(re, []byte("[payments] charge accepted")))
+	assert.Equal(t, "", serviceFromPattern(re, []byte("charge accepted")))
+
+	re = regexp.MustCompile(`app=(?P<app>\w+) svc=(?P<service>\w+)`)
+	assert.Equal(t, "billing", serviceFromPattern(re, []byte("app=shop svc=billing done")))
+}
+
+func TestServiceFromAttribute(t *testing.T) {
+	assert.Equal(t, "payments", serviceFromAttribute("service", []byte(`{"service": "payments", "msg": "ok"}`)))
+	assert.Equal(t, "billing", serviceFromAttribute("meta.service", []byte(` {"meta": {"service": "billing"}}`)))
+	assert.Equal(t, "", serviceFromAttribute("meta.service", []byte(`{"meta": "billing"}`)))
+	assert.Equal(t, "", serviceFromAttribute("service", []byte(`{"service": 42}`)))
+	assert.Equal(t, "", serviceFromAttribute("service", []byte(`service=payments`)))
+	assert.Equal(t, "", serviceFromAttribute("service", []byte(`{"service": `)))
+}
+
+func TestAssignService(t *testing.T) {
+	source := &config.IntegrationConfigLogSource{
+		Service:          "default",
+		ServiceReg:       regexp.MustCompile(`^\[(\w+)\]`),
+		ServiceAttribute: "service",
+	}
+
+	msg := newNetworkMessage([]byte("[payments] charge accepted"), source)
+	assignService(msg)
+	assert.Equal(t, "payments", msg.GetService())
+
+	msg = newNetworkMessage([]byte(`{"service": "billing"}`), source)
+	assignService(msg)
+	assert.Equal(t, "billing", msg.GetService())
+
+	msg = newNetworkMessage([]byte("no service here"), source)
+	assignService(msg)
+	assert.Equal(t, "default", msg.GetService())
+}
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"fmt"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+)
+
+// defaultPriority is the syslog priority of the messages without a detected severity
+const defaultPriority = 46 // facility syslog, severity info
+
+// remapSeverity applies the first remap_severity rule of the source of msg matching it.
+// The severity is either the one detected by the input, or the priority of messages
+// already formatted as syslog, in which case the priority of the content is rewritten
+func remapSeverity(msg message.Message) {
+	for _, rule := range msg.GetOrigin().LogSource.ProcessingRules {
+		if rule.Type != config.REMAP_SEVERITY {
+			continue
+		}
+		priority, inContent := detectPriority(msg)
+		if rule.FromLevel != config.NoSeverity && rule.FromLevel != priority%8 {
+			continue
+		}
+		if rule.Reg != nil && !rule.Reg.Match(msg.Content()) {
+			continue
+		}
+		// keep the facility, change the severity
+		setPriority(msg, priority-priority%8+rule.SeverityLevel, inContent)
+		return
+	}
+}
+
+// detectPriority returns the syslog priority of msg, and whether it comes from its content
+func detectPriority(msg message.Message) (int, bool) {
+	if priority, _, ok := parsePriority(msg.Content()); ok {
+		return priority, true
+	}
+	if priority, _, ok := parsePriority(msg.GetSeverity()); ok {
+		return priority, false
+	}
+	return defaultPriority, false
+}
+
+// setPriority sets the syslog priority of msg
+func setPriority(msg message.Message, priority int, inContent bool) {
+	formatted := []byte(fmt.Sprintf("<%d>", priority))
+	if !inContent {
+		msg.SetSeverity(formatted)
+		return
+	}
+	_, length, _ := parsePriority(msg.Content())
+	msg.SetContent(append(formatted, msg.Content()[length:]...))
+}
+
+// parsePriority parses the <PRI> header at the beginning of b,
+// returning the priority and the length of the header
+func parsePriority(b []byte) (int, int, bool) {
+	if len(b) < 3 || b[0] != '<' {
+		return 0, 0, false
+	}
+	priority := 0
+	for i := 1; i < len(b) && i <= 4; i++ {
+		switch {
+		case b[i] == '>' && i > 1:
+			return priority, i + 1, priority <= 191
+		case b[i] >= '0' && b[i] <= '9':
+			priority = priority*10 + int(b[i]-'0')
+		default:
+			return 0, 0, false
+		}
+	}
+	return 0, 0, false
+}
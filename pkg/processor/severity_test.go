@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package processor
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRemapSeverityRule(pattern string, from, severity int) config.LogsProcessingRule {
+	rule := config.LogsProcessingRule{
+		Type:          config.REMAP_SEVERITY,
+		Name:          "remap",
+		FromLevel:     from,
+		SeverityLevel: severity,
+	}
+	if pattern != "" {
+		rule.Reg = regexp.MustCompile(pattern)
+	}
+	return rule
+}
+
+func TestParsePriority(t *testing.T) {
+	priority, length, ok := parsePriority([]byte("<46>0 message"))
+	assert.True(t, ok)
+	assert.Equal(t, 46, priority)
+	assert.Equal(t, 4, length)
+
+	priority, length, ok = parsePriority([]byte("<4>"))
+	assert.True(t, ok)
+	assert.Equal(t, 4, priority)
+	assert.Equal(t, 3, length)
+
+	for _, invalid := range []string{"", "<>", "<46", "<1234>", "<192>", "<a>", "46>"} {
+		_, _, ok = parsePriority([]byte(invalid))
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestRemapSeverityOnDetectedSeverity(t *testing.T) {
+	source := &config.IntegrationConfigLogSource{ProcessingRules: []config.LogsProcessingRule{
+		newRemapSeverityRule(`\bWARN(ING)?\b`, config.NoSeverity, 4),
+		newRemapSeverityRule("known noisy error", 3, 6),
+	}}
+
+	// messages without detected severity are info
+	msg := newNetworkMessage([]byte("WARNING: disk almost full"), source)
+	remapSeverity(msg)
+	assert.Equal(t, "<44>", string(msg.GetSeverity()))
+
+	msg = newNetworkMessage([]byte("a known noisy error"), source)
+	msg.SetSeverity(config.SEV_ERROR)
+	remapSeverity(msg)
+	assert.Equal(t, "<46>", string(msg.GetSeverity()))
+
+	// the from severity must match
+	msg = newNetworkMessage([]byte("a known noisy error"), source)
+	remapSeverity(msg)
+	assert.Nil(t, msg.GetSeverity())
+}
+
+func TestRemapSeverityOnSyslogMessages(t *testing.T) {
+	source := &config.IntegrationConfigLogSource{ProcessingRules: []config.LogsProcessingRule{
+		newRemapSeverityRule("", 4, 3),
+	}}
+
+	// syslog level 4 with facility local0
+	msg := newNetworkMessage([]byte("<132>1 2017-10-16T10:00:00Z host app - - - disk almost full"), source)
+	remapSeverity(msg)
+	assert.Equal(t, "<131>1 2017-10-16T10:00:00Z host app - - - disk almost full", string(msg.Content()))
+
+	msg = newNetworkMessage([]byte("<134>1 2017-10-16T10:00:00Z host app - - - all good"), source)
+	remapSeverity(msg)
+	assert.Equal(t, "<134>1 2017-10-16T10:00:00Z host app - - - all good", string(msg.Content()))
+}
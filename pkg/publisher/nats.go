@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package publisher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsConnect is the CONNECT message of the NATS protocol, acknowledgements
+// of each message being disabled
+const natsConnect = "CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"datadog-log-agent\"}\r\n"
+
+// A NATSSubject publishes payloads on a NATS subject
+type NATSSubject struct {
+	address string
+	subject string
+
+	conn  net.Conn
+	mutex sync.Mutex
+	// err is set when the server reported an error or closed the connection
+	err error
+}
+
+// NewNATSSubject returns a client of subject on the NATS server at address
+func NewNATSSubject(address, subject string) *NATSSubject {
+	return &NATSSubject{
+		address: address,
+		subject: subject,
+	}
+}
+
+// Connect opens a connection to the server and checks it accepts the client
+func (n *NATSSubject) Connect() error {
+	conn, err := net.DialTimeout("tcp", n.address, ioTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(ioTimeout))
+	reader := bufio.NewReader(conn)
+	info, err := readLine(reader)
+	if err == nil && !strings.HasPrefix(info, "INFO") {
+		err = fmt.Errorf("unexpected greeting: %s", info)
+	}
+	if err == nil {
+		_, err = conn.Write([]byte(natsConnect + "PING\r\n"))
+	}
+	if err == nil {
+		// the server answers the PING once it processed the CONNECT
+		err = expectPong(reader)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	n.mutex.Lock()
+	n.conn = conn
+	n.err = nil
+	n.mutex.Unlock()
+	go n.readForever(conn, reader)
+	return nil
+}
+
+// Publish sends payload on the subject
+func (n *NATSSubject) Publish(payload []byte) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	message := fmt.Sprintf("PUB %s %d\r\n%s\r\n", n.subject, len(payload), payload)
+	n.conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+	_, err := n.conn.Write([]byte(message))
+	return err
+}
+
+// Close closes the connection to the server
+func (n *NATSSubject) Close() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	return n.conn.Close()
+}
+
+// readForever answers the keepalives of the server and records its errors
+func (n *NATSSubject) readForever(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := readLine(reader)
+		n.mutex.Lock()
+		switch {
+		case err != nil:
+			n.err = err
+		case strings.HasPrefix(line, "PING"):
+			conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+			_, err = conn.Write([]byte("PONG\r\n"))
+		case strings.HasPrefix(line, "-ERR"):
+			n.err = errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		n.mutex.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// expectPong reads the answer to a PING, skipping the other messages
+func expectPong(reader *bufio.Reader) error {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package publisher
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveNATS accepts a client and returns the lines it sends
+func serveNATS(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	lines := make(chan string, 10)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if line == "PING" {
+				conn.Write([]byte("PONG\r\nPING\r\n"))
+			}
+			lines <- line
+		}
+	}()
+	return listener.Addr().String(), lines
+}
+
+func TestNATSSubjectPublish(t *testing.T) {
+	address, lines := serveNATS(t)
+	n := NewNATSSubject(address, "datadog.logs")
+	assert.Nil(t, n.Connect())
+
+	assert.True(t, strings.HasPrefix(<-lines, "CONNECT {"))
+	assert.Equal(t, "PING", <-lines)
+	// the client answers the keepalive of the server
+	assert.Equal(t, "PONG", <-lines)
+
+	assert.Nil(t, n.Publish([]byte(`{"message":"hello"}`)))
+	assert.Equal(t, "PUB datadog.logs 19", <-lines)
+	assert.Equal(t, `{"message":"hello"}`, <-lines)
+
+	assert.Nil(t, n.Close())
+}
+
+func TestNATSSubjectConnectFailsOnError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {}\r\n-ERR 'Authorization Violation'\r\n"))
+	}()
+	n := NewNATSSubject(listener.Addr().String(), "datadog.logs")
+	assert.EqualError(t, n.Connect(), "'Authorization Violation'")
+}
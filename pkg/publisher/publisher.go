@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package publisher
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+const (
+	defaultQueueSize   = 10000
+	defaultRetryPeriod = 5 * time.Second
+	// ioTimeout bounds the time spent connecting to or talking with a server
+	ioTimeout = 10 * time.Second
+)
+
+// droppedMessages counts the messages that couldn't be published, by publisher
+var droppedMessages = metrics.NewLabeledCounter("logs_publisher_dropped_messages")
+
+// A Client delivers payloads to a stream processing system
+type Client interface {
+	Connect() error
+	Publish(payload []byte) error
+	Close() error
+}
+
+// A Publisher publishes processed messages to a Client, for local consumers
+// to subscribe to them. It never blocks the pipelines: messages are queued
+// and dropped when the queue is full, for instance while the server is down
+type Publisher struct {
+	name        string
+	client      Client
+	inputChan   chan []byte
+	retryPeriod time.Duration
+
+	connected bool
+	mutex     sync.Mutex
+	lastError error
+}
+
+// New returns an initialized Publisher
+func New(name string, client Client) *Publisher {
+	return &Publisher{
+		name:        name,
+		client:      client,
+		inputChan:   make(chan []byte, defaultQueueSize),
+		retryPeriod: defaultRetryPeriod,
+	}
+}
+
+// Start starts the Publisher
+func (p *Publisher) Start() {
+	status.Register("publisher "+p.name, p.Status)
+	go p.run()
+}
+
+// Publish queues envelope for publication, dropping it if the queue is full
+func (p *Publisher) Publish(envelope *message.Envelope) {
+	payload, err := envelope.Encode()
+	if err != nil {
+		log.Println("Can't publish message to", p.name+":", err)
+		return
+	}
+	select {
+	case p.inputChan <- bytes.TrimSuffix(payload, []byte("\n")):
+	default:
+		droppedMessages.Add(p.name, 1)
+	}
+}
+
+// Status returns the state of the connection to the server
+func (p *Publisher) Status() interface{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.lastError != nil {
+		return fmt.Sprintf("disconnected: %v", p.lastError)
+	}
+	return "connected"
+}
+
+// run publishes the queued messages
+func (p *Publisher) run() {
+	for payload := range p.inputChan {
+		p.publish(payload)
+	}
+}
+
+// publish delivers payload, connecting the client again once when it fails
+func (p *Publisher) publish(payload []byte) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if !p.connected {
+			p.connect()
+		}
+		err := p.client.Publish(payload)
+		if err == nil {
+			return
+		}
+		p.setError(err)
+		p.client.Close()
+		p.connected = false
+	}
+	droppedMessages.Add(p.name, 1)
+}
+
+// connect connects the client, retrying until it succeeds
+func (p *Publisher) connect() {
+	for {
+		err := p.client.Connect()
+		p.setError(err)
+		if err == nil {
+			p.connected = true
+			return
+		}
+		time.Sleep(p.retryPeriod)
+	}
+}
+
+// setError records the outcome of the last operation, logging its changes
+func (p *Publisher) setError(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err != nil && p.lastError == nil {
+		log.Println("Can't publish messages to", p.name+":", err)
+	} else if err == nil && p.lastError != nil {
+		log.Println("Publishing messages to", p.name)
+	}
+	p.lastError = err
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package publisher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockClient fails to publish as many times as failures
+type mockClient struct {
+	connects  int
+	failures  int
+	published []string
+}
+
+func (c *mockClient) Connect() error {
+	c.connects++
+	return nil
+}
+
+func (c *mockClient) Publish(payload []byte) error {
+	if c.failures > 0 {
+		c.failures--
+		return errors.New("broken pipe")
+	}
+	c.published = append(c.published, string(payload))
+	return nil
+}
+
+func (c *mockClient) Close() error {
+	return nil
+}
+
+func droppedCount(name string) int64 {
+	return droppedMessages.Value(name)
+}
+
+func TestPublisherReconnects(t *testing.T) {
+	client := &mockClient{failures: 1}
+	p := New("reconnects", client)
+	p.publish([]byte("hello"))
+	assert.Equal(t, []string{"hello"}, client.published)
+	assert.Equal(t, 2, client.connects)
+	assert.Equal(t, "connected", p.Status())
+
+	client.failures = 2
+	p.publish([]byte("lost"))
+	assert.Equal(t, int64(1), droppedCount("reconnects"))
+	assert.Equal(t, "disconnected: broken pipe", p.Status())
+}
+
+func TestPublisherDropsWhenQueueIsFull(t *testing.T) {
+	p := New("full", &mockClient{})
+	p.inputChan = make(chan []byte, 1)
+	p.Publish(&message.Envelope{Message: "first"})
+	p.Publish(&message.Envelope{Message: "second"})
+	assert.Equal(t, int64(1), droppedCount("full"))
+
+	payload := <-p.inputChan
+	assert.Contains(t, string(payload), `"first"`)
+	assert.NotEqual(t, byte('\n'), payload[len(payload)-1])
+}
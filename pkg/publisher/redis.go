@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package publisher
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// A RedisStream appends payloads to a Redis stream with XADD,
+// capping the stream to about maxLen entries
+type RedisStream struct {
+	address string
+	key     string
+	maxLen  int64
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisStream returns a client of the stream key of the Redis server at address
+func NewRedisStream(address, key string, maxLen int64) *RedisStream {
+	return &RedisStream{
+		address: address,
+		key:     key,
+		maxLen:  maxLen,
+	}
+}
+
+// Connect opens a connection to the server
+func (r *RedisStream) Connect() error {
+	conn, err := net.DialTimeout("tcp", r.address, ioTimeout)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	r.reader = bufio.NewReader(conn)
+	return nil
+}
+
+// Publish adds payload to the stream as the message field of a new entry
+func (r *RedisStream) Publish(payload []byte) error {
+	args := [][]byte{[]byte("XADD"), []byte(r.key)}
+	if r.maxLen > 0 {
+		args = append(args, []byte("MAXLEN"), []byte("~"), []byte(strconv.FormatInt(r.maxLen, 10)))
+	}
+	args = append(args, []byte("*"), []byte("message"), payload)
+
+	r.conn.SetDeadline(time.Now().Add(ioTimeout))
+	_, err := r.conn.Write(encodeCommand(args))
+	if err != nil {
+		return err
+	}
+	return readReply(r.reader)
+}
+
+// Close closes the connection to the server
+func (r *RedisStream) Close() error {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.Close()
+}
+
+// encodeCommand returns a command in the Redis serialization protocol
+func encodeCommand(args [][]byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n", len(arg))
+		buf.Write(arg)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// readReply reads the reply to a command, returning the error it holds if any
+func readReply(reader *bufio.Reader) error {
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	if len(line) == 0 {
+		return errors.New("empty reply")
+	}
+	switch line[0] {
+	case '-':
+		return errors.New(line[1:])
+	case '$':
+		// the identifier of the new entry
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("invalid reply: %s", line)
+		}
+		if n < 0 {
+			return nil
+		}
+		_, err = reader.Discard(n + 2)
+		return err
+	case '+', ':':
+		return nil
+	default:
+		return fmt.Errorf("unexpected reply: %s", line)
+	}
+}
+
+// readLine reads a line terminated by \r\n and returns it without its terminator
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight([]byte(line), "\r\n")), nil
+}
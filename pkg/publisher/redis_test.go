@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package publisher
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveRedis answers the commands it receives with replies, returning the commands
+func serveRedis(t *testing.T, replies ...string) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	commands := make(chan string, len(replies))
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for _, reply := range replies {
+			buf := make([]byte, 4096)
+			n, err := reader.Read(buf)
+			if err != nil {
+				return
+			}
+			commands <- string(buf[:n])
+			conn.Write([]byte(reply))
+		}
+	}()
+	return listener.Addr().String(), commands
+}
+
+func TestRedisStreamPublish(t *testing.T) {
+	address, commands := serveRedis(t, "$15\r\n1508148000000-0\r\n", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+	r := NewRedisStream(address, "logs", 1000)
+	assert.Nil(t, r.Connect())
+	defer r.Close()
+
+	assert.Nil(t, r.Publish([]byte(`{"message":"hello"}`)))
+	assert.Equal(t, "*8\r\n$4\r\nXADD\r\n$4\r\nlogs\r\n$6\r\nMAXLEN\r\n$1\r\n~\r\n$4\r\n1000\r\n$1\r\n*\r\n$7\r\nmessage\r\n$19\r\n{\"message\":\"hello\"}\r\n", <-commands)
+
+	err := r.Publish([]byte("hello"))
+	assert.EqualError(t, err, "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func TestEncodeCommandWithoutMaxLen(t *testing.T) {
+	assert.Equal(t, "*2\r\n$4\r\nPING\r\n$0\r\n\r\n", string(encodeCommand([][]byte{[]byte("PING"), []byte("")})))
+}
@@ -13,6 +13,8 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/fault"
 )
 
 const (
@@ -26,6 +28,7 @@ type ConnectionManager struct {
 	connectionString    string
 	serverName          string
 	skip_ssl_validation bool
+	dialer              *Dialer
 
 	mutex   sync.Mutex
 	retries int
@@ -34,11 +37,12 @@ type ConnectionManager struct {
 }
 
 // NewConnectionManager returns an initialized ConnectionManager
-func NewConnectionManager(ddUrl string, ddPort int, skip_ssl_validation bool) *ConnectionManager {
+func NewConnectionManager(ddUrl string, ddPort int, skip_ssl_validation bool, dialer *Dialer) *ConnectionManager {
 	return &ConnectionManager{
 		connectionString:    fmt.Sprintf("%s:%d", ddUrl, ddPort),
 		serverName:          ddUrl,
 		skip_ssl_validation: skip_ssl_validation,
+		dialer:              dialer,
 
 		mutex: sync.Mutex{},
 
@@ -59,7 +63,7 @@ func (cm *ConnectionManager) NewConnection() net.Conn {
 		}
 
 		cm.retries += 1
-		outConn, err := net.DialTimeout("tcp", cm.connectionString, timeout)
+		outConn, err := cm.dialer.Dial("tcp", cm.connectionString)
 		if err != nil {
 			log.Println(err)
 			cm.backoff()
@@ -81,11 +85,23 @@ func (cm *ConnectionManager) NewConnection() net.Conn {
 		}
 
 		cm.retries = 0
+		outConn = fault.WrapConn(outConn)
 		go cm.handleServerClose(outConn)
 		return outConn
 	}
 }
 
+// Dialer returns the dialer used to open connections to the intake
+func (cm *ConnectionManager) Dialer() *Dialer {
+	return cm.dialer
+}
+
+// IsStale returns true when the intake hostname doesn't resolve
+// to the address conn is connected to anymore
+func (cm *ConnectionManager) IsStale(conn net.Conn) bool {
+	return cm.dialer.IsStale(conn, cm.serverName)
+}
+
 // CloseConnection closes a connection on the client side
 func (cm *ConnectionManager) CloseConnection(conn net.Conn) {
 	conn.Close()
@@ -0,0 +1,243 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DNS resolution strategies
+const (
+	DNSStrategyAny        = "any"
+	DNSStrategyPreferIPv4 = "prefer_ipv4"
+	DNSStrategyPreferIPv6 = "prefer_ipv6"
+	DNSStrategyIPv4Only   = "ipv4_only"
+	DNSStrategyIPv6Only   = "ipv6_only"
+)
+
+// A Dialer opens outgoing connections to the intake. It can bind connections
+// to a source IP or interface, for multi-homed hosts or policy routing,
+// and resolves the intake hostname according to a DNS strategy,
+// optionally using a custom resolver.
+// The hostname is resolved again on every connection, and long-lived
+// connections should be checked with IsStale every RefreshInterval
+type Dialer struct {
+	localIPs        []net.IP
+	strategy        string
+	resolver        *net.Resolver
+	timeout         time.Duration
+	refreshInterval time.Duration
+}
+
+// NewDialer returns an initialized Dialer.
+// sourceIP and sourceInterface are exclusive, resolverAddr is a host:port
+// of the DNS server to use instead of the system one; all of them are optional.
+// As the system resolver doesn't expose the TTL of the records,
+// refreshInterval is the maximum time we trust a resolved address
+func NewDialer(sourceIP, sourceInterface, strategy, resolverAddr string, refreshInterval time.Duration) (*Dialer, error) {
+	d := &Dialer{
+		strategy:        strategy,
+		resolver:        net.DefaultResolver,
+		timeout:         timeout,
+		refreshInterval: refreshInterval,
+	}
+
+	switch d.strategy {
+	case "":
+		d.strategy = DNSStrategyAny
+	case DNSStrategyAny, DNSStrategyPreferIPv4, DNSStrategyPreferIPv6, DNSStrategyIPv4Only, DNSStrategyIPv6Only:
+	default:
+		return nil, fmt.Errorf("unknown dns strategy %s", strategy)
+	}
+
+	switch {
+	case sourceIP != "" && sourceInterface != "":
+		return nil, fmt.Errorf("source ip and source interface can't be set together")
+	case sourceIP != "":
+		ip := net.ParseIP(sourceIP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source ip %s", sourceIP)
+		}
+		d.localIPs = []net.IP{ip}
+	case sourceInterface != "":
+		ips, err := interfaceIPs(sourceInterface)
+		if err != nil {
+			return nil, err
+		}
+		d.localIPs = ips
+	}
+
+	if resolverAddr != "" {
+		if _, _, err := net.SplitHostPort(resolverAddr); err != nil {
+			return nil, fmt.Errorf("invalid dns resolver %s: %v", resolverAddr, err)
+		}
+		d.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, resolverAddr)
+			},
+		}
+	}
+	return d, nil
+}
+
+// interfaceIPs returns the IPs bound to the network interface named name
+func interfaceIPs(name string) ([]net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no ip bound to interface %s", name)
+	}
+	return ips, nil
+}
+
+// Dial connects to address, trying all the resolved IPs in order until one succeeds
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// DialContext connects to address, trying all the resolved IPs in order until one succeeds
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		dialer := net.Dialer{Timeout: d.timeout}
+		if localIP := d.localIPFor(ip); localIP != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+		} else if len(d.localIPs) > 0 {
+			lastErr = fmt.Errorf("no source ip available to reach %s", ip)
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// IsReachable returns true if a connection to address can be opened
+func (d *Dialer) IsReachable(address string) bool {
+	conn, err := d.Dial("tcp", address)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// RefreshInterval returns how often long-lived connections should be checked
+func (d *Dialer) RefreshInterval() time.Duration {
+	return d.refreshInterval
+}
+
+// IsStale returns true when the remote address of conn is no longer
+// one of the addresses host resolves to, in which case the connection
+// should be closed and opened again. When the resolution fails,
+// the connection is considered valid
+func (d *Dialer) IsStale(conn net.Conn, host string) bool {
+	remote, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	ips, err := d.resolve(ctx, host)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if ip.Equal(remote.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns the IPs of host, ordered and filtered according to the dns strategy
+func (d *Dialer) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := d.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	ips = sortIPs(ips, d.strategy)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no address matching dns strategy %s for %s", d.strategy, host)
+	}
+	return ips, nil
+}
+
+// localIPFor returns the source ip to use to reach remote, nil if none is configured
+func (d *Dialer) localIPFor(remote net.IP) net.IP {
+	for _, ip := range d.localIPs {
+		if isIPv4(ip) == isIPv4(remote) {
+			return ip
+		}
+	}
+	return nil
+}
+
+// sortIPs orders and filters ips according to strategy
+func sortIPs(ips []net.IP, strategy string) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if isIPv4(ip) {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch strategy {
+	case DNSStrategyPreferIPv4:
+		return append(v4, v6...)
+	case DNSStrategyPreferIPv6:
+		return append(v6, v4...)
+	case DNSStrategyIPv4Only:
+		return v4
+	case DNSStrategyIPv6Only:
+		return v6
+	default:
+		return ips
+	}
+}
+
+func isIPv4(ip net.IP) bool {
+	return ip.To4() != nil
+}
@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDialer(t *testing.T) {
+	d, err := NewDialer("", "", "", "", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, DNSStrategyAny, d.strategy)
+
+	_, err = NewDialer("", "", "whatever", "", 0)
+	assert.NotNil(t, err)
+
+	_, err = NewDialer("not an ip", "", "", "", 0)
+	assert.NotNil(t, err)
+
+	_, err = NewDialer("127.0.0.1", "lo", "", "", 0)
+	assert.NotNil(t, err)
+
+	_, err = NewDialer("", "", "", "8.8.8.8", 0)
+	assert.NotNil(t, err)
+
+	d, err = NewDialer("127.0.0.1", "", DNSStrategyPreferIPv4, "8.8.8.8:53", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, []net.IP{net.ParseIP("127.0.0.1")}, d.localIPs)
+	assert.Equal(t, time.Minute, d.RefreshInterval())
+}
+
+func TestSortIPs(t *testing.T) {
+	v4 := net.ParseIP("10.0.0.1")
+	v6 := net.ParseIP("::1")
+	ips := []net.IP{v6, v4}
+	assert.Equal(t, []net.IP{v6, v4}, sortIPs(ips, DNSStrategyAny))
+	assert.Equal(t, []net.IP{v4, v6}, sortIPs(ips, DNSStrategyPreferIPv4))
+	assert.Equal(t, []net.IP{v6, v4}, sortIPs(ips, DNSStrategyPreferIPv6))
+	assert.Equal(t, []net.IP{v4}, sortIPs(ips, DNSStrategyIPv4Only))
+	assert.Equal(t, []net.IP{v6}, sortIPs(ips, DNSStrategyIPv6Only))
+}
+
+func TestDialerBindsSourceIP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	d, err := NewDialer("127.0.0.1", "", "", "", 0)
+	assert.Nil(t, err)
+	conn, err := d.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1", conn.LocalAddr().(*net.TCPAddr).IP.String())
+	conn.Close()
+
+	d, err = NewDialer("", "", DNSStrategyIPv6Only, "", 0)
+	assert.Nil(t, err)
+	_, err = d.Dial("tcp", l.Addr().String())
+	assert.NotNil(t, err)
+}
+
+func TestDialerIsStale(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	d, err := NewDialer("", "", "", "", time.Minute)
+	assert.Nil(t, err)
+	conn, err := d.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	assert.False(t, d.IsStale(conn, "127.0.0.1"))
+	assert.True(t, d.IsStale(conn, "127.0.0.2"))
+	// resolution failures keep the connection
+	assert.False(t, d.IsStale(conn, "unknown.invalid"))
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+//go:build faultinjection
+// +build faultinjection
+
+package sender
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/fault"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// startFaultTestIntake returns a ConnectionManager to an intake
+// forwarding the lines it receives, over any number of connections
+func startFaultTestIntake(t *testing.T) (*ConnectionManager, chan string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+	dialer, err := NewDialer("", "", "", "", 0)
+	assert.Nil(t, err)
+	cm := NewConnectionManager("127.0.0.1", listener.Addr().(*net.TCPAddr).Port, true, dialer)
+	return cm, lines, func() { listener.Close() }
+}
+
+func TestSenderDeliversAllMessagesWhenConnectionsDrop(t *testing.T) {
+	cm, lines, stop := startFaultTestIntake(t)
+	defer stop()
+	// each connection is dropped in the middle of its second message
+	fault.Set(fault.Faults{DropConnectionAfterBytes: 15})
+	defer fault.Reset()
+
+	inputChan := make(chan message.Message, 3)
+	outputChan := make(chan message.Message, 3)
+	New(inputChan, outputChan, cm).Start()
+	for i := 0; i < 3; i++ {
+		inputChan <- message.NewMessage([]byte(fmt.Sprintf("message %d\n", i)))
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-outputChan:
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not acknowledged")
+		}
+	}
+
+	// at least once: every message is fully received, the partial writes being lost
+	expected := map[string]bool{"message 0\n": true, "message 1\n": true, "message 2\n": true}
+	for len(expected) > 0 {
+		select {
+		case line := <-lines:
+			assert.True(t, strings.HasPrefix(line, "message "), line)
+			delete(expected, line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("messages not received: %v", expected)
+		}
+	}
+}
+
+func TestSenderAcknowledgesMessagesOnlyOnceSent(t *testing.T) {
+	cm, lines, stop := startFaultTestIntake(t)
+	defer stop()
+	fault.Set(fault.Faults{AckDelay: 200 * time.Millisecond})
+	defer fault.Reset()
+
+	inputChan := make(chan message.Message, 1)
+	outputChan := make(chan message.Message, 1)
+	New(inputChan, outputChan, cm).Start()
+	inputChan <- message.NewMessage([]byte("hello\n"))
+
+	assert.Equal(t, "hello\n", <-lines)
+	// the message was sent but not acknowledged yet: a crash now replays it
+	assert.Equal(t, 0, len(outputChan))
+	select {
+	case <-outputChan:
+	case <-time.After(time.Second):
+		t.Fatal("message not acknowledged")
+	}
+}
@@ -0,0 +1,381 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
+	"github.com/DataDog/datadog-log-agent/pkg/deadletter"
+	"github.com/DataDog/datadog-log-agent/pkg/fault"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+)
+
+const (
+	defaultBatchSize   = 100
+	defaultBatchWait   = 5 * time.Second
+	httpRequestTimeout = 20 * time.Second
+)
+
+// Reasons for which a message can be dropped by the HTTPSender
+const (
+	dropReasonOversize         = "oversize"
+	dropReasonRejected         = "rejected"
+	dropReasonRetriesExhausted = "retries_exhausted"
+)
+
+// droppedMessages counts messages the HTTPSender gave up on, by reason
+var droppedMessages = metrics.NewLabeledCounter("logs_sender_dropped_messages")
+
+// DroppedMessages returns the number of messages the HTTPSender gave up on
+func DroppedMessages() int64 {
+	return droppedMessages.Total()
+}
+
+// deadlineFlushes counts the batches sent early for their oldest message
+// reaching the latency budget
+var deadlineFlushes = metrics.NewCounter("logs_sender_deadline_flushes")
+
+// sendStatus represents how the intake handled a batch
+type sendStatus int
+
+const (
+	sendSuccess sendStatus = iota
+	sendTooLarge
+	sendRetryable
+	sendThrottled
+	sendRejected
+)
+
+// pendingMessage is a message waiting to be sent, along with
+// the number of times we already tried to send it
+type pendingMessage struct {
+	msg     message.Message
+	retries int
+}
+
+// An HTTPSender sends batches of messages from an inputChan to datadog's http intake.
+// When a batch partially fails, it is split and retried, each message
+// having its own retry budget, so a single bad message can't stall the pipeline
+type HTTPSender struct {
+	inputChan  chan message.Message
+	outputChan chan message.Message
+	client     *http.Client
+	url        string
+	batchSize  int
+	batchWait  time.Duration
+	maxRetries int
+	throttler  *Throttler
+	intake     *intakeStatus
+	dialer     *Dialer
+	// codec compresses the batches when set
+	codec compression.Codec
+	// latencyBudget is how long a message can stay in the pipeline before
+	// its batch is sent, whatever its size; 0 for no budget
+	latencyBudget time.Duration
+
+	backoff func(attempt int)
+}
+
+// NewHTTPSender returns an initialized HTTPSender
+func NewHTTPSender(inputChan, outputChan chan message.Message, url string, maxRetries int, dialer *Dialer) *HTTPSender {
+	client := &http.Client{Timeout: httpRequestTimeout}
+	if dialer != nil {
+		client.Transport = &http.Transport{
+			Proxy:       http.ProxyFromEnvironment,
+			DialContext: dialer.DialContext,
+		}
+	}
+	return &HTTPSender{
+		inputChan:  inputChan,
+		outputChan: outputChan,
+		client:     client,
+		url:        url,
+		batchSize:  defaultBatchSize,
+		batchWait:  defaultBatchWait,
+		maxRetries: maxRetries,
+		throttler:  GetThrottler(url),
+		intake:     getIntakeStatus(url),
+		dialer:     dialer,
+		backoff:    sleepBackoff,
+	}
+}
+
+// Compress makes the HTTPSender compress the batches it sends with codec.
+// It must be called before Start
+func (s *HTTPSender) Compress(codec compression.Codec) {
+	s.codec = codec
+}
+
+// SetLatencyBudget makes the HTTPSender send a batch as soon as one of its
+// messages has been in the pipeline for budget, so that the logs of low volume
+// sources don't wait for the batch to fill up. It must be called before Start
+func (s *HTTPSender) SetLatencyBudget(budget time.Duration) {
+	s.latencyBudget = budget
+}
+
+// Start starts the HTTPSender
+func (s *HTTPSender) Start() {
+	go s.run()
+}
+
+// run accumulates messages in batches and sends them when a batch is full,
+// when it has been waiting for too long or when its oldest message reaches
+// the latency budget
+func (s *HTTPSender) run() {
+	batch := []*pendingMessage{}
+	ticker := time.NewTicker(s.batchWait)
+	defer ticker.Stop()
+	dnsTicker := s.newDNSTicker()
+	defer dnsTicker.Stop()
+	deadlineTimer := time.NewTimer(time.Hour)
+	stopTimer(deadlineTimer)
+	defer deadlineTimer.Stop()
+	var deadline time.Time
+	flush := func() {
+		s.sendBatch(batch)
+		batch = []*pendingMessage{}
+		deadline = time.Time{}
+		stopTimer(deadlineTimer)
+	}
+	for {
+		select {
+		case <-dnsTicker.C:
+			// idle connections will be opened again, resolving the intake hostname
+			if transport, ok := s.client.Transport.(*http.Transport); ok {
+				transport.CloseIdleConnections()
+			}
+		case msg, ok := <-s.inputChan:
+			if !ok {
+				s.sendBatch(batch)
+				return
+			}
+			if message.IsEndOfStream(msg) {
+				// the stream is complete once its last batch is sent
+				flush()
+				s.outputChan <- msg
+				continue
+			}
+			batch = append(batch, &pendingMessage{msg: msg})
+			if len(batch) >= s.batchSize {
+				flush()
+				continue
+			}
+			if due, ok := s.deadline(msg); ok && (deadline.IsZero() || due.Before(deadline)) {
+				deadline = due
+				stopTimer(deadlineTimer)
+				deadlineTimer.Reset(time.Until(due))
+			}
+		case <-deadlineTimer.C:
+			if len(batch) > 0 {
+				deadlineFlushes.Add(1)
+				s.sendBatch(batch)
+				batch = []*pendingMessage{}
+			}
+			deadline = time.Time{}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				flush()
+			}
+		}
+	}
+}
+
+// deadline returns when the batch of msg must be sent for msg to stay within
+// the latency budget, if there is a budget and msg tells when it was received
+func (s *HTTPSender) deadline(msg message.Message) (time.Time, bool) {
+	origin := msg.GetOrigin()
+	if s.latencyBudget <= 0 || origin == nil || origin.ReceivedAt.IsZero() {
+		return time.Time{}, false
+	}
+	return origin.ReceivedAt.Add(s.latencyBudget), true
+}
+
+// stopTimer stops t and drains its channel, so that it can be reset
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
+
+// newDNSTicker returns a ticker firing when kept-alive connections should be
+// renewed, or a ticker that never fires when no refresh is configured
+func (s *HTTPSender) newDNSTicker() *time.Ticker {
+	if s.dialer == nil || s.dialer.RefreshInterval() <= 0 {
+		t := time.NewTicker(time.Hour)
+		t.Stop()
+		return t
+	}
+	return time.NewTicker(s.dialer.RefreshInterval())
+}
+
+// sendBatch sends a batch to the intake until all of its messages
+// are either delivered or dropped
+func (s *HTTPSender) sendBatch(batch []*pendingMessage) {
+	for attempt := 1; len(batch) > 0; attempt++ {
+		s.throttler.Wait()
+		result, retryAfter := s.post(batch)
+		switch result {
+		case sendSuccess:
+			s.throttler.Reset()
+			s.forward(batch)
+			return
+		case sendTooLarge:
+			if len(batch) == 1 {
+				s.drop(batch, dropReasonOversize)
+				return
+			}
+			s.split(batch)
+			return
+		case sendRejected:
+			s.drop(batch, dropReasonRejected)
+			return
+		case sendRetryable:
+			batch = s.consumeRetry(batch)
+			if len(batch) > 1 {
+				// isolate the messages the intake may choke on
+				s.backoff(attempt)
+				s.split(batch)
+				return
+			}
+		case sendThrottled:
+			// the intake asks us to slow down: pause all the senders of this
+			// destination, the pause replacing the backoff. The attempt still
+			// consumes the retry budget, as the intake may never accept the batch
+			s.throttler.Throttle(retryAfter)
+			batch = s.consumeRetry(batch)
+			continue
+		}
+		if len(batch) > 0 {
+			s.backoff(attempt)
+		}
+	}
+}
+
+// split sends both halves of a batch separately
+func (s *HTTPSender) split(batch []*pendingMessage) {
+	middle := len(batch) / 2
+	s.sendBatch(batch[:middle])
+	s.sendBatch(batch[middle:])
+}
+
+// consumeRetry decrements the retry budget of every message in batch,
+// drops the messages that exhausted their budget and returns the others
+func (s *HTTPSender) consumeRetry(batch []*pendingMessage) []*pendingMessage {
+	remaining := []*pendingMessage{}
+	exhausted := []*pendingMessage{}
+	for _, pending := range batch {
+		pending.retries++
+		if pending.retries > s.maxRetries {
+			exhausted = append(exhausted, pending)
+		} else {
+			remaining = append(remaining, pending)
+		}
+	}
+	s.drop(exhausted, dropReasonRetriesExhausted)
+	return remaining
+}
+
+// post sends the content of batch to the intake and classifies the response,
+// also returning for how long the intake asks us to wait when relevant
+func (s *HTTPSender) post(batch []*pendingMessage) (sendStatus, time.Duration) {
+	var body bytes.Buffer
+	for _, pending := range batch {
+		body.Write(pending.msg.Content())
+	}
+	payload := body.Bytes()
+	if s.codec != nil {
+		compressed, err := s.codec.Compress(payload)
+		if err != nil {
+			log.Println("Can't compress batch:", err)
+			return sendRejected, 0
+		}
+		payload = compressed
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(payload))
+	if err != nil {
+		log.Println(err)
+		return sendRejected, 0
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if s.codec != nil {
+		req.Header.Set("Content-Encoding", s.codec.ContentEncoding())
+	}
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	sendLatency.ObserveSince(start)
+	if err != nil {
+		s.intake.reportError(err)
+		return sendRetryable, 0
+	}
+	resp.Body.Close()
+	s.intake.reportResponse(resp.StatusCode)
+	return classifyStatusCode(resp.StatusCode), parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+}
+
+// classifyStatusCode returns how a batch should be handled given the intake response code
+func classifyStatusCode(statusCode int) sendStatus {
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return sendSuccess
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return sendTooLarge
+	case statusCode == http.StatusTooManyRequests:
+		return sendThrottled
+	case statusCode >= 500, statusCode == http.StatusRequestTimeout:
+		return sendRetryable
+	default:
+		return sendRejected
+	}
+}
+
+// forward notifies the auditor that messages were successfully sent
+func (s *HTTPSender) forward(batch []*pendingMessage) {
+	fault.DelayAck()
+	for _, pending := range batch {
+		s.outputChan <- pending.msg
+	}
+}
+
+// drop counts the messages we give up on. They are still forwarded
+// to the auditor so that their offsets are committed and we don't
+// try to send them again on restart
+func (s *HTTPSender) drop(batch []*pendingMessage, reason string) {
+	if len(batch) == 0 {
+		return
+	}
+	log.Println("Dropping", len(batch), "messages:", reason)
+	droppedMessages.Add(reason, int64(len(batch)))
+	for _, pending := range batch {
+		deadletter.Write(reason, pending.msg, WithoutAPIKey(pending.msg.Content()))
+		pending.msg.GetOrigin().Acknowledge(false)
+	}
+	s.forward(batch)
+}
+
+// WithoutAPIKey returns payload without the api key it starts with, nor its end of line
+func WithoutAPIKey(payload []byte) []byte {
+	if i := bytes.IndexByte(payload, ' '); i >= 0 {
+		payload = payload[i+1:]
+	}
+	return bytes.TrimSuffix(payload, []byte{'\n'})
+}
+
+// sleepBackoff sleeps longer and longer as attempts increase
+func sleepBackoff(attempt int) {
+	backoffDuration := backoffSleepTimeUnit * attempt
+	if backoffDuration > maxBackoffSleepTime {
+		backoffDuration = maxBackoffSleepTime
+	}
+	time.Sleep(time.Second * time.Duration(backoffDuration))
+}
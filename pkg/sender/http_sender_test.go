@@ -0,0 +1,247 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
+	"github.com/DataDog/datadog-log-agent/pkg/deadletter"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPSenderTestSuite struct {
+	suite.Suite
+
+	server     *httptest.Server
+	mu         sync.Mutex
+	bodies     []string
+	handler    func(body string) int
+	outputChan chan message.Message
+	s          *HTTPSender
+}
+
+func (suite *HTTPSenderTestSuite) SetupTest() {
+	suite.bodies = []string{}
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		suite.mu.Lock()
+		suite.bodies = append(suite.bodies, string(b))
+		suite.mu.Unlock()
+		w.WriteHeader(suite.handler(string(b)))
+	}))
+	suite.outputChan = make(chan message.Message, 10)
+	suite.s = NewHTTPSender(nil, suite.outputChan, suite.server.URL, 2, nil)
+	suite.s.backoff = func(int) {}
+}
+
+func (suite *HTTPSenderTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *HTTPSenderTestSuite) newBatch(contents ...string) []*pendingMessage {
+	batch := []*pendingMessage{}
+	for _, content := range contents {
+		batch = append(batch, &pendingMessage{msg: message.NewMessage([]byte(content))})
+	}
+	return batch
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchSucceeds() {
+	suite.handler = func(string) int { return http.StatusOK }
+	suite.s.sendBatch(suite.newBatch("a\n", "b\n"))
+	suite.Equal([]string{"a\nb\n"}, suite.bodies)
+	suite.Equal(2, len(suite.outputChan))
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchCompresses() {
+	suite.handler = func(string) int { return http.StatusOK }
+	codec, err := compression.New(compression.KindGzip, 0)
+	suite.Nil(err)
+	suite.s.Compress(codec)
+	suite.s.sendBatch(suite.newBatch("a\n", "b\n"))
+	suite.Equal(1, len(suite.bodies))
+	body, err := compression.Decompress([]byte(suite.bodies[0]))
+	suite.Nil(err)
+	suite.Equal("a\nb\n", string(body))
+	suite.Equal(2, len(suite.outputChan))
+}
+
+func (suite *HTTPSenderTestSuite) TestEndOfStreamFlushesBatch() {
+	suite.handler = func(string) int { return http.StatusOK }
+	inputChan := make(chan message.Message, 10)
+	suite.s.inputChan = inputChan
+	suite.s.batchWait = time.Hour
+	suite.s.Start()
+	inputChan <- message.NewMessage([]byte("a\n"))
+	inputChan <- message.NewEndOfStreamMessage(message.NewOrigin())
+
+	suite.Equal("a\n", string((<-suite.outputChan).Content()))
+	suite.True(message.IsEndOfStream(<-suite.outputChan))
+	suite.mu.Lock()
+	suite.Equal([]string{"a\n"}, suite.bodies)
+	suite.mu.Unlock()
+	close(inputChan)
+}
+
+func (suite *HTTPSenderTestSuite) TestLatencyBudgetFlushesBatch() {
+	suite.handler = func(string) int { return http.StatusOK }
+	inputChan := make(chan message.Message, 10)
+	suite.s.inputChan = inputChan
+	suite.s.batchWait = time.Hour
+	suite.s.SetLatencyBudget(50 * time.Millisecond)
+	suite.s.Start()
+	defer close(inputChan)
+	before := deadlineFlushes.Value()
+
+	msg := message.NewMessage([]byte("a\n"))
+	msg.SetOrigin(message.NewOrigin())
+	inputChan <- msg
+	select {
+	case <-suite.outputChan:
+	case <-time.After(5 * time.Second):
+		suite.Fail("the batch wasn't sent within the latency budget")
+	}
+
+	// a message received before the budget is sent right away
+	msg = message.NewMessage([]byte("b\n"))
+	origin := message.NewOrigin()
+	origin.ReceivedAt = time.Now().Add(-time.Minute)
+	msg.SetOrigin(origin)
+	inputChan <- msg
+	suite.Equal("b\n", string((<-suite.outputChan).Content()))
+	suite.Equal(int64(2), deadlineFlushes.Value()-before)
+	suite.mu.Lock()
+	suite.Equal([]string{"a\n", "b\n"}, suite.bodies)
+	suite.mu.Unlock()
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchSplitsWhenTooLarge() {
+	suite.handler = func(body string) int {
+		if strings.Count(body, "\n") > 1 {
+			return http.StatusRequestEntityTooLarge
+		}
+		return http.StatusOK
+	}
+	suite.s.sendBatch(suite.newBatch("a\n", "b\n", "c\n"))
+	suite.Equal([]string{"a\nb\nc\n", "a\n", "b\nc\n", "b\n", "c\n"}, suite.bodies)
+	suite.Equal(3, len(suite.outputChan))
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchIsolatesAndDropsFailingMessage() {
+	before := droppedCount(dropReasonRetriesExhausted)
+	suite.handler = func(body string) int {
+		if strings.Contains(body, "poison") {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	}
+	suite.s.sendBatch(suite.newBatch("a\n", "poison\n"))
+	// the poison message is isolated then retried until its budget is exhausted
+	suite.Equal([]string{"a\npoison\n", "a\n", "poison\n", "poison\n"}, suite.bodies)
+	suite.Equal(int64(1), droppedCount(dropReasonRetriesExhausted)-before)
+	// both messages are forwarded to the auditor
+	suite.Equal(2, len(suite.outputChan))
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchDropsRejectedMessages() {
+	before := droppedCount(dropReasonRejected)
+	suite.handler = func(string) int { return http.StatusForbidden }
+	suite.s.sendBatch(suite.newBatch("a\n", "b\n"))
+	suite.Equal(1, len(suite.bodies))
+	suite.Equal(int64(2), droppedCount(dropReasonRejected)-before)
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchWritesDroppedMessagesToDeadLetterFile() {
+	dir, err := ioutil.TempDir("", "deadletter")
+	suite.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dead_letters.json")
+	suite.Nil(deadletter.Open(path, 0))
+	defer deadletter.Close()
+
+	suite.handler = func(string) int { return http.StatusRequestEntityTooLarge }
+	suite.s.sendBatch(suite.newBatch("apikey/logset <46>0 2017-10-16T10:00:00Z host app - - - huge\n"))
+	content, err := ioutil.ReadFile(path)
+	suite.Nil(err)
+	suite.Contains(string(content), `"reason":"oversize"`)
+	suite.Contains(string(content), `"message":"\u003c46\u003e0 2017-10-16T10:00:00Z host app - - - huge"`)
+	suite.NotContains(string(content), "apikey")
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchIsThrottled() {
+	suite.s.throttler = NewThrottler("test")
+	suite.s.throttler.nextDuration = time.Millisecond
+	calls := 0
+	suite.handler = func(string) int {
+		calls++
+		if calls < 3 {
+			return http.StatusTooManyRequests
+		}
+		return http.StatusOK
+	}
+	batch := suite.newBatch("a\n")
+	suite.s.sendBatch(batch)
+	suite.Equal(3, len(suite.bodies))
+	suite.Equal(1, len(suite.outputChan))
+	// the throttled attempts consume the retry budget
+	suite.Equal(2, batch[0].retries)
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchDropsMessagesAlwaysThrottled() {
+	before := droppedCount(dropReasonRetriesExhausted)
+	suite.s.throttler = NewThrottler("test")
+	suite.s.throttler.nextDuration = time.Millisecond
+	suite.handler = func(string) int { return http.StatusTooManyRequests }
+	suite.s.sendBatch(suite.newBatch("a\n", "b\n"))
+	// the batch is sent once, then retried until the budget is exhausted
+	suite.Equal(3, len(suite.bodies))
+	suite.Equal(int64(2), droppedCount(dropReasonRetriesExhausted)-before)
+	suite.Equal(2, len(suite.outputChan))
+}
+
+func (suite *HTTPSenderTestSuite) TestClassifyStatusCode() {
+	suite.Equal(sendSuccess, classifyStatusCode(200))
+	suite.Equal(sendTooLarge, classifyStatusCode(413))
+	suite.Equal(sendThrottled, classifyStatusCode(429))
+	suite.Equal(sendRetryable, classifyStatusCode(503))
+	suite.Equal(sendRejected, classifyStatusCode(400))
+}
+
+func (suite *HTTPSenderTestSuite) TestSendBatchReportsInvalidAPIKey() {
+	suite.s.intake = &intakeStatus{destination: "test"}
+	suite.handler = func(string) int { return http.StatusForbidden }
+	suite.s.sendBatch(suite.newBatch("a\n"))
+	suite.Equal("API key invalid: check api_key (HTTP 403)", suite.s.intake.Status())
+
+	suite.handler = func(string) int { return http.StatusOK }
+	suite.s.sendBatch(suite.newBatch("a\n"))
+	suite.Equal("OK", suite.s.intake.Status())
+}
+
+func (suite *HTTPSenderTestSuite) TestDescribeResponse() {
+	suite.Equal("OK", describeResponse(202))
+	suite.Contains(describeResponse(413), "payload too large")
+	suite.Contains(describeResponse(429), "quota exceeded")
+	suite.Equal("HTTP 400 Bad Request", describeResponse(400))
+}
+
+func droppedCount(reason string) int64 {
+	return droppedMessages.Value(reason)
+}
+
+func TestHTTPSenderTestSuite(t *testing.T) {
+	suite.Run(t, new(HTTPSenderTestSuite))
+}
@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+// intakeHints tell what to do about the intake responses calling for a user action
+var intakeHints = map[int]string{
+	http.StatusUnauthorized:          "API key invalid: check api_key",
+	http.StatusForbidden:             "API key invalid: check api_key",
+	http.StatusRequestEntityTooLarge: "payload too large: messages over the intake size limit are dropped, lower the line limits",
+	http.StatusTooManyRequests:       "quota exceeded: the intake throttles the agent, logs are delayed",
+}
+
+// An intakeStatus reports the last outcome of the requests to a destination,
+// with a hint when the user can fix it
+type intakeStatus struct {
+	destination string
+	mutex       sync.Mutex
+	last        string
+}
+
+var (
+	intakeStatuses      = make(map[string]*intakeStatus)
+	intakeStatusesMutex = &sync.Mutex{}
+)
+
+// getIntakeStatus returns the intakeStatus shared by all the senders of a destination
+func getIntakeStatus(destination string) *intakeStatus {
+	intakeStatusesMutex.Lock()
+	defer intakeStatusesMutex.Unlock()
+	s, ok := intakeStatuses[destination]
+	if !ok {
+		s = &intakeStatus{destination: destination}
+		intakeStatuses[destination] = s
+		status.Register("intake "+destination, s.Status)
+	}
+	return s
+}
+
+// reportResponse records the status code of a response of the intake
+func (s *intakeStatus) reportResponse(statusCode int) {
+	s.report(describeResponse(statusCode))
+}
+
+// reportError records a request that got no response
+func (s *intakeStatus) reportError(err error) {
+	s.report(fmt.Sprintf("unreachable: %v", err))
+}
+
+// report records the outcome of a request, logging it when it changes
+func (s *intakeStatus) report(outcome string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if outcome == s.last {
+		return
+	}
+	s.last = outcome
+	log.Println("Intake", s.destination, "status:", outcome)
+}
+
+// Status returns the last outcome of the requests to the intake
+func (s *intakeStatus) Status() interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.last
+}
+
+// describeResponse returns the outcome of a request answered with statusCode
+func describeResponse(statusCode int) string {
+	if hint, ok := intakeHints[statusCode]; ok {
+		return fmt.Sprintf("%s (HTTP %d)", hint, statusCode)
+	}
+	if statusCode >= 200 && statusCode < 300 {
+		return "OK"
+	}
+	return fmt.Sprintf("HTTP %d %s", statusCode, http.StatusText(statusCode))
+}
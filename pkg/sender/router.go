@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+)
+
+// A Router dispatches the messages of an inputChan to the senders of their
+// logset, the messages of the other logsets going to defaultChan
+type Router struct {
+	inputChan   chan message.Message
+	defaultChan chan message.Message
+	routes      map[string]chan message.Message
+	// logset is the logset of the agent, that of the sources without one
+	logset string
+}
+
+// NewRouter returns a Router sending the messages of the logsets of routes,
+// lower case, to their channel, logset being the logset of the agent
+func NewRouter(inputChan, defaultChan chan message.Message, routes map[string]chan message.Message, logset string) *Router {
+	return &Router{
+		inputChan:   inputChan,
+		defaultChan: defaultChan,
+		routes:      routes,
+		logset:      logset,
+	}
+}
+
+// Start starts the Router
+func (r *Router) Start() {
+	go r.run()
+}
+
+// run dispatches the messages until inputChan is closed, then closes the
+// channels of the senders
+func (r *Router) run() {
+	for msg := range r.inputChan {
+		r.route(msg) <- msg
+	}
+	close(r.defaultChan)
+	for _, c := range r.routes {
+		close(c)
+	}
+}
+
+// route returns the channel of the logset of msg
+func (r *Router) route(msg message.Message) chan message.Message {
+	logset := logsetOf(msg)
+	if logset == "" && message.IsEndOfStream(msg) {
+		logset = r.logset
+	}
+	if c, exists := r.routes[strings.ToLower(logset)]; exists {
+		return c
+	}
+	return r.defaultChan
+}
+
+// logsetOf returns the logset msg is sent to. The payloads start with the
+// credentials they are sent with, apikey[/logset], which the messages
+// uploaded from the spool keep, while the end of stream messages carry none
+// and follow the messages of their source
+func logsetOf(msg message.Message) string {
+	if message.IsEndOfStream(msg) {
+		origin := msg.GetOrigin()
+		switch {
+		case origin == nil:
+			return ""
+		case origin.APIKey != "" || origin.Logset != "":
+			return origin.Logset
+		case origin.LogSource != nil:
+			return origin.LogSource.Logset
+		}
+		return ""
+	}
+	payload := msg.Content()
+	if i := bytes.IndexByte(payload, ' '); i >= 0 {
+		payload = payload[:i]
+	}
+	if i := bytes.IndexByte(payload, '/'); i >= 0 {
+		return string(payload[i+1:])
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+)
+
+func TestRouterDispatchesByLogset(t *testing.T) {
+	inputChan := make(chan message.Message, 10)
+	defaultChan := make(chan message.Message, 10)
+	euChan := make(chan message.Message, 10)
+	NewRouter(inputChan, defaultChan, map[string]chan message.Message{"eu": euChan}, "eu").Start()
+
+	inputChan <- message.NewMessage([]byte("apikey/EU <46>0 - hello\n"))
+	inputChan <- message.NewMessage([]byte("apikey/us <46>0 - hello\n"))
+	inputChan <- message.NewMessage([]byte("apikey <46>0 - hello/eu\n"))
+
+	// the end of stream messages follow the messages of their source
+	origin := message.NewOrigin()
+	origin.LogSource = &config.IntegrationConfigLogSource{Logset: "us"}
+	inputChan <- message.NewEndOfStreamMessage(origin)
+	origin = message.NewOrigin()
+	origin.LogSource = &config.IntegrationConfigLogSource{}
+	inputChan <- message.NewEndOfStreamMessage(origin)
+	close(inputChan)
+
+	var routed, others []message.Message
+	for msg := range euChan {
+		routed = append(routed, msg)
+	}
+	for msg := range defaultChan {
+		others = append(others, msg)
+	}
+	assert.Len(t, routed, 2)
+	assert.Equal(t, "apikey/EU <46>0 - hello\n", string(routed[0].Content()))
+	assert.True(t, message.IsEndOfStream(routed[1]))
+	assert.Len(t, others, 3)
+	assert.True(t, message.IsEndOfStream(others[2]))
+}
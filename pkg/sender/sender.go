@@ -6,11 +6,19 @@
 package sender
 
 import (
+	"log"
 	"net"
+	"time"
 
+	"github.com/DataDog/datadog-log-agent/pkg/fault"
 	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
 )
 
+// sendLatency measures the time spent writing each message to the intake,
+// or posting each batch when using http, including reconnections
+var sendLatency = metrics.NewHistogram("logs_send_latency_seconds", metrics.LatencyBuckets)
+
 // A Sender sends messages from an inputChan to datadog's intake,
 // handling connections and retries
 type Sender struct {
@@ -18,6 +26,8 @@ type Sender struct {
 	outputChan  chan message.Message
 	connManager *ConnectionManager
 	conn        net.Conn
+
+	lastDNSCheck time.Time
 }
 
 // New returns an initialized Sender
@@ -37,15 +47,23 @@ func (s *Sender) Start() {
 // run lets the sender wire messages
 func (s *Sender) run() {
 	for payload := range s.inputChan {
+		if message.IsEndOfStream(payload) {
+			// every message of the stream was written
+			s.outputChan <- payload
+			continue
+		}
 		s.wireMessage(payload)
 	}
 }
 
 // wireMessage lets the Sender send a message to datadog's intake
 func (s *Sender) wireMessage(payload message.Message) {
+	start := time.Now()
+	s.checkConnection()
 	for {
 		if s.conn == nil {
 			s.conn = s.connManager.NewConnection() // blocks until a new conn is ready
+			s.lastDNSCheck = time.Now()
 		}
 		_, err := s.conn.Write(payload.Content())
 		if err != nil {
@@ -54,7 +72,24 @@ func (s *Sender) wireMessage(payload message.Message) {
 			continue
 		}
 
+		sendLatency.ObserveSince(start)
+		fault.DelayAck()
 		s.outputChan <- payload
 		return
 	}
 }
+
+// checkConnection periodically closes the current connection if the intake
+// endpoint has moved, so that we don't stay pinned to a dead address
+func (s *Sender) checkConnection() {
+	refreshInterval := s.connManager.Dialer().RefreshInterval()
+	if s.conn == nil || refreshInterval <= 0 || time.Since(s.lastDNSCheck) < refreshInterval {
+		return
+	}
+	s.lastDNSCheck = time.Now()
+	if s.connManager.IsStale(s.conn) {
+		log.Println("Intake endpoint address changed, reconnecting")
+		s.connManager.CloseConnection(s.conn)
+		s.conn = nil
+	}
+}
@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+const (
+	minThrottleDuration = 1 * time.Second
+	maxThrottleDuration = 5 * time.Minute
+)
+
+// A Throttler pauses all sends to a destination when the intake
+// asks us to slow down. When the intake doesn't say for how long,
+// the pause doubles on every consecutive rate-limited response
+type Throttler struct {
+	destination  string
+	mutex        sync.Mutex
+	pausedUntil  time.Time
+	nextDuration time.Duration
+}
+
+var (
+	throttlers      = make(map[string]*Throttler)
+	throttlersMutex = &sync.Mutex{}
+)
+
+// GetThrottler returns the Throttler shared by all the senders of a destination
+func GetThrottler(destination string) *Throttler {
+	throttlersMutex.Lock()
+	defer throttlersMutex.Unlock()
+	t, ok := throttlers[destination]
+	if !ok {
+		t = NewThrottler(destination)
+		throttlers[destination] = t
+		status.Register("throttling "+destination, t.Status)
+	}
+	return t
+}
+
+// NewThrottler returns an initialized Throttler
+func NewThrottler(destination string) *Throttler {
+	return &Throttler{
+		destination:  destination,
+		nextDuration: minThrottleDuration,
+	}
+}
+
+// Throttle pauses sends for retryAfter, or for an increasing
+// duration if retryAfter is not set
+func (t *Throttler) Throttle(retryAfter time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	duration := retryAfter
+	if duration <= 0 {
+		duration = t.nextDuration
+		t.nextDuration *= 2
+		if t.nextDuration > maxThrottleDuration {
+			t.nextDuration = maxThrottleDuration
+		}
+	}
+	until := time.Now().Add(duration)
+	if until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+// Reset lets the throttler forget about previous rate-limited responses
+func (t *Throttler) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.nextDuration = minThrottleDuration
+}
+
+// Wait blocks until sends are allowed again
+func (t *Throttler) Wait() {
+	for {
+		remaining := t.remaining()
+		if remaining <= 0 {
+			return
+		}
+		time.Sleep(remaining)
+	}
+}
+
+// IsThrottled returns true if sends are currently paused
+func (t *Throttler) IsThrottled() bool {
+	return t.remaining() > 0
+}
+
+// Status returns the throttling state of the destination
+func (t *Throttler) Status() interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if time.Now().After(t.pausedUntil) {
+		return "not throttled"
+	}
+	return "throttled until " + t.pausedUntil.UTC().Format(time.RFC3339)
+}
+
+// remaining returns for how long sends are still paused
+func (t *Throttler) remaining() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.pausedUntil.Sub(time.Now())
+}
+
+// parseRetryAfter returns the duration the intake asks us to wait,
+// given the value of a Retry-After header, or 0 if it's not set or invalid.
+// See https://tools.ietf.org/html/rfc7231#section-7.1.3
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if date.Before(now) {
+			return 0
+		}
+		return date.Sub(now)
+	}
+	return 0
+}
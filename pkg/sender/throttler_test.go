@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2017, time.October, 12, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter("Thu, 12 Oct 2017 10:00:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Thu, 12 Oct 2017 09:00:00 GMT", now))
+}
+
+func TestThrottler(t *testing.T) {
+	throttler := NewThrottler("test")
+	assert.False(t, throttler.IsThrottled())
+	assert.Equal(t, "not throttled", throttler.Status())
+
+	throttler.Throttle(time.Minute)
+	assert.True(t, throttler.IsThrottled())
+	assert.Contains(t, throttler.Status(), "throttled until")
+
+	// without Retry-After, the pause doubles on each consecutive throttling
+	throttler = NewThrottler("test")
+	throttler.Throttle(0)
+	assert.Equal(t, 2*minThrottleDuration, throttler.nextDuration)
+	throttler.Throttle(0)
+	assert.Equal(t, 4*minThrottleDuration, throttler.nextDuration)
+	throttler.Reset()
+	assert.Equal(t, minThrottleDuration, throttler.nextDuration)
+
+	throttler = NewThrottler("test")
+	throttler.Throttle(10 * time.Millisecond)
+	throttler.Wait()
+	assert.False(t, throttler.IsThrottled())
+}
+
+func TestGetThrottlerIsSharedPerDestination(t *testing.T) {
+	assert.True(t, GetThrottler("a") == GetThrottler("a"))
+	assert.False(t, GetThrottler("a") == GetThrottler("b"))
+}
@@ -0,0 +1,217 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package sender
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/config"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/processor"
+	"github.com/stretchr/testify/assert"
+)
+
+// run `go test ./pkg/sender -update` to regenerate the golden files after an intended change of the wire format
+var updateGolden = flag.Bool("update", false, "update the golden files of the wire format")
+
+const (
+	goldenDir       = "tests/golden"
+	goldenAPIKey    = "0123456789abcdef"
+	goldenHostname  = "golden-host"
+	goldenTimestamp = "2017-10-16T10:00:00.000000000Z"
+)
+
+// A wireFormatCase describes messages going through a processor and a sender
+type wireFormatCase struct {
+	name     string
+	useHTTP  bool
+	logset   string
+	source   config.IntegrationConfigLogSource
+	contents []string
+}
+
+var wireFormatCases = []wireFormatCase{
+	{
+		name: "tcp_file_message",
+		source: config.IntegrationConfigLogSource{
+			Service:        "nginx",
+			Source:         "nginx",
+			SourceCategory: "http_access",
+			Tags:           "env:prod,team:web",
+		},
+		contents: []string{"GET /index.html 200"},
+	},
+	{
+		name:     "tcp_source_logset",
+		logset:   "playground",
+		source:   config.IntegrationConfigLogSource{Logset: "devteam"},
+		contents: []string{"hello world"},
+	},
+	{
+		name:     "tcp_rfc5424_passthrough",
+		source:   config.IntegrationConfigLogSource{Tags: "env:prod"},
+		contents: []string{"<46>1 2017-10-16T10:00:00Z remote-host app - - - already formatted"},
+	},
+	{
+		name: "tcp_masked_sequences",
+		source: config.IntegrationConfigLogSource{
+			Service: "payments",
+			ProcessingRules: []config.LogsProcessingRule{{
+				Type:                    config.MASK_SEQUENCES,
+				Name:                    "mask_cards",
+				Reg:                     regexp.MustCompile("[0-9]{16}"),
+				ReplacePlaceholderBytes: []byte("[masked_card]"),
+			}},
+		},
+		contents: []string{"paid with 4111111111111111"},
+	},
+	{
+		name:    "http_batch",
+		useHTTP: true,
+		source: config.IntegrationConfigLogSource{
+			Service: "app",
+			Source:  "python",
+			Tags:    "env:prod,version:1.0",
+		},
+		contents: []string{"first log", "second log"},
+	},
+	{
+		name:    "http_escaping",
+		useHTTP: true,
+		source: config.IntegrationConfigLogSource{
+			Source: `we"ird]`,
+			Tags:   `path:C:\logs,quote:"a"`,
+		},
+		contents: []string{`message with "quotes" and \backslashes\ and ]brackets[`},
+	},
+}
+
+func TestWireFormat(t *testing.T) {
+	defer config.LogsAgent.Set("log_use_http", false)
+	config.LogsAgent.Set("hostname", goldenHostname)
+	for _, c := range wireFormatCases {
+		messages := processGoldenCase(t, c)
+		var wire []byte
+		if c.useHTTP {
+			wire = sendHTTP(t, messages)
+		} else {
+			wire = sendTCP(t, messages)
+		}
+		assertGolden(t, c.name, wire)
+	}
+}
+
+// processGoldenCase returns the messages of c once processed
+func processGoldenCase(t *testing.T, c wireFormatCase) []message.Message {
+	config.LogsAgent.Set("log_use_http", c.useHTTP)
+	source := c.source
+	source.TagsPayload = config.BuildTagsPayload(&source, source.Tags)
+
+	inputChan := make(chan message.Message, len(c.contents))
+	outputChan := make(chan message.Message, len(c.contents))
+	processor.New(inputChan, outputChan, goldenAPIKey, c.logset, nil).Start()
+	for _, content := range c.contents {
+		msg := message.NewFileMessage([]byte(content))
+		origin := message.NewOrigin()
+		origin.LogSource = &source
+		origin.Timestamp = goldenTimestamp
+		msg.SetOrigin(origin)
+		inputChan <- msg
+	}
+	close(inputChan)
+
+	messages := []message.Message{}
+	for range c.contents {
+		select {
+		case msg := <-outputChan:
+			messages = append(messages, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("%s: message not processed", c.name)
+		}
+	}
+	return messages
+}
+
+// sendTCP returns the bytes the Sender writes on the wire for messages
+func sendTCP(t *testing.T, messages []message.Message) []byte {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var wire []byte
+		for range messages {
+			line, err := reader.ReadBytes('\n')
+			wire = append(wire, line...)
+			if err != nil {
+				break
+			}
+		}
+		received <- wire
+	}()
+
+	dialer, err := NewDialer("", "", "", "", 0)
+	assert.Nil(t, err)
+	cm := NewConnectionManager("127.0.0.1", listener.Addr().(*net.TCPAddr).Port, true, dialer)
+	inputChan := make(chan message.Message, len(messages))
+	outputChan := make(chan message.Message, len(messages))
+	New(inputChan, outputChan, cm).Start()
+	for _, msg := range messages {
+		inputChan <- msg
+	}
+	select {
+	case wire := <-received:
+		return wire
+	case <-time.After(time.Second):
+		t.Fatal("messages not sent")
+		return nil
+	}
+}
+
+// sendHTTP returns the body the HTTPSender posts for messages sent as a single batch
+func sendHTTP(t *testing.T, messages []message.Message) []byte {
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer server.Close()
+
+	batch := []*pendingMessage{}
+	for _, msg := range messages {
+		batch = append(batch, &pendingMessage{msg: msg})
+	}
+	s := NewHTTPSender(nil, make(chan message.Message, len(messages)), server.URL, 0, nil)
+	s.sendBatch(batch)
+	return <-bodies
+}
+
+// assertGolden compares wire with the content of the golden file of name
+func assertGolden(t *testing.T, name string, wire []byte) {
+	path := filepath.Join(goldenDir, name+".golden")
+	if *updateGolden {
+		assert.Nil(t, ioutil.WriteFile(path, wire, 0644))
+		return
+	}
+	golden, err := ioutil.ReadFile(path)
+	assert.Nil(t, err, name)
+	assert.Equal(t, string(golden), string(wire), "%s: the wire format changed, run with -update if this is intended", name)
+}
@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package spool
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// errUndecryptable is returned when a record can't be decrypted, for instance
+// because it was written with another key or without encryption
+var errUndecryptable = errors.New("can't decrypt spooled payload")
+
+// Encrypt makes the spool encrypt the payloads it stores with AES-GCM,
+// key being 16, 24 or 32 bytes long. It must be called before any read or write.
+// The payloads spooled before are still read, unencrypted
+func (s *Spool) Encrypt(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.aead = aead
+	return nil
+}
+
+// seal returns the record stored for payload: its random nonce followed by its ciphertext
+func seal(aead cipher.AEAD, payload []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, payload, nil), nil
+}
+
+// open returns the payload of a record sealed with seal, aead being nil when
+// the spool no longer encrypts its payloads
+func open(aead cipher.AEAD, record []byte) ([]byte, error) {
+	if aead == nil || len(record) < aead.NonceSize() {
+		return nil, errUndecryptable
+	}
+	nonce := record[:aead.NonceSize()]
+	payload, err := aead.Open(nil, nonce, record[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errUndecryptable
+	}
+	return payload, nil
+}
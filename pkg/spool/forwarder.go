@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package spool
+
+import (
+	"log"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/deadletter"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/DataDog/datadog-log-agent/pkg/metrics"
+	"github.com/DataDog/datadog-log-agent/pkg/sender"
+	"github.com/DataDog/datadog-log-agent/pkg/utils"
+)
+
+const (
+	defaultProbePeriod = 30 * time.Second
+	// writeRetries is the number of times a failed write is retried, with a
+	// backoff doubling from defaultWriteBackoff, before the message is dropped
+	writeRetries        = 3
+	defaultWriteBackoff = 100 * time.Millisecond
+)
+
+// droppedMessages counts the messages which could be neither spooled nor sent
+var droppedMessages = metrics.NewCounter("logs_spool_dropped_messages")
+
+// A Writer stores the messages of an inputChan in a spool. Once stored,
+// a message is safe: it is forwarded to the auditor so that its offset is committed
+type Writer struct {
+	spool        *Spool
+	inputChan    chan message.Message
+	outputChan   chan message.Message
+	writeBackoff time.Duration
+}
+
+// NewWriter returns an initialized Writer
+func NewWriter(spool *Spool, inputChan, outputChan chan message.Message) *Writer {
+	return &Writer{
+		spool:        spool,
+		inputChan:    inputChan,
+		outputChan:   outputChan,
+		writeBackoff: defaultWriteBackoff,
+	}
+}
+
+// Start starts the Writer
+func (w *Writer) Start() {
+	go w.run()
+}
+
+// run writes the messages to the spool
+func (w *Writer) run() {
+	for msg := range w.inputChan {
+		if message.IsEndOfStream(msg) {
+			// the stream is safe once its messages are stored
+			w.outputChan <- msg
+			continue
+		}
+		if err := w.write(msg.Content()); err != nil {
+			w.drop(msg, err)
+		}
+		w.outputChan <- msg
+	}
+}
+
+// write stores payload in the spool, retrying with backoff on the errors that
+// may be transient, such as a full disk
+func (w *Writer) write(payload []byte) error {
+	backoff := w.writeBackoff
+	for retry := 0; ; retry++ {
+		err := w.spool.Write(payload)
+		if err == nil || err == ErrClosed || retry == writeRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// drop counts msg, which could be neither spooled nor sent, and acknowledges it
+// as not sent. Like the messages the senders give up on, it's still forwarded to
+// the auditor so that the offsets of the files move past it
+func (w *Writer) drop(msg message.Message, err error) {
+	log.Println("Dropping message, can't write it to the spool:", err)
+	droppedMessages.Add(1)
+	deadletter.Write(deadletter.ReasonSpoolError, msg, sender.WithoutAPIKey(msg.Content()))
+	msg.GetOrigin().Acknowledge(false)
+}
+
+// An Uploader reads the messages stored in a spool and forwards them to a sender,
+// as long as the intake is reachable. Messages are committed in the spool once the
+// sender delivered them, so the upload resumes where it stopped after a restart
+type Uploader struct {
+	spool         *Spool
+	outputChan    chan message.Message
+	committedChan chan message.Message
+	isReachable   func() bool
+	bandwidth     *utils.TokenBucket
+	probePeriod   time.Duration
+	lastProbe     time.Time
+}
+
+// NewUploader returns an initialized Uploader. Messages are sent to outputChan,
+// and the sender must forward them to committedChan once delivered.
+// isReachable is called before uploading to detect connectivity, and
+// maxBytesPerSecond caps the upload bandwidth, 0 meaning no cap
+func NewUploader(spool *Spool, outputChan, committedChan chan message.Message, isReachable func() bool, maxBytesPerSecond int64) *Uploader {
+	var bandwidth *utils.TokenBucket
+	if maxBytesPerSecond > 0 {
+		bandwidth = utils.NewTokenBucket(maxBytesPerSecond)
+	}
+	return &Uploader{
+		spool:         spool,
+		outputChan:    outputChan,
+		committedChan: committedChan,
+		isReachable:   isReachable,
+		bandwidth:     bandwidth,
+		probePeriod:   defaultProbePeriod,
+	}
+}
+
+// Start starts the Uploader
+func (u *Uploader) Start() {
+	go u.commit()
+	go u.run()
+}
+
+// run forwards the content of the spool to the sender
+func (u *Uploader) run() {
+	for {
+		payload, position, err := u.spool.Next()
+		if err != nil {
+			return
+		}
+		u.waitForConnectivity()
+		if u.bandwidth != nil {
+			u.bandwidth.Wait(len(payload))
+		}
+		msg := message.NewMessage(payload)
+		origin := message.NewOrigin()
+		origin.Offset = position.Encode()
+		msg.SetOrigin(origin)
+		u.outputChan <- msg
+	}
+}
+
+// waitForConnectivity blocks until the intake is reachable.
+// The intake is probed at most once per probe period while it's reachable
+func (u *Uploader) waitForConnectivity() {
+	if u.isReachable == nil || time.Since(u.lastProbe) < u.probePeriod {
+		return
+	}
+	for !u.isReachable() {
+		time.Sleep(u.probePeriod)
+	}
+	u.lastProbe = time.Now()
+}
+
+// commit marks the messages delivered by the sender as committed in the spool
+func (u *Uploader) commit() {
+	for msg := range u.committedChan {
+		u.spool.Commit(DecodePosition(msg.GetOrigin().Offset))
+	}
+}
@@ -0,0 +1,452 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package spool
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
+)
+
+const (
+	defaultSegmentSize = 16 * 1024 * 1024
+	recordHeaderLength = 4
+	segmentExtension   = ".spool"
+	cursorFileName     = "cursor.json"
+	cursorFlushPeriod  = 1 * time.Second
+
+	// recordEncrypted flags the length in the header of the encrypted records,
+	// the records spooled before the encryption was enabled being read as is
+	recordEncrypted = 1 << 31
+)
+
+// ErrClosed is returned when reading from a closed Spool
+var ErrClosed = errors.New("spool closed")
+
+// A Position locates the end of a record in the spool
+type Position struct {
+	Segment int64
+	Offset  int64
+}
+
+// Encode packs a position in an int64, so that it can travel with messages
+func (p Position) Encode() int64 {
+	return p.Segment<<40 | p.Offset
+}
+
+// DecodePosition unpacks a position packed with Encode
+func DecodePosition(encoded int64) Position {
+	return Position{
+		Segment: encoded >> 40,
+		Offset:  encoded & (1<<40 - 1),
+	}
+}
+
+// after returns true if p is located after other
+func (p Position) after(other Position) bool {
+	return p.Segment > other.Segment || (p.Segment == other.Segment && p.Offset > other.Offset)
+}
+
+// A Spool is a persistent FIFO queue of payloads, stored in segment files.
+// Payloads are read with Next, and must be committed once delivered:
+// on restart, reading resumes from the last committed position.
+// When the spool grows over its maximum size, the oldest segments are dropped
+type Spool struct {
+	dir         string
+	maxSize     int64
+	segmentSize int64
+
+	mutex *sync.Mutex
+	cond  *sync.Cond
+
+	size         int64
+	writeSegment int64
+	writeFile    *os.File
+	writeOffset  int64
+	readSegment  int64
+	readFile     *os.File
+	readOffset   int64
+	committed    Position
+	dirtyCursor  bool
+	closed       bool
+	done         chan struct{}
+
+	// aead encrypts the payloads when set
+	aead cipher.AEAD
+	// codec compresses the payloads when set, before they are encrypted
+	codec compression.Codec
+}
+
+// New returns a Spool storing its segments in dir, resuming from a previous run if any.
+// A maxSize of 0 means the spool size is not limited
+func New(dir string, maxSize int64) (*Spool, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	mutex := &sync.Mutex{}
+	s := &Spool{
+		dir:         dir,
+		maxSize:     maxSize,
+		segmentSize: defaultSegmentSize,
+		mutex:       mutex,
+		cond:        sync.NewCond(mutex),
+		done:        make(chan struct{}),
+	}
+	segments, err := s.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	s.committed = s.readCursor()
+
+	// remove the segments that were fully delivered
+	for len(segments) > 0 && segments[0] < s.committed.Segment {
+		os.Remove(s.segmentPath(segments[0]))
+		segments = segments[1:]
+	}
+	for _, segment := range segments {
+		if info, err := os.Stat(s.segmentPath(segment)); err == nil {
+			s.size += info.Size()
+		}
+	}
+
+	if len(segments) == 0 {
+		s.writeSegment = s.committed.Segment
+		s.readSegment = s.committed.Segment
+		if s.committed.Offset > 0 {
+			// the committed segment is gone, start with a new one
+			s.writeSegment++
+			s.readSegment++
+		}
+	} else {
+		s.writeSegment = segments[len(segments)-1]
+		if segments[0] == s.committed.Segment {
+			s.readSegment = s.committed.Segment
+			s.readOffset = s.committed.Offset
+		} else {
+			s.readSegment = segments[0]
+		}
+	}
+	err = s.openWriteSegment()
+	if err != nil {
+		return nil, err
+	}
+	go s.flushCursorPeriodically()
+	return s, nil
+}
+
+// Write appends payload to the spool
+func (s *Spool) Write(payload []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if s.codec != nil {
+		compressed, err := s.codec.Compress(payload)
+		if err != nil {
+			return err
+		}
+		payload = compressed
+	}
+	header := uint32(0)
+	if s.aead != nil {
+		sealed, err := seal(s.aead, payload)
+		if err != nil {
+			return err
+		}
+		payload = sealed
+		header = recordEncrypted
+	}
+	if s.writeOffset >= s.segmentSize {
+		s.writeFile.Close()
+		s.writeSegment++
+		err := s.openWriteSegment()
+		if err != nil {
+			return err
+		}
+	}
+	record := make([]byte, recordHeaderLength+len(payload))
+	binary.BigEndian.PutUint32(record, header|uint32(len(payload)))
+	copy(record[recordHeaderLength:], payload)
+	n, err := s.writeFile.Write(record)
+	s.writeOffset += int64(n)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	s.enforceMaxSize()
+	s.cond.Broadcast()
+	return nil
+}
+
+// Next blocks until a payload is available and returns it,
+// along with the position to commit once it's delivered
+func (s *Spool) Next() ([]byte, Position, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for {
+		if s.closed {
+			return nil, Position{}, ErrClosed
+		}
+		if s.readSegment == s.writeSegment && s.readOffset >= s.writeOffset {
+			s.cond.Wait()
+			continue
+		}
+		if s.readFile == nil {
+			f, err := os.Open(s.segmentPath(s.readSegment))
+			if err != nil {
+				// the segment was dropped, move to the next one
+				s.nextReadSegment()
+				continue
+			}
+			s.readFile = f
+		}
+		payload, encrypted, err := s.readRecord()
+		if err != nil {
+			if s.readSegment < s.writeSegment {
+				// end of a previous segment, or truncated record after a crash
+				s.nextReadSegment()
+			} else {
+				s.cond.Wait()
+			}
+			continue
+		}
+		s.readOffset += int64(recordHeaderLength + len(payload))
+		if encrypted {
+			payload, err = open(s.aead, payload)
+			if err != nil {
+				log.Println("Dropping spooled payload:", err)
+				continue
+			}
+		}
+		// the payloads spooled before the compression changed are read too
+		payload, err = compression.Decompress(payload)
+		if err != nil {
+			log.Println("Dropping spooled payload:", err)
+			continue
+		}
+		return payload, Position{Segment: s.readSegment, Offset: s.readOffset}, nil
+	}
+}
+
+// Compress makes the spool compress the payloads it stores with codec.
+// It must be called before any write
+func (s *Spool) Compress(codec compression.Codec) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.codec = codec
+}
+
+// Commit marks all the payloads up to position as delivered
+func (s *Spool) Commit(position Position) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !position.after(s.committed) {
+		return
+	}
+	for segment := s.committed.Segment; segment < position.Segment; segment++ {
+		s.removeSegment(segment)
+	}
+	s.committed = position
+	s.dirtyCursor = true
+}
+
+// Size returns the size in bytes of the data stored in the spool
+func (s *Spool) Size() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.size
+}
+
+// Indexes returns the last committed position, the position of the next payload to read
+// and the position of the next payload to write
+func (s *Spool) Indexes() (committed, read, write Position) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.committed, Position{Segment: s.readSegment, Offset: s.readOffset}, Position{Segment: s.writeSegment, Offset: s.writeOffset}
+}
+
+// Close flushes the cursor and releases the spool files
+func (s *Spool) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.flushCursor()
+	s.writeFile.Close()
+	if s.readFile != nil {
+		s.readFile.Close()
+	}
+	s.cond.Broadcast()
+}
+
+// readRecord reads the record located at the current read offset, and
+// whether its payload is encrypted
+func (s *Spool) readRecord() ([]byte, bool, error) {
+	header := make([]byte, recordHeaderLength)
+	_, err := s.readFile.ReadAt(header, s.readOffset)
+	if err != nil {
+		return nil, false, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	payload := make([]byte, length&^recordEncrypted)
+	_, err = s.readFile.ReadAt(payload, s.readOffset+recordHeaderLength)
+	if err != nil {
+		return nil, false, io.ErrUnexpectedEOF
+	}
+	return payload, length&recordEncrypted != 0, nil
+}
+
+// nextReadSegment moves the reader to the beginning of the next segment
+func (s *Spool) nextReadSegment() {
+	if s.readFile != nil {
+		s.readFile.Close()
+		s.readFile = nil
+	}
+	s.readSegment++
+	s.readOffset = 0
+}
+
+// enforceMaxSize drops the oldest segments until the spool fits in its maximum size.
+// The segment currently written is never dropped
+func (s *Spool) enforceMaxSize() {
+	for s.maxSize > 0 && s.size > s.maxSize && s.committed.Segment < s.writeSegment {
+		dropped := s.committed.Segment
+		log.Println("Spool is full, dropping segment", dropped)
+		s.removeSegment(dropped)
+		s.committed = Position{Segment: dropped + 1}
+		s.dirtyCursor = true
+		if s.readSegment <= dropped {
+			if s.readFile != nil {
+				s.readFile.Close()
+				s.readFile = nil
+			}
+			s.readSegment = dropped + 1
+			s.readOffset = 0
+		}
+	}
+}
+
+// removeSegment deletes a segment file and updates the spool size
+func (s *Spool) removeSegment(segment int64) {
+	path := s.segmentPath(segment)
+	if info, err := os.Stat(path); err == nil {
+		s.size -= info.Size()
+	}
+	os.Remove(path)
+}
+
+// openWriteSegment opens the segment currently written
+func (s *Spool) openWriteSegment() error {
+	f, err := os.OpenFile(s.segmentPath(s.writeSegment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.writeFile = f
+	s.writeOffset = info.Size()
+	return nil
+}
+
+// listSegments returns the sorted sequence numbers of the segments found on disk
+func (s *Spool) listSegments() ([]int64, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []int64
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != segmentExtension {
+			continue
+		}
+		segment, err := strconv.ParseInt(strings.TrimSuffix(f.Name(), segmentExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (s *Spool) segmentPath(segment int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", segment, segmentExtension))
+}
+
+// readCursor returns the last committed position persisted on disk
+func (s *Spool) readCursor() Position {
+	var position Position
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, cursorFileName))
+	if err != nil {
+		return position
+	}
+	err = json.Unmarshal(b, &position)
+	if err != nil {
+		log.Println("Can't read spool cursor:", err)
+		return Position{}
+	}
+	return position
+}
+
+// flushCursorPeriodically persists the committed position when it changes
+func (s *Spool) flushCursorPeriodically() {
+	ticker := time.NewTicker(cursorFlushPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.flushCursor()
+			s.mutex.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// flushCursor writes the committed position on disk
+func (s *Spool) flushCursor() {
+	if !s.dirtyCursor {
+		return
+	}
+	b, err := json.Marshal(s.committed)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	path := filepath.Join(s.dir, cursorFileName)
+	err = ioutil.WriteFile(path+".tmp", b, 0600)
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		log.Println("Can't write spool cursor:", err)
+		return
+	}
+	s.dirtyCursor = false
+}
@@ -0,0 +1,181 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package spool
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-log-agent/pkg/compression"
+	"github.com/DataDog/datadog-log-agent/pkg/message"
+	"github.com/stretchr/testify/suite"
+)
+
+type SpoolTestSuite struct {
+	suite.Suite
+
+	dir   string
+	spool *Spool
+}
+
+func (suite *SpoolTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "spool")
+	suite.Nil(err)
+	suite.spool, err = New(suite.dir, 0)
+	suite.Nil(err)
+}
+
+func (suite *SpoolTestSuite) TearDownTest() {
+	suite.spool.Close()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *SpoolTestSuite) reopen() {
+	suite.spool.Close()
+	var err error
+	suite.spool, err = New(suite.dir, 0)
+	suite.Nil(err)
+}
+
+func (suite *SpoolTestSuite) next() string {
+	payload, _, err := suite.spool.Next()
+	suite.Nil(err)
+	return string(payload)
+}
+
+func (suite *SpoolTestSuite) TestWriteAndRead() {
+	suite.Nil(suite.spool.Write([]byte("hello")))
+	suite.Nil(suite.spool.Write([]byte("world")))
+	suite.Equal("hello", suite.next())
+	suite.Equal("world", suite.next())
+	suite.Equal(int64(2*(recordHeaderLength+5)), suite.spool.Size())
+}
+
+func (suite *SpoolTestSuite) TestResumesFromCommittedPosition() {
+	suite.spool.Write([]byte("a"))
+	suite.spool.Write([]byte("b"))
+	suite.spool.Write([]byte("c"))
+	_, position, _ := suite.spool.Next()
+	suite.spool.Commit(position)
+	suite.next()
+
+	// b was read but not committed, it's read again after a restart
+	suite.reopen()
+	suite.Equal("b", suite.next())
+	suite.Equal("c", suite.next())
+}
+
+func (suite *SpoolTestSuite) TestRotatesAndRemovesCommittedSegments() {
+	suite.spool.segmentSize = 1
+	suite.spool.Write([]byte("a"))
+	suite.spool.Write([]byte("b"))
+	suite.spool.Write([]byte("c"))
+	segments, _ := suite.spool.listSegments()
+	suite.Equal([]int64{0, 1, 2}, segments)
+
+	suite.next()
+	_, position, _ := suite.spool.Next()
+	suite.spool.Commit(position)
+	segments, _ = suite.spool.listSegments()
+	suite.Equal([]int64{1, 2}, segments)
+
+	suite.reopen()
+	suite.Equal("c", suite.next())
+}
+
+func (suite *SpoolTestSuite) TestDropsOldestSegmentsWhenFull() {
+	suite.spool.segmentSize = 1
+	suite.spool.maxSize = 2 * (recordHeaderLength + 1)
+	suite.spool.Write([]byte("a"))
+	suite.spool.Write([]byte("b"))
+	suite.spool.Write([]byte("c"))
+	suite.Equal(int64(2*(recordHeaderLength+1)), suite.spool.Size())
+	suite.Equal("b", suite.next())
+	suite.Equal("c", suite.next())
+}
+
+func (suite *SpoolTestSuite) TestPositionEncoding() {
+	position := Position{Segment: 12, Offset: 345}
+	suite.Equal(position, DecodePosition(position.Encode()))
+}
+
+func (suite *SpoolTestSuite) TestEncryptsPayloads() {
+	key := []byte("0123456789abcdef")
+	suite.Nil(suite.spool.Encrypt(key))
+	suite.Nil(suite.spool.Write([]byte("secret log")))
+	suite.Equal("secret log", suite.next())
+
+	segment, err := ioutil.ReadFile(suite.spool.segmentPath(0))
+	suite.Nil(err)
+	suite.NotContains(string(segment), "secret log")
+
+	// records written with another key are skipped
+	suite.Nil(suite.spool.Encrypt([]byte("fedcba9876543210")))
+	suite.Nil(suite.spool.Write([]byte("first")))
+	suite.Nil(suite.spool.Encrypt(key))
+	suite.Nil(suite.spool.Write([]byte("second")))
+	suite.Equal("second", suite.next())
+
+	suite.NotNil(suite.spool.Encrypt([]byte("short")))
+}
+
+func (suite *SpoolTestSuite) TestReadsPlaintextBacklogOnceEncrypted() {
+	suite.Nil(suite.spool.Write([]byte("before encryption")))
+	suite.reopen()
+	suite.Nil(suite.spool.Encrypt([]byte("0123456789abcdef")))
+	suite.Nil(suite.spool.Write([]byte("encrypted")))
+	suite.Equal("before encryption", suite.next())
+	payload, position, err := suite.spool.Next()
+	suite.Nil(err)
+	suite.Equal("encrypted", string(payload))
+	suite.spool.Commit(position)
+
+	// the encrypted records can't be read once the encryption is disabled
+	suite.Nil(suite.spool.Write([]byte("unreadable")))
+	suite.reopen()
+	suite.Nil(suite.spool.Write([]byte("plaintext")))
+	suite.Equal("plaintext", suite.next())
+}
+
+func (suite *SpoolTestSuite) TestCompressesPayloads() {
+	suite.Nil(suite.spool.Write([]byte("before compression")))
+	codec, err := compression.New(compression.KindZstd, 0)
+	suite.Nil(err)
+	suite.spool.Compress(codec)
+	suite.Nil(suite.spool.Write([]byte(strings.Repeat("compressed log ", 100))))
+
+	suite.Equal("before compression", suite.next())
+	suite.Equal(strings.Repeat("compressed log ", 100), suite.next())
+	suite.True(suite.spool.Size() < 1000)
+}
+
+func (suite *SpoolTestSuite) TestWriterDropsMessagesItCantSpool() {
+	inputChan := make(chan message.Message, 1)
+	outputChan := make(chan message.Message, 1)
+	NewWriter(suite.spool, inputChan, outputChan).Start()
+	defer close(inputChan)
+
+	acks := make(chan bool, 1)
+	msg := message.NewMessage([]byte("apikey hello"))
+	origin := message.NewOrigin()
+	origin.Ack = func(sent bool) { acks <- sent }
+	msg.SetOrigin(origin)
+
+	// the spool can't be written once closed
+	suite.spool.Close()
+	before := droppedMessages.Value()
+	inputChan <- msg
+	suite.Equal(msg, <-outputChan)
+	suite.False(<-acks)
+	suite.Equal(int64(1), droppedMessages.Value()-before)
+}
+
+func TestSpoolTestSuite(t *testing.T) {
+	suite.Run(t, new(SpoolTestSuite))
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package status
+
+import (
+	"expvar"
+	"sync"
+)
+
+// A Provider returns the current state of a component of the agent
+type Provider func() interface{}
+
+var (
+	providers = make(map[string]Provider)
+	mutex     = &sync.Mutex{}
+)
+
+func init() {
+	expvar.Publish("logs_agent_status", expvar.Func(func() interface{} { return Get() }))
+}
+
+// Register adds a provider to the agent status under name,
+// replacing any provider previously registered with the same name
+func Register(name string, provider Provider) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	providers[name] = provider
+}
+
+// Set registers a static value in the agent status under name
+func Set(name string, value interface{}) {
+	Register(name, func() interface{} { return value })
+}
+
+// Get returns the current state of all the registered components
+func Get() map[string]interface{} {
+	mutex.Lock()
+	p := make(map[string]Provider, len(providers))
+	for name, provider := range providers {
+		p[name] = provider
+	}
+	mutex.Unlock()
+
+	s := make(map[string]interface{}, len(p))
+	for name, provider := range p {
+		s[name] = provider()
+	}
+	return s
+}
@@ -0,0 +1,31 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package status
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	counter := 0
+	Register("counter", func() interface{} {
+		counter++
+		return counter
+	})
+	Set("hello", "world")
+
+	s := Get()
+	assert.Equal(t, 1, s["counter"])
+	assert.Equal(t, "world", s["hello"])
+
+	s = Get()
+	assert.Equal(t, 2, s["counter"])
+
+	Set("hello", "you")
+	assert.Equal(t, "you", Get()["hello"])
+}
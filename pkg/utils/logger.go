@@ -7,7 +7,9 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
 )
 
 type logWriter struct {
@@ -21,3 +23,13 @@ func SetupLogger() {
 	log.SetFlags(0)
 	log.SetOutput(new(logWriter))
 }
+
+// SetupLogFile writes the logs of the agent to the file at path too
+func SetupLogFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(io.MultiWriter(new(logWriter), f))
+	return nil
+}
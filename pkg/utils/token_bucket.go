@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package utils
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// A TokenBucket limits a throughput to a rate of tokens per second,
+// allowing bursts of up to one second worth of tokens
+type TokenBucket struct {
+	mutex    sync.Mutex
+	rate     float64
+	tokens   float64
+	lastFill time.Time
+}
+
+// NewTokenBucket returns a TokenBucket allowing rate tokens per second
+func NewTokenBucket(rate int64) *TokenBucket {
+	return &TokenBucket{
+		rate:     float64(rate),
+		tokens:   float64(rate),
+		lastFill: time.Now(),
+	}
+}
+
+// Wait blocks until n tokens are available and consumes them.
+// Requests larger than the burst size are allowed but delay the next ones
+func (b *TokenBucket) Wait(n int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.fill()
+	b.tokens -= float64(n)
+	if b.tokens < 0 {
+		time.Sleep(time.Duration(-b.tokens / b.rate * float64(time.Second)))
+	}
+}
+
+// Take consumes n tokens without blocking, possibly leaving the bucket
+// in debt, which delays the next calls to Wait and TryTake
+func (b *TokenBucket) Take(n int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.fill()
+	b.tokens -= float64(n)
+}
+
+// TryTake consumes n tokens and returns true if they are available, requests
+// larger than the burst size only needing a full bucket. It returns false
+// without blocking otherwise
+func (b *TokenBucket) TryTake(n int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.fill()
+	if b.tokens < math.Min(float64(n), b.rate) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// fill adds the tokens accumulated since the last fill
+func (b *TokenBucket) fill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.lastFill = now
+}
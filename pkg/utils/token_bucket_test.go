@@ -0,0 +1,45 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketAllowsBurst(t *testing.T) {
+	b := NewTokenBucket(1000)
+	start := time.Now()
+	b.Wait(1000)
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+}
+
+func TestTokenBucketLimitsRate(t *testing.T) {
+	b := NewTokenBucket(1000)
+	start := time.Now()
+	b.Wait(1000)
+	b.Wait(200)
+	assert.True(t, time.Since(start) >= 150*time.Millisecond)
+}
+
+func TestTokenBucketTake(t *testing.T) {
+	b := NewTokenBucket(1000)
+	assert.True(t, b.TryTake(600))
+	assert.False(t, b.TryTake(600))
+	assert.True(t, b.TryTake(400))
+	// requests larger than the burst size need a full bucket
+	b = NewTokenBucket(1000)
+	assert.True(t, b.TryTake(4096))
+	assert.False(t, b.TryTake(1))
+
+	b = NewTokenBucket(1000)
+	b.Take(1500)
+	assert.False(t, b.TryTake(1))
+	time.Sleep(600 * time.Millisecond)
+	assert.True(t, b.TryTake(1))
+}
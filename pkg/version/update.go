@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-log-agent/pkg/status"
+)
+
+const (
+	// ReleasesURL lists the latest release of the agent
+	ReleasesURL        = "https://api.github.com/repos/DataDog/datadog-log-agent/releases/latest"
+	updateCheckPeriod  = 24 * time.Hour
+	updateCheckTimeout = 10 * time.Second
+)
+
+// An UpdateChecker periodically looks for a release newer than the running version
+type UpdateChecker struct {
+	url     string
+	current string
+	client  *http.Client
+
+	mutex  sync.Mutex
+	latest string
+	err    error
+}
+
+// NewUpdateChecker returns an initialized UpdateChecker
+func NewUpdateChecker(url, current string) *UpdateChecker {
+	return &UpdateChecker{
+		url:     url,
+		current: current,
+		client:  &http.Client{Timeout: updateCheckTimeout},
+	}
+}
+
+// Start checks for updates now and then every day, reporting the result in the status
+func (c *UpdateChecker) Start() {
+	status.Register("update", c.Status)
+	go func() {
+		for {
+			c.Check()
+			time.Sleep(updateCheckPeriod)
+		}
+	}()
+}
+
+// Check fetches the latest release
+func (c *UpdateChecker) Check() {
+	latest, err := c.fetchLatest()
+	if err != nil {
+		log.Println("Can't check for updates:", err)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.err = err
+	if err == nil {
+		c.latest = latest
+	}
+}
+
+// Status returns whether a newer release is available
+func (c *UpdateChecker) Status() interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+	case c.latest == "" && c.err != nil:
+		return fmt.Sprintf("unknown: %v", c.err)
+	case c.latest == "":
+		return "unknown"
+	case isNewer(c.latest, c.current):
+		return fmt.Sprintf("version %s is available", c.latest)
+	default:
+		return "up to date"
+	}
+}
+
+// fetchLatest returns the version of the latest release
+func (c *UpdateChecker) fetchLatest() (string, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("releases endpoint returned %s", resp.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&release)
+	if err != nil {
+		return "", err
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("no release found")
+	}
+	return release.TagName, nil
+}
+
+// isNewer returns true if version a is strictly greater than version b.
+// Versions are compared number by number, ignoring a leading v and pre-release suffixes;
+// development builds are never considered outdated
+func isNewer(a, b string) bool {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na = pa[i]
+		}
+		if i < len(pb) {
+			nb = pb[i]
+		}
+		if na != nb {
+			return na > nb
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	v = strings.SplitN(v, "-", 2)[0]
+	numbers := []int{}
+	for _, part := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, true
+}
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package version
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNewer(t *testing.T) {
+	assert.True(t, isNewer("v1.2.0", "1.1.9"))
+	assert.True(t, isNewer("1.10.0", "1.9.0"))
+	assert.True(t, isNewer("1.2.1", "1.2"))
+	assert.False(t, isNewer("1.2.0", "1.2.0"))
+	assert.False(t, isNewer("1.2.0-rc1", "1.2.0"))
+	assert.False(t, isNewer("1.1.0", "1.2.0"))
+	assert.False(t, isNewer("1.2.0", "dev"))
+}
+
+func TestUpdateCheckerStatus(t *testing.T) {
+	latest := "v1.3.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "%s"}`, latest)
+	}))
+	defer server.Close()
+
+	checker := NewUpdateChecker(server.URL, "1.2.0")
+	assert.Equal(t, "unknown", checker.Status())
+	checker.Check()
+	assert.Equal(t, "version v1.3.0 is available", checker.Status())
+
+	latest = "v1.2.0"
+	checker.Check()
+	assert.Equal(t, "up to date", checker.Status())
+}
+
+func TestUpdateCheckerStatusOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	checker := NewUpdateChecker(server.URL, "1.2.0")
+	checker.Check()
+	assert.Equal(t, "unknown: releases endpoint returned 404 Not Found", checker.Status())
+}
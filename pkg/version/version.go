@@ -0,0 +1,34 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017 Datadog, Inc.
+
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Build information, set at build time with
+// -ldflags "-X github.com/DataDog/datadog-log-agent/pkg/version.Version=..."
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info returns the build information of the agent
+func Info() map[string]string {
+	return map[string]string{
+		"version":    Version,
+		"commit":     Commit,
+		"build_date": BuildDate,
+		"go_version": runtime.Version(),
+	}
+}
+
+// String returns a human readable description of the build
+func String() string {
+	return fmt.Sprintf("logagent %s (commit %s, built %s with %s)", Version, Commit, BuildDate, runtime.Version())
+}